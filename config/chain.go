@@ -0,0 +1,143 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"dex-aggregator/internal/types"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const uniswapV2FactoryABI = `[
+	{"constant":true,"inputs":[],"name":"feeTo","outputs":[{"name":"","type":"address"}],"type":"function"}
+]`
+
+var factoryABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(uniswapV2FactoryABI))
+	if err != nil {
+		panic(fmt.Sprintf("invalid factory ABI: %v", err))
+	}
+	return parsed
+}()
+
+// FactoryReader is the subset of ethclient.Client needed to inspect factory contracts
+type FactoryReader interface {
+	ethereum.ContractCaller
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+}
+
+// v3FactoryMinCodeSize separates Uniswap V3 factories, which embed the pool creation
+// code, from other contracts that do not implement the V2 feeTo() interface
+const v3FactoryMinCodeSize = 20000
+
+// chainLoadTimeout bounds exchange discovery during Init
+const chainLoadTimeout = 10 * time.Second
+
+// LoadExchangesFromChain inspects each factory contract and returns an exchange for
+// every factory it recognises. Factories answering IUniswapV2Factory.feeTo() are V2;
+// otherwise the version is derived from the size of the deployed bytecode.
+// Unrecognised factories are skipped with a warning.
+func LoadExchangesFromChain(ctx context.Context, client FactoryReader, factoryAddresses []string) ([]types.Exchange, error) {
+	exchanges := make([]types.Exchange, 0, len(factoryAddresses))
+
+	for _, factoryAddress := range factoryAddresses {
+		if !common.IsHexAddress(factoryAddress) {
+			return nil, fmt.Errorf("invalid factory address: %s", factoryAddress)
+		}
+		factory := common.HexToAddress(factoryAddress)
+
+		code, err := client.CodeAt(ctx, factory, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read code of factory %s: %v", factory.Hex(), err)
+		}
+		if len(code) == 0 {
+			log.Printf("Warning: No contract deployed at factory %s, skipping", factory.Hex())
+			continue
+		}
+
+		version, err := factoryVersion(ctx, client, factory, code)
+		if err != nil {
+			log.Printf("Warning: Unrecognised factory %s, skipping: %v", factory.Hex(), err)
+			continue
+		}
+
+		exchanges = append(exchanges, types.Exchange{
+			Name:    fmt.Sprintf("Factory %s", factory.Hex()[:10]),
+			Factory: factory.Hex(),
+			Version: version,
+		})
+	}
+
+	return exchanges, nil
+}
+
+func factoryVersion(ctx context.Context, client ethereum.ContractCaller, factory common.Address, code []byte) (string, error) {
+	data, err := factoryABI.Pack("feeTo")
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.CallContract(ctx, ethereum.CallMsg{To: &factory, Data: data}, nil)
+	if err == nil {
+		if _, err = factoryABI.Unpack("feeTo", result); err == nil {
+			return "v2", nil
+		}
+	}
+
+	if len(code) >= v3FactoryMinCodeSize {
+		return "v3", nil
+	}
+	return "", fmt.Errorf("feeTo() failed and %d bytes of code is too small for a V3 factory: %v", len(code), err)
+}
+
+// MergeExchanges appends discovered exchanges to the configured ones. A configured
+// exchange takes precedence over a discovered exchange with the same factory address.
+func MergeExchanges(configured, discovered []types.Exchange) []types.Exchange {
+	merged := make([]types.Exchange, 0, len(configured)+len(discovered))
+	seen := make(map[string]bool, len(configured))
+
+	for _, exchange := range configured {
+		seen[strings.ToLower(exchange.Factory)] = true
+		merged = append(merged, exchange)
+	}
+	for _, exchange := range discovered {
+		factory := strings.ToLower(exchange.Factory)
+		if seen[factory] {
+			continue
+		}
+		seen[factory] = true
+		merged = append(merged, exchange)
+	}
+
+	return merged
+}
+
+// loadLiveExchanges merges exchanges discovered from the configured factories into cfg
+func loadLiveExchanges(cfg *Config) {
+	ctx, cancel := context.WithTimeout(context.Background(), chainLoadTimeout)
+	defer cancel()
+
+	client, err := ethclient.DialContext(ctx, cfg.Ethereum.RPCURL)
+	if err != nil {
+		log.Printf("Warning: Failed to connect to Ethereum RPC, using configured exchanges only: %v", err)
+		return
+	}
+	defer client.Close()
+
+	discovered, err := LoadExchangesFromChain(ctx, client, cfg.DEX.Factories)
+	if err != nil {
+		log.Printf("Warning: Failed to load exchanges from chain, using configured exchanges only: %v", err)
+		return
+	}
+
+	cfg.DEX.Exchanges = MergeExchanges(cfg.DEX.Exchanges, discovered)
+	log.Printf("Loaded %d exchanges from %d factories on chain", len(discovered), len(cfg.DEX.Factories))
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"dex-aggregator/internal/types"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubFactoryReader serves fake factory bytecode and feeTo() results by address
+type stubFactoryReader struct {
+	code  map[common.Address][]byte
+	feeTo map[common.Address]common.Address
+}
+
+func (s *stubFactoryReader) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return s.code[account], nil
+}
+
+func (s *stubFactoryReader) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	feeTo, ok := s.feeTo[*call.To]
+	if !ok {
+		return nil, errors.New("execution reverted")
+	}
+	return factoryABI.Methods["feeTo"].Outputs.Pack(feeTo)
+}
+
+func TestLoadExchangesFromChain(t *testing.T) {
+	uniswapV2 := common.HexToAddress("0x5C69bEe701ef814a2B6a3EDD4B1652CB9cc5aA6f")
+	sushiSwap := common.HexToAddress("0xC0AEe478e3658e2610c5F7A4A2E1777cE9e4f2Ac")
+	uniswapV3 := common.HexToAddress("0x1F98431c8aD98523631AE4a59f267346ea31F984")
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	empty := common.HexToAddress("0x0000000000000000000000000000000000000001")
+
+	client := &stubFactoryReader{
+		code: map[common.Address][]byte{
+			uniswapV2: make([]byte, 12000),
+			sushiSwap: make([]byte, 12000),
+			uniswapV3: make([]byte, 24000),
+			token:     make([]byte, 4000),
+		},
+		feeTo: map[common.Address]common.Address{
+			uniswapV2: {},
+			sushiSwap: common.HexToAddress("0x8f1E7f5b5BE1Dd4b49D8B2dC5E8EF6aC6D6C1f3b"),
+		},
+	}
+
+	discovered, err := LoadExchangesFromChain(context.Background(), client, []string{
+		uniswapV2.Hex(), sushiSwap.Hex(), uniswapV3.Hex(), token.Hex(), empty.Hex(),
+	})
+	assert.NoError(t, err)
+
+	// The token contract and the empty address are skipped
+	assert.Len(t, discovered, 3)
+	assert.Equal(t, uniswapV2.Hex(), discovered[0].Factory)
+	assert.Equal(t, "v2", discovered[0].Version)
+	assert.Equal(t, "v2", discovered[1].Version)
+	assert.Equal(t, uniswapV3.Hex(), discovered[2].Factory)
+	assert.Equal(t, "v3", discovered[2].Version)
+
+	configured := []types.Exchange{
+		{Name: "Uniswap V2", Factory: "0x5c69bee701ef814a2b6a3edd4b1652cb9cc5aa6f", Router: "0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D", Version: "v2"},
+	}
+	merged := MergeExchanges(configured, discovered)
+
+	assert.Len(t, merged, 3)
+	assert.Equal(t, configured[0], merged[0], "configured exchange takes precedence")
+	assert.Equal(t, sushiSwap.Hex(), merged[1].Factory)
+	assert.Equal(t, uniswapV3.Hex(), merged[2].Factory)
+}
+
+func TestLoadExchangesFromChain_InvalidAddress(t *testing.T) {
+	_, err := LoadExchangesFromChain(context.Background(), &stubFactoryReader{}, []string{"0x123"})
+	assert.Error(t, err)
+}
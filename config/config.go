@@ -1,10 +1,14 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"math/big"
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"dex-aggregator/internal/types"
@@ -20,27 +24,91 @@ type Config struct {
 	DEX         DEXConfig         `yaml:"dex"`
 	BaseTokens  []string          `yaml:"base_tokens"`
 	Performance PerformanceConfig `yaml:"performance"`
+	Dev         DevConfig         `yaml:"dev"`
 }
 
 type ServerConfig struct {
-	Port         string `yaml:"port"`
-	ReadTimeout  int    `yaml:"read_timeout"`
-	WriteTimeout int    `yaml:"write_timeout"`
+	Port         string            `yaml:"port"`
+	ReadTimeout  int               `yaml:"read_timeout"`
+	WriteTimeout int               `yaml:"write_timeout"`
+	APIKeys      map[string]string `yaml:"api_keys"`    // API key -> owner name
+	AdminToken   string            `yaml:"admin_token"` // Required by operator endpoints; empty disables them
+	DevMode      bool              `yaml:"dev_mode"`    // Enables the DevConfig options; refused when SERVER_ENV=production
+
+	RateLimitRPS   float64 `yaml:"rate_limit_rps"`   // Requests per second for each client (API key owner or IP); 0 disables the limit
+	RateLimitBurst int     `yaml:"rate_limit_burst"` // Requests allowed at once before the limit applies
+}
+
+// DevConfig holds local development aids, applied only when Server.DevMode is on
+type DevConfig struct {
+	ArtificialLatencyMs int `yaml:"artificial_latency_ms"` // Delay added to every response
 }
 
 type RedisConfig struct {
-	Addr     string `yaml:"addr"`
-	Password string `yaml:"password"`
-	DB       int    `yaml:"db"`
+	Addr             string `yaml:"addr"`
+	Password         string `yaml:"password"`
+	DB               int    `yaml:"db"`
+	MaxRetries       int    `yaml:"max_retries"`         // Retries after a failed operation
+	RetryBaseDelayMs int    `yaml:"retry_base_delay_ms"` // Delay before the first retry, quadrupled for each one after
 }
 
 type EthereumConfig struct {
-	RPCURL  string `yaml:"rpc_url"`
-	ChainID int64  `yaml:"chain_id"`
+	RPCURL       string `yaml:"rpc_url"`
+	ChainID      int64  `yaml:"chain_id"`
+	TokenListURL string `yaml:"token_list_url"`
 }
 
 type DEXConfig struct {
 	Exchanges []types.Exchange `yaml:"exchanges"`
+	GasCosts  map[string]int64 `yaml:"gas_costs"` // Exchange name -> gas per swap
+	BaseGas   int64            `yaml:"base_gas"`  // Fixed gas per transaction
+	HopGas    int64            `yaml:"hop_gas"`   // Extra gas per hop on top of the exchange swap cost
+	Factories []string         `yaml:"factories"` // Factory contracts to discover exchanges from when USE_LIVE_DATA=true
+
+	// Token routing restrictions; an empty AllowedTokens allows every token not denied
+	AllowedTokens []string `yaml:"allowed_tokens"`
+	DeniedTokens  []string `yaml:"denied_tokens"`
+
+	// StrictExchangeValidation rejects pools whose exchange is not in Exchanges; on by default
+	StrictExchangeValidation bool `yaml:"strict_exchange_validation"`
+
+	// MinLiquidityProduct is the smallest reserve0 * reserve1, as a decimal integer, of a pool
+	// that may be stored or routed through
+	MinLiquidityProduct string `yaml:"min_liquidity_product"`
+
+	// MaxAmountInWei is the largest quote amountIn accepted, as a decimal integer; empty
+	// accepts any amount. Quotes above LargeAmountWarningWei are accepted but logged.
+	MaxAmountInWei        string `yaml:"max_amount_in_wei"`
+	LargeAmountWarningWei string `yaml:"large_amount_warning_wei"`
+
+	// MaxConsecutiveHopsPerDEX caps how many hops in a row a path may take through one
+	// exchange; 0 leaves paths unlimited
+	MaxConsecutiveHopsPerDEX int `yaml:"max_consecutive_hops_per_dex"`
+
+	// MaxPoolsPerPair keeps only the deepest pools of each token pair in the routing graph;
+	// negative keeps every pool
+	MaxPoolsPerPair int `yaml:"max_pools_per_pair"`
+
+	// MaxReserveAgeSecs is the age beyond which a pool's reserves are stale; zero or negative
+	// disables the check, which is the default since only a live collector keeps reserves
+	// fresh. Stale pools are skipped unless StaleReservePenalty, the fraction of a stale
+	// hop's output to deduct instead, is positive.
+	MaxReserveAgeSecs   int     `yaml:"max_reserve_age_secs"`
+	StaleReservePenalty float64 `yaml:"stale_reserve_penalty"`
+
+	// PairSlippageOverrides replaces Performance.MaxSlippage for the swaps of a token pair,
+	// keyed by "tokenA:tokenB" in either order
+	PairSlippageOverrides map[string]float64 `yaml:"pair_slippage_overrides"`
+}
+
+// FindExchange returns the configured exchange with the given name, ignoring case
+func (d DEXConfig) FindExchange(name string) (types.Exchange, bool) {
+	for _, exchange := range d.Exchanges {
+		if strings.EqualFold(exchange.Name, name) {
+			return exchange, true
+		}
+	}
+	return types.Exchange{}, false
 }
 
 type PerformanceConfig struct {
@@ -51,15 +119,43 @@ type PerformanceConfig struct {
 	MaxSlippage          float64       `json:"max_slippage" yaml:"max_slippage"`
 	MaxPaths             int           `json:"max_paths" yaml:"max_paths"`
 	GraphRefreshInterval time.Duration `json:"graph_refresh_interval" yaml:"graph_refresh_seconds"`
+	GraphSnapshotPath    string        `json:"graph_snapshot_path" yaml:"graph_snapshot_path"`
+	MaxPoolAge           time.Duration `json:"max_pool_age" yaml:"max_pool_age_seconds"`               // Pools older than this fail the health check
+	PreferHighScorePools bool          `json:"prefer_high_score_pools" yaml:"prefer_high_score_pools"` // Favour deeper, fresher pools among near-equal paths
+	QuoteHistorySize     int           `json:"quote_history_size" yaml:"quote_history_size"`           // Recent quotes kept for GET /api/v1/quotes/history
+	DeduplicatePaths     bool          `json:"deduplicate_paths" yaml:"deduplicate_paths"`             // Drop quote paths through the same set of pools
+	WarmUpOnStart        bool          `json:"warm_up_on_start" yaml:"warm_up_on_start"`               // Quote every base token pair at startup to fill the quote cache
+	PathFindingTimeoutMs int           `json:"path_finding_timeout_ms" yaml:"path_finding_timeout_ms"` // Quote the paths found so far once a path search takes this long; 0 disables
+	MinHealthyPools      int           `json:"min_healthy_pools" yaml:"min_healthy_pools"`             // Alert when fewer pools than this are stored; 0 disables
+	PathAlgorithm        string        `json:"path_algorithm" yaml:"path_algorithm"`                   // Path search: dijkstra, yen, astar or bidir
+	SymbolCacheTTL       time.Duration `json:"symbol_cache_ttl" yaml:"symbol_cache_ttl_seconds"`       // How long token symbols shown by the API are cached
+}
+
+// pathAlgorithms are the accepted PerformanceConfig.PathAlgorithm values
+var pathAlgorithms = map[string]bool{"dijkstra": true, "yen": true, "astar": true, "bidir": true}
+
+// current holds the active configuration. Hot reload replaces it while
+// requests are reading it, so it is only accessed through Current and Set.
+var current atomic.Pointer[Config]
+
+// Current returns the active configuration, or nil before Init has run.
+// Callers that read several fields should keep the returned pointer rather
+// than calling Current again, so a reload cannot land between the reads.
+func Current() *Config {
+	return current.Load()
 }
 
-var AppConfig *Config
+// Set replaces the active configuration
+func Set(cfg *Config) {
+	current.Store(cfg)
+}
 
-// loadConfigFromFile loads default configuration from a YAML file.
-func loadConfigFromFile(path string, config *Config) error {
+// loadConfigFromFile loads default configuration from a YAML file. A missing
+// file is only an error when required is set.
+func loadConfigFromFile(path string, config *Config, required bool) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if os.IsNotExist(err) && !required {
 			log.Printf("Warning: YAML config file not found at %s. Using env vars and defaults only.", path)
 			return nil
 		}
@@ -72,27 +168,99 @@ func loadConfigFromFile(path string, config *Config) error {
 	return nil
 }
 
+// DefaultConfigPath is the YAML file read by Init
+const DefaultConfigPath = "config/config.yaml"
+
 func Init() error {
-	AppConfig = &Config{}
+	return InitFromFile(DefaultConfigPath)
+}
 
-	if err := loadConfigFromFile("config/config.yaml", AppConfig); err != nil {
-		log.Printf("Warning: Failed to load config.yaml: %v. Using defaults.", err)
+// InitFromFile builds the configuration from the YAML file at path, the environment
+// and defaults, then replaces the active configuration in a single store.
+// A missing or unreadable file falls back to the environment and defaults.
+func InitFromFile(path string) error {
+	cfg, err := build(path, false)
+	if err != nil {
+		return err
+	}
+	Set(cfg)
+	return nil
+}
+
+// Reload is InitFromFile for a running service: the YAML file must exist and
+// parse, and on any error the active configuration is left untouched.
+func Reload(path string) error {
+	cfg, err := build(path, true)
+	if err != nil {
+		return err
+	}
+	Set(cfg)
+	return nil
+}
+
+// build assembles and validates a configuration without installing it
+func build(path string, strict bool) (*Config, error) {
+	// Defaults that YAML can only switch off are set before it is loaded
+	cfg := &Config{
+		DEX:         DEXConfig{StrictExchangeValidation: true},
+		Performance: PerformanceConfig{DeduplicatePaths: true},
+	}
+
+	if err := loadConfigFromFile(path, cfg, strict); err != nil {
+		if strict {
+			return nil, fmt.Errorf("load %s: %w", path, err)
+		}
+		log.Printf("Warning: Failed to load %s: %v. Using defaults.", path, err)
 	}
 
 	if err := godotenv.Load(); err != nil {
 		log.Printf("Warning: .env file not found, using environment variables")
 	}
 
-	AppConfig.Server.Port = getEnv("SERVER_PORT", AppConfig.Server.Port, "8080")
-	AppConfig.Server.ReadTimeout = getEnvAsInt("SERVER_READ_TIMEOUT", AppConfig.Server.ReadTimeout, 15)
-	AppConfig.Server.WriteTimeout = getEnvAsInt("SERVER_WRITE_TIMEOUT", AppConfig.Server.WriteTimeout, 15)
+	cfg.Server.Port = getEnv("SERVER_PORT", cfg.Server.Port, "8080")
+	cfg.Server.ReadTimeout = getEnvAsInt("SERVER_READ_TIMEOUT", cfg.Server.ReadTimeout, 15)
+	cfg.Server.WriteTimeout = getEnvAsInt("SERVER_WRITE_TIMEOUT", cfg.Server.WriteTimeout, 15)
+	cfg.Server.APIKeys = getEnvAsMap("API_KEYS", cfg.Server.APIKeys)
+	cfg.Server.AdminToken = getEnv("ADMIN_TOKEN", cfg.Server.AdminToken, "")
+	cfg.Server.DevMode = getEnvAsBool("DEV_MODE", cfg.Server.DevMode)
+	cfg.Server.RateLimitRPS = getEnvAsFloat("RATE_LIMIT_RPS", cfg.Server.RateLimitRPS, 0)
+	cfg.Server.RateLimitBurst = getEnvAsInt("RATE_LIMIT_BURST", cfg.Server.RateLimitBurst, 20)
+
+	cfg.Dev.ArtificialLatencyMs = getEnvAsInt("DEV_ARTIFICIAL_LATENCY_MS", cfg.Dev.ArtificialLatencyMs, 0)
 
-	AppConfig.Redis.Addr = getEnv("REDIS_ADDR", AppConfig.Redis.Addr, "localhost:6379")
-	AppConfig.Redis.Password = getEnv("REDIS_PASSWORD", AppConfig.Redis.Password, "")
-	AppConfig.Redis.DB = getEnvAsInt("REDIS_DB", AppConfig.Redis.DB, 0)
+	cfg.Redis.Addr = getEnv("REDIS_ADDR", cfg.Redis.Addr, "localhost:6379")
+	cfg.Redis.Password = getEnv("REDIS_PASSWORD", cfg.Redis.Password, "")
+	cfg.Redis.DB = getEnvAsInt("REDIS_DB", cfg.Redis.DB, 0)
+	cfg.Redis.MaxRetries = getEnvAsInt("REDIS_MAX_RETRIES", cfg.Redis.MaxRetries, 3)
+	cfg.Redis.RetryBaseDelayMs = getEnvAsInt("REDIS_RETRY_BASE_DELAY_MS", cfg.Redis.RetryBaseDelayMs, 50)
 
-	AppConfig.Ethereum.RPCURL = getEnv("ETH_RPC_URL", AppConfig.Ethereum.RPCURL, "wss://mainnet.infura.io/ws/v3/YOUR-PROJECT-ID")
-	AppConfig.Ethereum.ChainID = getEnvAsInt64("ETH_CHAIN_ID", AppConfig.Ethereum.ChainID, 1)
+	cfg.Ethereum.RPCURL = getEnv("ETH_RPC_URL", cfg.Ethereum.RPCURL, "wss://mainnet.infura.io/ws/v3/YOUR-PROJECT-ID")
+	cfg.Ethereum.ChainID = getEnvAsInt64("ETH_CHAIN_ID", cfg.Ethereum.ChainID, 1)
+	cfg.Ethereum.TokenListURL = getEnv("TOKEN_LIST_URL", cfg.Ethereum.TokenListURL, "")
+
+	if len(cfg.DEX.GasCosts) == 0 {
+		cfg.DEX.GasCosts = map[string]int64{
+			"Uniswap V2": 100000,
+			"SushiSwap":  120000,
+		}
+	}
+	cfg.DEX.BaseGas = getEnvAsInt64("DEX_BASE_GAS", cfg.DEX.BaseGas, 21000)
+	cfg.DEX.HopGas = getEnvAsInt64("DEX_HOP_GAS", cfg.DEX.HopGas, 0)
+	cfg.DEX.Factories = getEnvAsSlice("DEX_FACTORIES", ",", cfg.DEX.Factories, nil)
+	cfg.DEX.AllowedTokens = getEnvAsSlice("DEX_ALLOWED_TOKENS", ",", cfg.DEX.AllowedTokens, nil)
+	cfg.DEX.DeniedTokens = getEnvAsSlice("DEX_DENIED_TOKENS", ",", cfg.DEX.DeniedTokens, nil)
+	cfg.DEX.StrictExchangeValidation = getEnvAsBool("DEX_STRICT_EXCHANGE_VALIDATION", cfg.DEX.StrictExchangeValidation)
+	cfg.DEX.MinLiquidityProduct = getEnv("DEX_MIN_LIQUIDITY_PRODUCT", cfg.DEX.MinLiquidityProduct, "1000000000000")
+	cfg.DEX.MaxAmountInWei = getEnv("DEX_MAX_AMOUNT_IN_WEI", cfg.DEX.MaxAmountInWei, "")
+	cfg.DEX.LargeAmountWarningWei = getEnv("DEX_LARGE_AMOUNT_WARNING_WEI", cfg.DEX.LargeAmountWarningWei, "1000000000000000000")
+	cfg.DEX.MaxConsecutiveHopsPerDEX = getEnvAsInt("DEX_MAX_CONSECUTIVE_HOPS_PER_DEX", cfg.DEX.MaxConsecutiveHopsPerDEX, 0)
+	cfg.DEX.MaxPoolsPerPair = getEnvAsInt("DEX_MAX_POOLS_PER_PAIR", cfg.DEX.MaxPoolsPerPair, 3)
+	cfg.DEX.MaxReserveAgeSecs = getEnvAsInt("DEX_MAX_RESERVE_AGE_SECS", cfg.DEX.MaxReserveAgeSecs, -1)
+	cfg.DEX.StaleReservePenalty = getEnvAsFloat("DEX_STALE_RESERVE_PENALTY", cfg.DEX.StaleReservePenalty, 0)
+
+	if os.Getenv("USE_LIVE_DATA") == "true" && cfg.Ethereum.RPCURL != "" && len(cfg.DEX.Factories) > 0 {
+		loadLiveExchanges(cfg)
+	}
 
 	defaultBaseTokens := []string{
 		"0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2",
@@ -100,17 +268,85 @@ func Init() error {
 		"0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
 		"0x6b175474e89094c44da98b954eedeac495271d0f",
 	}
-	AppConfig.BaseTokens = getEnvAsSlice("BASE_TOKENS", ",", AppConfig.BaseTokens, defaultBaseTokens)
+	cfg.BaseTokens = getEnvAsSlice("BASE_TOKENS", ",", cfg.BaseTokens, defaultBaseTokens)
 
-	AppConfig.Performance.MaxConcurrentPaths = getEnvAsInt("MAX_CONCURRENT_PATHS", AppConfig.Performance.MaxConcurrentPaths, 10)
-	AppConfig.Performance.CacheTTL = time.Duration(getEnvAsInt("CACHE_TTL_SECONDS", int(AppConfig.Performance.CacheTTL.Seconds()), 300)) * time.Second
-	AppConfig.Performance.RequestTimeout = time.Duration(getEnvAsInt("REQUEST_TIMEOUT_SECONDS", int(AppConfig.Performance.RequestTimeout.Seconds()), 30)) * time.Second
-	AppConfig.Performance.MaxHops = getEnvAsInt("MAX_HOPS", AppConfig.Performance.MaxHops, 3)
-	AppConfig.Performance.MaxSlippage = getEnvAsFloat("MAX_SLIPPAGE", AppConfig.Performance.MaxSlippage, 5.0)
-	AppConfig.Performance.MaxPaths = getEnvAsInt("MAX_PATHS", AppConfig.Performance.MaxPaths, 20)
-	AppConfig.Performance.GraphRefreshInterval = time.Duration(getEnvAsInt("GRAPH_REFRESH_SECONDS", int(AppConfig.Performance.GraphRefreshInterval.Seconds()), 30)) * time.Second
+	cfg.Performance.MaxConcurrentPaths = getEnvAsInt("MAX_CONCURRENT_PATHS", cfg.Performance.MaxConcurrentPaths, 10)
+	cfg.Performance.CacheTTL = time.Duration(getEnvAsInt("CACHE_TTL_SECONDS", int(cfg.Performance.CacheTTL.Seconds()), 300)) * time.Second
+	cfg.Performance.RequestTimeout = time.Duration(getEnvAsInt("REQUEST_TIMEOUT_SECONDS", int(cfg.Performance.RequestTimeout.Seconds()), 30)) * time.Second
+	cfg.Performance.MaxHops = getEnvAsInt("MAX_HOPS", cfg.Performance.MaxHops, 3)
+	cfg.Performance.MaxSlippage = getEnvAsFloat("MAX_SLIPPAGE", cfg.Performance.MaxSlippage, 5.0)
+	cfg.Performance.MaxPaths = getEnvAsInt("MAX_PATHS", cfg.Performance.MaxPaths, 20)
+	cfg.Performance.GraphRefreshInterval = time.Duration(getEnvAsInt("GRAPH_REFRESH_SECONDS", int(cfg.Performance.GraphRefreshInterval.Seconds()), 30)) * time.Second
+	cfg.Performance.GraphSnapshotPath = getEnv("GRAPH_SNAPSHOT_PATH", cfg.Performance.GraphSnapshotPath, "")
+	cfg.Performance.MaxPoolAge = time.Duration(getEnvAsInt("MAX_POOL_AGE_SECONDS", int(cfg.Performance.MaxPoolAge.Seconds()), 3600)) * time.Second
+	cfg.Performance.PreferHighScorePools = getEnvAsBool("PREFER_HIGH_SCORE_POOLS", cfg.Performance.PreferHighScorePools)
+	cfg.Performance.QuoteHistorySize = getEnvAsInt("QUOTE_HISTORY_SIZE", cfg.Performance.QuoteHistorySize, 100)
+	cfg.Performance.DeduplicatePaths = getEnvAsBool("DEDUPLICATE_PATHS", cfg.Performance.DeduplicatePaths)
+	cfg.Performance.WarmUpOnStart = getEnvAsBool("WARM_UP_ON_START", cfg.Performance.WarmUpOnStart)
+	cfg.Performance.PathFindingTimeoutMs = getEnvAsInt("PATH_FINDING_TIMEOUT_MS", cfg.Performance.PathFindingTimeoutMs, 0)
+	cfg.Performance.MinHealthyPools = getEnvAsInt("MIN_HEALTHY_POOLS", cfg.Performance.MinHealthyPools, 1)
+	cfg.Performance.PathAlgorithm = getEnv("PATH_ALGORITHM", cfg.Performance.PathAlgorithm, "dijkstra")
+	cfg.Performance.SymbolCacheTTL = time.Duration(getEnvAsInt("SYMBOL_CACHE_TTL_SECONDS", int(cfg.Performance.SymbolCacheTTL.Seconds()), 300)) * time.Second
 
-	return nil
+	if err := Validate(cfg); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks cfg for values the service cannot run with and reports every
+// violation, not just the first
+func Validate(cfg *Config) error {
+	var errs []error
+
+	if port, err := strconv.Atoi(cfg.Server.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port %q must be a port number between 1 and 65535", cfg.Server.Port))
+	}
+	if cfg.Server.RateLimitRPS < 0 {
+		errs = append(errs, fmt.Errorf("server.rate_limit_rps %g must not be negative", cfg.Server.RateLimitRPS))
+	}
+	if cfg.Server.RateLimitRPS > 0 && cfg.Server.RateLimitBurst < 1 {
+		errs = append(errs, fmt.Errorf("server.rate_limit_burst %d must be at least 1", cfg.Server.RateLimitBurst))
+	}
+	if cfg.Performance.MaxSlippage < 0.01 || cfg.Performance.MaxSlippage > 100.0 {
+		errs = append(errs, fmt.Errorf("performance.max_slippage %g must be between 0.01 and 100", cfg.Performance.MaxSlippage))
+	}
+	if cfg.Performance.MaxHops < 1 || cfg.Performance.MaxHops > 10 {
+		errs = append(errs, fmt.Errorf("performance.max_hops %d must be between 1 and 10", cfg.Performance.MaxHops))
+	}
+	if cfg.Performance.MaxConcurrentPaths < 1 {
+		errs = append(errs, fmt.Errorf("performance.max_concurrent_paths %d must be at least 1", cfg.Performance.MaxConcurrentPaths))
+	}
+	if cfg.Performance.PathAlgorithm != "" && !pathAlgorithms[cfg.Performance.PathAlgorithm] {
+		errs = append(errs, fmt.Errorf("performance.path_algorithm %q must be one of dijkstra, yen, astar, bidir", cfg.Performance.PathAlgorithm))
+	}
+	for pair, slippage := range cfg.DEX.PairSlippageOverrides {
+		if tokens := strings.Split(pair, ":"); len(tokens) != 2 || tokens[0] == "" || tokens[1] == "" {
+			errs = append(errs, fmt.Errorf("dex.pair_slippage_overrides key %q must be tokenA:tokenB", pair))
+		}
+		if slippage < 0.01 || slippage > 100.0 {
+			errs = append(errs, fmt.Errorf("dex.pair_slippage_overrides[%s] %g must be between 0.01 and 100", pair, slippage))
+		}
+	}
+	for _, amount := range []struct{ key, value string }{
+		{"dex.max_amount_in_wei", cfg.DEX.MaxAmountInWei},
+		{"dex.large_amount_warning_wei", cfg.DEX.LargeAmountWarningWei},
+	} {
+		if amount.value == "" {
+			continue
+		}
+		if n, ok := new(big.Int).SetString(amount.value, 10); !ok || n.Sign() <= 0 {
+			errs = append(errs, fmt.Errorf("%s %q must be a positive integer", amount.key, amount.value))
+		}
+	}
+	if cfg.Redis.Addr == "" {
+		errs = append(errs, errors.New("redis.addr must not be empty"))
+	}
+	if cfg.Ethereum.ChainID <= 0 {
+		errs = append(errs, fmt.Errorf("ethereum.chain_id %d must be positive", cfg.Ethereum.ChainID))
+	}
+
+	return errors.Join(errs...)
 }
 
 // getEnv returns env value if set, otherwise yamlValue if not empty, otherwise fallback.
@@ -160,6 +396,14 @@ func getEnvAsFloat(key string, yamlValue float64, fallback float64) float64 {
 	return fallback
 }
 
+// getEnvAsBool returns env bool if set, otherwise yamlValue.
+func getEnvAsBool(key string, yamlValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return yamlValue
+}
+
 // getEnvAsSlice returns env slice if set, otherwise yamlValue if non-empty, otherwise fallback.
 func getEnvAsSlice(key, separator string, yamlValue []string, fallback []string) []string {
 	valueStr := os.Getenv(key)
@@ -171,3 +415,21 @@ func getEnvAsSlice(key, separator string, yamlValue []string, fallback []string)
 	}
 	return fallback
 }
+
+// getEnvAsMap parses env "k1:v1,k2:v2" if set, otherwise returns yamlValue.
+func getEnvAsMap(key string, yamlValue map[string]string) map[string]string {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return yamlValue
+	}
+	result := make(map[string]string)
+	for _, entry := range strings.Split(valueStr, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || k == "" {
+			log.Printf("Warning: Ignoring malformed %s entry: %q", key, entry)
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func validConfig() *Config {
+	cfg := &Config{}
+	cfg.Server.Port = "8080"
+	cfg.Redis.Addr = "localhost:6379"
+	cfg.Ethereum.ChainID = 1
+	cfg.Performance.MaxSlippage = 5.0
+	cfg.Performance.MaxHops = 3
+	cfg.Performance.MaxConcurrentPaths = 10
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		errors []string
+	}{
+		{"valid", func(cfg *Config) {}, nil},
+		{"non-numeric port", func(cfg *Config) { cfg.Server.Port = "http" }, []string{"server.port"}},
+		{"port zero", func(cfg *Config) { cfg.Server.Port = "0" }, []string{"server.port"}},
+		{"port too large", func(cfg *Config) { cfg.Server.Port = "65536" }, []string{"server.port"}},
+		{"slippage too small", func(cfg *Config) { cfg.Performance.MaxSlippage = 0.001 }, []string{"performance.max_slippage"}},
+		{"slippage too large", func(cfg *Config) { cfg.Performance.MaxSlippage = 100.5 }, []string{"performance.max_slippage"}},
+		{"zero hops", func(cfg *Config) { cfg.Performance.MaxHops = 0 }, []string{"performance.max_hops"}},
+		{"too many hops", func(cfg *Config) { cfg.Performance.MaxHops = 11 }, []string{"performance.max_hops"}},
+		{"zero concurrency", func(cfg *Config) { cfg.Performance.MaxConcurrentPaths = 0 }, []string{"performance.max_concurrent_paths"}},
+		{"empty redis addr", func(cfg *Config) { cfg.Redis.Addr = "" }, []string{"redis.addr"}},
+		{"negative chain id", func(cfg *Config) { cfg.Ethereum.ChainID = -1 }, []string{"ethereum.chain_id"}},
+		{"pair slippage override", func(cfg *Config) {
+			cfg.DEX.PairSlippageOverrides = map[string]float64{"0xusdc:0xusdt": 0.1}
+		}, nil},
+		{"malformed pair slippage key", func(cfg *Config) {
+			cfg.DEX.PairSlippageOverrides = map[string]float64{"0xusdc": 0.1}
+		}, []string{"dex.pair_slippage_overrides key"}},
+		{"pair slippage out of range", func(cfg *Config) {
+			cfg.DEX.PairSlippageOverrides = map[string]float64{"0xusdc:0xusdt": 0}
+		}, []string{"dex.pair_slippage_overrides[0xusdc:0xusdt]"}},
+		{"amount limits", func(cfg *Config) {
+			cfg.DEX.MaxAmountInWei = "1000000000000000000000"
+			cfg.DEX.LargeAmountWarningWei = "1000000000000000000"
+		}, nil},
+		{"non-numeric max amount", func(cfg *Config) { cfg.DEX.MaxAmountInWei = "1e21" }, []string{"dex.max_amount_in_wei"}},
+		{"zero large amount warning", func(cfg *Config) { cfg.DEX.LargeAmountWarningWei = "0" }, []string{"dex.large_amount_warning_wei"}},
+		{"rate limit", func(cfg *Config) {
+			cfg.Server.RateLimitRPS = 10
+			cfg.Server.RateLimitBurst = 20
+		}, nil},
+		{"negative rate limit", func(cfg *Config) { cfg.Server.RateLimitRPS = -1 }, []string{"server.rate_limit_rps"}},
+		{"rate limit without burst", func(cfg *Config) {
+			cfg.Server.RateLimitRPS = 10
+			cfg.Server.RateLimitBurst = 0
+		}, []string{"server.rate_limit_burst"}},
+		{"yen path algorithm", func(cfg *Config) { cfg.Performance.PathAlgorithm = "yen" }, nil},
+		{"unknown path algorithm", func(cfg *Config) { cfg.Performance.PathAlgorithm = "bfs" }, []string{"performance.path_algorithm"}},
+		{
+			"every violation",
+			func(cfg *Config) {
+				cfg.Server.Port = ""
+				cfg.Performance.MaxSlippage = 0
+				cfg.Performance.MaxHops = 0
+				cfg.Performance.MaxConcurrentPaths = 0
+				cfg.Redis.Addr = ""
+				cfg.Ethereum.ChainID = 0
+			},
+			[]string{"server.port", "performance.max_slippage", "performance.max_hops", "performance.max_concurrent_paths", "redis.addr", "ethereum.chain_id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+
+			err := Validate(cfg)
+			if len(tt.errors) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			for _, want := range tt.errors {
+				assert.Contains(t, err.Error(), want)
+			}
+			if unwrapped, ok := err.(interface{ Unwrap() []error }); assert.True(t, ok) {
+				assert.Len(t, unwrapped.Unwrap(), len(tt.errors))
+			}
+		})
+	}
+}
+
+func TestInitFromFile_RejectsInvalidConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(configPath, []byte("performance:\n  max_hops: 20\n"), 0o644))
+
+	original := Current()
+	defer Set(original)
+
+	err := InitFromFile(configPath)
+	assert.ErrorContains(t, err, "performance.max_hops")
+	assert.Same(t, original, Current())
+}
+
+func TestReload_KeepsConfigOnBadFile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(configPath, []byte("performance:\n  max_slippage: 2.5\n"), 0o644))
+
+	original := Current()
+	defer Set(original)
+
+	assert.NoError(t, Reload(configPath))
+	loaded := Current()
+	assert.Equal(t, 2.5, loaded.Performance.MaxSlippage)
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"malformed yaml", "performance:\n  max_slippage: [2.5\n"},
+		{"invalid value", "performance:\n  max_hops: 20\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, os.WriteFile(configPath, []byte(tt.data), 0o644))
+			assert.Error(t, Reload(configPath))
+			assert.Same(t, loaded, Current())
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		assert.NoError(t, os.Remove(configPath))
+		assert.Error(t, Reload(configPath))
+		assert.Same(t, loaded, Current())
+	})
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ReloadDebounce is how long the watcher waits after the last change to the
+// file before reloading it. Editors and deploy tools often truncate, write and
+// rename in separate steps; reloading on the first event would read a
+// half-written file.
+const ReloadDebounce = 250 * time.Millisecond
+
+// Watcher reloads the configuration when its YAML file is written and
+// notifies subscribers after the active configuration has been replaced
+type Watcher struct {
+	path     string
+	watcher  *fsnotify.Watcher
+	debounce time.Duration
+
+	mu          sync.Mutex
+	subscribers []chan struct{}
+
+	done chan struct{}
+}
+
+// NewWatcher starts watching the YAML file at path
+func NewWatcher(path string) (*Watcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	// Watch the directory so editors that replace the file are still seen
+	if err := fsWatcher.Add(filepath.Dir(path)); err != nil {
+		fsWatcher.Close()
+		return nil, err
+	}
+
+	w := &Watcher{
+		path:     filepath.Clean(path),
+		watcher:  fsWatcher,
+		debounce: ReloadDebounce,
+		done:     make(chan struct{}),
+	}
+	go w.run()
+
+	log.Printf("Config watcher started for %s", w.path)
+	return w, nil
+}
+
+// Subscribe returns a channel that receives a value after each reload.
+// Notifications are coalesced if the subscriber falls behind.
+func (w *Watcher) Subscribe() <-chan struct{} {
+	ch := make(chan struct{}, 1)
+
+	w.mu.Lock()
+	w.subscribers = append(w.subscribers, ch)
+	w.mu.Unlock()
+
+	return ch
+}
+
+// Close stops watching the file
+func (w *Watcher) Close() error {
+	err := w.watcher.Close()
+	<-w.done
+	return err
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+
+	// reload fires once the file has been quiet for the debounce period; each
+	// new event pushes it back
+	reload := time.NewTimer(w.debounce)
+	reload.Stop()
+	defer reload.Stop()
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != w.path || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			reload.Reset(w.debounce)
+
+		case <-reload.C:
+			// A file that fails to parse or validate leaves the running config in place
+			if err := Reload(w.path); err != nil {
+				log.Printf("Config reload from %s failed, keeping the current config: %v", w.path, err)
+				continue
+			}
+			log.Printf("Config reloaded from %s", w.path)
+			w.notify()
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Config watcher error: %v", err)
+		}
+	}
+}
+
+func (w *Watcher) notify() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, ch := range w.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcher_DebouncesWritesAndKeepsConfigOnError(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(data string) {
+		assert.NoError(t, os.WriteFile(configPath, []byte(data), 0o644))
+	}
+
+	original := Current()
+	defer Set(original)
+
+	writeConfig("performance:\n  max_slippage: 1.0\n")
+	assert.NoError(t, InitFromFile(configPath))
+
+	watcher, err := NewWatcher(configPath)
+	assert.NoError(t, err)
+	defer watcher.Close()
+	updates := watcher.Subscribe()
+
+	// A burst of writes, including a half-written file, settles into one reload
+	writeConfig("performance:\n  max_slippage: [")
+	writeConfig("performance:\n  max_slippage: 2.0\n")
+	writeConfig("performance:\n  max_slippage: 3.0\n")
+
+	select {
+	case <-updates:
+	case <-time.After(5 * ReloadDebounce):
+		t.Fatal("timed out waiting for config reload")
+	}
+	assert.Equal(t, 3.0, Current().Performance.MaxSlippage)
+
+	select {
+	case <-updates:
+		t.Fatal("burst of writes reloaded more than once")
+	case <-time.After(2 * ReloadDebounce):
+	}
+
+	// A malformed file is not applied and subscribers are not notified
+	reloaded := Current()
+	writeConfig("performance:\n  max_slippage: [")
+
+	select {
+	case <-updates:
+		t.Fatal("malformed config notified subscribers")
+	case <-time.After(2 * ReloadDebounce):
+	}
+	assert.Same(t, reloaded, Current())
+}
@@ -0,0 +1,189 @@
+//go:build integration
+
+// Package integration runs the cache against a real Redis server, which the unit tests
+// replace with miniredis. Start one with
+//
+//	docker compose -f integration/docker-compose.yml up -d
+//
+// and run
+//
+//	INTEGRATION_REDIS_ADDR=localhost:6379 go test -tags=integration ./integration/...
+//
+// Every test empties the Redis database it is given, so the address must be set
+// explicitly and the tests skip without it rather than flush a Redis that holds real
+// data. With the address set, they fail rather than skip when Redis is unreachable, so
+// a missing server cannot pass for a working cache.
+package integration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/types"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// redisAddr returns the Redis the tests may flush, skipping the test when none is set
+func redisAddr(t *testing.T) string {
+	t.Helper()
+	addr := os.Getenv("INTEGRATION_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("INTEGRATION_REDIS_ADDR is not set; the integration tests flush the Redis database they use")
+	}
+	return addr
+}
+
+// newTwoLevelCache returns a cache over an emptied Redis database
+func newTwoLevelCache(t *testing.T) *cache.TwoLevelCache {
+	t.Helper()
+	addr := redisAddr(t)
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	require.NoError(t, client.Ping(ctx).Err(), "Redis must be running at %s", addr)
+	require.NoError(t, client.FlushDB(ctx).Err())
+
+	tlc := cache.NewTwoLevelCache(addr, "", types.DefaultChainID, 5*time.Minute)
+	t.Cleanup(tlc.Close)
+	return tlc
+}
+
+func testPool(address, token0, token1 string) *types.Pool {
+	return &types.Pool{
+		Address:  address,
+		Exchange: "Uniswap V2",
+		Version:  "v2",
+		Token0:   types.Token{Address: token0, Symbol: "T0", Decimals: 18},
+		Token1:   types.Token{Address: token1, Symbol: "T1", Decimals: 6},
+		Reserve0: big.NewInt(1000000000000000000),
+		Reserve1: big.NewInt(2000000000),
+		Fee:      300,
+	}
+}
+
+func TestTwoLevelCache_PoolFoundInRedisAfterClearingLocalCache(t *testing.T) {
+	tlc := newTwoLevelCache(t)
+	ctx := context.Background()
+
+	require.NoError(t, tlc.StorePool(ctx, testPool("0xpool", "0xtokena", "0xtokenb")))
+
+	// Served locally while the local cache holds it
+	_, err := tlc.GetPool(ctx, "0xpool")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), tlc.GetStats().LocalHits)
+	assert.Zero(t, tlc.GetStats().RedisHits)
+
+	tlc.ClearLocalCache()
+
+	pool, err := tlc.GetPool(ctx, "0xpool")
+	require.NoError(t, err)
+	assert.Equal(t, "0xpool", pool.Address)
+	assert.Equal(t, "2000000000", pool.Reserve1.String())
+	assert.Equal(t, 300, pool.Fee)
+
+	stats := tlc.GetStats()
+	assert.Equal(t, int64(1), stats.LocalMisses, "the cleared local cache misses")
+	assert.Equal(t, int64(1), stats.RedisHits, "Redis serves the pool")
+
+	// The Redis hit backfills the local cache
+	assert.Eventually(t, func() bool {
+		_, err := tlc.GetPool(ctx, "0xpool")
+		return err == nil && tlc.GetStats().LocalHits == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestTwoLevelCache_GetAllPools(t *testing.T) {
+	tlc := newTwoLevelCache(t)
+	ctx := context.Background()
+
+	// Enough pools to need several pipelined reads
+	const poolCount = 250
+	expected := make(map[string]bool, poolCount)
+	for i := 0; i < poolCount; i++ {
+		address := fmt.Sprintf("0xpool%03d", i)
+		expected[address] = true
+		require.NoError(t, tlc.StorePool(ctx, testPool(address, fmt.Sprintf("0xtoken%03d", i), "0xquote")))
+	}
+	tlc.ClearLocalCache()
+
+	pools, err := tlc.GetAllPools(ctx)
+	require.NoError(t, err)
+	require.Len(t, pools, poolCount)
+
+	found := make(map[string]bool, poolCount)
+	for _, pool := range pools {
+		found[pool.Address] = true
+		assert.NotNil(t, pool.Reserve0, "pool %s", pool.Address)
+	}
+	assert.Equal(t, expected, found)
+	assert.Zero(t, tlc.GetStats().FallbackHits, "Redis answered, not the local cache")
+}
+
+func TestTwoLevelCache_GetPoolsByTokens_NormalisesAddresses(t *testing.T) {
+	tlc := newTwoLevelCache(t)
+	ctx := context.Background()
+
+	tokenA := "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"
+	tokenB := "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+	require.NoError(t, tlc.StorePool(ctx, testPool("0xmixedcase", tokenA, tokenB)))
+	require.NoError(t, tlc.StorePool(ctx, testPool("0xother", tokenA, "0xtokenc")))
+
+	lookups := []struct{ tokenA, tokenB string }{
+		{tokenA, tokenB},
+		{tokenB, tokenA},
+		{strings.ToLower(tokenA), "0x" + strings.ToUpper(tokenB[2:])},
+		{strings.ToLower(tokenB), strings.ToLower(tokenA)},
+	}
+	for _, lookup := range lookups {
+		pools, err := tlc.GetPoolsByTokens(ctx, lookup.tokenA, lookup.tokenB)
+		require.NoError(t, err)
+		if assert.Len(t, pools, 1, "%s / %s", lookup.tokenA, lookup.tokenB) {
+			assert.Equal(t, "0xmixedcase", pools[0].Address)
+		}
+	}
+}
+
+func TestTwoLevelCache_ClearLocalCacheForcesRedisFallback(t *testing.T) {
+	tlc := newTwoLevelCache(t)
+	ctx := context.Background()
+
+	for _, address := range []string{"0xpool1", "0xpool2", "0xpool3"} {
+		require.NoError(t, tlc.StorePool(ctx, testPool(address, "0xtokena", "0xtokenb")))
+	}
+	for _, address := range []string{"0xpool1", "0xpool2", "0xpool3"} {
+		_, err := tlc.GetPool(ctx, address)
+		require.NoError(t, err)
+	}
+	before := tlc.GetStats()
+	assert.Equal(t, int64(3), before.LocalHits)
+
+	tlc.ClearLocalCache()
+
+	for _, address := range []string{"0xpool1", "0xpool2", "0xpool3"} {
+		_, err := tlc.GetPool(ctx, address)
+		require.NoError(t, err)
+	}
+	after := tlc.GetStats()
+	assert.Equal(t, before.LocalHits, after.LocalHits, "no lookup is served locally")
+	assert.Equal(t, int64(3), after.LocalMisses-before.LocalMisses)
+	assert.Equal(t, int64(3), after.RedisHits-before.RedisHits)
+
+	// Pools absent from both layers are still misses
+	_, err := tlc.GetPool(ctx, "0xmissing")
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), tlc.GetStats().RedisMisses)
+}
@@ -1,15 +1,23 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"dex-aggregator/config"
 	"dex-aggregator/internal/aggregator"
+	"dex-aggregator/internal/api"
+	"dex-aggregator/internal/api/middleware"
 	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/resolver"
 	"dex-aggregator/internal/types"
+	"encoding/json"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -51,7 +59,7 @@ func TestIntegration_CompleteFlow(t *testing.T) {
 
 	// Create router
 	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10, CacheTTL: 60 * time.Second}
-	router := aggregator.NewRouter(store, perfConfig)
+	router := aggregator.NewRouter(context.Background(), store, perfConfig)
 	assert.NotNil(t, router)
 
 	// Create quote request - using smaller amount
@@ -100,3 +108,91 @@ func TestIntegration_CachePerformance(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, pool.Address, retrievedPool2.Address)
 }
+
+// TestIntegration_PausedPoolExcludedFromQuotes pauses the best pool through the admin
+// API and checks later quotes route around it until it is unpaused
+func TestIntegration_PausedPoolExcludedFromQuotes(t *testing.T) {
+	assert.NoError(t, config.Init())
+
+	const (
+		weth       = "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"
+		usdt       = "0xdac17f958d2ee523a2206206994597c13d831ec7"
+		usdc       = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+		adminToken = "test-admin-token"
+	)
+
+	wethToken := TestToken(weth, "WETH", 18)
+	usdtToken := TestToken(usdt, "USDT", 6)
+	usdcToken := TestToken(usdc, "USDC", 6)
+	hundredWETH, _ := new(big.Int).SetString("100000000000000000000", 10)
+	tenWETH, _ := new(big.Int).SetString("10000000000000000000", 10)
+
+	store := cache.NewMemoryStore()
+	for _, pool := range []*types.Pool{
+		TestPool("pool-deep", "Uniswap V2", wethToken, usdtToken, hundredWETH, big.NewInt(200000000000)),
+		TestPool("pool-shallow", "SushiSwap", wethToken, usdtToken, tenWETH, big.NewInt(20000000000)),
+		TestPool("pool-weth-usdc", "Uniswap V2", wethToken, usdcToken, tenWETH, big.NewInt(20000000000)),
+		TestPool("pool-usdc-usdt", "Uniswap V2", usdcToken, usdtToken, big.NewInt(50000000000), big.NewInt(50000000000)),
+	} {
+		assert.NoError(t, store.StorePool(context.Background(), pool))
+	}
+
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	router := aggregator.NewRouter(context.Background(), store, perfConfig)
+	handler := api.NewHandler(router, store, resolver.NewSymbolCache(store, 0))
+
+	r := mux.NewRouter()
+	adminAuth := middleware.AdminTokenAuth(adminToken)
+	r.Handle("/api/v1/pools/{address}/pause", adminAuth(http.HandlerFunc(handler.PausePool))).Methods("PATCH")
+	r.Handle("/api/v1/pools/{address}/unpause", adminAuth(http.HandlerFunc(handler.UnpausePool))).Methods("PATCH")
+	r.HandleFunc("/api/v1/quote", handler.GetQuote).Methods("POST")
+
+	// quotedPools returns every pool address used by any path of a fresh quote
+	quotedPools := func() map[string]bool {
+		body, _ := json.Marshal(map[string]string{"tokenIn": weth, "tokenOut": usdt, "amountIn": "1000000000000000"})
+		req := httptest.NewRequest("POST", "/api/v1/quote", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			return nil
+		}
+
+		var response types.QuoteResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		used := make(map[string]bool)
+		for _, path := range response.Paths {
+			for _, pool := range path.Pools {
+				used[pool.Address] = true
+			}
+		}
+		return used
+	}
+
+	patch := func(action, token string) int {
+		req := httptest.NewRequest("PATCH", "/api/v1/pools/pool-deep/"+action, nil)
+		if token != "" {
+			req.Header.Set(middleware.AdminTokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.True(t, quotedPools()["pool-deep"])
+
+	assert.Equal(t, http.StatusUnauthorized, patch("pause", ""))
+	assert.Equal(t, http.StatusUnauthorized, patch("pause", "wrong-token"))
+	assert.True(t, quotedPools()["pool-deep"])
+
+	assert.Equal(t, http.StatusOK, patch("pause", adminToken))
+	assert.Eventually(t, func() bool {
+		used := quotedPools()
+		return !used["pool-deep"] && used["pool-shallow"]
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, http.StatusOK, patch("unpause", adminToken))
+	assert.Eventually(t, func() bool {
+		return quotedPools()["pool-deep"]
+	}, 5*time.Second, 10*time.Millisecond)
+}
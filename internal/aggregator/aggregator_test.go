@@ -1,11 +1,25 @@
 package aggregator
 
 import (
+	"bytes"
 	"context"
 	"dex-aggregator/config"
+	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/circuitbreaker"
+	"dex-aggregator/internal/testutil"
 	"dex-aggregator/internal/types"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"log"
 	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -46,6 +60,40 @@ func (m *MockStore) GetAllPools(ctx context.Context) ([]*types.Pool, error) {
 	return args.Get(0).([]*types.Pool), args.Error(1)
 }
 
+func (m *MockStore) GetPoolsByExchange(ctx context.Context, exchange string) ([]*types.Pool, error) {
+	args := m.Called(ctx, exchange)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.Pool), args.Error(1)
+}
+
+func (m *MockStore) GetPoolsCreatedAfter(ctx context.Context, since time.Time) ([]*types.Pool, error) {
+	args := m.Called(ctx, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.Pool), args.Error(1)
+}
+
+func (m *MockStore) UpdatePool(ctx context.Context, address string, update *types.PoolUpdate) (*types.Pool, error) {
+	args := m.Called(ctx, address, update)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Pool), args.Error(1)
+}
+
+func (m *MockStore) ApplyReserveDelta(ctx context.Context, address string, delta0, delta1 *big.Int) error {
+	args := m.Called(ctx, address, delta0, delta1)
+	return args.Error(0)
+}
+
+func (m *MockStore) BulkUpdateReserves(ctx context.Context, updates []types.ReserveUpdate) error {
+	args := m.Called(ctx, updates)
+	return args.Error(0)
+}
+
 func (m *MockStore) StoreToken(ctx context.Context, token *types.Token) error {
 	args := m.Called(ctx, token)
 	return args.Error(0)
@@ -71,12 +119,12 @@ func TestPriceCalculator_CalculateOutput(t *testing.T) {
 	}
 
 	// Use smaller input amount to avoid slippage errors
-	amountOut, err := calculator.CalculateOutput(pool, big.NewInt(1000), "0xTokenA")
+	amountOut, err := calculator.CalculateOutput(context.Background(), pool, big.NewInt(1000), "0xTokenA")
 	assert.NoError(t, err)
 	assert.True(t, amountOut.Cmp(big.NewInt(0)) > 0)
 
 	// Test non-existent token
-	_, err = calculator.CalculateOutput(pool, big.NewInt(1000), "0xInvalidToken")
+	_, err = calculator.CalculateOutput(context.Background(), pool, big.NewInt(1000), "0xInvalidToken")
 	assert.Error(t, err)
 
 	// Test zero reserves
@@ -86,11 +134,42 @@ func TestPriceCalculator_CalculateOutput(t *testing.T) {
 		Reserve0: big.NewInt(0),
 		Reserve1: big.NewInt(0),
 	}
-	amountOut, err = calculator.CalculateOutput(zeroPool, big.NewInt(1000), "0xTokenA")
+	amountOut, err = calculator.CalculateOutput(context.Background(), zeroPool, big.NewInt(1000), "0xTokenA")
 	assert.NoError(t, err)
 	assert.Equal(t, int64(0), amountOut.Int64())
 }
 
+func TestPriceCalculator_CalculateOutput_FeeSplit(t *testing.T) {
+	calculator := NewPriceCalculator()
+	amountIn := big.NewInt(1000000)
+
+	quote := func(lpFeeBps, protocolFeeBps int) *big.Int {
+		pool := &types.Pool{
+			Token0:         types.Token{Address: "0xtokena"},
+			Token1:         types.Token{Address: "0xtokenb"},
+			Reserve0:       big.NewInt(1000000000),
+			Reserve1:       big.NewInt(2000000000),
+			LPFeeBps:       lpFeeBps,
+			ProtocolFeeBps: protocolFeeBps,
+		}
+		amountOut, err := calculator.CalculateOutput(context.Background(), pool, amountIn, "0xtokena")
+		assert.NoError(t, err)
+		return amountOut
+	}
+
+	// 2e9 * 1e6 * 0.997 / (1e9 + 1e6 * 0.997)
+	defaultOut := quote(0, 0)
+	assert.Equal(t, "1992013", defaultOut.String())
+
+	// The protocol share is part of the 0.30% fee, not charged on top of it
+	assert.Equal(t, defaultOut.String(), quote(25, 5).String())
+	assert.Equal(t, defaultOut.String(), quote(30, 0).String())
+
+	// A lower total fee leaves more output
+	assert.Equal(t, 1, quote(4, 1).Cmp(defaultOut))
+	assert.Equal(t, quote(5, 0).String(), quote(4, 1).String())
+}
+
 func TestPriceCalculator_CalculatePathOutput(t *testing.T) {
 	calculator := NewPriceCalculator()
 	calculator.SetMaxSlippage(5.0) // Temporarily increase slippage limit
@@ -110,16 +189,122 @@ func TestPriceCalculator_CalculatePathOutput(t *testing.T) {
 		},
 	}
 
-	amountOut, err := calculator.CalculatePathOutput(pools, big.NewInt(1000), "0xTokenA", "0xTokenC")
+	amountOut, err := calculator.CalculatePathOutput(context.Background(), pools, big.NewInt(1000), "0xTokenA", "0xTokenC")
 	assert.NoError(t, err)
 	assert.True(t, amountOut.Cmp(big.NewInt(0)) > 0)
 
 	// Test empty path
-	amountOut, err = calculator.CalculatePathOutput([]*types.Pool{}, big.NewInt(1000), "0xTokenA", "0xTokenB")
+	amountOut, err = calculator.CalculatePathOutput(context.Background(), []*types.Pool{}, big.NewInt(1000), "0xTokenA", "0xTokenB")
 	assert.NoError(t, err)
 	assert.Equal(t, int64(0), amountOut.Int64())
 }
 
+func TestRouter_CalculatePathsConcurrently_HopDetails(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := NewRouter(context.Background(), mockStore, perfConfig)
+
+	path := []*types.Pool{
+		{Address: "pool-ab", Token0: types.Token{Address: "0xTokenA"}, Token1: types.Token{Address: "0xTokenB"}, Reserve0: big.NewInt(1000000000), Reserve1: big.NewInt(2000000000)},
+		// Traded from token1 to token0
+		{Address: "pool-cb", Token0: types.Token{Address: "0xTokenC"}, Token1: types.Token{Address: "0xTokenB"}, Reserve0: big.NewInt(3000000000), Reserve1: big.NewInt(1500000000)},
+		{Address: "pool-cd", Token0: types.Token{Address: "0xTokenC"}, Token1: types.Token{Address: "0xTokenD"}, Reserve0: big.NewInt(4000000000), Reserve1: big.NewInt(1000000000)},
+	}
+
+	amountIn := big.NewInt(1000000)
+	req := &types.QuoteRequest{TokenIn: "0xtokena", TokenOut: "0xtokend", AmountIn: amountIn}
+	tradePaths := router.calculatePathsConcurrently(context.Background(), [][]*types.Pool{path}, req, "0xtokena", "0xtokend")
+	if !assert.Len(t, tradePaths, 1) {
+		return
+	}
+	tradePath := tradePaths[0]
+	hops := tradePath.HopDetails
+	if !assert.Len(t, hops, 3) {
+		return
+	}
+
+	// Each hop feeds the next, and the last hop's output is the path's output
+	assert.Equal(t, amountIn.String(), hops[0].AmountIn.String())
+	for i := 1; i < len(hops); i++ {
+		assert.Equal(t, hops[i-1].AmountOut.String(), hops[i].AmountIn.String())
+		assert.Equal(t, hops[i-1].TokenOut, hops[i].TokenIn)
+	}
+	assert.Equal(t, tradePath.AmountOut.String(), hops[2].AmountOut.String())
+
+	assert.Equal(t, []string{"pool-ab", "pool-cb", "pool-cd"}, []string{hops[0].PoolAddress, hops[1].PoolAddress, hops[2].PoolAddress})
+	assert.Equal(t, "0xtokenb", hops[1].TokenIn)
+	assert.Equal(t, "0xtokenc", hops[1].TokenOut)
+	for _, hop := range hops {
+		// Every hop pays the 0.3% fee
+		assert.Greater(t, hop.PriceImpact, 0.29)
+	}
+
+	expected, err := router.calculator.CalculatePathOutput(context.Background(), path, amountIn, "0xtokena", "0xtokend")
+	assert.NoError(t, err)
+	assert.Equal(t, expected.String(), tradePath.AmountOut.String())
+}
+
+func TestRouter_CalculatePathsConcurrently_SlippagePercent(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := NewRouter(context.Background(), mockStore, perfConfig)
+
+	reserve := big.NewInt(1000000000)
+	direct := []*types.Pool{
+		{Address: "pool-ad", Token0: types.Token{Address: "0xtokena"}, Token1: types.Token{Address: "0xtokend"}, Reserve0: reserve, Reserve1: reserve},
+	}
+	multiHop := []*types.Pool{
+		{Address: "pool-ab", Token0: types.Token{Address: "0xtokena"}, Token1: types.Token{Address: "0xtokenb"}, Reserve0: reserve, Reserve1: reserve},
+		{Address: "pool-bc", Token0: types.Token{Address: "0xtokenb"}, Token1: types.Token{Address: "0xtokenc"}, Reserve0: reserve, Reserve1: reserve},
+		{Address: "pool-cd", Token0: types.Token{Address: "0xtokenc"}, Token1: types.Token{Address: "0xtokend"}, Reserve0: reserve, Reserve1: reserve},
+	}
+	amountIn := big.NewInt(10000000) // 1% of each pool
+
+	paths := func(maxSlippage float64) map[string]*types.TradePath {
+		req := &types.QuoteRequest{TokenIn: "0xtokena", TokenOut: "0xtokend", AmountIn: amountIn, MaxSlippage: maxSlippage}
+		byFirstPool := make(map[string]*types.TradePath)
+		for _, tradePath := range router.calculatePathsConcurrently(context.Background(), [][]*types.Pool{direct, multiHop}, req, "0xtokena", "0xtokend") {
+			byFirstPool[tradePath.Pools[0].Address] = tradePath
+		}
+		return byFirstPool
+	}
+
+	all := paths(0)
+	if !assert.Len(t, all, 2) {
+		return
+	}
+	for _, tradePath := range all {
+		var sum float64
+		for _, hop := range tradePath.HopDetails {
+			sum += hop.PriceImpact
+		}
+		assert.InDelta(t, sum, tradePath.SlippagePercent, 1e-9)
+	}
+
+	// A swap of 1% of a pool loses about 1% to price impact and 0.3% to the fee
+	single := all["pool-ad"].SlippagePercent
+	assert.InDelta(t, 1.3, single, 0.05)
+	// Each of the three hops slips about as much again
+	accumulated := all["pool-ab"].SlippagePercent
+	assert.Greater(t, accumulated, 3*single*0.95)
+	assert.Less(t, accumulated, 3*single*1.05)
+
+	// A tolerance between the two keeps only the direct path
+	filtered := paths((single + accumulated) / 2)
+	assert.Len(t, filtered, 1)
+	assert.Contains(t, filtered, "pool-ad")
+
+	assert.Empty(t, paths(single/2))
+}
+
 func TestRouter_GetBestQuote(t *testing.T) {
 	perfConfig := config.PerformanceConfig{
 		MaxSlippage:        5.0,
@@ -144,7 +329,7 @@ func TestRouter_GetBestQuote(t *testing.T) {
 	// It's called twice: 1. By NewPathFinder (initial load), 2. By GetBestQuote (logging).
 	mockStore.On("GetAllPools", mock.Anything).Return(mockPools, nil).Twice()
 
-	router := NewRouter(mockStore, perfConfig)
+	router := NewRouter(context.Background(), mockStore, perfConfig)
 
 	req := &types.QuoteRequest{
 		TokenIn:  "0xweth",
@@ -159,10 +344,193 @@ func TestRouter_GetBestQuote(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, response)
 	assert.True(t, response.AmountOut.Cmp(big.NewInt(0)) > 0)
+	assert.NotEmpty(t, response.ExecutionPrice)
 
 	mockStore.AssertExpectations(t)
 }
 
+func TestPriceCalculator_CalculateExecutionPrice(t *testing.T) {
+	calculator := NewPriceCalculator()
+
+	weth := types.Token{Address: "0xweth", Symbol: "WETH", Decimals: 18}
+	usdt := types.Token{Address: "0xusdt", Symbol: "USDT", Decimals: 6}
+	dai := types.Token{Address: "0xdai", Symbol: "DAI", Decimals: 18}
+	wethUsdt := &types.Pool{Address: "pool1", Token0: weth, Token1: usdt}
+	usdtDai := &types.Pool{Address: "pool2", Token0: usdt, Token1: dai}
+
+	oneWeth := big.NewInt(1000000000000000000)
+	twoThousandUsdt := big.NewInt(2000000000)
+
+	// 1 WETH -> 2000 USDT: 18 - 6 = 12 decimal adjustment
+	price, err := calculator.CalculateExecutionPrice([]*types.Pool{wethUsdt}, oneWeth, twoThousandUsdt, "0xweth", "0xusdt")
+	assert.NoError(t, err)
+	assert.Equal(t, "2000.000000", price)
+
+	// Reverse direction: 2000 USDT -> 1 WETH
+	price, err = calculator.CalculateExecutionPrice([]*types.Pool{wethUsdt}, twoThousandUsdt, oneWeth, "0xusdt", "0xweth")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.000500", price)
+
+	// Multi-hop reads tokenOut decimals from the last pool
+	twoThousandDai, _ := new(big.Int).SetString("2000000000000000000000", 10)
+	price, err = calculator.CalculateExecutionPrice([]*types.Pool{wethUsdt, usdtDai}, oneWeth, twoThousandDai, "0xweth", "0xdai")
+	assert.NoError(t, err)
+	assert.Equal(t, "2000.000000", price)
+
+	_, err = calculator.CalculateExecutionPrice([]*types.Pool{wethUsdt}, oneWeth, twoThousandUsdt, "0xweth", "0xdai")
+	assert.Error(t, err)
+}
+
+func TestPriceCalculator_PairSlippageOverrides(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	const (
+		usdc = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+		usdt = "0xdac17f958d2ee523a2206206994597c13d831ec7"
+		dai  = "0x6b175474e89094c44da98b954eedeac495271d0f"
+	)
+	pool := func(token0, token1 string) *types.Pool {
+		// 1,000,000 of each stablecoin, in 6-decimal units
+		return &types.Pool{
+			Address:  token0 + "-" + token1,
+			Token0:   types.Token{Address: token0, Decimals: 6},
+			Token1:   types.Token{Address: token1, Decimals: 6},
+			Reserve0: big.NewInt(1000000e6),
+			Reserve1: big.NewInt(1000000e6),
+		}
+	}
+	// About 0.8% slippage with the fee: under the 5% default but over a 0.1% override
+	amountIn := big.NewInt(5000e6)
+	smallAmountIn := big.NewInt(100e6)
+
+	calculator := NewPriceCalculator()
+	// Keys match in either order and case
+	calculator.SetPairSlippageOverrides(map[string]float64{
+		strings.ToUpper(usdt) + ":" + usdc: 0.1,
+		"malformed":                        0.1,
+	})
+
+	assert.Equal(t, 0.1, calculator.MaxSlippageForPair(usdc, usdt))
+	assert.Equal(t, 0.1, calculator.MaxSlippageForPair(usdt, usdc))
+	assert.Equal(t, 5.0, calculator.MaxSlippageForPair(usdc, dai))
+
+	_, err := calculator.CalculateOutput(context.Background(), pool(usdc, usdt), amountIn, usdc)
+	assert.ErrorContains(t, err, "slippage too high", "the override rejects the trade")
+	_, err = calculator.CalculateOutput(context.Background(), pool(usdt, usdc), amountIn, usdc)
+	assert.ErrorContains(t, err, "slippage too high", "pools with the tokens reversed share the override")
+
+	out, err := calculator.CalculateOutput(context.Background(), pool(usdc, dai), amountIn, usdc)
+	assert.NoError(t, err, "the default limit accepts the same trade on another pair")
+	assert.Positive(t, out.Sign())
+
+	// Trades within the override still go through. The 0.3% fee counts as slippage, so an
+	// override below it would reject every trade.
+	calculator.SetPairSlippageOverrides(map[string]float64{usdc + ":" + usdt: 0.5})
+	_, err = calculator.CalculateOutput(context.Background(), pool(usdc, usdt), smallAmountIn, usdc)
+	assert.NoError(t, err)
+
+	// Clearing the overrides restores the default
+	calculator.SetPairSlippageOverrides(nil)
+	_, err = calculator.CalculateOutput(context.Background(), pool(usdc, usdt), amountIn, usdc)
+	assert.NoError(t, err)
+}
+
+func TestPriceCalculator_NormalisedSpotPrice(t *testing.T) {
+	calculator := NewPriceCalculator()
+
+	weth := types.Token{Address: "0xWETH", Symbol: "WETH", Decimals: 18}
+	usdt := types.Token{Address: "0xusdt", Symbol: "USDT", Decimals: 6}
+	e18 := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	// 1000 WETH against 2,000,000 USDT
+	pool := &types.Pool{
+		Address:  "pool1",
+		Token0:   weth,
+		Token1:   usdt,
+		Reserve0: new(big.Int).Mul(big.NewInt(1000), e18),
+		Reserve1: big.NewInt(2000000e6),
+	}
+
+	price, err := calculator.NormalisedSpotPrice(pool, "0xweth")
+	assert.NoError(t, err)
+	value, _ := price.Float64()
+	assert.InDelta(t, 2000, value, 1e-9)
+
+	price, err = calculator.NormalisedSpotPrice(pool, "0xusdt")
+	assert.NoError(t, err)
+	value, _ = price.Float64()
+	assert.InDelta(t, 0.0005, value, 1e-15)
+
+	_, err = calculator.NormalisedSpotPrice(pool, "0xdai")
+	assert.Error(t, err)
+
+	empty := &types.Pool{Address: "pool2", Token0: weth, Token1: usdt, Reserve0: big.NewInt(0), Reserve1: big.NewInt(1)}
+	_, err = calculator.NormalisedSpotPrice(empty, "0xweth")
+	assert.Error(t, err)
+
+	// Selling 1 WETH returns just under 2000 USDT after the fee and price impact
+	out, err := calculator.CalculateNormalisedOutput(pool, e18, "0xweth")
+	assert.NoError(t, err)
+	value, _ = out.Float64()
+	assert.InDelta(t, 2000*0.997*1000/1000.997, value, 1e-6)
+
+	_, err = calculator.CalculateNormalisedOutput(pool, e18, "0xdai")
+	assert.Error(t, err)
+}
+
+func TestPriceCalculator_ComputeImpermanentLoss(t *testing.T) {
+	calculator := NewPriceCalculator()
+	e18 := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	weth := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), e18) }
+
+	testCases := []struct {
+		name                    string
+		entryR0, entryR1        *big.Int
+		currentR0, currentR1    *big.Int
+		expectedImpermanentLoss float64
+	}{
+		{"Equal ratio", weth(10), big.NewInt(20000e6), weth(20), big.NewInt(40000e6), 0},
+		// Price of token0 doubles: 2*sqrt(2)/3 - 1
+		{"2x price", weth(100), big.NewInt(200000e6), weth(100), big.NewInt(400000e6), -0.057191},
+		// Price of token0 quadruples: 2*2/5 - 1
+		{"4x price", weth(100), big.NewInt(200000e6), weth(50), big.NewInt(400000e6), -0.2},
+		// Price of token0 quarters, symmetric with 4x
+		{"0.25x price", weth(50), big.NewInt(400000e6), weth(100), big.NewInt(200000e6), -0.2},
+		{"Zero reserve", weth(1), big.NewInt(0), weth(1), big.NewInt(1), 0},
+		{"Nil reserve", nil, big.NewInt(1), weth(1), big.NewInt(1), 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			il := calculator.ComputeImpermanentLoss(tc.entryR0, tc.entryR1, tc.currentR0, tc.currentR1)
+			assert.InDelta(t, tc.expectedImpermanentLoss, il, 1e-6)
+		})
+	}
+}
+
+func TestPriceCalculator_CalculatePathPriceImpact(t *testing.T) {
+	calculator := NewPriceCalculator()
+	path := []*types.Pool{
+		{Address: "a-b", Token0: types.Token{Address: "0xtokena"}, Token1: types.Token{Address: "0xtokenb"}, Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000)},
+		{Address: "c-b", Token0: types.Token{Address: "0xtokenc"}, Token1: types.Token{Address: "0xtokenb"}, Reserve0: big.NewInt(3000), Reserve1: big.NewInt(3000)},
+	}
+
+	// 100 A is worth 200 C at spot prices
+	impact, err := calculator.CalculatePathPriceImpact(path, big.NewInt(100), big.NewInt(180), "0xTokenA")
+	assert.NoError(t, err)
+	assert.InDelta(t, 10.0, impact, 1e-9)
+
+	impact, err = calculator.CalculatePathPriceImpact(path, big.NewInt(100), big.NewInt(200), "0xtokena")
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.0, impact, 1e-9)
+
+	_, err = calculator.CalculatePathPriceImpact(path, big.NewInt(100), big.NewInt(180), "0xtokenc")
+	assert.Error(t, err)
+
+	_, err = calculator.CalculatePathPriceImpact(nil, big.NewInt(100), big.NewInt(180), "0xtokena")
+	assert.Error(t, err)
+}
+
 func TestPathFinder_FindDirectPaths(t *testing.T) {
 	mockStore := new(MockStore)
 
@@ -189,7 +557,7 @@ func TestPathFinder_FindDirectPaths(t *testing.T) {
 	mockStore.On("GetAllPools", mock.Anything).Return(mockPools, nil).Once()
 
 	// Now, create the PathFinder
-	pathFinder := NewPathFinder(mockStore, NewPriceCalculator())
+	pathFinder := NewPathFinder(context.Background(), mockStore, NewPriceCalculator())
 
 	// We can optionally test the RefreshGraph function again explicitly
 	// Add a second expectation for this explicit call.
@@ -215,7 +583,7 @@ func TestRouter_FindOptimalPath(t *testing.T) {
 	// Add expectation for the initial load in NewRouter
 	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
 
-	router := NewRouter(mockStore, perfConfig)
+	router := NewRouter(context.Background(), mockStore, perfConfig)
 
 	tradePaths := []*types.TradePath{
 		{
@@ -232,9 +600,1057 @@ func TestRouter_FindOptimalPath(t *testing.T) {
 		},
 	}
 
-	bestPath := router.findOptimalPath(tradePaths)
+	bestPath := router.findOptimalPath(tradePaths, nil, 0)
 	assert.NotNil(t, bestPath)
 	assert.Equal(t, int64(1200), bestPath.AmountOut.Int64())
+	assert.Nil(t, bestPath.NetAmountOut)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestRouter_FindOptimalPath_NetOfGas(t *testing.T) {
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := NewRouter(context.Background(), mockStore, config.PerformanceConfig{MaxConcurrentPaths: 1})
+
+	oneHop := &types.TradePath{
+		Pools:     []*types.Pool{{Address: "direct"}},
+		AmountOut: big.NewInt(10000000000000000), // 0.01 tokenOut
+		GasCost:   big.NewInt(121000),
+	}
+	twoHop := &types.TradePath{
+		Pools:     []*types.Pool{{Address: "hop1"}, {Address: "hop2"}},
+		AmountOut: big.NewInt(11000000000000000), // Higher gross output
+		GasCost:   big.NewInt(221000),
+	}
+	gasPriceWei := big.NewInt(30000000000) // 30 gwei
+
+	// Gross ranking prefers the two-hop path
+	assert.Equal(t, twoHop, router.findOptimalPath([]*types.TradePath{oneHop, twoHop}, nil, 0))
+
+	// Net of gas, the extra 100000 gas (0.003 tokenOut) outweighs the 0.001 gain
+	bestPath := router.findOptimalPath([]*types.TradePath{oneHop, twoHop}, gasPriceWei, 0)
+	assert.Equal(t, oneHop, bestPath)
+	assert.Equal(t, "6370000000000000", oneHop.NetAmountOut.String())
+	assert.Equal(t, "4370000000000000", twoHop.NetAmountOut.String())
+	assert.Equal(t, netScore(twoHop, gasPriceWei), twoHop.NetAmountOut)
+}
+
+func TestRouter_FindOptimalPath_RiskAversion(t *testing.T) {
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := NewRouter(context.Background(), mockStore, config.PerformanceConfig{MaxConcurrentPaths: 1})
+
+	newPaths := func() (*types.TradePath, *types.TradePath) {
+		shallow := &types.TradePath{
+			Pools:       []*types.Pool{{Address: "shallow"}},
+			AmountOut:   big.NewInt(1000000),
+			GasCost:     big.NewInt(110000),
+			PriceImpact: 4.0,
+		}
+		deep := &types.TradePath{
+			Pools:       []*types.Pool{{Address: "deep"}},
+			AmountOut:   big.NewInt(980000), // 2% lower output
+			GasCost:     big.NewInt(110000),
+			PriceImpact: 0.5,
+		}
+		return shallow, deep
+	}
+
+	testCases := []struct {
+		name         string
+		riskAversion float64
+		expected     string
+		expectedNet  string // NetAmountOut of the best path, empty when not ranked by utility
+	}{
+		{"Ignore slippage", 0, "shallow", ""},
+		{"Low risk aversion", 0.25, "shallow", "990000"},  // 1000000 - 10000 beats 980000 - 1225
+		{"High risk aversion", 1, "deep", "975100"},       // 980000 - 4900 beats 1000000 - 40000
+		{"Very high risk aversion", 10, "deep", "931000"}, // 980000 - 49000 beats 1000000 - 400000
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			shallow, deep := newPaths()
+			bestPath := router.findOptimalPath([]*types.TradePath{shallow, deep}, nil, tc.riskAversion)
+
+			assert.Equal(t, tc.expected, bestPath.Pools[0].Address)
+			if tc.expectedNet == "" {
+				assert.Nil(t, bestPath.NetAmountOut)
+			} else {
+				assert.Equal(t, tc.expectedNet, bestPath.NetAmountOut.String())
+			}
+		})
+	}
+}
+
+func TestDiversifyByDEX(t *testing.T) {
+	tradePaths := []*types.TradePath{
+		{AmountOut: big.NewInt(1000), Dexes: []string{"Uniswap V2"}},
+		{AmountOut: big.NewInt(1500), Dexes: []string{"Uniswap V2"}},
+		{AmountOut: big.NewInt(1200), Dexes: []string{"Uniswap V2", "SushiSwap"}},
+		{AmountOut: big.NewInt(1100), Dexes: []string{"Uniswap V2", "SushiSwap"}},
+	}
+
+	diversified := diversifyByDEX(tradePaths)
+
+	assert.Len(t, diversified, 2)
+	assert.Equal(t, int64(1500), diversified[0].AmountOut.Int64())
+	assert.Equal(t, []string{"Uniswap V2"}, diversified[0].Dexes)
+	assert.Equal(t, int64(1200), diversified[1].AmountOut.Int64())
+	assert.Equal(t, []string{"Uniswap V2", "SushiSwap"}, diversified[1].Dexes)
+
+	// Exchange order matters: SushiSwap then Uniswap V2 is a different combination
+	diversified = diversifyByDEX(append(tradePaths, &types.TradePath{AmountOut: big.NewInt(900), Dexes: []string{"SushiSwap", "Uniswap V2"}}))
+	assert.Len(t, diversified, 3)
+}
+
+func TestRouter_DeduplicatePaths(t *testing.T) {
+	mockStore := new(MockStore)
+	// Add expectation for the initial load in NewRouter
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := NewRouter(context.Background(), mockStore, config.PerformanceConfig{MaxSlippage: 5.0, MaxConcurrentPaths: 10, DeduplicatePaths: true})
+
+	poolA := &types.Pool{Address: "0xPoolA"}
+	poolB := &types.Pool{Address: "0xpoolb"}
+	poolC := &types.Pool{Address: "0xpoolc"}
+
+	tradePaths := []*types.TradePath{
+		{Pools: []*types.Pool{poolA, poolB}, AmountOut: big.NewInt(1000)},
+		{Pools: []*types.Pool{poolB, poolA}, AmountOut: big.NewInt(1100)},
+		{Pools: []*types.Pool{poolA, poolC}, AmountOut: big.NewInt(900)},
+	}
+
+	deduplicated := router.deduplicatePaths(tradePaths)
+
+	// The reordered A/B path is a duplicate; the one with more output survives
+	if assert.Len(t, deduplicated, 2) {
+		assert.Equal(t, int64(1100), deduplicated[0].AmountOut.Int64())
+		assert.Equal(t, int64(900), deduplicated[1].AmountOut.Int64())
+	}
+	assert.Equal(t, "0xpoola|0xpoolb", pathPoolKey(tradePaths[1].Pools))
 
 	mockStore.AssertExpectations(t)
 }
+
+func TestRouter_BypassesFailingExchange(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ctx := context.Background()
+	store := cache.NewMemoryStore()
+	weth := types.Token{Address: "0xweth", Symbol: "WETH", Decimals: 18}
+	usdt := types.Token{Address: "0xusdt", Symbol: "USDT", Decimals: 6}
+	deep, _ := new(big.Int).SetString("1000000000000000000000", 10)
+	pools := []*types.Pool{
+		{Address: "0xuni", Exchange: "Uniswap V2", Token0: weth, Token1: usdt, Reserve0: deep, Reserve1: big.NewInt(2000000000000)},
+		// Reserves read an hour ago, so every hop through it is skipped as stale
+		{Address: "0xsushi", Exchange: "SushiSwap", Token0: weth, Token1: usdt, Reserve0: deep, Reserve1: big.NewInt(2000000000000), ReserveUpdatedAt: time.Now().Add(-time.Hour)},
+	}
+	for _, pool := range pools {
+		assert.NoError(t, store.StorePool(ctx, pool))
+	}
+
+	router := NewRouter(ctx, store, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10})
+	router.SetReserveStaleness(config.DEXConfig{MaxReserveAgeSecs: 60})
+	req := &types.QuoteRequest{TokenIn: "0xweth", TokenOut: "0xusdt", AmountIn: big.NewInt(1000000000000000000), MaxHops: 2}
+
+	for i := 0; i < circuitbreaker.DefaultFailureThreshold; i++ {
+		resp, err := router.GetBestQuote(ctx, req)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Uniswap V2"}, resp.BestPath.Dexes)
+	}
+
+	stats := router.ExchangeBreakerStats()
+	assert.Equal(t, int64(1), stats["SushiSwap"].Trips)
+	assert.Equal(t, int64(0), stats["Uniswap V2"].Trips)
+	assert.Positive(t, stats["Uniswap V2"].Successes)
+	assert.Zero(t, stats["Uniswap V2"].Failures)
+
+	// Opening the breaker rebuilds the graph without the exchange's pools
+	assert.Eventually(t, func() bool {
+		return !strings.Contains(router.ExportGraphDOT(), "SushiSwap")
+	}, time.Second, 10*time.Millisecond)
+	assert.Contains(t, router.ExportGraphDOT(), "Uniswap V2")
+}
+
+func TestRouter_OversizedQuotesKeepBreakerClosed(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ctx := context.Background()
+	store := cache.NewMemoryStore()
+	weth := types.Token{Address: "0xweth", Symbol: "WETH", Decimals: 18}
+	usdt := types.Token{Address: "0xusdt", Symbol: "USDT", Decimals: 6}
+	deep, _ := new(big.Int).SetString("1000000000000000000000", 10)
+	pools := []*types.Pool{
+		{Address: "0xuni", Exchange: "Uniswap V2", Token0: weth, Token1: usdt, Reserve0: deep, Reserve1: big.NewInt(2000000000000)},
+		// Far too shallow for a 1 WETH trade, so every hop through it fails the slippage check
+		{Address: "0xsushi", Exchange: "SushiSwap", Token0: weth, Token1: usdt, Reserve0: big.NewInt(1000000000000000000), Reserve1: big.NewInt(2000000000)},
+	}
+	for _, pool := range pools {
+		assert.NoError(t, store.StorePool(ctx, pool))
+	}
+	router := NewRouter(ctx, store, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10})
+
+	oneWETH := big.NewInt(1000000000000000000)
+	for i := 0; i < 2*circuitbreaker.DefaultFailureThreshold; i++ {
+		// Too large for SushiSwap's pool only
+		resp, err := router.GetBestQuote(ctx, &types.QuoteRequest{TokenIn: "0xweth", TokenOut: "0xusdt", AmountIn: oneWETH, MaxHops: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Uniswap V2"}, resp.BestPath.Dexes)
+
+		// Too large for every pool: the quote fails, but no exchange is to blame
+		_, err = router.GetBestQuote(ctx, &types.QuoteRequest{TokenIn: "0xweth", TokenOut: "0xusdt", AmountIn: new(big.Int).Mul(deep, big.NewInt(int64(i+1))), MaxHops: 2})
+		assert.Error(t, err)
+	}
+
+	stats := router.ExchangeBreakerStats()
+	for _, exchange := range []string{"SushiSwap", "Uniswap V2"} {
+		assert.Zero(t, stats[exchange].Trips, exchange)
+		assert.Zero(t, stats[exchange].Failures, exchange)
+	}
+	assert.Contains(t, router.ExportGraphDOT(), "SushiSwap")
+}
+
+func TestRouter_GetBestQuote_DiversifyDEX(t *testing.T) {
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+
+	// Two Uniswap pools and one SushiSwap pool for the same pair
+	var pools []*types.Pool
+	for i, exchange := range []string{"Uniswap V2", "Uniswap V2", "SushiSwap"} {
+		pools = append(pools, &types.Pool{
+			Address:  fmt.Sprintf("pool%d", i),
+			Exchange: exchange,
+			Token0:   types.Token{Address: "0xweth"},
+			Token1:   types.Token{Address: "0xusdt"},
+			Reserve0: big.NewInt(1000000000000000000),
+			Reserve1: big.NewInt(int64(2000000000000 + i)),
+		})
+	}
+
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return(pools, nil)
+	router := NewRouter(context.Background(), mockStore, perfConfig)
+
+	req := &types.QuoteRequest{
+		TokenIn:  "0xweth",
+		TokenOut: "0xusdt",
+		AmountIn: big.NewInt(1000000000000000),
+		MaxHops:  1,
+	}
+
+	response, err := router.GetBestQuote(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Len(t, response.Paths, 3)
+
+	req.DiversifyDEX = true
+	response, err = router.GetBestQuote(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Len(t, response.Paths, 2)
+}
+
+func TestRouter_GetBestQuote_GasToken(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	// 1000 WETH : 2,000,000 USDC, so one wei of gas is worth 2000e6/1e18 USDC base units
+	wethReserve, _ := new(big.Int).SetString("1000000000000000000000", 10)
+	store := testutil.NewMemStoreWithPools(
+		testutil.NewPool().WithAddress("0xwethusdc").WithTokenAddresses(WETHAddress, "0xusdc").
+			WithReserves(wethReserve, big.NewInt(2000000000000)).Build(),
+		testutil.NewPool().WithAddress("0xusdcdai").WithTokenAddresses("0xusdc", "0xdai").
+			WithReserves(big.NewInt(1000000000000), big.NewInt(1000000000000)).Build(),
+		testutil.NewPool().WithAddress("0xorphan").WithTokenAddresses("0xfoo", "0xbar").
+			WithReserves(big.NewInt(1000000000000), big.NewInt(1000000000000)).Build(),
+	)
+	router := NewRouter(context.Background(), store, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10})
+
+	testCases := []struct {
+		name         string
+		gasToken     string
+		gasPriceGwei uint64
+		// expected converts the gas cost in wei into the gas token, or is nil when omitted
+		expected func(gasCostWei *big.Int) *big.Int
+	}{
+		{"no gas token", "", 0, nil},
+		{"WETH at the default gas price", WETHAddress, 0, func(gasCostWei *big.Int) *big.Int { return gasCostWei }},
+		{"WETH in mixed case", "0x" + strings.ToUpper(WETHAddress[2:]), 50, func(gasCostWei *big.Int) *big.Int { return gasCostWei }},
+		{"USDC", "0xusdc", 50, func(gasCostWei *big.Int) *big.Int {
+			return new(big.Int).Div(new(big.Int).Mul(gasCostWei, big.NewInt(2000000000000)), wethReserve)
+		}},
+		{"token without a price path", "0xfoo", 50, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &types.QuoteRequest{
+				TokenIn:      "0xusdc",
+				TokenOut:     "0xdai",
+				AmountIn:     big.NewInt(1000000),
+				GasPriceGwei: tc.gasPriceGwei,
+				GasToken:     tc.gasToken,
+			}
+			resp, err := router.GetBestQuote(context.Background(), req)
+			assert.NoError(t, err)
+
+			if tc.expected == nil {
+				assert.Nil(t, resp.GasCostInGasToken)
+				return
+			}
+			gasPriceGwei := tc.gasPriceGwei
+			if gasPriceGwei == 0 {
+				gasPriceGwei = defaultGasPriceGwei
+			}
+			gasCostWei := new(big.Int).Mul(resp.GasEstimate, new(big.Int).SetUint64(gasPriceGwei*1e9))
+			if assert.NotNil(t, resp.GasCostInGasToken) {
+				assert.Equal(t, tc.expected(gasCostWei).String(), resp.GasCostInGasToken.String())
+			}
+		})
+	}
+}
+
+func TestRouter_WarmCommonQuotes(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	originalConfig := config.Current()
+	defer config.Set(originalConfig)
+	// 0xlonely has no pools, so its six pairs have no route
+	config.Set(&config.Config{BaseTokens: []string{"0xWETH", "0xusdt", "0xdai", "0xlonely"}})
+
+	weth := types.Token{Address: "0xweth", Decimals: 18}
+	usdt := types.Token{Address: "0xusdt", Decimals: 6}
+	dai := types.Token{Address: "0xdai", Decimals: 18}
+	deep, _ := new(big.Int).SetString("1000000000000000000000000", 10)
+	store := testutil.NewMemStoreWithPools(
+		testutil.NewPool().WithAddress("0x01").WithTokens(weth, usdt).WithReserves(deep, deep).Build(),
+		testutil.NewPool().WithAddress("0x02").WithTokens(weth, dai).WithReserves(deep, deep).Build(),
+	)
+
+	t.Run("fills the quote cache", func(t *testing.T) {
+		perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10, GraphRefreshInterval: time.Minute}
+		router := NewRouter(context.Background(), store, perfConfig)
+
+		assert.NoError(t, router.WarmCommonQuotes(context.Background()))
+		// Every ordered pair of weth, usdt and dai, with usdt and dai routed through weth
+		assert.Equal(t, 6, router.quotes.len())
+
+		// One whole usdt is 10^6 of its smallest unit, not 10^18
+		wrongScale := &types.QuoteRequest{TokenIn: "0xusdt", TokenOut: "0xdai", AmountIn: big.NewInt(1e18)}
+		_, ok := router.quotes.get(newQuoteCacheKey(wrongScale))
+		assert.False(t, ok)
+
+		req := &types.QuoteRequest{TokenIn: "0xusdt", TokenOut: "0xdai", AmountIn: big.NewInt(1e6)}
+		cached, ok := router.quotes.get(newQuoteCacheKey(req))
+		if assert.True(t, ok) {
+			assert.Len(t, cached.BestPath.Pools, 2)
+		}
+		_, ok = router.quotes.get(newQuoteCacheKey(&types.QuoteRequest{TokenIn: "0xdai", TokenOut: "0xusdt", AmountIn: big.NewInt(1e18)}))
+		assert.True(t, ok)
+
+		resp, err := router.GetBestQuote(context.Background(), req)
+		assert.NoError(t, err)
+		assert.Equal(t, cached.AmountOut.String(), resp.AmountOut.String())
+
+		// Routing config changes drop the cached quotes
+		router.SetGasCosts(config.DEXConfig{})
+		assert.Equal(t, 0, router.quotes.len())
+	})
+
+	t.Run("disabled without a graph refresh interval", func(t *testing.T) {
+		perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+		router := NewRouter(context.Background(), store, perfConfig)
+
+		assert.NoError(t, router.WarmCommonQuotes(context.Background()))
+		assert.Equal(t, 0, router.quotes.len())
+	})
+
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10, GraphRefreshInterval: time.Minute}
+		router := NewRouter(context.Background(), store, perfConfig)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, router.WarmCommonQuotes(ctx), context.Canceled)
+		assert.Equal(t, 0, router.quotes.len())
+	})
+}
+
+func TestRouter_EstimateGasCost_ConfiguredExchanges(t *testing.T) {
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := NewRouter(context.Background(), mockStore, config.PerformanceConfig{MaxConcurrentPaths: 1})
+
+	router.SetGasCosts(config.DEXConfig{
+		GasCosts: map[string]int64{"Fictional Swap": 75000, "Uniswap V2": 100000},
+		BaseGas:  30000,
+		HopGas:   5000,
+	})
+
+	testCases := []struct {
+		name     string
+		path     []*types.Pool
+		expected int64
+	}{
+		{"configured exchange", []*types.Pool{{Exchange: "Fictional Swap"}}, 30000 + 75000 + 5000},
+		{"case-insensitive lookup", []*types.Pool{{Exchange: "fictional swap"}}, 30000 + 75000 + 5000},
+		{"unknown exchange falls back", []*types.Pool{{Exchange: "Unknown DEX"}}, 30000 + 110000 + 5000},
+		{"multi-hop", []*types.Pool{{Exchange: "Fictional Swap"}, {Exchange: "Uniswap V2"}}, 30000 + 75000 + 100000 + 2*5000},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, big.NewInt(tc.expected), router.estimateGasCost(tc.path))
+		})
+	}
+
+	// An unset base gas falls back to the 21000 transaction cost
+	router.SetGasCosts(config.DEXConfig{})
+	assert.Equal(t, big.NewInt(21000+110000), router.estimateGasCost([]*types.Pool{{Exchange: "Uniswap V2"}}))
+}
+
+func TestPathFinder_SaveAndLoadGraph(t *testing.T) {
+	mockStore := new(MockStore)
+
+	mockPools := []*types.Pool{
+		{
+			Address:  "pool1",
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xtokena"},
+			Token1:   types.Token{Address: "0xtokenb"},
+			Reserve0: big.NewInt(1000000000),
+			Reserve1: big.NewInt(2000000000),
+			Fee:      300,
+		},
+		{
+			Address:  "pool2",
+			Exchange: "SushiSwap",
+			Token0:   types.Token{Address: "0xtokenb"},
+			Token1:   types.Token{Address: "0xtokenc"},
+			Reserve0: big.NewInt(1000000000),
+			Reserve1: big.NewInt(2000000000),
+			Fee:      300,
+		},
+	}
+	mockStore.On("GetAllPools", mock.Anything).Return(mockPools, nil).Once()
+
+	pathFinder := NewPathFinder(context.Background(), mockStore, NewPriceCalculator())
+
+	var buf bytes.Buffer
+	err := pathFinder.SaveGraph(context.Background(), &buf)
+	assert.NoError(t, err)
+	assert.Greater(t, buf.Len(), 0)
+
+	// Load into a fresh path finder without touching the store
+	restored := newPathFinder(context.Background(), new(MockStore), NewPriceCalculator())
+	err = restored.LoadGraph(context.Background(), &buf, time.Minute)
+	assert.NoError(t, err)
+
+	g := restored.graph.Load()
+	assert.NotNil(t, g)
+	assert.True(t, g.hasEdge("0xtokena", "0xtokenb"))
+	assert.True(t, g.hasEdge("0xtokenc", "0xtokenb"))
+	assert.False(t, g.hasEdge("0xtokena", "0xtokenc"))
+
+	edges := g.edgesBetween("0xtokenb", "0xtokenc")
+	assert.Equal(t, 1, len(edges))
+	assert.Equal(t, "pool2", edges[0].pool.Address)
+	assert.Equal(t, "SushiSwap", edges[0].pool.Exchange)
+	// Reserves are restored, so the loaded graph quotes before its first refresh
+	assert.Equal(t, int64(1000000000), edges[0].pool.Reserve0.Int64())
+	assert.Equal(t, int64(2000000000), edges[0].pool.Reserve1.Int64())
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestPathFinder_LoadGraph_Stale(t *testing.T) {
+	var buf bytes.Buffer
+	snapshot := graphSnapshot{
+		SavedAt: time.Now().Add(-time.Hour),
+		Pools: []snapshotPool{{
+			Address:  "pool1",
+			Token0:   types.Token{Address: "0xtokena"},
+			Token1:   types.Token{Address: "0xtokenb"},
+			Reserve0: big.NewInt(1000000000),
+			Reserve1: big.NewInt(2000000000),
+		}},
+	}
+	assert.NoError(t, gob.NewEncoder(&buf).Encode(&snapshot))
+	data := buf.Bytes()
+
+	pathFinder := newPathFinder(context.Background(), new(MockStore), NewPriceCalculator())
+	err := pathFinder.LoadGraph(context.Background(), bytes.NewReader(data), time.Minute)
+	assert.ErrorIs(t, err, ErrStaleSnapshot)
+	assert.Nil(t, pathFinder.graph.Load())
+
+	// Without a maximum age any snapshot is loaded
+	assert.NoError(t, pathFinder.LoadGraph(context.Background(), bytes.NewReader(data), 0))
+	assert.True(t, pathFinder.graph.Load().hasEdge("0xtokena", "0xtokenb"))
+}
+
+func TestPathFinder_LoadGraph_KeepsFees(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	reserve, _ := new(big.Int).SetString("1000000000000000000000000", 10)
+	lowFee := testutil.NewPool().WithAddress("0xlowfee").WithTokenAddresses("0xtokena", "0xtokenb").WithReserves(reserve, reserve).Build()
+	lowFee.LPFeeBps = 5
+	feeSwitch := testutil.NewPool().WithAddress("0xfeeswitch").WithTokenAddresses("0xtokenb", "0xtokenc").WithReserves(reserve, reserve).Build()
+	feeSwitch.LPFeeBps, feeSwitch.ProtocolFeeBps = 25, 5
+	paused := testutil.NewPool().WithAddress("0xpaused").WithTokenAddresses("0xtokena", "0xtokenc").WithReserves(reserve, reserve).WithPaused(true).Build()
+
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{lowFee, feeSwitch, paused}, nil).Once()
+	var buf bytes.Buffer
+	assert.NoError(t, NewPathFinder(context.Background(), mockStore, NewPriceCalculator()).SaveGraph(context.Background(), &buf))
+
+	calculator := NewPriceCalculator()
+	restored := newPathFinder(context.Background(), new(MockStore), calculator)
+	assert.NoError(t, restored.LoadGraph(context.Background(), &buf, time.Minute))
+
+	g := restored.graph.Load()
+	if edges := g.edgesBetween("0xtokenb", "0xtokenc"); assert.Len(t, edges, 1) {
+		assert.Equal(t, 25, edges[0].pool.LPFeeBps)
+		assert.Equal(t, 5, edges[0].pool.ProtocolFeeBps)
+	}
+	assert.False(t, g.hasEdge("0xtokena", "0xtokenc"), "paused pools stay out of routing")
+
+	// The 5 bps pool is quoted at 5 bps, not the 30 bps default, before any refresh
+	// The router only lists the store's pools for logging, so the empty store is not used for routing
+	routerStore := new(MockStore)
+	routerStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil)
+	router := newRouter(routerStore, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}, calculator, restored)
+	amountIn := big.NewInt(1e18)
+	resp, err := router.GetBestQuote(context.Background(), &types.QuoteRequest{TokenIn: "0xtokena", TokenOut: "0xtokenb", AmountIn: amountIn})
+	if assert.NoError(t, err) {
+		expected, err := calculator.CalculateOutput(context.Background(), lowFee, amountIn, "0xtokena")
+		assert.NoError(t, err)
+		assert.Equal(t, expected.String(), resp.AmountOut.String())
+
+		atDefaultFee := *lowFee
+		atDefaultFee.LPFeeBps = 0
+		defaultOut, err := calculator.CalculateOutput(context.Background(), &atDefaultFee, amountIn, "0xtokena")
+		assert.NoError(t, err)
+		assert.NotEqual(t, defaultOut.String(), resp.AmountOut.String())
+	}
+}
+
+func TestPathFinder_LoadGraph_InvalidData(t *testing.T) {
+	pathFinder := newPathFinder(context.Background(), new(MockStore), NewPriceCalculator())
+
+	err := pathFinder.LoadGraph(context.Background(), bytes.NewReader([]byte("not a snapshot")), 0)
+	assert.Error(t, err)
+	assert.Nil(t, pathFinder.graph.Load())
+}
+
+func TestPathFinder_ExportGraphDOT(t *testing.T) {
+	pathFinder := newPathFinder(context.Background(), new(MockStore), NewPriceCalculator())
+	assert.Equal(t, "graph dex {\n  node [shape=ellipse, fontname=\"monospace\"];\n}\n", pathFinder.ExportGraphDOT())
+
+	pathFinder.graph.Store(buildGraphParallel([]*types.Pool{
+		{
+			Address:  "pool1",
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"},
+			Token1:   types.Token{Address: "0xdAC17F958D2ee523a2206206994597C13D831ec7"},
+			Reserve0: big.NewInt(1000),
+			Reserve1: big.NewInt(1000),
+			Fee:      300,
+		},
+		{
+			Address:  "pool2",
+			Exchange: "SushiSwap",
+			Token0:   types.Token{Address: "0xdAC17F958D2ee523a2206206994597C13D831ec7"},
+			Token1:   types.Token{Address: "0x6B175474E89094C44Da98b954EedeAC495271d0F"},
+			Reserve0: big.NewInt(1000),
+			Reserve1: big.NewInt(1000),
+			Fee:      250,
+		},
+	}, 1))
+
+	dot := pathFinder.ExportGraphDOT()
+	assert.True(t, strings.HasPrefix(dot, "graph dex {\n"))
+	assert.True(t, strings.HasSuffix(dot, "}\n"))
+
+	// Nodes
+	assert.Contains(t, dot, `"0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2" [label="0xc02a...6cc2"];`)
+	assert.Contains(t, dot, `"0xdac17f958d2ee523a2206206994597c13d831ec7" [label="0xdac1...1ec7"];`)
+	assert.Contains(t, dot, `"0x6b175474e89094c44da98b954eedeac495271d0f" [label="0x6b17...1d0f"];`)
+
+	// One edge per pool, coloured by exchange in name order
+	assert.Contains(t, dot, `"0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2" -- "0xdac17f958d2ee523a2206206994597c13d831ec7" [label="Uniswap V2 (fee 300)", color="red"];`)
+	assert.Contains(t, dot, `"0xdac17f958d2ee523a2206206994597c13d831ec7" -- "0x6b175474e89094c44da98b954eedeac495271d0f" [label="SushiSwap (fee 250)", color="blue"];`)
+	assert.Equal(t, 2, strings.Count(dot, " -- "))
+}
+
+func TestPathFinder_GraphStats(t *testing.T) {
+	pathFinder := newPathFinder(context.Background(), new(MockStore), NewPriceCalculator())
+	assert.Equal(t, GraphStats{}, pathFinder.GraphStats())
+
+	pool := func(address, token0, token1 string) *types.Pool {
+		return &types.Pool{
+			Address:  address,
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: token0},
+			Token1:   types.Token{Address: token1},
+			Reserve0: big.NewInt(1000),
+			Reserve1: big.NewInt(1000),
+		}
+	}
+
+	// A triangle with a doubled a-b pair, plus a separate d-e pair served by two pools
+	pathFinder.graph.Store(buildGraphParallel([]*types.Pool{
+		pool("ab1", "0xa", "0xb"),
+		pool("ab2", "0xa", "0xb"),
+		pool("bc", "0xb", "0xc"),
+		pool("ac", "0xa", "0xc"),
+		pool("de1", "0xd", "0xe"),
+		pool("de2", "0xe", "0xd"),
+	}, 1))
+
+	assert.Equal(t, GraphStats{
+		NodeCount:           5,
+		EdgeCount:           4,
+		PoolCount:           6,
+		AverageDegree:       1.6,
+		MaxDegree:           2,
+		HubToken:            "0xa",
+		ConnectedComponents: 2,
+	}, pathFinder.GraphStats())
+
+	// Joining the two groups makes 0xa the hub
+	pathFinder.graph.Store(buildGraphParallel([]*types.Pool{
+		pool("ab", "0xa", "0xb"),
+		pool("bc", "0xb", "0xc"),
+		pool("ac", "0xa", "0xc"),
+		pool("ad", "0xa", "0xd"),
+		pool("de1", "0xd", "0xe"),
+		pool("de2", "0xd", "0xe"),
+	}, 1))
+
+	stats := pathFinder.GraphStats()
+	assert.Equal(t, 5, stats.EdgeCount)
+	assert.Equal(t, 6, stats.PoolCount)
+	assert.Equal(t, 2.0, stats.AverageDegree)
+	assert.Equal(t, 3, stats.MaxDegree)
+	assert.Equal(t, "0xa", stats.HubToken)
+	assert.Equal(t, 1, stats.ConnectedComponents)
+}
+
+func TestArbitrageDetector_FindCycles(t *testing.T) {
+	perfConfig := config.PerformanceConfig{
+		MaxSlippage:        5.0,
+		MaxHops:            3,
+		MaxConcurrentPaths: 10,
+	}
+	mockStore := new(MockStore)
+
+	// A -> B -> C -> A is mispriced: 1 A buys 2 B, 2 B buy 4 C, 4 C buy 1.2 A
+	mockPools := []*types.Pool{
+		{
+			Address:  "pool-ab",
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xa"},
+			Token1:   types.Token{Address: "0xb"},
+			Reserve0: big.NewInt(1000000000),
+			Reserve1: big.NewInt(2000000000),
+		},
+		{
+			Address:  "pool-bc",
+			Exchange: "SushiSwap",
+			Token0:   types.Token{Address: "0xb"},
+			Token1:   types.Token{Address: "0xc"},
+			Reserve0: big.NewInt(1000000000),
+			Reserve1: big.NewInt(2000000000),
+		},
+		{
+			Address:  "pool-ca",
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xc"},
+			Token1:   types.Token{Address: "0xa"},
+			Reserve0: big.NewInt(4000000000),
+			Reserve1: big.NewInt(1200000000),
+		},
+		// Fairly priced isolated pair, no arbitrage
+		{
+			Address:  "pool-de",
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xd"},
+			Token1:   types.Token{Address: "0xe"},
+			Reserve0: big.NewInt(1000000000),
+			Reserve1: big.NewInt(1000000000),
+		},
+	}
+	mockStore.On("GetAllPools", mock.Anything).Return(mockPools, nil).Once()
+
+	router := NewRouter(context.Background(), mockStore, perfConfig)
+
+	cycles, err := router.FindArbitrage(context.Background(), "0xA", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(cycles))
+
+	cycle := cycles[0]
+	assert.Equal(t, 3, len(cycle.Pools))
+	assert.Equal(t, "0xa", cycle.Tokens[0])
+	assert.Equal(t, "0xa", cycle.Tokens[len(cycle.Tokens)-1])
+	// 1.2x gross, minus three 0.3% fees
+	assert.InDelta(t, 1892, cycle.EstimatedProfitBps, 2)
+	assert.True(t, cycle.GasCostWei.Cmp(big.NewInt(0)) > 0)
+
+	// A high threshold filters the cycle out
+	cycles, err = router.FindArbitrage(context.Background(), "0xa", 5000)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(cycles))
+
+	// No cycles through the fairly priced pair
+	cycles, err = router.FindArbitrage(context.Background(), "0xd", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(cycles))
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestArbitrageDetector_FindCycles_UsesPoolFees(t *testing.T) {
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+
+	// A -> B -> C -> A returns 1.005 A per A before fees: profitable through 1 bps
+	// pools, but not through 30 bps or 100 bps ones
+	cyclePools := func(feeBps int) []*types.Pool {
+		pool := func(address, token0, token1 string, reserve0, reserve1 int64) *types.Pool {
+			return &types.Pool{
+				Address:  address,
+				Exchange: "Uniswap V2",
+				Token0:   types.Token{Address: token0},
+				Token1:   types.Token{Address: token1},
+				Reserve0: big.NewInt(reserve0),
+				Reserve1: big.NewInt(reserve1),
+				LPFeeBps: feeBps,
+			}
+		}
+		return []*types.Pool{
+			pool("pool-ab", "0xa", "0xb", 1000000000, 1000000000),
+			pool("pool-bc", "0xb", "0xc", 1000000000, 1000000000),
+			pool("pool-ca", "0xc", "0xa", 1000000000, 1005000000),
+		}
+	}
+
+	for _, tc := range []struct {
+		feeBps    int
+		profitBps int
+	}{
+		{feeBps: 1, profitBps: 47},
+		{feeBps: 30},
+		{feeBps: 100},
+	} {
+		t.Run(fmt.Sprintf("%d bps", tc.feeBps), func(t *testing.T) {
+			mockStore := new(MockStore)
+			mockStore.On("GetAllPools", mock.Anything).Return(cyclePools(tc.feeBps), nil).Once()
+			router := NewRouter(context.Background(), mockStore, perfConfig)
+
+			cycles, err := router.FindArbitrage(context.Background(), "0xa", 1)
+			assert.NoError(t, err)
+			if tc.profitBps == 0 {
+				assert.Empty(t, cycles)
+				return
+			}
+			if assert.Len(t, cycles, 1) {
+				assert.InDelta(t, tc.profitBps, cycles[0].EstimatedProfitBps, 1)
+			}
+		})
+	}
+}
+
+func TestRouter_UpdateConfigOnReload(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(maxSlippage float64) {
+		data := fmt.Sprintf("performance:\n  max_slippage: %.1f\n  max_concurrent_paths: 4\n", maxSlippage)
+		assert.NoError(t, os.WriteFile(configPath, []byte(data), 0o644))
+	}
+
+	originalConfig := config.Current()
+	defer config.Set(originalConfig)
+
+	writeConfig(5.0)
+	assert.NoError(t, config.InitFromFile(configPath))
+
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := NewRouter(context.Background(), mockStore, config.Current().Performance)
+	assert.Equal(t, 5.0, router.calculator.MaxSlippage())
+
+	watcher, err := config.NewWatcher(configPath)
+	assert.NoError(t, err)
+	defer watcher.Close()
+	updates := watcher.Subscribe()
+
+	writeConfig(1.5)
+
+	// A single write may arrive as several events (truncate, then write), so
+	// keep applying reloads until the new value lands or the deadline passes
+	deadline := time.After(time.Second)
+	for router.calculator.MaxSlippage() != 1.5 {
+		select {
+		case <-updates:
+			router.UpdateConfig(config.Current().Performance)
+		case <-deadline:
+			t.Fatalf("timed out waiting for config reload, maxSlippage=%.2f", router.calculator.MaxSlippage())
+		}
+	}
+
+	assert.Equal(t, 4, router.maxConcurrent)
+}
+
+func TestRouter_GetBestQuote_ContextCancelled(t *testing.T) {
+	perfConfig := config.PerformanceConfig{MaxSlippage: 100.0, MaxHops: 3, MaxConcurrentPaths: 1}
+
+	// Many parallel pools between the same pair so there is plenty of work to abandon
+	var pools []*types.Pool
+	for i := 0; i < 50; i++ {
+		pools = append(pools, &types.Pool{
+			Address:  fmt.Sprintf("pool%d", i),
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xweth"},
+			Token1:   types.Token{Address: "0xusdt"},
+			Reserve0: big.NewInt(1000000000000000000),
+			Reserve1: big.NewInt(2000000000000),
+		})
+	}
+
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return(pools, nil).Once()
+	router := NewRouter(context.Background(), mockStore, perfConfig)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel the request once the quote pipeline is under way
+	mockStore.On("GetAllPools", mock.Anything).Run(func(args mock.Arguments) {
+		cancel()
+	}).Return(pools, nil).Once()
+
+	req := &types.QuoteRequest{
+		TokenIn:  "0xweth",
+		TokenOut: "0xusdt",
+		AmountIn: big.NewInt(1000000000000000),
+		MaxHops:  3,
+	}
+
+	response, err := router.GetBestQuote(ctx, req)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, response)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestRouter_GetBestQuote_PartialResult(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	const chainLength = 10
+	perfConfig := config.PerformanceConfig{
+		MaxSlippage:          5.0,
+		MaxHops:              3,
+		MaxConcurrentPaths:   10,
+		GraphRefreshInterval: time.Minute,
+		PathFindingTimeoutMs: 100,
+	}
+	router := NewRouter(context.Background(), testutil.NewMemStoreWithPools(longChainPools(chainLength)...), perfConfig)
+
+	// Hold the search after the direct path, its first, until the timeout ends it with
+	// the chain still queued
+	var find pathFinderFunc = func(pf *PathFinder, ctx context.Context, tokenIn, tokenOut string, amountIn *big.Int, maxHops, maxPaths int, search *pathSearch) ([][]*types.Pool, error) {
+		search.onPathFound = func() { <-ctx.Done() }
+		return pf.findBestPaths(ctx, tokenIn, tokenOut, amountIn, maxHops, maxPaths, search)
+	}
+	router.pathFinder.algorithm.Store(&find)
+
+	req := &types.QuoteRequest{TokenIn: "0xtokena", TokenOut: "0xtokenb", AmountIn: big.NewInt(1000000000000000), MaxHops: chainLength}
+	resp, err := router.GetBestQuote(context.Background(), req)
+	assert.NoError(t, err)
+	if assert.NotNil(t, resp) {
+		assert.True(t, resp.PartialResult)
+		assert.Equal(t, "direct", resp.BestPath.Pools[0].Address)
+	}
+
+	// Partial quotes are not cached, so a later search may do better
+	assert.Equal(t, 0, router.quotes.len())
+}
+
+func TestRouter_InFlightQuotes(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	perfConfig := config.PerformanceConfig{MaxSlippage: 100.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	pools := []*types.Pool{
+		{
+			Address:  "weth-usdt",
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xweth"},
+			Token1:   types.Token{Address: "0xusdt"},
+			Reserve0: big.NewInt(1000000000000000000),
+			Reserve1: big.NewInt(2000000000000),
+		},
+	}
+
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return(pools, nil).Once()
+	router := NewRouter(context.Background(), mockStore, perfConfig)
+	assert.Zero(t, router.InFlightQuotes())
+
+	// Hold every quote inside GetBestQuote until released
+	release := make(chan struct{})
+	mockStore.On("GetAllPools", mock.Anything).Run(func(args mock.Arguments) {
+		<-release
+	}).Return(pools, nil)
+
+	const quotes = 50
+	var wg sync.WaitGroup
+	for i := 0; i < quotes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := router.GetBestQuote(context.Background(), &types.QuoteRequest{
+				TokenIn:  "0xweth",
+				TokenOut: "0xusdt",
+				AmountIn: big.NewInt(1000000000000000),
+				MaxHops:  3,
+			})
+			assert.NoError(t, err)
+		}()
+	}
+
+	var peak int64
+	assert.Eventually(t, func() bool {
+		if n := router.InFlightQuotes(); n > peak {
+			peak = n
+		}
+		return peak == quotes
+	}, 5*time.Second, time.Millisecond)
+	assert.Greater(t, peak, int64(0))
+
+	close(release)
+	wg.Wait()
+	assert.Zero(t, router.InFlightQuotes())
+}
+
+func TestRouter_AverageQuoteLatencyMs(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	perfConfig := config.PerformanceConfig{MaxSlippage: 100.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	pools := []*types.Pool{
+		{
+			Address:  "weth-usdt",
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xweth"},
+			Token1:   types.Token{Address: "0xusdt"},
+			Reserve0: big.NewInt(1000000000000000000),
+			Reserve1: big.NewInt(2000000000000),
+		},
+	}
+
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return(pools, nil).Once()
+	router := NewRouter(context.Background(), mockStore, perfConfig)
+	assert.Zero(t, router.AverageQuoteLatencyMs())
+
+	// Every quote loads the pools, so each takes at least the delay
+	const delay = 50 * time.Millisecond
+	mockStore.On("GetAllPools", mock.Anything).Run(func(args mock.Arguments) {
+		time.Sleep(delay)
+	}).Return(pools, nil)
+
+	for i := 1; i <= 5; i++ {
+		// Distinct amounts, so no quote is served from the quote cache
+		_, err := router.GetBestQuote(context.Background(), &types.QuoteRequest{
+			TokenIn:  "0xweth",
+			TokenOut: "0xusdt",
+			AmountIn: big.NewInt(int64(i) * 1000000000000000),
+			MaxHops:  3,
+		})
+		assert.NoError(t, err)
+	}
+
+	expected := float64(delay / time.Millisecond)
+	assert.InEpsilon(t, expected, router.AverageQuoteLatencyMs(), 0.2)
+}
+
+func TestRouter_CalculatePathsConcurrently_GoroutinesExitOnCancel(t *testing.T) {
+	perfConfig := config.PerformanceConfig{MaxSlippage: 100.0, MaxHops: 3, MaxConcurrentPaths: 1}
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := NewRouter(context.Background(), mockStore, perfConfig)
+
+	pool := &types.Pool{
+		Address:  "pool1",
+		Token0:   types.Token{Address: "0xweth"},
+		Token1:   types.Token{Address: "0xusdt"},
+		Reserve0: big.NewInt(1000000000000000000),
+		Reserve1: big.NewInt(2000000000000),
+	}
+	paths := make([][]*types.Pool, 200)
+	for i := range paths {
+		paths[i] = []*types.Pool{pool}
+	}
+
+	baseline := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := &types.QuoteRequest{TokenIn: "0xweth", TokenOut: "0xusdt", AmountIn: big.NewInt(1000000000000000)}
+	tradePaths := router.calculatePathsConcurrently(ctx, paths, req, "0xweth", "0xusdt")
+	assert.Empty(t, tradePaths)
+
+	// Poll by hand: assert.Eventually runs its condition on an extra goroutine
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), baseline, "path calculation goroutines did not exit")
+}
+
+func TestSplitAmount(t *testing.T) {
+	targets := func(fractions ...uint) []types.MultiQuoteTarget {
+		out := make([]types.MultiQuoteTarget, len(fractions))
+		for i, fraction := range fractions {
+			out[i] = types.MultiQuoteTarget{TokenOut: fmt.Sprintf("0xtoken%d", i), Fraction: fraction}
+		}
+		return out
+	}
+
+	testCases := []struct {
+		name     string
+		amount   int64
+		targets  []types.MultiQuoteTarget
+		expected []string
+		errorMsg string
+	}{
+		{"Even split", 1000, targets(6000, 4000), []string{"600", "400"}, ""},
+		{"Remainder goes to the last target", 100, targets(3333, 3333, 3334), []string{"33", "33", "34"}, ""},
+		{"Single target", 7, targets(10000), []string{"7"}, ""},
+		{"Fractions too small", 1000, targets(5000, 4000), nil, "sum to 9000"},
+		{"Fractions too large", 1000, targets(6000, 6000), nil, "sum to 12000"},
+		{"Target without input", 1, targets(5000, 5000), nil, "receives no input"},
+		{"No targets", 1000, nil, nil, "at least one target"},
+		{"Zero amount", 0, targets(10000), nil, "must be positive"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			amounts, err := splitAmount(big.NewInt(tc.amount), tc.targets)
+			if tc.errorMsg != "" {
+				assert.ErrorContains(t, err, tc.errorMsg)
+				return
+			}
+			assert.NoError(t, err)
+
+			var got []string
+			for _, amount := range amounts {
+				got = append(got, amount.String())
+			}
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
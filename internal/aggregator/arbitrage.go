@@ -0,0 +1,213 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+
+	"dex-aggregator/internal/types"
+)
+
+const (
+	// maxCycleLength bounds the number of pools in an arbitrage cycle
+	maxCycleLength = 4
+	// defaultGasPriceGwei is used to convert gas units into wei for cycle costs
+	defaultGasPriceGwei = 30
+)
+
+// ArbitrageCycle is a sequence of pools starting and ending at the same token
+type ArbitrageCycle struct {
+	Pools              []*types.Pool `json:"pools"`
+	Tokens             []string      `json:"tokens"`
+	EstimatedProfitBps int           `json:"estimatedProfitBps"`
+	GasCostWei         *big.Int      `json:"gasCostWei"`
+}
+
+// MarshalJSON custom marshaler for ArbitrageCycle to handle big.Int
+func (c *ArbitrageCycle) MarshalJSON() ([]byte, error) {
+	type Alias ArbitrageCycle
+	return json.Marshal(&struct {
+		GasCostWei string `json:"gasCostWei"`
+		*Alias
+	}{
+		GasCostWei: c.GasCostWei.String(),
+		Alias:      (*Alias)(c),
+	})
+}
+
+// ArbitrageDetector finds profitable cycles in the path finder graph
+type ArbitrageDetector struct {
+	pathFinder  *PathFinder
+	estimateGas func(path []*types.Pool) *big.Int
+	gasPriceWei *big.Int
+}
+
+func NewArbitrageDetector(pathFinder *PathFinder, estimateGas func(path []*types.Pool) *big.Int) *ArbitrageDetector {
+	return &ArbitrageDetector{
+		pathFinder:  pathFinder,
+		estimateGas: estimateGas,
+		gasPriceWei: new(big.Int).Mul(big.NewInt(defaultGasPriceGwei), big.NewInt(1e9)),
+	}
+}
+
+// cycleState is the best path found to a token at a given hop count
+type cycleState struct {
+	weight float64 // sum of -log(rate) along the path
+	path   []*types.Pool
+	tokens []string
+}
+
+// FindCycles finds cycles through startToken whose spot-rate product exceeds 1 by at
+// least minProfitBps after fees. It runs a hop-bounded Bellman-Ford on negative log
+// exchange rates, so a negative cycle weight is a profitable cycle.
+func (ad *ArbitrageDetector) FindCycles(ctx context.Context, startToken string, minProfitBps int) ([]ArbitrageCycle, error) {
+	g := ad.pathFinder.graph.Load()
+	if g == nil {
+		return nil, fmt.Errorf("graph not initialized")
+	}
+
+	start := strings.ToLower(startToken)
+	if !g.hasToken(start) {
+		return []ArbitrageCycle{}, nil
+	}
+
+	// level[k][token] holds the lowest-weight simple path of k hops from start to token
+	level := map[string]*cycleState{
+		start: {weight: 0, tokens: []string{start}},
+	}
+
+	var cycles []ArbitrageCycle
+	seen := make(map[string]bool)
+
+	for hops := 1; hops <= maxCycleLength; hops++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		next := make(map[string]*cycleState)
+		for token, state := range level {
+			for _, neighbour := range g.adj[token] {
+				for _, edge := range g.edgesBetween(token, neighbour) {
+					pool := edge.pool
+					edgeWeight, ok := logRateWeight(pool, token)
+					if !ok || containsPool(state.path, pool) {
+						continue
+					}
+					weight := state.weight + edgeWeight
+
+					if neighbour == start {
+						if hops < 2 {
+							continue
+						}
+						ad.recordCycle(&cycles, seen, state, pool, weight, minProfitBps)
+						continue
+					}
+
+					if containsToken(state.tokens, neighbour) {
+						continue
+					}
+
+					// Bellman-Ford relaxation, restricted to paths of exactly this length
+					if existing, ok := next[neighbour]; !ok || weight < existing.weight {
+						path := make([]*types.Pool, len(state.path)+1)
+						copy(path, state.path)
+						path[len(path)-1] = pool
+
+						tokens := make([]string, len(state.tokens)+1)
+						copy(tokens, state.tokens)
+						tokens[len(tokens)-1] = neighbour
+
+						next[neighbour] = &cycleState{weight: weight, path: path, tokens: tokens}
+					}
+				}
+			}
+		}
+		level = next
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i].EstimatedProfitBps > cycles[j].EstimatedProfitBps
+	})
+
+	log.Printf("ArbitrageDetector: Found %d cycles from %s (min profit %d bps)", len(cycles), start, minProfitBps)
+	return cycles, nil
+}
+
+func (ad *ArbitrageDetector) recordCycle(cycles *[]ArbitrageCycle, seen map[string]bool, state *cycleState, closing *types.Pool, weight float64, minProfitBps int) {
+	if weight >= 0 {
+		return
+	}
+
+	profitBps := int((math.Exp(-weight) - 1) * 10000)
+	if profitBps < minProfitBps {
+		return
+	}
+
+	pools := make([]*types.Pool, len(state.path)+1)
+	copy(pools, state.path)
+	pools[len(pools)-1] = closing
+
+	addresses := make([]string, len(pools))
+	for i, pool := range pools {
+		addresses[i] = pool.Address
+	}
+	key := strings.Join(addresses, ",")
+	if seen[key] {
+		return
+	}
+	seen[key] = true
+
+	tokens := make([]string, len(state.tokens)+1)
+	copy(tokens, state.tokens)
+	tokens[len(tokens)-1] = state.tokens[0]
+
+	gasCost := ad.estimateGas(pools)
+	*cycles = append(*cycles, ArbitrageCycle{
+		Pools:              pools,
+		Tokens:             tokens,
+		EstimatedProfitBps: profitBps,
+		GasCostWei:         new(big.Int).Mul(gasCost, ad.gasPriceWei),
+	})
+}
+
+// logRateWeight returns -log of the spot rate for swapping tokenIn through pool less the
+// pool's own fee. Since a swap never returns more than that, it bounds the output from above.
+func logRateWeight(pool *types.Pool, tokenIn string) (float64, bool) {
+	reserveIn, reserveOut := pool.Reserve0, pool.Reserve1
+	if strings.ToLower(pool.Token1.Address) == tokenIn {
+		reserveIn, reserveOut = pool.Reserve1, pool.Reserve0
+	}
+	if reserveIn == nil || reserveOut == nil || reserveIn.Sign() <= 0 || reserveOut.Sign() <= 0 {
+		return 0, false
+	}
+
+	rate, _ := new(big.Float).Quo(new(big.Float).SetInt(reserveOut), new(big.Float).SetInt(reserveIn)).Float64()
+	rate *= float64(10000-pool.TotalFeeBps()) / 10000
+	if rate <= 0 || math.IsInf(rate, 0) {
+		return 0, false
+	}
+	return -math.Log(rate), true
+}
+
+func containsPool(path []*types.Pool, pool *types.Pool) bool {
+	for _, p := range path {
+		if p == pool {
+			return true
+		}
+	}
+	return false
+}
+
+func containsToken(tokens []string, token string) bool {
+	for _, t := range tokens {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
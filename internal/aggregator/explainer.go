@@ -0,0 +1,170 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"dex-aggregator/internal/types"
+)
+
+// ExplainQuote computes a quote for req and describes its routing decision in plain language
+func (r *Router) ExplainQuote(ctx context.Context, req *types.QuoteRequest) (*types.QuoteExplanation, error) {
+	resp, err := r.GetBestQuote(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return r.explain(ctx, req, resp)
+}
+
+// explain builds the explanation of resp, the quote answering req
+func (r *Router) explain(ctx context.Context, req *types.QuoteRequest, resp *types.QuoteResponse) (*types.QuoteExplanation, error) {
+	best := resp.BestPath
+	hops, err := r.explainHops(ctx, best, req.AmountIn, strings.ToLower(req.TokenIn))
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := make([]string, 0, len(hops)+1)
+	symbols = append(symbols, hops[0].TokenIn)
+	for _, hop := range hops {
+		symbols = append(symbols, hop.TokenOut)
+	}
+
+	summary := fmt.Sprintf("Best path: %s via %s (%d %s). %d alternative %s evaluated.",
+		strings.Join(symbols, " → "), strings.Join(uniqueDexes(best.Dexes), ", "),
+		len(hops), pluralize(len(hops), "hop", "hops"), len(resp.Paths)-1, pluralize(len(resp.Paths)-1, "path", "paths"))
+
+	return &types.QuoteExplanation{
+		Summary:     summary,
+		PathDetails: hops,
+		WhyBestPath: explainChoice(best, resp.Paths),
+		PriceImpact: explainPriceImpact(best.PriceImpact),
+	}, nil
+}
+
+// explainHops replays path hop by hop from tokenIn to report each pool's output
+func (r *Router) explainHops(ctx context.Context, path *types.TradePath, amountIn *big.Int, tokenIn string) ([]*types.HopExplanation, error) {
+	hops := make([]*types.HopExplanation, 0, len(path.Pools))
+	amount := amountIn
+	if path.AmountIn != nil {
+		amount = path.AmountIn
+	}
+
+	for _, pool := range path.Pools {
+		in, out := pool.Token0, pool.Token1
+		if strings.ToLower(pool.Token0.Address) != tokenIn {
+			in, out = pool.Token1, pool.Token0
+		}
+
+		amountOut, err := r.calculator.CalculateOutput(ctx, pool, amount, tokenIn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay hop through %s: %v", pool.Address, err)
+		}
+
+		hops = append(hops, &types.HopExplanation{
+			Pool:      pool.Address,
+			Exchange:  pool.Exchange,
+			TokenIn:   tokenLabel(in),
+			TokenOut:  tokenLabel(out),
+			Fee:       pool.Fee,
+			AmountIn:  amount.String(),
+			AmountOut: amountOut.String(),
+		})
+		amount = amountOut
+		tokenIn = strings.ToLower(out.Address)
+	}
+	return hops, nil
+}
+
+// explainChoice compares best against the runner-up among paths
+func explainChoice(best *types.TradePath, paths []*types.TradePath) string {
+	var next *types.TradePath
+	for _, path := range paths {
+		if path == best {
+			continue
+		}
+		if next == nil || path.AmountOut.Cmp(next.AmountOut) > 0 {
+			next = path
+		}
+	}
+	if next == nil {
+		return "Chosen because it is the only path with a positive output."
+	}
+
+	outputDiff := percentDiff(best.AmountOut, next.AmountOut)
+	var reason string
+	switch {
+	case outputDiff > 0:
+		reason = fmt.Sprintf("Chosen because it yields %.2f%% more output than the next-best path", outputDiff)
+	case outputDiff < 0:
+		reason = fmt.Sprintf("Chosen despite yielding %.2f%% less output than the next-best path, as its lower costs leave more after gas", -outputDiff)
+	default:
+		reason = "Chosen because it yields the same output as the next-best path"
+	}
+
+	if best.GasCost != nil && next.GasCost != nil && next.GasCost.Sign() > 0 {
+		switch gasDiff := percentDiff(best.GasCost, next.GasCost); {
+		case gasDiff < 0:
+			reason += fmt.Sprintf(" and uses %.0f%% less gas", -gasDiff)
+		case gasDiff > 0:
+			reason += fmt.Sprintf(" while using %.0f%% more gas", gasDiff)
+		default:
+			reason += " at the same gas cost"
+		}
+	}
+	return reason + "."
+}
+
+// explainPriceImpact describes a price impact percentage
+func explainPriceImpact(impact float64) string {
+	switch {
+	case impact < 0.1:
+		return fmt.Sprintf("Price impact is negligible (%.2f%%): the trade barely moves the pool prices.", impact)
+	case impact < 1:
+		return fmt.Sprintf("Price impact is low (%.2f%%): the pools are deep relative to the trade size.", impact)
+	case impact < 5:
+		return fmt.Sprintf("Price impact is moderate (%.2f%%): consider splitting the trade or trading a smaller amount.", impact)
+	default:
+		return fmt.Sprintf("Price impact is high (%.2f%%): the trade is large relative to the available liquidity.", impact)
+	}
+}
+
+// percentDiff returns how much larger a is than b, as a percentage of b
+func percentDiff(a, b *big.Int) float64 {
+	if b.Sign() == 0 {
+		return 0
+	}
+	diff := new(big.Float).SetInt(new(big.Int).Sub(a, b))
+	pct, _ := diff.Quo(diff, new(big.Float).SetInt(b)).Float64()
+	return pct * 100
+}
+
+// tokenLabel names a token by symbol, falling back to its address
+func tokenLabel(token types.Token) string {
+	if token.Symbol != "" && token.Symbol != "UNKNOWN" {
+		return token.Symbol
+	}
+	return token.Address
+}
+
+// uniqueDexes lists dexes in path order without repeats
+func uniqueDexes(dexes []string) []string {
+	seen := make(map[string]bool, len(dexes))
+	unique := make([]string, 0, len(dexes))
+	for _, dex := range dexes {
+		if !seen[dex] {
+			seen[dex] = true
+			unique = append(unique, dex)
+		}
+	}
+	return unique
+}
+
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
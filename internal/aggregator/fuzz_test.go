@@ -0,0 +1,112 @@
+package aggregator
+
+import (
+	"context"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"testing"
+
+	"dex-aggregator/internal/types"
+)
+
+// Fuzz targets for the AMM arithmetic. Run each target individually, e.g.:
+//
+//	go test ./internal/aggregator -run=^$ -fuzz=^FuzzCalculateOutput$ -fuzztime=60s
+//	go test ./internal/aggregator -run=^$ -fuzz=^FuzzCalculatePathOutput$ -fuzztime=60s
+
+func FuzzCalculateOutput(f *testing.F) {
+	// Small reserves, large input
+	f.Add(big.NewInt(1000000000).Bytes(), big.NewInt(1000).Bytes(), big.NewInt(2000).Bytes())
+	// Equal reserves
+	f.Add(big.NewInt(1000).Bytes(), big.NewInt(1000000000).Bytes(), big.NewInt(1000000000).Bytes())
+	// Zero reserves
+	f.Add(big.NewInt(1000).Bytes(), []byte{}, []byte{})
+	// Zero input
+	f.Add([]byte{}, big.NewInt(1000000).Bytes(), big.NewInt(2000000).Bytes())
+
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	calculator := NewPriceCalculator()
+	calculator.SetMaxSlippage(100.0)
+
+	f.Fuzz(func(t *testing.T, amountInBytes, reserveInBytes, reserveOutBytes []byte) {
+		amountIn := new(big.Int).SetBytes(amountInBytes)
+		reserveIn := new(big.Int).SetBytes(reserveInBytes)
+		reserveOut := new(big.Int).SetBytes(reserveOutBytes)
+
+		pool := &types.Pool{
+			Address:  "fuzz-pool",
+			Token0:   types.Token{Address: "0xtokena"},
+			Token1:   types.Token{Address: "0xtokenb"},
+			Reserve0: reserveIn,
+			Reserve1: reserveOut,
+		}
+
+		amountOut, err := calculator.CalculateOutput(context.Background(), pool, amountIn, "0xtokena")
+		if err != nil {
+			return
+		}
+
+		if amountOut.Sign() < 0 {
+			t.Fatalf("negative output %s for amountIn=%s reserves=%s/%s", amountOut, amountIn, reserveIn, reserveOut)
+		}
+		if amountOut.Cmp(reserveOut) > 0 {
+			t.Fatalf("output %s exceeds reserveOut %s", amountOut, reserveOut)
+		}
+		if reserveIn.Sign() == 0 && reserveOut.Sign() == 0 && amountOut.Sign() != 0 {
+			t.Fatalf("non-zero output %s from empty pool", amountOut)
+		}
+	})
+}
+
+func FuzzCalculatePathOutput(f *testing.F) {
+	f.Add(big.NewInt(1000).Bytes(),
+		big.NewInt(1000000000).Bytes(), big.NewInt(2000000000).Bytes(),
+		big.NewInt(1500000000).Bytes(), big.NewInt(3000000000).Bytes())
+	f.Add(big.NewInt(1000000000).Bytes(),
+		big.NewInt(1000).Bytes(), big.NewInt(2000).Bytes(),
+		big.NewInt(1000).Bytes(), big.NewInt(1000).Bytes())
+	f.Add(big.NewInt(1000).Bytes(), []byte{}, []byte{}, []byte{}, []byte{})
+
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	calculator := NewPriceCalculator()
+	calculator.SetMaxSlippage(100.0)
+
+	f.Fuzz(func(t *testing.T, amountInBytes, reserveA, reserveB1, reserveB2, reserveC []byte) {
+		amountIn := new(big.Int).SetBytes(amountInBytes)
+
+		pools := []*types.Pool{
+			{
+				Address:  "fuzz-pool-1",
+				Token0:   types.Token{Address: "0xtokena"},
+				Token1:   types.Token{Address: "0xtokenb"},
+				Reserve0: new(big.Int).SetBytes(reserveA),
+				Reserve1: new(big.Int).SetBytes(reserveB1),
+			},
+			{
+				Address:  "fuzz-pool-2",
+				Token0:   types.Token{Address: "0xtokenb"},
+				Token1:   types.Token{Address: "0xtokenc"},
+				Reserve0: new(big.Int).SetBytes(reserveB2),
+				Reserve1: new(big.Int).SetBytes(reserveC),
+			},
+		}
+
+		amountOut, err := calculator.CalculatePathOutput(context.Background(), pools, amountIn, "0xtokena", "0xtokenc")
+		if err != nil {
+			return
+		}
+
+		if amountOut.Sign() < 0 {
+			t.Fatalf("negative path output %s", amountOut)
+		}
+		if amountOut.Cmp(pools[1].Reserve1) > 0 {
+			t.Fatalf("path output %s exceeds final reserveOut %s", amountOut, pools[1].Reserve1)
+		}
+	})
+}
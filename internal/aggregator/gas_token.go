@@ -0,0 +1,48 @@
+package aggregator
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	applog "dex-aggregator/internal/log"
+	"dex-aggregator/internal/types"
+)
+
+// WETHAddress is the Ethereum mainnet WETH contract, which prices gas paid in ETH
+const WETHAddress = "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"
+
+// gasTokenMaxHops bounds the path used to price the gas token against WETH
+const gasTokenMaxHops = 3
+
+// gasCostInGasToken converts gasEstimate, at the request's gas price or
+// defaultGasPriceGwei, from wei into base units of req.GasToken at the spot prices
+// along the best path from WETH. It returns nil when no path prices the gas token.
+func (r *Router) gasCostInGasToken(ctx context.Context, req *types.QuoteRequest, gasEstimate *big.Int) *big.Int {
+	gasPriceGwei := req.GasPriceGwei
+	if gasPriceGwei == 0 {
+		gasPriceGwei = defaultGasPriceGwei
+	}
+	gasCostWei := new(big.Int).Mul(gasEstimate, new(big.Int).SetUint64(gasPriceGwei))
+	gasCostWei.Mul(gasCostWei, big.NewInt(1e9))
+
+	gasToken := strings.ToLower(req.GasToken)
+	if gasToken == WETHAddress {
+		return gasCostWei
+	}
+
+	logger := applog.FromContext(ctx)
+	paths, err := r.pathFinder.findBestPaths(ctx, WETHAddress, gasToken, gasCostWei, gasTokenMaxHops, 1, &pathSearch{})
+	if err != nil || len(paths) == 0 {
+		logger.Warn("No price path for gas token, omitting gas cost in gas token", "gasToken", gasToken, "error", err)
+		return nil
+	}
+
+	spotOut, err := spotPathOutput(paths[0], gasCostWei, WETHAddress)
+	if err != nil {
+		logger.Warn("Failed to price gas token, omitting gas cost in gas token", "gasToken", gasToken, "error", err)
+		return nil
+	}
+	gasCost, _ := spotOut.Int(nil)
+	return gasCost
+}
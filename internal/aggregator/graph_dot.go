@@ -0,0 +1,82 @@
+package aggregator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"dex-aggregator/internal/types"
+)
+
+// dotExchangeColors are assigned to exchanges in name order, repeating when exhausted
+var dotExchangeColors = []string{"blue", "red", "darkgreen", "orange", "purple", "brown", "magenta", "cyan4"}
+
+// ExportGraphDOT renders the current graph in Graphviz DOT format for debugging.
+// Tokens are nodes and every pool is an edge labelled with its exchange and fee,
+// coloured per exchange. An uninitialized graph renders as an empty graph.
+func (pf *PathFinder) ExportGraphDOT() string {
+	var b strings.Builder
+	b.WriteString("graph dex {\n")
+	b.WriteString("  node [shape=ellipse, fontname=\"monospace\"];\n")
+
+	g := pf.graph.Load()
+	if g == nil {
+		b.WriteString("}\n")
+		return b.String()
+	}
+
+	tokens := make([]string, 0, len(g.adj))
+	for token := range g.adj {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	// Each pool has an edge in both directions; keep one
+	var pools []*types.Pool
+	seen := make(map[*types.Pool]bool)
+	for i := range g.edges {
+		pool := g.edges[i].pool
+		if !seen[pool] {
+			seen[pool] = true
+			pools = append(pools, pool)
+		}
+	}
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i].Address < pools[j].Address
+	})
+
+	var exchanges []string
+	colors := make(map[string]string)
+	for _, pool := range pools {
+		if _, ok := colors[pool.Exchange]; !ok {
+			colors[pool.Exchange] = ""
+			exchanges = append(exchanges, pool.Exchange)
+		}
+	}
+	sort.Strings(exchanges)
+	for i, exchange := range exchanges {
+		colors[exchange] = dotExchangeColors[i%len(dotExchangeColors)]
+	}
+
+	for _, token := range tokens {
+		fmt.Fprintf(&b, "  %q [label=%q];\n", token, shortAddress(token))
+	}
+	for _, pool := range pools {
+		fmt.Fprintf(&b, "  %q -- %q [label=%q, color=%q];\n",
+			strings.ToLower(pool.Token0.Address),
+			strings.ToLower(pool.Token1.Address),
+			fmt.Sprintf("%s (fee %d)", pool.Exchange, pool.Fee),
+			colors[pool.Exchange])
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// shortAddress truncates an address to its first 6 and last 4 characters
+func shortAddress(address string) string {
+	if len(address) <= 10 {
+		return address
+	}
+	return address[:6] + "..." + address[len(address)-4:]
+}
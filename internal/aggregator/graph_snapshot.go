@@ -0,0 +1,154 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"sort"
+	"time"
+
+	"dex-aggregator/internal/types"
+)
+
+// ErrStaleSnapshot is returned by LoadGraph for a snapshot saved longer ago than allowed
+var ErrStaleSnapshot = errors.New("graph snapshot is stale")
+
+// graphSnapshot is the gob-encoded form of the routing graph: its pools, with the
+// reserves they had when it was saved. The adjacency is rebuilt from the pools.
+type graphSnapshot struct {
+	SavedAt time.Time
+	Pools   []snapshotPool
+}
+
+// snapshotPool holds the pool fields needed to rebuild graph edges and quote through them
+type snapshotPool struct {
+	Address          string
+	ChainID          int64
+	Exchange         string
+	Version          string
+	Token0           types.Token
+	Token1           types.Token
+	Reserve0         *big.Int
+	Reserve1         *big.Int
+	Fee              int
+	FeeTier          string
+	LPFeeBps         int
+	ProtocolFeeBps   int
+	Paused           bool
+	LastUpdated      time.Time
+	ReserveUpdatedAt time.Time
+}
+
+// SaveGraph writes the current graph topology to w as gob-encoded bytes
+func (pf *PathFinder) SaveGraph(ctx context.Context, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	g := pf.graph.Load()
+	if g == nil {
+		return fmt.Errorf("graph not initialized")
+	}
+
+	snapshot := graphSnapshot{SavedAt: time.Now()}
+
+	seen := make(map[*types.Pool]bool)
+	for token, neighbours := range g.adj {
+		for _, neighbour := range neighbours {
+			for _, edge := range g.edgesBetween(token, neighbour) {
+				pool := edge.pool
+				if seen[pool] {
+					continue
+				}
+				seen[pool] = true
+				snapshot.Pools = append(snapshot.Pools, snapshotPool{
+					Address:          pool.Address,
+					ChainID:          pool.ChainID,
+					Exchange:         pool.Exchange,
+					Version:          pool.Version,
+					Token0:           pool.Token0,
+					Token1:           pool.Token1,
+					Reserve0:         pool.Reserve0,
+					Reserve1:         pool.Reserve1,
+					Fee:              pool.Fee,
+					FeeTier:          pool.FeeTier,
+					LPFeeBps:         pool.LPFeeBps,
+					ProtocolFeeBps:   pool.ProtocolFeeBps,
+					Paused:           pool.Paused,
+					LastUpdated:      pool.LastUpdated,
+					ReserveUpdatedAt: pool.ReserveUpdatedAt,
+				})
+			}
+		}
+	}
+
+	// Keep output stable so identical graphs produce identical snapshots
+	sort.Slice(snapshot.Pools, func(i, j int) bool {
+		return snapshot.Pools[i].Address < snapshot.Pools[j].Address
+	})
+
+	if err := gob.NewEncoder(w).Encode(&snapshot); err != nil {
+		return fmt.Errorf("failed to encode graph snapshot: %v", err)
+	}
+
+	log.Printf("PathFinder: Saved graph snapshot with %d tokens and %d pools", len(g.adj), len(snapshot.Pools))
+	return nil
+}
+
+// LoadGraph restores a graph written by SaveGraph and swaps it in atomically, with the
+// reserves its pools had when it was saved. A snapshot saved more than maxAge ago is
+// rejected with ErrStaleSnapshot, since quoting from its reserves would mislead; a
+// maxAge of 0 accepts any age.
+func (pf *PathFinder) LoadGraph(ctx context.Context, r io.Reader, maxAge time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var snapshot graphSnapshot
+	if err := gob.NewDecoder(r).Decode(&snapshot); err != nil {
+		return fmt.Errorf("failed to decode graph snapshot: %v", err)
+	}
+	if age := time.Since(snapshot.SavedAt); maxAge > 0 && age > maxAge {
+		return fmt.Errorf("%w: saved %v ago, more than %v", ErrStaleSnapshot, age.Round(time.Second), maxAge)
+	}
+
+	pools := make([]*types.Pool, len(snapshot.Pools))
+	for i, sp := range snapshot.Pools {
+		pools[i] = &types.Pool{
+			Address:          sp.Address,
+			ChainID:          sp.ChainID,
+			Exchange:         sp.Exchange,
+			Version:          sp.Version,
+			Token0:           sp.Token0,
+			Token1:           sp.Token1,
+			Reserve0:         reserveOrZero(sp.Reserve0),
+			Reserve1:         reserveOrZero(sp.Reserve1),
+			Fee:              sp.Fee,
+			FeeTier:          sp.FeeTier,
+			LPFeeBps:         sp.LPFeeBps,
+			ProtocolFeeBps:   sp.ProtocolFeeBps,
+			Paused:           sp.Paused,
+			LastUpdated:      sp.LastUpdated,
+			ReserveUpdatedAt: sp.ReserveUpdatedAt,
+		}
+	}
+
+	g := pf.buildGraph(pools)
+	pf.graph.Store(g)
+
+	log.Printf("PathFinder: Loaded graph snapshot from %v ago with %d tokens and %d pools",
+		time.Since(snapshot.SavedAt).Round(time.Second), len(g.adj), len(pools))
+	return nil
+}
+
+// reserveOrZero returns reserve, or zero for a pool saved without one
+func reserveOrZero(reserve *big.Int) *big.Int {
+	if reserve == nil {
+		return big.NewInt(0)
+	}
+	return reserve
+}
@@ -0,0 +1,84 @@
+package aggregator
+
+import (
+	"sort"
+
+	"dex-aggregator/internal/types"
+)
+
+// GraphStats describes the topology of the routing graph
+type GraphStats struct {
+	NodeCount           int     `json:"nodeCount"`     // Unique tokens
+	EdgeCount           int     `json:"edgeCount"`     // Unique token pairs
+	PoolCount           int     `json:"poolCount"`     // Pools, several of which may share a pair
+	AverageDegree       float64 `json:"averageDegree"` // Mean neighbours per token
+	MaxDegree           int     `json:"maxDegree"`
+	HubToken            string  `json:"hubToken,omitempty"` // Token with MaxDegree neighbours
+	ConnectedComponents int     `json:"connectedComponents"`
+}
+
+// GraphStats computes topology metrics of the current graph snapshot. An
+// uninitialized graph has zero stats.
+func (pf *PathFinder) GraphStats() GraphStats {
+	var stats GraphStats
+	g := pf.graph.Load()
+	if g == nil || len(g.adj) == 0 {
+		return stats
+	}
+
+	tokens := make([]string, 0, len(g.adj))
+	for token := range g.adj {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	degrees := 0
+	for _, token := range tokens {
+		degree := len(g.adj[token])
+		degrees += degree
+		// Tokens are visited in order, so ties go to the smallest address
+		if degree > stats.MaxDegree {
+			stats.MaxDegree = degree
+			stats.HubToken = token
+		}
+	}
+
+	// Each pool has an edge in both directions
+	pools := make(map[*types.Pool]bool)
+	for i := range g.edges {
+		pools[g.edges[i].pool] = true
+	}
+
+	stats.NodeCount = len(tokens)
+	stats.EdgeCount = degrees / 2
+	stats.PoolCount = len(pools)
+	stats.AverageDegree = float64(degrees) / float64(len(tokens))
+	stats.ConnectedComponents = connectedComponents(g, tokens)
+	return stats
+}
+
+// connectedComponents counts the groups of tokens reachable from one another
+func connectedComponents(g *graphData, tokens []string) int {
+	visited := make(map[string]bool, len(tokens))
+	components := 0
+	for _, start := range tokens {
+		if visited[start] {
+			continue
+		}
+		components++
+
+		visited[start] = true
+		queue := []string{start}
+		for len(queue) > 0 {
+			token := queue[0]
+			queue = queue[1:]
+			for _, neighbour := range g.adj[token] {
+				if !visited[neighbour] {
+					visited[neighbour] = true
+					queue = append(queue, neighbour)
+				}
+			}
+		}
+	}
+	return components
+}
@@ -0,0 +1,85 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"dex-aggregator/internal/types"
+)
+
+// GetMultiQuote splits req.AmountIn across the targets by their fractions and quotes every
+// target concurrently. Any rounding remainder goes to the last target so the legs spend
+// exactly AmountIn. It fails if any leg cannot be quoted.
+func (r *Router) GetMultiQuote(ctx context.Context, req *types.MultiQuoteRequest) (*types.MultiQuoteResponse, error) {
+	amounts, err := splitAmount(req.AmountIn, req.Targets)
+	if err != nil {
+		return nil, err
+	}
+
+	legs := make([]*types.QuoteResponse, len(req.Targets))
+	errs := make([]error, len(req.Targets))
+	var wg sync.WaitGroup
+	for i, target := range req.Targets {
+		wg.Add(1)
+		go func(i int, target types.MultiQuoteTarget) {
+			defer wg.Done()
+			legs[i], errs[i] = r.GetBestQuote(ctx, &types.QuoteRequest{
+				TokenIn:  req.TokenIn,
+				TokenOut: target.TokenOut,
+				AmountIn: amounts[i],
+				MaxHops:  req.MaxHops,
+			})
+		}(i, target)
+	}
+	wg.Wait()
+
+	totalGas := big.NewInt(0)
+	for i, leg := range legs {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("failed to quote %s: %w", req.Targets[i].TokenOut, errs[i])
+		}
+		totalGas.Add(totalGas, leg.GasEstimate)
+	}
+
+	return &types.MultiQuoteResponse{
+		Legs:             legs,
+		TotalGasEstimate: totalGas,
+	}, nil
+}
+
+// splitAmount divides amount by the targets' basis point fractions, which must sum to
+// types.MultiQuoteFractionTotal and give every target a positive amount
+func splitAmount(amount *big.Int, targets []types.MultiQuoteTarget) ([]*big.Int, error) {
+	if amount == nil || amount.Sign() <= 0 {
+		return nil, fmt.Errorf("amountIn must be positive")
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("at least one target is required")
+	}
+
+	var total uint
+	for _, target := range targets {
+		total += target.Fraction
+	}
+	if total != types.MultiQuoteFractionTotal {
+		return nil, fmt.Errorf("target fractions sum to %d, not %d", total, types.MultiQuoteFractionTotal)
+	}
+
+	amounts := make([]*big.Int, len(targets))
+	remaining := new(big.Int).Set(amount)
+	for i, target := range targets {
+		if i == len(targets)-1 {
+			amounts[i] = remaining
+		} else {
+			share := new(big.Int).Mul(amount, new(big.Int).SetUint64(uint64(target.Fraction)))
+			amounts[i] = share.Div(share, big.NewInt(types.MultiQuoteFractionTotal))
+			remaining.Sub(remaining, amounts[i])
+		}
+		if amounts[i].Sign() <= 0 {
+			return nil, fmt.Errorf("target %s receives no input", target.TokenOut)
+		}
+	}
+	return amounts, nil
+}
@@ -0,0 +1,423 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+
+	applog "dex-aggregator/internal/log"
+	"dex-aggregator/internal/types"
+)
+
+// Path search algorithms, selected by PerformanceConfig.PathAlgorithm
+const (
+	PathAlgorithmDijkstra = "dijkstra" // Best-first search, keeping the best path to each token
+	PathAlgorithmYen      = "yen"      // Yen's k shortest paths: each path is a detour from a previous one
+	PathAlgorithmAStar    = "astar"    // Dijkstra guided towards tokenOut, see FindBestPathsAStar
+	PathAlgorithmBidir    = "bidir"    // Meet in the middle: half the hops from each end
+)
+
+// pathFinderFunc is a path search algorithm. Every algorithm takes FindBestPaths'
+// arguments, plus the pathSearch options such as the exchange to route through.
+type pathFinderFunc func(pf *PathFinder, ctx context.Context, tokenIn, tokenOut string, amountIn *big.Int, maxHops, maxPaths int, search *pathSearch) ([][]*types.Pool, error)
+
+// newPathFinderAlgorithm returns the algorithm called name. Unknown names, which config
+// validation rejects, fall back to Dijkstra.
+func newPathFinderAlgorithm(name string) pathFinderFunc {
+	switch strings.ToLower(name) {
+	case PathAlgorithmYen:
+		return (*PathFinder).findPathsYen
+	case PathAlgorithmAStar:
+		return (*PathFinder).findPathsAStar
+	case PathAlgorithmBidir:
+		return (*PathFinder).findPathsBidirectional
+	default:
+		return (*PathFinder).findBestPaths
+	}
+}
+
+// SetPathAlgorithm selects the algorithm FindBestPaths and the router search with
+func (pf *PathFinder) SetPathAlgorithm(name string) {
+	algorithm := newPathFinderAlgorithm(name)
+	pf.algorithm.Store(&algorithm)
+}
+
+// dispatch searches paths with the selected algorithm, Dijkstra if none was set
+func (pf *PathFinder) dispatch(ctx context.Context, tokenIn, tokenOut string, amountIn *big.Int, maxHops, maxPaths int, search *pathSearch) ([][]*types.Pool, error) {
+	find := (*PathFinder).findBestPaths
+	if algorithm := pf.algorithm.Load(); algorithm != nil {
+		find = *algorithm
+	}
+	return find(pf, ctx, tokenIn, tokenOut, amountIn, maxHops, maxPaths, search)
+}
+
+func (pf *PathFinder) findPathsAStar(ctx context.Context, tokenIn, tokenOut string, amountIn *big.Int, maxHops, maxPaths int, search *pathSearch) ([][]*types.Pool, error) {
+	search.aStar = true
+	return pf.findBestPaths(ctx, tokenIn, tokenOut, amountIn, maxHops, maxPaths, search)
+}
+
+// rankedPath is a complete path and the amount of tokenOut it returns
+type rankedPath struct {
+	pools     []*types.Pool
+	amountOut *big.Int
+}
+
+// simulatePath swaps amountIn of tokenIn along path with the checks the search applies
+// to each hop. It returns false if any hop fails.
+func (pf *PathFinder) simulatePath(ctx context.Context, path []*types.Pool, tokenIn string, amountIn *big.Int) (*big.Int, bool) {
+	maxConsecutive := int(pf.maxConsecutiveHopsPerDEX.Load())
+	staleness := pf.staleness.Load()
+
+	token := strings.ToLower(tokenIn)
+	amount := amountIn
+	for i, pool := range path {
+		if maxConsecutive > 0 && trailingExchangeHops(path[:i], pool.Exchange) >= maxConsecutive {
+			return nil, false
+		}
+		out, err := pf.priceCalc.CalculateOutput(ctx, pool, amount, token)
+		if err != nil || out.Sign() <= 0 {
+			return nil, false
+		}
+		out, ok := staleness.adjustForStaleness(ctx, pool, out)
+		if !ok {
+			return nil, false
+		}
+		amount = out
+		token = otherToken(pool, token)
+	}
+	return amount, true
+}
+
+// otherToken is the token pool swaps token into
+func otherToken(pool *types.Pool, token string) string {
+	if strings.ToLower(pool.Token0.Address) == token {
+		return strings.ToLower(pool.Token1.Address)
+	}
+	return strings.ToLower(pool.Token0.Address)
+}
+
+// pathTokens lists the tokens path visits, starting with tokenIn
+func pathTokens(path []*types.Pool, tokenIn string) []string {
+	tokens := make([]string, 0, len(path)+1)
+	tokens = append(tokens, strings.ToLower(tokenIn))
+	for _, pool := range path {
+		tokens = append(tokens, otherToken(pool, tokens[len(tokens)-1]))
+	}
+	return tokens
+}
+
+// pathKey identifies a path by its pools
+func pathKey(path []*types.Pool) string {
+	var b strings.Builder
+	for _, pool := range path {
+		b.WriteString(strings.ToLower(pool.Address))
+		b.WriteByte('/')
+	}
+	return b.String()
+}
+
+// sortRankedPaths orders paths by output, most first, keeping equal outputs in order
+func sortRankedPaths(paths []*rankedPath) {
+	sort.SliceStable(paths, func(i, j int) bool {
+		return paths[i].amountOut.Cmp(paths[j].amountOut) > 0
+	})
+}
+
+func rankedPools(paths []*rankedPath) [][]*types.Pool {
+	pools := make([][]*types.Pool, len(paths))
+	for i, path := range paths {
+		pools[i] = path.pools
+	}
+	return pools
+}
+
+// findPathsYen finds up to maxPaths loop-free paths with Yen's algorithm. The best path
+// comes from a Dijkstra search; each further path is the best detour from a path already
+// found: it keeps a prefix of that path, then leaves it by a pool no found path with
+// the same prefix takes, without revisiting the prefix's tokens. Unlike Dijkstra, which
+// keeps one path per token, paths may share every token but one pool.
+func (pf *PathFinder) findPathsYen(ctx context.Context, tokenIn, tokenOut string, amountIn *big.Int, maxHops, maxPaths int, search *pathSearch) ([][]*types.Pool, error) {
+	if maxHops <= 0 {
+		maxHops = pf.maxHops
+	}
+	first, err := pf.findBestPaths(ctx, tokenIn, tokenOut, amountIn, maxHops, 1, search)
+	if err != nil || len(first) == 0 {
+		return first, err
+	}
+	firstOut, ok := pf.simulatePath(ctx, first[0], tokenIn, amountIn)
+	if !ok {
+		return first, nil
+	}
+
+	logger := applog.FromContext(ctx)
+	found := []*rankedPath{{pools: first[0], amountOut: firstOut}}
+	seen := map[string]bool{pathKey(first[0]): true}
+	var candidates []*rankedPath
+
+	for len(found) < maxPaths {
+		if err := ctx.Err(); err != nil {
+			logger.Warn("PathFinder: Yen search stopped early, returning partial paths", "count", len(found), "error", err)
+			return rankedPools(found), ErrPartialResult
+		}
+
+		previous := found[len(found)-1].pools
+		tokens := pathTokens(previous, tokenIn)
+		for i := range previous {
+			root := previous[:i]
+			rootOut, ok := pf.simulatePath(ctx, root, tokenIn, amountIn)
+			if !ok {
+				continue
+			}
+
+			// Leave the root by a pool no found path sharing the root takes
+			spur := &pathSearch{
+				exchange:       search.exchange,
+				excludedPools:  make(map[*types.Pool]bool),
+				excludedTokens: make(map[string]bool, i),
+				nested:         true,
+			}
+			for _, path := range found {
+				if len(path.pools) > i && samePools(path.pools[:i], root) {
+					spur.excludedPools[path.pools[i]] = true
+				}
+			}
+			for _, token := range tokens[:i] {
+				spur.excludedTokens[token] = true
+			}
+
+			spurPaths, err := pf.findBestPaths(ctx, tokens[i], tokenOut, rootOut, maxHops-i, 1, spur)
+			search.expanded += spur.expanded
+			if err != nil && !errors.Is(err, ErrPartialResult) {
+				continue
+			}
+			for _, spurPath := range spurPaths {
+				candidate := make([]*types.Pool, 0, i+len(spurPath))
+				candidate = append(append(candidate, root...), spurPath...)
+				key := pathKey(candidate)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				if out, ok := pf.simulatePath(ctx, candidate, tokenIn, amountIn); ok {
+					candidates = append(candidates, &rankedPath{pools: candidate, amountOut: out})
+				}
+			}
+		}
+
+		if len(candidates) == 0 {
+			break
+		}
+		sortRankedPaths(candidates)
+		found = append(found, candidates[0])
+		candidates = candidates[1:]
+	}
+
+	// Dijkstra's first path is not always the best, so a detour may beat it
+	sortRankedPaths(found)
+	logger.Info("PathFinder: Yen search found paths", "count", len(found))
+	return rankedPools(found), nil
+}
+
+func samePools(a, b []*types.Pool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// findPathsBidirectional searches ceil(maxHops/2) hops forward from tokenIn, simulating
+// swaps and keeping the maxPaths best prefixes reaching each token, and floor(maxHops/2)
+// hops back from tokenOut, keeping the maxPaths suffixes with the best spot rates. Paths
+// are the loop-free joins of a prefix and a suffix meeting at a token, each simulated in
+// full, so both halves of the search grow with half the hops.
+func (pf *PathFinder) findPathsBidirectional(ctx context.Context, tokenIn, tokenOut string, amountIn *big.Int, maxHops, maxPaths int, search *pathSearch) ([][]*types.Pool, error) {
+	if maxHops <= 0 {
+		maxHops = pf.maxHops
+	}
+	tokenIn = strings.ToLower(tokenIn)
+	tokenOut = strings.ToLower(tokenOut)
+
+	logger := applog.FromContext(ctx)
+	logger.Info("PathFinder: Searching paths bidirectionally",
+		"from", tokenIn, "to", tokenOut, "amountIn", amountIn.String(), "maxHops", maxHops, "maxPaths", maxPaths, "exchange", search.exchange)
+
+	g := pf.graph.Load()
+	if g == nil {
+		logger.Info("PathFinder: Graph is not initialized")
+		return [][]*types.Pool{}, errors.New("graph not initialized")
+	}
+	if !g.hasToken(tokenIn) || !g.hasToken(tokenOut) {
+		return [][]*types.Pool{}, nil
+	}
+
+	forward := pf.forwardPrefixes(ctx, g, tokenIn, amountIn, (maxHops+1)/2, maxPaths, search)
+	backward := backwardSuffixes(g, tokenOut, maxHops/2, maxPaths, search.exchange)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var paths []*rankedPath
+	seen := make(map[string]bool)
+	for token, prefixes := range forward {
+		for _, suffix := range backward[token] {
+			for _, prefix := range prefixes {
+				path := joinPath(prefix.pools, suffix.pools, tokenIn)
+				if path == nil {
+					continue
+				}
+				key := pathKey(path)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				if out, ok := pf.simulatePath(ctx, path, tokenIn, amountIn); ok {
+					paths = append(paths, &rankedPath{pools: path, amountOut: out})
+				}
+			}
+		}
+	}
+
+	sortRankedPaths(paths)
+	if len(paths) > maxPaths {
+		paths = paths[:maxPaths]
+	}
+	logger.Info("PathFinder: Bidirectional search found paths", "count", len(paths))
+	return rankedPools(paths), nil
+}
+
+// forwardPrefixes simulates every loop-free path of up to hops hops from tokenIn and keeps
+// the keep best reaching each token, including the empty path at tokenIn
+func (pf *PathFinder) forwardPrefixes(ctx context.Context, g *graphData, tokenIn string, amountIn *big.Int, hops, keep int, search *pathSearch) map[string][]*rankedPath {
+	staleness := pf.staleness.Load()
+	maxConsecutive := int(pf.maxConsecutiveHopsPerDEX.Load())
+
+	prefixes := map[string][]*rankedPath{tokenIn: {{amountOut: amountIn}}}
+	frontier := prefixes
+	for hop := 0; hop < hops && ctx.Err() == nil; hop++ {
+		next := make(map[string][]*rankedPath)
+		for token, states := range frontier {
+			search.expanded += len(states)
+			for _, state := range states {
+				for _, neighbor := range g.adj[token] {
+					if neighbor == tokenIn || pf.pathContainsToken(state.pools, neighbor) {
+						continue
+					}
+					for _, edge := range g.edgesBetween(token, neighbor) {
+						pool := edge.pool
+						if search.exchange != "" && !strings.EqualFold(pool.Exchange, search.exchange) {
+							continue
+						}
+						if maxConsecutive > 0 && trailingExchangeHops(state.pools, pool.Exchange) >= maxConsecutive {
+							continue
+						}
+						out, err := pf.priceCalc.CalculateOutput(ctx, pool, state.amountOut, token)
+						if err != nil || out.Sign() <= 0 {
+							continue
+						}
+						out, ok := staleness.adjustForStaleness(ctx, pool, out)
+						if !ok {
+							continue
+						}
+						path := make([]*types.Pool, len(state.pools)+1)
+						copy(path, state.pools)
+						path[len(path)-1] = pool
+						next[neighbor] = append(next[neighbor], &rankedPath{pools: path, amountOut: out})
+					}
+				}
+			}
+		}
+		for token, states := range next {
+			sortRankedPaths(states)
+			if len(states) > keep {
+				states = states[:keep]
+			}
+			next[token] = states
+			prefixes[token] = append(prefixes[token], states...)
+		}
+		frontier = next
+	}
+	return prefixes
+}
+
+// suffix is a path to tokenOut and the log of the product of its spot rates
+type suffix struct {
+	pools   []*types.Pool // In swap order, ending at tokenOut
+	logRate float64
+}
+
+// backwardSuffixes lists loop-free paths of up to hops hops into tokenOut, keeping the
+// keep with the best spot rates from each token, including the empty path at tokenOut.
+// Spot rates need no amount, which is unknown until a prefix is chosen.
+func backwardSuffixes(g *graphData, tokenOut string, hops, keep int, exchange string) map[string][]*suffix {
+	suffixes := map[string][]*suffix{tokenOut: {{}}}
+	frontier := suffixes
+	for hop := 0; hop < hops; hop++ {
+		next := make(map[string][]*suffix)
+		for token, states := range frontier {
+			for _, state := range states {
+				for _, from := range g.adj[token] {
+					if from == tokenOut || suffixContainsToken(state.pools, from) {
+						continue
+					}
+					for _, edge := range g.edgesBetween(from, token) {
+						if exchange != "" && !strings.EqualFold(edge.pool.Exchange, exchange) {
+							continue
+						}
+						weight, ok := logRateWeight(edge.pool, from)
+						if !ok {
+							continue
+						}
+						pools := make([]*types.Pool, 0, len(state.pools)+1)
+						pools = append(append(pools, edge.pool), state.pools...)
+						next[from] = append(next[from], &suffix{pools: pools, logRate: state.logRate - weight})
+					}
+				}
+			}
+		}
+		for token, states := range next {
+			sort.SliceStable(states, func(i, j int) bool { return states[i].logRate > states[j].logRate })
+			if len(states) > keep {
+				states = states[:keep]
+			}
+			next[token] = states
+			suffixes[token] = append(suffixes[token], states...)
+		}
+		frontier = next
+	}
+	return suffixes
+}
+
+func suffixContainsToken(pools []*types.Pool, token string) bool {
+	for _, pool := range pools {
+		if strings.ToLower(pool.Token0.Address) == token || strings.ToLower(pool.Token1.Address) == token {
+			return true
+		}
+	}
+	return false
+}
+
+// joinPath joins prefix and suffix into one path, or returns nil if it would visit a
+// token twice or the result is empty
+func joinPath(prefix, suffix []*types.Pool, tokenIn string) []*types.Pool {
+	if len(prefix)+len(suffix) == 0 {
+		return nil
+	}
+	path := make([]*types.Pool, 0, len(prefix)+len(suffix))
+	path = append(append(path, prefix...), suffix...)
+
+	visited := make(map[string]bool, len(path)+1)
+	for _, token := range pathTokens(path, tokenIn) {
+		if visited[token] {
+			return nil
+		}
+		visited[token] = true
+	}
+	return path
+}
@@ -5,60 +5,278 @@ package aggregator
 import (
 	"container/heap"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"math/big"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"sync/atomic" // Change: import atomic
 	"time"
 
+	"dex-aggregator/config"
 	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/circuitbreaker"
+	applog "dex-aggregator/internal/log"
 	"dex-aggregator/internal/types"
+	"dex-aggregator/internal/validation"
+	"dex-aggregator/internal/volume"
 )
 
-// Add: graphData to store the routing graph snapshot
+// graphData is an immutable routing graph snapshot. Pools are stored once per
+// direction in a flat edge slice, grouped by token pair, to keep allocations low.
 type graphData struct {
-	adj          map[string]map[string]bool
-	poolMap      map[string]map[string][]*types.Pool
-	liquidityMap map[string]map[string]*big.Int
+	adj       map[string][]string  // token -> sorted neighbour tokens
+	edges     []poolEdge           // pool edges, contiguous per token pair
+	edgeIndex map[string]edgeRange // edgeKey(from, to) -> range in edges
+}
+
+// poolEdge is a pool traded from one of its tokens to the other
+type poolEdge struct {
+	from, to string
+	pool     *types.Pool
+}
+
+// edgeRange locates a token pair's pools in graphData.edges. int32 halves the
+// index size and still addresses two billion edges.
+type edgeRange struct {
+	start, end int32
+}
+
+func edgeKey(from, to string) string {
+	return from + ":" + to
+}
+
+// hasToken reports whether token has any pools in the graph
+func (g *graphData) hasToken(token string) bool {
+	_, ok := g.adj[token]
+	return ok
+}
+
+// hasEdge reports whether a pool connects from to to
+func (g *graphData) hasEdge(from, to string) bool {
+	neighbours := g.adj[from]
+	i := sort.SearchStrings(neighbours, to)
+	return i < len(neighbours) && neighbours[i] == to
+}
+
+// tokenDecimals returns the decimals of token as recorded by its first pool in the
+// graph, which may be nil
+func (g *graphData) tokenDecimals(token string) (int, bool) {
+	if g == nil || len(g.adj[token]) == 0 {
+		return 0, false
+	}
+	edges := g.edgesBetween(token, g.adj[token][0])
+	if len(edges) == 0 {
+		return 0, false
+	}
+	return tokenDecimals(edges[0].pool, token)
+}
+
+// edgesBetween returns the pool edges from from to to, in pool order
+func (g *graphData) edgesBetween(from, to string) []poolEdge {
+	r, ok := g.edgeIndex[edgeKey(from, to)]
+	if !ok {
+		return nil
+	}
+	return g.edges[r.start:r.end]
 }
 
 type PathFinder struct {
+	ctx       context.Context // Application lifetime, bounds background refreshes
 	cache     cache.Store
 	priceCalc *PriceCalculator // Add PriceCalculator dependency
 	maxHops   int
 
+	// buildWorkers is the number of goroutines used to build the graph on refresh
+	buildWorkers int
+
+	// preferHighScore orders near-equal paths by pool liquidity score during search
+	preferHighScore atomic.Bool
+
+	// tokens restricts which tokens pools may connect in the graph
+	tokens atomic.Pointer[tokenFilter]
+
+	// volumes supplies Pool.Volume24h on refresh when set
+	volumes atomic.Pointer[volume.Accumulator]
+
+	// validators exclude pools from the graph, such as pools that lost their liquidity
+	validators atomic.Pointer[[]validation.PoolValidator]
+
+	// maxConsecutiveHopsPerDEX caps consecutive hops through one exchange; 0 is unlimited
+	maxConsecutiveHopsPerDEX atomic.Int64
+
+	// maxPoolsPerPair is the number of deepest pools kept per token pair; 0 keeps every pool
+	maxPoolsPerPair atomic.Int64
+
+	// staleness decides how hops through pools with old reserves are treated
+	staleness atomic.Pointer[reserveStaleness]
+
+	// algorithm is the path search FindBestPaths dispatches to; nil is Dijkstra
+	algorithm atomic.Pointer[pathFinderFunc]
+
+	// breaker excludes the pools of exchanges whose paths keep failing from the graph
+	breaker atomic.Pointer[circuitbreaker.ExchangeBreaker]
+
 	// Change: Remove graphLock, adj, poolMap, liquidityMap
 	// Use atomic.Pointer for lock-free read/write
 	graph atomic.Pointer[graphData]
 }
 
-// Update constructor
-func NewPathFinder(cache cache.Store, priceCalc *PriceCalculator) *PathFinder {
-	pf := &PathFinder{
-		cache:     cache,
-		priceCalc: priceCalc, // Inject dependency
-		maxHops:   3,
-		// graph will be initialized in RefreshGraph
-	}
+// NewPathFinder loads the graph from the cache and refreshes it in the background
+// until ctx, the application lifetime context, is cancelled
+func NewPathFinder(ctx context.Context, cache cache.Store, priceCalc *PriceCalculator) *PathFinder {
+	pf := newPathFinder(ctx, cache, priceCalc)
 
 	// 1. Perform the first blocking refresh here
 	// This will increase server startup time but ensures the service is ready immediately
 	log.Println("PathFinder: Performing initial graph load...")
-	if err := pf.RefreshGraph(context.Background()); err != nil {
+	if err := pf.RefreshGraph(ctx); err != nil {
 		// If the graph fails to load on startup, the service won't work, this is a fatal error
 		log.Fatalf("PathFinder: Initial graph refresh failed: %v", err)
 	}
 	log.Println("PathFinder: Initial graph load complete.")
 
+	pf.startGraphRefresher()
+
+	return pf
+}
+
+// NewPathFinderFromSnapshot warm-starts the graph from a snapshot written by SaveGraph
+// at most maxAge ago, as LoadGraph checks, and refreshes it from the cache in the
+// background.
+func NewPathFinderFromSnapshot(ctx context.Context, cache cache.Store, priceCalc *PriceCalculator, r io.Reader, maxAge time.Duration) (*PathFinder, error) {
+	pf := newPathFinder(ctx, cache, priceCalc)
+
+	if err := pf.LoadGraph(ctx, r, maxAge); err != nil {
+		return nil, err
+	}
+
+	pf.RefreshGraphAsync()
+	pf.startGraphRefresher()
+
+	return pf, nil
+}
+
+func newPathFinder(ctx context.Context, cache cache.Store, priceCalc *PriceCalculator) *PathFinder {
+	pf := &PathFinder{
+		ctx:       ctx,
+		cache:     cache,
+		priceCalc: priceCalc, // Inject dependency
+		maxHops:   3,
+
+		buildWorkers: runtime.NumCPU(),
+		// graph will be initialized in RefreshGraph or LoadGraph
+	}
+
+	if cfg := config.Current(); cfg != nil {
+		pf.SetTokenFilter(cfg.DEX.AllowedTokens, cfg.DEX.DeniedTokens)
+		pf.SetMaxConsecutiveHopsPerDEX(cfg.DEX.MaxConsecutiveHopsPerDEX)
+		pf.SetMaxPoolsPerPair(cfg.DEX.MaxPoolsPerPair)
+		pf.SetReserveStaleness(cfg.DEX)
+		pf.SetPathAlgorithm(cfg.Performance.PathAlgorithm)
+	}
+	return pf
+}
+
+func (pf *PathFinder) startGraphRefresher() {
 	// Change: Get refresh interval from config
 	// Note: We defined it in config.go, but NewRouter doesn't receive it
 	// Hardcode for now, ideally should be passed from config
 	refreshInterval := 30 * time.Second
-	// refreshInterval := config.AppConfig.Performance.GraphRefreshInterval
-	go pf.runGraphRefresher(context.Background(), refreshInterval)
+	// refreshInterval := config.Current().Performance.GraphRefreshInterval
+	go pf.runGraphRefresher(pf.ctx, refreshInterval)
+}
 
-	return pf
+// SetPreferHighScorePools biases the search toward deeper, fresher pools when paths
+// offer near-equal output
+func (pf *PathFinder) SetPreferHighScorePools(prefer bool) {
+	pf.preferHighScore.Store(prefer)
+}
+
+// SetTokenFilter restricts routing to pools whose tokens are all allowed and none denied.
+// An empty allowed list allows every token. It applies from the next graph refresh.
+func (pf *PathFinder) SetTokenFilter(allowed, denied []string) {
+	pf.tokens.Store(newTokenFilter(allowed, denied))
+}
+
+// SetPoolValidators excludes pools failing any of validators from the next graph refresh
+func (pf *PathFinder) SetPoolValidators(validators ...validation.PoolValidator) {
+	pf.validators.Store(&validators)
+}
+
+// SetMaxConsecutiveHopsPerDEX limits paths to at most n consecutive hops through the
+// same exchange, promoting routing diversity. n <= 0 removes the limit.
+func (pf *PathFinder) SetMaxConsecutiveHopsPerDEX(n int) {
+	pf.maxConsecutiveHopsPerDEX.Store(int64(max(n, 0)))
+}
+
+// SetMaxPoolsPerPair keeps only the n pools with the largest reserve product of each
+// token pair in the graph from the next refresh. n <= 0 keeps every pool.
+func (pf *PathFinder) SetMaxPoolsPerPair(n int) {
+	pf.maxPoolsPerPair.Store(int64(max(n, 0)))
+}
+
+// reserveStaleness is the policy for pools whose reserves are older than maxAge
+type reserveStaleness struct {
+	maxAge  time.Duration // Non-positive disables the check
+	penalty float64       // Fraction of a stale hop's output deducted; 0 skips the pool
+}
+
+// SetReserveStaleness applies the DEX config's stale reserve policy to future searches
+func (pf *PathFinder) SetReserveStaleness(dexConfig config.DEXConfig) {
+	pf.staleness.Store(&reserveStaleness{
+		maxAge:  time.Duration(dexConfig.MaxReserveAgeSecs) * time.Second,
+		penalty: math.Min(math.Max(dexConfig.StaleReservePenalty, 0), 1),
+	})
+}
+
+// adjustForStaleness applies the stale reserve policy to the output of a hop through pool.
+// It returns false when the pool must be skipped. Pools without a reserve timestamp are
+// never stale.
+func (s *reserveStaleness) adjustForStaleness(ctx context.Context, pool *types.Pool, amountOut *big.Int) (*big.Int, bool) {
+	if s == nil || s.maxAge <= 0 || pool.ReserveUpdatedAt.IsZero() {
+		return amountOut, true
+	}
+	age := time.Since(pool.ReserveUpdatedAt)
+	if age <= s.maxAge {
+		return amountOut, true
+	}
+
+	logger := applog.FromContext(ctx)
+	if s.penalty == 0 {
+		logger.Warn("PathFinder: Skipping pool with stale reserves", "pool", pool.Address, "age", age.Round(time.Second))
+		return nil, false
+	}
+
+	logger.Warn("PathFinder: Penalising pool with stale reserves", "pool", pool.Address, "age", age.Round(time.Second), "penalty", s.penalty)
+	keep := big.NewInt(int64(math.Round((1 - s.penalty) * 10000)))
+	penalised := new(big.Int).Mul(amountOut, keep)
+	return penalised.Div(penalised, big.NewInt(10000)), true
+}
+
+// SetVolumeAccumulator attaches 24 hour swap volumes to pools from the next graph refresh
+func (pf *PathFinder) SetVolumeAccumulator(volumes *volume.Accumulator) {
+	pf.volumes.Store(volumes)
+}
+
+// SetExchangeBreaker leaves the pools of exchanges open in breaker out of the graph
+// from the next refresh
+func (pf *PathFinder) SetExchangeBreaker(breaker *circuitbreaker.ExchangeBreaker) {
+	pf.breaker.Store(breaker)
+}
+
+// RefreshGraphAsync rebuilds the graph in the background under the application context
+func (pf *PathFinder) RefreshGraphAsync() {
+	go func() {
+		if err := pf.RefreshGraph(pf.ctx); err != nil {
+			log.Printf("PathFinder: Background graph refresh failed: %v", err)
+		}
+	}()
 }
 
 func (pf *PathFinder) runGraphRefresher(ctx context.Context, interval time.Duration) {
@@ -88,61 +306,307 @@ func (pf *PathFinder) RefreshGraph(ctx context.Context) error {
 		return fmt.Errorf("failed to get pools for graph refresh: %v", err)
 	}
 
-	// Build a new graph (Build in local variables)
-	adj := make(map[string]map[string]bool)
-	poolMap := make(map[string]map[string][]*types.Pool)
-	liquidityMap := make(map[string]map[string]*big.Int)
+	if volumes := pf.volumes.Load(); volumes != nil {
+		allPools = withVolumes(allPools, volumes)
+	}
 
-	for _, pool := range allPools {
-		t0 := strings.ToLower(pool.Token0.Address)
-		t1 := strings.ToLower(pool.Token1.Address)
+	// Change: Atomically replace the pointer instead of using a lock
+	pf.graph.Store(pf.buildGraph(allPools))
+
+	log.Printf("PathFinder: Graph refreshed, %d pools loaded.", len(allPools))
+	return nil
+}
+
+// withVolumes returns copies of pools with Volume24h set, leaving the cached pools untouched
+func withVolumes(pools []*types.Pool, volumes *volume.Accumulator) []*types.Pool {
+	result := make([]*types.Pool, len(pools))
+	for i, pool := range pools {
+		p := *pool
+		p.Volume24h = volumes.GetVolume24h(pool.Address)
+		result[i] = &p
+	}
+	return result
+}
+
+// buildGraph builds a graph from the unpaused, valid pools allowed by the token filter
+func (pf *PathFinder) buildGraph(allPools []*types.Pool) *graphData {
+	allPools = unpausedPools(allPools)
+	if validators := pf.validators.Load(); validators != nil {
+		allPools = validPools(allPools, *validators)
+	}
+	if filter := pf.tokens.Load(); filter != nil {
+		allPools = filter.apply(allPools)
+	}
+	if breaker := pf.breaker.Load(); breaker != nil {
+		allPools = closedExchangePools(allPools, breaker)
+	}
+	if keepTopN := int(pf.maxPoolsPerPair.Load()); keepTopN > 0 {
+		allPools = pf.deepestPoolsPerPair(allPools, keepTopN)
+	}
+	return buildGraphParallel(allPools, pf.buildWorkers)
+}
+
+// unpausedPools returns the pools that are not paused
+func unpausedPools(pools []*types.Pool) []*types.Pool {
+	active := make([]*types.Pool, 0, len(pools))
+	for _, pool := range pools {
+		if pool.IsActive() {
+			active = append(active, pool)
+		}
+	}
+	if paused := len(pools) - len(active); paused > 0 {
+		log.Printf("PathFinder: Skipped %d paused pools", paused)
+	}
+	return active
+}
 
-		if adj[t0] == nil {
-			adj[t0] = make(map[string]bool)
+// deepestPoolsPerPair groups pools by token pair and keeps the keepTopN deepest of each
+func (pf *PathFinder) deepestPoolsPerPair(pools []*types.Pool, keepTopN int) []*types.Pool {
+	var pairs []string
+	byPair := make(map[string][]*types.Pool)
+	for _, pool := range pools {
+		token0 := strings.ToLower(pool.Token0.Address)
+		token1 := strings.ToLower(pool.Token1.Address)
+		if token0 > token1 {
+			token0, token1 = token1, token0
 		}
-		if adj[t1] == nil {
-			adj[t1] = make(map[string]bool)
+		key := edgeKey(token0, token1)
+		if _, ok := byPair[key]; !ok {
+			pairs = append(pairs, key)
 		}
-		if poolMap[t0] == nil {
-			poolMap[t0] = make(map[string][]*types.Pool)
+		byPair[key] = append(byPair[key], pool)
+	}
+
+	kept := make([]*types.Pool, 0, len(pools))
+	for _, key := range pairs {
+		kept = append(kept, pf.filterPoolsByLiquidity(byPair[key], keepTopN)...)
+	}
+	if pruned := len(pools) - len(kept); pruned > 0 {
+		log.Printf("PathFinder: Pruned %d shallow pools beyond %d per token pair", pruned, keepTopN)
+	}
+	return kept
+}
+
+// filterPoolsByLiquidity returns the keepTopN pools with the largest Reserve0 * Reserve1,
+// deepest first. The pools are expected to share a token pair.
+func (pf *PathFinder) filterPoolsByLiquidity(pools []*types.Pool, keepTopN int) []*types.Pool {
+	if keepTopN <= 0 || len(pools) <= keepTopN {
+		return pools
+	}
+
+	products := make(map[*types.Pool]*big.Int, len(pools))
+	for _, pool := range pools {
+		product := new(big.Int)
+		if pool.Reserve0 != nil && pool.Reserve1 != nil {
+			product.Mul(pool.Reserve0, pool.Reserve1)
 		}
-		if poolMap[t1] == nil {
-			poolMap[t1] = make(map[string][]*types.Pool)
+		products[pool] = product
+	}
+
+	sorted := make([]*types.Pool, len(pools))
+	copy(sorted, pools)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return products[sorted[i]].Cmp(products[sorted[j]]) > 0
+	})
+	return sorted[:keepTopN]
+}
+
+// closedExchangePools returns the pools whose exchange breaker is not open
+func closedExchangePools(pools []*types.Pool, breaker *circuitbreaker.ExchangeBreaker) []*types.Pool {
+	closed := make([]*types.Pool, 0, len(pools))
+	for _, pool := range pools {
+		if !breaker.IsOpen(pool.Exchange) {
+			closed = append(closed, pool)
 		}
-		if liquidityMap[t0] == nil {
-			liquidityMap[t0] = make(map[string]*big.Int)
+	}
+	if skipped := len(pools) - len(closed); skipped > 0 {
+		log.Printf("PathFinder: Skipped %d pools of exchanges with an open circuit breaker", skipped)
+	}
+	return closed
+}
+
+// validPools returns the pools that pass validators, logging each one skipped
+func validPools(pools []*types.Pool, validators []validation.PoolValidator) []*types.Pool {
+	valid := make([]*types.Pool, 0, len(pools))
+	for _, pool := range pools {
+		if err := validation.Validate(pool, validators); err != nil {
+			log.Printf("PathFinder: Skipping pool %s: %v", pool.Address, err)
+			continue
 		}
-		if liquidityMap[t1] == nil {
-			liquidityMap[t1] = make(map[string]*big.Int)
+		valid = append(valid, pool)
+	}
+	return valid
+}
+
+// tokenFilter holds lowercased token allow and deny sets
+type tokenFilter struct {
+	allowed map[string]bool // empty allows every token
+	denied  map[string]bool
+}
+
+func newTokenFilter(allowed, denied []string) *tokenFilter {
+	tf := &tokenFilter{
+		allowed: make(map[string]bool, len(allowed)),
+		denied:  make(map[string]bool, len(denied)),
+	}
+	for _, token := range allowed {
+		tf.allowed[strings.ToLower(strings.TrimSpace(token))] = true
+	}
+	for _, token := range denied {
+		tf.denied[strings.ToLower(strings.TrimSpace(token))] = true
+	}
+	return tf
+}
+
+func (tf *tokenFilter) permits(token string) bool {
+	token = strings.ToLower(token)
+	if tf.denied[token] {
+		return false
+	}
+	return len(tf.allowed) == 0 || tf.allowed[token]
+}
+
+// apply returns the pools whose tokens are both permitted
+func (tf *tokenFilter) apply(pools []*types.Pool) []*types.Pool {
+	if len(tf.allowed) == 0 && len(tf.denied) == 0 {
+		return pools
+	}
+
+	filtered := make([]*types.Pool, 0, len(pools))
+	for _, pool := range pools {
+		if tf.permits(pool.Token0.Address) && tf.permits(pool.Token1.Address) {
+			filtered = append(filtered, pool)
 		}
+	}
+	if skipped := len(pools) - len(filtered); skipped > 0 {
+		log.Printf("PathFinder: Excluded %d pools by token allow/deny lists", skipped)
+	}
+	return filtered
+}
+
+// minPoolsPerWorker keeps small pool sets on a single goroutine, where fan-out costs more than it saves
+const minPoolsPerWorker = 1000
+
+// graphBuilder collects pool edges per token pair before they are laid out in a graphData
+type graphBuilder struct {
+	pairs map[string]*pendingPair
+	order []*pendingPair // Pairs in the order they were first seen
+	edges int
+}
+
+// pendingPair holds the pools of one directed token pair while the graph is built
+type pendingPair struct {
+	from, to string
+	pools    []*types.Pool
+}
+
+func newGraphBuilder() *graphBuilder {
+	return &graphBuilder{pairs: make(map[string]*pendingPair)}
+}
+
+// buildGraphParallel partitions allPools into contiguous chunks, collects the edges of
+// each chunk on its own goroutine and merges the partials in chunk order. Merging in
+// order keeps every pair's pools, and the pairs themselves, in allPools order, so the
+// result matches a sequential build of the same pools.
+func buildGraphParallel(allPools []*types.Pool, workers int) *graphData {
+	if maxWorkers := len(allPools) / minPoolsPerWorker; workers > maxWorkers {
+		workers = maxWorkers
+	}
+	if workers <= 1 {
+		b := newGraphBuilder()
+		b.addPools(allPools)
+		return b.build()
+	}
 
-		adj[t0][t1] = true
-		adj[t1][t0] = true
-		poolMap[t0][t1] = append(poolMap[t0][t1], pool)
-		poolMap[t1][t0] = append(poolMap[t1][t0], pool)
-
-		poolLiquidity := new(big.Int).Mul(pool.Reserve0, pool.Reserve1)
-		if existing, exists := liquidityMap[t0][t1]; exists {
-			liquidityMap[t0][t1] = new(big.Int).Add(existing, poolLiquidity)
-			liquidityMap[t1][t0] = new(big.Int).Add(existing, poolLiquidity)
-		} else {
-			liquidityMap[t0][t1] = poolLiquidity
-			liquidityMap[t1][t0] = poolLiquidity
+	chunkSize := (len(allPools) + workers - 1) / workers
+	partials := make([]*graphBuilder, workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		lo := i * chunkSize
+		hi := min(lo+chunkSize, len(allPools))
+		partials[i] = newGraphBuilder()
+		if lo >= hi {
+			continue
 		}
+
+		wg.Add(1)
+		go func(partial *graphBuilder, pools []*types.Pool) {
+			defer wg.Done()
+			partial.addPools(pools)
+		}(partials[i], allPools[lo:hi])
 	}
+	wg.Wait()
 
-	// Change: Create new graphData snapshot
-	newGraph := &graphData{
-		adj:          adj,
-		poolMap:      poolMap,
-		liquidityMap: liquidityMap,
+	// Partials are merged on this goroutine; the graph is only published once complete
+	b := partials[0]
+	for _, partial := range partials[1:] {
+		b.merge(partial)
 	}
+	return b.build()
+}
 
-	// Change: Atomically replace the pointer instead of using a lock
-	pf.graph.Store(newGraph)
+// addPools adds each pool as an edge in both directions
+func (b *graphBuilder) addPools(pools []*types.Pool) {
+	for _, pool := range pools {
+		t0 := strings.ToLower(pool.Token0.Address)
+		t1 := strings.ToLower(pool.Token1.Address)
 
-	log.Printf("PathFinder: Graph refreshed, %d pools loaded.", len(allPools))
-	return nil
+		b.addEdges(t0, t1, pool)
+		b.addEdges(t1, t0, pool)
+	}
+}
+
+// merge appends other's pairs after b's own, preserving pool order within each pair
+func (b *graphBuilder) merge(other *graphBuilder) {
+	for _, pair := range other.order {
+		b.addEdges(pair.from, pair.to, pair.pools...)
+	}
+}
+
+func (b *graphBuilder) addEdges(from, to string, pools ...*types.Pool) {
+	key := edgeKey(from, to)
+	pair, ok := b.pairs[key]
+	if !ok {
+		pair = &pendingPair{from: from, to: to}
+		b.pairs[key] = pair
+		b.order = append(b.order, pair)
+	}
+
+	pair.pools = append(pair.pools, pools...)
+	b.edges += len(pools)
+}
+
+// build lays the collected pairs out in a graphData
+func (b *graphBuilder) build() *graphData {
+	degree := make(map[string]int)
+	for _, pair := range b.order {
+		degree[pair.from]++
+	}
+
+	g := &graphData{
+		adj:       make(map[string][]string, len(degree)),
+		edges:     make([]poolEdge, 0, b.edges),
+		edgeIndex: make(map[string]edgeRange, len(b.order)),
+	}
+
+	for _, pair := range b.order {
+		start := len(g.edges)
+		for _, pool := range pair.pools {
+			g.edges = append(g.edges, poolEdge{from: pair.from, to: pair.to, pool: pool})
+		}
+		g.edgeIndex[edgeKey(pair.from, pair.to)] = edgeRange{start: int32(start), end: int32(len(g.edges))}
+
+		if g.adj[pair.from] == nil {
+			g.adj[pair.from] = make([]string, 0, degree[pair.from])
+		}
+		g.adj[pair.from] = append(g.adj[pair.from], pair.to)
+	}
+
+	for _, neighbours := range g.adj {
+		sort.Strings(neighbours)
+	}
+	return g
 }
 
 // --- Priority Queue Implementation ---
@@ -152,118 +616,250 @@ type pathState struct {
 	path      []*types.Pool // Path to this point (composed of Pools)
 	amountOut *big.Int      // Amount of tokens held when reaching this point
 	lastToken string        // Last token in this path
+	score     float64       // Lowest liquidity score of the pools in path
+	estimate  float64       // A* only: log of the best output in tokenOut reachable from here
 	index     int           // Index in the heap
 }
 
+// scoreTieBps is the output difference, in basis points, within which PreferHighScorePools
+// treats two paths as equivalent and orders them by liquidity score
+const scoreTieBps = 10
+
+// outranks reports whether a should be expanded before b: more output first, then a
+// higher liquidity score. With preferScore, outputs within scoreTieBps count as equal.
+func outranks(a, b *pathState, preferScore bool) bool {
+	cmp := a.amountOut.Cmp(b.amountOut)
+	if cmp != 0 && preferScore && withinBps(a.amountOut, b.amountOut, scoreTieBps) {
+		cmp = 0
+	}
+	if cmp != 0 {
+		return cmp > 0
+	}
+	return a.score > b.score
+}
+
+// worseOutput reports whether a has less output than b, beyond scoreTieBps with preferScore
+func worseOutput(a, b *pathState, preferScore bool) bool {
+	if a.amountOut.Cmp(b.amountOut) >= 0 {
+		return false
+	}
+	return !preferScore || !withinBps(a.amountOut, b.amountOut, scoreTieBps)
+}
+
+// withinBps reports whether |a-b| is at most bps basis points of the larger value
+func withinBps(a, b *big.Int, bps int64) bool {
+	larger := a
+	if b.Cmp(a) > 0 {
+		larger = b
+	}
+	diff := new(big.Int).Sub(a, b)
+	diff.Abs(diff).Mul(diff, big.NewInt(10000))
+	return diff.Cmp(new(big.Int).Mul(larger, big.NewInt(bps))) <= 0
+}
+
 // priorityQueue implements heap.Interface
-type priorityQueue []*pathState
+type priorityQueue struct {
+	states      []*pathState
+	preferScore bool
+	aStar       bool // Order by estimate rather than amountOut
+}
 
-func (pq priorityQueue) Len() int { return len(pq) }
+func (pq *priorityQueue) Len() int { return len(pq.states) }
 
-func (pq priorityQueue) Less(i, j int) bool {
+func (pq *priorityQueue) Less(i, j int) bool {
+	if pq.aStar && pq.states[i].estimate != pq.states[j].estimate {
+		return pq.states[i].estimate > pq.states[j].estimate
+	}
 	// We want a Max-Heap, so sort by amountOut in descending order
-	return pq[i].amountOut.Cmp(pq[j].amountOut) > 0
+	return outranks(pq.states[i], pq.states[j], pq.preferScore)
 }
 
-func (pq priorityQueue) Swap(i, j int) {
-	pq[i], pq[j] = pq[j], pq[i]
-	pq[i].index = i
-	pq[j].index = j
+func (pq *priorityQueue) Swap(i, j int) {
+	pq.states[i], pq.states[j] = pq.states[j], pq.states[i]
+	pq.states[i].index = i
+	pq.states[j].index = j
 }
 
 func (pq *priorityQueue) Push(x interface{}) {
-	n := len(*pq)
 	item := x.(*pathState)
-	item.index = n
-	*pq = append(*pq, item)
+	item.index = len(pq.states)
+	pq.states = append(pq.states, item)
 }
 
 func (pq *priorityQueue) Pop() interface{} {
-	old := *pq
+	old := pq.states
 	n := len(old)
 	item := old[n-1]
 	old[n-1] = nil  // avoid memory leak
 	item.index = -1 // for safety
-	*pq = old[0 : n-1]
+	pq.states = old[0 : n-1]
 	return item
 }
 
 // --- Override FindBestPaths ---
 
-// FindBestPaths finds the optimal quote paths
+// ErrPartialResult is returned with the paths found before a search's context ended.
+// The paths are valid, but better ones may not have been reached.
+var ErrPartialResult = errors.New("path search stopped early with partial results")
+
+// FindBestPaths finds the optimal quote paths with the algorithm set by SetPathAlgorithm. If ctx ends after at least one path was
+// found, those paths are returned with ErrPartialResult instead of the context's error.
 func (pf *PathFinder) FindBestPaths(ctx context.Context, tokenIn, tokenOut string, amountIn *big.Int, maxHops, maxPaths int) ([][]*types.Pool, error) {
+	return pf.dispatch(ctx, tokenIn, tokenOut, amountIn, maxHops, maxPaths, &pathSearch{})
+}
+
+// FindBestPathsForExchange is FindBestPaths through the pools of exchange alone. It
+// searches the same graph, skipping the pools of every other exchange.
+func (pf *PathFinder) FindBestPathsForExchange(ctx context.Context, tokenIn, tokenOut string, amountIn *big.Int, exchange string, maxHops, maxPaths int) ([][]*types.Pool, error) {
+	return pf.dispatch(ctx, tokenIn, tokenOut, amountIn, maxHops, maxPaths, &pathSearch{exchange: exchange})
+}
+
+// FindBestPathsAStar is FindBestPaths guided towards tokenOut: paths are expanded in
+// order of their output plus the best spot-price rate still reachable from their last
+// token, so paths that cannot reach tokenOut well are left unexplored. Spot rates never
+// undershoot a swap's rate, so it finds the same best path while expanding fewer.
+func (pf *PathFinder) FindBestPathsAStar(ctx context.Context, tokenIn, tokenOut string, amountIn *big.Int, maxHops, maxPaths int) ([][]*types.Pool, error) {
+	return pf.findBestPaths(ctx, tokenIn, tokenOut, amountIn, maxHops, maxPaths, &pathSearch{aStar: true})
+}
+
+// pathSearch configures a findBestPaths call and records how much work it did
+type pathSearch struct {
+	exchange string // Only route through this exchange's pools; empty allows every exchange
+	aStar    bool   // Guide the search with a spotHeuristic
+
+	excludedPools  map[*types.Pool]bool // Pools the paths may not take
+	excludedTokens map[string]bool      // Tokens the paths may not visit
+	nested         bool                 // Part of a larger search, which reports to the breaker itself
+
+	// onPathFound, when set, is called after each path to tokenOut is found. Tests use
+	// it to end the search at a known point.
+	onPathFound func()
+
+	expanded int // Paths whose next hops were explored
+}
+
+// excludes reports whether the search may not take pool to token
+func (s *pathSearch) excludes(pool *types.Pool, token string) bool {
+	if s.exchange != "" && !strings.EqualFold(pool.Exchange, s.exchange) {
+		return true
+	}
+	return s.excludedPools[pool] || s.excludedTokens[token]
+}
+
+// findBestPaths searches paths as configured by search
+func (pf *PathFinder) findBestPaths(ctx context.Context, tokenIn, tokenOut string, amountIn *big.Int, maxHops, maxPaths int, search *pathSearch) ([][]*types.Pool, error) {
 	if maxHops <= 0 {
 		maxHops = pf.maxHops
 	}
+	exchange := search.exchange
 
 	normalizedTokenIn := strings.ToLower(tokenIn)
 	normalizedTokenOut := strings.ToLower(tokenOut)
 
-	log.Printf("PathFinder: Searching best paths from %s to %s (amountIn: %s, maxHops: %d, maxPaths: %d)",
-		normalizedTokenIn, normalizedTokenOut, amountIn.String(), maxHops, maxPaths)
+	logger := applog.FromContext(ctx)
+	logger.Info("PathFinder: Searching best paths",
+		"from", normalizedTokenIn, "to", normalizedTokenOut, "amountIn", amountIn.String(), "maxHops", maxHops, "maxPaths", maxPaths, "exchange", exchange, "aStar", search.aStar)
 
 	// Change: Atomically load graph snapshot, remove RLock
 	g := pf.graph.Load()
 	if g == nil {
-		log.Println("PathFinder: Graph is not initialized")
+		logger.Info("PathFinder: Graph is not initialized")
 		return [][]*types.Pool{}, fmt.Errorf("graph not initialized")
 	}
 
 	// Change: Use 'g' (snapshot) instead of 'pf'
-	if g.adj[normalizedTokenIn] == nil {
-		log.Printf("PathFinder: TokenIn %s not found in graph", normalizedTokenIn)
+	if !g.hasToken(normalizedTokenIn) {
+		logger.Info("PathFinder: TokenIn not found in graph", "token", normalizedTokenIn)
 		return [][]*types.Pool{}, nil
 	}
-	if g.adj[normalizedTokenOut] == nil {
-		log.Printf("PathFinder: TokenOut %s not found in graph", normalizedTokenOut)
+	if !g.hasToken(normalizedTokenOut) {
+		logger.Info("PathFinder: TokenOut not found in graph", "token", normalizedTokenOut)
 		return [][]*types.Pool{}, nil
 	}
 
 	var bestPaths [][]*types.Pool
 
+	preferScore := pf.preferHighScore.Load()
+	maxConsecutive := int(pf.maxConsecutiveHopsPerDEX.Load())
+	staleness := pf.staleness.Load()
+
+	// outcomes records per exchange how its simulated hops went, for the breaker
+	outcomes := make(exchangeOutcomes)
+
+	// A* estimates the output each path could still reach; nil for plain Dijkstra
+	var heuristic *spotHeuristic
+	if search.aStar {
+		heuristic = newSpotHeuristic(g, normalizedTokenOut, exchange, maxHops)
+	}
+
 	// Initialize Dijkstra
-	// Priority queue, sorted by amountOut (max-heap)
-	pq := make(priorityQueue, 0)
-	heap.Init(&pq)
+	// Priority queue, sorted by amountOut (max-heap) with liquidity score breaking ties
+	pq := &priorityQueue{preferScore: preferScore, aStar: search.aStar}
+	heap.Init(pq)
 
-	// bestAmountPerToken records the highest output amount to reach a token, for pruning
-	bestAmountPerToken := make(map[string]*big.Int)
+	// bestStatePerToken records the highest-ranked state reaching a token, for pruning
+	bestStatePerToken := make(map[string]*pathState)
 
 	// Add all first-hop paths to the queue
 	// Iterate over all neighbors of tokenIn
 	// Change: Use 'g'
-	for neighborToken := range g.adj[normalizedTokenIn] {
+	for _, neighborToken := range g.adj[normalizedTokenIn] {
 		// Iterate over all pools between tokenIn and neighborToken
 		// Change: Use 'g'
-		for _, pool := range g.poolMap[normalizedTokenIn][neighborToken] {
+		for _, edge := range g.edgesBetween(normalizedTokenIn, neighborToken) {
+			pool := edge.pool
+			if search.excludes(pool, neighborToken) {
+				continue
+			}
 			// Simulate trade, calculate first hop output
-			hopAmountOut, err := pf.priceCalc.CalculateOutput(pool, amountIn, normalizedTokenIn)
+			hopAmountOut, err := pf.priceCalc.CalculateOutput(ctx, pool, amountIn, normalizedTokenIn)
 			if err != nil || hopAmountOut.Cmp(big.NewInt(0)) <= 0 {
+				outcomes.record(pool.Exchange, hopErrorOutcome(err))
 				continue // Invalid trade or no output
 			}
+			hopAmountOut, ok := staleness.adjustForStaleness(ctx, pool, hopAmountOut)
+			if !ok {
+				outcomes.record(pool.Exchange, outcomeFailed)
+				continue
+			}
+			outcomes.record(pool.Exchange, outcomeSucceeded)
 
 			newState := &pathState{
 				path:      []*types.Pool{pool},
 				amountOut: hopAmountOut,
 				lastToken: neighborToken,
+				score:     pool.LiquidityScore(),
+			}
+			// Paths that cannot reach tokenOut within maxHops are not worth a place in the queue
+			if heuristic != nil && !heuristic.estimate(newState, maxHops-1) {
+				continue
 			}
-			heap.Push(&pq, newState)
+			heap.Push(pq, newState)
 
-			if bestAmount, ok := bestAmountPerToken[neighborToken]; !ok || hopAmountOut.Cmp(bestAmount) > 0 {
-				bestAmountPerToken[neighborToken] = hopAmountOut
+			if best, ok := bestStatePerToken[neighborToken]; !ok || outranks(newState, best, preferScore) {
+				bestStatePerToken[neighborToken] = newState
 			}
 		}
 	}
 
 	// Start Dijkstra search
 	for pq.Len() > 0 && len(bestPaths) < maxPaths {
+		// Stop once the caller has gone away or the search timed out, keeping any paths found
+		if err := ctx.Err(); err != nil {
+			if len(bestPaths) == 0 {
+				return nil, err
+			}
+			logger.Warn("PathFinder: Search stopped early, returning partial paths", "count", len(bestPaths), "error", err)
+			return bestPaths, ErrPartialResult
+		}
+
 		// Pop the path with the current maximum amountOut
-		currentState := heap.Pop(&pq).(*pathState)
+		currentState := heap.Pop(pq).(*pathState)
 
 		// Check if it's a better path (pruning)
 		// If we previously found a better quote to this token via a shorter (or same length) path, skip
-		if bestAmount, ok := bestAmountPerToken[currentState.lastToken]; ok {
-			if currentState.amountOut.Cmp(bestAmount) < 0 {
+		if best, ok := bestStatePerToken[currentState.lastToken]; ok {
+			if worseOutput(currentState, best, preferScore) {
 				continue
 			}
 		}
@@ -271,6 +867,9 @@ func (pf *PathFinder) FindBestPaths(ctx context.Context, tokenIn, tokenOut strin
 		// Check if destination is reached
 		if currentState.lastToken == normalizedTokenOut {
 			bestPaths = append(bestPaths, currentState.path)
+			if search.onPathFound != nil {
+				search.onPathFound()
+			}
 			// Found a path, continue searching until maxPaths is met
 			continue
 		}
@@ -281,11 +880,12 @@ func (pf *PathFinder) FindBestPaths(ctx context.Context, tokenIn, tokenOut strin
 		}
 
 		// Explore neighbors (next hop)
+		search.expanded++
 		currentHopToken := currentState.lastToken
 		currentHopAmountIn := currentState.amountOut
 
 		// Change: Use 'g'
-		for nextHopToken := range g.adj[currentHopToken] {
+		for _, nextHopToken := range g.adj[currentHopToken] {
 			// Avoid loops (simple check)
 			if pf.pathContainsToken(currentState.path, nextHopToken) {
 				continue
@@ -293,38 +893,142 @@ func (pf *PathFinder) FindBestPaths(ctx context.Context, tokenIn, tokenOut strin
 
 			// Iterate over all pools between currentHopToken and nextHopToken
 			// Change: Use 'g'
-			for _, pool := range g.poolMap[currentHopToken][nextHopToken] {
+			for _, edge := range g.edgesBetween(currentHopToken, nextHopToken) {
+				pool := edge.pool
+				if search.excludes(pool, nextHopToken) {
+					continue
+				}
+
+				// Enforce routing diversity across exchanges
+				if maxConsecutive > 0 && trailingExchangeHops(currentState.path, pool.Exchange) >= maxConsecutive {
+					continue
+				}
 
 				// Simulate trade
-				nextHopAmountOut, err := pf.priceCalc.CalculateOutput(pool, currentHopAmountIn, currentHopToken)
+				nextHopAmountOut, err := pf.priceCalc.CalculateOutput(ctx, pool, currentHopAmountIn, currentHopToken)
 				if err != nil || nextHopAmountOut.Cmp(big.NewInt(0)) <= 0 {
+					outcomes.record(pool.Exchange, hopErrorOutcome(err))
+					continue
+				}
+				nextHopAmountOut, ok := staleness.adjustForStaleness(ctx, pool, nextHopAmountOut)
+				if !ok {
+					outcomes.record(pool.Exchange, outcomeFailed)
+					continue
+				}
+				outcomes.record(pool.Exchange, outcomeSucceeded)
+
+				newState := &pathState{
+					amountOut: nextHopAmountOut,
+					lastToken: nextHopToken,
+					score:     math.Min(currentState.score, pool.LiquidityScore()),
+				}
+				if heuristic != nil && !heuristic.estimate(newState, maxHops-len(currentState.path)-1) {
 					continue
 				}
 
 				// Check if this is a better path to nextHopToken
-				if bestAmount, ok := bestAmountPerToken[nextHopToken]; !ok || nextHopAmountOut.Cmp(bestAmount) > 0 {
-					bestAmountPerToken[nextHopToken] = nextHopAmountOut
+				if best, ok := bestStatePerToken[nextHopToken]; !ok || outranks(newState, best, preferScore) {
+					bestStatePerToken[nextHopToken] = newState
 
 					// Create new path
 					newPath := make([]*types.Pool, len(currentState.path)+1)
 					copy(newPath, currentState.path)
 					newPath[len(newPath)-1] = pool
 
-					newState := &pathState{
-						path:      newPath,
-						amountOut: nextHopAmountOut,
-						lastToken: nextHopToken,
-					}
-					heap.Push(&pq, newState)
+					newState.path = newPath
+					heap.Push(pq, newState)
 				}
 			}
 		}
 	}
 
-	log.Printf("PathFinder: Found %d best paths.", len(bestPaths))
+	if !search.nested {
+		pf.recordExchangeOutcomes(outcomes)
+	}
+
+	logger.Info("PathFinder: Found best paths", "count", len(bestPaths))
 	return bestPaths, nil
 }
 
+// exchangeOutcome is what a search learned about the health of an exchange. Outcomes
+// are ordered so that a later hop can only raise an exchange's outcome.
+type exchangeOutcome int
+
+const (
+	// outcomeUnknown: hops failed only for reasons of the trade, such as its size
+	outcomeUnknown exchangeOutcome = iota
+	// outcomeFailed: a hop failed because of the exchange's data, such as stale reserves
+	outcomeFailed
+	// outcomeSucceeded: a hop through the exchange succeeded
+	outcomeSucceeded
+)
+
+// exchangeOutcomes maps exchange names to their outcome in a search
+type exchangeOutcomes map[string]exchangeOutcome
+
+// record raises the outcome of exchange to outcome
+func (o exchangeOutcomes) record(exchange string, outcome exchangeOutcome) {
+	if current, ok := o[exchange]; !ok || outcome > current {
+		o[exchange] = outcome
+	}
+}
+
+// hopErrorOutcome classifies a failed hop. Hops too large for the pool and empty
+// pools fail because of the amount quoted, which any client controls, so only other
+// errors count against the exchange.
+func hopErrorOutcome(err error) exchangeOutcome {
+	if err == nil || isInputError(err) {
+		return outcomeUnknown
+	}
+	return outcomeFailed
+}
+
+// isInputError reports whether err is caused by the quoted trade rather than the pool
+func isInputError(err error) bool {
+	return errors.Is(err, ErrSlippageTooHigh)
+}
+
+// recordExchangeOutcomes reports a search to the breaker: an exchange fails the search
+// only if a hop failed because of it and none of its hops succeeded. Exchanges whose
+// hops only failed for the trade's size are not reported.
+func (pf *PathFinder) recordExchangeOutcomes(outcomes exchangeOutcomes) {
+	breaker := pf.breaker.Load()
+	if breaker == nil {
+		return
+	}
+	for exchange, outcome := range outcomes {
+		switch outcome {
+		case outcomeSucceeded:
+			breaker.RecordSuccess(exchange)
+		case outcomeFailed:
+			pf.recordExchangeFailure(breaker, exchange)
+		}
+	}
+}
+
+// recordExchangeFailure counts a failure against exchange. When that opens its breaker
+// the graph is rebuilt without the exchange, and again once the exchange recovers.
+func (pf *PathFinder) recordExchangeFailure(breaker *circuitbreaker.ExchangeBreaker, exchange string) {
+	if !breaker.RecordFailure(exchange) {
+		return
+	}
+	pf.RefreshGraphAsync()
+	time.AfterFunc(breaker.RecoveryPeriod, func() {
+		if pf.ctx.Err() == nil {
+			pf.RefreshGraphAsync()
+		}
+	})
+}
+
+// trailingExchangeHops counts the pools at the end of path that belong to exchange
+func trailingExchangeHops(path []*types.Pool, exchange string) int {
+	count := 0
+	for i := len(path) - 1; i >= 0 && strings.EqualFold(path[i].Exchange, exchange); i-- {
+		count++
+	}
+	return count
+}
+
 // Helper function: check if path already contains a token (avoid loops)
 func (pf *PathFinder) pathContainsToken(path []*types.Pool, token string) bool {
 	// Simple loop check: check if the new token is already in the path (as either end of a pool)
@@ -0,0 +1,1036 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"dex-aggregator/config"
+	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/testutil"
+	"dex-aggregator/internal/types"
+	"dex-aggregator/internal/validation"
+	"dex-aggregator/internal/volume"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// generatePools returns n pools spread over a fixed token set so most token pairs
+// hold several pools, as on a real chain
+func generatePools(n int) []*types.Pool {
+	const tokenCount = 200
+
+	pools := make([]*types.Pool, n)
+	for i := 0; i < n; i++ {
+		t0 := i % tokenCount
+		t1 := (i*7 + 1 + i/tokenCount) % tokenCount
+		if t1 == t0 {
+			t1 = (t1 + 1) % tokenCount
+		}
+		pools[i] = &types.Pool{
+			Address:  fmt.Sprintf("pool-%d", i),
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: fmt.Sprintf("0xtoken%03d", t0)},
+			Token1:   types.Token{Address: fmt.Sprintf("0xtoken%03d", t1)},
+			Reserve0: big.NewInt(int64(1000000 + i)),
+			Reserve1: big.NewInt(int64(2000000 + i)),
+		}
+	}
+	return pools
+}
+
+func TestBuildGraphParallel_MatchesSequential(t *testing.T) {
+	pools := generatePools(10000)
+
+	sequential := buildGraphParallel(pools, 1)
+	for _, workers := range []int{2, 3, 8, 64} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			parallel := buildGraphParallel(pools, workers)
+
+			assert.Equal(t, sequential.adj, parallel.adj)
+			assert.Equal(t, sequential.edges, parallel.edges)
+			assert.Equal(t, sequential.edgeIndex, parallel.edgeIndex)
+		})
+	}
+}
+
+func TestBuildGraphParallel_SmallPoolSet(t *testing.T) {
+	pools := generatePools(10)
+
+	g := buildGraphParallel(pools, 8)
+	assert.Equal(t, buildGraphParallel(pools, 1).edges, g.edges)
+	assert.Empty(t, buildGraphParallel(nil, 8).adj)
+}
+
+func TestGraphData_Lookups(t *testing.T) {
+	pools := []*types.Pool{
+		{Address: "a-b-1", Token0: types.Token{Address: "0xTokenA"}, Token1: types.Token{Address: "0xtokenb"}, Reserve0: big.NewInt(10), Reserve1: big.NewInt(20)},
+		{Address: "c-a", Token0: types.Token{Address: "0xtokenc"}, Token1: types.Token{Address: "0xtokena"}, Reserve0: big.NewInt(1), Reserve1: big.NewInt(1)},
+		{Address: "a-b-2", Token0: types.Token{Address: "0xtokena"}, Token1: types.Token{Address: "0xtokenb"}, Reserve0: big.NewInt(5), Reserve1: big.NewInt(2)},
+	}
+	g := buildGraphParallel(pools, 1)
+
+	assert.Equal(t, []string{"0xtokenb", "0xtokenc"}, g.adj["0xtokena"])
+	assert.True(t, g.hasToken("0xtokenc"))
+	assert.False(t, g.hasToken("0xtokend"))
+	assert.True(t, g.hasEdge("0xtokenb", "0xtokena"))
+	assert.False(t, g.hasEdge("0xtokenb", "0xtokenc"))
+
+	edges := g.edgesBetween("0xtokena", "0xtokenb")
+	if assert.Len(t, edges, 2) {
+		assert.Equal(t, "a-b-1", edges[0].pool.Address)
+		assert.Equal(t, "a-b-2", edges[1].pool.Address)
+	}
+	assert.Empty(t, g.edgesBetween("0xtokenb", "0xtokenc"))
+}
+
+func BenchmarkRefreshGraph_10k(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ctx := context.Background()
+	store := cache.NewMemoryStore()
+	for _, pool := range generatePools(10000) {
+		if err := store.StorePool(ctx, pool); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for _, bc := range []struct {
+		name    string
+		workers int
+	}{
+		{"Sequential", 1},
+		{"Parallel", runtime.NumCPU()},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			pf := newPathFinder(ctx, store, NewPriceCalculator())
+			pf.buildWorkers = bc.workers
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := pf.RefreshGraph(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// nestedMapGraph is the nested-map graph layout graphData replaced, kept as the
+// baseline for BenchmarkFindBestPaths_Memory
+type nestedMapGraph struct {
+	adj          map[string]map[string]bool
+	poolMap      map[string]map[string][]*types.Pool
+	liquidityMap map[string]map[string]*big.Int
+}
+
+func buildNestedMapGraph(pools []*types.Pool) *nestedMapGraph {
+	g := &nestedMapGraph{
+		adj:          make(map[string]map[string]bool),
+		poolMap:      make(map[string]map[string][]*types.Pool),
+		liquidityMap: make(map[string]map[string]*big.Int),
+	}
+	addEdge := func(from, to string, pool *types.Pool, liquidity *big.Int) {
+		if g.adj[from] == nil {
+			g.adj[from] = make(map[string]bool)
+			g.poolMap[from] = make(map[string][]*types.Pool)
+			g.liquidityMap[from] = make(map[string]*big.Int)
+		}
+		g.adj[from][to] = true
+		g.poolMap[from][to] = append(g.poolMap[from][to], pool)
+		if existing, ok := g.liquidityMap[from][to]; ok {
+			g.liquidityMap[from][to] = new(big.Int).Add(existing, liquidity)
+		} else {
+			g.liquidityMap[from][to] = new(big.Int).Set(liquidity)
+		}
+	}
+	for _, pool := range pools {
+		t0 := strings.ToLower(pool.Token0.Address)
+		t1 := strings.ToLower(pool.Token1.Address)
+		liquidity := new(big.Int).Mul(pool.Reserve0, pool.Reserve1)
+		addEdge(t0, t1, pool, liquidity)
+		addEdge(t1, t0, pool, liquidity)
+	}
+	return g
+}
+
+// retainedHeap returns the live heap growth caused by build, after garbage collection
+func retainedHeap(build func() interface{}) int64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	result := build()
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(result)
+	return int64(after.HeapAlloc) - int64(before.HeapAlloc)
+}
+
+func BenchmarkFindBestPaths_Memory(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ctx := context.Background()
+	pools := generatePools(5000)
+	store := cache.NewMemoryStore()
+	for _, pool := range pools {
+		if err := store.StorePool(ctx, pool); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	var oldBytes, newBytes int64
+	for i := 0; i < b.N; i++ {
+		oldBytes = retainedHeap(func() interface{} {
+			return buildNestedMapGraph(pools)
+		})
+		newBytes = retainedHeap(func() interface{} {
+			pf := newPathFinder(ctx, store, NewPriceCalculator())
+			pf.buildWorkers = 1
+			if err := pf.RefreshGraph(ctx); err != nil {
+				b.Fatal(err)
+			}
+			return pf
+		})
+	}
+
+	b.ReportMetric(float64(oldBytes), "nested-map-B")
+	b.ReportMetric(float64(newBytes), "graph-B")
+	if float64(newBytes) >= 0.8*float64(oldBytes) {
+		b.Fatalf("graph retains %d bytes, not under 80%% of the nested-map layout's %d", newBytes, oldBytes)
+	}
+}
+
+func scoredPathFinder(t *testing.T, pools []*types.Pool) *PathFinder {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	pf := newPathFinder(context.Background(), cache.NewMemoryStore(), NewPriceCalculator())
+	pf.graph.Store(buildGraphParallel(pools, 1))
+	return pf
+}
+
+func TestPathFinder_PrefersFresherPool(t *testing.T) {
+	now := time.Now()
+	pool := testutil.NewPool().
+		WithTokenAddresses("0xtokena", "0xtokenb").
+		WithReserves(big.NewInt(1000000000), big.NewInt(2000000000))
+	stale := pool.WithAddress("stale-pool").WithLastUpdated(now.Add(-50 * time.Minute)).Build()
+	fresh := pool.WithAddress("fresh-pool").WithLastUpdated(now.Add(-time.Minute)).Build()
+
+	// The stale pool comes first in the graph, so only the score can put the fresh one ahead
+	pf := scoredPathFinder(t, []*types.Pool{stale, fresh})
+
+	paths, err := pf.FindBestPaths(context.Background(), "0xtokena", "0xtokenb", big.NewInt(1000000), 3, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, paths, 2) {
+		assert.Equal(t, "fresh-pool", paths[0][0].Address)
+		assert.Equal(t, "stale-pool", paths[1][0].Address)
+	}
+}
+
+func TestPathFinder_PreferHighScorePools(t *testing.T) {
+	now := time.Now()
+	pool := testutil.NewPool().WithTokenAddresses("0xtokena", "0xtokenb")
+	// Slightly deeper, so it gives marginally more output, but almost an hour old
+	stale := pool.WithAddress("stale-pool").
+		WithReserves(big.NewInt(1000000000), big.NewInt(2001000000)).
+		WithLastUpdated(now.Add(-50 * time.Minute)).
+		Build()
+	fresh := pool.WithAddress("fresh-pool").
+		WithReserves(big.NewInt(1000000000), big.NewInt(2000000000)).
+		WithLastUpdated(now.Add(-time.Minute)).
+		Build()
+
+	pf := scoredPathFinder(t, []*types.Pool{stale, fresh})
+
+	paths, err := pf.FindBestPaths(context.Background(), "0xtokena", "0xtokenb", big.NewInt(1000000), 3, 10)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, paths) {
+		assert.Equal(t, "stale-pool", paths[0][0].Address, "higher output wins by default")
+	}
+
+	pf.SetPreferHighScorePools(true)
+	paths, err = pf.FindBestPaths(context.Background(), "0xtokena", "0xtokenb", big.NewInt(1000000), 3, 10)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, paths) {
+		assert.Equal(t, "fresh-pool", paths[0][0].Address, "near-equal output defers to the fresher pool")
+	}
+}
+
+func TestPathFinder_TokenFilter(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	pool := func(address, token0, token1 string, reserve int64) *types.Pool {
+		return &types.Pool{
+			Address:  address,
+			Token0:   types.Token{Address: token0},
+			Token1:   types.Token{Address: token1},
+			Reserve0: big.NewInt(reserve),
+			Reserve1: big.NewInt(reserve),
+		}
+	}
+	// A reaches C through B, the deeper route, or through D
+	pools := []*types.Pool{
+		pool("a-b", "0xtokena", "0xtokenb", 1000000000),
+		pool("b-c", "0xtokenb", "0xtokenc", 1000000000),
+		pool("a-d", "0xtokena", "0xtokend", 100000000),
+		pool("d-c", "0xtokend", "0xtokenc", 100000000),
+	}
+
+	testCases := []struct {
+		name        string
+		allowed     []string
+		denied      []string
+		expectedVia string // intermediate token of the best path, empty for no path
+	}{
+		{"No filter", nil, nil, "0xtokenb"},
+		{"Denied token", nil, []string{"0xTokenB"}, "0xtokend"},
+		{"Allowlist", []string{"0xtokena", "0xtokenc", "0xtokend"}, nil, "0xtokend"},
+		{"Denied wins over allowed", []string{"0xtokena", "0xtokenc", "0xtokend"}, []string{"0xtokend"}, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			mockStore.On("GetAllPools", mock.Anything).Return(pools, nil)
+
+			pf := newPathFinder(context.Background(), mockStore, NewPriceCalculator())
+			pf.SetTokenFilter(tc.allowed, tc.denied)
+			assert.NoError(t, pf.RefreshGraph(context.Background()))
+
+			paths, err := pf.FindBestPaths(context.Background(), "0xtokena", "0xtokenc", big.NewInt(1000), 3, 10)
+			assert.NoError(t, err)
+
+			if tc.expectedVia == "" {
+				assert.Empty(t, paths)
+				return
+			}
+			if assert.NotEmpty(t, paths) {
+				assert.Len(t, paths[0], 2)
+				assert.Equal(t, tc.expectedVia, paths[0][0].Token1.Address)
+			}
+			for _, path := range paths {
+				for _, p := range path {
+					for _, denied := range tc.denied {
+						assert.NotEqual(t, strings.ToLower(denied), p.Token0.Address, "path through %s", p.Address)
+						assert.NotEqual(t, strings.ToLower(denied), p.Token1.Address, "path through %s", p.Address)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPathFinder_RefreshAttachesVolume(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	cached := testutil.NewPool().
+		WithAddress("a-b").
+		WithTokenAddresses("0xtokena", "0xtokenb").
+		WithReserves(big.NewInt(1000000000), big.NewInt(1000000000)).
+		Build()
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{cached}, nil)
+
+	volumes := volume.NewAccumulator()
+	volumes.RecordSwap("a-b", big.NewInt(4200), time.Now())
+
+	pf := newPathFinder(context.Background(), mockStore, NewPriceCalculator())
+	pf.SetVolumeAccumulator(volumes)
+	assert.NoError(t, pf.RefreshGraph(context.Background()))
+
+	paths, err := pf.FindBestPaths(context.Background(), "0xtokena", "0xtokenb", big.NewInt(1000), 1, 10)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, paths) {
+		assert.Equal(t, "4200", paths[0][0].Volume24h.String())
+	}
+	assert.Nil(t, cached.Volume24h, "cached pool must not be modified")
+}
+
+func TestPathFinder_SkipsInvalidPools(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	pool := testutil.NewPool().WithTokenAddresses("0xtokena", "0xtokenb")
+	store := testutil.NewMemStoreWithPools(
+		pool.WithAddress("drained").WithReserves(big.NewInt(1000), big.NewInt(1000)).Build(),
+		pool.WithAddress("healthy").WithReserves(big.NewInt(100000000), big.NewInt(100000000)).Build(),
+	)
+
+	minLiquidity, err := validation.NewMinLiquidityValidator("1000000000000")
+	assert.NoError(t, err)
+	pf := newPathFinder(context.Background(), store, NewPriceCalculator())
+	pf.SetPoolValidators(minLiquidity)
+	assert.NoError(t, pf.RefreshGraph(context.Background()))
+
+	paths, err := pf.FindBestPaths(context.Background(), "0xtokena", "0xtokenb", big.NewInt(1000), 3, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, paths, 1) {
+		assert.Equal(t, "healthy", paths[0][0].Address)
+	}
+}
+
+func TestPathFinder_SkipsPausedPools(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	pool := testutil.NewPool().WithTokenAddresses("0xtokena", "0xtokenb")
+	store := testutil.NewMemStoreWithPools(
+		pool.WithAddress("deep").WithReserves(big.NewInt(1000000000), big.NewInt(1000000000)).WithPaused(true).Build(),
+		pool.WithAddress("shallow").WithReserves(big.NewInt(100000000), big.NewInt(100000000)).WithPaused(false).Build(),
+	)
+
+	pf := newPathFinder(context.Background(), store, NewPriceCalculator())
+	assert.NoError(t, pf.RefreshGraph(context.Background()))
+
+	paths, err := pf.FindBestPaths(context.Background(), "0xtokena", "0xtokenb", big.NewInt(1000), 3, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, paths, 1) {
+		assert.Equal(t, "shallow", paths[0][0].Address)
+	}
+}
+
+func TestPathFinder_FindBestPathsForExchange(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	reserves := testutil.NewPool().WithReserves(big.NewInt(1000000000), big.NewInt(1000000000))
+	store := testutil.NewMemStoreWithPools(
+		reserves.WithAddress("uni-ab").WithTokenAddresses("0xtokena", "0xtokenb").Build(),
+		reserves.WithAddress("uni-bc").WithTokenAddresses("0xtokenb", "0xtokenc").Build(),
+		reserves.WithAddress("sushi-ab").WithTokenAddresses("0xtokena", "0xtokenb").WithExchange("SushiSwap").Build(),
+	)
+
+	pf := newPathFinder(context.Background(), store, NewPriceCalculator())
+	assert.NoError(t, pf.RefreshGraph(context.Background()))
+
+	testCases := []struct {
+		name     string
+		exchange string
+		tokenOut string
+		expected [][]string // pool addresses of each path
+	}{
+		{"Direct through SushiSwap", "sushiswap", "0xtokenb", [][]string{{"sushi-ab"}}},
+		{"Direct through Uniswap V2", "Uniswap V2", "0xtokenb", [][]string{{"uni-ab"}}},
+		{"Two hops through Uniswap V2", "Uniswap V2", "0xtokenc", [][]string{{"uni-ab", "uni-bc"}}},
+		// SushiSwap has no 0xtokenb -> 0xtokenc pool
+		{"No route through SushiSwap", "SushiSwap", "0xtokenc", nil},
+		{"Unknown exchange", "Curve", "0xtokenb", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			paths, err := pf.FindBestPathsForExchange(context.Background(), "0xtokena", tc.tokenOut, big.NewInt(1000), tc.exchange, 3, 10)
+			assert.NoError(t, err)
+
+			var addresses [][]string
+			for _, path := range paths {
+				var pathAddresses []string
+				for _, pool := range path {
+					pathAddresses = append(pathAddresses, pool.Address)
+				}
+				addresses = append(addresses, pathAddresses)
+			}
+			assert.Equal(t, tc.expected, addresses)
+		})
+	}
+}
+
+// longChainPools returns a deep 0xtokena -> 0xtokenb pool paying twice the input, and a
+// chain of n pools from 0xtokena through tokens that lead nowhere. A search with enough
+// hops finds the direct path first, then walks the whole chain looking for more.
+func longChainPools(n int) []*types.Pool {
+	deep, _ := new(big.Int).SetString("1000000000000000000000000000000", 10)
+	pools := []*types.Pool{
+		testutil.NewPool().WithAddress("direct").WithTokenAddresses("0xtokena", "0xtokenb").
+			WithReserves(deep, new(big.Int).Mul(deep, big.NewInt(2))).Build(),
+	}
+
+	link := testutil.NewPool().WithReserves(deep, deep)
+	previous := "0xtokena"
+	for i := 0; i < n; i++ {
+		next := fmt.Sprintf("0xchain%05d", i)
+		pools = append(pools, link.WithAddress(fmt.Sprintf("chain-%d", i)).WithTokenAddresses(previous, next).Build())
+		previous = next
+	}
+	return pools
+}
+
+func TestPathFinder_PartialResultOnTimeout(t *testing.T) {
+	const chainLength = 10
+	pf := scoredPathFinder(t, longChainPools(chainLength))
+	amountIn := big.NewInt(1000000000000000)
+
+	// The direct path is found first; the search ends right after it, with the chain
+	// still queued
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	paths, err := pf.findBestPaths(ctx, "0xtokena", "0xtokenb", amountIn, chainLength, 10, &pathSearch{onPathFound: cancel})
+	assert.ErrorIs(t, err, ErrPartialResult)
+	if assert.Len(t, paths, 1) {
+		assert.Equal(t, "direct", paths[0][0].Address)
+	}
+
+	// Without a path found yet, the context's error is returned
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	paths, err = pf.FindBestPaths(ctx, "0xtokena", "0xtokenb", amountIn, chainLength, 10)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, paths)
+}
+
+func TestPathFinder_MaxConsecutiveHopsPerDEX(t *testing.T) {
+	// Every hop of 0xtokena -> 0xtokenb -> 0xtokenc -> 0xtokend has a deep Uniswap V2 pool
+	// and a shallower SushiSwap pool, so the unrestricted best path stays on Uniswap V2
+	tokens := []string{"0xtokena", "0xtokenb", "0xtokenc", "0xtokend"}
+	var pools []*types.Pool
+	for i := 0; i < len(tokens)-1; i++ {
+		for exchange, reserve := range map[string]int64{"Uniswap V2": 1000000000, "SushiSwap": 500000000} {
+			pools = append(pools, &types.Pool{
+				Address:  fmt.Sprintf("%s-%d", exchange, i),
+				Exchange: exchange,
+				Token0:   types.Token{Address: tokens[i]},
+				Token1:   types.Token{Address: tokens[i+1]},
+				Reserve0: big.NewInt(reserve),
+				Reserve1: big.NewInt(reserve),
+			})
+		}
+	}
+
+	// longestRun returns the most consecutive pools from one exchange in path
+	longestRun := func(path []*types.Pool) int {
+		longest, run := 0, 0
+		for i, pool := range path {
+			if i > 0 && pool.Exchange == path[i-1].Exchange {
+				run++
+			} else {
+				run = 1
+			}
+			longest = max(longest, run)
+		}
+		return longest
+	}
+
+	testCases := []struct {
+		name        string
+		limit       int
+		expectedRun int // longest same-exchange run in the best path
+	}{
+		{"Unlimited", 0, 3},
+		{"Limit 1", 1, 1},
+		{"Limit 2", 2, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pf := scoredPathFinder(t, pools)
+			pf.SetMaxConsecutiveHopsPerDEX(tc.limit)
+
+			paths, err := pf.FindBestPaths(context.Background(), "0xtokena", "0xtokend", big.NewInt(1000000), 3, 10)
+			assert.NoError(t, err)
+			if !assert.NotEmpty(t, paths) {
+				return
+			}
+
+			assert.Equal(t, tc.expectedRun, longestRun(paths[0]))
+			if tc.limit > 0 {
+				for _, path := range paths {
+					assert.LessOrEqual(t, longestRun(path), tc.limit)
+				}
+			}
+		})
+	}
+}
+
+func TestPathFinder_StaleReserves(t *testing.T) {
+	pool := testutil.NewPool().
+		WithTokenAddresses("0xtokena", "0xtokenb").
+		WithReserves(big.NewInt(1000000000), big.NewInt(2000000000))
+	stale := pool.WithAddress("stale-pool").WithReserveUpdatedAt(time.Now().Add(-10 * time.Minute)).Build()
+	fresh := pool.WithAddress("fresh-pool").WithReserveUpdatedAt(time.Now()).Build()
+	unknown := pool.WithAddress("unknown-pool").WithReserveUpdatedAt(time.Time{}).Build()
+
+	addresses := func(paths [][]*types.Pool) []string {
+		var out []string
+		for _, path := range paths {
+			out = append(out, path[0].Address)
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	t.Run("Stale pool skipped", func(t *testing.T) {
+		pf := scoredPathFinder(t, []*types.Pool{stale, fresh, unknown})
+		pf.SetReserveStaleness(config.DEXConfig{MaxReserveAgeSecs: 300})
+
+		paths, err := pf.FindBestPaths(context.Background(), "0xtokena", "0xtokenb", big.NewInt(1000000), 3, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"fresh-pool", "unknown-pool"}, addresses(paths))
+	})
+
+	t.Run("Only stale pools", func(t *testing.T) {
+		pf := scoredPathFinder(t, []*types.Pool{stale})
+		pf.SetReserveStaleness(config.DEXConfig{MaxReserveAgeSecs: 300})
+
+		paths, err := pf.FindBestPaths(context.Background(), "0xtokena", "0xtokenb", big.NewInt(1000000), 3, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, paths)
+	})
+
+	t.Run("Check disabled", func(t *testing.T) {
+		pf := scoredPathFinder(t, []*types.Pool{stale})
+		pf.SetReserveStaleness(config.DEXConfig{MaxReserveAgeSecs: -1})
+
+		paths, err := pf.FindBestPaths(context.Background(), "0xtokena", "0xtokenb", big.NewInt(1000000), 3, 10)
+		assert.NoError(t, err)
+		assert.Len(t, paths, 1)
+	})
+
+	t.Run("Default config", func(t *testing.T) {
+		// Mock pools are stored once and never refreshed, so with the default config
+		// quotes must keep working once their reserves are older than any max age
+		original := config.Current()
+		defer config.Set(original)
+		t.Setenv("DEX_MAX_RESERVE_AGE_SECS", "")
+		assert.NoError(t, config.InitFromFile(filepath.Join(t.TempDir(), "missing.yaml")))
+
+		mockStore := new(MockStore)
+		mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{stale}, nil)
+		router := NewRouter(context.Background(), mockStore, config.Current().Performance)
+		resp, err := router.GetBestQuote(context.Background(), &types.QuoteRequest{
+			TokenIn: "0xtokena", TokenOut: "0xtokenb", AmountIn: big.NewInt(1000000), MaxHops: 3,
+		})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "stale-pool", resp.BestPath.Pools[0].Address)
+		}
+	})
+
+	t.Run("Stale pool penalised", func(t *testing.T) {
+		// The stale pool comes first and would rank first on output alone
+		pf := scoredPathFinder(t, []*types.Pool{stale, fresh})
+		pf.SetReserveStaleness(config.DEXConfig{MaxReserveAgeSecs: 300, StaleReservePenalty: 0.01})
+
+		paths, err := pf.FindBestPaths(context.Background(), "0xtokena", "0xtokenb", big.NewInt(1000000), 3, 10)
+		assert.NoError(t, err)
+		if assert.NotEmpty(t, paths) {
+			assert.Equal(t, "fresh-pool", paths[0][0].Address)
+		}
+	})
+}
+
+func TestPathFinder_MaxPoolsPerPair(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	pool := func(address, token0, token1 string, reserve int64) *types.Pool {
+		return &types.Pool{
+			Address:  address,
+			Token0:   types.Token{Address: token0},
+			Token1:   types.Token{Address: token1},
+			Reserve0: big.NewInt(reserve),
+			Reserve1: big.NewInt(reserve),
+		}
+	}
+	pools := []*types.Pool{
+		pool("ab-small", "0xtokena", "0xtokenb", 1000),
+		pool("ab-deep", "0xtokena", "0xtokenb", 1000000),
+		pool("ba-medium", "0xtokenb", "0xtokena", 10000),
+		pool("ab-tiny", "0xtokena", "0xtokenb", 10),
+		pool("bc", "0xtokenb", "0xtokenc", 10),
+	}
+
+	pf := newPathFinder(context.Background(), new(MockStore), NewPriceCalculator())
+	kept := pf.filterPoolsByLiquidity(pools[:4], 2)
+	if assert.Len(t, kept, 2) {
+		assert.Equal(t, "ab-deep", kept[0].Address)
+		assert.Equal(t, "ba-medium", kept[1].Address)
+	}
+	assert.Len(t, pf.filterPoolsByLiquidity(pools[:4], 0), 4)
+
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return(pools, nil)
+	pf = newPathFinder(context.Background(), mockStore, NewPriceCalculator())
+
+	// Both token orders count as the same pair; the shallow b-c pool is alone in its pair
+	pf.SetMaxPoolsPerPair(2)
+	assert.NoError(t, pf.RefreshGraph(context.Background()))
+	g := pf.graph.Load()
+	var addresses []string
+	for _, edge := range g.edgesBetween("0xtokena", "0xtokenb") {
+		addresses = append(addresses, edge.pool.Address)
+	}
+	assert.ElementsMatch(t, []string{"ab-deep", "ba-medium"}, addresses)
+	assert.Len(t, g.edgesBetween("0xtokenb", "0xtokenc"), 1)
+
+	pf.SetMaxPoolsPerPair(-1)
+	assert.NoError(t, pf.RefreshGraph(context.Background()))
+	assert.Len(t, pf.graph.Load().edgesBetween("0xtokena", "0xtokenb"), 4)
+}
+
+// generatePairPools returns poolsPerPair pools of increasing depth for each pair of a
+// ring of tokens with chords, so that every pair has many competing pools
+func generatePairPools(tokenCount, poolsPerPair int) []*types.Pool {
+	var pools []*types.Pool
+	for i := 0; i < tokenCount; i++ {
+		for _, j := range []int{(i + 1) % tokenCount, (i + 7) % tokenCount} {
+			for k := 0; k < poolsPerPair; k++ {
+				reserve := int64(1000000000) << k
+				pools = append(pools, &types.Pool{
+					Address:  fmt.Sprintf("pool-%d-%d-%d", i, j, k),
+					Exchange: "Uniswap V2",
+					Token0:   types.Token{Address: fmt.Sprintf("0xtoken%03d", i)},
+					Token1:   types.Token{Address: fmt.Sprintf("0xtoken%03d", j)},
+					Reserve0: big.NewInt(reserve),
+					Reserve1: big.NewInt(reserve),
+				})
+			}
+		}
+	}
+	return pools
+}
+
+func BenchmarkFindBestPaths_MaxPoolsPerPair(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ctx := context.Background()
+	pools := generatePairPools(50, 10)
+
+	for _, bc := range []struct {
+		name     string
+		maxPools int
+	}{
+		{"Unpruned", 0},
+		{"Top3", 3},
+		{"Top1", 1},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			pf := newPathFinder(ctx, new(MockStore), NewPriceCalculator())
+			pf.SetMaxPoolsPerPair(bc.maxPools)
+			pf.graph.Store(pf.buildGraph(pools))
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := pf.FindBestPaths(ctx, "0xtoken000", "0xtoken015", big.NewInt(1000000), 3, 20); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// twentyTokenPools connects 20 tokens of similar value, each pair by a pool with a
+// slightly different price, so many token sequences compete for the best route
+func twentyTokenPools() []*types.Pool {
+	deep, _ := new(big.Int).SetString("1000000000000000000000000", 10)
+	var pools []*types.Pool
+	for i := 0; i < 20; i++ {
+		for j := i + 1; j < 20; j++ {
+			if (i*7+j*3)%4 == 0 {
+				continue // Leave some pairs unconnected
+			}
+			// Prices within 5% of parity, varying deterministically by pair
+			skew := big.NewInt(int64(9500 + (i*37+j*91)%1000))
+			reserve1 := new(big.Int).Div(new(big.Int).Mul(deep, skew), big.NewInt(10000))
+			pools = append(pools, testutil.NewPool().
+				WithAddress(fmt.Sprintf("pool-%02d-%02d", i, j)).
+				WithTokenAddresses(fmt.Sprintf("0xtoken%02d", i), fmt.Sprintf("0xtoken%02d", j)).
+				WithReserves(deep, reserve1).
+				Build())
+		}
+	}
+	return pools
+}
+
+// bestPathOutput returns the largest output of any loop-free path of at most maxHops
+// pools from tokenIn to tokenOut, found by trying them all
+func bestPathOutput(ctx context.Context, pf *PathFinder, tokenIn, tokenOut string, amountIn *big.Int, maxHops int) *big.Int {
+	g := pf.graph.Load()
+	best := big.NewInt(0)
+	visited := map[string]bool{tokenIn: true}
+	var walk func(token string, amount *big.Int, hops int)
+	walk = func(token string, amount *big.Int, hops int) {
+		if token == tokenOut {
+			if amount.Cmp(best) > 0 {
+				best = amount
+			}
+			return
+		}
+		if hops == maxHops {
+			return
+		}
+		for _, next := range g.adj[token] {
+			if visited[next] {
+				continue
+			}
+			for _, edge := range g.edgesBetween(token, next) {
+				out, err := pf.priceCalc.CalculateOutput(ctx, edge.pool, amount, token)
+				if err != nil || out.Sign() <= 0 {
+					continue
+				}
+				visited[next] = true
+				walk(next, out, hops+1)
+				visited[next] = false
+			}
+		}
+	}
+	walk(tokenIn, amountIn, 0)
+	return best
+}
+
+func TestPathFinder_FindBestPathsAStar(t *testing.T) {
+	pf := scoredPathFinder(t, twentyTokenPools())
+	ctx := context.Background()
+	amountIn := big.NewInt(1000000000000000000)
+
+	for _, maxHops := range []int{1, 2, 3} {
+		t.Run(fmt.Sprintf("%d hops", maxHops), func(t *testing.T) {
+			best := bestPathOutput(ctx, pf, "0xtoken00", "0xtoken19", amountIn, maxHops)
+
+			// A* stops at the first path to tokenOut, which is the best one
+			aStar := &pathSearch{aStar: true}
+			paths, err := pf.findBestPaths(ctx, "0xtoken00", "0xtoken19", amountIn, maxHops, 1, aStar)
+			assert.NoError(t, err)
+			if assert.Len(t, paths, 1) {
+				out, err := pf.priceCalc.CalculatePathOutput(ctx, paths[0], amountIn, "0xtoken00", "0xtoken19")
+				assert.NoError(t, err)
+				assert.Equal(t, best.String(), out.String())
+			}
+
+			// The search without a heuristic prunes by the best amount reaching each token,
+			// so even run to exhaustion it may miss the best path
+			dijkstra := &pathSearch{}
+			all, err := pf.findBestPaths(ctx, "0xtoken00", "0xtoken19", amountIn, maxHops, 1000, dijkstra)
+			assert.NoError(t, err)
+			for _, path := range all {
+				out, err := pf.priceCalc.CalculatePathOutput(ctx, path, amountIn, "0xtoken00", "0xtoken19")
+				if assert.NoError(t, err) {
+					assert.LessOrEqual(t, out.Cmp(best), 0)
+				}
+			}
+			if maxHops == 3 {
+				assert.Less(t, aStar.expanded, dijkstra.expanded, "A* explores fewer paths")
+			} else {
+				assert.LessOrEqual(t, aStar.expanded, dijkstra.expanded)
+			}
+		})
+	}
+
+	t.Run("exported method", func(t *testing.T) {
+		paths, err := pf.FindBestPathsAStar(ctx, "0xtoken00", "0xtoken19", amountIn, 3, 1)
+		assert.NoError(t, err)
+		assert.Len(t, paths, 1)
+	})
+
+	t.Run("unreachable within hops", func(t *testing.T) {
+		chain := scoredPathFinder(t, longChainPools(5)[1:])
+		paths, err := chain.FindBestPathsAStar(ctx, "0xtokena", "0xchain00004", amountIn, 3, 1)
+		assert.NoError(t, err)
+		assert.Empty(t, paths)
+	})
+}
+
+// TestPathFinder_FindBestPathsAStar_LowFeePools checks that the heuristic allows for each
+// pool's fee. A bound that assumed the default 0.3% fee would rank the two-hop path
+// through 1 bps pools below the direct 0.3% pool, and A* would stop at the direct one.
+func TestPathFinder_FindBestPathsAStar_LowFeePools(t *testing.T) {
+	deep, _ := new(big.Int).SetString("1000000000000000000000000", 10)
+	pool := func(address, token0, token1 string, feeBps int) *types.Pool {
+		p := testutil.NewPool().WithAddress(address).WithTokenAddresses(token0, token1).WithReserves(deep, deep).Build()
+		p.LPFeeBps = feeBps
+		return p
+	}
+	pf := scoredPathFinder(t, []*types.Pool{
+		pool("a-d", "0xtokena", "0xtokend", 30),
+		pool("a-b", "0xtokena", "0xtokenb", 1),
+		pool("b-d", "0xtokenb", "0xtokend", 1),
+	})
+	ctx := context.Background()
+	amountIn := big.NewInt(1000000000000000000)
+
+	dijkstra, err := pf.findBestPaths(ctx, "0xtokena", "0xtokend", amountIn, 2, 10, &pathSearch{})
+	assert.NoError(t, err)
+	bestPath, best := bestOfPaths(t, pf, dijkstra, "0xtokena", "0xtokend", amountIn)
+	assert.Equal(t, []string{"a-b", "b-d"}, poolAddresses(bestPath))
+
+	aStar, err := pf.findBestPaths(ctx, "0xtokena", "0xtokend", amountIn, 2, 1, &pathSearch{aStar: true})
+	assert.NoError(t, err)
+	if assert.Len(t, aStar, 1) {
+		assert.Equal(t, []string{"a-b", "b-d"}, poolAddresses(aStar[0]))
+		out, err := pf.priceCalc.CalculatePathOutput(ctx, aStar[0], amountIn, "0xtokena", "0xtokend")
+		assert.NoError(t, err)
+		assert.Equal(t, best.String(), out.String())
+	}
+}
+
+// BenchmarkFindBestPaths_AStar compares the paths each search expands on a 20-token
+// graph: A* stops at the first path it reaches, which is the best, while the search
+// without a heuristic runs until no path is left to expand
+func BenchmarkFindBestPaths_AStar(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ctx := context.Background()
+	pf := newPathFinder(ctx, cache.NewMemoryStore(), NewPriceCalculator())
+	pf.graph.Store(buildGraphParallel(twentyTokenPools(), 1))
+	amountIn := big.NewInt(1000000000000000000)
+
+	for _, bc := range []struct {
+		name     string
+		aStar    bool
+		maxPaths int
+	}{
+		{"Dijkstra", false, 1000},
+		{"AStar", true, 1},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var expanded int
+			for i := 0; i < b.N; i++ {
+				search := &pathSearch{aStar: bc.aStar}
+				if _, err := pf.findBestPaths(ctx, "0xtoken00", "0xtoken19", amountIn, 3, bc.maxPaths, search); err != nil {
+					b.Fatal(err)
+				}
+				expanded = search.expanded
+			}
+			b.ReportMetric(float64(expanded), "expanded/op")
+		})
+	}
+}
+
+// diamondPools connects 0xtokena to 0xtokend directly and through 0xtokenb or 0xtokenc.
+// The paths through 0xtokenb pay the most, then through 0xtokenc, then the direct pool.
+func diamondPools() []*types.Pool {
+	deep, _ := new(big.Int).SetString("1000000000000000000000000", 10)
+	pool := func(address, token0, token1 string, rateBps int64) *types.Pool {
+		return testutil.NewPool().WithAddress(address).WithTokenAddresses(token0, token1).
+			WithReserves(deep, new(big.Int).Div(new(big.Int).Mul(deep, big.NewInt(rateBps)), big.NewInt(10000))).Build()
+	}
+	return []*types.Pool{
+		pool("a-d", "0xtokena", "0xtokend", 10000),
+		pool("a-b", "0xtokena", "0xtokenb", 11000),
+		pool("b-d", "0xtokenb", "0xtokend", 11000),
+		pool("a-c", "0xtokena", "0xtokenc", 10500),
+		pool("c-d", "0xtokenc", "0xtokend", 10000),
+	}
+}
+
+func poolAddresses(path []*types.Pool) []string {
+	addresses := make([]string, len(path))
+	for i, pool := range path {
+		addresses[i] = pool.Address
+	}
+	return addresses
+}
+
+// bestOfPaths returns the path with the most output
+func bestOfPaths(t *testing.T, pf *PathFinder, paths [][]*types.Pool, tokenIn, tokenOut string, amountIn *big.Int) ([]*types.Pool, *big.Int) {
+	t.Helper()
+	var best []*types.Pool
+	bestOut := big.NewInt(0)
+	for _, path := range paths {
+		out, err := pf.priceCalc.CalculatePathOutput(context.Background(), path, amountIn, tokenIn, tokenOut)
+		if assert.NoError(t, err) && out.Cmp(bestOut) > 0 {
+			best, bestOut = path, out
+		}
+	}
+	return best, bestOut
+}
+
+var pathAlgorithms = []string{PathAlgorithmDijkstra, PathAlgorithmYen, PathAlgorithmAStar, PathAlgorithmBidir}
+
+func TestNewPathFinderAlgorithm(t *testing.T) {
+	pf := scoredPathFinder(t, diamondPools())
+	ctx := context.Background()
+	amountIn := big.NewInt(1000000000000000000)
+	best := bestPathOutput(ctx, pf, "0xtokena", "0xtokend", amountIn, 3)
+
+	for _, name := range pathAlgorithms {
+		t.Run(name, func(t *testing.T) {
+			paths, err := newPathFinderAlgorithm(name)(pf, ctx, "0xtokena", "0xtokend", amountIn, 3, 10, &pathSearch{})
+			assert.NoError(t, err)
+
+			path, out := bestOfPaths(t, pf, paths, "0xtokena", "0xtokend", amountIn)
+			assert.Equal(t, []string{"a-b", "b-d"}, poolAddresses(path))
+			assert.Equal(t, best.String(), out.String())
+		})
+	}
+
+	t.Run("exchange", func(t *testing.T) {
+		for _, name := range pathAlgorithms {
+			paths, err := newPathFinderAlgorithm(name)(pf, ctx, "0xtokena", "0xtokend", amountIn, 3, 10, &pathSearch{exchange: "SushiSwap"})
+			assert.NoError(t, err, name)
+			assert.Empty(t, paths, name)
+		}
+	})
+}
+
+// TestPathFinder_PathAlgorithms_FindBestPath compares each algorithm with a brute-force
+// search on a 20-token graph. Dijkstra keeps one path per token, which can miss the best
+// path, so it is held only to never beating it.
+func TestPathFinder_PathAlgorithms_FindBestPath(t *testing.T) {
+	pf := scoredPathFinder(t, twentyTokenPools())
+	ctx := context.Background()
+	amountIn := big.NewInt(1000000000000000000)
+
+	for _, maxHops := range []int{1, 2, 3} {
+		best := bestPathOutput(ctx, pf, "0xtoken00", "0xtoken19", amountIn, maxHops)
+		for _, name := range pathAlgorithms {
+			t.Run(fmt.Sprintf("%s %d hops", name, maxHops), func(t *testing.T) {
+				paths, err := newPathFinderAlgorithm(name)(pf, ctx, "0xtoken00", "0xtoken19", amountIn, maxHops, 5, &pathSearch{})
+				assert.NoError(t, err)
+				assert.LessOrEqual(t, len(paths), 5)
+				for _, path := range paths {
+					assert.LessOrEqual(t, len(path), maxHops)
+					assert.NotNil(t, joinPath(path, nil, "0xtoken00"), "loop-free")
+				}
+
+				_, out := bestOfPaths(t, pf, paths, "0xtoken00", "0xtoken19", amountIn)
+				if name == PathAlgorithmDijkstra {
+					assert.LessOrEqual(t, out.Cmp(best), 0)
+				} else {
+					assert.Equal(t, best.String(), out.String())
+				}
+			})
+		}
+	}
+}
+
+func TestPathFinder_SetPathAlgorithm(t *testing.T) {
+	pf := scoredPathFinder(t, diamondPools())
+	ctx := context.Background()
+	amountIn := big.NewInt(1000000000000000000)
+
+	// Yen lists every path, best first
+	pf.SetPathAlgorithm(PathAlgorithmYen)
+	paths, err := pf.FindBestPaths(ctx, "0xtokena", "0xtokend", amountIn, 3, 10)
+	assert.NoError(t, err)
+	var addresses [][]string
+	for _, path := range paths {
+		addresses = append(addresses, poolAddresses(path))
+	}
+	assert.Equal(t, [][]string{{"a-b", "b-d"}, {"a-c", "c-d"}, {"a-d"}}, addresses)
+
+	// Unknown names search with Dijkstra
+	pf.SetPathAlgorithm("bfs")
+	unknown, err := pf.FindBestPaths(ctx, "0xtokena", "0xtokend", amountIn, 3, 10)
+	assert.NoError(t, err)
+	dijkstra, err := pf.findBestPaths(ctx, "0xtokena", "0xtokend", amountIn, 3, 10, &pathSearch{})
+	assert.NoError(t, err)
+	assert.Equal(t, dijkstra, unknown)
+}
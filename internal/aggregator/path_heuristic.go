@@ -0,0 +1,79 @@
+package aggregator
+
+import (
+	"math"
+	"math/big"
+	"strings"
+)
+
+// spotHeuristic is the A* heuristic of a search for tokenOut. A swap never returns more
+// than the pool's spot rate less the pool's fee, so the best product of those rates from
+// a token to tokenOut bounds what a path through that token can still gain, and never
+// underestimates it.
+type spotHeuristic struct {
+	// bestLogRate[k][token] is the log of the best product of spot rates over at most k
+	// hops from token to tokenOut. Tokens that cannot reach tokenOut in k hops are absent.
+	bestLogRate []map[string]float64
+}
+
+// newSpotHeuristic computes the best rates to tokenOut over up to maxHops hops through
+// the pools of exchange, or of every exchange when it is empty. Each round extends the
+// rates of the tokens that improved in the previous one by a hop, Bellman-Ford style.
+func newSpotHeuristic(g *graphData, tokenOut, exchange string, maxHops int) *spotHeuristic {
+	h := &spotHeuristic{bestLogRate: make([]map[string]float64, maxHops+1)}
+	h.bestLogRate[0] = map[string]float64{tokenOut: 0}
+
+	improved := map[string]bool{tokenOut: true}
+	for k := 1; k <= maxHops; k++ {
+		previous := h.bestLogRate[k-1]
+		current := make(map[string]float64, len(previous))
+		for token, rate := range previous {
+			current[token] = rate
+		}
+
+		next := make(map[string]bool)
+		for to := range improved {
+			for _, from := range g.adj[to] {
+				for _, edge := range g.edgesBetween(from, to) {
+					if exchange != "" && !strings.EqualFold(edge.pool.Exchange, exchange) {
+						continue
+					}
+					weight, ok := logRateWeight(edge.pool, from)
+					if !ok {
+						continue
+					}
+					rate := previous[to] - weight
+					if best, ok := current[from]; !ok || rate > best {
+						current[from] = rate
+						next[from] = true
+					}
+				}
+			}
+		}
+		h.bestLogRate[k] = current
+		improved = next
+	}
+	return h
+}
+
+// estimate sets state.estimate to the log of the most tokenOut it could reach within
+// remainingHops. It returns false when tokenOut is out of reach.
+func (h *spotHeuristic) estimate(state *pathState, remainingHops int) bool {
+	if remainingHops < 0 {
+		return false
+	}
+	if remainingHops >= len(h.bestLogRate) {
+		remainingHops = len(h.bestLogRate) - 1
+	}
+	rate, ok := h.bestLogRate[remainingHops][state.lastToken]
+	if !ok {
+		return false
+	}
+	state.estimate = logBigInt(state.amountOut) + rate
+	return true
+}
+
+func logBigInt(x *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(x).Float64()
+	return math.Log(f)
+}
@@ -1,16 +1,26 @@
 package aggregator
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"math"
 	"math/big"
 	"strings"
+	"sync"
 
+	applog "dex-aggregator/internal/log"
 	"dex-aggregator/internal/types"
 )
 
+// ErrSlippageTooHigh is returned for a trade too large for the pool, which says nothing
+// about the health of the pool's exchange
+var ErrSlippageTooHigh = errors.New("slippage too high")
+
 type PriceCalculator struct {
-	maxSlippage float64 // Maximum allowed slippage percentage
+	mu           sync.RWMutex
+	maxSlippage  float64            // Maximum allowed slippage percentage
+	pairSlippage map[string]float64 // pairKey -> slippage replacing maxSlippage for the pair
 }
 
 func NewPriceCalculator() *PriceCalculator {
@@ -20,62 +30,50 @@ func NewPriceCalculator() *PriceCalculator {
 }
 
 // CalculateOutput calculates output amount for a single pool with slippage check
-func (pc *PriceCalculator) CalculateOutput(pool *types.Pool, amountIn *big.Int, tokenIn string) (*big.Int, error) {
+func (pc *PriceCalculator) CalculateOutput(ctx context.Context, pool *types.Pool, amountIn *big.Int, tokenIn string) (*big.Int, error) {
+	logger := applog.FromContext(ctx)
 	var reserveIn, reserveOut *big.Int
 
 	poolToken0 := strings.ToLower(pool.Token0.Address)
 	poolToken1 := strings.ToLower(pool.Token1.Address)
 	tokenInLower := strings.ToLower(tokenIn)
 
-	log.Printf("CalculateOutput: pool %s, tokens: %s/%s, input token: %s",
-		pool.Address, poolToken0, poolToken1, tokenInLower)
+	logger.Info("CalculateOutput", "pool", pool.Address, "token0", poolToken0, "token1", poolToken1, "inputToken", tokenInLower)
 
 	if poolToken0 == tokenInLower {
 		reserveIn = pool.Reserve0
 		reserveOut = pool.Reserve1
-		log.Printf("Token0 match, reserves: in=%s, out=%s", reserveIn.String(), reserveOut.String())
+		logger.Info("Token0 match", "reserveIn", reserveIn.String(), "reserveOut", reserveOut.String())
 	} else if poolToken1 == tokenInLower {
 		reserveIn = pool.Reserve1
 		reserveOut = pool.Reserve0
-		log.Printf("Token1 match, reserves: in=%s, out=%s", reserveIn.String(), reserveOut.String())
+		logger.Info("Token1 match", "reserveIn", reserveIn.String(), "reserveOut", reserveOut.String())
 	} else {
-		log.Printf("Token %s not found in pool", tokenIn)
+		logger.Info("Token not found in pool", "token", tokenIn, "pool", pool.Address)
 		return big.NewInt(0), fmt.Errorf("token %s not found in pool", tokenIn)
 	}
 
 	if reserveIn.Cmp(big.NewInt(0)) == 0 || reserveOut.Cmp(big.NewInt(0)) == 0 {
-		log.Printf("Zero reserves: in=%s, out=%s", reserveIn.String(), reserveOut.String())
+		logger.Info("Zero reserves", "reserveIn", reserveIn.String(), "reserveOut", reserveOut.String())
 		return big.NewInt(0), nil
 	}
 
-	if err := pc.checkSlippage(reserveIn, reserveOut, amountIn); err != nil {
-		log.Printf("Slippage check failed: %v", err)
+	if err := pc.checkSlippageWithLimit(ctx, reserveIn, reserveOut, amountIn, pc.MaxSlippageForPair(poolToken0, poolToken1)); err != nil {
+		logger.Info("Slippage check failed", "error", err)
 		return big.NewInt(0), err
 	}
 
-	fee := big.NewInt(997)
-	thousand := big.NewInt(1000)
-
-	amountInWithFee := new(big.Int).Mul(amountIn, fee)
-	numerator := new(big.Int).Mul(reserveOut, amountInWithFee)
-
-	denominator := new(big.Int).Mul(reserveIn, thousand)
-	denominator.Add(denominator, amountInWithFee)
-
-	if denominator.Cmp(big.NewInt(0)) == 0 {
-		log.Printf("Zero denominator")
-		return big.NewInt(0), nil
-	}
-
-	amountOut := new(big.Int).Div(numerator, denominator)
+	// The protocol share comes out of the same fee, so only the total affects the output
+	amountOut := calculateOutputWithFeeBps(reserveIn, reserveOut, amountIn, pool.TotalFeeBps())
 
-	log.Printf("Calculation: amountIn=%s, amountOut=%s", amountIn.String(), amountOut.String())
+	logger.Info("Calculation", "amountIn", amountIn.String(), "amountOut", amountOut.String(),
+		"lpFeeBps", pool.LPFeeBps, "protocolFeeBps", pool.ProtocolFeeBps)
 
 	return amountOut, nil
 }
 
 // CalculateOutputWithSlippageCheck calculates output with custom slippage limit
-func (pc *PriceCalculator) CalculateOutputWithSlippageCheck(pool *types.Pool, amountIn *big.Int, tokenIn string, maxSlippage float64) (*big.Int, error) {
+func (pc *PriceCalculator) CalculateOutputWithSlippageCheck(ctx context.Context, pool *types.Pool, amountIn *big.Int, tokenIn string, maxSlippage float64) (*big.Int, error) {
 	var reserveIn, reserveOut *big.Int
 
 	if pool.Token0.Address == tokenIn {
@@ -93,34 +91,44 @@ func (pc *PriceCalculator) CalculateOutputWithSlippageCheck(pool *types.Pool, am
 	}
 
 	// Check slippage with custom limit
-	if err := pc.checkSlippageWithLimit(reserveIn, reserveOut, amountIn, maxSlippage); err != nil {
+	if err := pc.checkSlippageWithLimit(ctx, reserveIn, reserveOut, amountIn, maxSlippage); err != nil {
 		return big.NewInt(0), err
 	}
 
-	fee := big.NewInt(997)
-	thousand := big.NewInt(1000)
-
-	amountInWithFee := new(big.Int).Mul(amountIn, fee)
-	numerator := new(big.Int).Mul(reserveOut, amountInWithFee)
-
-	denominator := new(big.Int).Mul(reserveIn, thousand)
-	denominator.Add(denominator, amountInWithFee)
+	return calculateOutputWithFeeBps(reserveIn, reserveOut, amountIn, pool.TotalFeeBps()), nil
+}
 
-	if denominator.Cmp(big.NewInt(0)) == 0 {
-		return big.NewInt(0), nil
-	}
+// hopError is returned by CalculatePathOutput when the output of one pool fails
+type hopError struct {
+	index int
+	pool  *types.Pool
+	err   error
+}
 
-	amountOut := new(big.Int).Div(numerator, denominator)
+func (e *hopError) Error() string {
+	return fmt.Sprintf("pool %d calculation failed: %v", e.index, e.err)
+}
 
-	return amountOut, nil
+func (e *hopError) Unwrap() error {
+	return e.err
 }
 
 // CalculatePathOutput calculates output for a multi-hop path
-func (pc *PriceCalculator) CalculatePathOutput(pools []*types.Pool, amountIn *big.Int, tokenIn, tokenOut string) (*big.Int, error) {
-	if len(pools) == 0 {
+func (pc *PriceCalculator) CalculatePathOutput(ctx context.Context, pools []*types.Pool, amountIn *big.Int, tokenIn, tokenOut string) (*big.Int, error) {
+	hops, err := pc.CalculatePathHops(ctx, pools, amountIn, tokenIn, tokenOut)
+	if err != nil {
+		return big.NewInt(0), err
+	}
+	if len(hops) == 0 {
 		return big.NewInt(0), nil
 	}
+	return hops[len(hops)-1].AmountOut, nil
+}
 
+// CalculatePathHops simulates a multi-hop path one pool at a time and returns what
+// flowed through each hop. The last hop's AmountOut is the path output.
+func (pc *PriceCalculator) CalculatePathHops(ctx context.Context, pools []*types.Pool, amountIn *big.Int, tokenIn, tokenOut string) ([]types.HopDetail, error) {
+	hops := make([]types.HopDetail, 0, len(pools))
 	currentAmount := new(big.Int).Set(amountIn)
 	currentToken := strings.ToLower(tokenIn)
 	tokenOutLower := strings.ToLower(tokenOut)
@@ -128,9 +136,9 @@ func (pc *PriceCalculator) CalculatePathOutput(pools []*types.Pool, amountIn *bi
 	for i, pool := range pools {
 		inputToken := currentToken
 
-		amountOut, err := pc.CalculateOutput(pool, currentAmount, inputToken)
+		amountOut, err := pc.CalculateOutput(ctx, pool, currentAmount, inputToken)
 		if err != nil {
-			return big.NewInt(0), fmt.Errorf("pool %d calculation failed: %v", i, err)
+			return nil, &hopError{index: i, pool: pool, err: err}
 		}
 
 		poolToken0Lower := strings.ToLower(pool.Token0.Address)
@@ -142,28 +150,89 @@ func (pc *PriceCalculator) CalculatePathOutput(pools []*types.Pool, amountIn *bi
 		} else if poolToken1Lower == inputTokenLower {
 			currentToken = poolToken0Lower
 		} else {
-			return big.NewInt(0), fmt.Errorf("token %s not found in pool %s", inputToken, pool.Address)
+			return nil, fmt.Errorf("token %s not found in pool %s", inputToken, pool.Address)
 		}
 
+		// A hop without output has no meaningful price impact
+		var priceImpact float64
+		if amountOut.Sign() > 0 {
+			priceImpact, _ = pc.CalculatePathPriceImpact([]*types.Pool{pool}, currentAmount, amountOut, inputTokenLower)
+		}
+
+		hops = append(hops, types.HopDetail{
+			PoolAddress: pool.Address,
+			TokenIn:     inputTokenLower,
+			TokenOut:    currentToken,
+			AmountIn:    currentAmount,
+			AmountOut:   amountOut,
+			PriceImpact: priceImpact,
+		})
+
 		currentAmount = amountOut
 
 		if i == len(pools)-1 {
 			if currentToken != tokenOutLower {
-				return big.NewInt(0), fmt.Errorf("final output token %s does not match requested tokenOut %s", currentToken, tokenOutLower)
+				return nil, fmt.Errorf("final output token %s does not match requested tokenOut %s", currentToken, tokenOutLower)
 			}
 		}
 	}
 
-	return currentAmount, nil
+	return hops, nil
+}
+
+// CalculatePathPriceImpact returns, as a percentage, how far amountOut falls short of
+// amountIn converted at the spot prices along path. Like checkSlippageWithLimit, the
+// shortfall includes the swap fee.
+func (pc *PriceCalculator) CalculatePathPriceImpact(path []*types.Pool, amountIn, amountOut *big.Int, tokenIn string) (float64, error) {
+	if len(path) == 0 || amountIn == nil || amountIn.Sign() <= 0 || amountOut == nil {
+		return 0, fmt.Errorf("invalid path or amounts")
+	}
+
+	spotOut, err := spotPathOutput(path, amountIn, tokenIn)
+	if err != nil {
+		return 0, err
+	}
+
+	if spotOut.Sign() == 0 {
+		return 0, fmt.Errorf("zero spot output")
+	}
+
+	shortfall := new(big.Float).Sub(spotOut, new(big.Float).SetInt(amountOut))
+	impact, _ := new(big.Float).Quo(shortfall, spotOut).Float64()
+	return impact * 100, nil
 }
 
-// checkSlippage verifies that the trade doesn't exceed maximum slippage
-func (pc *PriceCalculator) checkSlippage(reserveIn, reserveOut, amountIn *big.Int) error {
-	return pc.checkSlippageWithLimit(reserveIn, reserveOut, amountIn, pc.maxSlippage)
+// spotPathOutput converts amountIn of tokenIn into the token path ends in at the spot
+// prices of its pools, without fees or price impact
+func spotPathOutput(path []*types.Pool, amountIn *big.Int, tokenIn string) (*big.Float, error) {
+	spotOut := new(big.Float).SetInt(amountIn)
+	currentToken := strings.ToLower(tokenIn)
+
+	for _, pool := range path {
+		var reserveIn, reserveOut *big.Int
+		switch currentToken {
+		case strings.ToLower(pool.Token0.Address):
+			reserveIn, reserveOut = pool.Reserve0, pool.Reserve1
+			currentToken = strings.ToLower(pool.Token1.Address)
+		case strings.ToLower(pool.Token1.Address):
+			reserveIn, reserveOut = pool.Reserve1, pool.Reserve0
+			currentToken = strings.ToLower(pool.Token0.Address)
+		default:
+			return nil, fmt.Errorf("token %s not found in pool %s", currentToken, pool.Address)
+		}
+		if reserveIn == nil || reserveOut == nil || reserveIn.Sign() <= 0 {
+			return nil, fmt.Errorf("pool %s has no liquidity", pool.Address)
+		}
+
+		spotOut.Mul(spotOut, new(big.Float).SetInt(reserveOut))
+		spotOut.Quo(spotOut, new(big.Float).SetInt(reserveIn))
+	}
+
+	return spotOut, nil
 }
 
 // checkSlippageWithLimit verifies slippage with custom limit
-func (pc *PriceCalculator) checkSlippageWithLimit(reserveIn, reserveOut, amountIn *big.Int, maxSlippage float64) error {
+func (pc *PriceCalculator) checkSlippageWithLimit(ctx context.Context, reserveIn, reserveOut, amountIn *big.Int, maxSlippage float64) error {
 	if amountIn.Cmp(big.NewInt(0)) == 0 {
 		return nil
 	}
@@ -175,7 +244,7 @@ func (pc *PriceCalculator) checkSlippageWithLimit(reserveIn, reserveOut, amountI
 
 	// 2. Check division by zero
 	if fReserveIn.Cmp(big.NewFloat(0)) == 0 {
-		log.Printf("Slippage check: zero reserveIn")
+		applog.FromContext(ctx).Info("Slippage check: zero reserveIn")
 		return fmt.Errorf("zero reserveIn")
 	}
 
@@ -206,18 +275,153 @@ func (pc *PriceCalculator) checkSlippageWithLimit(reserveIn, reserveOut, amountI
 	slippagePercent, _ := priceImpactRatio.Float64()
 	slippagePercent = slippagePercent * 100
 
-	log.Printf("Slippage check: Spot=%.6f, Eff=%.6f, Impact=%.2f%% (Max: %.2f%%)",
-		spotPrice, effectivePrice, slippagePercent, maxSlippage)
+	logger := applog.FromContext(ctx)
+	logger.Info("Slippage check",
+		"spot", spotPrice.Text('f', 6), "effective", effectivePrice.Text('f', 6),
+		"impactPercent", slippagePercent, "maxPercent", maxSlippage)
 
 	// 8. Check if exceeds maximum allowed slippage
 	if slippagePercent > maxSlippage {
-		return fmt.Errorf("slippage too high: %.2f%% (max: %.2f%%)", slippagePercent, maxSlippage)
+		return fmt.Errorf("%w: %.2f%% (max: %.2f%%)", ErrSlippageTooHigh, slippagePercent, maxSlippage)
 	}
 
-	log.Printf("Slippage check passed: %.2f%%", slippagePercent)
+	logger.Info("Slippage check passed", "impactPercent", slippagePercent)
 	return nil
 }
 
+// executionPriceDecimals is the number of fractional digits in an execution price
+const executionPriceDecimals = 6
+
+// CalculateExecutionPrice returns amountOut / amountIn in whole tokenOut per whole tokenIn,
+// formatted as a decimal string. Token decimals are read from the first and last pools of path.
+func (pc *PriceCalculator) CalculateExecutionPrice(path []*types.Pool, amountIn, amountOut *big.Int, tokenIn, tokenOut string) (string, error) {
+	if len(path) == 0 {
+		return "", fmt.Errorf("empty path")
+	}
+	if amountIn == nil || amountIn.Sign() <= 0 || amountOut == nil {
+		return "", fmt.Errorf("invalid amounts")
+	}
+
+	decimalsIn, ok := tokenDecimals(path[0], tokenIn)
+	if !ok {
+		return "", fmt.Errorf("token %s not found in pool %s", tokenIn, path[0].Address)
+	}
+	decimalsOut, ok := tokenDecimals(path[len(path)-1], tokenOut)
+	if !ok {
+		return "", fmt.Errorf("token %s not found in pool %s", tokenOut, path[len(path)-1].Address)
+	}
+
+	// price = amountOut / amountIn * 10^(decimalsIn - decimalsOut)
+	price := new(big.Float).SetPrec(256).Quo(
+		new(big.Float).SetPrec(256).SetInt(amountOut),
+		new(big.Float).SetPrec(256).SetInt(amountIn),
+	)
+	return shiftDecimals(price, decimalsIn-decimalsOut).Text('f', executionPriceDecimals), nil
+}
+
+// NormalisedSpotPrice returns the pool's spot price of tokenIn in whole units of the
+// other token, e.g. about 2000 for WETH in a WETH/USDT pool rather than the raw reserve
+// ratio of 2e-9: reserveOut / reserveIn * 10^(decimalsIn - decimalsOut).
+func (pc *PriceCalculator) NormalisedSpotPrice(pool *types.Pool, tokenIn string) (*big.Float, error) {
+	reserveIn, reserveOut, decimalsIn, decimalsOut, err := poolSide(pool, tokenIn)
+	if err != nil {
+		return nil, err
+	}
+	if reserveIn.Sign() <= 0 || reserveOut.Sign() <= 0 {
+		return nil, fmt.Errorf("pool %s has no liquidity", pool.Address)
+	}
+
+	price := new(big.Float).SetPrec(256).Quo(
+		new(big.Float).SetPrec(256).SetInt(reserveOut),
+		new(big.Float).SetPrec(256).SetInt(reserveIn),
+	)
+	return shiftDecimals(price, decimalsIn-decimalsOut), nil
+}
+
+// CalculateNormalisedOutput returns the pool's output for amountIn of tokenIn in whole
+// units of the output token, for display. Unlike CalculateOutput it applies no slippage limit.
+func (pc *PriceCalculator) CalculateNormalisedOutput(pool *types.Pool, amountIn *big.Int, tokenIn string) (*big.Float, error) {
+	if amountIn == nil || amountIn.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amountIn")
+	}
+	reserveIn, reserveOut, _, decimalsOut, err := poolSide(pool, tokenIn)
+	if err != nil {
+		return nil, err
+	}
+	if reserveIn.Sign() <= 0 || reserveOut.Sign() <= 0 {
+		return nil, fmt.Errorf("pool %s has no liquidity", pool.Address)
+	}
+
+	amountOut := new(big.Float).SetPrec(256).SetInt(calculateOutputWithFee(reserveIn, reserveOut, amountIn))
+	return shiftDecimals(amountOut, -decimalsOut), nil
+}
+
+// poolSide returns the reserves and decimals of tokenIn and the pool's other token
+func poolSide(pool *types.Pool, tokenIn string) (reserveIn, reserveOut *big.Int, decimalsIn, decimalsOut int, err error) {
+	switch strings.ToLower(tokenIn) {
+	case strings.ToLower(pool.Token0.Address):
+		reserveIn, reserveOut = pool.Reserve0, pool.Reserve1
+		decimalsIn, decimalsOut = pool.Token0.Decimals, pool.Token1.Decimals
+	case strings.ToLower(pool.Token1.Address):
+		reserveIn, reserveOut = pool.Reserve1, pool.Reserve0
+		decimalsIn, decimalsOut = pool.Token1.Decimals, pool.Token0.Decimals
+	default:
+		return nil, nil, 0, 0, fmt.Errorf("token %s not found in pool %s", tokenIn, pool.Address)
+	}
+	if reserveIn == nil || reserveOut == nil {
+		return nil, nil, 0, 0, fmt.Errorf("pool %s has no reserves", pool.Address)
+	}
+	return reserveIn, reserveOut, decimalsIn, decimalsOut, nil
+}
+
+// shiftDecimals multiplies value in place by 10^shift and returns it
+func shiftDecimals(value *big.Float, shift int) *big.Float {
+	scale := new(big.Float).SetPrec(value.Prec()).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(shift))), nil))
+	if shift >= 0 {
+		return value.Mul(value, scale)
+	}
+	return value.Quo(value, scale)
+}
+
+// ComputeImpermanentLoss returns the impermanent loss of a constant-product position as a
+// fraction (e.g. -0.0572 for a 2x price move) using IL = 2*sqrt(r)/(1+r) - 1, where r is
+// the current token0 price in token1 divided by the entry price. The decimal adjustment of
+// NormalisedSpotPrice cancels out of r, so raw reserves suffice. It returns 0 when any
+// reserve is nil or not positive.
+func (pc *PriceCalculator) ComputeImpermanentLoss(entryR0, entryR1, curR0, curR1 *big.Int) float64 {
+	for _, reserve := range []*big.Int{entryR0, entryR1, curR0, curR1} {
+		if reserve == nil || reserve.Sign() <= 0 {
+			return 0
+		}
+	}
+
+	// r = (curR1/curR0) / (entryR1/entryR0) = curR1*entryR0 / (curR0*entryR1)
+	ratio, _ := new(big.Float).SetPrec(256).Quo(
+		new(big.Float).SetPrec(256).SetInt(new(big.Int).Mul(curR1, entryR0)),
+		new(big.Float).SetPrec(256).SetInt(new(big.Int).Mul(curR0, entryR1)),
+	).Float64()
+
+	return 2*math.Sqrt(ratio)/(1+ratio) - 1
+}
+
+// tokenDecimals returns the decimals of token as recorded in pool
+func tokenDecimals(pool *types.Pool, token string) (int, bool) {
+	switch strings.ToLower(token) {
+	case strings.ToLower(pool.Token0.Address):
+		return pool.Token0.Decimals, true
+	case strings.ToLower(pool.Token1.Address):
+		return pool.Token1.Decimals, true
+	}
+	return 0, false
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
 func calculateOutputWithoutFee(reserveIn, reserveOut, amountIn *big.Int) *big.Int {
 	numerator := new(big.Int).Mul(reserveOut, amountIn)
 	denominator := new(big.Int).Add(reserveIn, amountIn)
@@ -230,13 +434,15 @@ func calculateOutputWithoutFee(reserveIn, reserveOut, amountIn *big.Int) *big.In
 }
 
 func calculateOutputWithFee(reserveIn, reserveOut, amountIn *big.Int) *big.Int {
-	fee := big.NewInt(997)
-	thousand := big.NewInt(1000)
+	return calculateOutputWithFeeBps(reserveIn, reserveOut, amountIn, types.DefaultSwapFeeBps)
+}
 
-	amountInWithFee := new(big.Int).Mul(amountIn, fee)
+// calculateOutputWithFeeBps is the constant-product output after a swap fee of feeBps
+func calculateOutputWithFeeBps(reserveIn, reserveOut, amountIn *big.Int, feeBps int) *big.Int {
+	amountInWithFee := new(big.Int).Mul(amountIn, big.NewInt(int64(10000-feeBps)))
 	numerator := new(big.Int).Mul(reserveOut, amountInWithFee)
 
-	denominator := new(big.Int).Mul(reserveIn, thousand)
+	denominator := new(big.Int).Mul(reserveIn, big.NewInt(10000))
 	denominator.Add(denominator, amountInWithFee)
 
 	if denominator.Cmp(big.NewInt(0)) == 0 {
@@ -248,5 +454,52 @@ func calculateOutputWithFee(reserveIn, reserveOut, amountIn *big.Int) *big.Int {
 
 // SetMaxSlippage updates the maximum allowed slippage
 func (pc *PriceCalculator) SetMaxSlippage(slippage float64) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
 	pc.maxSlippage = slippage
 }
+
+// MaxSlippage returns the maximum allowed slippage
+func (pc *PriceCalculator) MaxSlippage() float64 {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.maxSlippage
+}
+
+// SetPairSlippageOverrides replaces the maximum slippage of the token pairs in overrides,
+// keyed by "tokenA:tokenB" in either order. Malformed keys are ignored.
+func (pc *PriceCalculator) SetPairSlippageOverrides(overrides map[string]float64) {
+	pairSlippage := make(map[string]float64, len(overrides))
+	for pair, slippage := range overrides {
+		tokenA, tokenB, ok := strings.Cut(pair, ":")
+		if !ok || tokenA == "" || tokenB == "" {
+			continue
+		}
+		pairSlippage[PairKey(tokenA, tokenB)] = slippage
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.pairSlippage = pairSlippage
+}
+
+// MaxSlippageForPair returns the maximum slippage of swaps between tokenA and tokenB:
+// their override if one is set, otherwise MaxSlippage
+func (pc *PriceCalculator) MaxSlippageForPair(tokenA, tokenB string) float64 {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	if slippage, ok := pc.pairSlippage[PairKey(tokenA, tokenB)]; ok {
+		return slippage
+	}
+	return pc.maxSlippage
+}
+
+// PairKey is the lowercase token addresses in ascending order, joined by ":", so both
+// orders of a pair share one key
+func PairKey(tokenA, tokenB string) string {
+	tokenA, tokenB = strings.ToLower(tokenA), strings.ToLower(tokenB)
+	if tokenA > tokenB {
+		tokenA, tokenB = tokenB, tokenA
+	}
+	return tokenA + ":" + tokenB
+}
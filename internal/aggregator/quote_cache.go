@@ -0,0 +1,164 @@
+package aggregator
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"dex-aggregator/config"
+	"dex-aggregator/internal/types"
+)
+
+// maxCachedQuotes bounds the quote cache; quotes for new requests are not cached
+// while it is full of unexpired entries
+const maxCachedQuotes = 10000
+
+// quoteCacheKey identifies the quote requests that always get the same response
+// from one routing graph and config
+type quoteCacheKey struct {
+	tokenIn      string
+	tokenOut     string
+	amountIn     string
+	maxHops      int
+	gasPriceGwei uint64
+	diversifyDEX bool
+	riskAversion float64
+	maxSlippage  float64
+}
+
+func newQuoteCacheKey(req *types.QuoteRequest) quoteCacheKey {
+	maxHops := req.MaxHops
+	if maxHops == 0 {
+		maxHops = 3
+	}
+	return quoteCacheKey{
+		tokenIn:      strings.ToLower(req.TokenIn),
+		tokenOut:     strings.ToLower(req.TokenOut),
+		amountIn:     req.AmountIn.String(),
+		maxHops:      maxHops,
+		gasPriceGwei: req.GasPriceGwei,
+		diversifyDEX: req.DiversifyDEX,
+		riskAversion: req.RiskAversion,
+		maxSlippage:  req.MaxSlippage,
+	}
+}
+
+type cachedQuote struct {
+	resp    *types.QuoteResponse
+	expires time.Time
+}
+
+// quoteCache keeps successful quotes for ttl. A zero ttl disables it.
+type quoteCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[quoteCacheKey]cachedQuote
+}
+
+func newQuoteCache(ttl time.Duration) *quoteCache {
+	return &quoteCache{ttl: ttl, entries: make(map[quoteCacheKey]cachedQuote)}
+}
+
+// get returns a copy of the cached response, so callers may set its fields
+func (qc *quoteCache) get(key quoteCacheKey) (*types.QuoteResponse, bool) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+
+	entry, ok := qc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(qc.entries, key)
+		return nil, false
+	}
+	resp := *entry.resp
+	return &resp, true
+}
+
+func (qc *quoteCache) put(key quoteCacheKey, resp *types.QuoteResponse) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+
+	if qc.ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	if len(qc.entries) >= maxCachedQuotes {
+		for k, entry := range qc.entries {
+			if now.After(entry.expires) {
+				delete(qc.entries, k)
+			}
+		}
+		if len(qc.entries) >= maxCachedQuotes {
+			return
+		}
+	}
+	stored := *resp
+	qc.entries[key] = cachedQuote{resp: &stored, expires: now.Add(qc.ttl)}
+}
+
+// reset drops every cached quote and applies ttl to quotes cached from now on
+func (qc *quoteCache) reset(ttl time.Duration) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	qc.ttl = ttl
+	qc.entries = make(map[quoteCacheKey]cachedQuote)
+}
+
+func (qc *quoteCache) clear() {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	qc.entries = make(map[quoteCacheKey]cachedQuote)
+}
+
+func (qc *quoteCache) len() int {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	return len(qc.entries)
+}
+
+// WarmCommonQuotes quotes one whole token between every pair of configured base tokens,
+// filling the quote cache so the first requests for them are served from it. A whole
+// token is 10^decimals of its smallest unit, with the decimals its pools record. Pairs
+// without a route are skipped; it only fails when ctx is done.
+func (r *Router) WarmCommonQuotes(ctx context.Context) error {
+	var baseTokens []string
+	if cfg := config.Current(); cfg != nil {
+		baseTokens = cfg.BaseTokens
+	}
+	g := r.pathFinder.graph.Load()
+
+	start := time.Now()
+	warmed := 0
+	for _, tokenIn := range baseTokens {
+		decimals, ok := g.tokenDecimals(strings.ToLower(tokenIn))
+		if !ok {
+			log.Printf("Quote warm-up: skipping %s, which has no pools", tokenIn)
+			continue
+		}
+		amountIn := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+
+		for _, tokenOut := range baseTokens {
+			if strings.EqualFold(tokenIn, tokenOut) {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			req := &types.QuoteRequest{TokenIn: tokenIn, TokenOut: tokenOut, AmountIn: new(big.Int).Set(amountIn)}
+			if _, err := r.GetBestQuote(ctx, req); err != nil {
+				log.Printf("Quote warm-up: skipping %s -> %s: %v", tokenIn, tokenOut, err)
+				continue
+			}
+			warmed++
+		}
+	}
+
+	log.Printf("Quote warm-up: %d base token pairs warmed in %v", warmed, time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -2,55 +2,370 @@ package aggregator
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math/big"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"dex-aggregator/config"
 	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/circuitbreaker"
+	"dex-aggregator/internal/history"
+	applog "dex-aggregator/internal/log"
+	"dex-aggregator/internal/stats"
 	"dex-aggregator/internal/types"
+	"dex-aggregator/internal/validation"
+	"dex-aggregator/internal/volume"
 )
 
 type Router struct {
-	cache         cache.Store
-	pathFinder    *PathFinder
-	calculator    *PriceCalculator
+	cache      cache.Store
+	pathFinder *PathFinder
+	calculator *PriceCalculator
+	arbitrage  *ArbitrageDetector
+
+	// inFlightQuotes counts GetBestQuote calls that have not yet returned
+	inFlightQuotes atomic.Int64
+
+	// quoteLatency averages the latency of recent GetBestQuote calls
+	quoteLatency *stats.RollingAverage
+
+	// quoteHistory records every completed quote when set
+	quoteHistory atomic.Pointer[history.RingBuffer]
+
+	// breaker tracks failing paths per exchange and opens exchanges that keep failing
+	breaker *circuitbreaker.ExchangeBreaker
+
+	// quotes caches successful quotes for one graph refresh interval
+	quotes *quoteCache
+
+	mu            sync.RWMutex
 	maxConcurrent int
+	dedupPaths    bool             // Drop trade paths through the same set of pools
+	pathTimeout   time.Duration    // Path searches stop with the paths found so far after this; 0 disables
+	gasCosts      map[string]int64 // Lowercase exchange name -> gas per swap
+	baseGas       int64
+	hopGas        int64
 }
 
-func NewRouter(cache cache.Store, perfConfig config.PerformanceConfig) *Router {
+const (
+	// defaultSwapGas is used for exchanges without a configured gas cost
+	defaultSwapGas int64 = 110000
+	// defaultBaseGas is the fixed cost of any transaction
+	defaultBaseGas int64 = 21000
+)
+
+// NewRouter creates a router whose background work runs until ctx is cancelled
+func NewRouter(ctx context.Context, cache cache.Store, perfConfig config.PerformanceConfig) *Router {
 	calculator := NewPriceCalculator()
 	// Use configured values to override defaults
 	calculator.SetMaxSlippage(perfConfig.MaxSlippage)
 
-	return &Router{
+	return newRouter(cache, perfConfig, calculator, NewPathFinder(ctx, cache, calculator))
+}
+
+// NewRouterFromSnapshot creates a router whose path finder graph is warm-started
+// from a snapshot instead of a blocking initial refresh. Snapshots older than
+// perfConfig.MaxPoolAge, which the pool health check would call stale, are rejected.
+func NewRouterFromSnapshot(ctx context.Context, cache cache.Store, perfConfig config.PerformanceConfig, snapshot io.Reader) (*Router, error) {
+	calculator := NewPriceCalculator()
+	calculator.SetMaxSlippage(perfConfig.MaxSlippage)
+
+	pathFinder, err := NewPathFinderFromSnapshot(ctx, cache, calculator, snapshot, perfConfig.MaxPoolAge)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRouter(cache, perfConfig, calculator, pathFinder), nil
+}
+
+func newRouter(cache cache.Store, perfConfig config.PerformanceConfig, calculator *PriceCalculator, pathFinder *PathFinder) *Router {
+	r := &Router{
 		cache:         cache,
-		pathFinder:    NewPathFinder(cache, calculator),
+		pathFinder:    pathFinder,
 		calculator:    calculator,
 		maxConcurrent: perfConfig.MaxConcurrentPaths,
+		dedupPaths:    perfConfig.DeduplicatePaths,
+		pathTimeout:   time.Duration(perfConfig.PathFindingTimeoutMs) * time.Millisecond,
+		breaker:       circuitbreaker.NewExchangeBreaker(circuitbreaker.DefaultFailureThreshold, circuitbreaker.DefaultRecoveryPeriod),
+		quotes:        newQuoteCache(perfConfig.GraphRefreshInterval),
+		quoteLatency:  stats.NewRollingAverage(stats.DefaultWindowSize),
+	}
+	pathFinder.SetExchangeBreaker(r.breaker)
+	r.arbitrage = NewArbitrageDetector(pathFinder, r.estimateGasCost)
+	pathFinder.SetPreferHighScorePools(perfConfig.PreferHighScorePools)
+	pathFinder.SetPathAlgorithm(perfConfig.PathAlgorithm)
+
+	var dexConfig config.DEXConfig
+	if cfg := config.Current(); cfg != nil {
+		dexConfig = cfg.DEX
 	}
+	r.SetGasCosts(dexConfig)
+	r.SetPairSlippageOverrides(dexConfig)
+
+	return r
+}
+
+// SetPairSlippageOverrides applies the DEX config's per-pair slippage limits to quotes
+func (r *Router) SetPairSlippageOverrides(dexConfig config.DEXConfig) {
+	r.calculator.SetPairSlippageOverrides(dexConfig.PairSlippageOverrides)
+	r.quotes.clear()
+}
+
+// SetTokenFilter applies the token allow and deny lists and rebuilds the graph with them
+func (r *Router) SetTokenFilter(dexConfig config.DEXConfig) {
+	r.pathFinder.SetTokenFilter(dexConfig.AllowedTokens, dexConfig.DeniedTokens)
+	r.pathFinder.RefreshGraphAsync()
+	r.quotes.clear()
+}
+
+// SetPoolValidators excludes pools failing any of validators from routing and refreshes the graph
+func (r *Router) SetPoolValidators(validators ...validation.PoolValidator) {
+	r.pathFinder.SetPoolValidators(validators...)
+	r.pathFinder.RefreshGraphAsync()
+	r.quotes.clear()
+}
+
+// SetMaxConsecutiveHopsPerDEX limits quote paths to n consecutive hops through one exchange
+func (r *Router) SetMaxConsecutiveHopsPerDEX(n int) {
+	r.pathFinder.SetMaxConsecutiveHopsPerDEX(n)
+	r.quotes.clear()
+}
+
+// SetMaxPoolsPerPair keeps the n deepest pools of each token pair and rebuilds the graph with them
+func (r *Router) SetMaxPoolsPerPair(n int) {
+	r.pathFinder.SetMaxPoolsPerPair(n)
+	r.pathFinder.RefreshGraphAsync()
+	r.quotes.clear()
+}
+
+// SetReserveStaleness applies the DEX config's stale reserve policy to quote paths
+func (r *Router) SetReserveStaleness(dexConfig config.DEXConfig) {
+	r.pathFinder.SetReserveStaleness(dexConfig)
+	r.quotes.clear()
+}
+
+// MaxConcurrentPaths returns the configured bound on concurrent path calculations
+func (r *Router) MaxConcurrentPaths() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.maxConcurrent
+}
+
+// InFlightQuotes returns the number of quotes currently being calculated
+func (r *Router) InFlightQuotes() int64 {
+	return r.inFlightQuotes.Load()
+}
+
+// AverageQuoteLatencyMs returns the mean latency in milliseconds of the most recent
+// quotes, cached or not, or 0 before the first quote
+func (r *Router) AverageQuoteLatencyMs() float64 {
+	return r.quoteLatency.Average() / float64(time.Millisecond)
 }
 
-// GetBestQuote finds the best trading quote with optimized path search
+// SetVolumeAccumulator sets the source of pool 24 hour volumes used on graph refresh
+func (r *Router) SetVolumeAccumulator(volumes *volume.Accumulator) {
+	r.pathFinder.SetVolumeAccumulator(volumes)
+}
+
+// SetQuoteHistory records every quote, successful or not, in quoteHistory
+func (r *Router) SetQuoteHistory(quoteHistory *history.RingBuffer) {
+	r.quoteHistory.Store(quoteHistory)
+}
+
+// ExchangeBreakerStats returns the circuit breaker stats of every exchange quoted through
+func (r *Router) ExchangeBreakerStats() map[string]circuitbreaker.ExchangeStats {
+	return r.breaker.GetStats()
+}
+
+// Calculator returns the price calculator used for quotes
+func (r *Router) Calculator() *PriceCalculator {
+	return r.calculator
+}
+
+// SetGasCosts replaces the per-exchange gas table used for path gas estimates
+func (r *Router) SetGasCosts(dexConfig config.DEXConfig) {
+	gasCosts := make(map[string]int64, len(dexConfig.GasCosts))
+	for exchange, gas := range dexConfig.GasCosts {
+		gasCosts[strings.ToLower(exchange)] = gas
+	}
+
+	baseGas := dexConfig.BaseGas
+	if baseGas == 0 {
+		baseGas = defaultBaseGas
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gasCosts = gasCosts
+	r.baseGas = baseGas
+	r.hopGas = dexConfig.HopGas
+	r.quotes.clear()
+}
+
+// UpdateConfig applies reloadable performance settings to a running router
+func (r *Router) UpdateConfig(perfConfig config.PerformanceConfig) {
+	r.calculator.SetMaxSlippage(perfConfig.MaxSlippage)
+	r.pathFinder.SetPreferHighScorePools(perfConfig.PreferHighScorePools)
+	r.pathFinder.SetPathAlgorithm(perfConfig.PathAlgorithm)
+
+	r.mu.Lock()
+	r.maxConcurrent = perfConfig.MaxConcurrentPaths
+	r.dedupPaths = perfConfig.DeduplicatePaths
+	r.pathTimeout = time.Duration(perfConfig.PathFindingTimeoutMs) * time.Millisecond
+	r.mu.Unlock()
+	r.quotes.reset(perfConfig.GraphRefreshInterval)
+
+	log.Printf("Router config updated: maxSlippage=%.2f%% maxConcurrent=%d", perfConfig.MaxSlippage, perfConfig.MaxConcurrentPaths)
+}
+
+// FindArbitrage finds profitable cycles through startToken
+func (r *Router) FindArbitrage(ctx context.Context, startToken string, minProfitBps int) ([]ArbitrageCycle, error) {
+	return r.arbitrage.FindCycles(ctx, startToken, minProfitBps)
+}
+
+// RefreshGraph rebuilds the path finder graph from the cache and drops the cached quotes
+func (r *Router) RefreshGraph(ctx context.Context) error {
+	if err := r.pathFinder.RefreshGraph(ctx); err != nil {
+		return err
+	}
+	r.quotes.clear()
+	return nil
+}
+
+// RefreshGraphAsync rebuilds the path finder graph in the background, dropping the
+// cached quotes once it is rebuilt
+func (r *Router) RefreshGraphAsync() {
+	go func() {
+		if err := r.RefreshGraph(r.pathFinder.ctx); err != nil {
+			log.Printf("Router: Background graph refresh failed: %v", err)
+		}
+	}()
+}
+
+// SaveGraph writes a snapshot of the path finder graph to w
+func (r *Router) SaveGraph(ctx context.Context, w io.Writer) error {
+	return r.pathFinder.SaveGraph(ctx, w)
+}
+
+// GraphStats returns topology metrics of the path finder graph
+func (r *Router) GraphStats() GraphStats {
+	return r.pathFinder.GraphStats()
+}
+
+// ExportGraphDOT renders the path finder graph in Graphviz DOT format
+func (r *Router) ExportGraphDOT() string {
+	return r.pathFinder.ExportGraphDOT()
+}
+
+// GetBestQuote finds the best trading quote with optimized path search. Successful
+// quotes are cached for one graph refresh interval, or until the routing config changes.
+// When req.GasToken is set, the gas cost is also converted into that token.
 func (r *Router) GetBestQuote(ctx context.Context, req *types.QuoteRequest) (*types.QuoteResponse, error) {
 	startTime := time.Now()
+	key := newQuoteCacheKey(req)
+
+	resp, cached := r.quotes.get(key)
+	var err error
+	if cached {
+		resp.ProcessingTime = time.Since(startTime).Milliseconds()
+	} else if resp, err = r.getBestQuote(ctx, req, ""); err == nil && !resp.PartialResult {
+		r.quotes.put(key, resp)
+	}
+
+	// Priced per request, so cached quotes follow the current gas token price
+	if err == nil && req.GasToken != "" {
+		resp.GasCostInGasToken = r.gasCostInGasToken(ctx, req, resp.GasEstimate)
+	}
 
-	log.Printf("Quote request: %s -> %s, amount: %s", req.TokenIn, req.TokenOut, req.AmountIn.String())
+	r.recordQuote(req, resp, err, startTime)
+	r.quoteLatency.Add(time.Since(startTime).Nanoseconds())
+	return resp, err
+}
+
+// CompareExchanges quotes req through the pools of each exchange alone, in the order given.
+// Exchanges without a route get an error instead of an output, and the exchange with the
+// most output is named best.
+func (r *Router) CompareExchanges(ctx context.Context, req *types.QuoteRequest, exchanges []string) (*types.DEXComparisonResponse, error) {
+	comparisons := make([]*types.DEXComparison, len(exchanges))
+	var best *types.DEXComparison
+	for i, exchange := range exchanges {
+		comparison := &types.DEXComparison{Exchange: exchange}
+		comparisons[i] = comparison
+
+		resp, err := r.getBestQuote(ctx, req, exchange)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			comparison.Error = err.Error()
+			continue
+		}
+		comparison.AmountOut = resp.AmountOut
+		comparison.PriceImpact = strconv.FormatFloat(resp.BestPath.PriceImpact, 'f', 4, 64)
+		if best == nil || comparison.AmountOut.Cmp(best.AmountOut) > 0 {
+			best = comparison
+		}
+	}
+
+	response := &types.DEXComparisonResponse{Comparisons: comparisons}
+	if best != nil {
+		response.BestExchange = best.Exchange
+	}
+	return response, nil
+}
+
+// recordQuote adds the outcome of a quote started at startTime to the quote history
+func (r *Router) recordQuote(req *types.QuoteRequest, resp *types.QuoteResponse, err error, startTime time.Time) {
+	quoteHistory := r.quoteHistory.Load()
+	if quoteHistory == nil {
+		return
+	}
+
+	entry := history.HistoryEntry{
+		Timestamp: startTime,
+		TokenIn:   strings.ToLower(req.TokenIn),
+		TokenOut:  strings.ToLower(req.TokenOut),
+		AmountIn:  req.AmountIn.String(),
+		LatencyMs: time.Since(startTime).Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.AmountOut = resp.AmountOut.String()
+		entry.BestPathDexes = resp.BestPath.Dexes
+	}
+	quoteHistory.Add(entry)
+}
+
+// getBestQuote quotes req through the pools of exchange, or of every exchange when it is empty
+func (r *Router) getBestQuote(ctx context.Context, req *types.QuoteRequest, exchange string) (*types.QuoteResponse, error) {
+	startTime := time.Now()
+
+	r.inFlightQuotes.Add(1)
+	defer r.inFlightQuotes.Add(-1)
 
 	tokenIn := strings.ToLower(req.TokenIn)
 	tokenOut := strings.ToLower(req.TokenOut)
 
-	log.Printf("Normalized tokens: %s -> %s", tokenIn, tokenOut)
+	// Everything logged while serving the quote carries its token pair
+	ctx = applog.WithContext(ctx, applog.TokenInKey, tokenIn, applog.TokenOutKey, tokenOut)
+	logger := applog.FromContext(ctx)
+	logger.Info("Quote request", "amountIn", req.AmountIn.String())
+
 	// Get all pools for inspection
 	allPools, err := r.cache.GetAllPools(ctx)
 	if err != nil {
-		log.Printf("Failed to get all pools: %v", err)
+		logger.Info("Failed to get all pools", "error", err)
 	} else {
-		log.Printf("Total pools in cache: %d", len(allPools))
+		logger.Info("Total pools in cache", "count", len(allPools))
 
 		// Check if there are relevant pools
 		relatedPools := 0
@@ -60,12 +375,12 @@ func (r *Router) GetBestQuote(ctx context.Context, req *types.QuoteRequest) (*ty
 			if (poolToken0 == tokenIn || poolToken1 == tokenIn) &&
 				(poolToken0 == tokenOut || poolToken1 == tokenOut) {
 				relatedPools++
-				log.Printf("Found direct pool: %s, %s/%s, reserves: %s/%s",
-					pool.Address, pool.Token0.Symbol, pool.Token1.Symbol,
-					pool.Reserve0.String(), pool.Reserve1.String())
+				logger.Info("Found direct pool", "pool", pool.Address,
+					"pair", pool.Token0.Symbol+"/"+pool.Token1.Symbol,
+					"reserve0", pool.Reserve0.String(), "reserve1", pool.Reserve1.String())
 			}
 		}
-		log.Printf("Found %d direct pools for %s->%s", relatedPools, tokenIn, tokenOut)
+		logger.Info("Found direct pools", "count", relatedPools)
 	}
 
 	// Use optimized path finding that prioritizes high-liquidity routes
@@ -81,12 +396,25 @@ func (r *Router) GetBestQuote(ctx context.Context, req *types.QuoteRequest) (*ty
 		maxPaths = 10
 	}
 
-	paths, err = r.pathFinder.FindBestPaths(ctx, tokenIn, tokenOut, req.AmountIn, req.MaxHops, maxPaths)
-	if err != nil {
+	r.mu.RLock()
+	pathTimeout := r.pathTimeout
+	r.mu.RUnlock()
+	searchCtx := ctx
+	if pathTimeout > 0 {
+		var cancel context.CancelFunc
+		searchCtx, cancel = context.WithTimeout(ctx, pathTimeout)
+		defer cancel()
+	}
+
+	paths, err = r.pathFinder.dispatch(searchCtx, tokenIn, tokenOut, req.AmountIn, req.MaxHops, maxPaths, &pathSearch{exchange: exchange})
+	partialResult := errors.Is(err, ErrPartialResult)
+	if partialResult {
+		logger.Warn("Path search timed out, quoting the paths found so far", "count", len(paths), "timeout", pathTimeout)
+	} else if err != nil {
 		return nil, err
 	}
 
-	log.Printf("Found %d possible paths in %v", len(paths), time.Since(startTime))
+	logger.Info("Found possible paths", "count", len(paths), "elapsed", time.Since(startTime))
 
 	if len(paths) == 0 {
 		return nil, fmt.Errorf("no valid path found")
@@ -94,64 +422,146 @@ func (r *Router) GetBestQuote(ctx context.Context, req *types.QuoteRequest) (*ty
 
 	// Calculate outputs for all paths with concurrency control
 	tradePaths := r.calculatePathsConcurrently(ctx, paths, req, tokenIn, tokenOut)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Calculated valid trade paths", "count", len(tradePaths), "elapsed", time.Since(startTime))
 
-	log.Printf("After calculation, found %d valid trade paths in %v", len(tradePaths), time.Since(startTime))
+	r.mu.RLock()
+	dedupPaths := r.dedupPaths
+	r.mu.RUnlock()
+	if dedupPaths {
+		calculated := len(tradePaths)
+		tradePaths = r.deduplicatePaths(tradePaths)
+		logger.Info("Deduplicated trade paths", "count", len(tradePaths), "duplicatesRemoved", calculated-len(tradePaths))
+	}
 
 	if len(tradePaths) == 0 {
 		return nil, fmt.Errorf("no valid path with positive output found")
 	}
 
+	if req.DiversifyDEX {
+		tradePaths = diversifyByDEX(tradePaths)
+		logger.Info("After DEX diversification", "count", len(tradePaths))
+	}
+
 	// Find the best path considering both output amount and gas costs
-	bestPath := r.findOptimalPath(tradePaths)
+	var gasPriceWei *big.Int
+	if req.GasPriceGwei > 0 {
+		gasPriceWei = new(big.Int).Mul(new(big.Int).SetUint64(req.GasPriceGwei), big.NewInt(1e9))
+	}
+	bestPath := r.findOptimalPath(tradePaths, gasPriceWei, req.RiskAversion)
 
-	log.Printf("Best path output amount: %s (net: %s after gas)",
-		bestPath.AmountOut.String(),
-		new(big.Int).Sub(bestPath.AmountOut, bestPath.GasCost).String())
+	logger.Info("Best path",
+		"amountOut", bestPath.AmountOut.String(),
+		"netAfterGas", new(big.Int).Sub(bestPath.AmountOut, bestPath.GasCost).String())
+
+	executionPrice, err := r.calculator.CalculateExecutionPrice(bestPath.Pools, req.AmountIn, bestPath.AmountOut, tokenIn, tokenOut)
+	if err != nil {
+		logger.Info("Failed to calculate execution price", "error", err)
+	}
 
 	totalTime := time.Since(startTime)
-	log.Printf("Total quote processing time: %v", totalTime)
+	logger.Info("Total quote processing time", "elapsed", totalTime)
 
 	return &types.QuoteResponse{
 		AmountOut:      bestPath.AmountOut,
 		Paths:          tradePaths,
 		BestPath:       bestPath,
 		GasEstimate:    bestPath.GasCost,
+		ExecutionPrice: executionPrice,
 		ProcessingTime: totalTime.Milliseconds(),
+		PartialResult:  partialResult,
 	}, nil
 }
 
+// deduplicatePaths keeps one path per set of pools, so routes through the same pools in
+// a different order are only quoted once. The survivor is the one with the most output.
+func (r *Router) deduplicatePaths(paths []*types.TradePath) []*types.TradePath {
+	index := make(map[string]int, len(paths))
+	unique := make([]*types.TradePath, 0, len(paths))
+	for _, path := range paths {
+		key := pathPoolKey(path.Pools)
+		if i, seen := index[key]; seen {
+			if path.AmountOut.Cmp(unique[i].AmountOut) > 0 {
+				unique[i] = path
+			}
+			continue
+		}
+		index[key] = len(unique)
+		unique = append(unique, path)
+	}
+	return unique
+}
+
+// pathPoolKey is the sorted, lowercase pool addresses of a path joined with "|"
+func pathPoolKey(pools []*types.Pool) string {
+	addresses := make([]string, len(pools))
+	for i, pool := range pools {
+		addresses[i] = strings.ToLower(pool.Address)
+	}
+	sort.Strings(addresses)
+	return strings.Join(addresses, "|")
+}
+
 // calculatePathsConcurrently processes paths with controlled concurrency
 func (r *Router) calculatePathsConcurrently(ctx context.Context, paths [][]*types.Pool, req *types.QuoteRequest, tokenIn, tokenOut string) []*types.TradePath {
 	var wg sync.WaitGroup
-	sem := make(chan struct{}, r.maxConcurrent) // Semaphore for limiting concurrency
+	r.mu.RLock()
+	maxConcurrent := r.maxConcurrent
+	r.mu.RUnlock()
+
+	logger := applog.FromContext(ctx)
+	sem := make(chan struct{}, maxConcurrent) // Semaphore for limiting concurrency
 	resultsChan := make(chan *types.TradePath, len(paths))
 	errorChan := make(chan error, len(paths))
 
 	for i, path := range paths {
 		wg.Add(1)
 
-		go func(p []*types.Pool, pathIndex int) {
+		go func(p []*types.Pool, pathIndex int, breaker *circuitbreaker.ExchangeBreaker) {
 			defer wg.Done()
 
-			// Acquire semaphore
-			sem <- struct{}{}
+			// Acquire semaphore, giving up if the request is cancelled while queued
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
 			defer func() { <-sem }()
 
-			log.Printf("Calculating path %d with %d pools", pathIndex+1, len(p))
+			if ctx.Err() != nil {
+				return
+			}
+
+			logger.Info("Calculating path", "path", pathIndex+1, "pools", len(p))
 			for j, pool := range p {
-				log.Printf("  Pool %d: %s, %s/%s, reserves: %s/%s",
-					j+1, pool.Exchange, pool.Token0.Symbol, pool.Token1.Symbol,
-					pool.Reserve0.String(), pool.Reserve1.String())
+				logger.Info("Path pool", "path", pathIndex+1, "hop", j+1, "exchange", pool.Exchange,
+					"pair", pool.Token0.Symbol+"/"+pool.Token1.Symbol,
+					"reserve0", pool.Reserve0.String(), "reserve1", pool.Reserve1.String())
 			}
 
-			amountOut, err := r.calculator.CalculatePathOutput(p, req.AmountIn, tokenIn, tokenOut)
+			hops, err := r.calculator.CalculatePathHops(ctx, p, req.AmountIn, tokenIn, tokenOut)
 			if err != nil {
-				log.Printf("Path %d calculation failed: %v", pathIndex+1, err)
+				logger.Info("Path calculation failed", "path", pathIndex+1, "error", err)
+				// Trades too large for a pool are the client's doing, not the exchange's
+				var hopErr *hopError
+				if errors.As(err, &hopErr) && !isInputError(hopErr.err) {
+					r.pathFinder.recordExchangeFailure(breaker, hopErr.pool.Exchange)
+				}
 				errorChan <- err
 				return
 			}
+			for _, pool := range p {
+				breaker.RecordSuccess(pool.Exchange)
+			}
+			if len(hops) == 0 {
+				return
+			}
+			amountOut := hops[len(hops)-1].AmountOut
 
-			log.Printf("Path %d raw output: %s", pathIndex+1, amountOut.String())
+			logger.Info("Path raw output", "path", pathIndex+1, "amountOut", amountOut.String())
 
 			if amountOut.Cmp(big.NewInt(0)) <= 0 {
 				return
@@ -159,15 +569,35 @@ func (r *Router) calculatePathsConcurrently(ctx context.Context, paths [][]*type
 
 			gasCost := r.estimateGasCost(p)
 
+			priceImpact, err := r.calculator.CalculatePathPriceImpact(p, req.AmountIn, amountOut, tokenIn)
+			if err != nil {
+				logger.Info("Path price impact failed", "path", pathIndex+1, "error", err)
+			}
+
+			var slippage float64
+			for _, hop := range hops {
+				slippage += hop.PriceImpact
+			}
+
 			tradePath := &types.TradePath{
-				Pools:     p,
-				AmountOut: amountOut,
-				Dexes:     r.getDexesFromPath(p),
-				GasCost:   gasCost,
+				Pools:           p,
+				AmountIn:        new(big.Int).Set(req.AmountIn),
+				AmountOut:       amountOut,
+				Dexes:           r.getDexesFromPath(p),
+				GasCost:         gasCost,
+				PriceImpact:     priceImpact,
+				SlippagePercent: slippage,
+				HopDetails:      hops,
+			}
+
+			// Leave paths the caller would not accept out of route selection
+			if req.MaxSlippage > 0 && !tradePath.IsWithinSlippageTolerance(req.MaxSlippage) {
+				logger.Info("Path exceeds slippage tolerance", "path", pathIndex+1, "slippagePercent", slippage, "maxSlippage", req.MaxSlippage)
+				return
 			}
 
 			resultsChan <- tradePath
-		}(path, i)
+		}(path, i, r.breaker)
 	}
 
 	go func() {
@@ -187,48 +617,102 @@ func (r *Router) calculatePathsConcurrently(ctx context.Context, paths [][]*type
 		errorCount++
 	}
 	if errorCount > 0 {
-		log.Printf("%d paths had calculation errors", errorCount)
+		logger.Info("Paths had calculation errors", "count", errorCount)
 	}
 
 	return tradePaths
 }
 
-// findOptimalPath finds the best path considering both output and gas costs
-func (r *Router) findOptimalPath(tradePaths []*types.TradePath) *types.TradePath {
+// findOptimalPath finds the best path considering output, gas costs and slippage.
+// With a gas price or a positive risk aversion, paths are ranked by netUtility and
+// NetAmountOut is filled in; without either, they are ranked by raw output.
+func (r *Router) findOptimalPath(tradePaths []*types.TradePath, gasPriceWei *big.Int, riskAversion float64) *types.TradePath {
 	if len(tradePaths) == 0 {
 		return nil
 	}
 
-	// Sort by raw output amount (highest first)
-	sort.Slice(tradePaths, func(i, j int) bool {
-		return tradePaths[i].AmountOut.Cmp(tradePaths[j].AmountOut) > 0
+	if (gasPriceWei == nil || gasPriceWei.Sign() <= 0) && riskAversion <= 0 {
+		// Sort by raw output amount (highest first), keeping the path finder's order on ties
+		sort.SliceStable(tradePaths, func(i, j int) bool {
+			return tradePaths[i].AmountOut.Cmp(tradePaths[j].AmountOut) > 0
+		})
+		return tradePaths[0]
+	}
+
+	for _, tradePath := range tradePaths {
+		tradePath.NetAmountOut = netUtility(tradePath, gasPriceWei, riskAversion)
+	}
+
+	// Sort by output net of gas and slippage penalty (highest first)
+	sort.SliceStable(tradePaths, func(i, j int) bool {
+		return tradePaths[i].NetAmountOut.Cmp(tradePaths[j].NetAmountOut) > 0
 	})
 
-	// Return path with highest output
-	bestPath := tradePaths[0]
-	return bestPath
+	return tradePaths[0]
+}
+
+// diversifyByDEX keeps only the highest-output path for each exchange sequence,
+// preserving the order in which sequences first appear
+func diversifyByDEX(tradePaths []*types.TradePath) []*types.TradePath {
+	best := make(map[string]int, len(tradePaths))
+	var result []*types.TradePath
+
+	for _, tradePath := range tradePaths {
+		key := strings.Join(tradePath.Dexes, ",")
+		if i, ok := best[key]; ok {
+			if tradePath.AmountOut.Cmp(result[i].AmountOut) > 0 {
+				result[i] = tradePath
+			}
+			continue
+		}
+		best[key] = len(result)
+		result = append(result, tradePath)
+	}
+
+	return result
+}
+
+// netScore returns the path output minus its gas cost in wei. Gas is assumed to be
+// denominated in tokenOut, i.e. a tokenOut price of one per wei.
+func netScore(path *types.TradePath, gasPriceWei *big.Int) *big.Int {
+	gasCostWei := new(big.Int).Mul(path.GasCost, gasPriceWei)
+	return new(big.Int).Sub(path.AmountOut, gasCostWei)
+}
+
+// netUtility returns the path output minus its gas cost in wei, when gasPriceWei is set,
+// and minus a slippage penalty of amountOut * priceImpact / 100 * riskAversion
+func netUtility(path *types.TradePath, gasPriceWei *big.Int, riskAversion float64) *big.Int {
+	utility := new(big.Int).Set(path.AmountOut)
+	if gasPriceWei != nil && gasPriceWei.Sign() > 0 {
+		utility = netScore(path, gasPriceWei)
+	}
+
+	if riskAversion > 0 && path.PriceImpact > 0 {
+		penalty := new(big.Float).SetInt(path.AmountOut)
+		penalty.Mul(penalty, big.NewFloat(path.PriceImpact/100*riskAversion))
+		penaltyInt, _ := penalty.Int(nil)
+		utility.Sub(utility, penaltyInt)
+	}
+
+	return utility
 }
 
 // estimateGasCost provides more accurate gas estimation based on DEX type
 func (r *Router) estimateGasCost(path []*types.Pool) *big.Int {
-	baseGas := big.NewInt(21000) // Base transaction gas
-	swapGas := big.NewInt(0)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
+	gas := r.baseGas
 	for _, pool := range path {
 		// Different DEXes have different gas costs
-		var poolGas int64
-		switch strings.ToLower(pool.Exchange) {
-		case "uniswap v2":
-			poolGas = 100000
-		case "sushiswap":
-			poolGas = 120000
-		default:
-			poolGas = 110000 // Default estimate
+		poolGas, ok := r.gasCosts[strings.ToLower(pool.Exchange)]
+		if !ok {
+			poolGas = defaultSwapGas
 		}
-		swapGas.Add(swapGas, big.NewInt(poolGas))
+		gas += poolGas + r.hopGas
 	}
 
-	return new(big.Int).Add(baseGas, swapGas)
+	return big.NewInt(gas)
 }
 
 func (r *Router) getDexesFromPath(path []*types.Pool) []string {
@@ -0,0 +1,106 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dex-aggregator/config"
+	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// updateGolden rewrites the golden files from the current routing output. Run
+// UPDATE_GOLDEN=true go test ./internal/aggregator -run TestQuoteSnapshots after a
+// deliberate routing change and review the diff.
+var updateGolden = os.Getenv("UPDATE_GOLDEN") == "true"
+
+// snapshotRequest is one entry of testdata/requests.json
+type snapshotRequest struct {
+	Name    string              `json:"name"`
+	Request *types.QuoteRequest `json:"request"`
+}
+
+// goldenQuote is the routing decision of a quote: the fields a path-finding change must
+// not alter by accident. ProcessingTime and gas vary between runs and are left out.
+type goldenQuote struct {
+	AmountOut string          `json:"amountOut,omitempty"`
+	PathCount int             `json:"pathCount"`
+	BestPath  *goldenBestPath `json:"bestPath,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+type goldenBestPath struct {
+	Pools     []string `json:"pools"`
+	Dexes     []string `json:"dexes"`
+	AmountOut string   `json:"amountOut"`
+}
+
+func newGoldenQuote(resp *types.QuoteResponse, err error) *goldenQuote {
+	if err != nil {
+		return &goldenQuote{Error: err.Error()}
+	}
+	golden := &goldenQuote{AmountOut: resp.AmountOut.String(), PathCount: len(resp.Paths)}
+	if resp.BestPath != nil {
+		golden.BestPath = &goldenBestPath{Dexes: resp.BestPath.Dexes, AmountOut: resp.BestPath.AmountOut.String()}
+		for _, pool := range resp.BestPath.Pools {
+			golden.BestPath.Pools = append(golden.BestPath.Pools, pool.Address)
+		}
+	}
+	return golden
+}
+
+func readTestdata(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, v))
+}
+
+// TestQuoteSnapshots quotes the requests in testdata/requests.json against the pools in
+// testdata/pools.json and compares each result with testdata/responses_golden. Missing
+// golden files are written, as are all of them with UPDATE_GOLDEN=true.
+func TestQuoteSnapshots(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	var pools []*types.Pool
+	readTestdata(t, "pools.json", &pools)
+	var requests []snapshotRequest
+	readTestdata(t, "requests.json", &requests)
+
+	ctx := context.Background()
+	store := cache.NewMemoryStore()
+	for _, pool := range pools {
+		require.NoError(t, store.StorePool(ctx, pool))
+	}
+	router := NewRouter(ctx, store, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10})
+
+	for _, snapshot := range requests {
+		t.Run(snapshot.Name, func(t *testing.T) {
+			actual := newGoldenQuote(router.GetBestQuote(ctx, snapshot.Request))
+			path := filepath.Join("testdata", "responses_golden", snapshot.Name+".json")
+
+			data, err := os.ReadFile(path)
+			if updateGolden || os.IsNotExist(err) {
+				data, err := json.MarshalIndent(actual, "", "  ")
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(path, append(data, '\n'), 0o644))
+				t.Logf("wrote %s", path)
+				return
+			}
+			require.NoError(t, err)
+
+			var expected goldenQuote
+			require.NoError(t, json.Unmarshal(data, &expected))
+			assert.Equal(t, expected, *actual, "routing differs from %s; rerun with UPDATE_GOLDEN=true if the change is intended", path)
+		})
+	}
+}
@@ -1,112 +1,1325 @@
 package api
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"math"
 	"math/big"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"dex-aggregator/config"
 	"dex-aggregator/internal/aggregator"
+	"dex-aggregator/internal/api/middleware"
 	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/graph"
+	"dex-aggregator/internal/health"
+	"dex-aggregator/internal/history"
+	applog "dex-aggregator/internal/log"
+	"dex-aggregator/internal/monitor"
+	"dex-aggregator/internal/resolver"
 	"dex-aggregator/internal/types"
+	"dex-aggregator/internal/validation"
+	"dex-aggregator/internal/volume"
 
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/gorilla/mux"
 )
 
+// healthCheckTimeout bounds the total time spent running health sub-checks
+const healthCheckTimeout = 2 * time.Second
+
 type Handler struct {
-	router *aggregator.Router
-	cache  cache.Store
+	router        *aggregator.Router
+	cache         cache.Store
+	tokenResolver *resolver.TokenResolver
+	healthChecks  map[string]health.Checker
+	volumes       *volume.Accumulator
+	nameResolver  NameResolver
+	quoteHistory  *history.RingBuffer
+	poolHistory   *history.PoolHistory
+	priceMonitor  *monitor.PriceDeviationMonitor
+	symbols       *resolver.SymbolCache
+}
+
+// NameResolver maps ENS names to addresses, returning addresses unchanged
+type NameResolver interface {
+	Resolve(ctx context.Context, nameOrAddress string) (string, error)
+}
+
+// NewHandler serves the API from router and cache, naming tokens with symbols
+func NewHandler(router *aggregator.Router, cache cache.Store, symbols *resolver.SymbolCache) *Handler {
+	return &Handler{
+		router:       router,
+		cache:        cache,
+		healthChecks: make(map[string]health.Checker),
+		priceMonitor: monitor.NewPriceDeviationMonitor(),
+		symbols:      symbols,
+	}
+}
+
+// SetTokenResolver enables token metadata enrichment in GetTokens
+func (h *Handler) SetTokenResolver(tokenResolver *resolver.TokenResolver) {
+	h.tokenResolver = tokenResolver
+}
+
+// SetNameResolver lets GetQuote accept ENS names for tokenIn and tokenOut
+func (h *Handler) SetNameResolver(nameResolver NameResolver) {
+	h.nameResolver = nameResolver
+}
+
+// SetVolumeAccumulator records pool reserve changes as swap volume and enables GetPoolVolume
+func (h *Handler) SetVolumeAccumulator(volumes *volume.Accumulator) {
+	h.volumes = volumes
+}
+
+// SetQuoteHistory enables GetQuoteHistory, serving the quotes recorded in quoteHistory
+func (h *Handler) SetQuoteHistory(quoteHistory *history.RingBuffer) {
+	h.quoteHistory = quoteHistory
+}
+
+// SetPoolHistory enables GetPoolHistory, serving the reserve snapshots in poolHistory
+func (h *Handler) SetPoolHistory(poolHistory *history.PoolHistory) {
+	h.poolHistory = poolHistory
+}
+
+// AddHealthCheck registers a sub-check reported by HealthCheck under name
+func (h *Handler) AddHealthCheck(name string, checker health.Checker) {
+	h.healthChecks[name] = checker
+}
+
+func (h *Handler) GetQuote(w http.ResponseWriter, r *http.Request) {
+	logger := applog.FromContext(r.Context())
+
+	req, maxHopsAdjusted, ok := h.decodeQuoteRequest(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.router.GetBestQuote(r.Context(), req)
+	if err != nil {
+		logger.Info("Quote calculation failed", "error", err)
+		http.Error(w, "Quote calculation failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp.MaxHopsAdjusted = maxHopsAdjusted
+
+	logger.Info("Quote successful", "amountIn", req.AmountIn.String(), "amountOut", resp.AmountOut.String())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ExplainQuote computes a quote and returns a plain-language explanation of its route
+func (h *Handler) ExplainQuote(w http.ResponseWriter, r *http.Request) {
+	req, _, ok := h.decodeQuoteRequest(w, r)
+	if !ok {
+		return
+	}
+
+	explanation, err := h.router.ExplainQuote(r.Context(), req)
+	if err != nil {
+		applog.FromContext(r.Context()).Info("Quote explanation failed", "error", err)
+		http.Error(w, "Quote calculation failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(explanation)
+}
+
+// GetDEXComparison quotes a trade through each configured exchange on its own, so
+// traders can compare exchanges side by side
+func (h *Handler) GetDEXComparison(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := &types.QuoteRequest{TokenIn: query.Get("tokenIn"), TokenOut: query.Get("tokenOut")}
+	if req.TokenIn == "" || req.TokenOut == "" {
+		http.Error(w, "tokenIn and tokenOut are required", http.StatusBadRequest)
+		return
+	}
+	if !h.resolveTokens(w, r, &req.TokenIn, &req.TokenOut) {
+		return
+	}
+
+	amountIn, ok := new(big.Int).SetString(query.Get("amountIn"), 10)
+	if !ok || amountIn.Sign() <= 0 {
+		http.Error(w, "Invalid input amount", http.StatusBadRequest)
+		return
+	}
+	if !checkAmountLimits(w, r, amountIn) {
+		return
+	}
+	req.AmountIn = amountIn
+
+	configured := config.Current().DEX.Exchanges
+	exchanges := make([]string, len(configured))
+	for i, exchange := range configured {
+		exchanges[i] = exchange.Name
+	}
+
+	comparison, err := h.router.CompareExchanges(r.Context(), req, exchanges)
+	if err != nil {
+		applog.FromContext(r.Context()).Info("DEX comparison failed", "error", err)
+		http.Error(w, "Quote calculation failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comparison)
+}
+
+// decodeQuoteRequest reads and validates a quote request, clamping maxHops to the server
+// limit. It writes a 400 response and returns false when the request is invalid.
+func (h *Handler) decodeQuoteRequest(w http.ResponseWriter, r *http.Request) (req *types.QuoteRequest, maxHopsAdjusted, ok bool) {
+	logger := applog.FromContext(r.Context())
+	cfg := config.Current()
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		logger.Info("Invalid content type", "contentType", contentType)
+		http.Error(w, "Content-Type must be application/json", http.StatusBadRequest)
+		return nil, false, false
+	}
+
+	req = &types.QuoteRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		logger.Info("Failed to decode JSON", "error", err)
+		http.Error(w, "Invalid JSON format: "+err.Error(), http.StatusBadRequest)
+		return nil, false, false
+	}
+
+	logger.Info("Quote request", "tokenIn", req.TokenIn, "tokenOut", req.TokenOut, "amountIn", req.AmountIn.String())
+
+	if req.TokenIn == "" || req.TokenOut == "" {
+		http.Error(w, "tokenIn and tokenOut are required", http.StatusBadRequest)
+		return nil, false, false
+	}
+
+	if !h.resolveTokens(w, r, &req.TokenIn, &req.TokenOut) {
+		return nil, false, false
+	}
+
+	if req.AmountIn == nil || req.AmountIn.Cmp(big.NewInt(0)) <= 0 {
+		http.Error(w, "Invalid input amount", http.StatusBadRequest)
+		return nil, false, false
+	}
+	if !checkAmountLimits(w, r, req.AmountIn) {
+		return nil, false, false
+	}
+
+	if req.MaxSlippage < 0 || req.MaxSlippage > 100 || math.IsNaN(req.MaxSlippage) {
+		writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_SLIPPAGE", Message: "maxSlippage must be between 0 and 100"})
+		return nil, false, false
+	}
+
+	if req.MaxHops == 0 {
+		req.MaxHops = 3
+	}
+
+	// Never trust the client's hop count beyond the server limit
+	if req.MaxHops < 1 {
+		req.MaxHops = 1
+	}
+	if limit := cfg.Performance.MaxHops; limit > 0 && req.MaxHops > limit {
+		logger.Info("Clamping maxHops to server limit", "maxHops", req.MaxHops, "limit", limit)
+		req.MaxHops = limit
+		maxHopsAdjusted = true
+	}
+
+	return req, maxHopsAdjusted, true
+}
+
+// checkAmountLimits applies the configured DEX.MaxAmountInWei to each amount a request
+// would quote and logs amounts above DEX.LargeAmountWarningWei. It writes a 400 response
+// and returns false when an amount is over the limit.
+func checkAmountLimits(w http.ResponseWriter, r *http.Request, amounts ...*big.Int) bool {
+	logger := applog.FromContext(r.Context())
+	dexConfig := config.Current().DEX
+
+	limit := parseAmountLimit(dexConfig.MaxAmountInWei)
+	threshold := parseAmountLimit(dexConfig.LargeAmountWarningWei)
+	for _, amount := range amounts {
+		if limit != nil && amount.Cmp(limit) > 0 {
+			logger.Info("Rejecting quote amount above limit", "amountIn", amount.String(), "limit", limit.String())
+			writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_AMOUNT_TOO_LARGE", Message: "amountIn must not exceed " + limit.String()})
+			return false
+		}
+	}
+	for _, amount := range amounts {
+		if threshold != nil && amount.Cmp(threshold) > 0 {
+			logger.Warn("Large quote amount", "amountIn", amount.String(), "threshold", threshold.String())
+		}
+	}
+	return true
+}
+
+// parseAmountLimit reads a configured wei amount, returning nil when it is unset or
+// not a positive integer
+func parseAmountLimit(value string) *big.Int {
+	limit, ok := new(big.Int).SetString(value, 10)
+	if !ok || limit.Sign() <= 0 {
+		return nil
+	}
+	return limit
+}
+
+// resolveTokens replaces ENS names with addresses and validates the addresses. It writes
+// a 400 response and returns false when a token cannot be resolved or is invalid.
+func (h *Handler) resolveTokens(w http.ResponseWriter, r *http.Request, tokens ...*string) bool {
+	if h.nameResolver != nil {
+		for _, token := range tokens {
+			address, err := h.nameResolver.Resolve(r.Context(), *token)
+			if err != nil {
+				log.Printf("Failed to resolve %s: %v", *token, err)
+				writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_ENS_NOT_FOUND", Message: "could not resolve " + *token})
+				return false
+			}
+			*token = address
+		}
+	}
+
+	for _, token := range tokens {
+		if err := validateEthAddress(*token); err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return false
+		}
+	}
+	return true
+}
+
+// Simulate quotes each requested amount concurrently and returns the resulting price
+// curve. Amounts that cannot be quoted carry an error instead of failing the request.
+func (h *Handler) Simulate(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusBadRequest)
+		return
+	}
+
+	var req types.SimulateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.TokenIn == "" || req.TokenOut == "" {
+		http.Error(w, "tokenIn and tokenOut are required", http.StatusBadRequest)
+		return
+	}
+	if !h.resolveTokens(w, r, &req.TokenIn, &req.TokenOut) {
+		return
+	}
+
+	if len(req.Amounts) == 0 {
+		writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_MISSING_FIELD", Message: "amounts is required"})
+		return
+	}
+	if len(req.Amounts) > types.MaxSimulateAmounts {
+		writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_TOO_MANY_AMOUNTS", Message: fmt.Sprintf("at most %d amounts are allowed", types.MaxSimulateAmounts)})
+		return
+	}
+	for _, amount := range req.Amounts {
+		if amount.Sign() <= 0 {
+			writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_AMOUNT", Message: "amounts must be positive"})
+			return
+		}
+	}
+	if !checkAmountLimits(w, r, req.Amounts...) {
+		return
+	}
+
+	maxHops := 3
+	if limit := config.Current().Performance.MaxHops; limit > 0 && maxHops > limit {
+		maxHops = limit
+	}
+
+	curve := make([]*types.SimulatePoint, len(req.Amounts))
+	sem := make(chan struct{}, max(h.router.MaxConcurrentPaths(), 1))
+	var wg sync.WaitGroup
+	for i, amount := range req.Amounts {
+		wg.Add(1)
+		go func(i int, amount *big.Int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			point := &types.SimulatePoint{AmountIn: amount}
+			curve[i] = point
+
+			resp, err := h.router.GetBestQuote(r.Context(), &types.QuoteRequest{
+				TokenIn:  req.TokenIn,
+				TokenOut: req.TokenOut,
+				AmountIn: amount,
+				MaxHops:  maxHops,
+			})
+			if err != nil {
+				point.Error = err.Error()
+				return
+			}
+			point.AmountOut = resp.AmountOut
+			point.PriceImpact = strconv.FormatFloat(resp.BestPath.PriceImpact, 'f', 4, 64)
+		}(i, amount)
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&types.SimulateResponse{Curve: curve})
+}
+
+// MultiQuote splits one input across several output tokens and quotes every leg
+func (h *Handler) MultiQuote(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusBadRequest)
+		return
+	}
+
+	var req types.MultiQuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.TokenIn == "" {
+		writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_MISSING_FIELD", Message: "tokenIn is required"})
+		return
+	}
+	if len(req.Targets) == 0 {
+		writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_MISSING_FIELD", Message: "targets is required"})
+		return
+	}
+	if len(req.Targets) > types.MaxMultiQuoteTargets {
+		writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_TOO_MANY_TARGETS", Message: fmt.Sprintf("at most %d targets are allowed", types.MaxMultiQuoteTargets)})
+		return
+	}
+
+	tokens := []*string{&req.TokenIn}
+	var fractions uint
+	for i := range req.Targets {
+		tokens = append(tokens, &req.Targets[i].TokenOut)
+		fractions += req.Targets[i].Fraction
+	}
+	if !h.resolveTokens(w, r, tokens...) {
+		return
+	}
+	if fractions != types.MultiQuoteFractionTotal {
+		writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_FRACTIONS", Message: fmt.Sprintf("target fractions must sum to %d basis points", types.MultiQuoteFractionTotal)})
+		return
+	}
+	if req.AmountIn == nil || req.AmountIn.Sign() <= 0 {
+		writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_AMOUNT", Message: "amountIn must be positive"})
+		return
+	}
+	if !checkAmountLimits(w, r, req.AmountIn) {
+		return
+	}
+
+	if req.MaxHops < 1 {
+		req.MaxHops = 3
+	}
+	if limit := config.Current().Performance.MaxHops; limit > 0 && req.MaxHops > limit {
+		req.MaxHops = limit
+	}
+
+	resp, err := h.router.GetMultiQuote(r.Context(), &req)
+	if err != nil {
+		log.Printf("Multi-quote calculation failed: %v", err)
+		http.Error(w, "Quote calculation failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetArbitrage lists profitable cycles through startToken
+func (h *Handler) GetArbitrage(w http.ResponseWriter, r *http.Request) {
+	startToken := r.URL.Query().Get("startToken")
+	if startToken == "" {
+		http.Error(w, "startToken parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateEthAddress(startToken); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	minProfitBps := 0
+	if value := r.URL.Query().Get("minProfitBps"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid minProfitBps", http.StatusBadRequest)
+			return
+		}
+		minProfitBps = parsed
+	}
+
+	cycles, err := h.router.FindArbitrage(r.Context(), startToken, minProfitBps)
+	if err != nil {
+		log.Printf("Arbitrage search failed: %v", err)
+		http.Error(w, "Arbitrage search failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"startToken":   NormalizeAddress(startToken),
+		"minProfitBps": minProfitBps,
+		"count":        len(cycles),
+		"cycles":       cycles,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HealthCheck runs the registered sub-checks and responds 503 when any is unhealthy
+func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	checks := make(map[string]health.CheckResult, len(h.healthChecks))
+	for name, checker := range h.healthChecks {
+		checks[name] = checker.Check(ctx)
+	}
+
+	status := health.Overall(checks)
+	code := http.StatusOK
+	if status == health.StatusUnhealthy {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":            status,
+		"checks":            checks,
+		"inFlightQuotes":    h.router.InFlightQuotes(),
+		"avgQuoteLatencyMs": h.router.AverageQuoteLatencyMs(),
+	})
+}
+
+// scoredPool is a pool with its liquidity score, as listed by GetPools
+type scoredPool struct {
+	*types.Pool
+	LiquidityScore float64
+}
+
+// MarshalJSON adds liquidityScore to the pool's own JSON encoding
+func (sp scoredPool) MarshalJSON() ([]byte, error) {
+	return marshalPoolWithField(sp.Pool, "liquidityScore", sp.LiquidityScore)
+}
+
+// marshalPoolWithField encodes pool with an extra top-level field
+func marshalPoolWithField(pool *types.Pool, key string, value interface{}) ([]byte, error) {
+	data, err := json.Marshal(pool)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	fields[key] = encoded
+
+	return json.Marshal(fields)
+}
+
+// poolCounter is a store that counts its pools without loading them, such as
+// cache.MemoryStore and cache.TwoLevelCache
+type poolCounter interface {
+	Count() int64
 }
 
-func NewHandler(router *aggregator.Router, cache cache.Store) *Handler {
-	return &Handler{
-		router: router,
-		cache:  cache,
+// GetPools lists the pools matching the exchange and minReserve0 filters. With
+// countOnly=true only the count is returned, read from the store's counter when there
+// are no filters and the store keeps one.
+func (h *Handler) GetPools(w http.ResponseWriter, r *http.Request) {
+	exchange := r.URL.Query().Get("exchange")
+	countOnly := false
+	if value := r.URL.Query().Get("countOnly"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "Invalid countOnly", http.StatusBadRequest)
+			return
+		}
+		countOnly = parsed
+	}
+
+	var minReserve0 *big.Int
+	if value := r.URL.Query().Get("minReserve0"); value != "" {
+		parsed, ok := new(big.Int).SetString(value, 10)
+		if !ok || parsed.Sign() < 0 {
+			http.Error(w, "Invalid minReserve0", http.StatusBadRequest)
+			return
+		}
+		minReserve0 = parsed
+	}
+
+	if counter, ok := h.cache.(poolCounter); ok && countOnly && exchange == "" && minReserve0 == nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"count":   counter.Count(),
+			"filters": map[string]string{},
+		})
+		return
+	}
+
+	pools, err := h.cache.GetAllPools(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch pools: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	filtered := make([]*types.Pool, 0, len(pools))
+	for _, pool := range pools {
+		if exchange != "" && !strings.EqualFold(pool.Exchange, exchange) {
+			continue
+		}
+		if minReserve0 != nil && (pool.Reserve0 == nil || pool.Reserve0.Cmp(minReserve0) < 0) {
+			continue
+		}
+		filtered = append(filtered, pool)
+	}
+
+	filters := make(map[string]string)
+	if exchange != "" {
+		filters["exchange"] = exchange
+	}
+	if minReserve0 != nil {
+		filters["minReserve0"] = minReserve0.String()
+	}
+
+	if countOnly {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"count":   len(filtered),
+			"filters": filters,
+		})
+		return
+	}
+
+	w.Header().Set("Cache-Control", poolsCacheControl)
+	if len(filtered) > streamPoolsThreshold {
+		writePoolsStreaming(w, filtered, filters)
+		return
+	}
+
+	scored := make([]scoredPool, len(filtered))
+	for i, pool := range filtered {
+		scored[i] = scoredPool{Pool: pool, LiquidityScore: pool.LiquidityScore()}
+	}
+
+	response := map[string]interface{}{
+		"count":   len(scored),
+		"pools":   scored,
+		"filters": filters,
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(response); err != nil {
+		http.Error(w, "Failed to encode pools: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	middleware.WriteWithETag(w, r, body.Bytes())
+}
+
+// streamPoolsThreshold is the pool count above which GetPools streams its response
+// rather than marshalling every pool into memory first. Streamed responses carry no
+// ETag, since hashing them would need the whole body first.
+const streamPoolsThreshold = 500
+
+// poolsCacheControl lets clients reuse a pool list for 10 seconds
+var poolsCacheControl = middleware.CacheControl(10, 30)
+
+// writePoolsStreaming writes the GetPools response for pools without buffering it. The
+// keys come out in the same order as json.Encoder writes the response map.
+func writePoolsStreaming(w http.ResponseWriter, pools []*types.Pool, filters map[string]string) {
+	filtersJSON, err := json.Marshal(filters)
+	if err != nil {
+		http.Error(w, "Failed to encode filters: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, `{"count":`+strconv.Itoa(len(pools))+`,"filters":`)
+	w.Write(filtersJSON)
+	io.WriteString(w, `,"pools":`)
+	if _, err := (types.PoolSliceJSON{Pools: pools, IncludeLiquidityScore: true}).WriteTo(w); err != nil {
+		log.Printf("Failed to stream %d pools: %v", len(pools), err)
+		return
+	}
+	io.WriteString(w, "}\n")
+}
+
+// CreatePool stores an operator-supplied pool and refreshes the routing graph.
+// Re-posting an existing pool updates it in place and returns 200 instead of 201.
+func (h *Handler) CreatePool(w http.ResponseWriter, r *http.Request) {
+	var pool types.Pool
+	if err := json.NewDecoder(r.Body).Decode(&pool); err != nil {
+		http.Error(w, "Invalid JSON format: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if status, err := validatePoolFields(&pool, validateChecksumAddress); err != nil {
+		writeAPIError(w, status, err)
+		return
+	}
+	// The store stamps the creation time when the pool is first stored
+	pool.CreatedAt = time.Time{}
+
+	_, err := h.cache.GetPool(r.Context(), pool.Address)
+	exists := err == nil
+
+	pool.LastUpdated = time.Now()
+	if err := h.cache.StorePool(r.Context(), &pool); err != nil {
+		switch {
+		case errors.Is(err, validation.ErrInsufficientLiquidity):
+			writeAPIError(w, http.StatusUnprocessableEntity, &types.APIError{Code: "ERR_INSUFFICIENT_LIQUIDITY", Message: err.Error()})
+		case errors.Is(err, validation.ErrInvalidAddress):
+			writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_ADDRESS", Message: err.Error()})
+		case errors.Is(err, validation.ErrInvalidFee):
+			writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_FEE", Message: err.Error()})
+		default:
+			http.Error(w, "Failed to store pool: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Printf("Pool imported: %s (%s), existing=%v", pool.Address, pool.Exchange, exists)
+
+	// Refresh under the router's own context: the request ends before the refresh does
+	h.router.RefreshGraphAsync()
+
+	status := http.StatusCreated
+	if exists {
+		status = http.StatusOK
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&pool)
+}
+
+// ExportPools streams every cached pool as newline-delimited JSON, one pool per line,
+// for ImportPools to restore
+func (h *Handler) ExportPools(w http.ResponseWriter, r *http.Request) {
+	pools, err := h.cache.GetAllPools(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch pools: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Transfer-Encoding", "chunked")
+	flusher, _ := w.(http.Flusher)
+
+	encoder := json.NewEncoder(w)
+	for i, pool := range pools {
+		if err := encoder.Encode(pool); err != nil {
+			log.Printf("Pool export aborted after %d of %d pools: %v", i, len(pools), err)
+			return
+		}
+		if flusher != nil && (i+1)%exportFlushInterval == 0 {
+			flusher.Flush()
+		}
+	}
+	log.Printf("Exported %d pools", len(pools))
+}
+
+// exportFlushInterval is the number of pools ExportPools writes between flushes
+const exportFlushInterval = 100
+
+// poolCSVHeader names the columns written by ExportPoolsCSV
+var poolCSVHeader = []string{
+	"address", "exchange",
+	"token0_address", "token0_symbol", "token1_address", "token1_symbol",
+	"reserve0", "reserve1", "fee", "last_updated",
+}
+
+// ExportPoolsCSV writes the stored pools as a CSV attachment for analytics tools, one
+// row per pool under a poolCSVHeader row. The exchange query parameter keeps only the
+// pools of one exchange, matched ignoring case.
+func (h *Handler) ExportPoolsCSV(w http.ResponseWriter, r *http.Request) {
+	exchange := r.URL.Query().Get("exchange")
+
+	pools, err := h.cache.GetAllPools(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch pools: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=pools.csv")
+
+	writer := csv.NewWriter(w)
+	writer.Write(poolCSVHeader)
+	rows := 0
+	for _, pool := range pools {
+		if exchange != "" && !strings.EqualFold(pool.Exchange, exchange) {
+			continue
+		}
+		lastUpdated := ""
+		if !pool.LastUpdated.IsZero() {
+			lastUpdated = pool.LastUpdated.UTC().Format(time.RFC3339)
+		}
+		writer.Write([]string{
+			csvText(pool.Address), csvText(pool.Exchange),
+			csvText(pool.Token0.Address), csvText(pool.Token0.Symbol), csvText(pool.Token1.Address), csvText(pool.Token1.Symbol),
+			bigIntString(pool.Reserve0), bigIntString(pool.Reserve1),
+			strconv.Itoa(pool.Fee), lastUpdated,
+		})
+		rows++
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("Pool CSV export aborted: %v", err)
+		return
+	}
+	log.Printf("Exported %d pools as CSV", rows)
+}
+
+// csvText escapes a free-text cell so spreadsheets don't evaluate it as a formula, by
+// prefixing a ' to values starting with a formula character. Symbols and exchange names
+// come from token contracts and API clients, so they can't be trusted.
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// bigIntString formats n in decimal, writing a nil reserve as an empty cell
+func bigIntString(n *big.Int) string {
+	if n == nil {
+		return ""
+	}
+	return n.String()
+}
+
+// maxImportLineBytes bounds a single pool line read by ImportPools
+const maxImportLineBytes = 1 << 20
+
+// maxBulkBodyBytes bounds the body of ImportPools and BulkUpdateReserves
+const maxBulkBodyBytes = 32 << 20
+
+// ImportPools stores the pools in a newline-delimited JSON body, as written by
+// ExportPools. Each pool is checked as CreatePool checks it, except that addresses
+// need not be checksummed since exports keep the stored case. Lines that fail to
+// decode, validate or store are counted and reported without stopping the import.
+func (h *Handler) ImportPools(w http.ResponseWriter, r *http.Request) {
+	var pools []*types.Pool
+	var lines []int // Line number of each decoded pool
+	importErrors := []string{}
+
+	scanner := bufio.NewScanner(http.MaxBytesReader(w, r.Body, maxBulkBodyBytes))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineBytes)
+	for line := 1; scanner.Scan(); line++ {
+		data := strings.TrimSpace(scanner.Text())
+		if data == "" {
+			continue
+		}
+		var pool types.Pool
+		if err := json.Unmarshal([]byte(data), &pool); err != nil {
+			importErrors = append(importErrors, fmt.Sprintf("line %d: invalid JSON: %v", line, err))
+			continue
+		}
+		if _, err := validatePoolFields(&pool, validateEthAddress); err != nil {
+			importErrors = append(importErrors, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		pool.CreatedAt = time.Time{}
+		pools = append(pools, &pool)
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		http.Error(w, "Failed to read import body: "+err.Error(), bodyErrorStatus(err))
+		return
+	}
+
+	imported := 0
+	for i, err := range cache.BulkStorePool(r.Context(), h.cache, pools) {
+		if err != nil {
+			importErrors = append(importErrors, fmt.Sprintf("line %d: pool %s: %v", lines[i], pools[i].Address, err))
+			continue
+		}
+		imported++
+	}
+
+	log.Printf("Pool import: %d imported, %d failed", imported, len(importErrors))
+	if imported > 0 {
+		// Refresh under the router's own context: the request ends before the refresh does
+		h.router.RefreshGraphAsync()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"imported": imported,
+		"failed":   len(importErrors),
+		"errors":   importErrors,
+	})
+}
+
+// PausePool excludes a pool from routing until it is unpaused
+func (h *Handler) PausePool(w http.ResponseWriter, r *http.Request) {
+	h.setPoolPaused(w, r, true)
+}
+
+// UnpausePool returns a paused pool to routing
+func (h *Handler) UnpausePool(w http.ResponseWriter, r *http.Request) {
+	h.setPoolPaused(w, r, false)
+}
+
+// SetPoolActive switches a pool in or out of routing with {"active": bool}. An
+// inactive pool is a paused one, so this is PausePool or UnpausePool with a body.
+func (h *Handler) SetPoolActive(w http.ResponseWriter, r *http.Request) {
+	var req types.PoolActiveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Active == nil {
+		writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_MISSING_FIELD", Message: "active is required"})
+		return
+	}
+	h.setPoolPaused(w, r, !*req.Active)
+}
+
+func (h *Handler) setPoolPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	address := mux.Vars(r)["address"]
+
+	pool, err := h.cache.UpdatePool(r.Context(), address, &types.PoolUpdate{Paused: &paused})
+	if err != nil {
+		http.Error(w, "Pool not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	log.Printf("Pool %s paused=%v", pool.Address, paused)
+
+	// Refresh under the router's own context: the request ends before the refresh does
+	h.router.RefreshGraphAsync()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pool)
+}
+
+// writePoolStoreError responds 404 when err is cache.ErrPoolNotFound and 500 with
+// message for any other store failure
+func writePoolStoreError(w http.ResponseWriter, message string, err error) {
+	if errors.Is(err, cache.ErrPoolNotFound) {
+		http.Error(w, "Pool not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+}
+
+// ApplyReserveDelta adds signed deltas to a pool's reserves, for data sources that
+// report reserve changes rather than absolute values
+func (h *Handler) ApplyReserveDelta(w http.ResponseWriter, r *http.Request) {
+	address := mux.Vars(r)["address"]
+
+	var req types.ReserveDeltaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_DELTA", Message: err.Error()})
+		return
+	}
+	if req.Delta0 == nil && req.Delta1 == nil {
+		writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_MISSING_FIELD", Message: "delta0 or delta1 is required"})
+		return
+	}
+
+	if err := h.cache.ApplyReserveDelta(r.Context(), address, req.Delta0, req.Delta1); err != nil {
+		writePoolStoreError(w, "Failed to apply reserve delta", err)
+		return
+	}
+
+	pool, err := h.cache.GetPool(r.Context(), address)
+	if err != nil {
+		writePoolStoreError(w, "Failed to read pool", err)
+		return
+	}
+
+	log.Printf("Pool %s reserves adjusted by %v/%v", address, req.Delta0, req.Delta1)
+
+	// Refresh under the router's own context: the request ends before the refresh does
+	h.router.RefreshGraphAsync()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pool)
+}
+
+// BulkUpdateReserves sets the reserves and last update time of many pools from a JSON
+// array of types.ReserveUpdate, for data feeds that report absolute reserves. Updates
+// of unknown pools are counted as notFound without failing the others.
+func (h *Handler) BulkUpdateReserves(w http.ResponseWriter, r *http.Request) {
+	var updates []types.ReserveUpdate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBulkBodyBytes)).Decode(&updates); err != nil {
+		http.Error(w, "Invalid JSON format: "+err.Error(), bodyErrorStatus(err))
+		return
+	}
+	for i := range updates {
+		if updates[i].Address == "" {
+			writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_MISSING_FIELD", Message: fmt.Sprintf("update %d: address is required", i)})
+			return
+		}
+		if _, err := updates[i].PoolUpdate(); err != nil {
+			writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_RESERVE", Message: err.Error()})
+			return
+		}
+	}
+
+	notFound := 0
+	if err := h.cache.BulkUpdateReserves(r.Context(), updates); err != nil {
+		var notFoundErr *cache.PoolsNotFoundError
+		if !errors.As(err, &notFoundErr) {
+			http.Error(w, "Failed to update reserves: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		notFound = len(notFoundErr.Addresses)
+	}
+	updated := len(updates) - notFound
+
+	log.Printf("Bulk reserve update: %d updated, %d not found", updated, notFound)
+	if updated > 0 {
+		// Refresh under the router's own context: the request ends before the refresh does
+		h.router.RefreshGraphAsync()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{
+		"updated":  updated,
+		"notFound": notFound,
+	})
+}
+
+// GetNewPools lists pools created after the RFC3339 "since" timestamp, newest first
+func (h *Handler) GetNewPools(w http.ResponseWriter, r *http.Request) {
+	sinceParam := r.URL.Query().Get("since")
+	if sinceParam == "" {
+		writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_MISSING_FIELD", Message: "since is required"})
+		return
+	}
+	since, err := time.Parse(time.RFC3339, sinceParam)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_TIMESTAMP", Message: "since must be an RFC3339 timestamp"})
+		return
+	}
+
+	pools, err := h.cache.GetPoolsCreatedAfter(r.Context(), since)
+	if err != nil {
+		http.Error(w, "Failed to fetch pools: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i].CreatedAt.After(pools[j].CreatedAt)
+	})
+
+	response := map[string]interface{}{
+		"count": len(pools),
+		"pools": pools,
+		"since": since,
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
-func (h *Handler) GetQuote(w http.ResponseWriter, r *http.Request) {
-	contentType := r.Header.Get("Content-Type")
-	if contentType != "application/json" {
-		log.Printf("Invalid content type: %s", contentType)
-		http.Error(w, "Content-Type must be application/json", http.StatusBadRequest)
+// exchangeSummary is an exchange with its number of cached pools, as listed by GetExchanges
+type exchangeSummary struct {
+	Name      string `json:"name"`
+	Factory   string `json:"factory,omitempty"`
+	Version   string `json:"version,omitempty"`
+	PoolCount int    `json:"poolCount"`
+}
+
+// GetExchanges lists the configured exchanges and any others with cached pools, with
+// their pool counts. Results are sorted by name, or by pool count with ?sort=poolCount.
+func (h *Handler) GetExchanges(w http.ResponseWriter, r *http.Request) {
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy != "" && sortBy != "name" && sortBy != "poolCount" {
+		writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_SORT", Message: "sort must be name or poolCount"})
 		return
 	}
 
-	var req types.QuoteRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Failed to decode JSON: %v", err)
-		http.Error(w, "Invalid JSON format: "+err.Error(), http.StatusBadRequest)
+	pools, err := h.cache.GetAllPools(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch pools: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Quote request: %s -> %s, amount: %s", req.TokenIn, req.TokenOut, req.AmountIn.String())
+	// Configured exchanges are listed even without pools
+	summaries := make(map[string]*exchangeSummary)
+	for _, exchange := range config.Current().DEX.Exchanges {
+		summaries[strings.ToLower(exchange.Name)] = &exchangeSummary{
+			Name:    exchange.Name,
+			Factory: exchange.Factory,
+			Version: exchange.Version,
+		}
+	}
+	for _, pool := range pools {
+		key := strings.ToLower(pool.Exchange)
+		summary, ok := summaries[key]
+		if !ok {
+			summary = &exchangeSummary{Name: pool.Exchange}
+			summaries[key] = summary
+		}
+		summary.PoolCount++
+	}
 
-	if req.TokenIn == "" || req.TokenOut == "" {
-		http.Error(w, "tokenIn and tokenOut are required", http.StatusBadRequest)
-		return
+	exchanges := make([]*exchangeSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		exchanges = append(exchanges, summary)
 	}
+	sort.Slice(exchanges, func(i, j int) bool {
+		if sortBy == "poolCount" && exchanges[i].PoolCount != exchanges[j].PoolCount {
+			return exchanges[i].PoolCount > exchanges[j].PoolCount
+		}
+		return exchanges[i].Name < exchanges[j].Name
+	})
 
-	if !common.IsHexAddress(req.TokenIn) {
-		http.Error(w, "Invalid tokenIn address", http.StatusBadRequest)
-		return
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"exchanges": exchanges,
+	})
+}
+
+// GetGraphStats returns topology metrics of the routing graph
+func (h *Handler) GetGraphStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.router.GraphStats())
+}
+
+// GetGraphDOT returns the routing graph in Graphviz DOT format for debugging
+func (h *Handler) GetGraphDOT(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, h.router.ExportGraphDOT())
+}
+
+// defaultHistoryLimit is the number of quotes GetQuoteHistory returns without ?limit
+const defaultHistoryLimit = 20
+
+// GetQuoteHistory returns the most recent quotes, newest first
+func (h *Handler) GetQuoteHistory(w http.ResponseWriter, r *http.Request) {
+	limit := defaultHistoryLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_LIMIT", Message: "limit must be a positive integer"})
+			return
+		}
+		limit = parsed
 	}
 
-	if !common.IsHexAddress(req.TokenOut) {
-		http.Error(w, "Invalid tokenOut address", http.StatusBadRequest)
-		return
+	entries := []history.HistoryEntry{}
+	if h.quoteHistory != nil {
+		entries = h.quoteHistory.Recent(limit)
 	}
 
-	if req.AmountIn == nil || req.AmountIn.Cmp(big.NewInt(0)) <= 0 {
-		http.Error(w, "Invalid input amount", http.StatusBadRequest)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":   len(entries),
+		"entries": entries,
+	})
+}
+
+// GetPoolStats returns aggregate statistics about cached pools
+func (h *Handler) GetPoolStats(w http.ResponseWriter, r *http.Request) {
+	pools, err := h.cache.GetAllPools(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch pools: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if req.MaxHops == 0 {
-		req.MaxHops = 3
+	poolsByExchange := make(map[string]int)
+	for _, pool := range pools {
+		poolsByExchange[pool.Exchange]++
 	}
 
-	resp, err := h.router.GetBestQuote(r.Context(), &req)
-	if err != nil {
-		log.Printf("Quote calculation failed: %v", err)
-		http.Error(w, "Quote calculation failed: "+err.Error(), http.StatusInternalServerError)
-		return
+	clusters := graph.ClusterPools(pools)
+	largestClusterSize := 0
+	tokenCount := 0
+	for _, cluster := range clusters {
+		tokenCount += len(cluster.Tokens)
+		if len(cluster.Pools) > largestClusterSize {
+			largestClusterSize = len(cluster.Pools)
+		}
 	}
 
-	log.Printf("Quote successful: %s -> %s", req.AmountIn.String(), resp.AmountOut.String())
+	response := map[string]interface{}{
+		"poolCount":          len(pools),
+		"tokenCount":         tokenCount,
+		"poolsByExchange":    poolsByExchange,
+		"clusterCount":       len(clusters),
+		"largestClusterSize": largestClusterSize,
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(response)
 }
 
-func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"status": "healthy",
-	})
+// defaultDuplicateThresholdPercent is the spot price deviation above which GetDuplicatePools
+// reports a pair without ?thresholdPercent
+const defaultDuplicateThresholdPercent = 1.0
+
+// duplicatePool is one pool of a duplicatePoolGroup with its spot price
+type duplicatePool struct {
+	Address   string  `json:"address"`
+	Exchange  string  `json:"exchange"`
+	SpotPrice float64 `json:"spotPrice"`
 }
 
-func (h *Handler) GetPools(w http.ResponseWriter, r *http.Request) {
+// duplicatePoolGroup is a token pair served by several pools whose spot prices disagree
+type duplicatePoolGroup struct {
+	Token0           string          `json:"token0"`
+	Token1           string          `json:"token1"`
+	DeviationPercent float64         `json:"deviationPercent"`
+	Pools            []duplicatePool `json:"pools"`
+}
+
+// GetDuplicatePools reports token pairs with several pools whose spot prices deviate by
+// more than ?thresholdPercent, which usually means one of them holds stale reserves.
+// Prices are of the lower token address in the higher, so every pool of a pair compares alike.
+func (h *Handler) GetDuplicatePools(w http.ResponseWriter, r *http.Request) {
+	threshold := defaultDuplicateThresholdPercent
+	if raw := r.URL.Query().Get("thresholdPercent"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed < 0 || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+			writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_THRESHOLD", Message: "thresholdPercent must be a non-negative number"})
+			return
+		}
+		threshold = parsed
+	}
+
 	pools, err := h.cache.GetAllPools(r.Context())
 	if err != nil {
 		http.Error(w, "Failed to fetch pools: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if pools == nil {
-		pools = []*types.Pool{}
+	pairs := make(map[[2]string][]*types.Pool)
+	for _, pool := range pools {
+		token0, token1 := strings.ToLower(pool.Token0.Address), strings.ToLower(pool.Token1.Address)
+		if token0 > token1 {
+			token0, token1 = token1, token0
+		}
+		key := [2]string{token0, token1}
+		pairs[key] = append(pairs[key], pool)
 	}
 
-	response := map[string]interface{}{
-		"count": len(pools),
-		"pools": pools,
+	groups := []*duplicatePoolGroup{}
+	for key, pairPools := range pairs {
+		if len(pairPools) < 2 {
+			continue
+		}
+
+		group := &duplicatePoolGroup{Token0: key[0], Token1: key[1]}
+		minPrice, maxPrice := math.Inf(1), 0.0
+		for _, pool := range pairPools {
+			price, ok := h.pairSpotPrice(pool, key[0])
+			if !ok {
+				continue
+			}
+			group.Pools = append(group.Pools, duplicatePool{Address: pool.Address, Exchange: pool.Exchange, SpotPrice: price})
+			minPrice = math.Min(minPrice, price)
+			maxPrice = math.Max(maxPrice, price)
+		}
+		if len(group.Pools) < 2 {
+			continue
+		}
+
+		group.DeviationPercent = (maxPrice/minPrice - 1) * 100
+		if group.DeviationPercent <= threshold {
+			continue
+		}
+		sort.Slice(group.Pools, func(i, j int) bool {
+			return group.Pools[i].SpotPrice < group.Pools[j].SpotPrice
+		})
+		groups = append(groups, group)
 	}
 
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].DeviationPercent > groups[j].DeviationPercent
+	})
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"thresholdPercent": threshold,
+		"count":            len(groups),
+		"groups":           groups,
+	})
+}
+
+// defaultPriceAlertThresholdPercent is the deviation from the median price of a pair
+// above which GetPriceAlerts flags a pool without ?threshold
+const defaultPriceAlertThresholdPercent = 5.0
+
+// GetPriceAlerts reports pools whose spot price deviates from the median of their pair's
+// pools by more than ?threshold percent, for every pair or only ?pair=tokenA:tokenB
+func (h *Handler) GetPriceAlerts(w http.ResponseWriter, r *http.Request) {
+	threshold := defaultPriceAlertThresholdPercent
+	if raw := r.URL.Query().Get("threshold"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed < 0 || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+			writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_THRESHOLD", Message: "threshold must be a non-negative number"})
+			return
+		}
+		threshold = parsed
+	}
+	pair := r.URL.Query().Get("pair")
+
+	alerts, err := h.priceMonitor.Check(r.Context(), h.cache, pair, threshold)
+	if errors.Is(err, monitor.ErrInvalidPairKey) {
+		writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_PAIR", Message: err.Error()})
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to check pool prices: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Name the tokens of the alerted pairs, keyed by their lowercase addresses
+	symbols := make(map[string]string)
+	for _, alert := range alerts {
+		for _, token := range strings.Split(alert.PairKey, ":") {
+			if _, ok := symbols[token]; !ok {
+				symbols[token] = h.symbols.Resolve(r.Context(), token)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"thresholdPercent": threshold,
+		"count":            len(alerts),
+		"alerts":           alerts,
+		"symbols":          symbols,
+	})
+}
+
+// pairSpotPrice is the decimal-adjusted price of base in the pool's other token. It
+// reports false for pools with an empty reserve, which have no meaningful price.
+func (h *Handler) pairSpotPrice(pool *types.Pool, base string) (float64, bool) {
+	price, err := h.router.Calculator().NormalisedSpotPrice(pool, base)
+	if err != nil {
+		return 0, false
+	}
+	value, _ := price.Float64()
+	return value, true
 }
 
 func (h *Handler) GetPoolByAddress(w http.ResponseWriter, r *http.Request) {
@@ -131,17 +1344,190 @@ func (h *Handler) GetPoolByAddress(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(pool)
 }
 
+// GetImpermanentLoss reports the impermanent loss of a position entered at the given
+// reserves against the pool's current reserves
+func (h *Handler) GetImpermanentLoss(w http.ResponseWriter, r *http.Request) {
+	address := mux.Vars(r)["address"]
+
+	entryReserves := make([]*big.Int, 2)
+	for i, name := range []string{"entryReserve0", "entryReserve1"} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_MISSING_FIELD", Message: name + " is required"})
+			return
+		}
+		reserve, ok := new(big.Int).SetString(value, 10)
+		if !ok || reserve.Sign() <= 0 {
+			writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_RESERVES", Message: name + " must be a positive integer"})
+			return
+		}
+		entryReserves[i] = reserve
+	}
+
+	pool, err := h.cache.GetPool(r.Context(), address)
+	if err != nil {
+		http.Error(w, "Pool not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if pool.Reserve0 == nil || pool.Reserve0.Sign() <= 0 || pool.Reserve1 == nil || pool.Reserve1.Sign() <= 0 {
+		writeAPIError(w, http.StatusUnprocessableEntity, &types.APIError{Code: "ERR_INVALID_RESERVES", Message: "pool has no liquidity"})
+		return
+	}
+
+	il := h.router.Calculator().ComputeImpermanentLoss(entryReserves[0], entryReserves[1], pool.Reserve0, pool.Reserve1)
+
+	response := map[string]interface{}{
+		"pool":                   pool.Address,
+		"impermanentLossPercent": strconv.FormatFloat(il*100, 'f', 2, 64),
+		"currentReserve0":        pool.Reserve0.String(),
+		"currentReserve1":        pool.Reserve1.String(),
+		"entryReserve0":          entryReserves[0].String(),
+		"entryReserve1":          entryReserves[1].String(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetPoolVolume returns the pool's swap volume over the last 24 hours in token0 units
+func (h *Handler) GetPoolVolume(w http.ResponseWriter, r *http.Request) {
+	address := mux.Vars(r)["address"]
+
+	pool, err := h.cache.GetPool(r.Context(), address)
+	if err != nil {
+		http.Error(w, "Pool not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	volume24h := new(big.Int)
+	if h.volumes != nil {
+		volume24h = h.volumes.GetVolume24h(pool.Address)
+	}
+
+	response := map[string]interface{}{
+		"pool":      pool.Address,
+		"token":     pool.Token0.Address,
+		"volume24h": volume24h.String(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// defaultPoolHistoryLimit is the number of snapshots GetPoolHistory returns without ?limit
+const defaultPoolHistoryLimit = 50
+
+// GetPoolHistory returns the pool's reserve snapshots, newest first, optionally only
+// those taken at or after the RFC3339 "since" timestamp
+func (h *Handler) GetPoolHistory(w http.ResponseWriter, r *http.Request) {
+	address := mux.Vars(r)["address"]
+
+	limit := defaultPoolHistoryLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_LIMIT", Message: "limit must be a positive integer"})
+			return
+		}
+		limit = parsed
+	}
+
+	var since time.Time
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_TIMESTAMP", Message: "since must be an RFC3339 timestamp"})
+			return
+		}
+		since = parsed
+	}
+
+	pool, err := h.cache.GetPool(r.Context(), address)
+	if err != nil {
+		http.Error(w, "Pool not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var snapshots []history.ReserveSnapshot
+	if h.poolHistory != nil {
+		snapshots = h.poolHistory.Snapshots(pool.Address, since, limit)
+	}
+
+	entries := make([]map[string]interface{}, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		entries = append(entries, map[string]interface{}{
+			"timestamp": snapshot.Timestamp,
+			"reserve0":  snapshot.Reserve0.String(),
+			"reserve1":  snapshot.Reserve1.String(),
+			"spotPrice": spotPrice(snapshot.Reserve0, snapshot.Reserve1, pool.Token0.Decimals, pool.Token1.Decimals),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"snapshots": entries,
+	})
+}
+
+// spotPrice is the price of token0 in token1 implied by the reserves, adjusted for
+// the tokens' decimals. Empty reserves have a price of "0".
+func spotPrice(reserve0, reserve1 *big.Int, decimals0, decimals1 int) string {
+	if reserve0.Sign() == 0 {
+		return "0"
+	}
+	price := new(big.Float).Quo(new(big.Float).SetInt(reserve1), new(big.Float).SetInt(reserve0))
+	exponent := decimals0 - decimals1
+	scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(max(exponent, -exponent))), nil))
+	if exponent > 0 {
+		price.Mul(price, scale)
+	} else {
+		price.Quo(price, scale)
+	}
+	return price.Text('g', 10)
+}
+
+// poolSymbolSearcher is a store that finds pools by token symbol, such as cache.MemoryStore
+type poolSymbolSearcher interface {
+	GetPoolsBySymbol(ctx context.Context, symbolA, symbolB string) ([]*types.Pool, error)
+}
+
+// GetPoolsByTokens lists the pools between two tokens, given by address as tokenA and
+// tokenB or by symbol as symbolA and symbolB. Values without a 0x prefix are symbols
+// whichever parameter holds them; an address cannot be paired with a symbol.
 func (h *Handler) GetPoolsByTokens(w http.ResponseWriter, r *http.Request) {
-	tokenA := r.URL.Query().Get("tokenA")
-	tokenB := r.URL.Query().Get("tokenB")
+	query := r.URL.Query()
+	tokenA, tokenB := query.Get("tokenA"), query.Get("tokenB")
+	if tokenA == "" {
+		tokenA = query.Get("symbolA")
+	}
+	if tokenB == "" {
+		tokenB = query.Get("symbolB")
+	}
 
 	if tokenA == "" || tokenB == "" {
 		http.Error(w, "Both tokenA and tokenB parameters are required", http.StatusBadRequest)
 		return
 	}
 
-	normalizedTokenA := strings.ToLower(tokenA)
-	normalizedTokenB := strings.ToLower(tokenB)
+	switch isAddressA, isAddressB := hasHexPrefix(tokenA), hasHexPrefix(tokenB); {
+	case !isAddressA && !isAddressB:
+		h.getPoolsBySymbol(w, r, tokenA, tokenB)
+		return
+	case isAddressA != isAddressB:
+		writeAPIError(w, http.StatusBadRequest, &types.APIError{Code: "ERR_MIXED_TOKEN_QUERY", Message: "tokens must both be addresses or both be symbols"})
+		return
+	}
+
+	for _, addr := range []string{tokenA, tokenB} {
+		if err := validateEthAddress(addr); err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	normalizedTokenA := NormalizeAddress(tokenA)
+	normalizedTokenB := NormalizeAddress(tokenB)
 
 	log.Printf("API: Searching pools for token pair: %s / %s", tokenA, tokenB)
 
@@ -172,46 +1558,207 @@ func (h *Handler) GetPoolsByTokens(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+func hasHexPrefix(s string) bool {
+	return len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X')
+}
+
+// getPoolsBySymbol serves GetPoolsByTokens for a pair of token symbols
+func (h *Handler) getPoolsBySymbol(w http.ResponseWriter, r *http.Request, symbolA, symbolB string) {
+	searcher, ok := h.cache.(poolSymbolSearcher)
+	if !ok {
+		writeAPIError(w, http.StatusNotImplemented, &types.APIError{Code: "ERR_SYMBOL_SEARCH_UNSUPPORTED", Message: "the pool store cannot search by symbol"})
+		return
+	}
+
+	log.Printf("API: Searching pools for symbol pair: %s / %s", symbolA, symbolB)
+
+	pools, err := searcher.GetPoolsBySymbol(r.Context(), symbolA, symbolB)
+	if err != nil {
+		log.Printf("API: Error fetching pools: %v", err)
+		http.Error(w, "Failed to fetch pools: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if pools == nil {
+		pools = []*types.Pool{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"symbolA": symbolA,
+		"symbolB": symbolB,
+		"count":   len(pools),
+		"pools":   pools,
+	})
+}
+
+// Positions of a token in a pool, as reported by GetTokenPools
+const (
+	tokenPositionToken0 = "asToken0"
+	tokenPositionToken1 = "asToken1"
+)
+
+// tokenPool is a pool listed by GetTokenPools with the token's position in it
+type tokenPool struct {
+	*types.Pool
+	Position string
+}
+
+// MarshalJSON adds position to the pool's own JSON encoding
+func (tp tokenPool) MarshalJSON() ([]byte, error) {
+	return marshalPoolWithField(tp.Pool, "position", tp.Position)
+}
+
+// GetTokenPools lists the pools the token at {address} is one of the two tokens of,
+// each with its position, asToken0 or asToken1. ?exchange limits them to one exchange.
+func (h *Handler) GetTokenPools(w http.ResponseWriter, r *http.Request) {
+	address := mux.Vars(r)["address"]
+	if err := validateEthAddress(address); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	exchange := r.URL.Query().Get("exchange")
+
+	pools, err := h.cache.GetAllPools(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch pools: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	matched := []tokenPool{}
+	for _, pool := range pools {
+		if exchange != "" && !strings.EqualFold(pool.Exchange, exchange) {
+			continue
+		}
+		switch {
+		case strings.EqualFold(pool.Token0.Address, address):
+			matched = append(matched, tokenPool{Pool: pool, Position: tokenPositionToken0})
+		case strings.EqualFold(pool.Token1.Address, address):
+			matched = append(matched, tokenPool{Pool: pool, Position: tokenPositionToken1})
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Address < matched[j].Address })
+
+	filters := make(map[string]string)
+	if exchange != "" {
+		filters["exchange"] = exchange
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":   NormalizeAddress(address),
+		"symbol":  h.symbols.Resolve(r.Context(), address),
+		"count":   len(matched),
+		"filters": filters,
+		"pools":   matched,
+	})
+}
+
+// GetTokens lists all tokens found in cached pools
+func (h *Handler) GetTokens(w http.ResponseWriter, r *http.Request) {
+	pools, err := h.cache.GetAllPools(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch pools: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tokensByAddress := make(map[string]types.Token)
+	for _, pool := range pools {
+		for _, token := range []types.Token{pool.Token0, pool.Token1} {
+			token.Address = NormalizeAddress(token.Address)
+			tokensByAddress[token.Address] = token
+		}
+	}
+
+	tokens := make([]types.Token, 0, len(tokensByAddress))
+	for _, token := range tokensByAddress {
+		if h.tokenResolver != nil {
+			resolved, err := h.tokenResolver.Resolve(r.Context(), token.Address)
+			if err != nil {
+				log.Printf("API: Failed to resolve token %s: %v", token.Address, err)
+			} else {
+				token.Name = resolved.Name
+				token.LogoURI = resolved.LogoURI
+			}
+		}
+		tokens = append(tokens, token)
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].Address < tokens[j].Address
+	})
+
+	response := map[string]interface{}{
+		"count":  len(tokens),
+		"tokens": tokens,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 func (h *Handler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Current()
 	configInfo := map[string]interface{}{
 		"server": map[string]interface{}{
-			"port":          config.AppConfig.Server.Port,
-			"read_timeout":  config.AppConfig.Server.ReadTimeout,
-			"write_timeout": config.AppConfig.Server.WriteTimeout,
+			"port":          cfg.Server.Port,
+			"read_timeout":  cfg.Server.ReadTimeout,
+			"write_timeout": cfg.Server.WriteTimeout,
 		},
 		"redis": map[string]interface{}{
-			"addr": config.AppConfig.Redis.Addr,
-			"db":   config.AppConfig.Redis.DB,
+			"addr": cfg.Redis.Addr,
+			"db":   cfg.Redis.DB,
 		},
 		"ethereum": map[string]interface{}{
-			"rpc_url":  config.AppConfig.Ethereum.RPCURL,
-			"chain_id": config.AppConfig.Ethereum.ChainID,
+			"rpc_url":  cfg.Ethereum.RPCURL,
+			"chain_id": cfg.Ethereum.ChainID,
 		},
 		"dex": map[string]interface{}{
 			// Change: BaseTokens is at the top level
-			"base_tokens": config.AppConfig.BaseTokens,
-			"token_count": len(config.AppConfig.BaseTokens),
-			"exchanges":   config.AppConfig.DEX.Exchanges, // Add: Display loaded exchanges
+			"base_tokens":    cfg.BaseTokens,
+			"token_count":    len(cfg.BaseTokens),
+			"exchanges":      cfg.DEX.Exchanges, // Add: Display loaded exchanges
+			"allowed_tokens": cfg.DEX.AllowedTokens,
+			"denied_tokens":  cfg.DEX.DeniedTokens,
 		},
-		"performance": config.AppConfig.Performance, // Change: Display the entire performance config directly
+		"performance": cfg.Performance, // Change: Display the entire performance config directly
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(configInfo)
 }
 
+// cacheStatsSource is a store that reports its performance, such as cache.TwoLevelCache
+type cacheStatsSource interface {
+	GetStats() *cache.CacheStats
+}
+
 // GetCacheStats returns cache performance statistics
 func (h *Handler) GetCacheStats(w http.ResponseWriter, r *http.Request) {
-	if twoLevelCache, ok := h.cache.(*cache.TwoLevelCache); ok {
-		stats := twoLevelCache.GetStats()
+	if statsSource, ok := h.cache.(cacheStatsSource); ok {
+		stats := statsSource.GetStats()
+		l1P50, l2P50 := stats.P50LatencyNs()
+		l1P95, l2P95 := stats.P95LatencyNs()
+		l1P99, l2P99 := stats.P99LatencyNs()
 
 		response := map[string]interface{}{
-			"local_hits":      stats.LocalHits,
-			"local_misses":    stats.LocalMisses,
-			"redis_hits":      stats.RedisHits,
-			"redis_misses":    stats.RedisMisses,
-			"local_hit_ratio": calculateHitRatio(stats.LocalHits, stats.LocalMisses),
-			"redis_hit_ratio": calculateHitRatio(stats.RedisHits, stats.RedisMisses),
+			"local_hits":        stats.LocalHits,
+			"local_misses":      stats.LocalMisses,
+			"redis_hits":        stats.RedisHits,
+			"redis_misses":      stats.RedisMisses,
+			"fallback_hits":     stats.FallbackHits,
+			"local_hit_ratio":   calculateHitRatio(stats.LocalHits, stats.LocalMisses),
+			"redis_hit_ratio":   calculateHitRatio(stats.RedisHits, stats.RedisMisses),
+			"l1_p50_latency_us": nanosToMicros(l1P50),
+			"l1_p95_latency_us": nanosToMicros(l1P95),
+			"l1_p99_latency_us": nanosToMicros(l1P99),
+			"l2_p50_latency_us": nanosToMicros(l2P50),
+			"l2_p95_latency_us": nanosToMicros(l2P95),
+			"l2_p99_latency_us": nanosToMicros(l2P99),
+			"redis_conn_pool": map[string]int64{
+				"hits":     stats.RedisConnPoolHits,
+				"misses":   stats.RedisConnPoolMisses,
+				"timeouts": stats.RedisConnPoolTimeouts,
+			},
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -221,6 +1768,10 @@ func (h *Handler) GetCacheStats(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func nanosToMicros(ns int64) float64 {
+	return float64(ns) / 1000
+}
+
 func calculateHitRatio(hits, misses int64) float64 {
 	total := hits + misses
 	if total == 0 {
@@ -5,18 +5,37 @@ import (
 	"context"
 	"dex-aggregator/config"
 	"dex-aggregator/internal/aggregator"
+	"dex-aggregator/internal/api/middleware"
 	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/health"
+	"dex-aggregator/internal/history"
+	"dex-aggregator/internal/monitor"
+	"dex-aggregator/internal/resolver"
+	"dex-aggregator/internal/testutil"
 	"dex-aggregator/internal/types"
+	"dex-aggregator/internal/validation"
+	"dex-aggregator/internal/volume"
+	"encoding/csv"
 	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
 	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 // MockStore simulates storage interface
@@ -53,6 +72,40 @@ func (m *MockStore) GetAllPools(ctx context.Context) ([]*types.Pool, error) {
 	return args.Get(0).([]*types.Pool), args.Error(1)
 }
 
+func (m *MockStore) GetPoolsByExchange(ctx context.Context, exchange string) ([]*types.Pool, error) {
+	args := m.Called(ctx, exchange)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.Pool), args.Error(1)
+}
+
+func (m *MockStore) GetPoolsCreatedAfter(ctx context.Context, since time.Time) ([]*types.Pool, error) {
+	args := m.Called(ctx, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.Pool), args.Error(1)
+}
+
+func (m *MockStore) UpdatePool(ctx context.Context, address string, update *types.PoolUpdate) (*types.Pool, error) {
+	args := m.Called(ctx, address, update)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Pool), args.Error(1)
+}
+
+func (m *MockStore) ApplyReserveDelta(ctx context.Context, address string, delta0, delta1 *big.Int) error {
+	args := m.Called(ctx, address, delta0, delta1)
+	return args.Error(0)
+}
+
+func (m *MockStore) BulkUpdateReserves(ctx context.Context, updates []types.ReserveUpdate) error {
+	args := m.Called(ctx, updates)
+	return args.Error(0)
+}
+
 func (m *MockStore) StoreToken(ctx context.Context, token *types.Token) error {
 	args := m.Called(ctx, token)
 	return args.Error(0)
@@ -100,6 +153,40 @@ func (m *MockTwoLevelCache) GetAllPools(ctx context.Context) ([]*types.Pool, err
 	return args.Get(0).([]*types.Pool), args.Error(1)
 }
 
+func (m *MockTwoLevelCache) GetPoolsByExchange(ctx context.Context, exchange string) ([]*types.Pool, error) {
+	args := m.Called(ctx, exchange)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.Pool), args.Error(1)
+}
+
+func (m *MockTwoLevelCache) GetPoolsCreatedAfter(ctx context.Context, since time.Time) ([]*types.Pool, error) {
+	args := m.Called(ctx, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.Pool), args.Error(1)
+}
+
+func (m *MockTwoLevelCache) UpdatePool(ctx context.Context, address string, update *types.PoolUpdate) (*types.Pool, error) {
+	args := m.Called(ctx, address, update)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Pool), args.Error(1)
+}
+
+func (m *MockTwoLevelCache) ApplyReserveDelta(ctx context.Context, address string, delta0, delta1 *big.Int) error {
+	args := m.Called(ctx, address, delta0, delta1)
+	return args.Error(0)
+}
+
+func (m *MockTwoLevelCache) BulkUpdateReserves(ctx context.Context, updates []types.ReserveUpdate) error {
+	args := m.Called(ctx, updates)
+	return args.Error(0)
+}
+
 func (m *MockTwoLevelCache) StoreToken(ctx context.Context, token *types.Token) error {
 	args := m.Called(ctx, token)
 	return args.Error(0)
@@ -121,11 +208,87 @@ func (m *MockTwoLevelCache) GetStats() *cache.CacheStats {
 	return args.Get(0).(*cache.CacheStats)
 }
 
+// MockENSResolver simulates ENS name resolution
+type MockENSResolver struct {
+	mock.Mock
+}
+
+func (m *MockENSResolver) Resolve(ctx context.Context, nameOrAddress string) (string, error) {
+	args := m.Called(ctx, nameOrAddress)
+	return args.String(0), args.Error(1)
+}
+
+// newTestHandler is NewHandler with a symbol cache over store using the default TTL
+func newTestHandler(router *aggregator.Router, store cache.Store) *Handler {
+	return NewHandler(router, store, resolver.NewSymbolCache(store, 0))
+}
+
+// overrideConfig installs a copy of the active config changed by apply for the
+// rest of the test. The active config is shared with running handlers, so it
+// is replaced rather than edited in place.
+func overrideConfig(t *testing.T, apply func(cfg *config.Config)) {
+	t.Helper()
+
+	original := config.Current()
+	cfg := *original
+	apply(&cfg)
+	config.Set(&cfg)
+	t.Cleanup(func() { config.Set(original) })
+}
+
+// lockedBuffer is a bytes.Buffer that is safe to use as a log destination while
+// background goroutines, such as graph refreshes, are also logging
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *lockedBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+// singleHopAllocBudget is the most allocations a single-hop quote round-trip may make.
+// Allocations, unlike timings, don't depend on how busy the machine running the tests is.
+const singleHopAllocBudget = 1000
+
 // Initialize configuration before all tests
 func TestMain(m *testing.M) {
 	// Initialize configuration
 	config.Init()
-	m.Run()
+	flag.Parse()
+
+	code := m.Run()
+	if code == 0 && !testing.Short() {
+		code = checkQuoteBudget()
+	}
+	os.Exit(code)
+}
+
+// checkQuoteBudget runs the single-hop quote benchmark and fails if it allocates more
+// than the budget. Its median time is only reported.
+func checkQuoteBudget() int {
+	result := testing.Benchmark(BenchmarkGetQuote_SingleHop)
+	fmt.Fprintf(os.Stderr, "single-hop quote: median %v, %d allocs/op\n",
+		time.Duration(result.Extra["median-ns/op"]), result.AllocsPerOp())
+	if allocs := result.AllocsPerOp(); allocs > singleHopAllocBudget {
+		fmt.Fprintf(os.Stderr, "FAIL: single-hop quote makes %d allocs/op, over the budget of %d\n", allocs, singleHopAllocBudget)
+		return 1
+	}
+	return 0
 }
 
 func TestGetQuote_Success(t *testing.T) {
@@ -170,8 +333,8 @@ func TestGetQuote_Success(t *testing.T) {
 	mockStore.On("GetAllPools", mock.Anything).Return(mockPools, nil).Twice()
 
 	// Create real Router but use mock Store
-	router := aggregator.NewRouter(mockStore, perfConfig)
-	handler := NewHandler(router, mockStore)
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
 
 	// Create request
 	req := httptest.NewRequest("POST", "/api/v1/quote", bytes.NewReader(body))
@@ -206,6 +369,12 @@ func TestGetQuote_Success(t *testing.T) {
 	// Verify output amount is in reasonable range
 	assert.True(t, amountOut.Cmp(big.NewInt(1000)) > 0, "AmountOut should be positive: %s", amountOut.String())
 
+	// Each path reports the input routed through it
+	paths := response["paths"].([]interface{})
+	if assert.NotEmpty(t, paths) {
+		assert.Equal(t, reqBody["amountIn"], paths[0].(map[string]interface{})["amountIn"])
+	}
+
 	mockStore.AssertExpectations(t)
 }
 
@@ -249,8 +418,8 @@ func TestGetQuote_WithSlippage(t *testing.T) {
 	// Set expectation *BEFORE* NewRouter is called.
 	mockStore.On("GetAllPools", mock.Anything).Return(mockPools, nil).Twice()
 
-	router := aggregator.NewRouter(mockStore, perfConfig)
-	handler := NewHandler(router, mockStore)
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
 
 	req := httptest.NewRequest("POST", "/api/v1/quote", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
@@ -275,14 +444,267 @@ func TestGetQuote_WithSlippage(t *testing.T) {
 	mockStore.AssertExpectations(t)
 }
 
+func TestGetQuote_MaxHopsClamped(t *testing.T) {
+	overrideConfig(t, func(cfg *config.Config) { cfg.Performance.MaxHops = 3 })
+
+	reserve0, _ := new(big.Int).SetString("100000000000000000000", 10) // 100 ETH
+	reserve1 := big.NewInt(200000000000)                               // 200,000 USDT
+	mockPools := []*types.Pool{
+		{
+			Address:  "test-pool",
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", Symbol: "WETH", Decimals: 18},
+			Token1:   types.Token{Address: "0xdac17f958d2ee523a2206206994597c13d831ec7", Symbol: "USDT", Decimals: 6},
+			Reserve0: reserve0,
+			Reserve1: reserve1,
+			Fee:      300,
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		maxHops          int
+		expectedAdjusted bool
+	}{
+		{"above server limit", 50, true},
+		{"within server limit", 2, false},
+		{"below minimum", -1, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+			mockStore.On("GetAllPools", mock.Anything).Return(mockPools, nil).Twice()
+			router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+			handler := newTestHandler(router, mockStore)
+
+			body, _ := json.Marshal(map[string]interface{}{
+				"tokenIn":  "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2",
+				"tokenOut": "0xdac17f958d2ee523a2206206994597c13d831ec7",
+				"amountIn": "1000000000000000",
+				"maxHops":  tc.maxHops,
+			})
+			req := httptest.NewRequest("POST", "/api/v1/quote", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.GetQuote(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code, "unexpected status: %s", w.Body.String())
+
+			var response map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			if tc.expectedAdjusted {
+				assert.Equal(t, true, response["maxHopsAdjusted"])
+			} else {
+				assert.NotContains(t, response, "maxHopsAdjusted")
+			}
+
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetQuote_AmountLimits(t *testing.T) {
+	overrideConfig(t, func(cfg *config.Config) {
+		cfg.DEX.MaxAmountInWei = "1000000000000000000000"     // 1000 ETH
+		cfg.DEX.LargeAmountWarningWei = "1000000000000000000" // 1 ETH
+	})
+
+	var logs lockedBuffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	reserve0, _ := new(big.Int).SetString("100000000000000000000000", 10) // 100,000 ETH
+	reserve1 := big.NewInt(200000000000000)                               // 200,000,000 USDT
+	mockPools := []*types.Pool{
+		testutil.NewPool().
+			WithAddress("test-pool").
+			WithTokens(
+				types.Token{Address: "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", Symbol: "WETH", Decimals: 18},
+				types.Token{Address: "0xdac17f958d2ee523a2206206994597c13d831ec7", Symbol: "USDT", Decimals: 6},
+			).
+			WithReserves(reserve0, reserve1).
+			WithFee(300).
+			Build(),
+	}
+
+	testCases := []struct {
+		name           string
+		amountIn       string
+		expectedStatus int
+		expectedCode   string
+		expectWarning  bool
+	}{
+		{"small amount", "1000000000000000", http.StatusOK, "", false},
+		{"at warning threshold", "1000000000000000000", http.StatusOK, "", false},
+		{"above warning threshold", "2000000000000000000", http.StatusOK, "", true},
+		{"at limit", "1000000000000000000000", http.StatusOK, "", true},
+		{"above limit", "1000000000000000000001", http.StatusBadRequest, "ERR_AMOUNT_TOO_LARGE", false},
+		{"far above limit", "1000000000000000000000000000000", http.StatusBadRequest, "ERR_AMOUNT_TOO_LARGE", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logs.Reset()
+			mockStore := new(MockStore)
+			mockStore.On("GetAllPools", mock.Anything).Return(mockPools, nil)
+			perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+			router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+			handler := newTestHandler(router, mockStore)
+
+			body, _ := json.Marshal(map[string]interface{}{
+				"tokenIn":  "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2",
+				"tokenOut": "0xdac17f958d2ee523a2206206994597c13d831ec7",
+				"amountIn": tc.amountIn,
+			})
+			req := httptest.NewRequest("POST", "/api/v1/quote", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.GetQuote(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code, "unexpected status: %s", w.Body.String())
+			if tc.expectedCode != "" {
+				var apiErr types.APIError
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+				assert.Equal(t, tc.expectedCode, apiErr.Code)
+			}
+			if tc.expectWarning {
+				assert.Contains(t, logs.String(), "level=WARN msg=\"Large quote amount\"")
+			} else {
+				assert.NotContains(t, logs.String(), "Large quote amount")
+			}
+		})
+	}
+}
+
+// TestAmountLimit_OtherQuoteEndpoints checks that every endpoint that quotes a
+// client-supplied amount applies DEX.MaxAmountInWei, not just the quote endpoint
+func TestAmountLimit_OtherQuoteEndpoints(t *testing.T) {
+	overrideConfig(t, func(cfg *config.Config) { cfg.DEX.MaxAmountInWei = "1000000000000000000000" }) // 1000 ETH
+
+	const (
+		weth = "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"
+		usdt = "0xdac17f958d2ee523a2206206994597c13d831ec7"
+		dai  = "0x6b175474e89094c44da98b954eedeac495271d0f"
+	)
+	tooLarge := "1000000000000000000001"
+
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil)
+	router := aggregator.NewRouter(context.Background(), mockStore, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10})
+	handler := newTestHandler(router, mockStore)
+
+	jsonRequest := func(path string, body interface{}) *http.Request {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	testCases := []struct {
+		name    string
+		req     *http.Request
+		handler http.HandlerFunc
+	}{
+		{
+			name:    "comparison",
+			req:     httptest.NewRequest("GET", "/api/v1/compare?tokenIn="+weth+"&tokenOut="+usdt+"&amountIn="+tooLarge, nil),
+			handler: handler.GetDEXComparison,
+		},
+		{
+			name: "simulate",
+			req: jsonRequest("/api/v1/simulate", map[string]interface{}{
+				"tokenIn": weth, "tokenOut": usdt, "amounts": []string{"1000", tooLarge},
+			}),
+			handler: handler.Simulate,
+		},
+		{
+			name: "multiquote",
+			req: jsonRequest("/api/v1/multiquote", map[string]interface{}{
+				"tokenIn": weth, "amountIn": tooLarge,
+				"targets": []map[string]interface{}{{"tokenOut": usdt, "fraction": 5000}, {"tokenOut": dai, "fraction": 5000}},
+			}),
+			handler: handler.MultiQuote,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tc.handler(w, tc.req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, "unexpected status: %s", w.Body.String())
+			var apiErr types.APIError
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+			assert.Equal(t, "ERR_AMOUNT_TOO_LARGE", apiErr.Code)
+		})
+	}
+}
+
+func TestGetQuote_NoAmountLimit(t *testing.T) {
+	overrideConfig(t, func(cfg *config.Config) { cfg.DEX.MaxAmountInWei = "" })
+
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil)
+	router := aggregator.NewRouter(context.Background(), mockStore, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10})
+	handler := newTestHandler(router, mockStore)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"tokenIn":  "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2",
+		"tokenOut": "0xdac17f958d2ee523a2206206994597c13d831ec7",
+		"amountIn": "1000000000000000000000000000000",
+	})
+	req := httptest.NewRequest("POST", "/api/v1/quote", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.GetQuote(w, req)
+
+	// Without a limit the amount reaches the router, which finds no route
+	assert.NotContains(t, w.Body.String(), "ERR_AMOUNT_TOO_LARGE")
+}
+
+func TestGetQuote_InvalidMaxSlippage(t *testing.T) {
+	mockStore := new(MockStore)
+	// Add expectation for the initial load in NewRouter
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := aggregator.NewRouter(context.Background(), mockStore, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10})
+	handler := newTestHandler(router, mockStore)
+
+	for _, maxSlippage := range []float64{-1, 100.5} {
+		body, _ := json.Marshal(map[string]interface{}{
+			"tokenIn":     "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2",
+			"tokenOut":    "0xdac17f958d2ee523a2206206994597c13d831ec7",
+			"amountIn":    "1000000000000000000",
+			"maxSlippage": maxSlippage,
+		})
+		req := httptest.NewRequest("POST", "/api/v1/quote", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		handler.GetQuote(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var apiErr types.APIError
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+		assert.Equal(t, "ERR_INVALID_SLIPPAGE", apiErr.Code)
+	}
+	mockStore.AssertExpectations(t)
+}
+
 func TestGetQuote_InvalidJSON(t *testing.T) {
 	mockStore := new(MockStore)
 	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
 
 	// Add expectation for the initial load in NewRouter
 	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
-	router := aggregator.NewRouter(mockStore, perfConfig)
-	handler := NewHandler(router, mockStore)
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
 
 	// Invalid JSON
 	req := httptest.NewRequest("POST", "/api/v1/quote", bytes.NewReader([]byte("invalid json")))
@@ -299,8 +721,8 @@ func TestGetQuote_InvalidContentType(t *testing.T) {
 	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
 	// Add expectation for the initial load in NewRouter
 	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
-	router := aggregator.NewRouter(mockStore, perfConfig)
-	handler := NewHandler(router, mockStore)
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
 
 	req := httptest.NewRequest("POST", "/api/v1/quote", bytes.NewReader([]byte("{}")))
 	req.Header.Set("Content-Type", "text/plain")
@@ -316,8 +738,8 @@ func TestGetQuote_MissingParameters(t *testing.T) {
 	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
 	// Add expectation for the initial load in NewRouter
 	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
-	router := aggregator.NewRouter(mockStore, perfConfig)
-	handler := NewHandler(router, mockStore)
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
 
 	testCases := []struct {
 		name     string
@@ -355,200 +777,2057 @@ func TestGetQuote_MissingParameters(t *testing.T) {
 	}
 }
 
-func TestGetPools(t *testing.T) {
-	mockStore := new(MockStore)
-	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
-	// Add expectation for the initial load in NewRouter
-	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
-	router := aggregator.NewRouter(mockStore, perfConfig)
-	handler := NewHandler(router, mockStore)
+func TestGetQuote_ENSNames(t *testing.T) {
+	const (
+		wethAddress = "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"
+		usdtAddress = "0xdac17f958d2ee523a2206206994597c13d831ec7"
+	)
 
-	// Mock cache return
-	expectedPools := []*types.Pool{
+	reserve0, _ := new(big.Int).SetString("100000000000000000000", 10) // 100 ETH
+	mockPools := []*types.Pool{
 		{
-			Address:  "pool1",
+			Address:  "test-pool",
 			Exchange: "Uniswap V2",
-			Token0: types.Token{
-				Address:  "0xtoken0",
-				Symbol:   "TOKEN0",
-				Decimals: 18,
-			},
-			Token1: types.Token{
-				Address:  "0xtoken1",
-				Symbol:   "TOKEN1",
-				Decimals: 18,
-			},
-			Reserve0: big.NewInt(1000000),
-			Reserve1: big.NewInt(2000000),
+			Token0:   types.Token{Address: wethAddress, Symbol: "WETH", Decimals: 18},
+			Token1:   types.Token{Address: usdtAddress, Symbol: "USDT", Decimals: 6},
+			Reserve0: reserve0,
+			Reserve1: big.NewInt(200000000000), // 200,000 USDT
+			Fee:      300,
 		},
 	}
-	// This is the expectation for the handler call itself
-	mockStore.On("GetAllPools", mock.Anything).Return(expectedPools, nil).Once()
-
-	req := httptest.NewRequest("GET", "/api/v1/pools", nil)
-	w := httptest.NewRecorder()
 
-	handler.GetPools(w, req)
-
-	assert.Equal(t, http.StatusOK, w.Code)
-
-	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Equal(t, float64(1), response["count"].(float64))
+	testCases := []struct {
+		name         string
+		tokenIn      string
+		tokenOut     string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"Names resolved", "weth.eth", "usdt.eth", http.StatusOK, ""},
+		{"Address and name", wethAddress, "usdt.eth", http.StatusOK, ""},
+		{"Unknown name", "weth.eth", "missing.eth", http.StatusBadRequest, "ERR_ENS_NOT_FOUND"},
+	}
 
-	// Verify pool data is correctly returned
-	poolsData := response["pools"].([]interface{})
-	assert.Equal(t, 1, len(poolsData))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			mockStore.On("GetAllPools", mock.Anything).Return(mockPools, nil)
+			perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+			router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+			handler := newTestHandler(router, mockStore)
+
+			nameResolver := new(MockENSResolver)
+			nameResolver.On("Resolve", mock.Anything, "weth.eth").Return(wethAddress, nil)
+			nameResolver.On("Resolve", mock.Anything, "usdt.eth").Return(usdtAddress, nil)
+			nameResolver.On("Resolve", mock.Anything, wethAddress).Return(wethAddress, nil)
+			nameResolver.On("Resolve", mock.Anything, "missing.eth").Return("", fmt.Errorf("ENS name not found"))
+			handler.SetNameResolver(nameResolver)
+
+			body, _ := json.Marshal(map[string]interface{}{
+				"tokenIn":  tc.tokenIn,
+				"tokenOut": tc.tokenOut,
+				"amountIn": "1000000000000000",
+			})
+			req := httptest.NewRequest("POST", "/api/v1/quote", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
 
-	poolData := poolsData[0].(map[string]interface{})
-	assert.Equal(t, "pool1", poolData["address"])
-	assert.Equal(t, "Uniswap V2", poolData["exchange"])
+			handler.GetQuote(w, req)
 
-	mockStore.AssertExpectations(t)
+			assert.Equal(t, tc.expectedCode, w.Code, w.Body.String())
+			if tc.expectedErr != "" {
+				var apiErr types.APIError
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+				assert.Equal(t, tc.expectedErr, apiErr.Code)
+				return
+			}
+
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.NotEmpty(t, response["amountOut"])
+		})
+	}
 }
 
-func TestGetPoolsByTokens(t *testing.T) {
+func TestExplainQuote(t *testing.T) {
 	mockStore := new(MockStore)
 	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
-	// Add expectation for the initial load in NewRouter
-	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
-	router := aggregator.NewRouter(mockStore, perfConfig)
-	handler := NewHandler(router, mockStore)
 
-	expectedPools := []*types.Pool{
-		{
-			Address:  "pool1",
-			Exchange: "Uniswap V2",
-			Token0: types.Token{
-				Address:  "0x123",
-				Symbol:   "TOKENA",
-				Decimals: 18,
-			},
-			Token1: types.Token{
-				Address:  "0x456",
-				Symbol:   "TOKENB",
-				Decimals: 18,
-			},
-		},
+	weth := types.Token{Address: "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", Symbol: "WETH", Decimals: 18}
+	usdt := types.Token{Address: "0xdac17f958d2ee523a2206206994597c13d831ec7", Symbol: "USDT", Decimals: 6}
+	reserve0, _ := new(big.Int).SetString("100000000000000000000", 10) // 100 ETH
+
+	// Identical reserves, so only SushiSwap's higher gas cost separates the paths
+	mockPools := []*types.Pool{
+		{Address: "sushi-pool", Exchange: "SushiSwap", Token0: weth, Token1: usdt, Reserve0: reserve0, Reserve1: big.NewInt(200000000000), Fee: 300},
+		{Address: "uniswap-pool", Exchange: "Uniswap V2", Token0: weth, Token1: usdt, Reserve0: reserve0, Reserve1: big.NewInt(200000000000), Fee: 300},
 	}
-	mockStore.On("GetPoolsByTokens", mock.Anything, "0x123", "0x456").Return(expectedPools, nil)
+	mockStore.On("GetAllPools", mock.Anything).Return(mockPools, nil).Twice()
 
-	req := httptest.NewRequest("GET", "/api/v1/pools/search?tokenA=0x123&tokenB=0x456", nil)
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"tokenIn":      weth.Address,
+		"tokenOut":     usdt.Address,
+		"amountIn":     "1000000000000000000", // 1 ETH
+		"gasPriceGwei": 20,
+	})
+	req := httptest.NewRequest("POST", "/api/v1/quote/explain", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
-	handler.GetPoolsByTokens(w, req)
+	handler.ExplainQuote(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
 
-	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Equal(t, "0x123", response["tokenA"])
-	assert.Equal(t, "0x456", response["tokenB"])
-	assert.Equal(t, float64(1), response["count"].(float64))
+	var explanation types.QuoteExplanation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &explanation))
 
-	pools := response["pools"].([]interface{})
-	assert.Equal(t, 1, len(pools))
+	assert.Equal(t, "Best path: WETH → USDT via Uniswap V2 (1 hop). 1 alternative path evaluated.", explanation.Summary)
+	assert.Contains(t, explanation.WhyBestPath, "same output as the next-best path and uses 14% less gas")
+	assert.Contains(t, explanation.PriceImpact, "Price impact is")
+
+	if assert.Len(t, explanation.PathDetails, 1) {
+		hop := explanation.PathDetails[0]
+		assert.Equal(t, "uniswap-pool", hop.Pool)
+		assert.Equal(t, "Uniswap V2", hop.Exchange)
+		assert.Equal(t, "WETH", hop.TokenIn)
+		assert.Equal(t, "USDT", hop.TokenOut)
+		assert.Equal(t, 300, hop.Fee)
+		assert.Equal(t, "1000000000000000000", hop.AmountIn)
+		amountOut, ok := new(big.Int).SetString(hop.AmountOut, 10)
+		assert.True(t, ok)
+		assert.Positive(t, amountOut.Sign())
+	}
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestGetQuoteHistory(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	mockStore := new(MockStore)
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+
+	weth := types.Token{Address: "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", Symbol: "WETH", Decimals: 18}
+	usdt := types.Token{Address: "0xdac17f958d2ee523a2206206994597c13d831ec7", Symbol: "USDT", Decimals: 6}
+	dai := "0x6b175474e89094c44da98b954eedeac495271d0f"
+	reserve0, _ := new(big.Int).SetString("100000000000000000000", 10) // 100 ETH
+	mockPools := []*types.Pool{
+		{Address: "test-pool", Exchange: "Uniswap V2", Token0: weth, Token1: usdt, Reserve0: reserve0, Reserve1: big.NewInt(200000000000), Fee: 300},
+	}
+	mockStore.On("GetAllPools", mock.Anything).Return(mockPools, nil)
+
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
+	quoteHistory := history.NewRingBuffer(10)
+	router.SetQuoteHistory(quoteHistory)
+	handler.SetQuoteHistory(quoteHistory)
+
+	quote := func(tokenOut string) {
+		body, _ := json.Marshal(map[string]interface{}{
+			"tokenIn":  weth.Address,
+			"tokenOut": tokenOut,
+			"amountIn": "1000000000000000",
+		})
+		req := httptest.NewRequest("POST", "/api/v1/quote", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		handler.GetQuote(httptest.NewRecorder(), req)
+	}
+	quote(usdt.Address)
+	quote(dai) // No pool, so the quote fails
+	quote(usdt.Address)
+
+	getHistory := func(query string) (int, []history.HistoryEntry) {
+		w := httptest.NewRecorder()
+		handler.GetQuoteHistory(w, httptest.NewRequest("GET", "/api/v1/quotes/history"+query, nil))
+
+		var response struct {
+			Count   int                    `json:"count"`
+			Entries []history.HistoryEntry `json:"entries"`
+		}
+		if w.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, response.Count, len(response.Entries))
+		}
+		return w.Code, response.Entries
+	}
+
+	code, entries := getHistory("")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, usdt.Address, entries[0].TokenOut)
+		assert.Equal(t, []string{"Uniswap V2"}, entries[0].BestPathDexes)
+		assert.NotEmpty(t, entries[0].AmountOut)
+		assert.Empty(t, entries[0].Error)
+
+		assert.Equal(t, dai, entries[1].TokenOut)
+		assert.Empty(t, entries[1].AmountOut)
+		assert.NotEmpty(t, entries[1].Error)
+
+		assert.Equal(t, "1000000000000000", entries[2].AmountIn)
+	}
+
+	code, entries = getHistory("?limit=1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, entries, 1)
+
+	for _, query := range []string{"?limit=0", "?limit=-1", "?limit=abc"} {
+		code, _ = getHistory(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestMultiQuote(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	weth := types.Token{Address: "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", Symbol: "WETH", Decimals: 18}
+	usdt := types.Token{Address: "0xdac17f958d2ee523a2206206994597c13d831ec7", Symbol: "USDT", Decimals: 6}
+	usdc := types.Token{Address: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", Symbol: "USDC", Decimals: 6}
+	reserve0, _ := new(big.Int).SetString("100000000000000000000", 10) // 100 ETH
+	mockPools := []*types.Pool{
+		{Address: "weth-usdt", Exchange: "Uniswap V2", Token0: weth, Token1: usdt, Reserve0: reserve0, Reserve1: big.NewInt(200000000000), Fee: 300},
+		{Address: "weth-usdc", Exchange: "SushiSwap", Token0: weth, Token1: usdc, Reserve0: reserve0, Reserve1: big.NewInt(200000000000), Fee: 300},
+	}
+
+	testCases := []struct {
+		name         string
+		targets      []map[string]interface{}
+		expectedCode int
+		expectedErr  string
+	}{
+		{
+			name: "60% to USDT, 40% to USDC",
+			targets: []map[string]interface{}{
+				{"tokenOut": usdt.Address, "fraction": 6000},
+				{"tokenOut": usdc.Address, "fraction": 4000},
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "Fractions not summing to 10000",
+			targets: []map[string]interface{}{
+				{"tokenOut": usdt.Address, "fraction": 6000},
+				{"tokenOut": usdc.Address, "fraction": 3000},
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "ERR_INVALID_FRACTIONS",
+		},
+		{
+			name:         "No targets",
+			targets:      []map[string]interface{}{},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "ERR_MISSING_FIELD",
+		},
+		{
+			name: "Invalid target token",
+			targets: []map[string]interface{}{
+				{"tokenOut": "not-an-address", "fraction": 10000},
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "ERR_INVALID_ADDRESS",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+			mockStore.On("GetAllPools", mock.Anything).Return(mockPools, nil)
+			router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+			handler := newTestHandler(router, mockStore)
+
+			body, _ := json.Marshal(map[string]interface{}{
+				"tokenIn":  weth.Address,
+				"amountIn": "1000000000000000000", // 1 WETH
+				"targets":  tc.targets,
+			})
+			req := httptest.NewRequest("POST", "/api/v1/multiquote", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.MultiQuote(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code, w.Body.String())
+			if tc.expectedErr != "" {
+				var apiErr types.APIError
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+				assert.Equal(t, tc.expectedErr, apiErr.Code)
+				return
+			}
+
+			var response struct {
+				Legs             []*types.QuoteResponse `json:"legs"`
+				TotalGasEstimate string                 `json:"totalGasEstimate"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			if !assert.Len(t, response.Legs, 2) {
+				return
+			}
+
+			// Legs follow target order and split the input 60/40
+			usdtLeg, usdcLeg := response.Legs[0], response.Legs[1]
+			assert.Equal(t, "weth-usdt", usdtLeg.BestPath.Pools[0].Address)
+			assert.Equal(t, "600000000000000000", usdtLeg.BestPath.AmountIn.String())
+			assert.Equal(t, "weth-usdc", usdcLeg.BestPath.Pools[0].Address)
+			assert.Equal(t, "400000000000000000", usdcLeg.BestPath.AmountIn.String())
+			assert.Positive(t, usdtLeg.AmountOut.Sign())
+			assert.Positive(t, usdcLeg.AmountOut.Sign())
+			assert.Equal(t, 1, usdtLeg.AmountOut.Cmp(usdcLeg.AmountOut))
+
+			totalGas := new(big.Int).Add(usdtLeg.GasEstimate, usdcLeg.GasEstimate)
+			assert.Equal(t, totalGas.String(), response.TotalGasEstimate)
+		})
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	const (
+		wethAddress = "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"
+		usdtAddress = "0xdac17f958d2ee523a2206206994597c13d831ec7"
+	)
+
+	reserve0, _ := new(big.Int).SetString("100000000000000000000", 10) // 100 ETH
+	mockPools := []*types.Pool{
+		{
+			Address:  "test-pool",
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: wethAddress, Symbol: "WETH", Decimals: 18},
+			Token1:   types.Token{Address: usdtAddress, Symbol: "USDT", Decimals: 6},
+			Reserve0: reserve0,
+			Reserve1: big.NewInt(200000000000), // 200,000 USDT
+			Fee:      300,
+		},
+	}
+
+	tooMany := make([]string, types.MaxSimulateAmounts+1)
+	for i := range tooMany {
+		tooMany[i] = "1000"
+	}
+
+	testCases := []struct {
+		name         string
+		amounts      []string
+		expectedCode int
+		expectedErr  string
+		failed       []bool // whether each curve point carries an error
+	}{
+		{"Empty amounts", []string{}, http.StatusBadRequest, "ERR_MISSING_FIELD", nil},
+		{"Too many amounts", tooMany, http.StatusBadRequest, "ERR_TOO_MANY_AMOUNTS", nil},
+		{"Non-positive amount", []string{"0"}, http.StatusBadRequest, "ERR_INVALID_AMOUNT", nil},
+		{"Single amount", []string{"1000000000000000"}, http.StatusOK, "", []bool{false}},
+		// 10,000 ETH into a 100 ETH pool exceeds the 5% slippage limit
+		{"Partial failure", []string{"1000000000000000", "10000000000000000000000", "2000000000000000"}, http.StatusOK, "", []bool{false, true, false}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			mockStore.On("GetAllPools", mock.Anything).Return(mockPools, nil)
+			perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 2}
+			router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+			handler := newTestHandler(router, mockStore)
+
+			body, _ := json.Marshal(map[string]interface{}{
+				"tokenIn":  wethAddress,
+				"tokenOut": usdtAddress,
+				"amounts":  tc.amounts,
+			})
+			req := httptest.NewRequest("POST", "/api/v1/simulate", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.Simulate(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code, w.Body.String())
+			if tc.expectedCode != http.StatusOK {
+				var apiErr types.APIError
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+				assert.Equal(t, tc.expectedErr, apiErr.Code)
+				return
+			}
+
+			var response struct {
+				Curve []map[string]interface{} `json:"curve"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Len(t, response.Curve, len(tc.amounts))
+			for i, point := range response.Curve {
+				assert.Equal(t, tc.amounts[i], point["amountIn"])
+				if tc.failed[i] {
+					assert.NotEmpty(t, point["error"])
+					assert.Nil(t, point["amountOut"])
+				} else {
+					assert.NotEmpty(t, point["amountOut"])
+					assert.NotEmpty(t, point["priceImpact"])
+					assert.Nil(t, point["error"])
+				}
+			}
+		})
+	}
+}
+
+func TestGetDEXComparison(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	const (
+		wethAddress = "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"
+		usdtAddress = "0xdac17f958d2ee523a2206206994597c13d831ec7"
+	)
+
+	overrideConfig(t, func(cfg *config.Config) {
+		cfg.DEX.Exchanges = []types.Exchange{{Name: "Uniswap V2"}, {Name: "SushiSwap"}, {Name: "PancakeSwap"}}
+	})
+
+	reserve0, _ := new(big.Int).SetString("100000000000000000000", 10) // 100 ETH
+	weth := types.Token{Address: wethAddress, Symbol: "WETH", Decimals: 18}
+	usdt := types.Token{Address: usdtAddress, Symbol: "USDT", Decimals: 6}
+	mockPools := []*types.Pool{
+		{Address: "uni-pool", Exchange: "Uniswap V2", Token0: weth, Token1: usdt, Reserve0: reserve0, Reserve1: big.NewInt(200000000000), Fee: 300},
+		// SushiSwap prices ETH 1% higher
+		{Address: "sushi-pool", Exchange: "SushiSwap", Token0: weth, Token1: usdt, Reserve0: reserve0, Reserve1: big.NewInt(202000000000), Fee: 300},
+	}
+
+	testCases := []struct {
+		name         string
+		query        string
+		expectedCode int
+	}{
+		{"Missing tokenOut", "tokenIn=" + wethAddress + "&amountIn=1000", http.StatusBadRequest},
+		{"Invalid token", "tokenIn=weth&tokenOut=" + usdtAddress + "&amountIn=1000", http.StatusBadRequest},
+		{"Missing amount", "tokenIn=" + wethAddress + "&tokenOut=" + usdtAddress, http.StatusBadRequest},
+		{"Non-positive amount", "tokenIn=" + wethAddress + "&tokenOut=" + usdtAddress + "&amountIn=0", http.StatusBadRequest},
+		{"Success", "tokenIn=" + wethAddress + "&tokenOut=" + usdtAddress + "&amountIn=1000000000000000", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			mockStore.On("GetAllPools", mock.Anything).Return(mockPools, nil)
+			perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 2}
+			router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+			handler := newTestHandler(router, mockStore)
+
+			req := httptest.NewRequest("GET", "/api/v1/compare?"+tc.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.GetDEXComparison(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code, w.Body.String())
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Comparisons  []map[string]interface{} `json:"comparisons"`
+				BestExchange string                   `json:"bestExchange"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "SushiSwap", response.BestExchange)
+			if !assert.Len(t, response.Comparisons, 3) {
+				return
+			}
+
+			uniswap, sushiswap, pancakeswap := response.Comparisons[0], response.Comparisons[1], response.Comparisons[2]
+			assert.Equal(t, "Uniswap V2", uniswap["exchange"])
+			assert.Equal(t, "SushiSwap", sushiswap["exchange"])
+			for _, comparison := range []map[string]interface{}{uniswap, sushiswap} {
+				assert.NotEmpty(t, comparison["amountOut"])
+				assert.NotEmpty(t, comparison["priceImpact"])
+				assert.Nil(t, comparison["error"])
+			}
+			uniOut, _ := new(big.Int).SetString(uniswap["amountOut"].(string), 10)
+			sushiOut, _ := new(big.Int).SetString(sushiswap["amountOut"].(string), 10)
+			assert.Equal(t, 1, sushiOut.Cmp(uniOut))
+
+			// PancakeSwap has no pools, so no route
+			assert.Equal(t, "PancakeSwap", pancakeswap["exchange"])
+			assert.NotEmpty(t, pancakeswap["error"])
+			assert.Nil(t, pancakeswap["amountOut"])
+		})
+	}
+}
+
+func TestGetPools(t *testing.T) {
+	mockStore := new(MockStore)
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	// Add expectation for the initial load in NewRouter
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
+
+	// Mock cache return
+	expectedPools := []*types.Pool{
+		{
+			Address:  "pool1",
+			Exchange: "Uniswap V2",
+			Token0: types.Token{
+				Address:  "0xtoken0",
+				Symbol:   "TOKEN0",
+				Decimals: 18,
+			},
+			Token1: types.Token{
+				Address:  "0xtoken1",
+				Symbol:   "TOKEN1",
+				Decimals: 18,
+			},
+			Reserve0:    big.NewInt(1000000),
+			Reserve1:    big.NewInt(2000000),
+			LastUpdated: time.Now(),
+		},
+	}
+	// This is the expectation for the handler call itself
+	mockStore.On("GetAllPools", mock.Anything).Return(expectedPools, nil).Once()
+
+	req := httptest.NewRequest("GET", "/api/v1/pools", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetPools(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), response["count"].(float64))
+
+	// Verify pool data is correctly returned
+	poolsData := response["pools"].([]interface{})
+	assert.Equal(t, 1, len(poolsData))
+
+	poolData := poolsData[0].(map[string]interface{})
+	assert.Equal(t, "pool1", poolData["address"])
+	assert.Equal(t, "Uniswap V2", poolData["exchange"])
+	assert.Equal(t, "1000000", poolData["reserve0"])
+	// log10(sqrt(1e6 * 2e6)) for a pool updated just now
+	assert.InDelta(t, 6.1505, poolData["liquidityScore"], 0.001)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestGetPools_CacheHeaders(t *testing.T) {
+	mockStore := new(MockStore)
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
+
+	// A pool past LiquidityScoreMaxAge scores zero, so its listing does not change
+	pools := []*types.Pool{{
+		Address:     "pool1",
+		Exchange:    "Uniswap V2",
+		Reserve0:    big.NewInt(1000000),
+		Reserve1:    big.NewInt(2000000),
+		LastUpdated: time.Now().Add(-2 * types.LiquidityScoreMaxAge),
+	}}
+	mockStore.On("GetAllPools", mock.Anything).Return(pools, nil)
+
+	w := httptest.NewRecorder()
+	handler.GetPools(w, httptest.NewRequest("GET", "/api/v1/pools", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "public, max-age=10, stale-while-revalidate=30", w.Header().Get("Cache-Control"))
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	testCases := []struct {
+		name        string
+		ifNoneMatch string
+		status      int
+	}{
+		{"matching ETag", etag, http.StatusNotModified},
+		{"stale ETag", `"stale"`, http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/pools", nil)
+			req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			w := httptest.NewRecorder()
+			handler.GetPools(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, etag, w.Header().Get("ETag"))
+			if tc.status == http.StatusNotModified {
+				assert.Empty(t, w.Body.String())
+			} else {
+				assert.Contains(t, w.Body.String(), `"pool1"`)
+			}
+		})
+	}
+}
+
+func TestGetPools_Filters(t *testing.T) {
+	pools := []*types.Pool{
+		{
+			Address:  "pool1",
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xtoken0", Symbol: "TOKEN0", Decimals: 18},
+			Token1:   types.Token{Address: "0xtoken1", Symbol: "TOKEN1", Decimals: 18},
+			Reserve0: big.NewInt(5000000),
+			Reserve1: big.NewInt(2000000),
+		},
+		{
+			Address:  "pool2",
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xtoken0", Symbol: "TOKEN0", Decimals: 18},
+			Token1:   types.Token{Address: "0xtoken2", Symbol: "TOKEN2", Decimals: 18},
+			Reserve0: big.NewInt(1000),
+			Reserve1: big.NewInt(2000),
+		},
+		{
+			Address:  "pool3",
+			Exchange: "SushiSwap",
+			Token0:   types.Token{Address: "0xtoken1", Symbol: "TOKEN1", Decimals: 18},
+			Token1:   types.Token{Address: "0xtoken2", Symbol: "TOKEN2", Decimals: 18},
+			Reserve0: big.NewInt(3000000),
+			Reserve1: big.NewInt(3000000),
+		},
+	}
+
+	testCases := []struct {
+		name              string
+		query             string
+		expectedAddresses []string
+		expectedFilters   map[string]interface{}
+	}{
+		{
+			name:              "no filters",
+			query:             "",
+			expectedAddresses: []string{"pool1", "pool2", "pool3"},
+			expectedFilters:   map[string]interface{}{},
+		},
+		{
+			name:              "exchange filter is case-insensitive",
+			query:             "?exchange=uniswap+v2",
+			expectedAddresses: []string{"pool1", "pool2"},
+			expectedFilters:   map[string]interface{}{"exchange": "uniswap v2"},
+		},
+		{
+			name:              "minReserve0 filter",
+			query:             "?minReserve0=1000000",
+			expectedAddresses: []string{"pool1", "pool3"},
+			expectedFilters:   map[string]interface{}{"minReserve0": "1000000"},
+		},
+		{
+			name:              "combined filters",
+			query:             "?exchange=Uniswap+V2&minReserve0=1000000",
+			expectedAddresses: []string{"pool1"},
+			expectedFilters:   map[string]interface{}{"exchange": "Uniswap V2", "minReserve0": "1000000"},
+		},
+		{
+			name:              "no matches",
+			query:             "?exchange=Curve",
+			expectedAddresses: []string{},
+			expectedFilters:   map[string]interface{}{"exchange": "Curve"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+			// Add expectation for the initial load in NewRouter
+			mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+			router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+			handler := newTestHandler(router, mockStore)
+
+			mockStore.On("GetAllPools", mock.Anything).Return(pools, nil).Once()
+
+			req := httptest.NewRequest("GET", "/api/v1/pools"+tc.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.GetPools(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, float64(len(tc.expectedAddresses)), response["count"])
+			assert.Equal(t, tc.expectedFilters, response["filters"])
+
+			addresses := []string{}
+			for _, p := range response["pools"].([]interface{}) {
+				addresses = append(addresses, p.(map[string]interface{})["address"].(string))
+			}
+			assert.Equal(t, tc.expectedAddresses, addresses)
+
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetPools_Streaming(t *testing.T) {
+	mockStore := new(MockStore)
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	// Add expectation for the initial load in NewRouter
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
+
+	pools := make([]*types.Pool, streamPoolsThreshold+100)
+	for i := range pools {
+		pools[i] = &types.Pool{
+			Address:     fmt.Sprintf("pool%d", i),
+			Exchange:    "Uniswap V2",
+			Token0:      types.Token{Address: "0xtoken0", Symbol: "TOKEN0", Decimals: 18},
+			Token1:      types.Token{Address: "0xtoken1", Symbol: "TOKEN1", Decimals: 18},
+			Reserve0:    big.NewInt(int64(1000000 + i)),
+			Reserve1:    big.NewInt(2000000),
+			LastUpdated: time.Now(),
+		}
+	}
+	mockStore.On("GetAllPools", mock.Anything).Return(pools, nil).Once()
+
+	req := httptest.NewRequest("GET", "/api/v1/pools?exchange=Uniswap+V2", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetPools(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=10, stale-while-revalidate=30", w.Header().Get("Cache-Control"))
+	assert.Empty(t, w.Header().Get("ETag"), "streamed responses are not hashed")
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(len(pools)), response["count"])
+	assert.Equal(t, map[string]interface{}{"exchange": "Uniswap V2"}, response["filters"])
+
+	poolsData := response["pools"].([]interface{})
+	assert.Len(t, poolsData, len(pools))
+	last := poolsData[len(poolsData)-1].(map[string]interface{})
+	assert.Equal(t, pools[len(pools)-1].Address, last["address"])
+	assert.Equal(t, pools[len(pools)-1].Reserve0.String(), last["reserve0"])
+	assert.InDelta(t, pools[len(pools)-1].LiquidityScore(), last["liquidityScore"], 0.001)
+
+	mockStore.AssertExpectations(t)
+}
+
+// newPoolListHandler returns GetPools behind content negotiation, serving n pools from a MockStore
+func newPoolListHandler(tb testing.TB, n int) http.Handler {
+	tb.Helper()
+
+	pools := make([]*types.Pool, n)
+	for i := range pools {
+		pools[i] = testutil.NewPool().
+			WithAddress(fmt.Sprintf("0x%040x", i)).
+			WithTokens(
+				testutil.NewToken("0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2").WithSymbol("WETH").Build(),
+				testutil.NewToken("0xdac17f958d2ee523a2206206994597c13d831ec7").WithSymbol("USDT").WithDecimals(6).Build(),
+			).
+			WithReserves(new(big.Int).Mul(big.NewInt(int64(1000+i)), big.NewInt(1e18)), big.NewInt(int64(2000000+i)*1e6)).
+			WithFee(300).
+			WithLastUpdated(time.Now()).
+			Build()
+	}
+
+	mockStore := new(MockStore)
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	// The first call is the initial load in NewRouter
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	mockStore.On("GetAllPools", mock.Anything).Return(pools, nil)
+
+	handler := newTestHandler(router, mockStore)
+	return middleware.ContentNegotiation(http.HandlerFunc(handler.GetPools))
+}
+
+func TestGetPools_MsgPack(t *testing.T) {
+	handler := newPoolListHandler(t, 3)
+
+	req := httptest.NewRequest("GET", "/api/v1/pools", nil)
+	req.Header.Set("Accept", middleware.MsgPackContentType)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, middleware.MsgPackContentType, w.Header().Get("Content-Type"))
+
+	var response struct {
+		Count int `msgpack:"count"`
+		Pools []struct {
+			Address  string `msgpack:"address"`
+			Reserve0 string `msgpack:"reserve0"`
+			Token1   struct {
+				Symbol   string `msgpack:"symbol"`
+				Decimals int    `msgpack:"decimals"`
+			} `msgpack:"token1"`
+			LiquidityScore float64 `msgpack:"liquidityScore"`
+		} `msgpack:"pools"`
+	}
+	assert.NoError(t, msgpack.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 3, response.Count)
+	if assert.Len(t, response.Pools, 3) {
+		assert.Equal(t, fmt.Sprintf("0x%040x", 0), response.Pools[0].Address)
+		assert.Equal(t, "1000000000000000000000", response.Pools[0].Reserve0)
+		assert.Equal(t, "USDT", response.Pools[0].Token1.Symbol)
+		assert.Equal(t, 6, response.Pools[0].Token1.Decimals)
+		assert.Positive(t, response.Pools[0].LiquidityScore)
+	}
+}
+
+// BenchmarkGetPools_ResponseSize compares JSON and MessagePack responses for a 50-pool list
+func BenchmarkGetPools_ResponseSize(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	handler := newPoolListHandler(b, 50)
+
+	for _, accept := range []string{"application/json", middleware.MsgPackContentType} {
+		b.Run(accept, func(b *testing.B) {
+			b.ReportAllocs()
+			var size int
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest("GET", "/api/v1/pools", nil)
+				req.Header.Set("Accept", accept)
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				size = w.Body.Len()
+			}
+			b.ReportMetric(float64(size), "bytes/response")
+		})
+	}
+}
+
+func TestGetPools_CountOnly(t *testing.T) {
+	store := testutil.NewMemStoreWithPools(
+		testutil.NewPool().WithAddress("pool1").WithExchange("Uniswap V2").WithReserves(big.NewInt(1000), big.NewInt(1000)).Build(),
+		testutil.NewPool().WithAddress("pool2").WithExchange("Uniswap V2").WithReserves(big.NewInt(5000), big.NewInt(1000)).Build(),
+		testutil.NewPool().WithAddress("pool3").WithExchange("SushiSwap").WithReserves(big.NewInt(5000), big.NewInt(1000)).Build(),
+	)
+	router := aggregator.NewRouter(context.Background(), store, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10})
+	handler := newTestHandler(router, store)
+
+	testCases := []struct {
+		query         string
+		expectedCount float64
+	}{
+		{"?countOnly=true", 3},
+		{"?countOnly=true&exchange=uniswap%20v2", 2},
+		{"?countOnly=1&minReserve0=2000", 2},
+		{"?countOnly=true&exchange=SushiSwap&minReserve0=2000", 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetPools(w, httptest.NewRequest("GET", "/api/v1/pools"+tc.query, nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tc.expectedCount, response["count"])
+			assert.NotContains(t, response, "pools")
+			assert.Contains(t, response, "filters")
+		})
+	}
+
+	t.Run("invalid countOnly", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.GetPools(w, httptest.NewRequest("GET", "/api/v1/pools?countOnly=maybe", nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestGetPools_CountOnly_StoreWithoutCounter(t *testing.T) {
+	mockStore := new(MockStore)
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := aggregator.NewRouter(context.Background(), mockStore, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10})
+	handler := newTestHandler(router, mockStore)
+
+	// Without a counter the pools are loaded and counted
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{
+		testutil.NewPool().WithAddress("pool1").Build(),
+		testutil.NewPool().WithAddress("pool2").Build(),
+	}, nil).Once()
+
+	w := httptest.NewRecorder()
+	handler.GetPools(w, httptest.NewRequest("GET", "/api/v1/pools?countOnly=true", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"count":2,"filters":{}}`, w.Body.String())
+	mockStore.AssertExpectations(t)
+}
+
+func TestGetPools_InvalidMinReserve0(t *testing.T) {
+	mockStore := new(MockStore)
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	// Add expectation for the initial load in NewRouter
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
+
+	for _, value := range []string{"abc", "-5", "1.5"} {
+		req := httptest.NewRequest("GET", "/api/v1/pools?minReserve0="+value, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetPools(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "minReserve0=%s", value)
+	}
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestExportImportPools_RoundTrip(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	overrideConfig(t, func(cfg *config.Config) {
+		cfg.DEX.StrictExchangeValidation = true
+		cfg.DEX.Exchanges = []types.Exchange{{Name: "Uniswap V2", Version: "v2"}}
+	})
+
+	ctx := context.Background()
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	newHandler := func(store cache.Store) *Handler {
+		return newTestHandler(aggregator.NewRouter(ctx, store, perfConfig), store)
+	}
+
+	source := cache.NewMemoryStore()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, source.StorePool(ctx, &types.Pool{
+			Address:  fmt.Sprintf("0x%040d", i),
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", Symbol: "A", Decimals: 18},
+			Token1:   types.Token{Address: fmt.Sprintf("0x%040d", 10+i), Symbol: "B", Decimals: 6},
+			Reserve0: big.NewInt(int64(1000000 + i)),
+			Reserve1: big.NewInt(int64(2000000 + i)),
+			Fee:      300,
+		}))
+	}
+
+	w := httptest.NewRecorder()
+	newHandler(source).ExportPools(w, httptest.NewRequest("GET", "/api/v1/pools/export", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	exported := w.Body.String()
+	assert.Len(t, strings.Split(strings.TrimSpace(exported), "\n"), 3, "one pool per line")
+
+	// A corrupt, an incomplete and an invalid line are reported without stopping the import
+	drained := `{"address":"0x0000000000000000000000000000000000000009","exchange":"Uniswap V2",` +
+		`"token0":{"address":"0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"},"token1":{"address":"0x0000000000000000000000000000000000000019"},` +
+		`"reserve0":"0","reserve1":"1"}`
+	body := exported + "{not json}\n" + `{"exchange":"Uniswap V2"}` + "\n" + drained + "\n"
+
+	target := cache.NewMemoryStore()
+	w = httptest.NewRecorder()
+	newHandler(target).ImportPools(w, httptest.NewRequest("POST", "/api/v1/pools/import", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Imported int      `json:"imported"`
+		Failed   int      `json:"failed"`
+		Errors   []string `json:"errors"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 3, response.Imported)
+	assert.Equal(t, 3, response.Failed)
+	if assert.Len(t, response.Errors, 3) {
+		assert.Contains(t, response.Errors[0], "line 4")
+		assert.Contains(t, response.Errors[1], "line 5")
+		assert.Contains(t, response.Errors[1], "ERR_MISSING_FIELD")
+		assert.Contains(t, response.Errors[2], "line 6")
+		assert.Contains(t, response.Errors[2], "ERR_INVALID_RESERVES")
+	}
+
+	original, err := source.GetAllPools(ctx)
+	assert.NoError(t, err)
+	restored, err := target.GetAllPools(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, restored, len(original))
+
+	pool, err := target.GetPool(ctx, "0x0000000000000000000000000000000000000002")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "1000002", pool.Reserve0.String())
+		assert.Equal(t, "2000002", pool.Reserve1.String())
+		assert.Equal(t, "Uniswap V2", pool.Exchange)
+	}
+}
+
+func TestImportPools_BodyTooLarge(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	store := cache.NewMemoryStore()
+	handler := newTestHandler(aggregator.NewRouter(context.Background(), store, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}), store)
+
+	// Blank lines are skipped, so only the body limit stops this import
+	body := strings.Repeat("\n", maxBulkBodyBytes+1)
+	w := httptest.NewRecorder()
+	handler.ImportPools(w, httptest.NewRequest("POST", "/api/v1/pools/import", strings.NewReader(body)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestExportPoolsCSV(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ctx := context.Background()
+	lastUpdated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	reserve0, _ := new(big.Int).SetString("100000000000000000000000", 10)
+	store := cache.NewMemoryStore()
+	for i, exchange := range []string{"Uniswap V2", "Uniswap V2", "SushiSwap"} {
+		assert.NoError(t, store.StorePool(ctx, &types.Pool{
+			Address:     fmt.Sprintf("0xpool%d", i),
+			Exchange:    exchange,
+			Token0:      types.Token{Address: "0xtokena", Symbol: "A", Decimals: 18},
+			Token1:      types.Token{Address: fmt.Sprintf("0xtoken%d", i), Symbol: "B", Decimals: 6},
+			Reserve0:    reserve0,
+			Reserve1:    big.NewInt(int64(2000000 + i)),
+			Fee:         300,
+			LastUpdated: lastUpdated,
+		}))
+	}
+	handler := newTestHandler(aggregator.NewRouter(ctx, store, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}), store)
+
+	testCases := []struct {
+		name         string
+		query        string
+		expectedRows int
+	}{
+		{"all pools", "", 3},
+		{"exchange filter", "?exchange=Uniswap+V2", 2},
+		{"exchange filter ignores case", "?exchange=sushiswap", 1},
+		{"unknown exchange", "?exchange=Curve", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ExportPoolsCSV(w, httptest.NewRequest("GET", "/api/v1/pools/export.csv"+tc.query, nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+			assert.Equal(t, "attachment; filename=pools.csv", w.Header().Get("Content-Disposition"))
+
+			records, err := csv.NewReader(w.Body).ReadAll()
+			assert.NoError(t, err)
+			if assert.NotEmpty(t, records) {
+				assert.Equal(t, []string{
+					"address", "exchange", "token0_address", "token0_symbol", "token1_address", "token1_symbol",
+					"reserve0", "reserve1", "fee", "last_updated",
+				}, records[0])
+				assert.Len(t, records[1:], tc.expectedRows)
+			}
+			for _, record := range records[1:] {
+				assert.Equal(t, "100000000000000000000000", record[6], "reserves are written in full")
+				assert.Equal(t, "300", record[8])
+				assert.Equal(t, "2024-05-01T12:00:00Z", record[9])
+			}
+		})
+	}
+}
+
+func TestExportPoolsCSV_EscapesFormulas(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ctx := context.Background()
+	store := cache.NewMemoryStore()
+	assert.NoError(t, store.StorePool(ctx, &types.Pool{
+		Address:  "0xpool",
+		Exchange: "@SUM(A1:A9)",
+		Token0:   types.Token{Address: "0xtokena", Symbol: "=HYPERLINK(\"http://evil\")"},
+		Token1:   types.Token{Address: "0xtokenb", Symbol: "-1+2"},
+		Reserve0: big.NewInt(1000),
+		Reserve1: big.NewInt(2000),
+		Fee:      300,
+	}))
+	handler := newTestHandler(aggregator.NewRouter(ctx, store, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}), store)
+
+	w := httptest.NewRecorder()
+	handler.ExportPoolsCSV(w, httptest.NewRequest("GET", "/api/v1/pools/export.csv", nil))
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, []string{
+			"0xpool", "'@SUM(A1:A9)", "0xtokena", "'=HYPERLINK(\"http://evil\")", "0xtokenb", "'-1+2",
+			"1000", "2000", "300", "",
+		}, records[1])
+	}
+}
+
+func TestCreatePool(t *testing.T) {
+	validPool := func() map[string]interface{} {
+		return map[string]interface{}{
+			"address":  "0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc",
+			"exchange": "Private MM",
+			"version":  "v2",
+			"token0":   map[string]interface{}{"address": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", "symbol": "USDC", "decimals": 6},
+			"token1":   map[string]interface{}{"address": "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", "symbol": "WETH", "decimals": 18},
+			"reserve0": "1000000000",
+			"reserve1": "500000000000000000",
+			"fee":      300,
+		}
+	}
+
+	// Pools may only be imported for configured exchanges
+	overrideConfig(t, func(cfg *config.Config) {
+		cfg.DEX.StrictExchangeValidation = true
+		cfg.DEX.Exchanges = []types.Exchange{{Name: "Private MM", Version: "v2"}}
+	})
+
+	newHandler := func() (*Handler, *MockStore) {
+		mockStore := new(MockStore)
+		perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+		// Add expectation for the initial load in NewRouter
+		mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+		router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+		// The asynchronous graph refresh may or may not run before the test ends
+		mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Maybe()
+		return newTestHandler(router, mockStore), mockStore
+	}
+
+	post := func(handler *Handler, body map[string]interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/api/v1/pools", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.CreatePool(w, req)
+		return w
+	}
+
+	t.Run("Created", func(t *testing.T) {
+		handler, mockStore := newHandler()
+		mockStore.On("GetPool", mock.Anything, "0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc").Return(nil, assert.AnError).Once()
+		mockStore.On("StorePool", mock.Anything, mock.AnythingOfType("*types.Pool")).Return(nil).Once()
+
+		w := post(handler, validPool())
+
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var response map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc", response["address"])
+		assert.Equal(t, "1000000000", response["reserve0"])
+		assert.NotEqual(t, "0001-01-01T00:00:00Z", response["last_updated"])
+
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("Creation time is left to the store", func(t *testing.T) {
+		handler, mockStore := newHandler()
+		mockStore.On("GetPool", mock.Anything, "0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc").Return(nil, assert.AnError).Once()
+		mockStore.On("StorePool", mock.Anything, mock.MatchedBy(func(pool *types.Pool) bool {
+			return pool.CreatedAt.IsZero()
+		})).Return(nil).Once()
+
+		body := validPool()
+		body["created_at"] = "2020-01-01T00:00:00Z"
+		w := post(handler, body)
+
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("Duplicate is an idempotent update", func(t *testing.T) {
+		handler, mockStore := newHandler()
+		existing := &types.Pool{Address: "0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"}
+		mockStore.On("GetPool", mock.Anything, "0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc").Return(existing, nil).Once()
+		mockStore.On("StorePool", mock.Anything, mock.AnythingOfType("*types.Pool")).Return(nil).Once()
+
+		w := post(handler, validPool())
+
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		mockStore.AssertExpectations(t)
+	})
+
+	testCases := []struct {
+		name         string
+		mutate       func(body map[string]interface{})
+		expectedCode string
+	}{
+		{"Missing address", func(b map[string]interface{}) { delete(b, "address") }, "ERR_MISSING_FIELD"},
+		{"Missing exchange", func(b map[string]interface{}) { delete(b, "exchange") }, "ERR_MISSING_FIELD"},
+		{"Missing token0 address", func(b map[string]interface{}) { b["token0"] = map[string]interface{}{"symbol": "USDC"} }, "ERR_MISSING_FIELD"},
+		{"Missing token1 address", func(b map[string]interface{}) { b["token1"] = map[string]interface{}{"symbol": "WETH"} }, "ERR_MISSING_FIELD"},
+		{"Invalid address", func(b map[string]interface{}) { b["address"] = "0x123" }, "ERR_INVALID_ADDRESS"},
+		{"Bad checksum", func(b map[string]interface{}) { b["address"] = "0xb4e16d0168e52d35cacd2c6185b44281ec28c9dc" }, "ERR_INVALID_CHECKSUM"},
+		{"Missing reserve", func(b map[string]interface{}) { delete(b, "reserve1") }, "ERR_INVALID_RESERVES"},
+		{"Zero reserve", func(b map[string]interface{}) { b["reserve0"] = "0" }, "ERR_INVALID_RESERVES"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler, mockStore := newHandler()
+			body := validPool()
+			tc.mutate(body)
+
+			w := post(handler, body)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var apiErr types.APIError
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+			assert.Equal(t, tc.expectedCode, apiErr.Code)
+
+			mockStore.AssertNotCalled(t, "StorePool", mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("Unknown exchange", func(t *testing.T) {
+		handler, mockStore := newHandler()
+		body := validPool()
+		body["exchange"] = "Shady Swap"
+
+		w := post(handler, body)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		var apiErr types.APIError
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+		assert.Equal(t, "ERR_UNKNOWN_EXCHANGE", apiErr.Code)
+
+		mockStore.AssertNotCalled(t, "StorePool", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Exchange name is case-insensitive", func(t *testing.T) {
+		handler, mockStore := newHandler()
+		mockStore.On("GetPool", mock.Anything, "0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc").Return(nil, assert.AnError).Once()
+		mockStore.On("StorePool", mock.Anything, mock.AnythingOfType("*types.Pool")).Return(nil).Once()
+		body := validPool()
+		body["exchange"] = "private mm"
+
+		w := post(handler, body)
+
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	})
+
+	t.Run("Insufficient liquidity", func(t *testing.T) {
+		handler, mockStore := newHandler()
+		mockStore.On("GetPool", mock.Anything, "0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc").Return(nil, assert.AnError).Once()
+		mockStore.On("StorePool", mock.Anything, mock.AnythingOfType("*types.Pool")).
+			Return(fmt.Errorf("%w: reserve product too small", validation.ErrInsufficientLiquidity)).Once()
+
+		w := post(handler, validPool())
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		var apiErr types.APIError
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+		assert.Equal(t, "ERR_INSUFFICIENT_LIQUIDITY", apiErr.Code)
+	})
+
+	t.Run("Validation disabled", func(t *testing.T) {
+		overrideConfig(t, func(cfg *config.Config) { cfg.DEX.StrictExchangeValidation = false })
+
+		handler, mockStore := newHandler()
+		mockStore.On("GetPool", mock.Anything, "0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc").Return(nil, assert.AnError).Once()
+		mockStore.On("StorePool", mock.Anything, mock.AnythingOfType("*types.Pool")).Return(nil).Once()
+		body := validPool()
+		body["exchange"] = "Shady Swap"
+
+		w := post(handler, body)
+
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	})
+}
+
+func TestGetPoolsByTokens(t *testing.T) {
+	mockStore := new(MockStore)
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	// Add expectation for the initial load in NewRouter
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
+
+	expectedPools := []*types.Pool{
+		{
+			Address:  "pool1",
+			Exchange: "Uniswap V2",
+			Token0: types.Token{
+				Address:  "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2",
+				Symbol:   "TOKENA",
+				Decimals: 18,
+			},
+			Token1: types.Token{
+				Address:  "0xdac17f958d2ee523a2206206994597c13d831ec7",
+				Symbol:   "TOKENB",
+				Decimals: 18,
+			},
+		},
+	}
+	mockStore.On("GetPoolsByTokens", mock.Anything, "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", "0xdac17f958d2ee523a2206206994597c13d831ec7").Return(expectedPools, nil)
+
+	req := httptest.NewRequest("GET", "/api/v1/pools/search?tokenA=0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2&tokenB=0xdac17f958d2ee523a2206206994597c13d831ec7", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetPoolsByTokens(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", response["tokenA"])
+	assert.Equal(t, "0xdac17f958d2ee523a2206206994597c13d831ec7", response["tokenB"])
+	assert.Equal(t, float64(1), response["count"].(float64))
+
+	pools := response["pools"].([]interface{})
+	assert.Equal(t, 1, len(pools))
+}
+
+func TestGetPoolsByTokens_Symbols(t *testing.T) {
+	weth := testutil.NewToken("0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2").WithSymbol("WETH").Build()
+	usdt := testutil.NewToken("0xdac17f958d2ee523a2206206994597c13d831ec7").WithSymbol("USDT").WithDecimals(6).Build()
+	dai := testutil.NewToken("0x6b175474e89094c44da98b954eedeac495271d0f").WithSymbol("DAI").Build()
+	reserves := func(b *testutil.PoolBuilder) *testutil.PoolBuilder {
+		return b.WithReserves(big.NewInt(1000000), big.NewInt(1000000))
+	}
+	store := testutil.NewMemStoreWithPools(
+		reserves(testutil.NewPool().WithAddress("weth-usdt").WithTokens(weth, usdt)).Build(),
+		reserves(testutil.NewPool().WithAddress("usdt-weth").WithTokens(usdt, weth).WithExchange("SushiSwap")).Build(),
+		reserves(testutil.NewPool().WithAddress("weth-dai").WithTokens(weth, dai)).Build(),
+	)
+	router := aggregator.NewRouter(context.Background(), store, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10})
+	handler := newTestHandler(router, store)
+
+	testCases := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedPools  []string
+	}{
+		{"symbol parameters", "?symbolA=WETH&symbolB=USDT", http.StatusOK, []string{"usdt-weth", "weth-usdt"}},
+		{"symbols in token parameters", "?tokenA=dai&tokenB=weth", http.StatusOK, []string{"weth-dai"}},
+		{"no matching pools", "?symbolA=USDT&symbolB=DAI", http.StatusOK, []string{}},
+		{"unknown symbol", "?symbolA=WBTC&symbolB=WETH", http.StatusOK, []string{}},
+		{"address and symbol", "?tokenA=" + weth.Address + "&symbolB=USDT", http.StatusBadRequest, nil},
+		{"symbol and address", "?tokenA=WETH&tokenB=" + usdt.Address, http.StatusBadRequest, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetPoolsByTokens(w, httptest.NewRequest("GET", "/api/v1/pools/search"+tc.query, nil))
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			if tc.expectedStatus != http.StatusOK {
+				var apiErr types.APIError
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+				assert.Equal(t, "ERR_MIXED_TOKEN_QUERY", apiErr.Code)
+				return
+			}
+
+			var response struct {
+				Count int           `json:"count"`
+				Pools []*types.Pool `json:"pools"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, len(tc.expectedPools), response.Count)
+			addresses := []string{}
+			for _, pool := range response.Pools {
+				addresses = append(addresses, pool.Address)
+			}
+			sort.Strings(addresses)
+			assert.Equal(t, tc.expectedPools, addresses)
+		})
+	}
+}
+
+func TestGetPoolsByTokens_SymbolsUnsupportedStore(t *testing.T) {
+	mockStore := new(MockStore)
+	// Add expectation for the initial load in NewRouter
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := aggregator.NewRouter(context.Background(), mockStore, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10})
+	handler := newTestHandler(router, mockStore)
+
+	w := httptest.NewRecorder()
+	handler.GetPoolsByTokens(w, httptest.NewRequest("GET", "/api/v1/pools/search?symbolA=WETH&symbolB=USDT", nil))
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	mockStore.AssertExpectations(t)
+}
+
+func TestHandlers_InvalidTokenAddress(t *testing.T) {
+	mockStore := new(MockStore)
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	// Add expectation for the initial load in NewRouter
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
+
+	validAddress := "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"
+	quoteBody := func(tokenIn, tokenOut string) []byte {
+		body, _ := json.Marshal(map[string]interface{}{"tokenIn": tokenIn, "tokenOut": tokenOut, "amountIn": "1000"})
+		return body
+	}
+
+	testCases := []struct {
+		name    string
+		request func() *http.Request
+		handler http.HandlerFunc
+	}{
+		{
+			name: "GetQuote invalid tokenIn",
+			request: func() *http.Request {
+				req := httptest.NewRequest("POST", "/api/v1/quote", bytes.NewReader(quoteBody("0x123", validAddress)))
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			handler: handler.GetQuote,
+		},
+		{
+			name: "GetQuote tokenOut without 0x prefix",
+			request: func() *http.Request {
+				req := httptest.NewRequest("POST", "/api/v1/quote", bytes.NewReader(quoteBody(validAddress, "dac17f958d2ee523a2206206994597c13d831ec7")))
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			handler: handler.GetQuote,
+		},
+		{
+			name: "GetPoolsByTokens invalid tokenA",
+			request: func() *http.Request {
+				return httptest.NewRequest("GET", "/api/v1/pools/search?tokenA=0x123&tokenB="+validAddress, nil)
+			},
+			handler: handler.GetPoolsByTokens,
+		},
+		{
+			name: "GetPoolsByTokens invalid tokenB",
+			request: func() *http.Request {
+				return httptest.NewRequest("GET", "/api/v1/pools/search?tokenA="+validAddress+"&tokenB=0xzz", nil)
+			},
+			handler: handler.GetPoolsByTokens,
+		},
+		{
+			name: "GetArbitrage invalid startToken",
+			request: func() *http.Request {
+				return httptest.NewRequest("GET", "/api/v1/arbitrage?startToken=not-an-address", nil)
+			},
+			handler: handler.GetArbitrage,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			tc.handler(w, tc.request())
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var apiErr types.APIError
+			err := json.Unmarshal(w.Body.Bytes(), &apiErr)
+			assert.NoError(t, err)
+			assert.Equal(t, "ERR_INVALID_ADDRESS", apiErr.Code)
+		})
+	}
+}
+
+// slowPinger simulates a Redis PING that takes delay to answer
+type slowPinger struct {
+	delay time.Duration
+}
+
+func (p *slowPinger) Ping(ctx context.Context) error {
+	time.Sleep(p.delay)
+	return nil
+}
+
+func TestHealthCheck(t *testing.T) {
+	now := time.Now()
+	freshPool := &types.Pool{Address: "fresh", Reserve0: big.NewInt(1), Reserve1: big.NewInt(1), LastUpdated: now.Add(-15 * time.Second)}
+	stalePool := &types.Pool{Address: "stale", Reserve0: big.NewInt(1), Reserve1: big.NewInt(1), LastUpdated: now.Add(-2 * time.Hour)}
+
+	testCases := []struct {
+		name           string
+		pools          []*types.Pool
+		redisDelay     time.Duration
+		expectedCode   int
+		expectedStatus string
+		expectedChecks map[string]string
+	}{
+		{
+			name:           "Healthy",
+			pools:          []*types.Pool{freshPool},
+			expectedCode:   http.StatusOK,
+			expectedStatus: "healthy",
+			expectedChecks: map[string]string{"redis": health.StatusOK, "pools": health.StatusOK},
+		},
+		{
+			name:           "Degraded when Redis is slow",
+			pools:          []*types.Pool{freshPool},
+			redisDelay:     20 * time.Millisecond,
+			expectedCode:   http.StatusOK,
+			expectedStatus: "degraded",
+			expectedChecks: map[string]string{"redis": health.StatusDegraded, "pools": health.StatusOK},
+		},
+		{
+			name:           "Unhealthy when all pools are stale",
+			pools:          []*types.Pool{stalePool},
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedStatus: "unhealthy",
+			expectedChecks: map[string]string{"redis": health.StatusOK, "pools": health.StatusUnhealthy},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+			// Add expectation for the initial load in NewRouter
+			mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+			router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+			handler := newTestHandler(router, mockStore)
+
+			mockStore.On("GetAllPools", mock.Anything).Return(tc.pools, nil)
+			handler.AddHealthCheck("redis", health.NewRedisChecker(&slowPinger{delay: tc.redisDelay}, 10*time.Millisecond))
+			handler.AddHealthCheck("pools", health.NewPoolFreshnessChecker(mockStore, time.Hour))
+
+			req := httptest.NewRequest("GET", "/health", nil)
+			w := httptest.NewRecorder()
+
+			handler.HealthCheck(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+
+			var response struct {
+				Status            string                        `json:"status"`
+				Checks            map[string]health.CheckResult `json:"checks"`
+				InFlightQuotes    *int64                        `json:"inFlightQuotes"`
+				AvgQuoteLatencyMs *float64                      `json:"avgQuoteLatencyMs"`
+			}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, response.Status)
+			if assert.NotNil(t, response.InFlightQuotes) {
+				assert.Zero(t, *response.InFlightQuotes)
+			}
+			if assert.NotNil(t, response.AvgQuoteLatencyMs) {
+				assert.Zero(t, *response.AvgQuoteLatencyMs)
+			}
+			for name, status := range tc.expectedChecks {
+				assert.Equal(t, status, response.Checks[name].Status, name)
+			}
+			assert.Equal(t, len(tc.pools), response.Checks["pools"].Count)
+		})
+	}
+}
+
+func TestGetConfig(t *testing.T) {
+	mockStore := new(MockStore)
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	// Add expectation for the initial load in NewRouter
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
+
+	// Ensure configuration is initialized
+	if config.Current() == nil {
+		config.Init()
+	}
+
+	req := httptest.NewRequest("GET", "/config", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetConfig(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	// Verify configuration structure
+	assert.Contains(t, response, "server")
+	assert.Contains(t, response, "redis")
+	assert.Contains(t, response, "ethereum")
+	assert.Contains(t, response, "dex")
+
+	dexConfig := response["dex"].(map[string]interface{})
+	assert.Contains(t, dexConfig, "allowed_tokens")
+	assert.Contains(t, dexConfig, "denied_tokens")
+
+	serverConfig := response["server"].(map[string]interface{})
+	assert.Contains(t, serverConfig, "port")
+	assert.Contains(t, serverConfig, "read_timeout")
+	assert.Contains(t, serverConfig, "write_timeout")
+}
+
+func TestGetPoolByAddress(t *testing.T) {
+	mockStore := new(MockStore)
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	// Add expectation for the initial load in NewRouter
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
+
+	expectedPool := &types.Pool{
+		Address:  "test-pool",
+		Exchange: "Uniswap V2",
+		Token0: types.Token{
+			Address:  "0xtoken0",
+			Symbol:   "TOKEN0",
+			Decimals: 18,
+		},
+		Token1: types.Token{
+			Address:  "0xtoken1",
+			Symbol:   "TOKEN1",
+			Decimals: 18,
+		},
+		Reserve0: big.NewInt(1000000),
+		Reserve1: big.NewInt(2000000),
+	}
+	mockStore.On("GetPool", mock.Anything, "test-pool").Return(expectedPool, nil)
+
+	req := httptest.NewRequest("GET", "/api/v1/pools/test-pool", nil)
+	req = mux.SetURLVars(req, map[string]string{"address": "test-pool"})
+	w := httptest.NewRecorder()
+
+	handler.GetPoolByAddress(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStore.AssertCalled(t, "GetPool", mock.Anything, "test-pool")
+
+	var pool types.Pool
+	err := json.Unmarshal(w.Body.Bytes(), &pool)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-pool", pool.Address)
+	assert.Equal(t, "Uniswap V2", pool.Exchange)
+}
+
+func TestBulkUpdateReserves(t *testing.T) {
+	testCases := []struct {
+		name         string
+		body         string
+		expectedCode int
+		expectedErr  string
+		expected     map[string]int
+		reserve0     string // Reserve0 of pool-1 afterwards
+	}{
+		{"empty input", `[]`, http.StatusOK, "", map[string]int{"updated": 0, "notFound": 0}, "1000000"},
+		{"full success", `[{"address": "pool-1", "reserve0": "1500000", "reserve1": "900000", "lastUpdated": "2024-01-01T00:00:00Z"}, {"address": "pool-2", "reserve1": "5"}]`,
+			http.StatusOK, "", map[string]int{"updated": 2, "notFound": 0}, "1500000"},
+		{"partially missing pools", `[{"address": "pool-1", "reserve0": "1200000"}, {"address": "missing-pool", "reserve0": "1"}]`,
+			http.StatusOK, "", map[string]int{"updated": 1, "notFound": 1}, "1200000"},
+		{"invalid reserve", `[{"address": "pool-1", "reserve0": "abc"}]`, http.StatusBadRequest, "ERR_INVALID_RESERVE", nil, "1000000"},
+		{"missing address", `[{"reserve0": "1"}]`, http.StatusBadRequest, "ERR_MISSING_FIELD", nil, "1000000"},
+		{"not an array", `{"address": "pool-1"}`, http.StatusBadRequest, "", nil, "1000000"},
+		{"body too large", "[" + strings.Repeat(" ", maxBulkBodyBytes) + "]", http.StatusRequestEntityTooLarge, "", nil, "1000000"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := testutil.NewMemStoreWithPools(
+				testutil.NewPool().WithAddress("pool-1").WithTokenAddresses("0xtokena", "0xtokenb").WithReserves(big.NewInt(1000000), big.NewInt(1000000)).Build(),
+				testutil.NewPool().WithAddress("pool-2").WithTokenAddresses("0xtokenb", "0xtokenc").WithReserves(big.NewInt(1000000), big.NewInt(1000000)).Build(),
+			)
+			router := aggregator.NewRouter(context.Background(), store, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10})
+			handler := newTestHandler(router, store)
+
+			req := httptest.NewRequest("POST", "/api/v1/pools/bulk-update", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+
+			handler.BulkUpdateReserves(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code, w.Body.String())
+			if tc.expectedErr != "" {
+				var apiErr types.APIError
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+				assert.Equal(t, tc.expectedErr, apiErr.Code)
+			}
+			if tc.expected != nil {
+				var response map[string]int
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, tc.expected, response)
+			}
+
+			pool, err := store.GetPool(context.Background(), "pool-1")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.reserve0, pool.Reserve0.String())
+		})
+	}
+}
+
+func TestBulkUpdateReserves_StoreError(t *testing.T) {
+	mockStore := new(MockStore)
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil)
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
+	mockStore.On("BulkUpdateReserves", mock.Anything, mock.Anything).Return(fmt.Errorf("connection refused"))
+
+	req := httptest.NewRequest("POST", "/api/v1/pools/bulk-update", strings.NewReader(`[{"address": "pool-1", "reserve0": "1"}]`))
+	w := httptest.NewRecorder()
+
+	handler.BulkUpdateReserves(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "connection refused")
+}
+
+func TestApplyReserveDelta(t *testing.T) {
+	testCases := []struct {
+		name         string
+		address      string
+		body         string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"Signed deltas", "test-pool", `{"delta0": "-500", "delta1": "1000"}`, http.StatusOK, ""},
+		{"Invalid delta", "test-pool", `{"delta0": "1e18"}`, http.StatusBadRequest, "ERR_INVALID_DELTA"},
+		{"No deltas", "test-pool", `{}`, http.StatusBadRequest, "ERR_MISSING_FIELD"},
+		{"Unknown pool", "missing-pool", `{"delta0": "1"}`, http.StatusNotFound, ""},
+		{"Store failure", "broken-pool", `{"delta0": "1"}`, http.StatusInternalServerError, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+			// The initial load in NewRouter and the refresh after an update
+			mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil)
+			router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+			handler := newTestHandler(router, mockStore)
+
+			mockStore.On("ApplyReserveDelta", mock.Anything, "test-pool", big.NewInt(-500), big.NewInt(1000)).Return(nil)
+			mockStore.On("ApplyReserveDelta", mock.Anything, "missing-pool", mock.Anything, mock.Anything).Return(fmt.Errorf("failed to apply reserve delta in Redis: %w", cache.ErrPoolNotFound))
+			mockStore.On("ApplyReserveDelta", mock.Anything, "broken-pool", mock.Anything, mock.Anything).Return(fmt.Errorf("connection refused"))
+			mockStore.On("GetPool", mock.Anything, "test-pool").Return(&types.Pool{
+				Address:  "test-pool",
+				Reserve0: big.NewInt(500),
+				Reserve1: big.NewInt(3000),
+			}, nil)
+
+			req := httptest.NewRequest("PATCH", "/api/v1/pools/"+tc.address+"/reserves", strings.NewReader(tc.body))
+			req = mux.SetURLVars(req, map[string]string{"address": tc.address})
+			w := httptest.NewRecorder()
+
+			handler.ApplyReserveDelta(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code, w.Body.String())
+			if tc.expectedErr != "" {
+				var apiErr types.APIError
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+				assert.Equal(t, tc.expectedErr, apiErr.Code)
+				mockStore.AssertNotCalled(t, "ApplyReserveDelta", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			if tc.expectedCode == http.StatusOK {
+				var pool types.Pool
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &pool))
+				assert.Equal(t, "500", pool.Reserve0.String())
+				assert.Equal(t, "3000", pool.Reserve1.String())
+			}
+		})
+	}
+}
+
+func TestSetPoolActive(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	weth := testutil.NewToken("0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2").WithSymbol("WETH").Build()
+	usdt := testutil.NewToken("0xdac17f958d2ee523a2206206994597c13d831ec7").WithSymbol("USDT").WithDecimals(6).Build()
+	reserve0, _ := new(big.Int).SetString("1000000000000000000000", 10) // 1000 WETH
+	pool := testutil.NewPool().WithTokens(weth, usdt).WithFee(300)
+	store := testutil.NewMemStoreWithPools(
+		pool.WithAddress("deep-pool").WithReserves(reserve0, big.NewInt(2000000000000)).Build(),
+		pool.WithAddress("shallow-pool").WithReserves(reserve0, big.NewInt(1900000000000)).Build(),
+	)
+
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	router := aggregator.NewRouter(context.Background(), store, perfConfig)
+	handler := newTestHandler(router, store)
+
+	bestPool := func() string {
+		resp, err := router.GetBestQuote(context.Background(), &types.QuoteRequest{
+			TokenIn:  weth.Address,
+			TokenOut: usdt.Address,
+			AmountIn: big.NewInt(1000000000000000000),
+			MaxHops:  1,
+		})
+		if err != nil {
+			return ""
+		}
+		return resp.BestPath.Pools[0].Address
+	}
+	setActive := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/api/v1/pools/deep-pool/active", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"address": "deep-pool"})
+		w := httptest.NewRecorder()
+		handler.SetPoolActive(w, req)
+		return w
+	}
+
+	assert.Equal(t, "deep-pool", bestPool())
+
+	w := setActive(`{"active": false}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var updated types.Pool
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.False(t, updated.IsActive())
+	assert.Eventually(t, func() bool { return bestPool() == "shallow-pool" }, time.Second, 10*time.Millisecond,
+		"quotes must not route through an inactive pool")
+
+	w = setActive(`{"active": true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Eventually(t, func() bool { return bestPool() == "deep-pool" }, time.Second, 10*time.Millisecond,
+		"reactivating the pool restores routing through it")
+
+	assert.Equal(t, http.StatusBadRequest, setActive(`{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, setActive(`{"active": "no"}`).Code)
+
+	req := httptest.NewRequest("PATCH", "/api/v1/pools/missing/active", strings.NewReader(`{"active": false}`))
+	req = mux.SetURLVars(req, map[string]string{"address": "missing"})
+	w = httptest.NewRecorder()
+	handler.SetPoolActive(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetNewPools(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pools := []*types.Pool{
+		{Address: "older-pool", Reserve0: big.NewInt(1), Reserve1: big.NewInt(1), CreatedAt: since.Add(time.Hour)},
+		{Address: "newest-pool", Reserve0: big.NewInt(1), Reserve1: big.NewInt(1), CreatedAt: since.Add(3 * time.Hour)},
+		{Address: "middle-pool", Reserve0: big.NewInt(1), Reserve1: big.NewInt(1), CreatedAt: since.Add(2 * time.Hour)},
+	}
+
+	testCases := []struct {
+		name         string
+		query        string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"Valid timestamp", "?since=2024-01-01T00:00:00Z", http.StatusOK, ""},
+		{"Missing since", "", http.StatusBadRequest, "ERR_MISSING_FIELD"},
+		{"Invalid since", "?since=yesterday", http.StatusBadRequest, "ERR_INVALID_TIMESTAMP"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+			// Add expectation for the initial load in NewRouter
+			mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+			router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+			handler := newTestHandler(router, mockStore)
+
+			mockStore.On("GetPoolsCreatedAfter", mock.Anything, since).Return(append([]*types.Pool(nil), pools...), nil)
+
+			req := httptest.NewRequest("GET", "/api/v1/pools/new"+tc.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.GetNewPools(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code, w.Body.String())
+			if tc.expectedErr != "" {
+				var apiErr types.APIError
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+				assert.Equal(t, tc.expectedErr, apiErr.Code)
+				mockStore.AssertNotCalled(t, "GetPoolsCreatedAfter", mock.Anything, mock.Anything)
+				return
+			}
+
+			var response struct {
+				Count int          `json:"count"`
+				Pools []types.Pool `json:"pools"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, 3, response.Count)
+			var addresses []string
+			for _, pool := range response.Pools {
+				addresses = append(addresses, pool.Address)
+			}
+			assert.Equal(t, []string{"newest-pool", "middle-pool", "older-pool"}, addresses)
+		})
+	}
 }
 
-func TestHealthCheck(t *testing.T) {
+func TestGetImpermanentLoss(t *testing.T) {
 	mockStore := new(MockStore)
 	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
 	// Add expectation for the initial load in NewRouter
 	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
-	router := aggregator.NewRouter(mockStore, perfConfig)
-	handler := NewHandler(router, mockStore)
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
 
-	req := httptest.NewRequest("GET", "/health", nil)
-	w := httptest.NewRecorder()
+	// Token0 price has doubled since entry at 1000000/2000000
+	pool := &types.Pool{
+		Address:  "test-pool",
+		Reserve0: big.NewInt(1000000),
+		Reserve1: big.NewInt(4000000),
+	}
+	mockStore.On("GetPool", mock.Anything, "test-pool").Return(pool, nil)
+	mockStore.On("GetPool", mock.Anything, "missing-pool").Return(nil, fmt.Errorf("pool not found"))
 
-	handler.HealthCheck(w, req)
+	testCases := []struct {
+		name         string
+		address      string
+		query        string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"Success", "test-pool", "entryReserve0=1000000&entryReserve1=2000000", http.StatusOK, ""},
+		{"Missing entry reserve", "test-pool", "entryReserve0=1000000", http.StatusBadRequest, "ERR_MISSING_FIELD"},
+		{"Invalid entry reserve", "test-pool", "entryReserve0=1000000&entryReserve1=abc", http.StatusBadRequest, "ERR_INVALID_RESERVES"},
+		{"Zero entry reserve", "test-pool", "entryReserve0=0&entryReserve1=2000000", http.StatusBadRequest, "ERR_INVALID_RESERVES"},
+		{"Unknown pool", "missing-pool", "entryReserve0=1000000&entryReserve1=2000000", http.StatusNotFound, ""},
+	}
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/pools/"+tc.address+"/il?"+tc.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"address": tc.address})
+			w := httptest.NewRecorder()
 
-	var response map[string]string
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Equal(t, "healthy", response["status"])
+			handler.GetImpermanentLoss(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			if tc.expectedErr != "" {
+				var apiErr types.APIError
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+				assert.Equal(t, tc.expectedErr, apiErr.Code)
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var response map[string]string
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "-5.72", response["impermanentLossPercent"])
+			assert.Equal(t, "1000000", response["currentReserve0"])
+			assert.Equal(t, "4000000", response["currentReserve1"])
+			assert.Equal(t, "1000000", response["entryReserve0"])
+			assert.Equal(t, "2000000", response["entryReserve1"])
+		})
+	}
 }
 
-func TestGetConfig(t *testing.T) {
+func TestGetPoolVolume(t *testing.T) {
 	mockStore := new(MockStore)
 	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
 	// Add expectation for the initial load in NewRouter
 	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
-	router := aggregator.NewRouter(mockStore, perfConfig)
-	handler := NewHandler(router, mockStore)
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
 
-	// Ensure configuration is initialized
-	if config.AppConfig == nil {
-		config.Init()
-	}
+	volumes := volume.NewAccumulator()
+	volumes.RecordSwap("test-pool", big.NewInt(1500), time.Now())
+	handler.SetVolumeAccumulator(volumes)
 
-	req := httptest.NewRequest("GET", "/config", nil)
-	w := httptest.NewRecorder()
+	pool := &types.Pool{
+		Address:  "test-pool",
+		Token0:   types.Token{Address: "0xtoken0"},
+		Reserve0: big.NewInt(1000000),
+		Reserve1: big.NewInt(2000000),
+	}
+	mockStore.On("GetPool", mock.Anything, "test-pool").Return(pool, nil)
+	mockStore.On("GetPool", mock.Anything, "missing-pool").Return(nil, fmt.Errorf("pool not found"))
 
-	handler.GetConfig(w, req)
+	testCases := []struct {
+		name           string
+		address        string
+		expectedCode   int
+		expectedVolume string
+	}{
+		{"Success", "test-pool", http.StatusOK, "1500"},
+		{"Unknown pool", "missing-pool", http.StatusNotFound, ""},
+	}
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/pools/"+tc.address+"/volume", nil)
+			req = mux.SetURLVars(req, map[string]string{"address": tc.address})
+			w := httptest.NewRecorder()
 
-	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
+			handler.GetPoolVolume(w, req)
 
-	// Verify configuration structure
-	assert.Contains(t, response, "server")
-	assert.Contains(t, response, "redis")
-	assert.Contains(t, response, "ethereum")
-	assert.Contains(t, response, "dex")
+			assert.Equal(t, tc.expectedCode, w.Code)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
 
-	serverConfig := response["server"].(map[string]interface{})
-	assert.Contains(t, serverConfig, "port")
-	assert.Contains(t, serverConfig, "read_timeout")
-	assert.Contains(t, serverConfig, "write_timeout")
+			var response map[string]string
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "test-pool", response["pool"])
+			assert.Equal(t, "0xtoken0", response["token"])
+			assert.Equal(t, tc.expectedVolume, response["volume24h"])
+		})
+	}
 }
 
-func TestGetPoolByAddress(t *testing.T) {
+func TestGetPoolHistory(t *testing.T) {
 	mockStore := new(MockStore)
 	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
 	// Add expectation for the initial load in NewRouter
 	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
-	router := aggregator.NewRouter(mockStore, perfConfig)
-	handler := NewHandler(router, mockStore)
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
 
-	expectedPool := &types.Pool{
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	poolHistory := history.NewPoolHistory(10)
+	poolHistory.RecordReserves("test-pool", big.NewInt(1000000000000000000), big.NewInt(2000000000), start)
+	poolHistory.RecordReserves("test-pool", big.NewInt(1000000000000000000), big.NewInt(2500000000), start.Add(time.Hour))
+	handler.SetPoolHistory(poolHistory)
+
+	pool := &types.Pool{
 		Address:  "test-pool",
-		Exchange: "Uniswap V2",
-		Token0: types.Token{
-			Address:  "0xtoken0",
-			Symbol:   "TOKEN0",
-			Decimals: 18,
-		},
-		Token1: types.Token{
-			Address:  "0xtoken1",
-			Symbol:   "TOKEN1",
-			Decimals: 18,
-		},
-		Reserve0: big.NewInt(1000000),
-		Reserve1: big.NewInt(2000000),
+		Token0:   types.Token{Address: "0xtoken0", Decimals: 18},
+		Token1:   types.Token{Address: "0xtoken1", Decimals: 6},
+		Reserve0: big.NewInt(1000000000000000000),
+		Reserve1: big.NewInt(2500000000),
 	}
-	mockStore.On("GetPool", mock.Anything, "test-pool").Return(expectedPool, nil)
-
-	req := httptest.NewRequest("GET", "/api/v1/pools/test-pool", nil)
-	req = mux.SetURLVars(req, map[string]string{"address": "test-pool"})
-	w := httptest.NewRecorder()
+	mockStore.On("GetPool", mock.Anything, "test-pool").Return(pool, nil)
+	mockStore.On("GetPool", mock.Anything, "missing-pool").Return(nil, fmt.Errorf("pool not found"))
 
-	handler.GetPoolByAddress(w, req)
+	testCases := []struct {
+		name          string
+		address       string
+		query         string
+		expectedCode  int
+		expectedPrice []string
+	}{
+		{"All snapshots, newest first", "test-pool", "", http.StatusOK, []string{"2500", "2000"}},
+		{"Limit", "test-pool", "?limit=1", http.StatusOK, []string{"2500"}},
+		{"Since", "test-pool", "?since=2024-01-01T00:30:00Z", http.StatusOK, []string{"2500"}},
+		{"Since after every snapshot", "test-pool", "?since=2024-01-02T00:00:00Z", http.StatusOK, []string{}},
+		{"Invalid limit", "test-pool", "?limit=0", http.StatusBadRequest, nil},
+		{"Invalid since", "test-pool", "?since=yesterday", http.StatusBadRequest, nil},
+		{"Unknown pool", "missing-pool", "", http.StatusNotFound, nil},
+	}
 
-	assert.Equal(t, http.StatusOK, w.Code)
-	mockStore.AssertCalled(t, "GetPool", mock.Anything, "test-pool")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/pools/"+tc.address+"/history"+tc.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"address": tc.address})
+			w := httptest.NewRecorder()
 
-	var pool types.Pool
-	err := json.Unmarshal(w.Body.Bytes(), &pool)
-	assert.NoError(t, err)
-	assert.Equal(t, "test-pool", pool.Address)
-	assert.Equal(t, "Uniswap V2", pool.Exchange)
+			handler.GetPoolHistory(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Snapshots []struct {
+					Timestamp time.Time `json:"timestamp"`
+					Reserve0  string    `json:"reserve0"`
+					Reserve1  string    `json:"reserve1"`
+					SpotPrice string    `json:"spotPrice"`
+				} `json:"snapshots"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+			prices := make([]string, len(response.Snapshots))
+			for i, snapshot := range response.Snapshots {
+				prices[i] = snapshot.SpotPrice
+				assert.Equal(t, "1000000000000000000", snapshot.Reserve0)
+			}
+			assert.Equal(t, tc.expectedPrice, prices)
+		})
+	}
 }
 
 func TestGetCacheStats_WithTwoLevelCache(t *testing.T) {
@@ -557,20 +2836,23 @@ func TestGetCacheStats_WithTwoLevelCache(t *testing.T) {
 	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
 	// Add expectation for the initial load in NewRouter
 	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
-	router := aggregator.NewRouter(mockStore, perfConfig)
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
 
 	// Create mock TwoLevelCache
 	mockTwoLevelCache := new(MockTwoLevelCache)
 
 	// Create Handler using mock TwoLevelCache
-	handler := NewHandler(router, mockTwoLevelCache)
+	handler := newTestHandler(router, mockTwoLevelCache)
 
 	// Mock cache statistics
 	expectedStats := &cache.CacheStats{
-		LocalHits:   100,
-		LocalMisses: 20,
-		RedisHits:   50,
-		RedisMisses: 10,
+		LocalHits:             100,
+		LocalMisses:           20,
+		RedisHits:             50,
+		RedisMisses:           10,
+		RedisConnPoolHits:     400,
+		RedisConnPoolMisses:   8,
+		RedisConnPoolTimeouts: 2,
 	}
 	mockTwoLevelCache.On("GetStats").Return(expectedStats)
 
@@ -579,31 +2861,20 @@ func TestGetCacheStats_WithTwoLevelCache(t *testing.T) {
 
 	handler.GetCacheStats(w, req)
 
-	// Check status code
-	if w.Code != http.StatusOK {
-		t.Logf("Expected status 200, got %d: %s", w.Code, w.Body.String())
-	}
-
-	// If status code is 200, verify response
-	if w.Code == http.StatusOK {
-		var response map[string]interface{}
-		err := json.Unmarshal(w.Body.Bytes(), &response)
-		assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
 
-		// Safely get field values
-		if localHits, exists := response["local_hits"]; exists {
-			assert.Equal(t, float64(100), localHits.(float64))
-		}
-		if localMisses, exists := response["local_misses"]; exists {
-			assert.Equal(t, float64(20), localMisses.(float64))
-		}
-		if redisHits, exists := response["redis_hits"]; exists {
-			assert.Equal(t, float64(50), redisHits.(float64))
-		}
-		if redisMisses, exists := response["redis_misses"]; exists {
-			assert.Equal(t, float64(10), redisMisses.(float64))
-		}
-	}
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, float64(100), response["local_hits"])
+	assert.Equal(t, float64(20), response["local_misses"])
+	assert.Equal(t, float64(50), response["redis_hits"])
+	assert.Equal(t, float64(10), response["redis_misses"])
+	assert.Equal(t, map[string]interface{}{
+		"hits":     float64(400),
+		"misses":   float64(8),
+		"timeouts": float64(2),
+	}, response["redis_conn_pool"])
+	mockTwoLevelCache.AssertExpectations(t)
 }
 
 func TestGetCacheStats_WithoutTwoLevelCache(t *testing.T) {
@@ -611,10 +2882,10 @@ func TestGetCacheStats_WithoutTwoLevelCache(t *testing.T) {
 	mockStore := new(MockStore)
 	// Add expectation for the initial load in NewRouter
 	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
-	router := aggregator.NewRouter(mockStore, perfConfig)
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
 
 	// Use regular MockStore, not TwoLevelCache
-	handler := NewHandler(router, mockStore)
+	handler := newTestHandler(router, mockStore)
 
 	req := httptest.NewRequest("GET", "/cache/stats", nil)
 	w := httptest.NewRecorder()
@@ -624,3 +2895,530 @@ func TestGetCacheStats_WithoutTwoLevelCache(t *testing.T) {
 	// Should return not implemented error
 	assert.Equal(t, http.StatusNotImplemented, w.Code)
 }
+
+func TestGetTokens(t *testing.T) {
+	mockStore := new(MockStore)
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	// Add expectation for the initial load in NewRouter
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
+
+	pools := []*types.Pool{
+		{
+			Address: "pool1",
+			Token0:  types.Token{Address: "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", Symbol: "WETH", Decimals: 18},
+			Token1:  types.Token{Address: "0xdac17f958d2ee523a2206206994597c13d831ec7", Symbol: "USDT", Decimals: 6},
+		},
+		{
+			Address: "pool2",
+			Token0:  types.Token{Address: "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", Symbol: "WETH", Decimals: 18},
+			Token1:  types.Token{Address: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", Symbol: "USDC", Decimals: 6},
+		},
+	}
+	mockStore.On("GetAllPools", mock.Anything).Return(pools, nil).Once()
+
+	// Resolver backed by the mock store: WETH is already enriched
+	mockStore.On("GetToken", mock.Anything, "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2").Return(&types.Token{
+		Address: "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", Symbol: "WETH", Name: "Wrapped Ether", LogoURI: "https://example.com/weth.png",
+	}, nil)
+	mockStore.On("GetToken", mock.Anything, mock.Anything).Return(&types.Token{Symbol: "UNKNOWN"}, nil)
+	handler.SetTokenResolver(resolver.NewTokenResolver(mockStore, nil, ""))
+
+	req := httptest.NewRequest("GET", "/api/v1/tokens", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetTokens(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Count  int           `json:"count"`
+		Tokens []types.Token `json:"tokens"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, response.Count)
+
+	tokensByAddress := make(map[string]types.Token)
+	for _, token := range response.Tokens {
+		tokensByAddress[token.Address] = token
+	}
+	weth := tokensByAddress["0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"]
+	assert.Equal(t, "Wrapped Ether", weth.Name)
+	assert.Equal(t, "https://example.com/weth.png", weth.LogoURI)
+	assert.Equal(t, "USDT", tokensByAddress["0xdac17f958d2ee523a2206206994597c13d831ec7"].Symbol)
+}
+
+func TestGetExchanges(t *testing.T) {
+	overrideConfig(t, func(cfg *config.Config) {
+		cfg.DEX.Exchanges = []types.Exchange{
+			{Name: "Uniswap V2", Factory: "0x5C69bEe701ef814a2B6a3EDD4B1652CB9cc5aA6f", Version: "v2"},
+			{Name: "SushiSwap", Factory: "0xC0AEe478e3658e2610c5F7A4A2E1777cE9e4f2Ac", Version: "v2"},
+			{Name: "Curve", Factory: "0xB9fC157394Af804a3578134A6585C0dc9cc990d4", Version: "stable"},
+		}
+	})
+
+	pools := []*types.Pool{
+		{Address: "pool1", Exchange: "Uniswap V2"},
+		{Address: "pool2", Exchange: "SushiSwap"},
+		{Address: "pool3", Exchange: "uniswap v2"},
+		{Address: "pool4", Exchange: "Private MM"},
+	}
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected []string // name:poolCount in response order
+	}{
+		{"Default sorts by name", "", []string{"Curve:0", "Private MM:1", "SushiSwap:1", "Uniswap V2:2"}},
+		{"Sort by name", "?sort=name", []string{"Curve:0", "Private MM:1", "SushiSwap:1", "Uniswap V2:2"}},
+		{"Sort by pool count", "?sort=poolCount", []string{"Uniswap V2:2", "Private MM:1", "SushiSwap:1", "Curve:0"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+			// Add expectation for the initial load in NewRouter
+			mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+			router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+			handler := newTestHandler(router, mockStore)
+			mockStore.On("GetAllPools", mock.Anything).Return(pools, nil)
+
+			req := httptest.NewRequest("GET", "/api/v1/exchanges"+tc.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.GetExchanges(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			var response struct {
+				Exchanges []struct {
+					Name      string `json:"name"`
+					Factory   string `json:"factory"`
+					PoolCount int    `json:"poolCount"`
+				} `json:"exchanges"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+			var got []string
+			for _, exchange := range response.Exchanges {
+				got = append(got, fmt.Sprintf("%s:%d", exchange.Name, exchange.PoolCount))
+				if exchange.Name == "Curve" {
+					assert.Equal(t, "0xB9fC157394Af804a3578134A6585C0dc9cc990d4", exchange.Factory)
+				}
+			}
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+
+	t.Run("Invalid sort", func(t *testing.T) {
+		mockStore := new(MockStore)
+		perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+		mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+		router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+		handler := newTestHandler(router, mockStore)
+
+		w := httptest.NewRecorder()
+		handler.GetExchanges(w, httptest.NewRequest("GET", "/api/v1/exchanges?sort=volume", nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var apiErr types.APIError
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+		assert.Equal(t, "ERR_INVALID_SORT", apiErr.Code)
+	})
+}
+
+func TestGetPoolStats(t *testing.T) {
+	mockStore := new(MockStore)
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	// Add expectation for the initial load in NewRouter
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
+
+	pools := []*types.Pool{
+		testutil.NewPool().WithAddress("pool1").WithTokenAddresses("0xa", "0xb").Build(),
+		testutil.NewPool().WithAddress("pool2").WithExchange("SushiSwap").WithTokenAddresses("0xb", "0xc").Build(),
+		testutil.NewPool().WithAddress("pool3").WithTokenAddresses("0xd", "0xe").Build(),
+	}
+	mockStore.On("GetAllPools", mock.Anything).Return(pools, nil).Once()
+
+	req := httptest.NewRequest("GET", "/api/v1/pools/stats", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetPoolStats(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(3), response["poolCount"])
+	assert.Equal(t, float64(5), response["tokenCount"])
+	assert.Equal(t, float64(2), response["clusterCount"])
+	assert.Equal(t, float64(2), response["largestClusterSize"])
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestGetDuplicatePools(t *testing.T) {
+	weth := types.Token{Address: "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", Symbol: "WETH", Decimals: 18}
+	usdt := types.Token{Address: "0xdAC17F958D2ee523a2206206994597C13D831ec7", Symbol: "USDT", Decimals: 6}
+	dai := types.Token{Address: "0x6B175474E89094C44Da98b954EedeAC495271d0F", Symbol: "DAI", Decimals: 18}
+	ether := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e18)) }
+	micro := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e6)) }
+
+	pools := []*types.Pool{
+		// WETH/USDT at 2000 and, with the tokens the other way round, 2100: 5% apart
+		{Address: "0xuni", Exchange: "Uniswap V2", Token0: weth, Token1: usdt, Reserve0: ether(1000), Reserve1: micro(2000000)},
+		{Address: "0xsushi", Exchange: "SushiSwap", Token0: usdt, Token1: weth, Reserve0: micro(2100000), Reserve1: ether(1000)},
+		// WETH/DAI pools 0.5% apart
+		{Address: "0xuni-dai", Exchange: "Uniswap V2", Token0: weth, Token1: dai, Reserve0: ether(1000), Reserve1: ether(2000000)},
+		{Address: "0xsushi-dai", Exchange: "SushiSwap", Token0: weth, Token1: dai, Reserve0: ether(1000), Reserve1: ether(2010000)},
+		// A pair with a single pool is never a duplicate
+		{Address: "0xusdt-dai", Exchange: "Uniswap V2", Token0: usdt, Token1: dai, Reserve0: micro(1000), Reserve1: ether(1000)},
+	}
+
+	testCases := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedPairs  int
+	}{
+		{name: "default threshold", query: "", expectedStatus: http.StatusOK, expectedPairs: 1},
+		{name: "lower threshold", query: "?thresholdPercent=0.1", expectedStatus: http.StatusOK, expectedPairs: 2},
+		{name: "higher threshold", query: "?thresholdPercent=10", expectedStatus: http.StatusOK, expectedPairs: 0},
+		{name: "invalid threshold", query: "?thresholdPercent=abc", expectedStatus: http.StatusBadRequest},
+		{name: "negative threshold", query: "?thresholdPercent=-1", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+			// Add expectation for the initial load in NewRouter
+			mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+			router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+			handler := newTestHandler(router, mockStore)
+			if tc.expectedStatus == http.StatusOK {
+				mockStore.On("GetAllPools", mock.Anything).Return(pools, nil).Once()
+			}
+
+			req := httptest.NewRequest("GET", "/api/v1/pools/duplicates"+tc.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.GetDuplicatePools(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			mockStore.AssertExpectations(t)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Count  int                  `json:"count"`
+				Groups []duplicatePoolGroup `json:"groups"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tc.expectedPairs, response.Count)
+			if tc.expectedPairs == 0 {
+				return
+			}
+
+			// Groups are sorted by deviation, so WETH/USDT comes first
+			group := response.Groups[0]
+			assert.Equal(t, strings.ToLower(weth.Address), group.Token0)
+			assert.Equal(t, strings.ToLower(usdt.Address), group.Token1)
+			assert.InDelta(t, 5.0, group.DeviationPercent, 0.001)
+			if assert.Len(t, group.Pools, 2) {
+				assert.Equal(t, "0xuni", group.Pools[0].Address)
+				assert.InDelta(t, 2000, group.Pools[0].SpotPrice, 0.001)
+				assert.Equal(t, "0xsushi", group.Pools[1].Address)
+				assert.InDelta(t, 2100, group.Pools[1].SpotPrice, 0.001)
+			}
+		})
+	}
+}
+
+func TestGetPriceAlerts(t *testing.T) {
+	weth := types.Token{Address: "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", Symbol: "WETH", Decimals: 18}
+	usdt := types.Token{Address: "0xdAC17F958D2ee523a2206206994597C13D831ec7", Symbol: "USDT", Decimals: 6}
+	ether := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e18)) }
+	micro := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e6)) }
+
+	// WETH/USDT at 2000, 2000 and 2600: the last is 30% above the median
+	pools := []*types.Pool{
+		{Address: "0xuni", Exchange: "Uniswap V2", Token0: weth, Token1: usdt, Reserve0: ether(1000), Reserve1: micro(2000000)},
+		{Address: "0xsushi", Exchange: "SushiSwap", Token0: usdt, Token1: weth, Reserve0: micro(2000000), Reserve1: ether(1000)},
+		{Address: "0xskewed", Exchange: "Uniswap V2", Token0: weth, Token1: usdt, Reserve0: ether(1000), Reserve1: micro(2600000)},
+	}
+	pair := weth.Address + ":" + usdt.Address
+
+	testCases := []struct {
+		name           string
+		query          string
+		storeCall      string
+		expectedStatus int
+		expectedAlerts []string
+	}{
+		{name: "default threshold", query: "", storeCall: "GetAllPools", expectedStatus: http.StatusOK, expectedAlerts: []string{"0xskewed"}},
+		{name: "one pair", query: "?pair=" + pair, storeCall: "GetPoolsByTokens", expectedStatus: http.StatusOK, expectedAlerts: []string{"0xskewed"}},
+		{name: "higher threshold", query: "?threshold=50", storeCall: "GetAllPools", expectedStatus: http.StatusOK, expectedAlerts: []string{}},
+		{name: "invalid threshold", query: "?threshold=abc", expectedStatus: http.StatusBadRequest},
+		{name: "invalid pair", query: "?pair=" + weth.Address, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+			// Add expectation for the initial load in NewRouter
+			mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+			router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+			handler := newTestHandler(router, mockStore)
+			switch tc.storeCall {
+			case "GetAllPools":
+				mockStore.On("GetAllPools", mock.Anything).Return(pools, nil).Once()
+			case "GetPoolsByTokens":
+				mockStore.On("GetPoolsByTokens", mock.Anything, weth.Address, usdt.Address).Return(pools, nil).Once()
+			}
+			if len(tc.expectedAlerts) > 0 {
+				// The symbol cache loads the pools to name the alerted tokens
+				mockStore.On("GetAllPools", mock.Anything).Return(pools, nil).Once()
+			}
+
+			req := httptest.NewRequest("GET", "/api/v1/pools/price-alerts"+tc.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.GetPriceAlerts(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			mockStore.AssertExpectations(t)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Count   int                      `json:"count"`
+				Alerts  []monitor.DeviationAlert `json:"alerts"`
+				Symbols map[string]string        `json:"symbols"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, len(tc.expectedAlerts), response.Count)
+			addresses := []string{}
+			for _, alert := range response.Alerts {
+				addresses = append(addresses, alert.PoolAddress)
+			}
+			assert.Equal(t, tc.expectedAlerts, addresses)
+			if len(response.Alerts) > 0 {
+				assert.InDelta(t, 2600, response.Alerts[0].SpotPrice, 0.001)
+				assert.InDelta(t, 2000, response.Alerts[0].MedianPrice, 0.001)
+				assert.InDelta(t, 30, response.Alerts[0].DeviationPercent, 0.001)
+				assert.Equal(t, map[string]string{
+					"0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2": "WETH",
+					"0xdac17f958d2ee523a2206206994597c13d831ec7": "USDT",
+				}, response.Symbols)
+			}
+		})
+	}
+}
+
+func TestGetTokenPools(t *testing.T) {
+	weth := testutil.NewToken("0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2").WithSymbol("WETH").Build()
+	usdt := testutil.NewToken("0xdac17f958d2ee523a2206206994597c13d831ec7").WithSymbol("USDT").WithDecimals(6).Build()
+	dai := testutil.NewToken("0x6b175474e89094c44da98b954eedeac495271d0f").WithSymbol("DAI").Build()
+	pool := func(address, exchange string, token0, token1 types.Token) *types.Pool {
+		return testutil.NewPool().WithAddress(address).WithExchange(exchange).WithTokens(token0, token1).
+			WithReserves(big.NewInt(1000000), big.NewInt(1000000)).Build()
+	}
+	store := testutil.NewMemStoreWithPools(
+		pool("pool-1", "Uniswap V2", weth, usdt),
+		pool("pool-2", "SushiSwap", usdt, weth),
+		pool("pool-3", "Uniswap V2", dai, weth),
+		pool("pool-4", "Uniswap V2", dai, usdt),
+	)
+	router := aggregator.NewRouter(context.Background(), store, config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10})
+	handler := newTestHandler(router, store)
+
+	testCases := []struct {
+		name           string
+		address        string
+		query          string
+		expectedStatus int
+		expectedSymbol string
+		expected       map[string]string // pool address -> position
+	}{
+		{"WETH", "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", "", http.StatusOK, "WETH",
+			map[string]string{"pool-1": "asToken0", "pool-2": "asToken1", "pool-3": "asToken1"}},
+		{"DAI", dai.Address, "", http.StatusOK, "DAI", map[string]string{"pool-3": "asToken0", "pool-4": "asToken0"}},
+		{"exchange filter", weth.Address, "?exchange=sushiswap", http.StatusOK, "WETH", map[string]string{"pool-2": "asToken1"}},
+		{"token without pools", "0x2260fac5e5542a773aa44fbc8bfb63c3d1e7ff7a", "", http.StatusOK, "0x2260...ff7a", map[string]string{}},
+		{"invalid address", "weth", "", http.StatusBadRequest, "", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/tokens/"+tc.address+"/pools"+tc.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"address": tc.address})
+			w := httptest.NewRecorder()
+
+			handler.GetTokenPools(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Token  string                   `json:"token"`
+				Symbol string                   `json:"symbol"`
+				Count  int                      `json:"count"`
+				Pools  []map[string]interface{} `json:"pools"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, strings.ToLower(tc.address), response.Token)
+			assert.Equal(t, tc.expectedSymbol, response.Symbol)
+			assert.Equal(t, len(tc.expected), response.Count)
+
+			positions := make(map[string]string)
+			for _, pool := range response.Pools {
+				positions[pool["address"].(string)] = pool["position"].(string)
+				assert.Contains(t, pool, "reserve0", "pools keep their own fields")
+			}
+			assert.Equal(t, tc.expected, positions)
+		})
+	}
+}
+
+func TestGetArbitrage(t *testing.T) {
+	mockStore := new(MockStore)
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	// Add expectation for the initial load in NewRouter
+	mockStore.On("GetAllPools", mock.Anything).Return([]*types.Pool{}, nil).Once()
+	router := aggregator.NewRouter(context.Background(), mockStore, perfConfig)
+	handler := newTestHandler(router, mockStore)
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected int
+	}{
+		{name: "Missing startToken", query: "", expected: http.StatusBadRequest},
+		{name: "Invalid startToken", query: "?startToken=0x123", expected: http.StatusBadRequest},
+		{name: "Invalid minProfitBps", query: "?startToken=0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2&minProfitBps=abc", expected: http.StatusBadRequest},
+		{name: "Valid request", query: "?startToken=0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2&minProfitBps=10", expected: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/arbitrage"+tc.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.GetArbitrage(w, req)
+
+			assert.Equal(t, tc.expected, w.Code)
+		})
+	}
+}
+
+// Benchmark addresses for the quote round-trip benchmarks
+var benchTokens = []string{
+	"0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2",
+	"0xdac17f958d2ee523a2206206994597c13d831ec7",
+	"0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+	"0x6b175474e89094c44da98b954eedeac495271d0f",
+}
+
+// newBenchHandler builds a real Router and Handler over a MemoryStore holding a chain of
+// pools benchTokens[0] -> ... -> benchTokens[hops], so the only route needs exactly hops swaps
+func newBenchHandler(b *testing.B, hops int) *Handler {
+	b.Helper()
+
+	store := cache.NewMemoryStore()
+	reserve, _ := new(big.Int).SetString("1000000000000000000000000", 10)
+	for i := 0; i < hops; i++ {
+		pool := &types.Pool{
+			Address:  fmt.Sprintf("bench-pool-%d", i),
+			Exchange: "Uniswap V2",
+			Version:  "v2",
+			Token0:   types.Token{Address: benchTokens[i], Symbol: fmt.Sprintf("TK%d", i), Decimals: 18},
+			Token1:   types.Token{Address: benchTokens[i+1], Symbol: fmt.Sprintf("TK%d", i+1), Decimals: 18},
+			Reserve0: new(big.Int).Set(reserve),
+			Reserve1: new(big.Int).Set(reserve),
+			Fee:      300,
+		}
+		if err := store.StorePool(context.Background(), pool); err != nil {
+			b.Fatalf("failed to store pool: %v", err)
+		}
+	}
+
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	router := aggregator.NewRouter(context.Background(), store, perfConfig)
+	return newTestHandler(router, store)
+}
+
+// benchmarkGetQuote measures a full quote round-trip: request marshal, handler, response unmarshal.
+// It reports the median round-trip time as median-ns/op alongside the usual mean.
+func benchmarkGetQuote(b *testing.B, hops int) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	handler := newBenchHandler(b, hops)
+	quoteReq := &types.QuoteRequest{
+		TokenIn:  benchTokens[0],
+		TokenOut: benchTokens[hops],
+		AmountIn: big.NewInt(1000000000000000000),
+		MaxHops:  3,
+	}
+
+	durations := make([]time.Duration, 0, b.N)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+
+		body, err := json.Marshal(quoteReq)
+		if err != nil {
+			b.Fatalf("failed to marshal request: %v", err)
+		}
+
+		req := httptest.NewRequest("POST", "/api/v1/quote", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		handler.GetQuote(w, req)
+
+		if w.Code != http.StatusOK {
+			b.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var response map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			b.Fatalf("failed to unmarshal response: %v", err)
+		}
+
+		durations = append(durations, time.Since(start))
+	}
+
+	b.StopTimer()
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	b.ReportMetric(float64(durations[len(durations)/2].Nanoseconds()), "median-ns/op")
+}
+
+// Run with: go test ./internal/api -run=^$ -bench=BenchmarkGetQuote -benchmem
+func BenchmarkGetQuote_SingleHop(b *testing.B) {
+	benchmarkGetQuote(b, 1)
+}
+
+func BenchmarkGetQuote_TwoHop(b *testing.B) {
+	benchmarkGetQuote(b, 2)
+}
+
+func BenchmarkGetQuote_ThreeHop(b *testing.B) {
+	benchmarkGetQuote(b, 3)
+}
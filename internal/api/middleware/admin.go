@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminTokenHeader is the request header carrying the operator admin token
+const AdminTokenHeader = "X-Admin-Token"
+
+// AdminTokenAuth rejects requests whose X-Admin-Token header does not match token.
+// An empty token disables the protected routes entirely.
+func AdminTokenAuth(token string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				log.Printf("Admin rejected: reason=not_configured method=%s path=%s remote=%s", r.Method, r.URL.Path, r.RemoteAddr)
+				writeAPIError(w, http.StatusForbidden, "ERR_FORBIDDEN", "admin endpoints are disabled")
+				return
+			}
+
+			provided := r.Header.Get(AdminTokenHeader)
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				log.Printf("Admin rejected: reason=invalid_token method=%s path=%s remote=%s", r.Method, r.URL.Path, r.RemoteAddr)
+				writeAPIError(w, http.StatusUnauthorized, "ERR_UNAUTHORIZED", "missing or invalid admin token")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"dex-aggregator/internal/types"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAdminTestRouter(token string) *mux.Router {
+	r := mux.NewRouter()
+	r.Use(AdminTokenAuth(token))
+	r.HandleFunc("/api/v1/pools", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	return r
+}
+
+func TestAdminTokenAuth(t *testing.T) {
+	testCases := []struct {
+		name           string
+		configured     string
+		provided       string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{name: "Valid token", configured: "admin-secret", provided: "admin-secret", expectedStatus: http.StatusCreated},
+		{name: "Missing token", configured: "admin-secret", provided: "", expectedStatus: http.StatusUnauthorized, expectedCode: "ERR_UNAUTHORIZED"},
+		{name: "Invalid token", configured: "admin-secret", provided: "wrong", expectedStatus: http.StatusUnauthorized, expectedCode: "ERR_UNAUTHORIZED"},
+		{name: "Not configured", configured: "", provided: "", expectedStatus: http.StatusForbidden, expectedCode: "ERR_FORBIDDEN"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/pools", nil)
+			if tc.provided != "" {
+				req.Header.Set(AdminTokenHeader, tc.provided)
+			}
+			w := httptest.NewRecorder()
+
+			newAdminTestRouter(tc.configured).ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			if tc.expectedCode != "" {
+				var apiErr types.APIError
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+				assert.Equal(t, tc.expectedCode, apiErr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type contextKey string
+
+const ownerContextKey contextKey = "apiKeyOwner"
+
+// APIKeyHeader is the request header carrying the client API key
+const APIKeyHeader = "X-API-Key"
+
+// authExemptPaths are reachable without an API key
+var authExemptPaths = map[string]bool{
+	"/health":       true,
+	"/openapi.json": true,
+}
+
+// APIKeyAuth rejects requests without a valid X-API-Key header.
+// validKeys maps each API key to the name of its owner.
+func APIKeyAuth(validKeys map[string]string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if authExemptPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := r.Header.Get(APIKeyHeader)
+			if key == "" {
+				log.Printf("Auth rejected: reason=missing_key method=%s path=%s remote=%s", r.Method, r.URL.Path, r.RemoteAddr)
+				writeAPIError(w, http.StatusUnauthorized, "ERR_UNAUTHORIZED", "missing API key")
+				return
+			}
+
+			owner, ok := validKeys[key]
+			if !ok {
+				log.Printf("Auth rejected: reason=invalid_key method=%s path=%s remote=%s", r.Method, r.URL.Path, r.RemoteAddr)
+				writeAPIError(w, http.StatusUnauthorized, "ERR_UNAUTHORIZED", "invalid API key")
+				return
+			}
+
+			log.Printf("Auth accepted: owner=%s method=%s path=%s", owner, r.Method, r.URL.Path)
+
+			ctx := context.WithValue(r.Context(), ownerContextKey, owner)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// OwnerFromContext returns the API key owner stored by APIKeyAuth
+func OwnerFromContext(ctx context.Context) (string, bool) {
+	owner, ok := ctx.Value(ownerContextKey).(string)
+	return owner, ok
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"dex-aggregator/internal/types"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAuthTestRouter() *mux.Router {
+	r := mux.NewRouter()
+	r.Use(APIKeyAuth(map[string]string{"secret-key": "alice"}))
+
+	r.HandleFunc("/api/v1/pools", func(w http.ResponseWriter, r *http.Request) {
+		owner, _ := OwnerFromContext(r.Context())
+		w.Write([]byte(owner))
+	})
+	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return r
+}
+
+func TestAPIKeyAuth_ValidKey(t *testing.T) {
+	r := newAuthTestRouter()
+
+	req := httptest.NewRequest("GET", "/api/v1/pools", nil)
+	req.Header.Set(APIKeyHeader, "secret-key")
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "alice", w.Body.String())
+}
+
+func TestAPIKeyAuth_Unauthenticated(t *testing.T) {
+	r := newAuthTestRouter()
+
+	testCases := []struct {
+		name string
+		key  string
+	}{
+		{name: "Missing key", key: ""},
+		{name: "Invalid key", key: "wrong-key"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/pools", nil)
+			if tc.key != "" {
+				req.Header.Set(APIKeyHeader, tc.key)
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+			var apiErr types.APIError
+			err := json.Unmarshal(w.Body.Bytes(), &apiErr)
+			assert.NoError(t, err)
+			assert.Equal(t, "ERR_UNAUTHORIZED", apiErr.Code)
+		})
+	}
+}
+
+func TestAPIKeyAuth_ExemptPath(t *testing.T) {
+	r := newAuthTestRouter()
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
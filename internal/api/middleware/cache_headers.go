@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// CacheHeaders lets clients cache successful GET responses: it sets Cache-Control to
+// public with the given max-age and stale-while-revalidate seconds, and an ETag that
+// is the SHA-256 of the response body. Requests whose If-None-Match lists that ETag
+// get 304 Not Modified without a body. The response is buffered to hash it, so apply
+// it only to routes that do not stream, and never to quote endpoints.
+func CacheHeaders(maxAge int, staleWhileRevalidate int) mux.MiddlewareFunc {
+	cacheControl := CacheControl(maxAge, staleWhileRevalidate)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			recorder := &bufferedResponse{header: w.Header(), status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			// Errors are not cached
+			if recorder.status != http.StatusOK {
+				w.WriteHeader(recorder.status)
+				w.Write(recorder.body.Bytes())
+				return
+			}
+
+			w.Header().Set("Cache-Control", cacheControl)
+			WriteWithETag(w, r, recorder.body.Bytes())
+		})
+	}
+}
+
+// CacheControl returns the Cache-Control value CacheHeaders sets, for handlers that set
+// it themselves because they stream some of their responses
+func CacheControl(maxAge int, staleWhileRevalidate int) string {
+	return fmt.Sprintf("public, max-age=%d, stale-while-revalidate=%d", maxAge, staleWhileRevalidate)
+}
+
+// WriteWithETag writes body as a 200 response with the SHA-256 of body as its ETag, or
+// 304 Not Modified without a body if r's If-None-Match lists that ETag
+func WriteWithETag(w http.ResponseWriter, r *http.Request, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	w.Header().Set("ETag", etag)
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		// Without a body there is nothing for ContentNegotiation to re-encode
+		w.Header().Del("Content-Type")
+		w.Header().Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag or is "*", comparing
+// weakly as RFC 9110 requires for If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
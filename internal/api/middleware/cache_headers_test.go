@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheHeaders(t *testing.T) {
+	body := `{"pools":[]}`
+	r := mux.NewRouter()
+	cached := CacheHeaders(10, 30)
+	r.Handle("/pools", cached(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))).Methods("GET")
+	r.Handle("/broken", cached(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Failed to fetch pools", http.StatusInternalServerError)
+	}))).Methods("GET")
+
+	// The ETag of the first response identifies the body
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/pools", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, body, w.Body.String())
+	assert.Equal(t, "public, max-age=10, stale-while-revalidate=30", w.Header().Get("Cache-Control"))
+	etag := w.Header().Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{64}"$`, etag)
+
+	testCases := []struct {
+		name           string
+		ifNoneMatch    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"no validator", "", http.StatusOK, body},
+		{"matching ETag", etag, http.StatusNotModified, ""},
+		{"matching weak ETag", "W/" + etag, http.StatusNotModified, ""},
+		{"matching ETag among others", `"stale", ` + etag, http.StatusNotModified, ""},
+		{"any ETag", "*", http.StatusNotModified, ""},
+		{"stale ETag", `"` + strings.Repeat("0", 64) + `"`, http.StatusOK, body},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/pools", nil)
+			if tc.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+			assert.Equal(t, etag, w.Header().Get("ETag"))
+			assert.NotEmpty(t, w.Header().Get("Cache-Control"))
+		})
+	}
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/broken", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Failed to fetch pools")
+		assert.Empty(t, w.Header().Get("ETag"))
+		assert.Empty(t, w.Header().Get("Cache-Control"))
+	})
+}
+
+func TestCacheHeaders_ChangedBody(t *testing.T) {
+	body := "first"
+	handler := CacheHeaders(300, 60)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/config", nil))
+	etag := w.Header().Get("ETag")
+
+	body = "second"
+	req := httptest.NewRequest("GET", "/config", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, "a changed body is sent again")
+	assert.Equal(t, "second", w.Body.String())
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
+
+func TestCacheHeaders_NotModifiedWithContentNegotiation(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(ContentNegotiation)
+	r.Handle("/pools", CacheHeaders(10, 30)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"count":1}`))
+	})))
+
+	req := httptest.NewRequest("GET", "/pools", nil)
+	req.Header.Set("Accept", MsgPackContentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, MsgPackContentType, w.Header().Get("Content-Type"))
+
+	req = httptest.NewRequest("GET", "/pools", nil)
+	req.Header.Set("Accept", MsgPackContentType)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+}
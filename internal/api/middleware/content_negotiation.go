@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgPackContentType is the media type clients send in Accept to receive MessagePack
+const MsgPackContentType = "application/msgpack"
+
+// ContentNegotiation re-encodes JSON responses as MessagePack for requests that accept
+// application/msgpack. Handlers keep writing JSON: the response is buffered, decoded and
+// encoded again, so it only costs anything for clients that ask for MessagePack.
+// Responses of any other content type pass through unchanged.
+func ContentNegotiation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		if !acceptsMsgPack(r.Header.Get("Accept")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := &bufferedResponse{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		body := recorder.body.Bytes()
+		if mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type")); mediaType == "application/json" {
+			if encoded, err := jsonToMsgPack(body); err != nil {
+				log.Printf("ContentNegotiation: sending JSON, failed to re-encode %s as MessagePack: %v", r.URL.Path, err)
+			} else {
+				w.Header().Set("Content-Type", MsgPackContentType)
+				w.Header().Del("Content-Length")
+				body = encoded
+			}
+		}
+
+		w.WriteHeader(recorder.status)
+		w.Write(body)
+	})
+}
+
+// acceptsMsgPack reports whether an Accept header lists MessagePack with a non-zero quality
+func acceptsMsgPack(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != MsgPackContentType {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if quality, err := strconv.ParseFloat(q, 64); err == nil && quality == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// bufferedResponse collects a response so it can be re-encoded before it is sent. It
+// shares its header map with the real response writer.
+type bufferedResponse struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if !b.wroteHeader {
+		b.status = status
+		b.wroteHeader = true
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
+
+// jsonToMsgPack encodes a JSON document as MessagePack, keeping integers as integers
+// in their smallest encoding
+func jsonToMsgPack(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+	// Counts and limits are small, so most integers fit in one or two bytes
+	encoder.UseCompactInts(true)
+	if err := encoder.Encode(convertNumbers(value)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// convertNumbers replaces the json.Numbers in value with int64s, or float64s where
+// the number has a fraction, exponent or does not fit an int64
+func convertNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = convertNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = convertNumbers(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestContentNegotiation(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(ContentNegotiation)
+	r.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"count":   2,
+			"score":   6.15,
+			"amount":  "1000000000000000000",
+			"pools":   []string{"pool1", "pool2"},
+			"enabled": true,
+		})
+	})
+	r.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("digraph {}"))
+	})
+
+	testCases := []struct {
+		name                string
+		path                string
+		accept              string
+		expectedContentType string
+	}{
+		{"JSON by default", "/json", "", "application/json"},
+		{"JSON requested", "/json", "application/json", "application/json"},
+		{"MessagePack requested", "/json", "application/msgpack", MsgPackContentType},
+		{"MessagePack among others", "/json", "application/json;q=0.5, application/msgpack", MsgPackContentType},
+		{"MessagePack refused", "/json", "application/msgpack;q=0", "application/json"},
+		{"Non-JSON response untouched", "/text", "application/msgpack", "text/plain; charset=utf-8"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", w.Header().Get("Vary"))
+			if tc.path == "/text" {
+				assert.Equal(t, "digraph {}", w.Body.String())
+				return
+			}
+			assert.Equal(t, http.StatusCreated, w.Code)
+
+			var body map[string]interface{}
+			if tc.expectedContentType == MsgPackContentType {
+				assert.NoError(t, msgpack.Unmarshal(w.Body.Bytes(), &body))
+				assert.EqualValues(t, 2, body["count"], "integers stay integers")
+				assert.IsType(t, int8(0), body["count"])
+			} else {
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, float64(2), body["count"])
+			}
+			assert.Equal(t, 6.15, body["score"])
+			assert.Equal(t, "1000000000000000000", body["amount"])
+			assert.Equal(t, []interface{}{"pool1", "pool2"}, body["pools"])
+			assert.Equal(t, true, body["enabled"])
+		})
+	}
+}
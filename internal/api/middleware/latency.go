@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ArtificialLatency delays every request by delay before handling it, to simulate a
+// slow backend during local development. It must never be enabled in production.
+func ArtificialLatency(delay time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
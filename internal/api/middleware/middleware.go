@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"dex-aggregator/internal/types"
+)
+
+// writeAPIError writes a structured APIError response
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&types.APIError{
+		Code:    code,
+		Message: message,
+	})
+}
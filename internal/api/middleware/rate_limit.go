@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/time/rate"
+)
+
+// Rate limit response headers, set on every limited response
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"     // Burst size
+	RateLimitRemainingHeader = "X-RateLimit-Remaining" // Requests left in the burst
+	RateLimitResetHeader     = "X-RateLimit-Reset"     // Unix time at which the burst is full again
+)
+
+// rateLimitExemptPaths are never limited, so probes and scrapes keep working under load
+var rateLimitExemptPaths = map[string]bool{
+	"/health":  true,
+	"/metrics": true,
+}
+
+// minLimiterIdleTTL is the shortest time a client's limiter is kept after its last request
+const minLimiterIdleTTL = time.Minute
+
+// RateLimit allows each client requestsPerSecond requests, in bursts of up to burst, and
+// rejects the rest with 429 and a Retry-After header. Clients are told apart by the API
+// key owner set by APIKeyAuth, so it must be installed after it, or by IP address for
+// unauthenticated requests. Every response reports the client's remaining quota in the
+// X-RateLimit headers so clients can pace themselves.
+func RateLimit(requestsPerSecond float64, burst int) mux.MiddlewareFunc {
+	limiters := newClientLimiters(requestsPerSecond, burst)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rateLimitExemptPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			limiter := limiters.get(rateLimitClient(r), time.Now())
+			reservation := limiter.Reserve()
+			delay := reservation.Delay()
+			allowed := reservation.OK() && delay == 0
+			if !allowed {
+				// Rejected requests do not use up quota
+				reservation.Cancel()
+			}
+			setRateLimitHeaders(w.Header(), limiter, time.Now())
+
+			if !allowed {
+				retryAfter := int(math.Ceil(delay.Seconds()))
+				if retryAfter < 1 {
+					retryAfter = 1
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				log.Printf("Rate limit exceeded: method=%s path=%s remote=%s", r.Method, r.URL.Path, r.RemoteAddr)
+				writeAPIError(w, http.StatusTooManyRequests, "ERR_RATE_LIMITED", "rate limit exceeded, retry after "+strconv.Itoa(retryAfter)+"s")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitClient identifies the client a request is counted against
+func rateLimitClient(r *http.Request) string {
+	if owner, ok := OwnerFromContext(r.Context()); ok {
+		return "key:" + owner
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// clientLimiters holds a token bucket per client. A bucket left idle long enough to
+// refill completely is indistinguishable from a new one, so it is dropped then to keep
+// the map from growing with every address ever seen.
+type clientLimiters struct {
+	limit   rate.Limit
+	burst   int
+	idleTTL time.Duration
+
+	mu        sync.Mutex
+	limiters  map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newClientLimiters(requestsPerSecond float64, burst int) *clientLimiters {
+	idleTTL := minLimiterIdleTTL
+	if requestsPerSecond > 0 {
+		idleTTL = max(idleTTL, time.Duration(float64(burst)/requestsPerSecond*float64(time.Second)))
+	}
+	return &clientLimiters{
+		limit:    rate.Limit(requestsPerSecond),
+		burst:    burst,
+		idleTTL:  idleTTL,
+		limiters: make(map[string]*clientLimiter),
+	}
+}
+
+// get returns the limiter of client, creating it on first use, and evicts idle
+// limiters at most once per idle TTL
+func (c *clientLimiters) get(client string, now time.Time) *rate.Limiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastSweep) >= c.idleTTL {
+		for key, entry := range c.limiters {
+			if now.Sub(entry.lastSeen) >= c.idleTTL {
+				delete(c.limiters, key)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	entry, ok := c.limiters[client]
+	if !ok {
+		entry = &clientLimiter{limiter: rate.NewLimiter(c.limit, c.burst)}
+		c.limiters[client] = entry
+	}
+	entry.lastSeen = now
+	return entry.limiter
+}
+
+// setRateLimitHeaders reports the limiter's quota as of now
+func setRateLimitHeaders(header http.Header, limiter *rate.Limiter, now time.Time) {
+	burst := limiter.Burst()
+	tokens := limiter.Tokens()
+
+	remaining := int(math.Floor(tokens))
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	reset := now
+	if missing := float64(burst) - tokens; missing > 0 && limiter.Limit() > 0 {
+		reset = now.Add(time.Duration(missing / float64(limiter.Limit()) * float64(time.Second)))
+	}
+
+	header.Set(RateLimitLimitHeader, strconv.Itoa(burst))
+	header.Set(RateLimitRemainingHeader, strconv.Itoa(remaining))
+	header.Set(RateLimitResetHeader, strconv.FormatInt(int64(math.Ceil(float64(reset.UnixNano())/float64(time.Second))), 10))
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"dex-aggregator/internal/types"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRateLimitTestRouter(requestsPerSecond float64, burst int) *mux.Router {
+	r := mux.NewRouter()
+	r.Use(RateLimit(requestsPerSecond, burst))
+	r.HandleFunc("/api/v1/pools", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return r
+}
+
+func headerInt(t *testing.T, w *httptest.ResponseRecorder, name string) int64 {
+	t.Helper()
+	value, err := strconv.ParseInt(w.Header().Get(name), 10, 64)
+	assert.NoError(t, err, "header %s", name)
+	return value
+}
+
+func TestRateLimit_Headers(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	// One request every 10s, so the bucket does not refill during the test
+	const burst = 5
+	r := newRateLimitTestRouter(0.1, burst)
+	start := time.Now()
+
+	previousRemaining, previousReset := int64(burst), int64(0)
+	for i := 0; i < burst; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/pools", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, strconv.Itoa(burst), w.Header().Get(RateLimitLimitHeader))
+
+		remaining := headerInt(t, w, RateLimitRemainingHeader)
+		assert.Equal(t, int64(burst-i-1), remaining)
+		assert.Less(t, remaining, previousRemaining, "remaining decreases with each request")
+
+		reset := headerInt(t, w, RateLimitResetHeader)
+		assert.Greater(t, reset, previousReset, "the reset moves later as the bucket empties")
+		// Refilling i+1 requests at 0.1/s takes 10s each
+		assert.InDelta(t, start.Unix()+int64(10*(i+1)), reset, 2)
+
+		previousRemaining, previousReset = remaining, reset
+	}
+
+	// The burst is spent
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/pools", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get(RateLimitRemainingHeader))
+	assert.Equal(t, previousReset, headerInt(t, w, RateLimitResetHeader), "rejected requests use no quota")
+	retryAfter := headerInt(t, w, "Retry-After")
+	assert.True(t, retryAfter >= 1 && retryAfter <= 10, "Retry-After %d", retryAfter)
+
+	var apiErr types.APIError
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+	assert.Equal(t, "ERR_RATE_LIMITED", apiErr.Code)
+
+	// Health checks are never limited
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(RateLimitRemainingHeader))
+}
+
+func TestRateLimit_Refills(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	r := newRateLimitTestRouter(100, 1)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/pools", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/pools", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	time.Sleep(20 * time.Millisecond)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/pools", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRateLimit_PerClient(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	r := mux.NewRouter()
+	r.Use(APIKeyAuth(map[string]string{"key-a": "alice", "key-a2": "alice", "key-b": "bob"}))
+	r.Use(RateLimit(0.1, 1))
+	r.HandleFunc("/api/v1/pools", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	request := func(key, remoteAddr string) int {
+		req := httptest.NewRequest("GET", "/api/v1/pools", nil)
+		req.Header.Set(APIKeyHeader, key)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("key-a", "192.0.2.1:1000"))
+	assert.Equal(t, http.StatusTooManyRequests, request("key-a", "192.0.2.1:1001"))
+
+	// Another owner behind the same address has its own quota
+	assert.Equal(t, http.StatusOK, request("key-b", "192.0.2.1:1002"))
+	// Every key of an owner shares the owner's quota, wherever it comes from
+	assert.Equal(t, http.StatusTooManyRequests, request("key-a2", "198.51.100.7:1000"))
+}
+
+func TestRateLimit_PerIPWithoutAPIKey(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	r := newRateLimitTestRouter(0.1, 1)
+	request := func(remoteAddr string) int {
+		req := httptest.NewRequest("GET", "/api/v1/pools", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("192.0.2.1:1000"))
+	// The port is ignored: a new connection from the same host is the same client
+	assert.Equal(t, http.StatusTooManyRequests, request("192.0.2.1:2000"))
+	assert.Equal(t, http.StatusOK, request("198.51.100.7:1000"))
+}
+
+func TestClientLimiters_EvictsIdleClients(t *testing.T) {
+	limiters := newClientLimiters(1, 10)
+	assert.Equal(t, minLimiterIdleTTL, limiters.idleTTL)
+	assert.Equal(t, 100*time.Second, newClientLimiters(0.1, 10).idleTTL, "kept until the bucket refills")
+
+	start := time.Now()
+	first := limiters.get("ip:192.0.2.1", start)
+	limiters.get("ip:198.51.100.7", start)
+	assert.Same(t, first, limiters.get("ip:192.0.2.1", start.Add(time.Second)))
+	assert.Len(t, limiters.limiters, 2)
+
+	// Only the client seen within the idle TTL survives the next sweep
+	limiters.get("ip:192.0.2.1", start.Add(minLimiterIdleTTL))
+	limiters.get("ip:203.0.113.9", start.Add(minLimiterIdleTTL+time.Second))
+	assert.NotContains(t, limiters.limiters, "ip:198.51.100.7")
+	assert.Len(t, limiters.limiters, 2)
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	applog "dex-aggregator/internal/log"
+
+	"github.com/gorilla/mux"
+)
+
+// RequestIDHeader carries the request ID, accepted from clients and echoed in responses
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID tags the request context with an ID for every log line written while the
+// request is served. A client-supplied X-Request-ID is reused, otherwise one is generated.
+func RequestID() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if id == "" || len(id) > 64 {
+				id = newRequestID()
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+			ctx := applog.WithContext(r.Context(), applog.RequestIDKey, id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// newRequestID returns 16 random hex characters
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
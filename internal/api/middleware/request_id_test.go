@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	applog "dex-aggregator/internal/log"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(previous)
+
+	r := mux.NewRouter()
+	r.Use(RequestID())
+	r.HandleFunc("/api/v1/quote", func(w http.ResponseWriter, r *http.Request) {
+		applog.FromContext(r.Context()).Info("handling quote")
+	})
+
+	// A client-supplied ID is kept
+	req := httptest.NewRequest("POST", "/api/v1/quote", nil)
+	req.Header.Set(RequestIDHeader, "client-id")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, "client-id", w.Header().Get(RequestIDHeader))
+	assert.Contains(t, buf.String(), "requestID=client-id")
+
+	// Otherwise one is generated
+	buf.Reset()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/quote", nil))
+
+	generated := w.Header().Get(RequestIDHeader)
+	assert.Len(t, generated, 16)
+	assert.Contains(t, buf.String(), "requestID="+generated)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"dex-aggregator/config"
+	"dex-aggregator/internal/types"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ethAddressLength is the length of a 0x-prefixed 20-byte hex address
+const ethAddressLength = 42
+
+// validateEthAddress returns an APIError unless addr is a 0x-prefixed hex address
+func validateEthAddress(addr string) error {
+	if len(addr) < ethAddressLength || !common.IsHexAddress(addr) {
+		return &types.APIError{
+			Code:    "ERR_INVALID_ADDRESS",
+			Message: fmt.Sprintf("invalid Ethereum address: %q", addr),
+		}
+	}
+	return nil
+}
+
+// validateChecksumAddress returns an APIError unless addr is a valid EIP-55 checksummed address
+func validateChecksumAddress(addr string) error {
+	if err := validateEthAddress(addr); err != nil {
+		return err
+	}
+	if common.HexToAddress(addr).Hex() != addr {
+		return &types.APIError{
+			Code:    "ERR_INVALID_CHECKSUM",
+			Message: fmt.Sprintf("address is not EIP-55 checksummed: %q", addr),
+		}
+	}
+	return nil
+}
+
+// NormalizeAddress returns the canonical lowercase form of an address
+func NormalizeAddress(addr string) string {
+	return strings.ToLower(addr)
+}
+
+// validatePoolFields checks an operator-supplied pool before it is stored, with
+// checkAddress applied to its pool and token addresses. It returns the status to
+// respond with alongside an APIError.
+func validatePoolFields(pool *types.Pool, checkAddress func(string) error) (int, error) {
+	if pool.Exchange == "" {
+		return http.StatusBadRequest, &types.APIError{Code: "ERR_MISSING_FIELD", Message: "exchange is required"}
+	}
+
+	for _, field := range []struct{ name, value string }{
+		{"address", pool.Address},
+		{"token0.address", pool.Token0.Address},
+		{"token1.address", pool.Token1.Address},
+	} {
+		if field.value == "" {
+			return http.StatusBadRequest, &types.APIError{Code: "ERR_MISSING_FIELD", Message: field.name + " is required"}
+		}
+		if err := checkAddress(field.value); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+
+	if pool.Reserve0 == nil || pool.Reserve0.Sign() <= 0 || pool.Reserve1 == nil || pool.Reserve1.Sign() <= 0 {
+		return http.StatusBadRequest, &types.APIError{Code: "ERR_INVALID_RESERVES", Message: "reserve0 and reserve1 must be positive"}
+	}
+
+	if dexConfig := config.Current().DEX; dexConfig.StrictExchangeValidation {
+		if _, ok := dexConfig.FindExchange(pool.Exchange); !ok {
+			return http.StatusUnprocessableEntity, &types.APIError{Code: "ERR_UNKNOWN_EXCHANGE", Message: "exchange " + pool.Exchange + " is not configured"}
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// bodyErrorStatus is the status for an error reading a request body: 413 when it
+// exceeded an http.MaxBytesReader limit and 400 otherwise
+func bodyErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// writeAPIError writes err as a structured APIError response
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	apiErr, ok := err.(*types.APIError)
+	if !ok {
+		apiErr = &types.APIError{Code: "ERR_INTERNAL", Message: err.Error()}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiErr)
+}
@@ -2,22 +2,53 @@ package cache
 
 import (
 	"context"
+	"dex-aggregator/internal/history"
 	"dex-aggregator/internal/types"
+	"dex-aggregator/internal/validation"
+	"dex-aggregator/internal/volume"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
 	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 )
 
+// TestMain runs the package tests against an in-process Redis server, exported through
+// REDIS_ADDR, so they never depend on a real Redis instance
+func TestMain(m *testing.M) {
+	server, err := miniredis.Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start miniredis: %v\n", err)
+		os.Exit(1)
+	}
+	os.Setenv("REDIS_ADDR", server.Addr())
+
+	code := m.Run()
+	server.Close()
+	os.Exit(code)
+}
+
+// testRedisAddr returns the address of the Redis server started by TestMain
+func testRedisAddr() string {
+	return os.Getenv("REDIS_ADDR")
+}
+
 func TestTwoLevelCache_StoreAndGetPool(t *testing.T) {
-	// Use real two-level cache, but use memory store to simulate Redis
-	// In real environment, this should connect to test Redis instance
-	tlc := NewTwoLevelCache(
-		"localhost:6379", // Test Redis address
-		"",
-		time.Minute*5,
-	)
+	tlc := NewTwoLevelCache(testRedisAddr(), "", types.DefaultChainID, time.Minute*5)
+	defer tlc.Close()
+	ctx := context.Background()
 
 	pool := &types.Pool{
 		Address:  "test-pool",
@@ -27,38 +58,49 @@ func TestTwoLevelCache_StoreAndGetPool(t *testing.T) {
 		Reserve0: big.NewInt(1000000),
 		Reserve1: big.NewInt(2000000),
 	}
+	assert.NoError(t, tlc.StorePool(ctx, pool))
 
-	// Test storage - since Redis might be unavailable, we mainly test local cache part
-	err := tlc.StorePool(context.Background(), pool)
-	// If Redis is unavailable, may return error, but we still verify local cache
-	if err != nil {
-		t.Logf("Redis store failed (expected in test environment): %v", err)
-	}
-
-	// Verify local cache has data
-	localPool, err := tlc.localCache.GetPool(context.Background(), "test-pool")
+	// Both layers hold the pool
+	localPool, err := tlc.localCache.GetPool(ctx, "test-pool")
 	assert.NoError(t, err)
 	assert.Equal(t, pool.Address, localPool.Address)
+
+	redisPool, err := tlc.redisCache.GetPool(ctx, "test-pool")
+	assert.NoError(t, err)
+	assert.Equal(t, pool.Address, redisPool.Address)
+	assert.Equal(t, "2000000", redisPool.Reserve1.String())
 }
 
 func TestTwoLevelCache_GetPool_LocalCacheHit(t *testing.T) {
-	tlc := NewTwoLevelCache("localhost:6379", "", time.Minute*5)
+	tlc := NewTwoLevelCache(testRedisAddr(), "", types.DefaultChainID, time.Minute*5)
+	defer tlc.Close()
+	ctx := context.Background()
 
-	// First store data in local cache
+	// Store the pool in the local cache only
 	pool := &types.Pool{
 		Address:  "local-pool",
 		Exchange: "Uniswap V2",
 	}
-	tlc.localCache.StorePool(context.Background(), pool)
+	assert.NoError(t, tlc.localCache.StorePool(ctx, pool))
 
-	// Test get - should hit local cache
-	retrievedPool, err := tlc.GetPool(context.Background(), "local-pool")
+	// Test get - should hit local cache without asking Redis, which does not have it
+	retrievedPool, err := tlc.GetPool(ctx, "local-pool")
 	assert.NoError(t, err)
 	assert.Equal(t, pool.Address, retrievedPool.Address)
 
 	// Verify statistics
 	stats := tlc.GetStats()
 	assert.Equal(t, int64(1), stats.LocalHits)
+	assert.Zero(t, stats.RedisHits+stats.RedisMisses)
+
+	// A pool only in Redis is a local miss and a Redis hit
+	assert.NoError(t, tlc.redisCache.StorePool(ctx, &types.Pool{Address: "redis-pool", Reserve0: big.NewInt(1), Reserve1: big.NewInt(1)}))
+	_, err = tlc.GetPool(ctx, "redis-pool")
+	assert.NoError(t, err)
+
+	stats = tlc.GetStats()
+	assert.Equal(t, int64(1), stats.LocalMisses)
+	assert.Equal(t, int64(1), stats.RedisHits)
 }
 
 func TestMemoryStore_BasicOperations(t *testing.T) {
@@ -113,13 +155,18 @@ func TestMemoryStore_TokenOperations(t *testing.T) {
 		Decimals: 18,
 	}
 
-	// Store token (current implementation is no-op)
+	// Store token
 	err := store.StoreToken(ctx, token)
 	assert.NoError(t, err)
 
-	// Get token (returns default)
+	// Get stored token
 	retrievedToken, err := store.GetToken(ctx, "0xtoken")
 	assert.NoError(t, err)
+	assert.Equal(t, "TEST", retrievedToken.Symbol)
+
+	// Unknown token returns default
+	retrievedToken, err = store.GetToken(ctx, "0xunknown")
+	assert.NoError(t, err)
 	assert.Equal(t, "UNKNOWN", retrievedToken.Symbol)
 }
 
@@ -216,3 +263,1481 @@ func TestMemoryStore_ConcurrentAccessSafe(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, len(pools), len(allPools))
 }
+
+func TestMemoryStore_Count(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	store := NewMemoryStore()
+	ctx := context.Background()
+	newPool := func(i int) *types.Pool {
+		return &types.Pool{
+			Address:  fmt.Sprintf("count-pool-%d", i),
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: fmt.Sprintf("0xtoken%d", i)},
+			Token1:   types.Token{Address: "0xquote"},
+			Reserve0: big.NewInt(1000000),
+			Reserve1: big.NewInt(2000000),
+		}
+	}
+
+	// Writers store 100 pools, each twice, and delete the odd ones while readers count
+	const poolCount = 100
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				count := store.Count()
+				assert.GreaterOrEqual(t, count, int64(0))
+				assert.LessOrEqual(t, count, int64(poolCount))
+			}
+		}()
+	}
+	for i := 0; i < poolCount; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, store.StorePool(ctx, newPool(i)))
+			assert.NoError(t, store.StorePool(ctx, newPool(i)), "re-storing a pool does not count it again")
+			if i%2 == 1 {
+				assert.NoError(t, store.DeletePool(ctx, newPool(i).Address))
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(stop)
+	readers.Wait()
+
+	allPools, err := store.GetAllPools(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, allPools, poolCount/2)
+	assert.Equal(t, int64(poolCount/2), store.Count())
+
+	// Pools of other chains are not returned by GetAllPools, so they are not counted
+	otherChain := newPool(poolCount)
+	otherChain.ChainID = types.DefaultChainID + 1
+	assert.NoError(t, store.StorePool(ctx, otherChain))
+	assert.Equal(t, int64(poolCount/2), store.Count())
+
+	store.ClearPools()
+	assert.Zero(t, store.Count())
+}
+
+func TestMemoryStore_GetPoolsBySymbol(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryStore()
+	pools := []*types.Pool{
+		{Address: "weth-usdt", Token0: types.Token{Address: "0xweth", Symbol: "WETH"}, Token1: types.Token{Address: "0xusdt", Symbol: "USDT"}},
+		{Address: "usdt-weth", Token0: types.Token{Address: "0xusdt", Symbol: "USDT"}, Token1: types.Token{Address: "0xweth", Symbol: "WETH"}},
+		{Address: "weth-dai", Token0: types.Token{Address: "0xweth", Symbol: "WETH"}, Token1: types.Token{Address: "0xdai", Symbol: "DAI"}},
+	}
+	for _, pool := range pools {
+		assert.NoError(t, ms.StorePool(ctx, pool))
+	}
+
+	testCases := []struct {
+		symbolA, symbolB string
+		expected         []string
+	}{
+		{"WETH", "USDT", []string{"usdt-weth", "weth-usdt"}},
+		{"usdt", "weth", []string{"usdt-weth", "weth-usdt"}},
+		{"WETH", "DAI", []string{"weth-dai"}},
+		{"USDT", "DAI", nil},
+		{"WBTC", "WETH", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.symbolA+"/"+tc.symbolB, func(t *testing.T) {
+			found, err := ms.GetPoolsBySymbol(ctx, tc.symbolA, tc.symbolB)
+			assert.NoError(t, err)
+
+			var addresses []string
+			for _, pool := range found {
+				addresses = append(addresses, pool.Address)
+			}
+			sort.Strings(addresses)
+			assert.Equal(t, tc.expected, addresses)
+		})
+	}
+}
+
+func TestMemoryStore_UpdatePool(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	original := &types.Pool{
+		Address:  "test-pool",
+		Token0:   types.Token{Address: "0xtokena"},
+		Token1:   types.Token{Address: "0xtokenb"},
+		Reserve0: big.NewInt(1000),
+		Reserve1: big.NewInt(2000),
+	}
+	assert.NoError(t, store.StorePool(ctx, original))
+
+	paused := true
+	updated, err := store.UpdatePool(ctx, "test-pool", &types.PoolUpdate{Paused: &paused})
+	assert.NoError(t, err)
+	assert.True(t, updated.Paused)
+	assert.Equal(t, "1000", updated.Reserve0.String(), "unset fields are unchanged")
+
+	// Readers holding the old pointer never see the update
+	assert.False(t, original.Paused)
+
+	stored, err := store.GetPool(ctx, "test-pool")
+	assert.NoError(t, err)
+	assert.True(t, stored.Paused)
+
+	pools, err := store.GetPoolsByTokens(ctx, "0xtokena", "0xtokenb")
+	assert.NoError(t, err)
+	if assert.Len(t, pools, 1) {
+		assert.True(t, pools[0].Paused)
+	}
+
+	_, err = store.UpdatePool(ctx, "missing-pool", &types.PoolUpdate{Paused: &paused})
+	assert.Error(t, err)
+}
+
+func TestMemoryStore_RecordsReserveHistory(t *testing.T) {
+	store := NewMemoryStore()
+	poolHistory := history.NewPoolHistory(10)
+	store.SetPoolHistory(poolHistory)
+	ctx := context.Background()
+
+	newPool := func(reserve0, reserve1 int64) *types.Pool {
+		return &types.Pool{
+			Address:  "test-pool",
+			Token0:   types.Token{Address: "0xtokena"},
+			Token1:   types.Token{Address: "0xtokenb"},
+			Reserve0: big.NewInt(reserve0),
+			Reserve1: big.NewInt(reserve1),
+		}
+	}
+
+	assert.NoError(t, store.StorePool(ctx, newPool(1000, 2000)))
+	assert.NoError(t, store.StorePool(ctx, newPool(1000, 2000)), "unchanged reserves are not recorded")
+	assert.NoError(t, store.StorePool(ctx, newPool(1100, 1900)))
+
+	paused := true
+	_, err := store.UpdatePool(ctx, "test-pool", &types.PoolUpdate{Paused: &paused})
+	assert.NoError(t, err)
+	assert.NoError(t, store.ApplyReserveDelta(ctx, "test-pool", big.NewInt(-50), big.NewInt(50)))
+
+	// Newest first: the delta, the re-import, then the first store
+	snapshots := poolHistory.Snapshots("test-pool", time.Time{}, 0)
+	if assert.Len(t, snapshots, 3) {
+		assert.Equal(t, "1050", snapshots[0].Reserve0.String())
+		assert.Equal(t, "1950", snapshots[0].Reserve1.String())
+		assert.Equal(t, "1100", snapshots[1].Reserve0.String())
+		assert.Equal(t, "1000", snapshots[2].Reserve0.String())
+		assert.Equal(t, "2000", snapshots[2].Reserve1.String())
+		assert.False(t, snapshots[0].Timestamp.IsZero())
+	}
+
+	// Deleting the pool drops its history
+	assert.NoError(t, store.DeletePool(ctx, "test-pool"))
+	assert.Empty(t, poolHistory.Snapshots("test-pool", time.Time{}, 0))
+}
+
+func TestMemoryStore_RecordsReserveChangeVolume(t *testing.T) {
+	store := NewMemoryStore()
+	volumes := volume.NewAccumulator()
+	store.SetVolumeAccumulator(volumes)
+	ctx := context.Background()
+
+	newPool := func(reserve0, reserve1 int64) *types.Pool {
+		return &types.Pool{
+			Address:  "test-pool",
+			Token0:   types.Token{Address: "0xtokena"},
+			Token1:   types.Token{Address: "0xtokenb"},
+			Reserve0: big.NewInt(reserve0),
+			Reserve1: big.NewInt(reserve1),
+		}
+	}
+
+	// The first store has no previous reserves to compare with
+	assert.NoError(t, store.StorePool(ctx, newPool(1000, 2000)))
+	assert.Equal(t, "0", volumes.GetVolume24h("test-pool").String())
+
+	assert.NoError(t, store.StorePool(ctx, newPool(1100, 1900)))
+	_, err := store.UpdatePool(ctx, "test-pool", &types.PoolUpdate{Reserve0: big.NewInt(1040)})
+	assert.NoError(t, err)
+	assert.NoError(t, store.ApplyReserveDelta(ctx, "test-pool", big.NewInt(-25), big.NewInt(30)))
+	assert.NoError(t, store.BulkUpdateReserves(ctx, []types.ReserveUpdate{{Address: "test-pool", Reserve0: "1055"}}))
+
+	// 100 from the re-import, 60 from the update, 25 from the delta and 40 from the bulk update
+	assert.Equal(t, "225", volumes.GetVolume24h("test-pool").String())
+}
+
+// recordingObserver records the address of every pool it is notified of
+type recordingObserver struct {
+	mu        sync.Mutex
+	addresses []string
+}
+
+func (o *recordingObserver) OnPoolStored(pool *types.Pool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.addresses = append(o.addresses, pool.Address)
+}
+
+func (o *recordingObserver) stored() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.addresses...)
+}
+
+type panickingObserver struct{}
+
+func (panickingObserver) OnPoolStored(pool *types.Pool) {
+	panic("observer failed")
+}
+
+func TestMemoryStore_Observers(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	minLiquidity, err := validation.NewMinLiquidityValidator("1000000")
+	assert.NoError(t, err)
+	store := NewMemoryStore(minLiquidity)
+	ctx := context.Background()
+	newPool := func(address string) *types.Pool {
+		return &types.Pool{
+			Address:  address,
+			Token0:   types.Token{Address: "0xtokena"},
+			Token1:   types.Token{Address: "0xtokenb"},
+			Reserve0: big.NewInt(1000),
+			Reserve1: big.NewInt(2000),
+		}
+	}
+
+	first, second := &recordingObserver{}, &recordingObserver{}
+	store.AddObserver(first)
+	store.AddObserver(&panickingObserver{})
+	store.AddObserver(second)
+
+	assert.NoError(t, store.StorePool(ctx, newPool("0xpool1")))
+	assert.NoError(t, store.StorePool(ctx, newPool("0xpool2")))
+	assert.NoError(t, store.StorePool(ctx, newPool("0xpool1")), "re-stored pools are notified again")
+	shallow := newPool("0xshallow")
+	shallow.Reserve0 = big.NewInt(1)
+	assert.Error(t, store.StorePool(ctx, shallow), "rejected pools are not notified")
+
+	expected := []string{"0xpool1", "0xpool2", "0xpool1"}
+	assert.Equal(t, expected, first.stored())
+	assert.Equal(t, expected, second.stored(), "a panicking observer does not stop the others")
+
+	store.RemoveObserver(first)
+	assert.NoError(t, store.StorePool(ctx, newPool("0xpool3")))
+	assert.Equal(t, expected, first.stored())
+	assert.Equal(t, append(expected, "0xpool3"), second.stored())
+}
+
+func TestMemoryStore_ObserverMayReadStore(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	var found *types.Pool
+	store.AddObserver(observerFunc(func(pool *types.Pool) {
+		found, _ = store.GetPool(ctx, pool.Address)
+	}))
+
+	assert.NoError(t, store.StorePool(ctx, &types.Pool{
+		Address:  "test-pool",
+		Token0:   types.Token{Address: "0xtokena"},
+		Token1:   types.Token{Address: "0xtokenb"},
+		Reserve0: big.NewInt(1000),
+		Reserve1: big.NewInt(2000),
+	}))
+	if assert.NotNil(t, found, "observers run after the write lock is released") {
+		assert.Equal(t, "test-pool", found.Address)
+	}
+}
+
+type observerFunc func(pool *types.Pool)
+
+func (f observerFunc) OnPoolStored(pool *types.Pool) { f(pool) }
+
+func TestTwoLevelCache_AddObserver(t *testing.T) {
+	tlc := NewTwoLevelCache(testRedisAddr(), "", types.DefaultChainID, time.Minute*5)
+	defer tlc.Close()
+
+	observer := &recordingObserver{}
+	tlc.AddObserver(observer)
+	assert.NoError(t, tlc.StorePool(context.Background(), &types.Pool{
+		Address:  "observed-pool",
+		Exchange: "Uniswap V2",
+		Token0:   types.Token{Address: "0xtokena"},
+		Token1:   types.Token{Address: "0xtokenb"},
+		Reserve0: big.NewInt(1000000),
+		Reserve1: big.NewInt(2000000),
+	}))
+	assert.Equal(t, []string{"observed-pool"}, observer.stored())
+
+	tlc.RemoveObserver(observer)
+	assert.Empty(t, tlc.localCache.observers)
+}
+
+func TestMemoryStore_GetPoolsCreatedAfter(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	start := time.Now()
+
+	pool := &types.Pool{Address: "test-pool", Token0: types.Token{Address: "0xtokena"}, Token1: types.Token{Address: "0xtokenb"}}
+	assert.NoError(t, store.StorePool(ctx, pool))
+	createdAt := pool.CreatedAt
+	assert.False(t, createdAt.Before(start))
+
+	// Storing the pool again is an update and keeps the creation time
+	assert.NoError(t, store.StorePool(ctx, &types.Pool{Address: "test-pool", Reserve0: big.NewInt(5)}))
+	stored, err := store.GetPool(ctx, "test-pool")
+	assert.NoError(t, err)
+	assert.Equal(t, createdAt, stored.CreatedAt)
+
+	pools, err := store.GetPoolsCreatedAfter(ctx, start.Add(-time.Second))
+	assert.NoError(t, err)
+	assert.Len(t, pools, 1)
+
+	pools, err = store.GetPoolsCreatedAfter(ctx, createdAt)
+	assert.NoError(t, err)
+	assert.Empty(t, pools)
+}
+
+func TestMemoryStore_GetPoolsByExchange(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	for _, pool := range []*types.Pool{
+		{Address: "uni-1", Exchange: "Uniswap V2", Token0: types.Token{Address: "0xtokena"}, Token1: types.Token{Address: "0xtokenb"}},
+		{Address: "uni-2", Exchange: "Uniswap V2", Token0: types.Token{Address: "0xtokenb"}, Token1: types.Token{Address: "0xtokenc"}},
+		{Address: "sushi-1", Exchange: "SushiSwap", Token0: types.Token{Address: "0xtokena"}, Token1: types.Token{Address: "0xtokenb"}},
+	} {
+		assert.NoError(t, store.StorePool(ctx, pool))
+	}
+	// Re-storing a pool does not index it twice
+	assert.NoError(t, store.StorePool(ctx, &types.Pool{Address: "uni-1", Exchange: "Uniswap V2", Token0: types.Token{Address: "0xtokena"}, Token1: types.Token{Address: "0xtokenb"}}))
+
+	addresses := func(exchange string) []string {
+		pools, err := store.GetPoolsByExchange(ctx, exchange)
+		assert.NoError(t, err)
+		var out []string
+		for _, pool := range pools {
+			out = append(out, pool.Address)
+		}
+		return out
+	}
+
+	assert.ElementsMatch(t, []string{"uni-1", "uni-2"}, addresses("Uniswap V2"))
+	assert.ElementsMatch(t, []string{"uni-1", "uni-2"}, addresses("uniswap v2"))
+	assert.ElementsMatch(t, []string{"sushi-1"}, addresses("SushiSwap"))
+	assert.Empty(t, addresses("Curve"))
+
+	// A pool stored under another exchange moves between indexes
+	assert.NoError(t, store.StorePool(ctx, &types.Pool{Address: "uni-2", Exchange: "SushiSwap", Token0: types.Token{Address: "0xtokenb"}, Token1: types.Token{Address: "0xtokenc"}}))
+	assert.ElementsMatch(t, []string{"uni-1"}, addresses("Uniswap V2"))
+	assert.ElementsMatch(t, []string{"sushi-1", "uni-2"}, addresses("SushiSwap"))
+
+	assert.NoError(t, store.DeletePool(ctx, "uni-1"))
+	assert.Empty(t, addresses("Uniswap V2"))
+	_, err := store.GetPool(ctx, "uni-1")
+	assert.Error(t, err)
+	pairPools, err := store.GetPoolsByTokens(ctx, "0xtokena", "0xtokenb")
+	assert.NoError(t, err)
+	assert.Len(t, pairPools, 1)
+
+	assert.Error(t, store.DeletePool(ctx, "uni-1"))
+}
+
+func TestMemoryStore_ApplyReserveDelta_Concurrent(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	store := NewMemoryStore()
+	ctx := context.Background()
+	assert.NoError(t, store.StorePool(ctx, &types.Pool{
+		Address:  "test-pool",
+		Token0:   types.Token{Address: "0xtokena"},
+		Token1:   types.Token{Address: "0xtokenb"},
+		Reserve0: big.NewInt(10000),
+		Reserve1: big.NewInt(10000),
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, store.ApplyReserveDelta(ctx, "test-pool", big.NewInt(10), big.NewInt(-5)))
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, store.ApplyReserveDelta(ctx, "test-pool", big.NewInt(-3), nil))
+		}()
+	}
+	wg.Wait()
+
+	pool, err := store.GetPool(ctx, "test-pool")
+	assert.NoError(t, err)
+	assert.Equal(t, "10700", pool.Reserve0.String())
+	assert.Equal(t, "9500", pool.Reserve1.String())
+
+	// Reserves never go below zero
+	assert.NoError(t, store.ApplyReserveDelta(ctx, "test-pool", big.NewInt(-20000), nil))
+	pool, _ = store.GetPool(ctx, "test-pool")
+	assert.Equal(t, "0", pool.Reserve0.String())
+
+	assert.ErrorIs(t, store.ApplyReserveDelta(ctx, "missing-pool", big.NewInt(1), nil), ErrPoolNotFound)
+}
+
+func TestReserveUpdatedAt(t *testing.T) {
+	redisStore, _ := newMiniRedisStore(t, 0)
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"redis":  redisStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			before := time.Now()
+
+			assert.NoError(t, store.StorePool(ctx, &types.Pool{
+				Address:  "0xfresh",
+				Token0:   types.Token{Address: "0xtokena"},
+				Token1:   types.Token{Address: "0xtokenb"},
+				Reserve0: big.NewInt(1000),
+				Reserve1: big.NewInt(2000),
+			}))
+			pool, err := store.GetPool(ctx, "0xfresh")
+			assert.NoError(t, err)
+			assert.False(t, pool.ReserveUpdatedAt.Before(before), "StorePool stamps new reserves")
+
+			// A known reserve time, as when copying pools between caches, is kept
+			old := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+			assert.NoError(t, store.StorePool(ctx, &types.Pool{
+				Address:          "0xold",
+				Token0:           types.Token{Address: "0xtokena"},
+				Token1:           types.Token{Address: "0xtokenb"},
+				Reserve0:         big.NewInt(1000),
+				Reserve1:         big.NewInt(2000),
+				ReserveUpdatedAt: old,
+			}))
+			pool, err = store.GetPool(ctx, "0xold")
+			assert.NoError(t, err)
+			assert.True(t, pool.ReserveUpdatedAt.Equal(old))
+
+			assert.NoError(t, store.ApplyReserveDelta(ctx, "0xold", big.NewInt(1), nil))
+			pool, err = store.GetPool(ctx, "0xold")
+			assert.NoError(t, err)
+			assert.False(t, pool.ReserveUpdatedAt.Before(before), "ApplyReserveDelta stamps the new reserves")
+			assert.Equal(t, "1001", pool.Reserve0.String())
+		})
+	}
+}
+
+func TestMemoryStore_RejectsLowLiquidityPools(t *testing.T) {
+	minLiquidity, err := validation.NewMinLiquidityValidator("1000000000000")
+	assert.NoError(t, err)
+	store := NewMemoryStore(minLiquidity)
+	ctx := context.Background()
+
+	pool := &types.Pool{Address: "dust-pool", Reserve0: big.NewInt(1000), Reserve1: big.NewInt(1000)}
+	err = store.StorePool(ctx, pool)
+	assert.True(t, errors.Is(err, validation.ErrInsufficientLiquidity), "got %v", err)
+	_, err = store.GetPool(ctx, "dust-pool")
+	assert.Error(t, err, "rejected pools are not stored")
+
+	pool = &types.Pool{Address: "deep-pool", Reserve0: big.NewInt(1000000), Reserve1: big.NewInt(1000000)}
+	assert.NoError(t, store.StorePool(ctx, pool))
+}
+
+func TestMemoryStore_ChainNamespacing(t *testing.T) {
+	ctx := context.Background()
+
+	newPools := func() (*types.Pool, *types.Pool) {
+		mainnetPool := &types.Pool{
+			Address:  "0xsamepool",
+			ChainID:  1,
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xtokena"},
+			Token1:   types.Token{Address: "0xtokenb"},
+			Reserve0: big.NewInt(1000000),
+			Reserve1: big.NewInt(2000000),
+		}
+		polygonPool := &types.Pool{
+			Address:  "0xsamepool",
+			ChainID:  137,
+			Exchange: "QuickSwap",
+			Token0:   types.Token{Address: "0xtokena"},
+			Token1:   types.Token{Address: "0xtokenb"},
+			Reserve0: big.NewInt(3000000),
+			Reserve1: big.NewInt(4000000),
+		}
+		return mainnetPool, polygonPool
+	}
+
+	for _, chainID := range []int64{1, 137} {
+		store := NewMemoryStoreWithChain(chainID)
+		mainnetPool, polygonPool := newPools()
+
+		assert.NoError(t, store.StorePool(ctx, mainnetPool))
+		assert.NoError(t, store.StorePool(ctx, polygonPool))
+
+		expected := mainnetPool
+		if chainID == 137 {
+			expected = polygonPool
+		}
+
+		retrievedPool, err := store.GetPool(ctx, "0xsamepool")
+		assert.NoError(t, err)
+		assert.Equal(t, chainID, retrievedPool.ChainID)
+		assert.Equal(t, expected.Exchange, retrievedPool.Exchange)
+		assert.Equal(t, expected.Reserve0.String(), retrievedPool.Reserve0.String())
+
+		allPools, err := store.GetAllPools(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(allPools))
+
+		pairPools, err := store.GetPoolsByTokens(ctx, "0xtokena", "0xtokenb")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(pairPools))
+		assert.Equal(t, chainID, pairPools[0].ChainID)
+	}
+}
+
+func TestMemoryStore_DefaultChainID(t *testing.T) {
+	store := NewMemoryStore()
+
+	pool := &types.Pool{Address: "no-chain-pool"}
+	assert.NoError(t, store.StorePool(context.Background(), pool))
+	assert.Equal(t, types.DefaultChainID, pool.ChainID)
+}
+
+func TestRedisStore_ChainKeys(t *testing.T) {
+	mainnet := NewRedisStore(testRedisAddr(), "", 1)
+	polygon := NewRedisStore(testRedisAddr(), "", 137)
+
+	assert.Equal(t, "dex:1:pool:0xabc", mainnet.poolKey(mainnet.chainID, "0xabc"))
+	assert.Equal(t, "dex:137:pool:0xabc", polygon.poolKey(polygon.chainID, "0xabc"))
+	assert.Equal(t, "dex:137:all_pools", polygon.allPoolsKey(polygon.chainID))
+	assert.Equal(t, "dex:1:token_pair:0xa:0xb", mainnet.tokenPairKey(mainnet.chainID, "0xa", "0xb"))
+	assert.NotEqual(t, mainnet.poolKey(mainnet.chainID, "0xabc"), polygon.poolKey(polygon.chainID, "0xabc"))
+}
+
+// roundTripHook counts client round-trips: one per command or pipeline sent
+type roundTripHook struct {
+	count     int64
+	pipelines int64 // round-trips that were pipelines
+}
+
+func (h *roundTripHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	atomic.AddInt64(&h.count, 1)
+	return ctx, nil
+}
+
+func (h *roundTripHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *roundTripHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	atomic.AddInt64(&h.count, 1)
+	atomic.AddInt64(&h.pipelines, 1)
+	return ctx, nil
+}
+
+func (h *roundTripHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+// newMiniRedisStore returns a RedisStore backed by an in-process Redis server,
+// with poolCount pools stored between the same token pair
+func newMiniRedisStore(tb testing.TB, poolCount int) (*RedisStore, *roundTripHook) {
+	tb.Helper()
+
+	server := miniredis.RunT(tb)
+	store := NewRedisStore(server.Addr(), "", types.DefaultChainID)
+	tb.Cleanup(func() { store.client.Close() })
+
+	for i := 0; i < poolCount; i++ {
+		pool := &types.Pool{
+			Address:  fmt.Sprintf("0xpool%d", i),
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xtokena", Symbol: "A", Decimals: 18},
+			Token1:   types.Token{Address: "0xtokenb", Symbol: "B", Decimals: 18},
+			Reserve0: big.NewInt(int64(1000 + i)),
+			Reserve1: big.NewInt(int64(2000 + i)),
+		}
+		if err := store.StorePool(context.Background(), pool); err != nil {
+			tb.Fatalf("failed to store pool: %v", err)
+		}
+	}
+
+	hook := &roundTripHook{}
+	store.client.AddHook(hook)
+	return store, hook
+}
+
+func TestRedisStore_UpdatePool(t *testing.T) {
+	store, _ := newMiniRedisStore(t, 1)
+	ctx := context.Background()
+
+	paused := true
+	updated, err := store.UpdatePool(ctx, "0xpool0", &types.PoolUpdate{Paused: &paused, Reserve1: big.NewInt(2500)})
+	assert.NoError(t, err)
+	assert.True(t, updated.Paused)
+
+	stored, err := store.GetPool(ctx, "0xpool0")
+	assert.NoError(t, err)
+	assert.True(t, stored.Paused)
+	assert.Equal(t, "1000", stored.Reserve0.String())
+	assert.Equal(t, "2500", stored.Reserve1.String())
+
+	_, err = store.UpdatePool(ctx, "0xmissing", &types.PoolUpdate{Paused: &paused})
+	assert.Error(t, err)
+}
+
+func TestRedisStore_GetPoolsCreatedAfter(t *testing.T) {
+	start := time.Now()
+	store, _ := newMiniRedisStore(t, 2)
+	ctx := context.Background()
+
+	original, err := store.GetPool(ctx, "0xpool0")
+	assert.NoError(t, err)
+	assert.False(t, original.CreatedAt.Before(start))
+
+	members, err := store.client.ZRange(ctx, "dex:1:pools_by_creation", 0, -1).Result()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"0xpool0", "0xpool1"}, members)
+
+	// Re-storing an existing pool keeps its creation time
+	assert.NoError(t, store.StorePool(ctx, &types.Pool{Address: "0xpool0", Reserve0: big.NewInt(1), Reserve1: big.NewInt(1)}))
+	stored, err := store.GetPool(ctx, "0xpool0")
+	assert.NoError(t, err)
+	assert.Equal(t, original.CreatedAt.Unix(), stored.CreatedAt.Unix())
+
+	pools, err := store.GetPoolsCreatedAfter(ctx, start.Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Len(t, pools, 2)
+
+	pools, err = store.GetPoolsCreatedAfter(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Empty(t, pools)
+}
+
+func TestRedisStore_GetAllPools_UnindexesExpiredPools(t *testing.T) {
+	store, _ := newMiniRedisStore(t, 2)
+	ctx := context.Background()
+
+	// 0xpool0 expires, leaving its address in the indexes
+	assert.NoError(t, store.client.Del(ctx, "dex:1:pool:0xpool0").Err())
+
+	pools, err := store.GetAllPools(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, pools, 1) {
+		assert.Equal(t, "0xpool1", pools[0].Address)
+	}
+
+	members, err := store.client.SMembers(ctx, "dex:1:all_pools").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0xpool1"}, members)
+	members, err = store.client.ZRange(ctx, "dex:1:pools_by_creation", 0, -1).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0xpool1"}, members)
+
+	// Stored again, it is a new pool with a new creation time
+	assert.NoError(t, store.StorePool(ctx, &types.Pool{Address: "0xpool0", Reserve0: big.NewInt(1), Reserve1: big.NewInt(1)}))
+	pools, err = store.GetAllPools(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, pools, 2)
+}
+
+func TestRedisStore_GetPoolsByExchange(t *testing.T) {
+	store, _ := newMiniRedisStore(t, 2) // Uniswap V2 pools
+	ctx := context.Background()
+
+	sushi := &types.Pool{Address: "0xsushi", Exchange: "SushiSwap", Token0: types.Token{Address: "0xtokena"}, Token1: types.Token{Address: "0xtokenb"}, Reserve0: big.NewInt(1), Reserve1: big.NewInt(1)}
+	assert.NoError(t, store.StorePool(ctx, sushi))
+
+	pools, err := store.GetPoolsByExchange(ctx, "uniswap v2")
+	assert.NoError(t, err)
+	assert.Len(t, pools, 2)
+
+	pools, err = store.GetPoolsByExchange(ctx, "SushiSwap")
+	assert.NoError(t, err)
+	if assert.Len(t, pools, 1) {
+		assert.Equal(t, "0xsushi", pools[0].Address)
+	}
+
+	// A pool stored under another exchange is no longer listed under its old one
+	moved := &types.Pool{Address: "0xpool0", Exchange: "SushiSwap", Token0: types.Token{Address: "0xtokena"}, Token1: types.Token{Address: "0xtokenb"}, Reserve0: big.NewInt(1), Reserve1: big.NewInt(1)}
+	assert.NoError(t, store.StorePool(ctx, moved))
+	pools, err = store.GetPoolsByExchange(ctx, "Uniswap V2")
+	assert.NoError(t, err)
+	assert.Len(t, pools, 1)
+
+	pools, err = store.GetPoolsByExchange(ctx, "Curve")
+	assert.NoError(t, err)
+	assert.Empty(t, pools)
+}
+
+func TestRedisStore_ApplyReserveDelta_Concurrent(t *testing.T) {
+	store, _ := newMiniRedisStore(t, 1) // reserves 1000 / 2000
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, store.ApplyReserveDelta(ctx, "0xpool0", big.NewInt(10), big.NewInt(-5)))
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, store.ApplyReserveDelta(ctx, "0xpool0", big.NewInt(-3), nil))
+		}()
+	}
+	wg.Wait()
+
+	pool, err := store.GetPool(ctx, "0xpool0")
+	assert.NoError(t, err)
+	assert.Equal(t, "1350", pool.Reserve0.String())
+	assert.Equal(t, "1750", pool.Reserve1.String())
+	assert.Equal(t, "0xtokena", pool.Token0.Address, "the rest of the pool is unchanged")
+
+	// Reserves beyond float precision, crossing digit boundaries, and clamping at zero
+	large, _ := new(big.Int).SetString("999999999999999999999999", 10)
+	assert.NoError(t, store.ApplyReserveDelta(ctx, "0xpool0", large, big.NewInt(-1750)))
+	pool, _ = store.GetPool(ctx, "0xpool0")
+	assert.Equal(t, "1000000000000000000001349", pool.Reserve0.String())
+	assert.Equal(t, "0", pool.Reserve1.String())
+
+	assert.NoError(t, store.ApplyReserveDelta(ctx, "0xpool0", big.NewInt(-1349), nil))
+	pool, _ = store.GetPool(ctx, "0xpool0")
+	assert.Equal(t, "1000000000000000000000000", pool.Reserve0.String())
+
+	ttl, err := store.client.TTL(ctx, store.poolKey(types.DefaultChainID, "0xpool0")).Result()
+	assert.NoError(t, err)
+	assert.Greater(t, ttl, time.Duration(0), "the pool keeps its TTL")
+
+	assert.ErrorIs(t, store.ApplyReserveDelta(ctx, "0xmissing", big.NewInt(1), nil), ErrPoolNotFound)
+}
+
+func TestRedisStore_RejectsLowLiquidityPools(t *testing.T) {
+	server := miniredis.RunT(t)
+	minLiquidity, err := validation.NewMinLiquidityValidator("1000000000000")
+	assert.NoError(t, err)
+	store := NewRedisStore(server.Addr(), "", types.DefaultChainID, minLiquidity)
+	defer store.client.Close()
+	ctx := context.Background()
+
+	err = store.StorePool(ctx, &types.Pool{Address: "0xdust", Reserve0: big.NewInt(1000), Reserve1: big.NewInt(1000)})
+	assert.True(t, errors.Is(err, validation.ErrInsufficientLiquidity), "got %v", err)
+	assert.False(t, server.Exists(store.poolKey(types.DefaultChainID, "0xdust")))
+
+	assert.NoError(t, store.StorePool(ctx, &types.Pool{Address: "0xdeep", Reserve0: big.NewInt(1000000), Reserve1: big.NewInt(1000000)}))
+}
+
+func TestRedisStore_GetPoolsByTokens_Pipelined(t *testing.T) {
+	store, hook := newMiniRedisStore(t, 5)
+	ctx := context.Background()
+
+	pools, err := store.GetPoolsByTokens(ctx, "0xtokenb", "0xtokena")
+	assert.NoError(t, err)
+	assert.Len(t, pools, 5)
+
+	// One SMEMBERS lookup plus one pipelined GET
+	assert.Equal(t, int64(2), atomic.LoadInt64(&hook.count))
+
+	atomic.StoreInt64(&hook.count, 0)
+	allPools, err := store.GetAllPools(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, allPools, 5)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&hook.count))
+
+	pools, err = store.GetPoolsByTokens(ctx, "0xtokena", "0xmissing")
+	assert.NoError(t, err)
+	assert.Empty(t, pools)
+}
+
+func TestRedisStore_GetPoolsByTokens_EitherTokenOrder(t *testing.T) {
+	store, _ := newMiniRedisStore(t, 0)
+	ctx := context.Background()
+
+	const (
+		weth = "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"
+		usdt = "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+	)
+	// The same pair stored with each token first, in mixed case
+	wethFirst := &types.Pool{Address: "0xpool-weth-usdt", Exchange: "Uniswap V2", Token0: types.Token{Address: weth}, Token1: types.Token{Address: usdt}, Reserve0: big.NewInt(1), Reserve1: big.NewInt(1)}
+	usdtFirst := &types.Pool{Address: "0xpool-usdt-weth", Exchange: "SushiSwap", Token0: types.Token{Address: usdt}, Token1: types.Token{Address: weth}, Reserve0: big.NewInt(1), Reserve1: big.NewInt(1)}
+	assert.NoError(t, store.StorePool(ctx, wethFirst))
+	assert.NoError(t, store.StorePool(ctx, usdtFirst))
+
+	// Both pools share the one lowercase, address-ordered index key
+	members, err := store.client.SMembers(ctx, store.tokenPairKey(store.chainID, strings.ToLower(weth), strings.ToLower(usdt))).Result()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"0xpool-weth-usdt", "0xpool-usdt-weth"}, members)
+
+	for _, query := range [][2]string{{weth, usdt}, {usdt, weth}, {strings.ToLower(usdt), strings.ToLower(weth)}} {
+		tokenA, tokenB := query[0], query[1]
+		pools, err := store.GetPoolsByTokens(ctx, tokenA, tokenB)
+		assert.NoError(t, err)
+		addresses := make([]string, len(pools))
+		for i, pool := range pools {
+			addresses[i] = pool.Address
+		}
+		assert.ElementsMatch(t, []string{"0xpool-weth-usdt", "0xpool-usdt-weth"}, addresses, "%s/%s", tokenA, tokenB)
+	}
+}
+
+func TestRedisStore_PipelineGetAllPools(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	store, hook := newMiniRedisStore(t, 20)
+
+	pools, err := store.GetAllPools(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, pools, 20)
+
+	// SMEMBERS for the addresses, then all 20 GETs in a single pipelined EXEC
+	assert.Equal(t, int64(1), atomic.LoadInt64(&hook.pipelines))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&hook.count))
+}
+
+// BenchmarkRedisStore_GetPoolsByTokens compares per-pool GETs with the pipelined lookup.
+// Run with: go test ./internal/cache -run=^$ -bench=GetPoolsByTokens -benchmem
+func BenchmarkRedisStore_GetPoolsByTokens(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	const poolCount = 50
+	ctx := context.Background()
+
+	b.Run("sequential", func(b *testing.B) {
+		store, hook := newMiniRedisStore(b, poolCount)
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			addrs, err := store.client.SMembers(ctx, store.tokenPairKey(store.chainID, "0xtokena", "0xtokenb")).Result()
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, addr := range addrs {
+				if _, err := store.GetPool(ctx, addr); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+
+		b.ReportMetric(float64(atomic.LoadInt64(&hook.count))/float64(b.N), "roundtrips/op")
+	})
+
+	b.Run("pipelined", func(b *testing.B) {
+		store, hook := newMiniRedisStore(b, poolCount)
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			if _, err := store.GetPoolsByTokens(ctx, "0xtokena", "0xtokenb"); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		b.ReportMetric(float64(atomic.LoadInt64(&hook.count))/float64(b.N), "roundtrips/op")
+	})
+}
+
+// failingHook fails the first failures commands with a transient error
+type failingHook struct {
+	failures int64
+	calls    int64
+}
+
+func (h *failingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	if atomic.AddInt64(&h.calls, 1) <= h.failures {
+		return ctx, fmt.Errorf("transient failure")
+	}
+	return ctx, nil
+}
+
+func (h *failingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *failingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return h.BeforeProcess(ctx, nil)
+}
+
+func (h *failingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	transient := fmt.Errorf("connection reset")
+
+	t.Run("Fails twice then succeeds", func(t *testing.T) {
+		attempts := 0
+		start := time.Now()
+		err := retryWithBackoff(context.Background(), 4, time.Millisecond, func() error {
+			attempts++
+			if attempts <= 2 {
+				return transient
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+		// 1ms then 4ms between attempts
+		assert.GreaterOrEqual(t, time.Since(start), 5*time.Millisecond)
+	})
+
+	t.Run("Does not retry redis.Nil", func(t *testing.T) {
+		attempts := 0
+		err := retryWithBackoff(context.Background(), 4, time.Millisecond, func() error {
+			attempts++
+			return redis.Nil
+		})
+		assert.Equal(t, redis.Nil, err)
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("Gives up after maxAttempts", func(t *testing.T) {
+		attempts := 0
+		err := retryWithBackoff(context.Background(), 3, time.Millisecond, func() error {
+			attempts++
+			return transient
+		})
+		assert.Equal(t, transient, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("Stops when the deadline is too close", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		attempts := 0
+		start := time.Now()
+		err := retryWithBackoff(ctx, 4, 50*time.Millisecond, func() error {
+			attempts++
+			return transient
+		})
+		assert.Equal(t, transient, err)
+		assert.Equal(t, 1, attempts)
+		assert.Less(t, time.Since(start), 20*time.Millisecond)
+	})
+}
+
+func TestRedisStore_RetriesTransientErrors(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	store, _ := newMiniRedisStore(t, 1)
+	store.SetRetryPolicy(3, time.Millisecond)
+
+	hook := &failingHook{failures: 2}
+	store.client.AddHook(hook)
+
+	pool, err := store.GetPool(context.Background(), "0xpool0")
+	assert.NoError(t, err)
+	assert.Equal(t, "0xpool0", pool.Address)
+	assert.Equal(t, int64(3), atomic.LoadInt64(&hook.calls))
+
+	// A missing key is a result, not a failure, so it is not retried
+	hook.failures = 0
+	atomic.StoreInt64(&hook.calls, 0)
+	_, err = store.GetPool(context.Background(), "0xmissing")
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&hook.calls))
+
+	// Pipelined reads are retried as a whole
+	hook.failures = 2
+	atomic.StoreInt64(&hook.calls, 0)
+	pools, err := store.GetAllPools(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, pools, 1)
+}
+
+func TestTwoLevelCache_ClearLocalCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	tlc := NewTwoLevelCache(server.Addr(), "", types.DefaultChainID, time.Minute*5)
+	defer tlc.Close()
+	ctx := context.Background()
+
+	pool := &types.Pool{Address: "cleared-pool", Exchange: "Uniswap V2", Reserve0: big.NewInt(1), Reserve1: big.NewInt(1)}
+	assert.NoError(t, tlc.StorePool(ctx, pool))
+	assert.NoError(t, tlc.StoreToken(ctx, &types.Token{Address: "0xtoken", Symbol: "TKN", Decimals: 6}))
+
+	tlc.ClearLocalCache()
+
+	_, err := tlc.localCache.GetPool(ctx, "cleared-pool")
+	assert.Error(t, err)
+	localPools, err := tlc.localCache.GetAllPools(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, localPools)
+
+	// Lookups fall back to Redis
+	found, err := tlc.GetPool(ctx, "cleared-pool")
+	assert.NoError(t, err)
+	assert.Equal(t, "cleared-pool", found.Address)
+	assert.Equal(t, int64(1), tlc.GetStats().RedisHits)
+
+	// Tokens stay cached
+	token, err := tlc.localCache.GetToken(ctx, "0xtoken")
+	assert.NoError(t, err)
+	assert.Equal(t, "TKN", token.Symbol)
+}
+
+func TestTwoLevelCache_ApplyReserveDelta_RecordsVolume(t *testing.T) {
+	server := miniredis.RunT(t)
+	tlc := NewTwoLevelCache(server.Addr(), "", types.DefaultChainID, time.Minute*5)
+	defer tlc.Close()
+	volumes := volume.NewAccumulator()
+	tlc.SetVolumeAccumulator(volumes)
+	ctx := context.Background()
+
+	pool := &types.Pool{Address: "delta-pool", Exchange: "Uniswap V2", Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000)}
+	assert.NoError(t, tlc.StorePool(ctx, pool))
+
+	assert.NoError(t, tlc.ApplyReserveDelta(ctx, "delta-pool", big.NewInt(-300), big.NewInt(600)))
+	assert.Equal(t, "300", volumes.GetVolume24h("delta-pool").String())
+
+	// A pool only Redis holds is recorded too
+	tlc.ClearLocalCache()
+	assert.NoError(t, tlc.ApplyReserveDelta(ctx, "delta-pool", big.NewInt(200), big.NewInt(-400)))
+	assert.Equal(t, "500", volumes.GetVolume24h("delta-pool").String())
+
+	// Failed deltas are not
+	assert.ErrorIs(t, tlc.ApplyReserveDelta(ctx, "missing-pool", big.NewInt(100), nil), ErrPoolNotFound)
+	assert.Equal(t, "0", volumes.GetVolume24h("missing-pool").String())
+}
+
+func TestTwoLevelCache_Count(t *testing.T) {
+	server := miniredis.RunT(t)
+	tlc := NewTwoLevelCache(server.Addr(), "", types.DefaultChainID, time.Minute*5)
+	defer tlc.Close()
+	ctx := context.Background()
+
+	for _, address := range []string{"count-pool-1", "count-pool-2"} {
+		pool := &types.Pool{Address: address, Exchange: "Uniswap V2", Reserve0: big.NewInt(1), Reserve1: big.NewInt(1)}
+		assert.NoError(t, tlc.StorePool(ctx, pool))
+	}
+	assert.Equal(t, int64(2), tlc.Count())
+
+	// The count is that of the local cache
+	tlc.ClearLocalCache()
+	assert.Equal(t, int64(0), tlc.Count())
+}
+
+func TestTwoLevelCache_GetPool_ReadYourWrites(t *testing.T) {
+	server := miniredis.RunT(t)
+	tlc := NewTwoLevelCache(server.Addr(), "", types.DefaultChainID, time.Minute*5)
+	defer tlc.Close()
+	ctx := context.Background()
+
+	pool := func(reserve0 int64) *types.Pool {
+		return &types.Pool{
+			Address:  "0xpool",
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xtokena"},
+			Token1:   types.Token{Address: "0xtokenb"},
+			Reserve0: big.NewInt(reserve0),
+			Reserve1: big.NewInt(2000),
+		}
+	}
+	stale := pool(1000)
+	assert.NoError(t, tlc.StorePool(ctx, stale))
+	assert.NoError(t, tlc.StorePool(ctx, pool(1500)))
+
+	// A warm from a GetAllPools snapshot taken before the second write replaces the
+	// local copy with the stale pool
+	tlc.warmLocalCache([]*types.Pool{stale})
+	local, err := tlc.localCache.GetPool(ctx, "0xpool")
+	assert.NoError(t, err)
+	assert.Equal(t, "1000", local.Reserve0.String())
+
+	found, err := tlc.GetPool(ctx, "0xpool")
+	assert.NoError(t, err)
+	assert.Equal(t, "1500", found.Reserve0.String())
+
+	// As does an eviction of the local copy
+	tlc.ClearLocalCache()
+	found, err = tlc.GetPool(ctx, "0xpool")
+	assert.NoError(t, err)
+	assert.Equal(t, "1500", found.Reserve0.String())
+
+	stats := tlc.GetStats()
+	assert.Zero(t, stats.LocalHits+stats.LocalMisses, "recent writes skip the local cache")
+	assert.Equal(t, int64(2), stats.RedisHits)
+
+	// Once the write is older than WriteBufferTTL, the local cache serves the pool again
+	tlc.writeBuffer.Store("0xpool", time.Now().Add(-WriteBufferTTL))
+	assert.Eventually(t, func() bool {
+		_, err := tlc.localCache.GetPool(ctx, "0xpool")
+		return err == nil
+	}, time.Second, time.Millisecond, "the Redis read backfills the local cache")
+	_, err = tlc.GetPool(ctx, "0xpool")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), tlc.GetStats().LocalHits)
+	_, buffered := tlc.writeBuffer.Load("0xpool")
+	assert.False(t, buffered, "expired entries are dropped")
+}
+
+func TestTwoLevelCache_StorePool_SweepsWriteBuffer(t *testing.T) {
+	server := miniredis.RunT(t)
+	tlc := NewTwoLevelCache(server.Addr(), "", types.DefaultChainID, time.Minute*5)
+	defer tlc.Close()
+	ctx := context.Background()
+
+	tlc.writeBuffer.Store("0xexpired", time.Now().Add(-WriteBufferTTL))
+	assert.NoError(t, tlc.StorePool(ctx, &types.Pool{Address: "0xfresh", Reserve0: big.NewInt(1), Reserve1: big.NewInt(1)}))
+
+	_, expired := tlc.writeBuffer.Load("0xexpired")
+	assert.False(t, expired)
+	_, fresh := tlc.writeBuffer.Load("0xfresh")
+	assert.True(t, fresh)
+}
+
+// testBulkUpdateReserves checks BulkUpdateReserves of store, which holds no pools yet
+func testBulkUpdateReserves(t *testing.T, store Store) {
+	ctx := context.Background()
+	for _, address := range []string{"0xpool1", "0xpool2"} {
+		assert.NoError(t, store.StorePool(ctx, &types.Pool{
+			Address:  address,
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xtokena"},
+			Token1:   types.Token{Address: "0xtokenb"},
+			Reserve0: big.NewInt(1000),
+			Reserve1: big.NewInt(2000),
+		}))
+	}
+	lastUpdated := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("empty input", func(t *testing.T) {
+		assert.NoError(t, store.BulkUpdateReserves(ctx, nil))
+	})
+
+	t.Run("full success", func(t *testing.T) {
+		assert.NoError(t, store.BulkUpdateReserves(ctx, []types.ReserveUpdate{
+			{Address: "0xpool1", Reserve0: "1100", Reserve1: "2200", LastUpdated: lastUpdated},
+			// Only reserve1, so reserve0 is unchanged
+			{Address: "0xpool2", Reserve1: "2500"},
+		}))
+
+		pool1, err := store.GetPool(ctx, "0xpool1")
+		assert.NoError(t, err)
+		assert.Equal(t, "1100", pool1.Reserve0.String())
+		assert.Equal(t, "2200", pool1.Reserve1.String())
+		assert.True(t, lastUpdated.Equal(pool1.LastUpdated))
+		assert.Equal(t, "Uniswap V2", pool1.Exchange)
+
+		pool2, err := store.GetPool(ctx, "0xpool2")
+		assert.NoError(t, err)
+		assert.Equal(t, "1000", pool2.Reserve0.String())
+		assert.Equal(t, "2500", pool2.Reserve1.String())
+	})
+
+	t.Run("partially missing pools", func(t *testing.T) {
+		err := store.BulkUpdateReserves(ctx, []types.ReserveUpdate{
+			{Address: "0xmissing1", Reserve0: "1"},
+			{Address: "0xpool1", Reserve0: "1200"},
+			{Address: "0xmissing2", Reserve0: "1"},
+		})
+		var notFound *PoolsNotFoundError
+		if assert.ErrorAs(t, err, &notFound) {
+			assert.Equal(t, []string{"0xmissing1", "0xmissing2"}, notFound.Addresses)
+		}
+
+		pool1, err := store.GetPool(ctx, "0xpool1")
+		assert.NoError(t, err)
+		assert.Equal(t, "1200", pool1.Reserve0.String())
+	})
+
+	t.Run("invalid reserve rejects the batch", func(t *testing.T) {
+		err := store.BulkUpdateReserves(ctx, []types.ReserveUpdate{
+			{Address: "0xpool1", Reserve0: "1300"},
+			{Address: "0xpool2", Reserve0: "-1"},
+		})
+		assert.EqualError(t, err, "invalid reserve0 for pool 0xpool2: -1")
+
+		pool1, err := store.GetPool(ctx, "0xpool1")
+		assert.NoError(t, err)
+		assert.Equal(t, "1200", pool1.Reserve0.String())
+	})
+}
+
+func TestMemoryStore_BulkUpdateReserves(t *testing.T) {
+	testBulkUpdateReserves(t, NewMemoryStore())
+}
+
+func TestRedisStore_BulkUpdateReserves(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := NewRedisStore(server.Addr(), "", types.DefaultChainID)
+	defer store.client.Close()
+
+	testBulkUpdateReserves(t, store)
+
+	// Rewritten pools keep their expiry
+	assert.Equal(t, 24*time.Hour, server.TTL(store.poolKey(types.DefaultChainID, "0xpool1")))
+}
+
+func TestTwoLevelCache_BulkUpdateReserves(t *testing.T) {
+	server := miniredis.RunT(t)
+	tlc := NewTwoLevelCache(server.Addr(), "", types.DefaultChainID, time.Minute*5)
+	defer tlc.Close()
+
+	testBulkUpdateReserves(t, tlc)
+
+	// Both layers hold the updated reserves
+	ctx := context.Background()
+	local, err := tlc.localCache.GetPool(ctx, "0xpool1")
+	assert.NoError(t, err)
+	assert.Equal(t, "1200", local.Reserve0.String())
+	remote, err := tlc.redisCache.GetPool(ctx, "0xpool1")
+	assert.NoError(t, err)
+	assert.Equal(t, "1200", remote.Reserve0.String())
+
+	// Only the pools found were written
+	_, buffered := tlc.writeBuffer.Load("0xmissing1")
+	assert.False(t, buffered)
+}
+
+func TestTwoLevelCache_BulkUpdateReserves_RecordsVolume(t *testing.T) {
+	server := miniredis.RunT(t)
+	tlc := NewTwoLevelCache(server.Addr(), "", types.DefaultChainID, time.Minute*5)
+	defer tlc.Close()
+	volumes := volume.NewAccumulator()
+	tlc.SetVolumeAccumulator(volumes)
+	ctx := context.Background()
+
+	for _, address := range []string{"0xlocal", "0xremote"} {
+		pool := &types.Pool{Address: address, Exchange: "Uniswap V2", Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000)}
+		assert.NoError(t, tlc.StorePool(ctx, pool))
+	}
+	// Only Redis holds 0xremote
+	assert.NoError(t, tlc.localCache.DeletePool(ctx, "0xremote"))
+
+	err := tlc.BulkUpdateReserves(ctx, []types.ReserveUpdate{
+		{Address: "0xlocal", Reserve0: "1200", Reserve1: "1800"},
+		{Address: "0xremote", Reserve0: "700", Reserve1: "2400"},
+		{Address: "0xmissing", Reserve0: "5000"},
+	})
+	var notFound *PoolsNotFoundError
+	if assert.ErrorAs(t, err, &notFound) {
+		assert.Equal(t, []string{"0xmissing"}, notFound.Addresses)
+	}
+
+	assert.Equal(t, "200", volumes.GetVolume24h("0xlocal").String())
+	assert.Equal(t, "300", volumes.GetVolume24h("0xremote").String())
+	assert.Equal(t, "0", volumes.GetVolume24h("0xmissing").String())
+}
+
+func TestTwoLevelCache_GetAllPools_FallsBackToLocalCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	tlc := NewTwoLevelCache(server.Addr(), "", types.DefaultChainID, time.Minute*5)
+	defer tlc.Close()
+	tlc.SetRetryPolicy(1, time.Millisecond)
+	ctx := context.Background()
+
+	pool := &types.Pool{
+		Address:  "0xpool",
+		Exchange: "Uniswap V2",
+		Token0:   types.Token{Address: "0xtokena", Symbol: "A"},
+		Token1:   types.Token{Address: "0xtokenb", Symbol: "B"},
+		Reserve0: big.NewInt(1000),
+		Reserve1: big.NewInt(2000),
+	}
+	assert.NoError(t, tlc.StorePool(ctx, pool))
+
+	// Every Redis command fails from here on, simulating an outage
+	tlc.redisCache.client.AddHook(&failingHook{failures: math.MaxInt64})
+
+	pools, err := tlc.GetAllPools(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, pools, 1)
+	assert.Equal(t, "0xpool", pools[0].Address)
+	assert.Equal(t, int64(1), tlc.GetStats().FallbackHits)
+}
+
+func TestTwoLevelCache_GetAllPools_EmptyLocalCacheReturnsError(t *testing.T) {
+	server := miniredis.RunT(t)
+	tlc := NewTwoLevelCache(server.Addr(), "", types.DefaultChainID, time.Minute*5)
+	defer tlc.Close()
+	tlc.SetRetryPolicy(1, time.Millisecond)
+	tlc.redisCache.client.AddHook(&failingHook{failures: math.MaxInt64})
+
+	pools, err := tlc.GetAllPools(context.Background())
+	assert.Error(t, err)
+	assert.Nil(t, pools)
+	assert.Zero(t, tlc.GetStats().FallbackHits)
+}
+
+func TestTwoLevelCache_WarmLocalCacheFromRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+
+	// Pools written by another instance exist only in Redis
+	writer := NewRedisStore(server.Addr(), "", types.DefaultChainID)
+	for i := 0; i < 25; i++ {
+		assert.NoError(t, writer.StorePool(ctx, &types.Pool{
+			Address:  fmt.Sprintf("0xpool%d", i),
+			Exchange: "Uniswap V2",
+			Token0:   types.Token{Address: "0xtokena", Symbol: "A"},
+			Token1:   types.Token{Address: "0xtokenb", Symbol: "B"},
+			Reserve0: big.NewInt(1000),
+			Reserve1: big.NewInt(2000),
+		}))
+	}
+
+	tlc := NewTwoLevelCache(server.Addr(), "", types.DefaultChainID, time.Minute*5)
+	defer tlc.Close()
+	progress := make(chan WarmProgress, 10)
+	tlc.SetWarmProgress(progress)
+
+	assert.False(t, tlc.WarmingComplete())
+	assert.NoError(t, tlc.WarmLocalCacheFromRedis(ctx, 10))
+	assert.True(t, tlc.WarmingComplete())
+
+	close(progress)
+	var updates []WarmProgress
+	for p := range progress {
+		updates = append(updates, p)
+	}
+	assert.Equal(t, []WarmProgress{{10, 25}, {20, 25}, {25, 25}}, updates)
+
+	// Every pool is now served from the local cache
+	for i := 0; i < 25; i++ {
+		_, err := tlc.localCache.GetPool(ctx, fmt.Sprintf("0xpool%d", i))
+		assert.NoError(t, err)
+	}
+}
+
+func TestTwoLevelCache_WarmLocalCacheFromRedis_Canceled(t *testing.T) {
+	server := miniredis.RunT(t)
+	tlc := NewTwoLevelCache(server.Addr(), "", types.DefaultChainID, time.Minute*5)
+	defer tlc.Close()
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, tlc.redisCache.StorePool(context.Background(), &types.Pool{
+			Address:  fmt.Sprintf("0xpool%d", i),
+			Token0:   types.Token{Address: "0xtokena"},
+			Token1:   types.Token{Address: "0xtokenb"},
+			Reserve0: big.NewInt(1000),
+			Reserve1: big.NewInt(2000),
+		}))
+	}
+
+	// Cancellation is noticed while waiting between batches
+	delay := warmBatchDelay
+	warmBatchDelay = time.Minute
+	defer func() { warmBatchDelay = delay }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := make(chan WarmProgress, 1)
+	tlc.SetWarmProgress(progress)
+	go func() {
+		<-progress
+		cancel()
+	}()
+
+	err := tlc.WarmLocalCacheFromRedis(ctx, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, tlc.WarmingComplete())
+}
+
+func TestTwoLevelCache_GetStats_RedisConnectionPool(t *testing.T) {
+	server := miniredis.RunT(t)
+	tlc := NewTwoLevelCache(server.Addr(), "", types.DefaultChainID, time.Minute)
+	defer tlc.Close()
+	ctx := context.Background()
+
+	pool := &types.Pool{Address: "0xpool", Exchange: "Uniswap V2", Token0: types.Token{Address: "0xtokena"}, Token1: types.Token{Address: "0xtokenb"}, Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000)}
+	assert.NoError(t, tlc.StorePool(ctx, pool))
+	_, err := tlc.redisCache.GetPool(ctx, "0xpool")
+	assert.NoError(t, err)
+
+	connPool := tlc.redisCache.ConnectionPoolStats()
+	// The first command dialled a connection that later commands reused
+	assert.GreaterOrEqual(t, connPool.Misses, uint32(1))
+	assert.GreaterOrEqual(t, connPool.Hits, uint32(1))
+	assert.Zero(t, connPool.Timeouts)
+
+	stats := tlc.GetStats()
+	assert.Equal(t, int64(connPool.Hits), stats.RedisConnPoolHits)
+	assert.Equal(t, int64(connPool.Misses), stats.RedisConnPoolMisses)
+	assert.Equal(t, int64(connPool.Timeouts), stats.RedisConnPoolTimeouts)
+}
+
+func TestTwoLevelCache_GetPoolLatencyPercentiles(t *testing.T) {
+	server := miniredis.RunT(t)
+	tlc := NewTwoLevelCache(server.Addr(), "", types.DefaultChainID, time.Minute*5)
+	defer tlc.Close()
+	ctx := context.Background()
+
+	assert.NoError(t, tlc.StorePool(ctx, &types.Pool{
+		Address:  "0xpool",
+		Token0:   types.Token{Address: "0xtokena"},
+		Token1:   types.Token{Address: "0xtokenb"},
+		Reserve0: big.NewInt(1000),
+		Reserve1: big.NewInt(2000),
+	}))
+	// Lapse the write buffer, so the lookups below are served by the local cache
+	tlc.writeBuffer.Clear()
+
+	for i := 0; i < 100; i++ {
+		_, err := tlc.GetPool(ctx, "0xpool")
+		assert.NoError(t, err)
+	}
+	// Misses in both levels are timed too
+	_, err := tlc.GetPool(ctx, "0xmissing")
+	assert.Error(t, err)
+
+	stats := tlc.GetStats()
+	assert.Len(t, stats.L1Latencies, 101)
+	assert.Len(t, stats.L2Latencies, 1)
+
+	l1P50, _ := stats.P50LatencyNs()
+	l1P95, l2P95 := stats.P95LatencyNs()
+	l1P99, _ := stats.P99LatencyNs()
+	assert.Positive(t, l1P95)
+	assert.Less(t, l1P95, int64(50*time.Millisecond), "local lookups are in-memory")
+	assert.LessOrEqual(t, l1P50, l1P95)
+	assert.LessOrEqual(t, l1P95, l1P99)
+	assert.Positive(t, l2P95)
+}
+
+func TestCacheStats_LatencySamples(t *testing.T) {
+	stats := &CacheStats{}
+	l1, l2 := stats.P99LatencyNs()
+	assert.Zero(t, l1)
+	assert.Zero(t, l2)
+
+	// 1..100ns in reverse, so percentiles depend on sorting
+	for i := 100; i >= 1; i-- {
+		recordLatency(&stats.L1Latencies, &stats.l1Next, time.Duration(i))
+	}
+	l1, _ = stats.P50LatencyNs()
+	assert.Equal(t, int64(50), l1)
+	l1, _ = stats.P95LatencyNs()
+	assert.Equal(t, int64(95), l1)
+	l1, _ = stats.P99LatencyNs()
+	assert.Equal(t, int64(99), l1)
+
+	// Samples are bounded, the oldest being overwritten first
+	for i := 0; i < maxLatencySamples; i++ {
+		recordLatency(&stats.L1Latencies, &stats.l1Next, time.Millisecond)
+	}
+	assert.Len(t, stats.L1Latencies, maxLatencySamples)
+	l1, _ = stats.P50LatencyNs()
+	assert.Equal(t, int64(time.Millisecond), l1)
+}
@@ -2,32 +2,185 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"dex-aggregator/internal/history"
 	"dex-aggregator/internal/types"
+	"dex-aggregator/internal/validation"
+	"dex-aggregator/internal/volume"
 )
 
+// ErrPoolNotFound is returned, possibly wrapped, for lookups and updates of a pool that
+// is not stored
+var ErrPoolNotFound = errors.New("pool not found")
+
 type MemoryStore struct {
-	pools      map[string]*types.Pool
+	chainID    int64
+	pools      map[string]*types.Pool // keyed by poolKey(chainID, address)
 	tokenPairs map[string]map[string][]string
+	byExchange map[string][]string // lowercase exchange name -> pool keys
+	tokens     map[string]*types.Token
+	fees       map[string]int             // detected pool fees, keyed by poolKey(chainID, address)
+	validators []validation.PoolValidator // checked by StorePool
+	history    *history.PoolHistory       // nil disables reserve history
+	volumes    *volume.Accumulator        // nil disables volume tracking
+	observers  []PoolObserver             // notified by StorePool
 	mutex      sync.RWMutex
+
+	// poolCount is the number of pools GetAllPools returns, readable without the mutex
+	poolCount atomic.Int64
+}
+
+// PoolObserver is notified of every pool a MemoryStore stores
+type PoolObserver interface {
+	OnPoolStored(pool *types.Pool)
+}
+
+func NewMemoryStore(validators ...validation.PoolValidator) *MemoryStore {
+	return NewMemoryStoreWithChain(types.DefaultChainID, validators...)
 }
 
-func NewMemoryStore() *MemoryStore {
+// NewMemoryStoreWithChain creates a memory store whose lookups are scoped to chainID.
+// StorePool rejects pools that fail any of validators.
+func NewMemoryStoreWithChain(chainID int64, validators ...validation.PoolValidator) *MemoryStore {
 	return &MemoryStore{
+		chainID:    chainID,
 		pools:      make(map[string]*types.Pool),
 		tokenPairs: make(map[string]map[string][]string),
+		byExchange: make(map[string][]string),
+		tokens:     make(map[string]*types.Token),
+		fees:       make(map[string]int),
+		validators: validators,
+	}
+}
+
+// SetPoolHistory records every reserve change of stored pools in poolHistory
+func (ms *MemoryStore) SetPoolHistory(poolHistory *history.PoolHistory) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.history = poolHistory
+}
+
+// SetVolumeAccumulator records the reserve0 change of every pool whose reserves are
+// updated, by any write, in volumes
+func (ms *MemoryStore) SetVolumeAccumulator(volumes *volume.Accumulator) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.volumes = volumes
+}
+
+// AddObserver registers o to be called after every successful StorePool. Observers run
+// synchronously on the storing goroutine, so they should return quickly; a panicking
+// observer is logged and does not affect the store or the other observers.
+func (ms *MemoryStore) AddObserver(o PoolObserver) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.observers = append(ms.observers, o)
+}
+
+// RemoveObserver unregisters o, which must be comparable, such as a pointer
+func (ms *MemoryStore) RemoveObserver(o PoolObserver) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	for i, observer := range ms.observers {
+		if observer == o {
+			ms.observers = append(ms.observers[:i:i], ms.observers[i+1:]...)
+			return
+		}
+	}
+}
+
+// notifyObservers calls every registered observer with pool. The caller must not hold
+// the mutex, so observers may read the store.
+func (ms *MemoryStore) notifyObservers(pool *types.Pool) {
+	ms.mutex.RLock()
+	observers := ms.observers
+	ms.mutex.RUnlock()
+
+	for _, observer := range observers {
+		notifyObserver(observer, pool)
+	}
+}
+
+func notifyObserver(observer PoolObserver, pool *types.Pool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Pool observer %T panicked on pool %s: %v", observer, pool.Address, r)
+		}
+	}()
+	observer.OnPoolStored(pool)
+}
+
+// recordReserves adds pool's reserves to the history if they differ from existing,
+// which is nil for a newly stored pool, and their reserve0 change to the volume.
+// The caller holds the mutex.
+func (ms *MemoryStore) recordReserves(existing, pool *types.Pool) {
+	if existing != nil && reservesEqual(existing.Reserve0, pool.Reserve0) && reservesEqual(existing.Reserve1, pool.Reserve1) {
+		return
+	}
+	if ms.history != nil {
+		ms.history.RecordReserves(pool.Address, pool.Reserve0, pool.Reserve1, pool.ReserveUpdatedAt)
+	}
+	// A reserve change approximates the swap volume, like a Sync event
+	if ms.volumes != nil && existing != nil {
+		ms.volumes.RecordReserveChange(pool.Address, existing.Reserve0, pool.Reserve0, pool.ReserveUpdatedAt)
+	}
+}
+
+// tracksVolume reports whether a volume accumulator is set
+func (ms *MemoryStore) tracksVolume() bool {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	return ms.volumes != nil
+}
+
+// recordVolume adds amount to the pool's volume for a reserve change the store did not
+// see, such as one applied to a pool it does not hold yet
+func (ms *MemoryStore) recordVolume(address string, amount *big.Int, timestamp time.Time) {
+	ms.mutex.RLock()
+	volumes := ms.volumes
+	ms.mutex.RUnlock()
+	if volumes != nil && amount != nil {
+		volumes.RecordSwap(address, new(big.Int).Abs(amount), timestamp)
+	}
+}
+
+func reservesEqual(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
+	return a.Cmp(b) == 0
+}
+
+// poolKey namespaces a pool address by chain ID
+func poolKey(chainID int64, address string) string {
+	return fmt.Sprintf("%d:%s", chainID, address)
 }
 
+// StorePool stores pool and then notifies the registered observers
 func (ms *MemoryStore) StorePool(ctx context.Context, pool *types.Pool) error {
+	if err := ms.storePool(pool); err != nil {
+		return err
+	}
+	ms.notifyObservers(pool)
+	return nil
+}
+
+func (ms *MemoryStore) storePool(pool *types.Pool) error {
 	ms.mutex.Lock()
 	defer ms.mutex.Unlock()
 
+	if pool.ChainID == 0 {
+		pool.ChainID = ms.chainID
+	}
+
 	// Ensure reserves are properly initialized
 	if pool.Reserve0 == nil {
 		pool.Reserve0 = big.NewInt(0)
@@ -36,12 +189,41 @@ func (ms *MemoryStore) StorePool(ctx context.Context, pool *types.Pool) error {
 		pool.Reserve1 = big.NewInt(0)
 	}
 
+	if err := validation.Validate(pool, ms.validators); err != nil {
+		return err
+	}
+
+	// Pools arriving without a reserve timestamp carry freshly read reserves
+	if pool.ReserveUpdatedAt.IsZero() {
+		pool.ReserveUpdatedAt = time.Now()
+	}
+
 	// Ensure token addresses are lowercase for consistency
 	pool.Token0.Address = strings.ToLower(pool.Token0.Address)
 	pool.Token1.Address = strings.ToLower(pool.Token1.Address)
 
-	// Store pool
-	ms.pools[pool.Address] = pool
+	// Store pool, keeping the creation time of pools that are already stored
+	key := poolKey(pool.ChainID, pool.Address)
+	existing, exists := ms.pools[key]
+	if exists {
+		pool.CreatedAt = existing.CreatedAt
+	} else if pool.CreatedAt.IsZero() {
+		pool.CreatedAt = time.Now()
+	}
+	ms.pools[key] = pool
+	ms.recordReserves(existing, pool)
+	if !exists && pool.ChainID == ms.chainID {
+		ms.poolCount.Add(1)
+	}
+
+	// A re-stored pool is already indexed unless its exchange changed
+	exchange := strings.ToLower(pool.Exchange)
+	if !exists || strings.ToLower(existing.Exchange) != exchange {
+		if exists {
+			ms.unindexExchange(strings.ToLower(existing.Exchange), key)
+		}
+		ms.byExchange[exchange] = append(ms.byExchange[exchange], key)
+	}
 
 	// Create token pair index with normalized addresses
 	token0 := pool.Token0.Address
@@ -59,26 +241,180 @@ func (ms *MemoryStore) StorePool(ctx context.Context, pool *types.Pool) error {
 	}
 
 	// Add pool addresses to both directions
-	ms.tokenPairs[token0][token1] = append(ms.tokenPairs[token0][token1], pool.Address)
-	ms.tokenPairs[token1][token0] = append(ms.tokenPairs[token1][token0], pool.Address)
+	ms.tokenPairs[token0][token1] = append(ms.tokenPairs[token0][token1], key)
+	ms.tokenPairs[token1][token0] = append(ms.tokenPairs[token1][token0], key)
 
 	log.Printf("Created index: %s<->%s -> %v", token0, token1, ms.tokenPairs[token0][token1])
 
 	return nil
 }
 
+// DeletePool removes a pool, its index entries and its reserve history
+func (ms *MemoryStore) DeletePool(ctx context.Context, address string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	key := poolKey(ms.chainID, address)
+	pool, exists := ms.pools[key]
+	if !exists {
+		return ErrPoolNotFound
+	}
+	delete(ms.pools, key)
+	ms.poolCount.Add(-1)
+
+	ms.unindexExchange(strings.ToLower(pool.Exchange), key)
+	token0, token1 := pool.Token0.Address, pool.Token1.Address
+	ms.tokenPairs[token0][token1] = removeKey(ms.tokenPairs[token0][token1], key)
+	ms.tokenPairs[token1][token0] = removeKey(ms.tokenPairs[token1][token0], key)
+	if ms.history != nil {
+		ms.history.Remove(pool.Address)
+	}
+	return nil
+}
+
+// ClearPools removes every pool and its index entries. Tokens are kept, since GetToken
+// answers unknown tokens with a placeholder rather than a miss.
+func (ms *MemoryStore) ClearPools() {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.pools = make(map[string]*types.Pool)
+	ms.tokenPairs = make(map[string]map[string][]string)
+	ms.byExchange = make(map[string][]string)
+	ms.poolCount.Store(0)
+}
+
+// unindexExchange drops key from the exchange index. The caller holds the mutex.
+func (ms *MemoryStore) unindexExchange(exchange, key string) {
+	if keys := removeKey(ms.byExchange[exchange], key); len(keys) > 0 {
+		ms.byExchange[exchange] = keys
+	} else {
+		delete(ms.byExchange, exchange)
+	}
+}
+
+// removeKey returns keys without any occurrence of key, reusing its backing array
+func removeKey(keys []string, key string) []string {
+	kept := keys[:0]
+	for _, k := range keys {
+		if k != key {
+			kept = append(kept, k)
+		}
+	}
+	return kept
+}
+
 func (ms *MemoryStore) GetPool(ctx context.Context, address string) (*types.Pool, error) {
 	ms.mutex.RLock()
 	defer ms.mutex.RUnlock()
 
-	pool, exists := ms.pools[address]
+	pool, exists := ms.pools[poolKey(ms.chainID, address)]
 	if !exists {
-		return nil, fmt.Errorf("pool not found")
+		return nil, ErrPoolNotFound
 	}
 
 	return pool, nil
 }
 
+// UpdatePool replaces the stored pool with an updated copy, so pools already handed
+// out to readers are never modified
+func (ms *MemoryStore) UpdatePool(ctx context.Context, address string, update *types.PoolUpdate) (*types.Pool, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	key := poolKey(ms.chainID, address)
+	existing, exists := ms.pools[key]
+	if !exists {
+		return nil, ErrPoolNotFound
+	}
+
+	pool := *existing
+	update.Apply(&pool)
+	ms.pools[key] = &pool
+	ms.recordReserves(existing, &pool)
+
+	return &pool, nil
+}
+
+// BulkUpdateReserves applies every update under a single write lock, so readers see
+// either none or all of them
+func (ms *MemoryStore) BulkUpdateReserves(ctx context.Context, updates []types.ReserveUpdate) error {
+	parsed, err := parseReserveUpdates(updates)
+	if err != nil {
+		return err
+	}
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	var notFound []string
+	for i, update := range updates {
+		key := poolKey(ms.chainID, update.Address)
+		existing, exists := ms.pools[key]
+		if !exists {
+			notFound = append(notFound, update.Address)
+			continue
+		}
+
+		pool := *existing
+		parsed[i].Apply(&pool)
+		ms.pools[key] = &pool
+		ms.recordReserves(existing, &pool)
+	}
+
+	if len(notFound) > 0 {
+		return &PoolsNotFoundError{Addresses: notFound}
+	}
+	return nil
+}
+
+// ApplyReserveDelta computes the new reserves from a snapshot without holding the lock and
+// swaps them in only if the pool was not replaced meanwhile, retrying otherwise
+func (ms *MemoryStore) ApplyReserveDelta(ctx context.Context, address string, delta0, delta1 *big.Int) error {
+	key := poolKey(ms.chainID, address)
+	for {
+		ms.mutex.RLock()
+		existing, exists := ms.pools[key]
+		ms.mutex.RUnlock()
+		if !exists {
+			return ErrPoolNotFound
+		}
+
+		pool := *existing
+		pool.Reserve0 = addReserveDelta(existing.Reserve0, delta0)
+		pool.Reserve1 = addReserveDelta(existing.Reserve1, delta1)
+		pool.ReserveUpdatedAt = time.Now()
+
+		ms.mutex.Lock()
+		if ms.pools[key] == existing {
+			ms.pools[key] = &pool
+			ms.recordReserves(existing, &pool)
+			ms.mutex.Unlock()
+			return nil
+		}
+		ms.mutex.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// addReserveDelta returns reserve + delta, clamped at zero. A nil reserve or delta is zero.
+func addReserveDelta(reserve, delta *big.Int) *big.Int {
+	result := new(big.Int)
+	if reserve != nil {
+		result.Set(reserve)
+	}
+	if delta != nil {
+		result.Add(result, delta)
+	}
+	if result.Sign() < 0 {
+		result.SetInt64(0)
+	}
+	return result
+}
+
 func (ms *MemoryStore) GetPoolsByTokens(ctx context.Context, tokenA, tokenB string) ([]*types.Pool, error) {
 	ms.mutex.RLock()
 	defer ms.mutex.RUnlock()
@@ -92,8 +428,8 @@ func (ms *MemoryStore) GetPoolsByTokens(ctx context.Context, tokenA, tokenB stri
 
 	if pairs, ok := ms.tokenPairs[tokenA]; ok {
 		if poolAddrs, ok := pairs[tokenB]; ok {
-			for _, addr := range poolAddrs {
-				if pool, exists := ms.pools[addr]; exists {
+			for _, key := range poolAddrs {
+				if pool, exists := ms.pools[key]; exists && pool.ChainID == ms.chainID {
 					pools = append(pools, pool)
 				}
 			}
@@ -105,26 +441,112 @@ func (ms *MemoryStore) GetPoolsByTokens(ctx context.Context, tokenA, tokenB stri
 	return pools, nil
 }
 
+// GetPoolsBySymbol returns the pools between the tokens with symbols symbolA and
+// symbolB, in either order, matching symbols ignoring case. Symbols are not indexed, so
+// it scans every pool.
+func (ms *MemoryStore) GetPoolsBySymbol(ctx context.Context, symbolA, symbolB string) ([]*types.Pool, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	var pools []*types.Pool
+	for _, pool := range ms.pools {
+		if pool.ChainID != ms.chainID {
+			continue
+		}
+		if (strings.EqualFold(pool.Token0.Symbol, symbolA) && strings.EqualFold(pool.Token1.Symbol, symbolB)) ||
+			(strings.EqualFold(pool.Token0.Symbol, symbolB) && strings.EqualFold(pool.Token1.Symbol, symbolA)) {
+			pools = append(pools, pool)
+		}
+	}
+
+	return pools, nil
+}
+
+// Count returns the number of pools GetAllPools would return, without taking the lock
+func (ms *MemoryStore) Count() int64 {
+	return ms.poolCount.Load()
+}
+
 func (ms *MemoryStore) GetAllPools(ctx context.Context) ([]*types.Pool, error) {
 	ms.mutex.RLock()
 	defer ms.mutex.RUnlock()
 
 	var pools []*types.Pool
 	for _, pool := range ms.pools {
-		pools = append(pools, pool)
+		if pool.ChainID == ms.chainID {
+			pools = append(pools, pool)
+		}
+	}
+
+	return pools, nil
+}
+
+// GetPoolsByExchange returns the pools of exchange, whose name is matched ignoring case
+func (ms *MemoryStore) GetPoolsByExchange(ctx context.Context, exchange string) ([]*types.Pool, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	var pools []*types.Pool
+	for _, key := range ms.byExchange[strings.ToLower(exchange)] {
+		if pool, exists := ms.pools[key]; exists && pool.ChainID == ms.chainID {
+			pools = append(pools, pool)
+		}
+	}
+
+	return pools, nil
+}
+
+// GetPoolsCreatedAfter returns the pools first stored after since
+func (ms *MemoryStore) GetPoolsCreatedAfter(ctx context.Context, since time.Time) ([]*types.Pool, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	var pools []*types.Pool
+	for _, pool := range ms.pools {
+		if pool.ChainID == ms.chainID && pool.CreatedAt.After(since) {
+			pools = append(pools, pool)
+		}
 	}
 
 	return pools, nil
 }
 
 func (ms *MemoryStore) StoreToken(ctx context.Context, token *types.Token) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.tokens[strings.ToLower(token.Address)] = token
 	return nil
 }
 
 func (ms *MemoryStore) GetToken(ctx context.Context, address string) (*types.Token, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	if token, exists := ms.tokens[strings.ToLower(address)]; exists {
+		return token, nil
+	}
+
+	// Return default token info
 	return &types.Token{
 		Address:  address,
 		Symbol:   "UNKNOWN",
 		Decimals: 18,
 	}, nil
 }
+
+// StorePoolFee records the detected fee of a pool that may not be stored yet, in the
+// units of Pool.Fee for the pool's version
+func (ms *MemoryStore) StorePoolFee(address string, fee int) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.fees[poolKey(ms.chainID, strings.ToLower(address))] = fee
+}
+
+// GetPoolFee returns a fee recorded by StorePoolFee
+func (ms *MemoryStore) GetPoolFee(address string) (int, bool) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	fee, ok := ms.fees[poolKey(ms.chainID, strings.ToLower(address))]
+	return fee, ok
+}
@@ -5,9 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/big"
+	"strconv"
+	"strings"
 	"time"
 
 	"dex-aggregator/internal/types"
+	"dex-aggregator/internal/validation"
 
 	"github.com/go-redis/redis/v8"
 )
@@ -17,30 +21,216 @@ type Store interface {
 	GetPool(ctx context.Context, address string) (*types.Pool, error)
 	GetPoolsByTokens(ctx context.Context, tokenA, tokenB string) ([]*types.Pool, error)
 	GetAllPools(ctx context.Context) ([]*types.Pool, error)
+	// GetPoolsByExchange returns the pools of exchange, whose name is matched ignoring case
+	GetPoolsByExchange(ctx context.Context, exchange string) ([]*types.Pool, error)
+	// GetPoolsCreatedAfter returns the pools first stored after since
+	GetPoolsCreatedAfter(ctx context.Context, since time.Time) ([]*types.Pool, error)
+	// UpdatePool applies a partial update to a stored pool and returns the result
+	UpdatePool(ctx context.Context, address string, update *types.PoolUpdate) (*types.Pool, error)
+	// ApplyReserveDelta atomically adds signed deltas to a pool's reserves, clamping at zero
+	ApplyReserveDelta(ctx context.Context, address string, delta0, delta1 *big.Int) error
+	// BulkUpdateReserves applies every update to its stored pool. Updates of pools that are
+	// not stored are skipped and reported by a *PoolsNotFoundError.
+	BulkUpdateReserves(ctx context.Context, updates []types.ReserveUpdate) error
 	StoreToken(ctx context.Context, token *types.Token) error
 	GetToken(ctx context.Context, address string) (*types.Token, error)
 }
 
+// BulkStorePool stores every pool in store, continuing past failures. The returned
+// errors line up with pools and are nil for the pools that were stored.
+func BulkStorePool(ctx context.Context, store Store, pools []*types.Pool) []error {
+	errs := make([]error, len(pools))
+	for i, pool := range pools {
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			continue
+		}
+		errs[i] = store.StorePool(ctx, pool)
+	}
+	return errs
+}
+
+// PoolsNotFoundError is returned by BulkUpdateReserves when some updates name pools that
+// are not stored. The updates of the stored pools were applied.
+type PoolsNotFoundError struct {
+	Addresses []string
+}
+
+func (e *PoolsNotFoundError) Error() string {
+	return fmt.Sprintf("%d pools not found: %s", len(e.Addresses), strings.Join(e.Addresses, ", "))
+}
+
+// parseReserveUpdates parses every update before any is applied, so a malformed update
+// rejects the whole batch
+func parseReserveUpdates(updates []types.ReserveUpdate) ([]*types.PoolUpdate, error) {
+	parsed := make([]*types.PoolUpdate, len(updates))
+	for i := range updates {
+		update, err := updates[i].PoolUpdate()
+		if err != nil {
+			return nil, err
+		}
+		parsed[i] = update
+	}
+	return parsed, nil
+}
+
 type RedisStore struct {
-	client *redis.Client
-	prefix string
+	client  *redis.Client
+	prefix  string
+	chainID int64
+
+	maxRetries     int
+	retryBaseDelay time.Duration
+
+	validators []validation.PoolValidator // checked by StorePool
 }
 
-func NewRedisStore(addr, password string) *RedisStore {
+const (
+	defaultMaxRetries     = 3
+	defaultRetryBaseDelay = 50 * time.Millisecond
+	// retryBackoffFactor multiplies the delay after each failed attempt: 50ms, 200ms, 800ms
+	retryBackoffFactor = 4
+)
+
+// NewRedisStore creates a store for chainID whose StorePool rejects pools that fail any of validators
+func NewRedisStore(addr, password string, chainID int64, validators ...validation.PoolValidator) *RedisStore {
 	client := redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
 		DB:       0,
+		// Retries are handled by retryWithBackoff so they apply to whole operations
+		MaxRetries: -1,
 	})
 
 	return &RedisStore{
-		client: client,
-		prefix: "dex:",
+		client:         client,
+		prefix:         "dex:",
+		chainID:        chainID,
+		maxRetries:     defaultMaxRetries,
+		retryBaseDelay: defaultRetryBaseDelay,
+		validators:     validators,
 	}
 }
 
+// SetRetryPolicy sets how many times a failed Redis operation is retried and the delay
+// before the first retry. Non-positive values keep the current setting.
+func (rs *RedisStore) SetRetryPolicy(maxRetries int, baseDelay time.Duration) {
+	if maxRetries > 0 {
+		rs.maxRetries = maxRetries
+	}
+	if baseDelay > 0 {
+		rs.retryBaseDelay = baseDelay
+	}
+}
+
+// retry runs fn under the store's retry policy
+func (rs *RedisStore) retry(ctx context.Context, fn func() error) error {
+	return retryWithBackoff(ctx, rs.maxRetries+1, rs.retryBaseDelay, fn)
+}
+
+// retryWithBackoff calls fn up to maxAttempts times, multiplying the delay between
+// attempts by retryBackoffFactor. redis.Nil is a result rather than a failure and is
+// returned immediately. It gives up early when ctx is done or its deadline would
+// pass before the next attempt.
+func retryWithBackoff(ctx context.Context, maxAttempts int, baseDelay time.Duration, fn func() error) error {
+	delay := baseDelay
+	var err error
+
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || err == redis.Nil || attempt >= maxAttempts {
+			return err
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		log.Printf("Redis operation failed (attempt %d/%d), retrying in %v: %v", attempt, maxAttempts, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= retryBackoffFactor
+	}
+}
+
+// Ping checks connectivity to Redis. It is not retried so health checks see failures immediately.
+func (rs *RedisStore) Ping(ctx context.Context) error {
+	return rs.client.Ping(ctx).Err()
+}
+
+// ConnectionPoolStats returns the counters and connection counts of the client's connection pool
+func (rs *RedisStore) ConnectionPoolStats() *redis.PoolStats {
+	return rs.client.PoolStats()
+}
+
+// chainPrefix returns the key namespace for a chain, e.g. "dex:1:"
+func (rs *RedisStore) chainPrefix(chainID int64) string {
+	return fmt.Sprintf("%s%d:", rs.prefix, chainID)
+}
+
+func (rs *RedisStore) poolKey(chainID int64, address string) string {
+	return fmt.Sprintf("%spool:%s", rs.chainPrefix(chainID), address)
+}
+
+// tokenPairKey is the set of addresses of the pools between lower and higher, the pair's
+// tokens in the order of Pool.SortedTokens
+func (rs *RedisStore) tokenPairKey(chainID int64, lower, higher string) string {
+	return fmt.Sprintf("%stoken_pair:%s:%s", rs.chainPrefix(chainID), strings.ToLower(lower), strings.ToLower(higher))
+}
+
+func (rs *RedisStore) allPoolsKey(chainID int64) string {
+	return fmt.Sprintf("%sall_pools", rs.chainPrefix(chainID))
+}
+
+// exchangePoolsKey is the set of addresses of the pools of exchange
+func (rs *RedisStore) exchangePoolsKey(chainID int64, exchange string) string {
+	return fmt.Sprintf("%sexchange_pools:%s", rs.chainPrefix(chainID), strings.ToLower(exchange))
+}
+
+// poolsByCreationKey is a sorted set of pool addresses scored by creation Unix time
+func (rs *RedisStore) poolsByCreationKey(chainID int64) string {
+	return fmt.Sprintf("%spools_by_creation", rs.chainPrefix(chainID))
+}
+
+func (rs *RedisStore) tokenKey(chainID int64, address string) string {
+	return fmt.Sprintf("%stoken:%s", rs.chainPrefix(chainID), address)
+}
+
 func (rs *RedisStore) StorePool(ctx context.Context, pool *types.Pool) error {
-	key := fmt.Sprintf("%spool:%s", rs.prefix, pool.Address)
+	if err := validation.Validate(pool, rs.validators); err != nil {
+		return err
+	}
+	if pool.ChainID == 0 {
+		pool.ChainID = rs.chainID
+	}
+	// Pools arriving without a reserve timestamp carry freshly read reserves
+	if pool.ReserveUpdatedAt.IsZero() {
+		pool.ReserveUpdatedAt = time.Now()
+	}
+	key := rs.poolKey(pool.ChainID, pool.Address)
+
+	// Keep the creation time of pools that are already stored
+	creationKey := rs.poolsByCreationKey(pool.ChainID)
+	var score float64
+	err := rs.retry(ctx, func() (err error) {
+		score, err = rs.client.ZScore(ctx, creationKey, pool.Address).Result()
+		return err
+	})
+	isNew := err == redis.Nil
+	if err != nil && !isNew {
+		return err
+	}
+	if isNew {
+		pool.CreatedAt = time.Now()
+	} else if pool.CreatedAt.Unix() != int64(score) {
+		pool.CreatedAt = time.Unix(int64(score), 0)
+	}
 
 	data, err := json.Marshal(pool)
 	if err != nil {
@@ -48,37 +238,66 @@ func (rs *RedisStore) StorePool(ctx context.Context, pool *types.Pool) error {
 	}
 
 	// Store pool information
-	err = rs.client.Set(ctx, key, data, 24*time.Hour).Err()
+	err = rs.retry(ctx, func() error {
+		return rs.client.Set(ctx, key, data, 24*time.Hour).Err()
+	})
 	if err != nil {
 		return err
 	}
 
 	// Create token pair index
-	tokenPairKey := fmt.Sprintf("%stoken_pair:%s:%s",
-		rs.prefix, pool.Token0.Address, pool.Token1.Address)
-	err = rs.client.SAdd(ctx, tokenPairKey, pool.Address).Err()
+	lower, higher := pool.SortedTokens()
+	tokenPairKey := rs.tokenPairKey(pool.ChainID, lower.Address, higher.Address)
+	err = rs.retry(ctx, func() error {
+		return rs.client.SAdd(ctx, tokenPairKey, pool.Address).Err()
+	})
 	if err != nil {
 		return err
 	}
 	rs.client.Expire(ctx, tokenPairKey, 24*time.Hour)
 
 	// Add to all pools set
-	allPoolsKey := fmt.Sprintf("%sall_pools", rs.prefix)
-	err = rs.client.SAdd(ctx, allPoolsKey, pool.Address).Err()
+	err = rs.retry(ctx, func() error {
+		return rs.client.SAdd(ctx, rs.allPoolsKey(pool.ChainID), pool.Address).Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	// Add to the exchange's set. Sets of exchanges a pool has left are pruned on read.
+	err = rs.retry(ctx, func() error {
+		return rs.client.SAdd(ctx, rs.exchangePoolsKey(pool.ChainID, pool.Exchange), pool.Address).Err()
+	})
 	if err != nil {
 		return err
 	}
 
+	if isNew {
+		err = rs.retry(ctx, func() error {
+			return rs.client.ZAddNX(ctx, creationKey, &redis.Z{
+				Score:  float64(pool.CreatedAt.Unix()),
+				Member: pool.Address,
+			}).Err()
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (rs *RedisStore) GetPool(ctx context.Context, address string) (*types.Pool, error) {
-	key := fmt.Sprintf("%spool:%s", rs.prefix, address)
+	key := rs.poolKey(rs.chainID, address)
 
-	data, err := rs.client.Get(ctx, key).Result()
+	var data string
+	err := rs.retry(ctx, func() (err error) {
+		data, err = rs.client.Get(ctx, key).Result()
+		return err
+	})
 	if err != nil {
 		if err == redis.Nil {
-			return nil, fmt.Errorf("pool not found: %s", address)
+			return nil, fmt.Errorf("%w: %s", ErrPoolNotFound, address)
 		}
 		return nil, err
 	}
@@ -91,10 +310,291 @@ func (rs *RedisStore) GetPool(ctx context.Context, address string) (*types.Pool,
 	return &pool, nil
 }
 
+// UpdatePool reads, updates and rewrites the pool. Concurrent updates of the same
+// pool are last-writer-wins.
+func (rs *RedisStore) UpdatePool(ctx context.Context, address string, update *types.PoolUpdate) (*types.Pool, error) {
+	pool, err := rs.GetPool(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	update.Apply(pool)
+	if err := rs.StorePool(ctx, pool); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// applyReserveDeltaScript adds signed decimal deltas (ARGV[1], ARGV[2]) to the reserve0
+// and reserve1 strings of the pool JSON at KEYS[1], clamping at zero and keeping the key's
+// TTL, and sets reserve_updated_at to ARGV[3]. Reserves can exceed Lua's number precision,
+// so the arithmetic is done on digits.
+var applyReserveDeltaScript = redis.NewScript(`
+local function compare(a, b)
+	if #a ~= #b then
+		return #a < #b and -1 or 1
+	end
+	if a == b then
+		return 0
+	end
+	return a < b and -1 or 1
+end
+
+local function add(a, b)
+	local result, carry = {}, 0
+	local i, j = #a, #b
+	while i > 0 or j > 0 or carry > 0 do
+		local sum = carry
+		if i > 0 then sum = sum + tonumber(a:sub(i, i)) end
+		if j > 0 then sum = sum + tonumber(b:sub(j, j)) end
+		table.insert(result, 1, tostring(sum % 10))
+		carry = math.floor(sum / 10)
+		i, j = i - 1, j - 1
+	end
+	return table.concat(result)
+end
+
+-- sub returns a - b for a > b
+local function sub(a, b)
+	local result, borrow = {}, 0
+	local i, j = #a, #b
+	while i > 0 do
+		local diff = tonumber(a:sub(i, i)) - borrow
+		if j > 0 then diff = diff - tonumber(b:sub(j, j)) end
+		if diff < 0 then
+			diff, borrow = diff + 10, 1
+		else
+			borrow = 0
+		end
+		table.insert(result, 1, tostring(diff))
+		i, j = i - 1, j - 1
+	end
+	return (table.concat(result):gsub("^0+", ""))
+end
+
+local function applyDelta(reserve, delta)
+	if delta:sub(1, 1) ~= "-" then
+		return add(reserve, delta)
+	end
+	delta = delta:sub(2)
+	if compare(reserve, delta) <= 0 then
+		return "0"
+	end
+	return sub(reserve, delta)
+end
+
+local data = redis.call("GET", KEYS[1])
+if not data then
+	return false
+end
+
+for index, field in ipairs({"reserve0", "reserve1"}) do
+	data = data:gsub('"' .. field .. '":"(%d+)"', function(reserve)
+		return '"' .. field .. '":"' .. applyDelta(reserve, ARGV[index]) .. '"'
+	end, 1)
+end
+data = data:gsub('"reserve_updated_at":"[^"]*"', function()
+	return '"reserve_updated_at":"' .. ARGV[3] .. '"'
+end, 1)
+
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl > 0 then
+	redis.call("SET", KEYS[1], data, "PX", ttl)
+else
+	redis.call("SET", KEYS[1], data)
+end
+return 1
+`)
+
+// ApplyReserveDelta updates the reserves in a Lua script so concurrent deltas are not
+// lost. It is not retried: a retry after a lost reply would apply the delta twice.
+func (rs *RedisStore) ApplyReserveDelta(ctx context.Context, address string, delta0, delta1 *big.Int) error {
+	err := applyReserveDeltaScript.Run(ctx, rs.client, []string{rs.poolKey(rs.chainID, address)},
+		deltaString(delta0), deltaString(delta1), time.Now().UTC().Format(time.RFC3339Nano)).Err()
+	if err == redis.Nil {
+		return fmt.Errorf("%w: %s", ErrPoolNotFound, address)
+	}
+	return err
+}
+
+// BulkUpdateReserves reads every pool in one pipeline and writes the updated pools back
+// in another, keeping their TTLs. Like UpdatePool, concurrent writes of the same pool are
+// last-writer-wins.
+func (rs *RedisStore) BulkUpdateReserves(ctx context.Context, updates []types.ReserveUpdate) error {
+	parsed, err := parseReserveUpdates(updates)
+	if err != nil {
+		return err
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	var getCmds []*redis.StringCmd
+	err = rs.retry(ctx, func() error {
+		pipe := rs.client.Pipeline()
+		getCmds = make([]*redis.StringCmd, len(updates))
+		for i, update := range updates {
+			getCmds[i] = pipe.Get(ctx, rs.poolKey(rs.chainID, update.Address))
+		}
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	var notFound []string
+	keys := make([]string, 0, len(updates))
+	values := make([][]byte, 0, len(updates))
+	for i, cmd := range getCmds {
+		data, err := cmd.Result()
+		if err == redis.Nil {
+			notFound = append(notFound, updates[i].Address)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var pool types.Pool
+		if err := json.Unmarshal([]byte(data), &pool); err != nil {
+			return fmt.Errorf("failed to unmarshal pool %s: %w", updates[i].Address, err)
+		}
+		parsed[i].Apply(&pool)
+		value, err := json.Marshal(&pool)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, rs.poolKey(rs.chainID, updates[i].Address))
+		values = append(values, value)
+	}
+
+	if len(keys) > 0 {
+		err = rs.retry(ctx, func() error {
+			pipe := rs.client.Pipeline()
+			for i, key := range keys {
+				pipe.Set(ctx, key, values[i], redis.KeepTTL)
+			}
+			_, err := pipe.Exec(ctx)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(notFound) > 0 {
+		return &PoolsNotFoundError{Addresses: notFound}
+	}
+	return nil
+}
+
+// deltaString formats a delta for applyReserveDeltaScript, treating nil as zero
+func deltaString(delta *big.Int) string {
+	if delta == nil {
+		return "0"
+	}
+	return delta.String()
+}
+
+// GetAllPools returns every pool stored for the chain. Pools whose keys have expired
+// are dropped from the pool and creation indexes as they are found.
 func (rs *RedisStore) GetAllPools(ctx context.Context) ([]*types.Pool, error) {
-	allPoolsKey := fmt.Sprintf("%sall_pools", rs.prefix)
+	poolAddrs, err := rs.allPoolAddresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(poolAddrs) == 0 {
+		return []*types.Pool{}, nil
+	}
+
+	pools, err := rs.bulkGetPools(ctx, poolAddrs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pools) < len(poolAddrs) {
+		found := make(map[string]bool, len(pools))
+		for _, pool := range pools {
+			found[pool.Address] = true
+		}
+		for _, address := range poolAddrs {
+			if !found[address] {
+				rs.unindexExpiredPool(ctx, address)
+			}
+		}
+	}
+	return pools, nil
+}
+
+// unindexExpiredPoolScript removes a pool from the all pools set and the creation
+// sorted set unless its key exists, so a pool stored concurrently keeps its entries
+var unindexExpiredPoolScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return 0
+end
+redis.call("SREM", KEYS[2], ARGV[1])
+redis.call("ZREM", KEYS[3], ARGV[1])
+return 1
+`)
+
+// unindexExpiredPool drops the index entries of a pool whose key has expired. Failures
+// are logged and left for the next GetAllPools.
+func (rs *RedisStore) unindexExpiredPool(ctx context.Context, address string) {
+	keys := []string{rs.poolKey(rs.chainID, address), rs.allPoolsKey(rs.chainID), rs.poolsByCreationKey(rs.chainID)}
+	if err := unindexExpiredPoolScript.Run(ctx, rs.client, keys, address).Err(); err != nil {
+		log.Printf("Failed to unindex expired pool %s: %v", address, err)
+	}
+}
+
+// allPoolAddresses returns the addresses of every pool stored for the chain
+func (rs *RedisStore) allPoolAddresses(ctx context.Context) ([]string, error) {
+	var poolAddrs []string
+	err := rs.retry(ctx, func() (err error) {
+		poolAddrs, err = rs.client.SMembers(ctx, rs.allPoolsKey(rs.chainID)).Result()
+		return err
+	})
+	return poolAddrs, err
+}
 
-	poolAddrs, err := rs.client.SMembers(ctx, allPoolsKey).Result()
+// GetPoolsByExchange reads the exchange's pool set and skips pools that have since been
+// stored under another exchange
+func (rs *RedisStore) GetPoolsByExchange(ctx context.Context, exchange string) ([]*types.Pool, error) {
+	var poolAddrs []string
+	err := rs.retry(ctx, func() (err error) {
+		poolAddrs, err = rs.client.SMembers(ctx, rs.exchangePoolsKey(rs.chainID, exchange)).Result()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pools, err := rs.bulkGetPools(ctx, poolAddrs)
+	if err != nil {
+		return nil, err
+	}
+
+	matching := make([]*types.Pool, 0, len(pools))
+	for _, pool := range pools {
+		if strings.EqualFold(pool.Exchange, exchange) {
+			matching = append(matching, pool)
+		}
+	}
+	return matching, nil
+}
+
+// GetPoolsCreatedAfter reads candidate addresses from the creation sorted set, whose
+// scores have second precision, and filters the pools on their exact creation time
+func (rs *RedisStore) GetPoolsCreatedAfter(ctx context.Context, since time.Time) ([]*types.Pool, error) {
+	var poolAddrs []string
+	err := rs.retry(ctx, func() (err error) {
+		poolAddrs, err = rs.client.ZRangeByScore(ctx, rs.poolsByCreationKey(rs.chainID), &redis.ZRangeBy{
+			Min: strconv.FormatInt(since.Unix(), 10),
+			Max: "+inf",
+		}).Result()
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -103,33 +603,56 @@ func (rs *RedisStore) GetAllPools(ctx context.Context) ([]*types.Pool, error) {
 		return []*types.Pool{}, nil
 	}
 
-	// 1. Create a Pipeline
-	pipe := rs.client.Pipeline()
+	pools, err := rs.bulkGetPools(ctx, poolAddrs)
+	if err != nil {
+		return nil, err
+	}
 
-	// 2. Add all Get commands to the Pipeline
-	cmds := make(map[string]*redis.StringCmd, len(poolAddrs))
-	for _, addr := range poolAddrs {
-		key := fmt.Sprintf("%spool:%s", rs.prefix, addr)
-		cmds[addr] = pipe.Get(ctx, key)
+	created := make([]*types.Pool, 0, len(pools))
+	for _, pool := range pools {
+		if pool.CreatedAt.After(since) {
+			created = append(created, pool)
+		}
 	}
+	return created, nil
+}
+
+// bulkGetPools fetches pools by address in a single pipelined round-trip.
+// Missing or undecodable pools are skipped.
+func (rs *RedisStore) bulkGetPools(ctx context.Context, addrs []string) ([]*types.Pool, error) {
+	if len(addrs) == 0 {
+		return []*types.Pool{}, nil
+	}
+
+	var cmds []*redis.StringCmd
+	err := rs.retry(ctx, func() error {
+		// 1. Create a Pipeline
+		pipe := rs.client.Pipeline()
+
+		// 2. Add all Get commands to the Pipeline
+		cmds = make([]*redis.StringCmd, len(addrs))
+		for i, addr := range addrs {
+			cmds[i] = pipe.Get(ctx, rs.poolKey(rs.chainID, addr))
+		}
 
-	// 3. Execute all commands at once
-	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		// 3. Execute all commands at once
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	if err != nil && err != redis.Nil {
 		// Even if some keys don't exist (redis.Nil), it shouldn't block the whole operation
 		// Only return on serious errors like connection issues
-		if err != redis.Nil {
-			log.Printf("Redis pipeline Exec error: %v", err)
-			return nil, err
-		}
+		log.Printf("Redis pipeline Exec error: %v", err)
+		return nil, err
 	}
 
 	// 4. Process results
-	var pools []*types.Pool
-	for addr, cmd := range cmds {
+	pools := make([]*types.Pool, 0, len(addrs))
+	for i, cmd := range cmds {
 		data, err := cmd.Result()
 		if err != nil {
 			if err != redis.Nil {
-				log.Printf("Failed to get pool %s from pipeline: %v", addr, err)
+				log.Printf("Failed to get pool %s from pipeline: %v", addrs[i], err)
 			}
 			// If key doesn't exist or fails to fetch, skip it
 			continue
@@ -137,7 +660,7 @@ func (rs *RedisStore) GetAllPools(ctx context.Context) ([]*types.Pool, error) {
 
 		var pool types.Pool
 		if err := json.Unmarshal([]byte(data), &pool); err != nil {
-			log.Printf("Failed to unmarshal pool %s: %v", addr, err)
+			log.Printf("Failed to unmarshal pool %s: %v", addrs[i], err)
 			continue
 		}
 		pools = append(pools, &pool)
@@ -147,20 +670,26 @@ func (rs *RedisStore) GetAllPools(ctx context.Context) ([]*types.Pool, error) {
 }
 
 func (rs *RedisStore) StoreToken(ctx context.Context, token *types.Token) error {
-	key := fmt.Sprintf("%stoken:%s", rs.prefix, token.Address)
+	key := rs.tokenKey(rs.chainID, token.Address)
 
 	data, err := json.Marshal(token)
 	if err != nil {
 		return err
 	}
 
-	return rs.client.Set(ctx, key, data, 24*time.Hour).Err()
+	return rs.retry(ctx, func() error {
+		return rs.client.Set(ctx, key, data, 24*time.Hour).Err()
+	})
 }
 
 func (rs *RedisStore) GetToken(ctx context.Context, address string) (*types.Token, error) {
-	key := fmt.Sprintf("%stoken:%s", rs.prefix, address)
+	key := rs.tokenKey(rs.chainID, address)
 
-	data, err := rs.client.Get(ctx, key).Result()
+	var data string
+	err := rs.retry(ctx, func() (err error) {
+		data, err = rs.client.Get(ctx, key).Result()
+		return err
+	})
 	if err != nil {
 		if err == redis.Nil {
 			// Return default token info
@@ -182,27 +711,20 @@ func (rs *RedisStore) GetToken(ctx context.Context, address string) (*types.Toke
 }
 
 func (rs *RedisStore) GetPoolsByTokens(ctx context.Context, tokenA, tokenB string) ([]*types.Pool, error) {
-	// Try both orderings
-	keys := []string{
-		fmt.Sprintf("%stoken_pair:%s:%s", rs.prefix, tokenA, tokenB),
-		fmt.Sprintf("%stoken_pair:%s:%s", rs.prefix, tokenB, tokenA),
+	tokenA, tokenB = strings.ToLower(tokenA), strings.ToLower(tokenB)
+	if tokenB < tokenA {
+		tokenA, tokenB = tokenB, tokenA
 	}
+	key := rs.tokenPairKey(rs.chainID, tokenA, tokenB)
 
 	var poolAddrs []string
-	for _, key := range keys {
-		addrs, err := rs.client.SMembers(ctx, key).Result()
-		if err == nil && len(addrs) > 0 {
-			poolAddrs = append(poolAddrs, addrs...)
-		}
-	}
-
-	var pools []*types.Pool
-	for _, addr := range poolAddrs {
-		pool, err := rs.GetPool(ctx, addr)
-		if err == nil && pool != nil {
-			pools = append(pools, pool)
-		}
+	err := rs.retry(ctx, func() (err error) {
+		poolAddrs, err = rs.client.SMembers(ctx, key).Result()
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return pools, nil
+	return rs.bulkGetPools(ctx, poolAddrs)
 }
@@ -2,12 +2,21 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"dex-aggregator/internal/history"
 	"dex-aggregator/internal/types"
+	"dex-aggregator/internal/validation"
+	"dex-aggregator/internal/volume"
 )
 
 // TwoLevelCache provides caching with both memory and Redis layers
@@ -17,6 +26,38 @@ type TwoLevelCache struct {
 	localTTL   time.Duration
 	mutex      sync.RWMutex
 	stats      *CacheStats
+	validators []validation.PoolValidator
+
+	// bgCtx outlives individual requests and bounds background cache warming
+	bgCtx    context.Context
+	bgCancel context.CancelFunc
+
+	// warmingComplete is set once WarmLocalCacheFromRedis has loaded every pool
+	warmingComplete atomic.Bool
+	warmProgress    chan<- WarmProgress
+
+	// writeBuffer holds the lowercase addresses of pools stored in the last
+	// WriteBufferTTL, mapped to when they were stored. GetPool reads them from Redis, as
+	// a background warm with an older snapshot may have replaced or evicted the local copy.
+	writeBuffer     sync.Map
+	lastBufferSweep atomic.Int64 // Unix nanoseconds of the last sweep of expired entries
+}
+
+// WriteBufferTTL is how long after StorePool GetPool reads the pool from Redis rather
+// than the local cache, so a caller always sees its own write
+const WriteBufferTTL = 500 * time.Millisecond
+
+// DefaultWarmBatchSize is the number of pools WarmLocalCacheFromRedis loads per batch
+// when given a non-positive batch size
+const DefaultWarmBatchSize = 100
+
+// warmBatchDelay spaces out warming batches so startup does not flood Redis
+var warmBatchDelay = 10 * time.Millisecond
+
+// WarmProgress reports how many pools WarmLocalCacheFromRedis has loaded so far
+type WarmProgress struct {
+	Loaded int
+	Total  int
 }
 
 // CacheStats tracks cache performance metrics
@@ -25,20 +66,128 @@ type CacheStats struct {
 	LocalMisses int64
 	RedisHits   int64
 	RedisMisses int64
-	mutex       sync.RWMutex
+	// FallbackHits counts GetAllPools calls served from the local cache while Redis failed
+	FallbackHits int64
+
+	// Redis connection pool counters, read from the client by GetStats: a hit reused an
+	// idle connection, a miss dialled a new one and a timeout waited for one in vain
+	RedisConnPoolHits     int64
+	RedisConnPoolMisses   int64
+	RedisConnPoolTimeouts int64
+
+	// L1Latencies and L2Latencies hold the durations in nanoseconds of the most recent
+	// GetPool lookups in the local cache and Redis, overwriting the oldest once full
+	L1Latencies []int64
+	L2Latencies []int64
+	l1Next      int
+	l2Next      int
+
+	mutex sync.RWMutex
+}
+
+// maxLatencySamples bounds the lookup durations kept per cache level
+const maxLatencySamples = 1000
+
+// recordLatency adds a sample to samples, replacing the sample at *next once full.
+// The caller holds the stats mutex.
+func recordLatency(samples *[]int64, next *int, d time.Duration) {
+	if len(*samples) < maxLatencySamples {
+		*samples = append(*samples, int64(d))
+		return
+	}
+	(*samples)[*next] = int64(d)
+	*next = (*next + 1) % maxLatencySamples
+}
+
+// P50LatencyNs returns the median local cache and Redis lookup latencies
+func (cs *CacheStats) P50LatencyNs() (l1, l2 int64) {
+	return cs.latencyPercentile(50)
 }
 
-func NewTwoLevelCache(redisAddr, redisPassword string, localTTL time.Duration) *TwoLevelCache {
+// P95LatencyNs returns the 95th percentile local cache and Redis lookup latencies
+func (cs *CacheStats) P95LatencyNs() (l1, l2 int64) {
+	return cs.latencyPercentile(95)
+}
+
+// P99LatencyNs returns the 99th percentile local cache and Redis lookup latencies
+func (cs *CacheStats) P99LatencyNs() (l1, l2 int64) {
+	return cs.latencyPercentile(99)
+}
+
+func (cs *CacheStats) latencyPercentile(p float64) (l1, l2 int64) {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+	return percentile(cs.L1Latencies, p), percentile(cs.L2Latencies, p)
+}
+
+// percentile returns the nearest-rank p-th percentile of samples, or 0 without samples
+func percentile(samples []int64, p float64) int64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// NewTwoLevelCache creates the cache layers for chainID, both validating stored pools with validators
+func NewTwoLevelCache(redisAddr, redisPassword string, chainID int64, localTTL time.Duration, validators ...validation.PoolValidator) *TwoLevelCache {
+	bgCtx, bgCancel := context.WithCancel(context.Background())
 	return &TwoLevelCache{
-		localCache: NewMemoryStore(),
-		redisCache: NewRedisStore(redisAddr, redisPassword),
+		localCache: NewMemoryStoreWithChain(chainID, validators...),
+		redisCache: NewRedisStore(redisAddr, redisPassword, chainID, validators...),
 		localTTL:   localTTL,
 		stats:      &CacheStats{},
+		validators: validators,
+		bgCtx:      bgCtx,
+		bgCancel:   bgCancel,
 	}
 }
 
+// Close stops background cache warming
+func (tlc *TwoLevelCache) Close() {
+	tlc.bgCancel()
+}
+
+// SetRetryPolicy configures retries of failed Redis operations
+func (tlc *TwoLevelCache) SetRetryPolicy(maxRetries int, baseDelay time.Duration) {
+	tlc.redisCache.SetRetryPolicy(maxRetries, baseDelay)
+}
+
+// SetPoolHistory records reserve changes of pools stored in the local cache in poolHistory
+func (tlc *TwoLevelCache) SetPoolHistory(poolHistory *history.PoolHistory) {
+	tlc.localCache.SetPoolHistory(poolHistory)
+}
+
+// SetVolumeAccumulator records reserve changes of pools stored in the local cache in volumes
+func (tlc *TwoLevelCache) SetVolumeAccumulator(volumes *volume.Accumulator) {
+	tlc.localCache.SetVolumeAccumulator(volumes)
+}
+
+// AddObserver registers o with the local cache, so it is notified of pools stored
+// through this cache and of pools the local cache loads from Redis
+func (tlc *TwoLevelCache) AddObserver(o PoolObserver) {
+	tlc.localCache.AddObserver(o)
+}
+
+// RemoveObserver unregisters o from the local cache
+func (tlc *TwoLevelCache) RemoveObserver(o PoolObserver) {
+	tlc.localCache.RemoveObserver(o)
+}
+
+// Ping checks connectivity to the Redis layer
+func (tlc *TwoLevelCache) Ping(ctx context.Context) error {
+	return tlc.redisCache.Ping(ctx)
+}
+
 // StorePool stores pool in both cache layers
 func (tlc *TwoLevelCache) StorePool(ctx context.Context, pool *types.Pool) error {
+	// Reject invalid pools before either layer would log a failure
+	if err := validation.Validate(pool, tlc.validators); err != nil {
+		return err
+	}
+
 	// Store in local cache
 	if err := tlc.localCache.StorePool(ctx, pool); err != nil {
 		log.Printf("Warning: Failed to store pool in local cache: %v", err)
@@ -49,42 +198,82 @@ func (tlc *TwoLevelCache) StorePool(ctx context.Context, pool *types.Pool) error
 		return fmt.Errorf("failed to store pool in Redis: %v", err)
 	}
 
+	tlc.bufferWrite(pool.Address)
 	return nil
 }
 
-// GetPool retrieves pool with two-level cache lookup
+// bufferWrite adds address to the write buffer, first dropping expired entries when the
+// buffer has not been swept for WriteBufferTTL
+func (tlc *TwoLevelCache) bufferWrite(address string) {
+	now := time.Now()
+	if last := tlc.lastBufferSweep.Load(); now.UnixNano()-last >= int64(WriteBufferTTL) && tlc.lastBufferSweep.CompareAndSwap(last, now.UnixNano()) {
+		tlc.writeBuffer.Range(func(key, written any) bool {
+			if now.Sub(written.(time.Time)) >= WriteBufferTTL {
+				tlc.writeBuffer.CompareAndDelete(key, written)
+			}
+			return true
+		})
+	}
+	tlc.writeBuffer.Store(strings.ToLower(address), now)
+}
+
+// recentlyWritten reports whether the pool at address was stored in the last WriteBufferTTL
+func (tlc *TwoLevelCache) recentlyWritten(address string) bool {
+	key := strings.ToLower(address)
+	written, ok := tlc.writeBuffer.Load(key)
+	if !ok {
+		return false
+	}
+	if time.Since(written.(time.Time)) >= WriteBufferTTL {
+		tlc.writeBuffer.CompareAndDelete(key, written)
+		return false
+	}
+	return true
+}
+
+// GetPool retrieves pool with two-level cache lookup. Pools stored in the last
+// WriteBufferTTL are read from Redis, so they reflect the latest StorePool.
 func (tlc *TwoLevelCache) GetPool(ctx context.Context, address string) (*types.Pool, error) {
-	// First try local cache
-	pool, err := tlc.localCache.GetPool(ctx, address)
-	if err == nil {
+	// First try local cache, unless the pool was just written
+	if !tlc.recentlyWritten(address) {
+		start := time.Now()
+		pool, err := tlc.localCache.GetPool(ctx, address)
+		elapsed := time.Since(start)
+		if err == nil {
+			tlc.stats.mutex.Lock()
+			tlc.stats.LocalHits++
+			recordLatency(&tlc.stats.L1Latencies, &tlc.stats.l1Next, elapsed)
+			tlc.stats.mutex.Unlock()
+			return pool, nil
+		}
+
 		tlc.stats.mutex.Lock()
-		tlc.stats.LocalHits++
+		tlc.stats.LocalMisses++
+		recordLatency(&tlc.stats.L1Latencies, &tlc.stats.l1Next, elapsed)
 		tlc.stats.mutex.Unlock()
-		return pool, nil
 	}
 
-	tlc.stats.mutex.Lock()
-	tlc.stats.LocalMisses++
-	tlc.stats.mutex.Unlock()
-
-	// Local cache miss, try Redis
-	pool, err = tlc.redisCache.GetPool(ctx, address)
+	// Local cache miss or recent write, try Redis
+	start := time.Now()
+	pool, err := tlc.redisCache.GetPool(ctx, address)
+	elapsed := time.Since(start)
 	if err != nil {
 		tlc.stats.mutex.Lock()
 		tlc.stats.RedisMisses++
+		recordLatency(&tlc.stats.L2Latencies, &tlc.stats.l2Next, elapsed)
 		tlc.stats.mutex.Unlock()
 		return nil, err
 	}
 
 	tlc.stats.mutex.Lock()
 	tlc.stats.RedisHits++
+	recordLatency(&tlc.stats.L2Latencies, &tlc.stats.l2Next, elapsed)
 	tlc.stats.mutex.Unlock()
 
 	// Populate local cache
 	go func() {
-		// Use background context to avoid cancellation
-		bgCtx := context.Background()
-		if err := tlc.localCache.StorePool(bgCtx, pool); err != nil {
+		// Use the cache's own context so the backfill outlives the request
+		if err := tlc.localCache.StorePool(tlc.bgCtx, pool); err != nil {
 			log.Printf("Warning: Failed to backfill local cache: %v", err)
 		}
 	}()
@@ -92,12 +281,130 @@ func (tlc *TwoLevelCache) GetPool(ctx context.Context, address string) (*types.P
 	return pool, nil
 }
 
-// GetAllPools gets all pools with caching optimization
+// UpdatePool updates the pool in Redis and refreshes the local copy
+func (tlc *TwoLevelCache) UpdatePool(ctx context.Context, address string, update *types.PoolUpdate) (*types.Pool, error) {
+	pool, err := tlc.redisCache.UpdatePool(ctx, address, update)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update pool in Redis: %w", err)
+	}
+
+	// Update the local copy in place when cached, so its token pair index is not duplicated
+	if _, err := tlc.localCache.UpdatePool(ctx, address, update); err != nil {
+		if err := tlc.localCache.StorePool(ctx, pool); err != nil {
+			log.Printf("Warning: Failed to store pool in local cache: %v", err)
+		}
+	}
+	return pool, nil
+}
+
+// ApplyReserveDelta applies the deltas in Redis and copies the resulting reserves to the
+// local cache, so both layers agree even if the local copy was stale
+func (tlc *TwoLevelCache) ApplyReserveDelta(ctx context.Context, address string, delta0, delta1 *big.Int) error {
+	if err := tlc.redisCache.ApplyReserveDelta(ctx, address, delta0, delta1); err != nil {
+		return fmt.Errorf("failed to apply reserve delta in Redis: %w", err)
+	}
+
+	pool, err := tlc.redisCache.GetPool(ctx, address)
+	if err != nil {
+		log.Printf("Warning: Failed to read updated pool from Redis: %v", err)
+		return nil
+	}
+
+	// Updating the local copy records the reserve change as volume. A pool the local
+	// cache does not hold has no previous reserves there, so delta0 is recorded instead.
+	update := &types.PoolUpdate{Reserve0: pool.Reserve0, Reserve1: pool.Reserve1}
+	if _, err := tlc.localCache.UpdatePool(ctx, address, update); err != nil {
+		tlc.localCache.recordVolume(address, delta0, pool.ReserveUpdatedAt)
+		if err := tlc.localCache.StorePool(ctx, pool); err != nil {
+			log.Printf("Warning: Failed to store pool in local cache: %v", err)
+		}
+	}
+	return nil
+}
+
+// BulkUpdateReserves applies the updates in Redis, then those of the pools found there to
+// the pools held locally. The updated pools are read from Redis until WriteBufferTTL has passed.
+func (tlc *TwoLevelCache) BulkUpdateReserves(ctx context.Context, updates []types.ReserveUpdate) error {
+	parsed, err := parseReserveUpdates(updates)
+	if err != nil {
+		return err
+	}
+
+	// The local cache records the volume of the pools it holds as it applies the updates
+	previous := tlc.remoteReserve0(ctx, updates, parsed)
+
+	redisErr := tlc.redisCache.BulkUpdateReserves(ctx, updates)
+	var notFound *PoolsNotFoundError
+	if redisErr != nil && !errors.As(redisErr, &notFound) {
+		return fmt.Errorf("failed to update reserves in Redis: %w", redisErr)
+	}
+
+	missing := make(map[string]bool)
+	if notFound != nil {
+		for _, address := range notFound.Addresses {
+			missing[address] = true
+		}
+	}
+	updated := make([]types.ReserveUpdate, 0, len(updates))
+	for i, update := range updates {
+		if missing[update.Address] {
+			continue
+		}
+		updated = append(updated, update)
+		if prevReserve0, ok := previous[update.Address]; ok {
+			tlc.localCache.recordVolume(update.Address, new(big.Int).Sub(parsed[i].Reserve0, prevReserve0), time.Now())
+		}
+	}
+
+	// Pools the local cache does not hold are loaded from Redis when next read
+	if err := tlc.localCache.BulkUpdateReserves(ctx, updated); err != nil && !errors.As(err, new(*PoolsNotFoundError)) {
+		log.Printf("Warning: Failed to update reserves in local cache: %v", err)
+	}
+	for _, update := range updated {
+		tlc.bufferWrite(update.Address)
+	}
+	return redisErr
+}
+
+// remoteReserve0 reads from Redis the current reserve0 of the pools that updates set a
+// reserve0 for but the local cache does not hold, so their volume can be recorded too.
+// It returns nil when the local cache does not track volume.
+func (tlc *TwoLevelCache) remoteReserve0(ctx context.Context, updates []types.ReserveUpdate, parsed []*types.PoolUpdate) map[string]*big.Int {
+	if !tlc.localCache.tracksVolume() {
+		return nil
+	}
+
+	reserves := make(map[string]*big.Int)
+	for i, update := range updates {
+		if parsed[i].Reserve0 == nil {
+			continue
+		}
+		if _, err := tlc.localCache.GetPool(ctx, update.Address); err == nil {
+			continue
+		}
+		if pool, err := tlc.redisCache.GetPool(ctx, update.Address); err == nil && pool.Reserve0 != nil {
+			reserves[update.Address] = pool.Reserve0
+		}
+	}
+	return reserves
+}
+
+// GetAllPools gets all pools with caching optimization. When Redis is unavailable it
+// falls back to the pools held in the local cache.
 func (tlc *TwoLevelCache) GetAllPools(ctx context.Context) ([]*types.Pool, error) {
 	// For getAll operations, always use Redis as the source of truth
 	pools, err := tlc.redisCache.GetAllPools(ctx)
 	if err != nil {
-		return nil, err
+		localPools, localErr := tlc.localCache.GetAllPools(ctx)
+		if localErr != nil || len(localPools) == 0 {
+			return nil, err
+		}
+
+		log.Printf("Warning: Redis unavailable, serving %d pools from local cache: %v", len(localPools), err)
+		tlc.stats.mutex.Lock()
+		tlc.stats.FallbackHits++
+		tlc.stats.mutex.Unlock()
+		return localPools, nil
 	}
 
 	// Update local cache in background
@@ -106,22 +413,113 @@ func (tlc *TwoLevelCache) GetAllPools(ctx context.Context) ([]*types.Pool, error
 	return pools, nil
 }
 
+// Count returns the number of pools in the local cache without taking its lock. It
+// matches Redis once the local cache is warm, see WarmingComplete.
+func (tlc *TwoLevelCache) Count() int64 {
+	return tlc.localCache.Count()
+}
+
 // warmLocalCache updates local cache with fresh data
 func (tlc *TwoLevelCache) warmLocalCache(pools []*types.Pool) {
-	bgCtx := context.Background()
 	for _, pool := range pools {
-		if err := tlc.localCache.StorePool(bgCtx, pool); err != nil {
+		if tlc.bgCtx.Err() != nil {
+			return
+		}
+		if err := tlc.localCache.StorePool(tlc.bgCtx, pool); err != nil {
 			log.Printf("Warning: Failed to warm local cache: %v", err)
 		}
 	}
 }
 
+// SetWarmProgress sets a channel that receives a WarmProgress after each warming batch.
+// Sends never block; updates are dropped while the channel is full.
+func (tlc *TwoLevelCache) SetWarmProgress(progress chan<- WarmProgress) {
+	tlc.mutex.Lock()
+	defer tlc.mutex.Unlock()
+	tlc.warmProgress = progress
+}
+
+// WarmingComplete reports whether WarmLocalCacheFromRedis has loaded every pool into
+// the local cache. Until then lookups missing the local cache are served from Redis.
+func (tlc *TwoLevelCache) WarmingComplete() bool {
+	return tlc.warmingComplete.Load()
+}
+
+// WarmLocalCacheFromRedis loads every pool stored in Redis into the local cache in
+// batches of batchSize, pausing between batches to avoid flooding Redis
+func (tlc *TwoLevelCache) WarmLocalCacheFromRedis(ctx context.Context, batchSize int) error {
+	if batchSize <= 0 {
+		batchSize = DefaultWarmBatchSize
+	}
+
+	addrs, err := tlc.redisCache.allPoolAddresses(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list pools: %w", err)
+	}
+
+	tlc.mutex.RLock()
+	progress := tlc.warmProgress
+	tlc.mutex.RUnlock()
+
+	loaded := 0
+	for start := 0; start < len(addrs); start += batchSize {
+		if start > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(warmBatchDelay):
+			}
+		}
+
+		end := min(start+batchSize, len(addrs))
+		pools, err := tlc.redisCache.bulkGetPools(ctx, addrs[start:end])
+		if err != nil {
+			return fmt.Errorf("failed to load pools %d-%d: %w", start, end, err)
+		}
+		for _, pool := range pools {
+			if err := tlc.localCache.StorePool(ctx, pool); err != nil {
+				log.Printf("Warning: Failed to warm local cache with pool %s: %v", pool.Address, err)
+				continue
+			}
+			loaded++
+		}
+
+		if progress != nil {
+			select {
+			case progress <- WarmProgress{Loaded: loaded, Total: len(addrs)}:
+			default:
+			}
+		}
+	}
+
+	tlc.warmingComplete.Store(true)
+	return nil
+}
+
+// GetPoolsByExchange returns the pools of exchange from Redis, which indexes every
+// instance's pools rather than only those read locally
+func (tlc *TwoLevelCache) GetPoolsByExchange(ctx context.Context, exchange string) ([]*types.Pool, error) {
+	return tlc.redisCache.GetPoolsByExchange(ctx, exchange)
+}
+
+// GetPoolsCreatedAfter returns recently created pools from Redis, which tracks creation
+// times across all instances
+func (tlc *TwoLevelCache) GetPoolsCreatedAfter(ctx context.Context, since time.Time) ([]*types.Pool, error) {
+	return tlc.redisCache.GetPoolsCreatedAfter(ctx, since)
+}
+
 // GetPoolsByTokens searches pools by token pair
 func (tlc *TwoLevelCache) GetPoolsByTokens(ctx context.Context, tokenA, tokenB string) ([]*types.Pool, error) {
 	// For token pair searches, use Redis directly as memory store doesn't have efficient indexing
 	return tlc.redisCache.GetPoolsByTokens(ctx, tokenA, tokenB)
 }
 
+// GetPoolsBySymbol searches the local cache by token symbols. Redis has no symbol
+// index, and the local cache holds every pool once GetAllPools has warmed it.
+func (tlc *TwoLevelCache) GetPoolsBySymbol(ctx context.Context, symbolA, symbolB string) ([]*types.Pool, error) {
+	return tlc.localCache.GetPoolsBySymbol(ctx, symbolA, symbolB)
+}
+
 // StoreToken stores token information
 func (tlc *TwoLevelCache) StoreToken(ctx context.Context, token *types.Token) error {
 	// Store in both caches
@@ -146,19 +544,27 @@ func (tlc *TwoLevelCache) GetToken(ctx context.Context, address string) (*types.
 
 // GetStats returns cache performance statistics
 func (tlc *TwoLevelCache) GetStats() *CacheStats {
+	connPool := tlc.redisCache.ConnectionPoolStats()
+
 	tlc.stats.mutex.RLock()
 	defer tlc.stats.mutex.RUnlock()
 
 	return &CacheStats{
-		LocalHits:   tlc.stats.LocalHits,
-		LocalMisses: tlc.stats.LocalMisses,
-		RedisHits:   tlc.stats.RedisHits,
-		RedisMisses: tlc.stats.RedisMisses,
+		LocalHits:             tlc.stats.LocalHits,
+		LocalMisses:           tlc.stats.LocalMisses,
+		RedisHits:             tlc.stats.RedisHits,
+		RedisMisses:           tlc.stats.RedisMisses,
+		FallbackHits:          tlc.stats.FallbackHits,
+		RedisConnPoolHits:     int64(connPool.Hits),
+		RedisConnPoolMisses:   int64(connPool.Misses),
+		RedisConnPoolTimeouts: int64(connPool.Timeouts),
+		L1Latencies:           slices.Clone(tlc.stats.L1Latencies),
+		L2Latencies:           slices.Clone(tlc.stats.L2Latencies),
 	}
 }
 
-// ClearLocalCache clears the local memory cache
+// ClearLocalCache drops the pools of the local memory cache, so pool lookups fall back
+// to Redis until they refill it
 func (tlc *TwoLevelCache) ClearLocalCache() {
-	// Implementation would require adding Clear method to MemoryStore
-	log.Println("Local cache clear requested - would need MemoryStore enhancement")
+	tlc.localCache.ClearPools()
 }
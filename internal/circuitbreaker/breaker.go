@@ -0,0 +1,124 @@
+package circuitbreaker
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFailureThreshold is the number of consecutive failures that opens an exchange
+	DefaultFailureThreshold = 5
+	// DefaultRecoveryPeriod is how long an exchange stays open before it is tried again
+	DefaultRecoveryPeriod = 30 * time.Second
+)
+
+// ExchangeStats tracks the outcomes recorded for one exchange
+type ExchangeStats struct {
+	Successes           int64     `json:"successes"`
+	Failures            int64     `json:"failures"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	Trips               int64     `json:"trips"` // Times the breaker opened
+	OpenUntil           time.Time `json:"openUntil"`
+}
+
+// ExchangeBreaker stops routing through an exchange whose paths keep failing. After
+// FailureThreshold consecutive failures the exchange is open for RecoveryPeriod, then
+// closes again with its failure count reset.
+type ExchangeBreaker struct {
+	FailureThreshold int
+	RecoveryPeriod   time.Duration
+
+	mutex     sync.RWMutex
+	exchanges map[string]*ExchangeStats // Lowercase exchange name -> stats
+	names     map[string]string         // Lowercase exchange name -> name as first recorded
+	now       func() time.Time
+}
+
+// NewExchangeBreaker creates a breaker, using the defaults for non-positive arguments
+func NewExchangeBreaker(failureThreshold int, recoveryPeriod time.Duration) *ExchangeBreaker {
+	if failureThreshold <= 0 {
+		failureThreshold = DefaultFailureThreshold
+	}
+	if recoveryPeriod <= 0 {
+		recoveryPeriod = DefaultRecoveryPeriod
+	}
+	return &ExchangeBreaker{
+		FailureThreshold: failureThreshold,
+		RecoveryPeriod:   recoveryPeriod,
+		exchanges:        make(map[string]*ExchangeStats),
+		names:            make(map[string]string),
+		now:              time.Now,
+	}
+}
+
+// stats returns the stats of exchange, creating them if needed. The caller holds the mutex.
+func (b *ExchangeBreaker) stats(exchange string) *ExchangeStats {
+	key := strings.ToLower(exchange)
+	stats, ok := b.exchanges[key]
+	if !ok {
+		stats = &ExchangeStats{}
+		b.exchanges[key] = stats
+		b.names[key] = exchange
+	}
+	return stats
+}
+
+// RecordSuccess resets the consecutive failures of exchange
+func (b *ExchangeBreaker) RecordSuccess(exchange string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	stats := b.stats(exchange)
+	stats.Successes++
+	stats.ConsecutiveFailures = 0
+}
+
+// RecordFailure counts a failure of exchange and reports whether it opened the breaker.
+// Failures while the exchange is already open are counted but never reopen it.
+func (b *ExchangeBreaker) RecordFailure(exchange string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	stats := b.stats(exchange)
+	stats.Failures++
+
+	now := b.now()
+	if now.Before(stats.OpenUntil) {
+		return false
+	}
+
+	stats.ConsecutiveFailures++
+	if stats.ConsecutiveFailures < b.FailureThreshold {
+		return false
+	}
+
+	stats.ConsecutiveFailures = 0
+	stats.Trips++
+	stats.OpenUntil = now.Add(b.RecoveryPeriod)
+	log.Printf("Warning: Circuit breaker opened for %s after %d consecutive failures, skipping its pools for %v",
+		exchange, b.FailureThreshold, b.RecoveryPeriod)
+	return true
+}
+
+// IsOpen reports whether exchange is currently skipped
+func (b *ExchangeBreaker) IsOpen(exchange string) bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	stats, ok := b.exchanges[strings.ToLower(exchange)]
+	return ok && b.now().Before(stats.OpenUntil)
+}
+
+// GetStats returns a copy of the stats of every exchange recorded, keyed by exchange name
+func (b *ExchangeBreaker) GetStats() map[string]ExchangeStats {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	result := make(map[string]ExchangeStats, len(b.exchanges))
+	for key, stats := range b.exchanges {
+		result[b.names[key]] = *stats
+	}
+	return result
+}
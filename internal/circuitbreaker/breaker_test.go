@@ -0,0 +1,66 @@
+package circuitbreaker
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExchangeBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	now := time.Now()
+	b := NewExchangeBreaker(3, time.Minute)
+	b.now = func() time.Time { return now }
+
+	assert.False(t, b.RecordFailure("SushiSwap"))
+	assert.False(t, b.RecordFailure("SushiSwap"))
+
+	// A success resets the run of failures
+	b.RecordSuccess("SushiSwap")
+	assert.False(t, b.RecordFailure("SushiSwap"))
+	assert.False(t, b.RecordFailure("SushiSwap"))
+	assert.False(t, b.IsOpen("SushiSwap"))
+
+	assert.True(t, b.RecordFailure("sushiswap"), "exchange names are case-insensitive")
+	assert.True(t, b.IsOpen("SushiSwap"))
+	assert.False(t, b.IsOpen("Uniswap V2"))
+
+	// Failures while open neither count towards nor reopen the breaker
+	assert.False(t, b.RecordFailure("SushiSwap"))
+
+	stats := b.GetStats()["SushiSwap"]
+	assert.Equal(t, int64(1), stats.Successes)
+	assert.Equal(t, int64(6), stats.Failures)
+	assert.Equal(t, int64(1), stats.Trips)
+	assert.Equal(t, 0, stats.ConsecutiveFailures)
+	assert.Equal(t, now.Add(time.Minute), stats.OpenUntil)
+}
+
+func TestExchangeBreaker_RecoversAfterRecoveryPeriod(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	now := time.Now()
+	b := NewExchangeBreaker(0, 0)
+	b.now = func() time.Time { return now }
+	assert.Equal(t, DefaultFailureThreshold, b.FailureThreshold)
+	assert.Equal(t, DefaultRecoveryPeriod, b.RecoveryPeriod)
+
+	for i := 0; i < DefaultFailureThreshold; i++ {
+		b.RecordFailure("SushiSwap")
+	}
+	assert.True(t, b.IsOpen("SushiSwap"))
+
+	now = now.Add(DefaultRecoveryPeriod)
+	assert.False(t, b.IsOpen("SushiSwap"))
+
+	// A recovered exchange needs a full run of failures to open again
+	assert.False(t, b.RecordFailure("SushiSwap"))
+	assert.False(t, b.IsOpen("SushiSwap"))
+}
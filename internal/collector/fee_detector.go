@@ -0,0 +1,110 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"dex-aggregator/internal/cache"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// V2Fee is the fee of every Uniswap V2 style pool
+const V2Fee = 300
+
+const poolFeeABI = `[
+	{"inputs":[],"name":"fee","outputs":[{"internalType":"uint24","name":"","type":"uint24"}],"stateMutability":"view","type":"function"}
+]`
+
+var feeABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(poolFeeABI))
+	if err != nil {
+		panic(fmt.Sprintf("invalid ABI: %v", err))
+	}
+	return parsed
+}()
+
+// FeeDetector works out the fee of pools discovered on-chain. Fees read from fee()
+// are cached so each pool is queried once; failed reads are retried on the next call.
+type FeeDetector struct {
+	caller ethereum.ContractCaller // nil disables on-chain lookups
+	cache  *cache.MemoryStore
+}
+
+func NewFeeDetector(caller ethereum.ContractCaller, cache *cache.MemoryStore) *FeeDetector {
+	return &FeeDetector{
+		caller: caller,
+		cache:  cache,
+	}
+}
+
+// DetectFee returns the fee of the pool at poolAddress in the units of Pool.Fee for its
+// version: thousandths of a percent for V2 and hundredths of a basis point for V3, so
+// 300 and 3000 are both 0.3%. V2 pools always charge V2Fee, V3 pools report their fee
+// tier through fee(), and pools of any other version are asked for fee() and assumed
+// to be V2 when the call fails. That assumption is not cached, since the failure may
+// be transient.
+func (fd *FeeDetector) DetectFee(ctx context.Context, poolAddress string, version string) (int, error) {
+	if fee, ok := fd.cache.GetPoolFee(poolAddress); ok {
+		return fee, nil
+	}
+
+	var fee int
+	switch strings.ToLower(version) {
+	case "v2":
+		fee = V2Fee
+	case "v3":
+		onChainFee, err := fd.callFee(ctx, poolAddress)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read fee() of V3 pool %s: %w", poolAddress, err)
+		}
+		fee = onChainFee
+	default:
+		onChainFee, err := fd.callFee(ctx, poolAddress)
+		if err != nil {
+			log.Printf("FeeDetector: No fee() on %s pool %s, assuming V2 fee: %v", version, poolAddress, err)
+			return V2Fee, nil
+		}
+		fee = onChainFee
+	}
+
+	fd.cache.StorePoolFee(poolAddress, fee)
+	return fee, nil
+}
+
+// callFee reads a pool's fee() through eth_call
+func (fd *FeeDetector) callFee(ctx context.Context, poolAddress string) (int, error) {
+	if fd.caller == nil {
+		return 0, fmt.Errorf("no Ethereum client")
+	}
+
+	data, err := feeABI.Pack("fee")
+	if err != nil {
+		return 0, err
+	}
+
+	to := common.HexToAddress(poolAddress)
+	result, err := fd.caller.CallContract(ctx, ethereum.CallMsg{To: &to, Data: data}, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	values, err := feeABI.Unpack("fee", result)
+	if err != nil {
+		return 0, err
+	}
+	if len(values) == 0 {
+		return 0, fmt.Errorf("empty fee() result")
+	}
+
+	fee, ok := values[0].(*big.Int)
+	if !ok {
+		return 0, fmt.Errorf("unexpected fee() result type %T", values[0])
+	}
+	return int(fee.Int64()), nil
+}
@@ -0,0 +1,127 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"testing"
+
+	"dex-aggregator/internal/cache"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/stretchr/testify/assert"
+)
+
+// feeCaller answers fee() calls with a fixed fee, or fails when err is set
+type feeCaller struct {
+	fee   int64
+	err   error
+	calls int
+}
+
+func (m *feeCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	method, err := feeABI.MethodById(call.Data)
+	if err != nil {
+		return nil, err
+	}
+	return method.Outputs.Pack(big.NewInt(m.fee))
+}
+
+const testPoolAddress = "0x88e6A0c2dDD26FEEb64F039a2c41296FcB3f5640"
+
+func TestFeeDetector_DetectFee(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	testCases := []struct {
+		name          string
+		version       string
+		caller        *feeCaller
+		expectedFee   int
+		expectedCalls int
+		expectError   bool
+	}{
+		{"V2 needs no call", "v2", &feeCaller{fee: 500}, V2Fee, 0, false},
+		{"V3 reads fee()", "V3", &feeCaller{fee: 500}, 500, 1, false},
+		{"V3 call fails", "v3", &feeCaller{err: errors.New("execution reverted")}, 0, 1, true},
+		{"Unknown version with fee()", "", &feeCaller{fee: 10000}, 10000, 1, false},
+		{"Unknown version without fee()", "stable", &feeCaller{err: errors.New("execution reverted")}, V2Fee, 1, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fd := NewFeeDetector(tc.caller, cache.NewMemoryStore())
+
+			fee, err := fd.DetectFee(context.Background(), testPoolAddress, tc.version)
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedFee, fee)
+			}
+			assert.Equal(t, tc.expectedCalls, tc.caller.calls)
+		})
+	}
+}
+
+func TestFeeDetector_CachesFees(t *testing.T) {
+	store := cache.NewMemoryStore()
+	caller := &feeCaller{fee: 3000}
+	fd := NewFeeDetector(caller, store)
+
+	for i := 0; i < 3; i++ {
+		fee, err := fd.DetectFee(context.Background(), testPoolAddress, "v3")
+		assert.NoError(t, err)
+		assert.Equal(t, 3000, fee)
+	}
+	assert.Equal(t, 1, caller.calls)
+
+	// Addresses are matched case-insensitively
+	fee, ok := store.GetPoolFee("0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640")
+	assert.True(t, ok)
+	assert.Equal(t, 3000, fee)
+}
+
+func TestFeeDetector_RetriesFailedReads(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	store := cache.NewMemoryStore()
+	caller := &feeCaller{err: errors.New("connection refused")}
+	fd := NewFeeDetector(caller, store)
+
+	// The assumed V2 fee is not cached
+	fee, err := fd.DetectFee(context.Background(), testPoolAddress, "")
+	assert.NoError(t, err)
+	assert.Equal(t, V2Fee, fee)
+	_, ok := store.GetPoolFee(testPoolAddress)
+	assert.False(t, ok)
+
+	// so the read is retried and its result cached once it succeeds
+	caller.err = nil
+	caller.fee = 10000
+	for i := 0; i < 2; i++ {
+		fee, err = fd.DetectFee(context.Background(), testPoolAddress, "")
+		assert.NoError(t, err)
+		assert.Equal(t, 10000, fee)
+	}
+	assert.Equal(t, 2, caller.calls)
+}
+
+func TestFeeDetector_NoCaller(t *testing.T) {
+	fd := NewFeeDetector(nil, cache.NewMemoryStore())
+
+	fee, err := fd.DetectFee(context.Background(), testPoolAddress, "v2")
+	assert.NoError(t, err)
+	assert.Equal(t, V2Fee, fee)
+
+	_, err = fd.DetectFee(context.Background(), "0x8ad599c3A0ff1De082011EFDDc58f1908eb6e6D8", "v3")
+	assert.Error(t, err)
+}
@@ -4,28 +4,84 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
 	"math/big"
 	"strings"
 	"time"
 
+	"dex-aggregator/config"
 	"dex-aggregator/internal/cache"
 	"dex-aggregator/internal/types"
 )
 
+// PoolTemplate describes a mock pool created on every configured exchange
+type PoolTemplate struct {
+	Token0Symbol  string
+	Token1Symbol  string
+	Reserve0      *big.Int
+	Reserve1      *big.Int
+	FeeMultiplier float64 // Scales the base 0.3% fee; zero means 1
+	// ProtocolFeeBps is the part of the fee paid to the protocol rather than LPs
+	ProtocolFeeBps int
+
+	// Optional Uniswap V3 state; Version overrides the exchange version when set
+	Version      string
+	TickSpacing  int
+	SqrtPriceX96 *big.Int
+	TickCurrent  int32
+	Liquidity    *big.Int
+}
+
+// baseFee is the pool fee in thousandths of a percent (300 = 0.3%)
+const baseFee = 300
+
 type MockPoolCollector struct {
 	cache     cache.Store
 	exchanges []*types.Exchange
+	templates []PoolTemplate
+}
+
+// NewMockPoolCollector creates a collector seeded with DefaultPoolTemplates on the given
+// exchanges, or on DefaultExchanges when none are given
+func NewMockPoolCollector(cache cache.Store, exchanges ...*types.Exchange) *MockPoolCollector {
+	if len(exchanges) == 0 {
+		exchanges = DefaultExchanges()
+	}
+	return NewMockPoolCollectorWithTemplates(cache, exchanges, DefaultPoolTemplates())
+}
+
+// DefaultExchanges returns the exchanges of the default configuration
+func DefaultExchanges() []*types.Exchange {
+	return []*types.Exchange{
+		{
+			Name:    "Uniswap V2",
+			Factory: "0x5C69bEe701ef814a2B6a3EDD4B1652CB9cc5aA6f",
+			Router:  "0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D",
+			Version: "v2",
+		},
+		{
+			Name:    "SushiSwap",
+			Factory: "0xC0AEe478e3658e2610c5F7A4A2E1777cE9e4f2Ac",
+			Router:  "0xd9e1cE17f2641f24aE83637ab66a2cca9C378B9F",
+			Version: "v2",
+		},
+	}
 }
 
-// NewMockPoolCollector signature changed: receives exchanges from config
-func NewMockPoolCollector(cache cache.Store, exchanges []*types.Exchange) *MockPoolCollector {
-	// Remove hardcoded exchanges
+// NewMockPoolCollectorWithTemplates creates a collector that creates one pool per template and exchange
+func NewMockPoolCollectorWithTemplates(cache cache.Store, exchanges []*types.Exchange, templates []PoolTemplate) *MockPoolCollector {
 	return &MockPoolCollector{
 		cache:     cache,
 		exchanges: exchanges, // Store exchanges passed from config
+		templates: append([]PoolTemplate(nil), templates...),
 	}
 }
 
+// AddTemplate registers an additional pool template for the next InitMockPools
+func (mpc *MockPoolCollector) AddTemplate(t PoolTemplate) {
+	mpc.templates = append(mpc.templates, t)
+}
+
 // helper function to create big.Int from string
 func bigIntFromString(s string) *big.Int {
 	result, ok := new(big.Int).SetString(s, 10)
@@ -36,171 +92,202 @@ func bigIntFromString(s string) *big.Int {
 	return result
 }
 
-func (mpc *MockPoolCollector) InitMockPools() error {
-	ctx := context.Background()
-
-	// Extended token list
-	tokens := map[string]types.Token{
-		"WETH": {
-			Address:  "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2",
-			Symbol:   "WETH",
-			Decimals: 18,
-		},
-		"USDT": {
-			Address:  "0xdac17f958d2ee523a2206206994597c13d831ec7",
-			Symbol:   "USDT",
-			Decimals: 6,
-		},
-		"USDC": {
-			Address:  "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
-			Symbol:   "USDC",
-			Decimals: 6,
-		},
-		"DAI": {
-			Address:  "0x6b175474e89094c44da98b954eedeac495271d0f",
-			Symbol:   "DAI",
-			Decimals: 18,
-		},
-		"WBTC": {
-			Address:  "0x2260fac5e5542a773aa44fbcfedf7c193bc2c599",
-			Symbol:   "WBTC",
-			Decimals: 8,
-		},
-		"LINK": {
-			Address:  "0x514910771af9ca656af840dff83e8264ecf986ca",
-			Symbol:   "LINK",
-			Decimals: 18,
-		},
-		"UNI": {
-			Address:  "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984",
-			Symbol:   "UNI",
-			Decimals: 18,
-		},
-		"AAVE": {
-			Address:  "0x7fc66500c84a76ad7e9c93437bfc5ac33e2ddae9",
-			Symbol:   "AAVE",
-			Decimals: 18,
-		},
-	}
+// mockTokens are the tokens templates can refer to by symbol
+var mockTokens = map[string]types.Token{
+	"WETH": {
+		Address:  "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2",
+		Symbol:   "WETH",
+		Decimals: 18,
+	},
+	"USDT": {
+		Address:  "0xdac17f958d2ee523a2206206994597c13d831ec7",
+		Symbol:   "USDT",
+		Decimals: 6,
+	},
+	"USDC": {
+		Address:  "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+		Symbol:   "USDC",
+		Decimals: 6,
+	},
+	"DAI": {
+		Address:  "0x6b175474e89094c44da98b954eedeac495271d0f",
+		Symbol:   "DAI",
+		Decimals: 18,
+	},
+	"WBTC": {
+		Address:  "0x2260fac5e5542a773aa44fbcfedf7c193bc2c599",
+		Symbol:   "WBTC",
+		Decimals: 8,
+	},
+	"LINK": {
+		Address:  "0x514910771af9ca656af840dff83e8264ecf986ca",
+		Symbol:   "LINK",
+		Decimals: 18,
+	},
+	"UNI": {
+		Address:  "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984",
+		Symbol:   "UNI",
+		Decimals: 18,
+	},
+	"AAVE": {
+		Address:  "0x7fc66500c84a76ad7e9c93437bfc5ac33e2ddae9",
+		Symbol:   "AAVE",
+		Decimals: 18,
+	},
+}
 
-	// Extended trading pairs
-	pairs := []struct {
-		name     string
-		token0   types.Token
-		token1   types.Token
-		reserve0 *big.Int
-		reserve1 *big.Int
-	}{
+// DefaultPoolTemplates returns the standard mock market used by the server
+func DefaultPoolTemplates() []PoolTemplate {
+	return []PoolTemplate{
 		// Main stablecoin pairs
 		{
-			name:     "WETH/USDT",
-			token0:   tokens["WETH"],
-			token1:   tokens["USDT"],
-			reserve0: bigIntFromString("10000000000000000000"), // 10 WETH
-			reserve1: big.NewInt(20000000000),                  // 20,000 USDT
+			Token0Symbol: "WETH",
+			Token1Symbol: "USDT",
+			Reserve0:     bigIntFromString("10000000000000000000"), // 10 WETH
+			Reserve1:     big.NewInt(20000000000),                  // 20,000 USDT
 		},
 		{
-			name:     "WETH/USDC",
-			token0:   tokens["WETH"],
-			token1:   tokens["USDC"],
-			reserve0: bigIntFromString("500000000000000000000"), // 500 WETH
-			reserve1: big.NewInt(100000000000),                  // 1,000,000 USDC
+			Token0Symbol: "WETH",
+			Token1Symbol: "USDC",
+			Reserve0:     bigIntFromString("500000000000000000000"), // 500 WETH
+			Reserve1:     big.NewInt(100000000000),                  // 1,000,000 USDC
 		},
 		{
-			name:     "WETH/DAI",
-			token0:   tokens["WETH"],
-			token1:   tokens["DAI"],
-			reserve0: bigIntFromString("3000000000000000000"),    // 3 WETH
-			reserve1: bigIntFromString("6000000000000000000000"), // 6000 DAI
+			Token0Symbol: "WETH",
+			Token1Symbol: "DAI",
+			Reserve0:     bigIntFromString("3000000000000000000"),    // 3 WETH
+			Reserve1:     bigIntFromString("6000000000000000000000"), // 6000 DAI
 		},
 		// Stablecoin trading pairs
 		{
-			name:     "USDC/USDT",
-			token0:   tokens["USDC"],
-			token1:   tokens["USDT"],
-			reserve0: big.NewInt(5000000000), // 5,000 USDC
-			reserve1: big.NewInt(5000000000), // 5,000 USDT
+			Token0Symbol: "USDC",
+			Token1Symbol: "USDT",
+			Reserve0:     big.NewInt(5000000000), // 5,000 USDC
+			Reserve1:     big.NewInt(5000000000), // 5,000 USDT
 		},
 		{
-			name:     "USDC/DAI",
-			token0:   tokens["USDC"],
-			token1:   tokens["DAI"],
-			reserve0: big.NewInt(3000000000),                     // 3,000 USDC
-			reserve1: bigIntFromString("3000000000000000000000"), // 3000 DAI
+			Token0Symbol: "USDC",
+			Token1Symbol: "DAI",
+			Reserve0:     big.NewInt(3000000000),                     // 3,000 USDC
+			Reserve1:     bigIntFromString("3000000000000000000000"), // 3000 DAI
 		},
 		// WBTC trading pairs
 		{
-			name:     "WETH/WBTC",
-			token0:   tokens["WETH"],
-			token1:   tokens["WBTC"],
-			reserve0: bigIntFromString("50000000000000000000"), // 50 WETH
-			reserve1: big.NewInt(200000000),                    // 2 WBTC
+			Token0Symbol: "WETH",
+			Token1Symbol: "WBTC",
+			Reserve0:     bigIntFromString("50000000000000000000"), // 50 WETH
+			Reserve1:     big.NewInt(200000000),                    // 2 WBTC
 		},
 		{
-			name:     "WBTC/USDT",
-			token0:   tokens["WBTC"],
-			token1:   tokens["USDT"],
-			reserve0: big.NewInt(100000000),   // 1 WBTC
-			reserve1: big.NewInt(30000000000), // 30,000 USDT
+			Token0Symbol: "WBTC",
+			Token1Symbol: "USDT",
+			Reserve0:     big.NewInt(100000000),   // 1 WBTC
+			Reserve1:     big.NewInt(30000000000), // 30,000 USDT
 		},
 		// Other token pairs
 		{
-			name:     "WETH/LINK",
-			token0:   tokens["WETH"],
-			token1:   tokens["LINK"],
-			reserve0: bigIntFromString("2000000000000000000"),    // 2 WETH
-			reserve1: bigIntFromString("2000000000000000000000"), // 2000 LINK
+			Token0Symbol: "WETH",
+			Token1Symbol: "LINK",
+			Reserve0:     bigIntFromString("2000000000000000000"),    // 2 WETH
+			Reserve1:     bigIntFromString("2000000000000000000000"), // 2000 LINK
 		},
 		{
-			name:     "WETH/UNI",
-			token0:   tokens["WETH"],
-			token1:   tokens["UNI"],
-			reserve0: bigIntFromString("1000000000000000000"),   // 1 WETH
-			reserve1: bigIntFromString("500000000000000000000"), // 500 UNI
+			Token0Symbol: "WETH",
+			Token1Symbol: "UNI",
+			Reserve0:     bigIntFromString("1000000000000000000"),   // 1 WETH
+			Reserve1:     bigIntFromString("500000000000000000000"), // 500 UNI
 		},
 		{
-			name:     "WETH/AAVE",
-			token0:   tokens["WETH"],
-			token1:   tokens["AAVE"],
-			reserve0: bigIntFromString("800000000000000000"),   // 0.8 WETH
-			reserve1: bigIntFromString("40000000000000000000"), // 40 AAVE
+			Token0Symbol: "WETH",
+			Token1Symbol: "AAVE",
+			Reserve0:     bigIntFromString("800000000000000000"),   // 0.8 WETH
+			Reserve1:     bigIntFromString("40000000000000000000"), // 40 AAVE
 		},
 		// Pairs needed for three-hop paths
 		{
-			name:     "LINK/USDT",
-			token0:   tokens["LINK"],
-			token1:   tokens["USDT"],
-			reserve0: bigIntFromString("5000000000000000000000"), // 5000 LINK
-			reserve1: big.NewInt(2500000000),                     // 2,500 USDT
+			Token0Symbol: "LINK",
+			Token1Symbol: "USDT",
+			Reserve0:     bigIntFromString("5000000000000000000000"), // 5000 LINK
+			Reserve1:     big.NewInt(2500000000),                     // 2,500 USDT
 		},
 		{
-			name:     "UNI/USDC",
-			token0:   tokens["UNI"],
-			token1:   tokens["USDC"],
-			reserve0: bigIntFromString("1000000000000000000000"), // 1000 UNI
-			reserve1: big.NewInt(2000000000),                     // 2,000 USDC
+			Token0Symbol: "UNI",
+			Token1Symbol: "USDC",
+			Reserve0:     bigIntFromString("1000000000000000000000"), // 1000 UNI
+			Reserve1:     big.NewInt(2000000000),                     // 2,000 USDC
 		},
-		// Add higher liquidity pools to pairs array
+		// Higher liquidity WETH/USDT pool, with the protocol fee switch on
 		{
-			name:     "WETH/USDT-HighLiquidity",
-			token0:   tokens["WETH"],
-			token1:   tokens["USDT"],
-			reserve0: bigIntFromString("100000000000000000000"), // 100 WETH
-			reserve1: big.NewInt(200000000000),                  // 200,000 USDT
+			Token0Symbol:   "WETH",
+			Token1Symbol:   "USDT",
+			Reserve0:       bigIntFromString("100000000000000000000"), // 100 WETH
+			Reserve1:       big.NewInt(200000000000),                  // 200,000 USDT
+			ProtocolFeeBps: 5,
 		},
+		// V3 0.05% USDC/WETH pool priced at 2000 USDC per WETH
+		{
+			Token0Symbol:  "USDC",
+			Token1Symbol:  "WETH",
+			Reserve0:      big.NewInt(2000000000000),                  // 2,000,000 USDC
+			Reserve1:      bigIntFromString("1000000000000000000000"), // 1000 WETH
+			FeeMultiplier: 500.0 / baseFee,
+			Version:       "v3",
+			TickSpacing:   10,
+			SqrtPriceX96:  bigIntFromString("1771595571142957102961017161607260"),
+			TickCurrent:   200311,
+			Liquidity:     bigIntFromString("44721359549995793"), // sqrt(reserve0 * reserve1)
+		},
+	}
+}
+
+// isKnownExchange applies the configured strict exchange validation to a pool's exchange
+func isKnownExchange(name string) bool {
+	cfg := config.Current()
+	if cfg == nil || !cfg.DEX.StrictExchangeValidation {
+		return true
 	}
+	_, ok := cfg.DEX.FindExchange(name)
+	return ok
+}
+
+// InitMockPools stores one pool per template for each configured exchange
+func (mpc *MockPoolCollector) InitMockPools() error {
+	ctx := context.Background()
 
 	// Create pools for each exchange
 	uniquePools := make(map[string]bool)
 	poolCount := 0
 
-	// Change: Iterate over mpc.exchanges (from config)
-	for _, exchange := range mpc.exchanges {
-		for i, pair := range pairs {
+	for i, template := range mpc.templates {
+		token0, ok := mockTokens[template.Token0Symbol]
+		if !ok {
+			return fmt.Errorf("template %d: unknown token symbol %q", i, template.Token0Symbol)
+		}
+		token1, ok := mockTokens[template.Token1Symbol]
+		if !ok {
+			return fmt.Errorf("template %d: unknown token symbol %q", i, template.Token1Symbol)
+		}
 
-			poolAddress := fmt.Sprintf("%s-%s-%d",
+		if template.Reserve0 == nil || template.Reserve1 == nil {
+			return fmt.Errorf("template %d: %s/%s reserves are required", i, template.Token0Symbol, template.Token1Symbol)
+		}
+
+		feeMultiplier := template.FeeMultiplier
+		if feeMultiplier == 0 {
+			feeMultiplier = 1
+		}
+		pairName := template.Token0Symbol + "/" + template.Token1Symbol
+
+		for _, exchange := range mpc.exchanges {
+			if !isKnownExchange(exchange.Name) {
+				log.Printf("Warning: Skipping %s pool on unconfigured exchange %s", pairName, exchange.Name)
+				continue
+			}
+
+			poolAddress := fmt.Sprintf("%s-%s-%s-%d",
 				strings.ToLower(strings.ReplaceAll(exchange.Name, " ", "")),
-				strings.ToLower(strings.ReplaceAll(pair.name, "/", "-")),
+				strings.ToLower(template.Token0Symbol),
+				strings.ToLower(template.Token1Symbol),
 				i)
 			if uniquePools[poolAddress] {
 				continue
@@ -208,22 +295,36 @@ func (mpc *MockPoolCollector) InitMockPools() error {
 			uniquePools[poolAddress] = true
 
 			pool := &types.Pool{
-				Address:     fmt.Sprintf("%s-%s-%d", strings.ToLower(strings.ReplaceAll(exchange.Name, " ", "")), strings.ToLower(strings.ReplaceAll(pair.name, "/", "-")), i),
+				Address:     poolAddress,
 				Exchange:    exchange.Name,    // Use Exchange name from config
 				Version:     exchange.Version, // Use Exchange version from config
-				Token0:      pair.token0,
-				Token1:      pair.token1,
-				Reserve0:    pair.reserve0,
-				Reserve1:    pair.reserve1,
-				Fee:         300,
+				Token0:      token0,
+				Token1:      token1,
+				Reserve0:    new(big.Int).Set(template.Reserve0),
+				Reserve1:    new(big.Int).Set(template.Reserve1),
+				Fee:         int(math.Round(baseFee * feeMultiplier)),
 				LastUpdated: time.Now(),
+				TickSpacing: template.TickSpacing,
+				TickCurrent: template.TickCurrent,
+			}
+			if template.Version != "" {
+				pool.Version = template.Version
+			}
+			pool.FeeTier = types.FeeToFeeTier(pool.Fee, pool.Version)
+			pool.ProtocolFeeBps = template.ProtocolFeeBps
+			pool.LPFeeBps = types.FeeToBasisPoints(pool.Fee, pool.Version) - template.ProtocolFeeBps
+			if template.SqrtPriceX96 != nil {
+				pool.SqrtPriceX96 = new(big.Int).Set(template.SqrtPriceX96)
+			}
+			if template.Liquidity != nil {
+				pool.Liquidity = new(big.Int).Set(template.Liquidity)
 			}
 
 			err := mpc.cache.StorePool(ctx, pool)
 			if err != nil {
 				log.Printf("Failed to store pool: %v", err)
 			} else {
-				log.Printf("✓ Created %s pool: %s", exchange.Name, pair.name)
+				log.Printf("✓ Created %s pool: %s", exchange.Name, pairName)
 				poolCount++
 			}
 		}
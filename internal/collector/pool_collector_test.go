@@ -0,0 +1,151 @@
+package collector
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"dex-aggregator/config"
+	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testExchanges = []*types.Exchange{
+	{Name: "Uniswap V2", Version: "v2"},
+	{Name: "SushiSwap", Version: "v2"},
+}
+
+func TestMockPoolCollector_Templates(t *testing.T) {
+	store := cache.NewMemoryStore()
+	mpc := NewMockPoolCollectorWithTemplates(store, testExchanges, []PoolTemplate{
+		{Token0Symbol: "WETH", Token1Symbol: "USDC", Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000000)},
+	})
+	mpc.AddTemplate(PoolTemplate{Token0Symbol: "USDC", Token1Symbol: "DAI", Reserve0: big.NewInt(500), Reserve1: big.NewInt(500), FeeMultiplier: 0.5})
+	mpc.AddTemplate(PoolTemplate{Token0Symbol: "WETH", Token1Symbol: "DAI", Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000000), ProtocolFeeBps: 5})
+
+	assert.NoError(t, mpc.InitMockPools())
+
+	pools, err := store.GetAllPools(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, pools, 6) // 3 templates x 2 exchanges
+
+	pool, err := store.GetPool(context.Background(), "sushiswap-weth-usdc-0")
+	assert.NoError(t, err)
+	assert.Equal(t, "SushiSwap", pool.Exchange)
+	assert.Equal(t, "WETH", pool.Token0.Symbol)
+	assert.Equal(t, 0, pool.Reserve1.Cmp(big.NewInt(2000000)))
+	assert.Equal(t, 300, pool.Fee)
+	assert.Equal(t, types.FeeTierV2_30, pool.FeeTier)
+	assert.Equal(t, 30, pool.LPFeeBps)
+	assert.Equal(t, 0, pool.ProtocolFeeBps)
+
+	pool, err = store.GetPool(context.Background(), "uniswapv2-usdc-dai-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 150, pool.Fee)
+	assert.Equal(t, "0.15%", pool.FeeTier)
+	assert.Equal(t, 15, pool.LPFeeBps)
+
+	// The protocol share comes out of the pool fee
+	pool, err = store.GetPool(context.Background(), "uniswapv2-weth-dai-2")
+	assert.NoError(t, err)
+	assert.Equal(t, 300, pool.Fee)
+	assert.Equal(t, 25, pool.LPFeeBps)
+	assert.Equal(t, 5, pool.ProtocolFeeBps)
+	assert.Equal(t, 30, pool.TotalFeeBps())
+}
+
+func TestMockPoolCollector_DefaultTemplates(t *testing.T) {
+	store := cache.NewMemoryStore()
+	mpc := NewMockPoolCollector(store, testExchanges...)
+
+	assert.NoError(t, mpc.InitMockPools())
+
+	pools, err := store.GetAllPools(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, pools, len(DefaultPoolTemplates())*len(testExchanges))
+}
+
+func TestMockPoolCollector_DefaultExchanges(t *testing.T) {
+	store := cache.NewMemoryStore()
+	mpc := NewMockPoolCollector(store)
+
+	assert.NoError(t, mpc.InitMockPools())
+
+	pools, err := store.GetAllPools(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, pools, len(DefaultPoolTemplates())*len(DefaultExchanges()))
+
+	exchanges := make(map[string]bool)
+	for _, pool := range pools {
+		exchanges[pool.Exchange] = true
+	}
+	assert.Equal(t, map[string]bool{"Uniswap V2": true, "SushiSwap": true}, exchanges)
+}
+
+func TestMockPoolCollector_InvalidTemplate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		template PoolTemplate
+	}{
+		{"Unknown token", PoolTemplate{Token0Symbol: "WETH", Token1Symbol: "NOPE", Reserve0: big.NewInt(1), Reserve1: big.NewInt(1)}},
+		{"Missing reserve", PoolTemplate{Token0Symbol: "WETH", Token1Symbol: "USDC", Reserve0: big.NewInt(1)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mpc := NewMockPoolCollectorWithTemplates(cache.NewMemoryStore(), testExchanges, []PoolTemplate{tc.template})
+			assert.Error(t, mpc.InitMockPools())
+		})
+	}
+}
+
+func TestMockPoolCollector_V3Template(t *testing.T) {
+	store := cache.NewMemoryStore()
+	mpc := NewMockPoolCollector(store, testExchanges...)
+
+	assert.NoError(t, mpc.InitMockPools())
+
+	var v3Pools []*types.Pool
+	pools, err := store.GetAllPools(context.Background())
+	assert.NoError(t, err)
+	for _, pool := range pools {
+		if pool.Version == "v3" {
+			v3Pools = append(v3Pools, pool)
+		}
+	}
+
+	assert.Len(t, v3Pools, len(testExchanges))
+	for _, pool := range v3Pools {
+		assert.Equal(t, 500, pool.Fee)
+		assert.Equal(t, types.FeeTierV3_5, pool.FeeTier)
+		assert.Equal(t, 5, pool.LPFeeBps)
+		assert.Equal(t, 10, pool.TickSpacing)
+		assert.Equal(t, int32(200311), pool.TickCurrent)
+		assert.NotNil(t, pool.SqrtPriceX96)
+		assert.NotNil(t, pool.Liquidity)
+	}
+}
+
+func TestMockPoolCollector_SkipsUnconfiguredExchanges(t *testing.T) {
+	previous := config.Current()
+	t.Cleanup(func() { config.Set(previous) })
+	config.Set(&config.Config{DEX: config.DEXConfig{
+		Exchanges:                []types.Exchange{{Name: "uniswap v2", Version: "v2"}},
+		StrictExchangeValidation: true,
+	}})
+
+	store := cache.NewMemoryStore()
+	mpc := NewMockPoolCollectorWithTemplates(store, testExchanges, []PoolTemplate{
+		{Token0Symbol: "WETH", Token1Symbol: "USDC", Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000000)},
+	})
+
+	assert.NoError(t, mpc.InitMockPools())
+
+	pools, err := store.GetAllPools(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, pools, 1) {
+		assert.Equal(t, "Uniswap V2", pools[0].Exchange)
+	}
+}
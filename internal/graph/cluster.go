@@ -0,0 +1,108 @@
+package graph
+
+import (
+	"sort"
+	"strings"
+
+	"dex-aggregator/internal/types"
+)
+
+// PoolCluster is a connected component of pools linked by shared tokens
+type PoolCluster struct {
+	Tokens []string      `json:"tokens"`
+	Pools  []*types.Pool `json:"pools"`
+}
+
+// disjointSet is a union-find structure over token addresses
+type disjointSet struct {
+	parent map[string]string
+	rank   map[string]int
+}
+
+func newDisjointSet() *disjointSet {
+	return &disjointSet{
+		parent: make(map[string]string),
+		rank:   make(map[string]int),
+	}
+}
+
+func (ds *disjointSet) add(x string) {
+	if _, exists := ds.parent[x]; !exists {
+		ds.parent[x] = x
+	}
+}
+
+func (ds *disjointSet) find(x string) string {
+	root := x
+	for ds.parent[root] != root {
+		root = ds.parent[root]
+	}
+	// Path compression
+	for ds.parent[x] != root {
+		next := ds.parent[x]
+		ds.parent[x] = root
+		x = next
+	}
+	return root
+}
+
+func (ds *disjointSet) union(a, b string) {
+	rootA, rootB := ds.find(a), ds.find(b)
+	if rootA == rootB {
+		return
+	}
+	// Union by rank
+	switch {
+	case ds.rank[rootA] < ds.rank[rootB]:
+		ds.parent[rootA] = rootB
+	case ds.rank[rootA] > ds.rank[rootB]:
+		ds.parent[rootB] = rootA
+	default:
+		ds.parent[rootB] = rootA
+		ds.rank[rootA]++
+	}
+}
+
+// ClusterPools groups pools into clusters reachable from each other via shared tokens.
+// Clusters are sorted by pool count, largest first.
+func ClusterPools(pools []*types.Pool) []PoolCluster {
+	ds := newDisjointSet()
+	for _, pool := range pools {
+		t0 := strings.ToLower(pool.Token0.Address)
+		t1 := strings.ToLower(pool.Token1.Address)
+		ds.add(t0)
+		ds.add(t1)
+		ds.union(t0, t1)
+	}
+
+	clustersByRoot := make(map[string]*PoolCluster)
+	for _, pool := range pools {
+		root := ds.find(strings.ToLower(pool.Token0.Address))
+		cluster, exists := clustersByRoot[root]
+		if !exists {
+			cluster = &PoolCluster{}
+			clustersByRoot[root] = cluster
+		}
+		cluster.Pools = append(cluster.Pools, pool)
+	}
+
+	for token := range ds.parent {
+		cluster := clustersByRoot[ds.find(token)]
+		cluster.Tokens = append(cluster.Tokens, token)
+	}
+
+	clusters := make([]PoolCluster, 0, len(clustersByRoot))
+	for _, cluster := range clustersByRoot {
+		sort.Strings(cluster.Tokens)
+		clusters = append(clusters, *cluster)
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Pools) != len(clusters[j].Pools) {
+			return len(clusters[i].Pools) > len(clusters[j].Pools)
+		}
+		return clusters[i].Tokens[0] < clusters[j].Tokens[0]
+	})
+
+	return clusters
+}
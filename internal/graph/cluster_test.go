@@ -0,0 +1,64 @@
+package graph
+
+import (
+	"testing"
+
+	"dex-aggregator/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPool(address, token0, token1 string) *types.Pool {
+	return &types.Pool{
+		Address: address,
+		Token0:  types.Token{Address: token0},
+		Token1:  types.Token{Address: token1},
+	}
+}
+
+func TestClusterPools_IsolatedSubgraphs(t *testing.T) {
+	pools := []*types.Pool{
+		// Cluster 1: A-B-C
+		testPool("pool1", "0xa", "0xb"),
+		testPool("pool2", "0xB", "0xc"),
+		testPool("pool3", "0xa", "0xc"),
+		// Cluster 2: D-E
+		testPool("pool4", "0xd", "0xe"),
+		testPool("pool5", "0xe", "0xd"),
+		// Cluster 3: F-G
+		testPool("pool6", "0xf", "0xg"),
+	}
+
+	clusters := ClusterPools(pools)
+
+	assert.Equal(t, 3, len(clusters))
+
+	assert.Equal(t, []string{"0xa", "0xb", "0xc"}, clusters[0].Tokens)
+	assert.Equal(t, 3, len(clusters[0].Pools))
+
+	assert.Equal(t, []string{"0xd", "0xe"}, clusters[1].Tokens)
+	assert.Equal(t, 2, len(clusters[1].Pools))
+
+	assert.Equal(t, []string{"0xf", "0xg"}, clusters[2].Tokens)
+	assert.Equal(t, 1, len(clusters[2].Pools))
+	assert.Equal(t, "pool6", clusters[2].Pools[0].Address)
+}
+
+func TestClusterPools_MergedByBridgePool(t *testing.T) {
+	pools := []*types.Pool{
+		testPool("pool1", "0xa", "0xb"),
+		testPool("pool2", "0xc", "0xd"),
+		testPool("bridge", "0xb", "0xc"),
+	}
+
+	clusters := ClusterPools(pools)
+
+	assert.Equal(t, 1, len(clusters))
+	assert.Equal(t, 4, len(clusters[0].Tokens))
+	assert.Equal(t, 3, len(clusters[0].Pools))
+}
+
+func TestClusterPools_Empty(t *testing.T) {
+	clusters := ClusterPools(nil)
+	assert.Equal(t, 0, len(clusters))
+}
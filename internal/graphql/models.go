@@ -0,0 +1,85 @@
+package graphql
+
+import (
+	"math/big"
+	"time"
+
+	"dex-aggregator/internal/types"
+)
+
+// newQuoteResponse converts a router quote into its GraphQL type
+func newQuoteResponse(resp *types.QuoteResponse) *QuoteResponse {
+	quote := &QuoteResponse{
+		AmountOut:     bigString(resp.AmountOut),
+		GasEstimate:   bigString(resp.GasEstimate),
+		BestPath:      newTradePath(resp.BestPath),
+		Paths:         make([]*TradePath, len(resp.Paths)),
+		PartialResult: resp.PartialResult,
+	}
+	if resp.ExecutionPrice != "" {
+		quote.ExecutionPrice = &resp.ExecutionPrice
+	}
+	for i, path := range resp.Paths {
+		quote.Paths[i] = newTradePath(path)
+	}
+	return quote
+}
+
+func newTradePath(path *types.TradePath) *TradePath {
+	if path == nil {
+		return nil
+	}
+	converted := &TradePath{
+		Pools:     make([]*Pool, len(path.Pools)),
+		Dexes:     path.Dexes,
+		AmountIn:  bigString(path.AmountIn),
+		AmountOut: bigString(path.AmountOut),
+		GasCost:   bigString(path.GasCost),
+	}
+	if converted.Dexes == nil {
+		converted.Dexes = []string{}
+	}
+	if path.PriceImpact != 0 {
+		priceImpact := path.PriceImpact
+		converted.PriceImpact = &priceImpact
+	}
+	for i, pool := range path.Pools {
+		converted.Pools[i] = newPool(pool)
+	}
+	return converted
+}
+
+func newPool(pool *types.Pool) *Pool {
+	converted := &Pool{
+		Address:     pool.Address,
+		Exchange:    pool.Exchange,
+		Version:     pool.Version,
+		Token0:      newToken(pool.Token0),
+		Token1:      newToken(pool.Token1),
+		Reserve0:    bigString(pool.Reserve0),
+		Reserve1:    bigString(pool.Reserve1),
+		Fee:         pool.Fee,
+		LastUpdated: pool.LastUpdated.Format(time.RFC3339),
+	}
+	if pool.FeeTier != "" {
+		feeTier := pool.FeeTier
+		converted.FeeTier = &feeTier
+	}
+	return converted
+}
+
+func newToken(token types.Token) *Token {
+	converted := &Token{Address: token.Address, Symbol: token.Symbol, Decimals: token.Decimals}
+	if token.Name != "" {
+		converted.Name = &token.Name
+	}
+	return converted
+}
+
+// bigString formats n in decimal, with nil as "0"
+func bigString(n *big.Int) string {
+	if n == nil {
+		return "0"
+	}
+	return n.String()
+}
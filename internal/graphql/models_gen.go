@@ -0,0 +1,52 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graphql
+
+type Mutation struct {
+}
+
+type Pool struct {
+	Address     string  `json:"address"`
+	Exchange    string  `json:"exchange"`
+	Version     string  `json:"version"`
+	Token0      *Token  `json:"token0"`
+	Token1      *Token  `json:"token1"`
+	Reserve0    string  `json:"reserve0"`
+	Reserve1    string  `json:"reserve1"`
+	Fee         int     `json:"fee"`
+	FeeTier     *string `json:"feeTier,omitempty"`
+	LastUpdated string  `json:"lastUpdated"`
+}
+
+type PoolList struct {
+	Count int     `json:"count"`
+	Pools []*Pool `json:"pools"`
+}
+
+type Query struct {
+}
+
+type QuoteResponse struct {
+	AmountOut      string       `json:"amountOut"`
+	GasEstimate    string       `json:"gasEstimate"`
+	ExecutionPrice *string      `json:"executionPrice,omitempty"`
+	BestPath       *TradePath   `json:"bestPath,omitempty"`
+	Paths          []*TradePath `json:"paths"`
+	PartialResult  bool         `json:"partialResult"`
+}
+
+type Token struct {
+	Address  string  `json:"address"`
+	Symbol   string  `json:"symbol"`
+	Name     *string `json:"name,omitempty"`
+	Decimals int     `json:"decimals"`
+}
+
+type TradePath struct {
+	Pools       []*Pool  `json:"pools"`
+	Dexes       []string `json:"dexes"`
+	AmountIn    string   `json:"amountIn"`
+	AmountOut   string   `json:"amountOut"`
+	GasCost     string   `json:"gasCost"`
+	PriceImpact *float64 `json:"priceImpact,omitempty"`
+}
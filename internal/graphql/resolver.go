@@ -0,0 +1,17 @@
+package graphql
+
+import (
+	"dex-aggregator/internal/aggregator"
+	"dex-aggregator/internal/cache"
+)
+
+// Resolver serves the GraphQL schema from the router and the pool store, the same
+// sources as the REST API
+type Resolver struct {
+	router *aggregator.Router
+	store  cache.Store
+}
+
+func NewResolver(router *aggregator.Router, store cache.Store) *Resolver {
+	return &Resolver{router: router, store: store}
+}
@@ -0,0 +1,146 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"testing"
+
+	"dex-aggregator/config"
+	"dex-aggregator/internal/aggregator"
+	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/testutil"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	weth = testutil.NewToken("0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2").WithSymbol("WETH").Build()
+	usdt = testutil.NewToken("0xdac17f958d2ee523a2206206994597c13d831ec7").WithSymbol("USDT").WithDecimals(6).Build()
+	dai  = testutil.NewToken("0x6b175474e89094c44da98b954eedeac495271d0f").WithSymbol("DAI").Build()
+)
+
+// newTestClient serves the schema from a router over store, the way main.go mounts it
+func newTestClient(t *testing.T, store cache.Store) *client.Client {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	previous := config.Current()
+	t.Cleanup(func() { config.Set(previous) })
+	config.Set(&config.Config{Performance: config.PerformanceConfig{MaxHops: 3}})
+
+	perfConfig := config.PerformanceConfig{MaxSlippage: 5.0, MaxHops: 3, MaxConcurrentPaths: 10}
+	router := aggregator.NewRouter(context.Background(), store, perfConfig)
+	return client.New(handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: NewResolver(router, store)})))
+}
+
+func newTestStore() cache.Store {
+	reserve, _ := new(big.Int).SetString("1000000000000000000000", 10) // 1000 WETH
+	return testutil.NewMemStoreWithPools(
+		testutil.NewPool().WithAddress("0xpool1").WithTokens(weth, usdt).
+			WithReserves(reserve, big.NewInt(2000000000000)).WithFee(300).Build(),
+		testutil.NewPool().WithAddress("0xpool2").WithExchange("SushiSwap").WithTokens(dai, weth).
+			WithReserves(big.NewInt(2000000), big.NewInt(1000)).WithFee(300).Build(),
+	)
+}
+
+func TestQuote(t *testing.T) {
+	c := newTestClient(t, newTestStore())
+
+	var resp struct {
+		Quote struct {
+			AmountOut string
+			BestPath  struct {
+				AmountIn string
+				Dexes    []string
+				Pools    []struct{ Address string }
+			}
+		}
+	}
+	c.MustPost(`query($in: String!, $out: String!) {
+		quote(tokenIn: $in, tokenOut: $out, amountIn: "1000000000000000000") {
+			amountOut
+			bestPath { amountIn dexes pools { address } }
+		}
+	}`, &resp, client.Var("in", weth.Address), client.Var("out", usdt.Address))
+
+	amountOut, ok := new(big.Int).SetString(resp.Quote.AmountOut, 10)
+	require.True(t, ok, "amountOut is a decimal string")
+	assert.Positive(t, amountOut.Sign())
+	assert.Equal(t, "1000000000000000000", resp.Quote.BestPath.AmountIn)
+	assert.Equal(t, []string{"Uniswap V2"}, resp.Quote.BestPath.Dexes)
+	if assert.Len(t, resp.Quote.BestPath.Pools, 1) {
+		assert.Equal(t, "0xpool1", resp.Quote.BestPath.Pools[0].Address)
+	}
+
+	// Invalid amounts are reported as GraphQL errors
+	err := c.Post(`{ quote(tokenIn: "0xa", tokenOut: "0xb", amountIn: "-1") { amountOut } }`, &resp)
+	assert.ErrorContains(t, err, "invalid input amount")
+}
+
+func TestPools(t *testing.T) {
+	c := newTestClient(t, newTestStore())
+
+	var resp struct {
+		Pools struct {
+			Count int
+			Pools []struct {
+				Address  string
+				Exchange string
+				Reserve0 string
+				Token0   struct{ Symbol string }
+			}
+		}
+	}
+	c.MustPost(`{ pools { count pools { address exchange reserve0 token0 { symbol } } } }`, &resp)
+	assert.Equal(t, 2, resp.Pools.Count)
+	if assert.Len(t, resp.Pools.Pools, 2) {
+		assert.Equal(t, "0xpool1", resp.Pools.Pools[0].Address)
+		assert.Equal(t, "1000000000000000000000", resp.Pools.Pools[0].Reserve0)
+		assert.Equal(t, "WETH", resp.Pools.Pools[0].Token0.Symbol)
+	}
+
+	c.MustPost(`{ pools(exchange: "sushiswap") { count pools { address exchange } } }`, &resp)
+	assert.Equal(t, 1, resp.Pools.Count)
+	if assert.Len(t, resp.Pools.Pools, 1) {
+		assert.Equal(t, "SushiSwap", resp.Pools.Pools[0].Exchange)
+	}
+
+	c.MustPost(`{ pools(limit: 1) { count pools { address } } }`, &resp)
+	assert.Equal(t, 1, resp.Pools.Count)
+
+	err := c.Post(`{ pools(limit: -1) { count } }`, &resp)
+	assert.ErrorContains(t, err, "limit must not be negative")
+}
+
+func TestRefreshPools(t *testing.T) {
+	store := newTestStore()
+	c := newTestClient(t, store)
+
+	// A pool stored after the router loaded its graph is routable once refreshed
+	reserve, _ := new(big.Int).SetString("1000000000000000000000", 10)
+	require.NoError(t, store.StorePool(context.Background(), testutil.NewPool().WithAddress("0xpool3").
+		WithTokens(usdt, dai).WithReserves(big.NewInt(2000000000000), reserve).WithFee(300).Build()))
+
+	var resp struct{ RefreshPools bool }
+	c.MustPost(`mutation { refreshPools }`, &resp)
+	assert.True(t, resp.RefreshPools)
+
+	var quote struct {
+		Quote struct {
+			BestPath struct{ Pools []struct{ Address string } }
+		}
+	}
+	c.MustPost(`query($in: String!, $out: String!) {
+		quote(tokenIn: $in, tokenOut: $out, amountIn: "1000000") { bestPath { pools { address } } }
+	}`, &quote, client.Var("in", usdt.Address), client.Var("out", dai.Address))
+	if assert.Len(t, quote.Quote.BestPath.Pools, 1) {
+		assert.Equal(t, "0xpool3", quote.Quote.BestPath.Pools[0].Address)
+	}
+}
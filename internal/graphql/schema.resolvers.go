@@ -0,0 +1,94 @@
+package graphql
+
+// This file will be automatically regenerated based on the schema, any resolver
+// implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.86
+
+import (
+	"context"
+	"dex-aggregator/config"
+	"dex-aggregator/internal/types"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// RefreshPools is the resolver for the refreshPools field.
+func (r *mutationResolver) RefreshPools(ctx context.Context) (*bool, error) {
+	if err := r.router.RefreshGraph(ctx); err != nil {
+		return nil, fmt.Errorf("graph refresh failed: %w", err)
+	}
+	refreshed := true
+	return &refreshed, nil
+}
+
+// Quote is the resolver for the quote field.
+func (r *queryResolver) Quote(ctx context.Context, tokenIn string, tokenOut string, amountIn string) (*QuoteResponse, error) {
+	if tokenIn == "" || tokenOut == "" {
+		return nil, errors.New("tokenIn and tokenOut are required")
+	}
+	amount, ok := new(big.Int).SetString(amountIn, 10)
+	if !ok || amount.Sign() <= 0 {
+		return nil, errors.New("invalid input amount")
+	}
+
+	// The REST default, capped at the server limit
+	maxHops := 3
+	if limit := config.Current().Performance.MaxHops; limit > 0 && maxHops > limit {
+		maxHops = limit
+	}
+
+	resp, err := r.router.GetBestQuote(ctx, &types.QuoteRequest{
+		TokenIn:  tokenIn,
+		TokenOut: tokenOut,
+		AmountIn: amount,
+		MaxHops:  maxHops,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("quote calculation failed: %w", err)
+	}
+	return newQuoteResponse(resp), nil
+}
+
+// Pools is the resolver for the pools field.
+func (r *queryResolver) Pools(ctx context.Context, exchange *string, limit *int) (*PoolList, error) {
+	if limit != nil && *limit < 0 {
+		return nil, errors.New("limit must not be negative")
+	}
+
+	var pools []*types.Pool
+	var err error
+	if exchange != nil && *exchange != "" {
+		pools, err = r.store.GetPoolsByExchange(ctx, *exchange)
+	} else {
+		pools, err = r.store.GetAllPools(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pools: %w", err)
+	}
+
+	// Sorted so that a limit always returns the same pools
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Address < pools[j].Address })
+	if limit != nil && *limit < len(pools) {
+		pools = pools[:*limit]
+	}
+
+	list := &PoolList{Count: len(pools), Pools: make([]*Pool, len(pools))}
+	for i, pool := range pools {
+		list.Pools[i] = newPool(pool)
+	}
+	return list, nil
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+type (
+	mutationResolver struct{ *Resolver }
+	queryResolver    struct{ *Resolver }
+)
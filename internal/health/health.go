@@ -0,0 +1,137 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"dex-aggregator/internal/cache"
+)
+
+// Check statuses, ordered from best to worst
+const (
+	StatusOK        = "ok"
+	StatusDegraded  = "degraded"
+	StatusUnhealthy = "unhealthy"
+)
+
+// CheckResult is the outcome of a single health check
+type CheckResult struct {
+	Status           string `json:"status"`
+	LatencyMs        int64  `json:"latencyMs,omitempty"`
+	Count            int    `json:"count,omitempty"`
+	OldestUpdatedAgo string `json:"oldestUpdatedAgo,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// Checker reports the health of one dependency
+type Checker interface {
+	Check(ctx context.Context) CheckResult
+}
+
+// Pinger is implemented by stores backed by Redis
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// defaultSlowThreshold is the PING latency above which Redis is reported as degraded
+const defaultSlowThreshold = 100 * time.Millisecond
+
+// RedisChecker reports Redis as unhealthy when PING fails and degraded when it is slow
+type RedisChecker struct {
+	pinger        Pinger
+	slowThreshold time.Duration
+}
+
+func NewRedisChecker(pinger Pinger, slowThreshold time.Duration) *RedisChecker {
+	if slowThreshold <= 0 {
+		slowThreshold = defaultSlowThreshold
+	}
+	return &RedisChecker{
+		pinger:        pinger,
+		slowThreshold: slowThreshold,
+	}
+}
+
+func (rc *RedisChecker) Check(ctx context.Context) CheckResult {
+	start := time.Now()
+	err := rc.pinger.Ping(ctx)
+	latency := time.Since(start)
+
+	result := CheckResult{Status: StatusOK, LatencyMs: latency.Milliseconds()}
+	switch {
+	case err != nil:
+		result.Status = StatusUnhealthy
+		result.Error = err.Error()
+	case latency > rc.slowThreshold:
+		result.Status = StatusDegraded
+	}
+	return result
+}
+
+// PoolFreshnessChecker reports pools as unhealthy when even the most recently updated
+// pool is older than maxAge, and degraded when only some pools are
+type PoolFreshnessChecker struct {
+	store  cache.Store
+	maxAge time.Duration
+	now    func() time.Time
+}
+
+func NewPoolFreshnessChecker(store cache.Store, maxAge time.Duration) *PoolFreshnessChecker {
+	return &PoolFreshnessChecker{
+		store:  store,
+		maxAge: maxAge,
+		now:    time.Now,
+	}
+}
+
+func (pc *PoolFreshnessChecker) Check(ctx context.Context) CheckResult {
+	pools, err := pc.store.GetAllPools(ctx)
+	if err != nil {
+		return CheckResult{Status: StatusUnhealthy, Error: err.Error()}
+	}
+	if len(pools) == 0 {
+		return CheckResult{Status: StatusUnhealthy, Error: "no pools loaded"}
+	}
+
+	newest, oldest := pools[0].LastUpdated, pools[0].LastUpdated
+	for _, pool := range pools[1:] {
+		if pool.LastUpdated.After(newest) {
+			newest = pool.LastUpdated
+		}
+		if pool.LastUpdated.Before(oldest) {
+			oldest = pool.LastUpdated
+		}
+	}
+
+	now := pc.now()
+	result := CheckResult{
+		Status:           StatusOK,
+		Count:            len(pools),
+		OldestUpdatedAgo: now.Sub(oldest).Round(time.Second).String(),
+	}
+	switch {
+	case pc.maxAge <= 0:
+		// Freshness is not enforced
+	case now.Sub(newest) > pc.maxAge:
+		result.Status = StatusUnhealthy
+		result.Error = fmt.Sprintf("no pool updated in the last %s", pc.maxAge)
+	case now.Sub(oldest) > pc.maxAge:
+		result.Status = StatusDegraded
+	}
+	return result
+}
+
+// Overall combines check results into "healthy", "degraded" or "unhealthy"
+func Overall(results map[string]CheckResult) string {
+	overall := "healthy"
+	for _, result := range results {
+		switch result.Status {
+		case StatusUnhealthy:
+			return StatusUnhealthy
+		case StatusDegraded:
+			overall = StatusDegraded
+		}
+	}
+	return overall
+}
@@ -0,0 +1,102 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePinger struct {
+	delay time.Duration
+	err   error
+}
+
+func (p *fakePinger) Ping(ctx context.Context) error {
+	time.Sleep(p.delay)
+	return p.err
+}
+
+func storeWithPools(t *testing.T, updated ...time.Time) cache.Store {
+	store := cache.NewMemoryStore()
+	for i, lastUpdated := range updated {
+		pool := &types.Pool{
+			Address:     "pool-" + string(rune('a'+i)),
+			Token0:      types.Token{Address: "0xtokena"},
+			Token1:      types.Token{Address: "0xtokenb"},
+			Reserve0:    big.NewInt(1000),
+			Reserve1:    big.NewInt(1000),
+			LastUpdated: lastUpdated,
+		}
+		assert.NoError(t, store.StorePool(context.Background(), pool))
+	}
+	return store
+}
+
+func TestRedisChecker(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pinger   *fakePinger
+		expected string
+	}{
+		{"Fast", &fakePinger{}, StatusOK},
+		{"Slow", &fakePinger{delay: 20 * time.Millisecond}, StatusDegraded},
+		{"Unreachable", &fakePinger{err: errors.New("connection refused")}, StatusUnhealthy},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := NewRedisChecker(tc.pinger, 10*time.Millisecond).Check(context.Background())
+			assert.Equal(t, tc.expected, result.Status)
+		})
+	}
+}
+
+func TestPoolFreshnessChecker(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name     string
+		updated  []time.Time
+		expected string
+	}{
+		{"Fresh", []time.Time{now.Add(-time.Second), now.Add(-15 * time.Second)}, StatusOK},
+		{"Some stale", []time.Time{now.Add(-time.Second), now.Add(-2 * time.Hour)}, StatusDegraded},
+		{"All stale", []time.Time{now.Add(-2 * time.Hour), now.Add(-3 * time.Hour)}, StatusUnhealthy},
+		{"No pools", nil, StatusUnhealthy},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			checker := NewPoolFreshnessChecker(storeWithPools(t, tc.updated...), time.Hour)
+			checker.now = func() time.Time { return now }
+
+			result := checker.Check(context.Background())
+			assert.Equal(t, tc.expected, result.Status)
+			assert.Equal(t, len(tc.updated), result.Count)
+		})
+	}
+}
+
+func TestPoolFreshnessChecker_OldestUpdatedAgo(t *testing.T) {
+	now := time.Now()
+	checker := NewPoolFreshnessChecker(storeWithPools(t, now.Add(-5*time.Second), now.Add(-15*time.Second)), time.Hour)
+	checker.now = func() time.Time { return now }
+
+	result := checker.Check(context.Background())
+	assert.Equal(t, "15s", result.OldestUpdatedAgo)
+	assert.Equal(t, 2, result.Count)
+}
+
+func TestOverall(t *testing.T) {
+	assert.Equal(t, "healthy", Overall(map[string]CheckResult{}))
+	assert.Equal(t, "healthy", Overall(map[string]CheckResult{"a": {Status: StatusOK}}))
+	assert.Equal(t, StatusDegraded, Overall(map[string]CheckResult{"a": {Status: StatusOK}, "b": {Status: StatusDegraded}}))
+	assert.Equal(t, StatusUnhealthy, Overall(map[string]CheckResult{"a": {Status: StatusDegraded}, "b": {Status: StatusUnhealthy}}))
+}
@@ -0,0 +1,114 @@
+package history
+
+import (
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPoolHistorySize is the number of snapshots kept per pool by a PoolHistory
+// created with a non-positive size
+const DefaultPoolHistorySize = 1000
+
+// ReserveSnapshot is a pool's reserves as of Timestamp
+type ReserveSnapshot struct {
+	Timestamp time.Time
+	Reserve0  *big.Int
+	Reserve1  *big.Int
+}
+
+// snapshotRing keeps one pool's most recent snapshots. snapshots grows as they are
+// recorded, up to the PoolHistory size, and then the oldest is overwritten.
+type snapshotRing struct {
+	snapshots []ReserveSnapshot
+	next      int // Slot the next snapshot is written to once full
+}
+
+// PoolHistory records how the reserves of every pool changed over time
+type PoolHistory struct {
+	mutex sync.RWMutex
+	pools map[string]*snapshotRing // Lowercase pool address -> snapshots
+	size  int
+}
+
+func NewPoolHistory(size int) *PoolHistory {
+	if size <= 0 {
+		size = DefaultPoolHistorySize
+	}
+	return &PoolHistory{
+		pools: make(map[string]*snapshotRing),
+		size:  size,
+	}
+}
+
+// RecordReserves appends the pool's reserves at timestamp. Reserves equal to the
+// latest snapshot are skipped, so repeated stores of an unchanged pool add nothing.
+func (ph *PoolHistory) RecordReserves(address string, r0, r1 *big.Int, timestamp time.Time) {
+	if r0 == nil || r1 == nil {
+		return
+	}
+	key := strings.ToLower(address)
+
+	ph.mutex.Lock()
+	defer ph.mutex.Unlock()
+
+	ring, ok := ph.pools[key]
+	if !ok {
+		ring = &snapshotRing{}
+		ph.pools[key] = ring
+	}
+
+	if n := len(ring.snapshots); n > 0 {
+		latest := ring.snapshots[(ring.next-1+n)%n]
+		if latest.Reserve0.Cmp(r0) == 0 && latest.Reserve1.Cmp(r1) == 0 {
+			return
+		}
+	}
+
+	snapshot := ReserveSnapshot{
+		Timestamp: timestamp,
+		Reserve0:  new(big.Int).Set(r0),
+		Reserve1:  new(big.Int).Set(r1),
+	}
+	if len(ring.snapshots) < ph.size {
+		ring.snapshots = append(ring.snapshots, snapshot)
+		ring.next = len(ring.snapshots) % ph.size
+		return
+	}
+	ring.snapshots[ring.next] = snapshot
+	ring.next = (ring.next + 1) % ph.size
+}
+
+// Remove drops the snapshots of a pool, for pools removed from the store
+func (ph *PoolHistory) Remove(address string) {
+	ph.mutex.Lock()
+	defer ph.mutex.Unlock()
+	delete(ph.pools, strings.ToLower(address))
+}
+
+// Snapshots returns up to limit of the pool's snapshots taken at or after since, newest
+// first. A non-positive limit returns every match and a zero since matches all snapshots.
+func (ph *PoolHistory) Snapshots(address string, since time.Time, limit int) []ReserveSnapshot {
+	ph.mutex.RLock()
+	defer ph.mutex.RUnlock()
+
+	snapshots := []ReserveSnapshot{}
+	ring, ok := ph.pools[strings.ToLower(address)]
+	if !ok {
+		return snapshots
+	}
+
+	n := len(ring.snapshots)
+	for i := 0; i < n; i++ {
+		if limit > 0 && len(snapshots) >= limit {
+			break
+		}
+		snapshot := ring.snapshots[(ring.next-1-i+n)%n]
+		if snapshot.Timestamp.Before(since) {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
@@ -0,0 +1,87 @@
+package history
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func reserve0s(snapshots []ReserveSnapshot) []int64 {
+	out := make([]int64, len(snapshots))
+	for i, s := range snapshots {
+		out[i] = s.Reserve0.Int64()
+	}
+	return out
+}
+
+func TestPoolHistory_Overflow(t *testing.T) {
+	ph := NewPoolHistory(3)
+	start := time.Now()
+
+	for i := 1; i <= 5; i++ {
+		ph.RecordReserves("0xPool", big.NewInt(int64(i)), big.NewInt(100), start.Add(time.Duration(i)*time.Second))
+	}
+
+	// The two oldest snapshots were overwritten; lookups ignore address case
+	assert.Equal(t, []int64{5, 4, 3}, reserve0s(ph.Snapshots("0xpool", time.Time{}, 0)))
+	assert.Equal(t, []int64{5, 4}, reserve0s(ph.Snapshots("0xpool", time.Time{}, 2)))
+	assert.Empty(t, ph.Snapshots("0xother", time.Time{}, 0))
+
+	// A full default-sized history keeps exactly DefaultPoolHistorySize snapshots
+	ph = NewPoolHistory(0)
+	for i := 1; i <= DefaultPoolHistorySize+10; i++ {
+		ph.RecordReserves("0xpool", big.NewInt(int64(i)), big.NewInt(100), start)
+	}
+	snapshots := ph.Snapshots("0xpool", time.Time{}, 0)
+	assert.Len(t, snapshots, DefaultPoolHistorySize)
+	assert.Equal(t, int64(DefaultPoolHistorySize+10), snapshots[0].Reserve0.Int64())
+	assert.Equal(t, int64(11), snapshots[len(snapshots)-1].Reserve0.Int64())
+}
+
+func TestPoolHistory_SinceFilter(t *testing.T) {
+	ph := NewPoolHistory(10)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 1; i <= 5; i++ {
+		ph.RecordReserves("0xpool", big.NewInt(int64(i)), big.NewInt(100), start.Add(time.Duration(i)*time.Minute))
+	}
+
+	assert.Equal(t, []int64{5, 4, 3}, reserve0s(ph.Snapshots("0xpool", start.Add(3*time.Minute), 0)))
+	assert.Equal(t, []int64{5, 4}, reserve0s(ph.Snapshots("0xpool", start.Add(3*time.Minute), 2)))
+	assert.Empty(t, ph.Snapshots("0xpool", start.Add(time.Hour), 0))
+}
+
+func TestPoolHistory_SkipsUnchangedReserves(t *testing.T) {
+	ph := NewPoolHistory(10)
+	now := time.Now()
+	r0, r1 := big.NewInt(1000), big.NewInt(2000)
+
+	ph.RecordReserves("0xpool", r0, r1, now)
+	ph.RecordReserves("0xpool", big.NewInt(1000), big.NewInt(2000), now.Add(time.Second))
+	assert.Len(t, ph.Snapshots("0xpool", time.Time{}, 0), 1)
+
+	// Snapshots hold copies, so later changes to the caller's reserves are not seen
+	r0.SetInt64(1)
+	assert.Equal(t, int64(1000), ph.Snapshots("0xpool", time.Time{}, 0)[0].Reserve0.Int64())
+}
+
+func TestPoolHistory_GrowsAsRecorded(t *testing.T) {
+	ph := NewPoolHistory(0)
+	ph.RecordReserves("0xpool", big.NewInt(1), big.NewInt(100), time.Now())
+
+	// A pool with one snapshot doesn't hold a DefaultPoolHistorySize buffer
+	assert.Less(t, cap(ph.pools["0xpool"].snapshots), DefaultPoolHistorySize)
+}
+
+func TestPoolHistory_Remove(t *testing.T) {
+	ph := NewPoolHistory(10)
+	ph.RecordReserves("0xPool", big.NewInt(1), big.NewInt(100), time.Now())
+	ph.RecordReserves("0xother", big.NewInt(1), big.NewInt(100), time.Now())
+
+	ph.Remove("0xPOOL")
+	assert.Empty(t, ph.Snapshots("0xpool", time.Time{}, 0))
+	assert.NotContains(t, ph.pools, "0xpool")
+	assert.Len(t, ph.Snapshots("0xother", time.Time{}, 0), 1)
+}
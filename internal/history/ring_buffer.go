@@ -0,0 +1,72 @@
+package history
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultSize is the capacity of a RingBuffer created with a non-positive size
+const DefaultSize = 100
+
+// HistoryEntry is one completed quote. Amounts are decimal strings in the tokens'
+// smallest units; failed quotes have no AmountOut and set Error.
+type HistoryEntry struct {
+	Timestamp     time.Time `json:"timestamp"`
+	TokenIn       string    `json:"tokenIn"`
+	TokenOut      string    `json:"tokenOut"`
+	AmountIn      string    `json:"amountIn"`
+	AmountOut     string    `json:"amountOut,omitempty"`
+	BestPathDexes []string  `json:"bestPathDexes,omitempty"`
+	LatencyMs     int64     `json:"latencyMs"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// RingBuffer keeps the most recent quotes, overwriting the oldest once full
+type RingBuffer struct {
+	mutex   sync.RWMutex
+	entries []HistoryEntry
+	next    int // Slot the next entry is written to
+	count   int
+}
+
+func NewRingBuffer(size int) *RingBuffer {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &RingBuffer{entries: make([]HistoryEntry, size)}
+}
+
+// Add records entry, evicting the oldest entry when the buffer is full
+func (rb *RingBuffer) Add(entry HistoryEntry) {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	rb.entries[rb.next] = entry
+	rb.next = (rb.next + 1) % len(rb.entries)
+	if rb.count < len(rb.entries) {
+		rb.count++
+	}
+}
+
+// Recent returns up to n entries, newest first. A non-positive n returns every entry.
+func (rb *RingBuffer) Recent(n int) []HistoryEntry {
+	rb.mutex.RLock()
+	defer rb.mutex.RUnlock()
+
+	if n <= 0 || n > rb.count {
+		n = rb.count
+	}
+
+	recent := make([]HistoryEntry, n)
+	for i := range recent {
+		recent[i] = rb.entries[(rb.next-1-i+len(rb.entries))%len(rb.entries)]
+	}
+	return recent
+}
+
+// Len returns the number of entries held
+func (rb *RingBuffer) Len() int {
+	rb.mutex.RLock()
+	defer rb.mutex.RUnlock()
+	return rb.count
+}
@@ -0,0 +1,76 @@
+package history
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func entry(i int) HistoryEntry {
+	return HistoryEntry{TokenIn: "0xtokena", TokenOut: "0xtokenb", AmountIn: fmt.Sprint(i)}
+}
+
+func amounts(entries []HistoryEntry) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = e.AmountIn
+	}
+	return out
+}
+
+func TestRingBuffer_RecentNewestFirst(t *testing.T) {
+	rb := NewRingBuffer(5)
+	assert.Empty(t, rb.Recent(10))
+
+	for i := 1; i <= 3; i++ {
+		rb.Add(entry(i))
+	}
+
+	assert.Equal(t, 3, rb.Len())
+	assert.Equal(t, []string{"3", "2", "1"}, amounts(rb.Recent(10)))
+	assert.Equal(t, []string{"3", "2"}, amounts(rb.Recent(2)))
+	assert.Equal(t, []string{"3", "2", "1"}, amounts(rb.Recent(0)))
+}
+
+func TestRingBuffer_Overflow(t *testing.T) {
+	rb := NewRingBuffer(0)
+
+	for i := 1; i <= DefaultSize; i++ {
+		rb.Add(entry(i))
+	}
+	recent := rb.Recent(0)
+	assert.Len(t, recent, DefaultSize)
+	assert.Equal(t, "1", recent[DefaultSize-1].AmountIn)
+
+	// The 101st entry evicts the 1st
+	rb.Add(entry(DefaultSize + 1))
+
+	recent = rb.Recent(0)
+	assert.Equal(t, DefaultSize, rb.Len())
+	assert.Len(t, recent, DefaultSize)
+	assert.Equal(t, "101", recent[0].AmountIn)
+	assert.Equal(t, "2", recent[DefaultSize-1].AmountIn)
+	assert.NotContains(t, amounts(recent), "1")
+}
+
+func TestRingBuffer_ConcurrentAccess(t *testing.T) {
+	rb := NewRingBuffer(10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			rb.Add(entry(i))
+		}(i)
+		go func() {
+			defer wg.Done()
+			assert.LessOrEqual(t, len(rb.Recent(5)), 5)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, rb.Len())
+}
@@ -0,0 +1,42 @@
+// Package log provides a structured logger that carries request metadata through
+// contexts, so deep calls can log which request they are serving.
+package log
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Attribute keys set on request contexts
+const (
+	RequestIDKey = "requestID"
+	TokenInKey   = "tokenIn"
+	TokenOutKey  = "tokenOut"
+)
+
+type attrsKey struct{}
+
+// ContextLogger is a slog.Logger carrying the attributes of a context
+type ContextLogger struct {
+	*slog.Logger
+}
+
+// WithContext returns a copy of ctx whose logger adds attrs, given as slog key-value
+// pairs or slog.Attr values, after any attributes already set on ctx
+func WithContext(ctx context.Context, attrs ...any) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]any)
+	combined := make([]any, 0, len(existing)+len(attrs))
+	combined = append(combined, existing...)
+	combined = append(combined, attrs...)
+	return context.WithValue(ctx, attrsKey{}, combined)
+}
+
+// FromContext returns the default slog logger with the attributes set on ctx, such as
+// RequestIDKey, TokenInKey and TokenOutKey
+func FromContext(ctx context.Context) *ContextLogger {
+	logger := slog.Default()
+	if attrs, _ := ctx.Value(attrsKey{}).([]any); len(attrs) > 0 {
+		logger = logger.With(attrs...)
+	}
+	return &ContextLogger{Logger: logger}
+}
@@ -0,0 +1,50 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func captureDefault(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func TestFromContext_IncludesAttributes(t *testing.T) {
+	buf := captureDefault(t)
+
+	ctx := WithContext(context.Background(), RequestIDKey, "req-123")
+	ctx = WithContext(ctx, TokenInKey, "0xtokena", TokenOutKey, "0xtokenb")
+
+	FromContext(ctx).Info("Slippage check passed", "impact", 1.5)
+
+	output := buf.String()
+	assert.Contains(t, output, "msg=\"Slippage check passed\"")
+	assert.Contains(t, output, "requestID=req-123")
+	assert.Contains(t, output, "tokenIn=0xtokena")
+	assert.Contains(t, output, "tokenOut=0xtokenb")
+	assert.Contains(t, output, "impact=1.5")
+}
+
+func TestWithContext_DoesNotModifyParent(t *testing.T) {
+	buf := captureDefault(t)
+
+	parent := WithContext(context.Background(), RequestIDKey, "parent")
+	_ = WithContext(parent, TokenInKey, "0xtokena")
+
+	FromContext(parent).Info("parent only")
+	assert.Contains(t, buf.String(), "requestID=parent")
+	assert.NotContains(t, buf.String(), "tokenIn")
+
+	buf.Reset()
+	FromContext(context.Background()).Info("no attributes")
+	assert.NotContains(t, buf.String(), "requestID")
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// QuoteInFlight is the number of quote requests being calculated
+var QuoteInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "dex_quote_inflight",
+	Help: "Number of quote requests currently being calculated.",
+})
+
+// PoolHealthAlerts counts the alerts raised by the pool health monitor
+var PoolHealthAlerts = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "dex_pool_health_alerts_total",
+	Help: "Number of alerts raised because too few pools were stored.",
+})
+
+func init() {
+	prometheus.MustRegister(QuoteInFlight, PoolHealthAlerts)
+}
+
+// InFlightSource reports the current number of in-flight quotes
+type InFlightSource interface {
+	InFlightQuotes() int64
+}
+
+// DefaultSampleInterval is how often SampleInFlightQuotes updates QuoteInFlight
+const DefaultSampleInterval = time.Second
+
+// SampleInFlightQuotes sets QuoteInFlight from source every interval until ctx is cancelled
+func SampleInFlightQuotes(ctx context.Context, source InFlightSource, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		QuoteInFlight.Set(float64(source.InFlightQuotes()))
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
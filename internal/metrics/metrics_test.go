@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSource struct {
+	value atomic.Int64
+}
+
+func (f *fakeSource) InFlightQuotes() int64 {
+	return f.value.Load()
+}
+
+func TestSampleInFlightQuotes(t *testing.T) {
+	source := &fakeSource{}
+	source.value.Store(7)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		SampleInFlightQuotes(ctx, source, 5*time.Millisecond)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(QuoteInFlight) == 7 }, time.Second, time.Millisecond)
+
+	source.value.Store(2)
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(QuoteInFlight) == 2 }, time.Second, time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sampler did not stop after cancel")
+	}
+}
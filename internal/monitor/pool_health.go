@@ -0,0 +1,67 @@
+// Package monitor watches the pool store in the background and raises alerts
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"dex-aggregator/internal/cache"
+)
+
+// DefaultPoolHealthInterval is how often a PoolHealthMonitor counts the stored pools
+const DefaultPoolHealthInterval = 30 * time.Second
+
+// PoolHealthMonitor alerts when the number of stored pools falls below a minimum, as
+// happens when the collector stops and pools expire from the cache. It alerts once when
+// the count drops and again only after it has recovered and dropped again.
+type PoolHealthMonitor struct {
+	interval time.Duration
+}
+
+// NewPoolHealthMonitor returns a monitor polling every interval, or every
+// DefaultPoolHealthInterval when interval is not positive
+func NewPoolHealthMonitor(interval time.Duration) *PoolHealthMonitor {
+	if interval <= 0 {
+		interval = DefaultPoolHealthInterval
+	}
+	return &PoolHealthMonitor{interval: interval}
+}
+
+// Start counts the pools in store now and on every interval until ctx is cancelled,
+// calling alertFn with a description when fewer than minPools are stored or the pools
+// cannot be loaded. It returns immediately; the polling runs in its own goroutine.
+func (m *PoolHealthMonitor) Start(ctx context.Context, store cache.Store, minPools int, alertFn func(string)) {
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		unhealthy := false
+		for {
+			problem := checkPoolCount(ctx, store, minPools)
+			if problem != "" && !unhealthy && ctx.Err() == nil {
+				alertFn(problem)
+			}
+			unhealthy = problem != ""
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// checkPoolCount describes why store is unhealthy, or returns "" when it holds at
+// least minPools pools
+func checkPoolCount(ctx context.Context, store cache.Store, minPools int) string {
+	pools, err := store.GetAllPools(ctx)
+	if err != nil {
+		return fmt.Sprintf("failed to load pools: %v", err)
+	}
+	if len(pools) < minPools {
+		return fmt.Sprintf("pool count %d is below the minimum of %d", len(pools), minPools)
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/testutil"
+	"dex-aggregator/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPools(n int) []*types.Pool {
+	pools := make([]*types.Pool, n)
+	for i := range pools {
+		pools[i] = testutil.NewPool().
+			WithAddress(fmt.Sprintf("0x%040x", i+1)).
+			WithTokenAddresses(fmt.Sprintf("0x%040x", 100+i), fmt.Sprintf("0x%040x", 200+i)).
+			WithReserves(big.NewInt(1e12), big.NewInt(1e12)).
+			WithFee(300).
+			Build()
+	}
+	return pools
+}
+
+// receiveAlert waits for the next alert, failing the test when none arrives
+func receiveAlert(t *testing.T, alerts <-chan string) string {
+	t.Helper()
+	select {
+	case alert := <-alerts:
+		return alert
+	case <-time.After(time.Second):
+		t.Fatal("no alert raised")
+		return ""
+	}
+}
+
+func TestPoolHealthMonitor_AlertsBelowMinimum(t *testing.T) {
+	store := testutil.NewMemStoreWithPools(testPools(3)...)
+	alerts := make(chan string, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	NewPoolHealthMonitor(5*time.Millisecond).Start(ctx, store, 5, func(alert string) { alerts <- alert })
+
+	assert.Equal(t, "pool count 3 is below the minimum of 5", receiveAlert(t, alerts))
+
+	// A pool count that stays low is only reported once
+	time.Sleep(30 * time.Millisecond)
+	assert.Empty(t, alerts)
+}
+
+// resizableStore serves the first n of its pools
+type resizableStore struct {
+	cache.Store
+	pools []*types.Pool
+	n     atomic.Int32
+}
+
+func (s *resizableStore) GetAllPools(ctx context.Context) ([]*types.Pool, error) {
+	return s.pools[:s.n.Load()], nil
+}
+
+func TestPoolHealthMonitor_AlertsAgainAfterRecovery(t *testing.T) {
+	store := &resizableStore{pools: testPools(5)}
+	store.n.Store(4)
+	alerts := make(chan string, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	NewPoolHealthMonitor(5*time.Millisecond).Start(ctx, store, 5, func(alert string) { alerts <- alert })
+
+	assert.Equal(t, "pool count 4 is below the minimum of 5", receiveAlert(t, alerts))
+
+	store.n.Store(5)
+	time.Sleep(30 * time.Millisecond)
+	assert.Empty(t, alerts)
+
+	store.n.Store(2)
+	assert.Equal(t, "pool count 2 is below the minimum of 5", receiveAlert(t, alerts))
+}
+
+func TestPoolHealthMonitor_HealthyStore(t *testing.T) {
+	store := testutil.NewMemStoreWithPools(testPools(5)...)
+	alerts := make(chan string, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	NewPoolHealthMonitor(5*time.Millisecond).Start(ctx, store, 5, func(alert string) { alerts <- alert })
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Empty(t, alerts)
+}
+
+type failingStore struct {
+	cache.Store
+}
+
+func (failingStore) GetAllPools(ctx context.Context) ([]*types.Pool, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestPoolHealthMonitor_AlertsWhenStoreFails(t *testing.T) {
+	alerts := make(chan string, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	NewPoolHealthMonitor(5*time.Millisecond).Start(ctx, failingStore{}, 5, func(alert string) { alerts <- alert })
+
+	assert.Equal(t, "failed to load pools: connection refused", receiveAlert(t, alerts))
+}
+
+func TestNewPoolHealthMonitor_DefaultInterval(t *testing.T) {
+	assert.Equal(t, DefaultPoolHealthInterval, NewPoolHealthMonitor(0).interval)
+	assert.Equal(t, time.Minute, NewPoolHealthMonitor(time.Minute).interval)
+}
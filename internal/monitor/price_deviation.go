@@ -0,0 +1,135 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"dex-aggregator/internal/aggregator"
+	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/types"
+)
+
+// ErrInvalidPairKey is returned by PriceDeviationMonitor.Check for a pair key that is
+// not two token addresses joined by ":"
+var ErrInvalidPairKey = errors.New("pair key must be tokenA:tokenB")
+
+// DeviationAlert is a pool whose spot price strays from the median of its pair's pools.
+// Prices are of the lower token address in the higher, adjusted for decimals.
+type DeviationAlert struct {
+	PoolAddress      string  `json:"poolAddress"`
+	Exchange         string  `json:"exchange"`
+	PairKey          string  `json:"pairKey"`
+	SpotPrice        float64 `json:"spotPrice"`
+	MedianPrice      float64 `json:"medianPrice"`
+	DeviationPercent float64 `json:"deviationPercent"`
+}
+
+// PriceDeviationMonitor flags pools whose spot price disagrees with the other pools of
+// the same pair, which usually means their reserves are stale or being manipulated
+type PriceDeviationMonitor struct {
+	calculator *aggregator.PriceCalculator
+}
+
+// NewPriceDeviationMonitor returns a PriceDeviationMonitor
+func NewPriceDeviationMonitor() *PriceDeviationMonitor {
+	return &PriceDeviationMonitor{calculator: aggregator.NewPriceCalculator()}
+}
+
+// Check compares each pool of the pair pairKey, "tokenA:tokenB", or of every pair when
+// pairKey is empty, with the median spot price of the pair's pools. It returns an alert
+// for each pool more than thresholdPercent from the median, largest deviation first.
+// Pairs with fewer than two priced pools have nothing to compare and are skipped.
+func (m *PriceDeviationMonitor) Check(ctx context.Context, store cache.Store, pairKey string, thresholdPercent float64) ([]DeviationAlert, error) {
+	var pools []*types.Pool
+	var err error
+	if pairKey == "" {
+		pools, err = store.GetAllPools(ctx)
+	} else {
+		tokens := strings.Split(pairKey, ":")
+		if len(tokens) != 2 || tokens[0] == "" || tokens[1] == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPairKey, pairKey)
+		}
+		pools, err = store.GetPoolsByTokens(ctx, tokens[0], tokens[1])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pools: %w", err)
+	}
+
+	pairs := make(map[string][]*types.Pool)
+	for _, pool := range pools {
+		key := aggregator.PairKey(pool.Token0.Address, pool.Token1.Address)
+		pairs[key] = append(pairs[key], pool)
+	}
+
+	alerts := []DeviationAlert{}
+	for key, pairPools := range pairs {
+		alerts = append(alerts, m.pairDeviations(key, pairPools, thresholdPercent)...)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].DeviationPercent != alerts[j].DeviationPercent {
+			return alerts[i].DeviationPercent > alerts[j].DeviationPercent
+		}
+		return alerts[i].PoolAddress < alerts[j].PoolAddress
+	})
+	return alerts, nil
+}
+
+// pairDeviations returns the alerts for the pools of one pair. Pools without a spot
+// price, such as those with an empty reserve, are left out.
+func (m *PriceDeviationMonitor) pairDeviations(pairKey string, pools []*types.Pool, thresholdPercent float64) []DeviationAlert {
+	base := strings.SplitN(pairKey, ":", 2)[0]
+
+	type pricedPool struct {
+		pool  *types.Pool
+		price float64
+	}
+	var priced []pricedPool
+	for _, pool := range pools {
+		spotPrice, err := m.calculator.NormalisedSpotPrice(pool, base)
+		if err != nil {
+			continue
+		}
+		price, _ := spotPrice.Float64()
+		priced = append(priced, pricedPool{pool: pool, price: price})
+	}
+	if len(priced) < 2 {
+		return nil
+	}
+
+	prices := make([]float64, len(priced))
+	for i, p := range priced {
+		prices[i] = p.price
+	}
+	median := medianOf(prices)
+
+	var alerts []DeviationAlert
+	for _, p := range priced {
+		deviation := math.Abs(p.price-median) / median * 100
+		if deviation <= thresholdPercent {
+			continue
+		}
+		alerts = append(alerts, DeviationAlert{
+			PoolAddress:      p.pool.Address,
+			Exchange:         p.pool.Exchange,
+			PairKey:          pairKey,
+			SpotPrice:        p.price,
+			MedianPrice:      median,
+			DeviationPercent: deviation,
+		})
+	}
+	return alerts
+}
+
+// medianOf returns the median of values, sorting them in place
+func medianOf(values []float64) float64 {
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
@@ -0,0 +1,86 @@
+package monitor
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"dex-aggregator/internal/testutil"
+	"dex-aggregator/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// deviationPools returns three 0xtokena/0xtokenb pools priced at 2, 2 and 2.6, and a
+// single 0xtokena/0xtokenc pool
+func deviationPools() []*types.Pool {
+	pool := func(address, token0, token1 string, reserve0, reserve1 int64) *types.Pool {
+		return testutil.NewPool().WithAddress(address).WithTokenAddresses(token0, token1).
+			WithReserves(big.NewInt(reserve0), big.NewInt(reserve1)).Build()
+	}
+	return []*types.Pool{
+		pool("0xuni", "0xtokena", "0xtokenb", 1e12, 2e12),
+		// The tokens the other way round price 0xtokena alike
+		pool("0xsushi", "0xtokenb", "0xtokena", 2e12, 1e12),
+		// 30% above the other two
+		pool("0xskewed", "0xtokena", "0xtokenb", 1e12, 2.6e12),
+		pool("0xlone", "0xtokena", "0xtokenc", 1e12, 9e12),
+	}
+}
+
+func TestPriceDeviationMonitor_Check(t *testing.T) {
+	store := testutil.NewMemStoreWithPools(deviationPools()...)
+	m := NewPriceDeviationMonitor()
+
+	testCases := []struct {
+		name      string
+		pairKey   string
+		threshold float64
+		expected  []string // alerted pool addresses
+	}{
+		{"every pair", "", 5.0, []string{"0xskewed"}},
+		{"one pair", "0xtokena:0xtokenb", 5.0, []string{"0xskewed"}},
+		{"pair key in either order", "0xTokenB:0xTokenA", 5.0, []string{"0xskewed"}},
+		{"threshold above the deviation", "", 35.0, nil},
+		{"single pool pair", "0xtokena:0xtokenc", 0, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			alerts, err := m.Check(context.Background(), store, tc.pairKey, tc.threshold)
+			assert.NoError(t, err)
+
+			var addresses []string
+			for _, alert := range alerts {
+				addresses = append(addresses, alert.PoolAddress)
+			}
+			assert.Equal(t, tc.expected, addresses)
+		})
+	}
+
+	t.Run("alert details", func(t *testing.T) {
+		alerts, err := m.Check(context.Background(), store, "", 5.0)
+		assert.NoError(t, err)
+		if assert.Len(t, alerts, 1) {
+			assert.Equal(t, "0xtokena:0xtokenb", alerts[0].PairKey)
+			assert.InDelta(t, 2.6, alerts[0].SpotPrice, 1e-9)
+			assert.InDelta(t, 2.0, alerts[0].MedianPrice, 1e-9)
+			assert.InDelta(t, 30.0, alerts[0].DeviationPercent, 1e-9)
+		}
+	})
+}
+
+func TestPriceDeviationMonitor_Check_Errors(t *testing.T) {
+	m := NewPriceDeviationMonitor()
+
+	_, err := m.Check(context.Background(), testutil.NewMemStoreWithPools(), "0xtokena", 5.0)
+	assert.ErrorIs(t, err, ErrInvalidPairKey)
+
+	_, err = m.Check(context.Background(), failingStore{}, "", 5.0)
+	assert.EqualError(t, err, "failed to load pools: connection refused")
+}
+
+func TestMedianOf(t *testing.T) {
+	assert.Equal(t, 2.0, medianOf([]float64{3, 1, 2}))
+	assert.Equal(t, 2.5, medianOf([]float64{4, 1, 3, 2}))
+}
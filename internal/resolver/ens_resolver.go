@@ -0,0 +1,133 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/types"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ensRegistryAddress is the ENS registry, deployed at the same address on mainnet and testnets
+const ensRegistryAddress = "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"
+
+// ensCacheSymbol marks token store entries that cache an ENS resolution. The entry is
+// keyed by the name and holds the resolved address in Name.
+const ensCacheSymbol = "ENS"
+
+const ensABIDefinition = `[
+	{"constant":true,"inputs":[{"name":"node","type":"bytes32"}],"name":"resolver","outputs":[{"name":"","type":"address"}],"type":"function"},
+	{"constant":true,"inputs":[{"name":"node","type":"bytes32"}],"name":"addr","outputs":[{"name":"","type":"address"}],"type":"function"}
+]`
+
+var ensABI = mustParseABI(ensABIDefinition)
+
+var hexAddressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// ErrENSNotFound is returned when a name has no resolver or no address record
+var ErrENSNotFound = errors.New("ENS name not found")
+
+// ENSResolver resolves ENS names such as dai.eth to addresses
+type ENSResolver struct {
+	store    cache.Store
+	caller   ethereum.ContractCaller
+	registry common.Address
+}
+
+func NewENSResolver(store cache.Store, caller ethereum.ContractCaller) *ENSResolver {
+	return &ENSResolver{
+		store:    store,
+		caller:   caller,
+		registry: common.HexToAddress(ensRegistryAddress),
+	}
+}
+
+// Resolve returns nameOrAddress unchanged when it is a hex address and otherwise
+// looks it up in ENS, caching the result in the token store
+func (er *ENSResolver) Resolve(ctx context.Context, nameOrAddress string) (string, error) {
+	if hexAddressPattern.MatchString(nameOrAddress) {
+		return nameOrAddress, nil
+	}
+
+	name := strings.ToLower(strings.TrimSpace(nameOrAddress))
+	if name == "" || !strings.Contains(name, ".") {
+		return "", fmt.Errorf("%w: %q", ErrENSNotFound, nameOrAddress)
+	}
+
+	if cached, err := er.store.GetToken(ctx, name); err == nil && cached.Symbol == ensCacheSymbol {
+		return cached.Name, nil
+	}
+
+	node := namehash(name)
+	resolverAddress, err := er.callAddress(ctx, er.registry, "resolver", node)
+	if err != nil {
+		return "", err
+	}
+	if resolverAddress == (common.Address{}) {
+		return "", fmt.Errorf("%w: %q has no resolver", ErrENSNotFound, name)
+	}
+
+	address, err := er.callAddress(ctx, resolverAddress, "addr", node)
+	if err != nil {
+		return "", err
+	}
+	if address == (common.Address{}) {
+		return "", fmt.Errorf("%w: %q has no address record", ErrENSNotFound, name)
+	}
+
+	resolved := address.Hex()
+	if err := er.store.StoreToken(ctx, &types.Token{Address: name, Symbol: ensCacheSymbol, Name: resolved}); err != nil {
+		log.Printf("ENSResolver: Failed to cache %s: %v", name, err)
+	}
+	return resolved, nil
+}
+
+// callAddress calls a view method taking an ENS node and returning an address
+func (er *ENSResolver) callAddress(ctx context.Context, contract common.Address, method string, node [32]byte) (common.Address, error) {
+	data, err := ensABI.Pack(method, node)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	result, err := er.caller.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("ENS %s() call failed: %v", method, err)
+	}
+
+	values, err := ensABI.Unpack(method, result)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(values) == 0 {
+		return common.Address{}, fmt.Errorf("empty %s() result", method)
+	}
+
+	address, ok := values[0].(common.Address)
+	if !ok {
+		return common.Address{}, fmt.Errorf("unexpected %s() result type %T", method, values[0])
+	}
+	return address, nil
+}
+
+// namehash computes the EIP-137 node of a lowercase name
+func namehash(name string) [32]byte {
+	var node [32]byte
+	if name == "" {
+		return node
+	}
+
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		labelHash := crypto.Keccak256([]byte(labels[i]))
+		copy(node[:], crypto.Keccak256(node[:], labelHash))
+	}
+	return node
+}
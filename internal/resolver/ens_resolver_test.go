@@ -0,0 +1,107 @@
+package resolver
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"dex-aggregator/internal/cache"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// ensCaller answers ENS registry and resolver calls from fixed records
+type ensCaller struct {
+	resolvers map[[32]byte]common.Address // node -> resolver
+	addresses map[[32]byte]common.Address // node -> address record
+	calls     int
+}
+
+func (m *ensCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	m.calls++
+	method, err := ensABI.MethodById(call.Data)
+	if err != nil {
+		return nil, err
+	}
+	args, err := method.Inputs.Unpack(call.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	node := args[0].([32]byte)
+
+	records := m.addresses
+	if method.Name == "resolver" {
+		records = m.resolvers
+	}
+	return method.Outputs.Pack(records[node])
+}
+
+const daiAddress = "0x6B175474E89094C44Da98b954EedeAC495271d0F"
+
+func newENSCaller() *ensCaller {
+	resolverAddress := common.HexToAddress("0x4976fb03C32e5B8cfe2b6cCB31c09Ba78EBaBa41")
+	node := namehash("dai.eth")
+	return &ensCaller{
+		resolvers: map[[32]byte]common.Address{node: resolverAddress},
+		addresses: map[[32]byte]common.Address{node: common.HexToAddress(daiAddress)},
+	}
+}
+
+func TestNamehash(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{"", "0000000000000000000000000000000000000000000000000000000000000000"},
+		{"eth", "93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae"},
+		{"foo.eth", "de9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f"},
+	}
+
+	for _, tc := range testCases {
+		node := namehash(tc.name)
+		assert.Equal(t, tc.expected, hex.EncodeToString(node[:]), "namehash(%q)", tc.name)
+	}
+}
+
+func TestENSResolver_Resolve(t *testing.T) {
+	caller := newENSCaller()
+	store := cache.NewMemoryStore()
+	er := NewENSResolver(store, caller)
+
+	address, err := er.Resolve(context.Background(), "DAI.eth")
+	assert.NoError(t, err)
+	assert.Equal(t, daiAddress, address)
+	assert.Equal(t, 2, caller.calls)
+
+	// The second lookup is served from the token store
+	address, err = er.Resolve(context.Background(), "dai.eth")
+	assert.NoError(t, err)
+	assert.Equal(t, daiAddress, address)
+	assert.Equal(t, 2, caller.calls)
+}
+
+func TestENSResolver_PassesAddressesThrough(t *testing.T) {
+	caller := newENSCaller()
+	er := NewENSResolver(cache.NewMemoryStore(), caller)
+
+	address, err := er.Resolve(context.Background(), wethAddress)
+	assert.NoError(t, err)
+	assert.Equal(t, wethAddress, address)
+	assert.Zero(t, caller.calls)
+}
+
+func TestENSResolver_NotFound(t *testing.T) {
+	caller := newENSCaller()
+	// A name whose resolver has no address record
+	caller.resolvers[namehash("empty.eth")] = common.HexToAddress("0x4976fb03C32e5B8cfe2b6cCB31c09Ba78EBaBa41")
+	er := NewENSResolver(cache.NewMemoryStore(), caller)
+
+	for _, name := range []string{"unknown.eth", "empty.eth", "not-a-name", "0x1234"} {
+		_, err := er.Resolve(context.Background(), name)
+		assert.True(t, errors.Is(err, ErrENSNotFound), "Resolve(%q) = %v", name, err)
+	}
+}
@@ -0,0 +1,122 @@
+package resolver
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"dex-aggregator/internal/cache"
+)
+
+// DefaultSymbolCacheTTL is how long a SymbolCache created with a non-positive TTL keeps
+// its symbols
+const DefaultSymbolCacheTTL = 5 * time.Minute
+
+// SymbolCache maps token addresses to the symbols of the pools' tokens, so handlers can
+// name tokens without scanning every pool
+type SymbolCache struct {
+	store cache.Store
+	ttl   time.Duration
+	now   func() time.Time
+
+	mutex      sync.Mutex
+	symbols    map[string]string // Lowercase token address -> symbol
+	unknown    map[string]bool   // Lowercase addresses no pool named, remembered until expires
+	expires    time.Time
+	loads      int           // Successful refreshes so far
+	refreshing chan struct{} // Closed when the refresh in flight ends; nil without one
+}
+
+func NewSymbolCache(store cache.Store, ttl time.Duration) *SymbolCache {
+	if ttl <= 0 {
+		ttl = DefaultSymbolCacheTTL
+	}
+	return &SymbolCache{
+		store: store,
+		ttl:   ttl,
+		now:   time.Now,
+	}
+}
+
+// Resolve returns the symbol of the token at address, or the address shortened to
+// 0x1234...abcd when no pool names it. The symbols are rebuilt from the store once the
+// TTL has passed, and sooner for an address not seen before, which a pool stored since
+// the last refresh may have brought. An address still unnamed after that is remembered
+// until the TTL passes, so it costs one refresh however often it is asked for.
+func (sc *SymbolCache) Resolve(ctx context.Context, address string) string {
+	key := strings.ToLower(address)
+
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	expired := sc.symbols == nil || !sc.now().Before(sc.expires)
+	if expired || (sc.symbols[key] == "" && !sc.unknown[key]) {
+		loads := sc.loads
+		sc.refresh(ctx)
+		if sc.symbols[key] == "" && sc.loads > loads {
+			sc.unknown[key] = true
+		}
+	}
+	if symbol := sc.symbols[key]; symbol != "" {
+		return symbol
+	}
+	return truncateAddress(address)
+}
+
+// refresh rebuilds the symbols from every stored pool. It is called with the mutex held
+// and releases it while the pools are fetched, so lookups that need no refresh are not
+// held up; callers that need one while it is in flight wait for it rather than start
+// another. Unknown addresses are forgotten only when the TTL has passed. On failure the
+// previous symbols are kept and the next Resolve tries again.
+func (sc *SymbolCache) refresh(ctx context.Context) {
+	if done := sc.refreshing; done != nil {
+		sc.mutex.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		sc.mutex.Lock()
+		return
+	}
+
+	done := make(chan struct{})
+	sc.refreshing = done
+	sc.mutex.Unlock()
+	pools, err := sc.store.GetAllPools(ctx)
+	sc.mutex.Lock()
+	sc.refreshing = nil
+	close(done)
+
+	if err != nil {
+		log.Printf("Failed to refresh token symbols: %v", err)
+		return
+	}
+
+	symbols := make(map[string]string)
+	for _, pool := range pools {
+		for _, token := range []struct{ address, symbol string }{
+			{pool.Token0.Address, pool.Token0.Symbol},
+			{pool.Token1.Address, pool.Token1.Symbol},
+		} {
+			if token.symbol != "" {
+				symbols[strings.ToLower(token.address)] = token.symbol
+			}
+		}
+	}
+	sc.symbols = symbols
+	sc.loads++
+	if now := sc.now(); sc.unknown == nil || !now.Before(sc.expires) {
+		sc.unknown = make(map[string]bool)
+		sc.expires = now.Add(sc.ttl)
+	}
+}
+
+// truncateAddress shortens a hex address to its first and last four digits
+func truncateAddress(address string) string {
+	if len(address) <= 12 {
+		return address
+	}
+	return address[:6] + "..." + address[len(address)-4:]
+}
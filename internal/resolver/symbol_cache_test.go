@@ -0,0 +1,175 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const usdtAddress = "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+
+// countingStore counts the GetAllPools calls a SymbolCache makes
+type countingStore struct {
+	*cache.MemoryStore
+	calls int
+	err   error
+}
+
+func (s *countingStore) GetAllPools(ctx context.Context) ([]*types.Pool, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.MemoryStore.GetAllPools(ctx)
+}
+
+func storeSymbolPool(t *testing.T, store cache.Store, address string, token0, token1 types.Token) {
+	t.Helper()
+	require.NoError(t, store.StorePool(context.Background(), &types.Pool{
+		Address:  address,
+		Exchange: "Uniswap V2",
+		Token0:   token0,
+		Token1:   token1,
+		Reserve0: big.NewInt(1000000),
+		Reserve1: big.NewInt(1000000),
+	}))
+}
+
+func newSymbolCacheFixture(t *testing.T) (*SymbolCache, *countingStore, *time.Time) {
+	store := &countingStore{MemoryStore: cache.NewMemoryStore()}
+	storeSymbolPool(t, store, "0xpool1", types.Token{Address: wethAddress, Symbol: "WETH"}, types.Token{Address: usdtAddress, Symbol: "USDT"})
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sc := NewSymbolCache(store, time.Minute)
+	sc.now = func() time.Time { return now }
+	return sc, store, &now
+}
+
+func TestSymbolCache_Hit(t *testing.T) {
+	sc, store, _ := newSymbolCacheFixture(t)
+	ctx := context.Background()
+
+	assert.Equal(t, "WETH", sc.Resolve(ctx, wethAddress))
+	assert.Equal(t, 1, store.calls)
+
+	// Cached, in any case
+	assert.Equal(t, "USDT", sc.Resolve(ctx, usdtAddress))
+	assert.Equal(t, "WETH", sc.Resolve(ctx, "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"))
+	assert.Equal(t, 1, store.calls)
+}
+
+func TestSymbolCache_Miss(t *testing.T) {
+	sc, store, _ := newSymbolCacheFixture(t)
+	ctx := context.Background()
+	dai := "0x6B175474E89094C44Da98b954EedeAC495271d0F"
+
+	assert.Equal(t, "WETH", sc.Resolve(ctx, wethAddress))
+
+	// An address not seen before refreshes the cache once, then is remembered as unknown
+	assert.Equal(t, "0x6B17...1d0F", sc.Resolve(ctx, dai))
+	assert.Equal(t, 2, store.calls)
+	assert.Equal(t, "0x6B17...1d0F", sc.Resolve(ctx, dai))
+	assert.Equal(t, 2, store.calls)
+
+	// A pool stored since is found by the next unseen address
+	storeSymbolPool(t, store, "0xpool2", types.Token{Address: dai, Symbol: "DAI"}, types.Token{Address: "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984", Symbol: "UNI"})
+	assert.Equal(t, "UNI", sc.Resolve(ctx, "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984"))
+	assert.Equal(t, "DAI", sc.Resolve(ctx, dai))
+	assert.Equal(t, 3, store.calls)
+}
+
+func TestSymbolCache_UnknownKeptUntilTTL(t *testing.T) {
+	sc, store, now := newSymbolCacheFixture(t)
+	ctx := context.Background()
+	first, second := "0x1111111111111111111111111111111111111111", "0x2222222222222222222222222222222222222222"
+
+	assert.Equal(t, "WETH", sc.Resolve(ctx, wethAddress))
+
+	// Each unknown address refreshes once; asking for them in turn does not rescan
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "0x1111...1111", sc.Resolve(ctx, first))
+		assert.Equal(t, "0x2222...2222", sc.Resolve(ctx, second))
+	}
+	assert.Equal(t, 3, store.calls)
+
+	// The TTL clears them along with the symbols
+	*now = now.Add(time.Minute)
+	assert.Equal(t, "0x1111...1111", sc.Resolve(ctx, first))
+	assert.Equal(t, "0x2222...2222", sc.Resolve(ctx, second))
+	assert.Equal(t, 5, store.calls)
+}
+
+// blockingStore holds GetAllPools until release is closed
+type blockingStore struct {
+	*cache.MemoryStore
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStore) GetAllPools(ctx context.Context) ([]*types.Pool, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return s.MemoryStore.GetAllPools(ctx)
+}
+
+func TestSymbolCache_LookupsDuringRefresh(t *testing.T) {
+	store := &blockingStore{MemoryStore: cache.NewMemoryStore(), started: make(chan struct{}, 1), release: make(chan struct{})}
+	storeSymbolPool(t, store, "0xpool1", types.Token{Address: wethAddress, Symbol: "WETH"}, types.Token{Address: usdtAddress, Symbol: "USDT"})
+	sc := NewSymbolCache(store, time.Minute)
+	ctx := context.Background()
+
+	close(store.release)
+	assert.Equal(t, "WETH", sc.Resolve(ctx, wethAddress))
+	<-store.started
+	store.release = make(chan struct{})
+
+	// An unseen address starts a refresh that blocks in the store
+	dai := "0x6B175474E89094C44Da98b954EedeAC495271d0F"
+	resolved := make(chan string)
+	go func() { resolved <- sc.Resolve(ctx, dai) }()
+	<-store.started
+
+	// Cached symbols are still served meanwhile
+	assert.Equal(t, "USDT", sc.Resolve(ctx, usdtAddress))
+
+	close(store.release)
+	assert.Equal(t, "0x6B17...1d0F", <-resolved)
+}
+
+func TestSymbolCache_TTLExpiry(t *testing.T) {
+	sc, store, now := newSymbolCacheFixture(t)
+	ctx := context.Background()
+
+	assert.Equal(t, "WETH", sc.Resolve(ctx, wethAddress))
+
+	// The symbol changed in the store, but the cached one is served until the TTL passes
+	storeSymbolPool(t, store, "0xpool1", types.Token{Address: wethAddress, Symbol: "WETH9"}, types.Token{Address: usdtAddress, Symbol: "USDT"})
+	*now = now.Add(59 * time.Second)
+	assert.Equal(t, "WETH", sc.Resolve(ctx, wethAddress))
+	assert.Equal(t, 1, store.calls)
+
+	*now = now.Add(time.Second)
+	assert.Equal(t, "WETH9", sc.Resolve(ctx, wethAddress))
+	assert.Equal(t, 2, store.calls)
+}
+
+func TestSymbolCache_RefreshError(t *testing.T) {
+	sc, store, now := newSymbolCacheFixture(t)
+	ctx := context.Background()
+
+	assert.Equal(t, "WETH", sc.Resolve(ctx, wethAddress))
+
+	// Failed refreshes keep the previous symbols
+	store.err = errors.New("connection refused")
+	*now = now.Add(time.Hour)
+	assert.Equal(t, "WETH", sc.Resolve(ctx, wethAddress))
+	assert.Equal(t, "0x1234", sc.Resolve(ctx, "0x1234"))
+}
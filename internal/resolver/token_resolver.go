@@ -0,0 +1,210 @@
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/types"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const erc20MetadataABI = `[
+	{"constant":true,"inputs":[],"name":"name","outputs":[{"name":"","type":"string"}],"type":"function"},
+	{"constant":true,"inputs":[],"name":"symbol","outputs":[{"name":"","type":"string"}],"type":"function"}
+]`
+
+var erc20ABI = mustParseABI(erc20MetadataABI)
+
+// Backoff between failed token list downloads, doubling from min up to max
+const (
+	tokenListRetryMin = time.Second
+	tokenListRetryMax = 5 * time.Minute
+)
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(fmt.Sprintf("invalid ABI: %v", err))
+	}
+	return parsed
+}
+
+// tokenListEntry is a token in the Uniswap token list format
+type tokenListEntry struct {
+	ChainID  int64  `json:"chainId"`
+	Address  string `json:"address"`
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+	LogoURI  string `json:"logoURI"`
+}
+
+type tokenList struct {
+	Tokens []tokenListEntry `json:"tokens"`
+}
+
+// TokenResolver enriches token metadata from the chain and a token list
+type TokenResolver struct {
+	store        cache.Store
+	caller       ethereum.ContractCaller // nil disables on-chain lookups
+	tokenListURL string                  // empty disables logo lookups
+	httpClient   *http.Client
+
+	listMu      sync.Mutex
+	list        map[string]tokenListEntry // nil until a download succeeds
+	listRetry   time.Duration             // backoff after the last failed download
+	listRetryAt time.Time                 // no download is attempted before this
+}
+
+func NewTokenResolver(store cache.Store, caller ethereum.ContractCaller, tokenListURL string) *TokenResolver {
+	return &TokenResolver{
+		store:        store,
+		caller:       caller,
+		tokenListURL: tokenListURL,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Resolve returns token metadata, fetching and caching it on a cache miss
+func (tr *TokenResolver) Resolve(ctx context.Context, address string) (*types.Token, error) {
+	address = strings.ToLower(address)
+
+	cached, err := tr.store.GetToken(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if cached.Name != "" {
+		return cached, nil
+	}
+
+	token := *cached
+	token.Address = address
+
+	if tr.caller != nil {
+		if name, err := tr.callString(ctx, address, "name"); err != nil {
+			log.Printf("TokenResolver: Failed to read name() of %s: %v", address, err)
+		} else {
+			token.Name = name
+		}
+		if symbol, err := tr.callString(ctx, address, "symbol"); err != nil {
+			log.Printf("TokenResolver: Failed to read symbol() of %s: %v", address, err)
+		} else {
+			token.Symbol = symbol
+		}
+	}
+
+	if entry, ok := tr.lookupTokenList(address); ok {
+		token.LogoURI = entry.LogoURI
+		if token.Name == "" {
+			token.Name = entry.Name
+		}
+		if token.Symbol == "" || token.Symbol == "UNKNOWN" {
+			token.Symbol = entry.Symbol
+			token.Decimals = entry.Decimals
+		}
+	}
+
+	if token.Name == "" {
+		// Nothing learned, don't cache so a later call can retry
+		return &token, nil
+	}
+
+	if err := tr.store.StoreToken(ctx, &token); err != nil {
+		log.Printf("TokenResolver: Failed to cache token %s: %v", address, err)
+	}
+
+	return &token, nil
+}
+
+// callString calls a no-argument ERC-20 view method returning a string
+func (tr *TokenResolver) callString(ctx context.Context, address, method string) (string, error) {
+	data, err := erc20ABI.Pack(method)
+	if err != nil {
+		return "", err
+	}
+
+	to := common.HexToAddress(address)
+	result, err := tr.caller.CallContract(ctx, ethereum.CallMsg{To: &to, Data: data}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	values, err := erc20ABI.Unpack(method, result)
+	if err != nil {
+		return "", err
+	}
+	if len(values) == 0 {
+		return "", fmt.Errorf("empty %s() result", method)
+	}
+
+	value, ok := values[0].(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected %s() result type %T", method, values[0])
+	}
+	return value, nil
+}
+
+// lookupTokenList finds a token in the token list, downloading the list on first use.
+// The download is not tied to any caller's context; a failed one is retried by a later
+// lookup after an exponential backoff.
+func (tr *TokenResolver) lookupTokenList(address string) (tokenListEntry, bool) {
+	if tr.tokenListURL == "" {
+		return tokenListEntry{}, false
+	}
+
+	tr.listMu.Lock()
+	defer tr.listMu.Unlock()
+
+	if tr.list == nil && !time.Now().Before(tr.listRetryAt) {
+		list, err := tr.fetchTokenList(context.Background())
+		if err != nil {
+			tr.listRetry = min(max(2*tr.listRetry, tokenListRetryMin), tokenListRetryMax)
+			tr.listRetryAt = time.Now().Add(tr.listRetry)
+			log.Printf("TokenResolver: Failed to fetch token list from %s, retrying in %v: %v", tr.tokenListURL, tr.listRetry, err)
+		} else {
+			tr.list = list
+			log.Printf("TokenResolver: Loaded %d tokens from %s", len(list), tr.tokenListURL)
+		}
+	}
+
+	entry, ok := tr.list[address]
+	return entry, ok
+}
+
+func (tr *TokenResolver) fetchTokenList(ctx context.Context) (map[string]tokenListEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tr.tokenListURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := tr.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var list tokenList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]tokenListEntry, len(list.Tokens))
+	for _, entry := range list.Tokens {
+		entries[strings.ToLower(entry.Address)] = entry
+	}
+	return entries, nil
+}
@@ -0,0 +1,140 @@
+package resolver
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dex-aggregator/internal/cache"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockCaller answers ERC-20 name()/symbol() calls with fixed values
+type mockCaller struct {
+	name   string
+	symbol string
+	calls  int
+}
+
+func (m *mockCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	m.calls++
+	method, err := erc20ABI.MethodById(call.Data)
+	if err != nil {
+		return nil, err
+	}
+	value := m.name
+	if method.Name == "symbol" {
+		value = m.symbol
+	}
+	return method.Outputs.Pack(value)
+}
+
+const wethAddress = "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"
+
+func newTokenListServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"tokens":[{"chainId":1,"address":"0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2","name":"Wrapped Ether","symbol":"WETH","decimals":18,"logoURI":"https://example.com/weth.png"}]}`))
+	}))
+}
+
+func TestTokenResolver_ResolveOnChainAndTokenList(t *testing.T) {
+	server := newTokenListServer(t)
+	defer server.Close()
+
+	store := cache.NewMemoryStore()
+	caller := &mockCaller{name: "Wrapped Ether", symbol: "WETH"}
+	tr := NewTokenResolver(store, caller, server.URL)
+
+	token, err := tr.Resolve(context.Background(), wethAddress)
+	assert.NoError(t, err)
+	assert.Equal(t, "Wrapped Ether", token.Name)
+	assert.Equal(t, "WETH", token.Symbol)
+	assert.Equal(t, "https://example.com/weth.png", token.LogoURI)
+	assert.Equal(t, 2, caller.calls)
+
+	// Enriched token is cached in the store
+	cached, err := store.GetToken(context.Background(), wethAddress)
+	assert.NoError(t, err)
+	assert.Equal(t, "Wrapped Ether", cached.Name)
+
+	// Second resolve is served from the store without on-chain calls
+	_, err = tr.Resolve(context.Background(), wethAddress)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, caller.calls)
+}
+
+func TestTokenResolver_TokenListOnly(t *testing.T) {
+	server := newTokenListServer(t)
+	defer server.Close()
+
+	tr := NewTokenResolver(cache.NewMemoryStore(), nil, server.URL)
+
+	token, err := tr.Resolve(context.Background(), wethAddress)
+	assert.NoError(t, err)
+	assert.Equal(t, "Wrapped Ether", token.Name)
+	assert.Equal(t, "WETH", token.Symbol)
+	assert.Equal(t, 18, token.Decimals)
+}
+
+func TestTokenResolver_TokenListRetriedAfterFailure(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"tokens":[{"chainId":1,"address":"0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2","name":"Wrapped Ether","symbol":"WETH","decimals":18}]}`))
+	}))
+	defer server.Close()
+
+	tr := NewTokenResolver(cache.NewMemoryStore(), nil, server.URL)
+
+	token, err := tr.Resolve(context.Background(), wethAddress)
+	assert.NoError(t, err)
+	assert.Equal(t, "", token.Name)
+	assert.Equal(t, 1, requests)
+
+	// Within the backoff the list is not downloaded again
+	token, err = tr.Resolve(context.Background(), wethAddress)
+	assert.NoError(t, err)
+	assert.Equal(t, "", token.Name)
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, tokenListRetryMin, tr.listRetry)
+
+	// Once the backoff has passed it is, independently of the caller's context
+	tr.listRetryAt = time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	token, err = tr.Resolve(ctx, wethAddress)
+	assert.NoError(t, err)
+	assert.Equal(t, "Wrapped Ether", token.Name)
+	assert.Equal(t, 2, requests)
+}
+
+func TestTokenResolver_NoSources(t *testing.T) {
+	store := cache.NewMemoryStore()
+	tr := NewTokenResolver(store, nil, "")
+
+	token, err := tr.Resolve(context.Background(), wethAddress)
+	assert.NoError(t, err)
+	assert.Equal(t, "", token.Name)
+	assert.Equal(t, "UNKNOWN", token.Symbol)
+}
+
+func TestERC20ABI_Selectors(t *testing.T) {
+	data, err := erc20ABI.Pack("name")
+	assert.NoError(t, err)
+	assert.Equal(t, "06fdde03", hex.EncodeToString(data))
+
+	data, err = erc20ABI.Pack("symbol")
+	assert.NoError(t, err)
+	assert.Equal(t, "95d89b41", hex.EncodeToString(data))
+}
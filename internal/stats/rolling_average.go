@@ -0,0 +1,55 @@
+package stats
+
+import "sync"
+
+// DefaultWindowSize is the number of samples averaged by a RollingAverage created with
+// a non-positive window size
+const DefaultWindowSize = 100
+
+// RollingAverage is the mean of the most recent samples, overwriting the oldest once the
+// window is full. Samples are int64 nanoseconds, as recorded from a time.Duration.
+type RollingAverage struct {
+	mutex   sync.Mutex
+	samples []int64
+	next    int // Slot the next sample is written to
+	count   int
+	sum     int64
+}
+
+func NewRollingAverage(windowSize int) *RollingAverage {
+	if windowSize <= 0 {
+		windowSize = DefaultWindowSize
+	}
+	return &RollingAverage{samples: make([]int64, windowSize)}
+}
+
+// Add records sample, evicting the oldest sample when the window is full
+func (ra *RollingAverage) Add(sample int64) {
+	ra.mutex.Lock()
+	defer ra.mutex.Unlock()
+
+	ra.sum += sample - ra.samples[ra.next]
+	ra.samples[ra.next] = sample
+	ra.next = (ra.next + 1) % len(ra.samples)
+	if ra.count < len(ra.samples) {
+		ra.count++
+	}
+}
+
+// Average returns the mean of the samples in the window, or 0 before the first sample
+func (ra *RollingAverage) Average() float64 {
+	ra.mutex.Lock()
+	defer ra.mutex.Unlock()
+
+	if ra.count == 0 {
+		return 0
+	}
+	return float64(ra.sum) / float64(ra.count)
+}
+
+// Len returns the number of samples in the window
+func (ra *RollingAverage) Len() int {
+	ra.mutex.Lock()
+	defer ra.mutex.Unlock()
+	return ra.count
+}
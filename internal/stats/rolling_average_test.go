@@ -0,0 +1,54 @@
+package stats
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollingAverage(t *testing.T) {
+	ra := NewRollingAverage(3)
+	assert.Zero(t, ra.Average())
+	assert.Zero(t, ra.Len())
+
+	ra.Add(10)
+	ra.Add(20)
+	assert.Equal(t, 15.0, ra.Average())
+	assert.Equal(t, 2, ra.Len())
+
+	ra.Add(30)
+	assert.Equal(t, 20.0, ra.Average())
+
+	// The window is full, so 10 is evicted
+	ra.Add(40)
+	assert.Equal(t, 30.0, ra.Average())
+	assert.Equal(t, 3, ra.Len())
+}
+
+func TestRollingAverage_DefaultWindowSize(t *testing.T) {
+	ra := NewRollingAverage(0)
+	for i := 0; i < DefaultWindowSize+10; i++ {
+		ra.Add(int64(i))
+	}
+	assert.Equal(t, DefaultWindowSize, ra.Len())
+}
+
+func TestRollingAverage_ConcurrentAdd(t *testing.T) {
+	ra := NewRollingAverage(1000)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				ra.Add(5)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1000, ra.Len())
+	assert.Equal(t, 5.0, ra.Average())
+}
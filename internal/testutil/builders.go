@@ -0,0 +1,164 @@
+// Package testutil builds tokens, pools and stores for tests
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"dex-aggregator/internal/cache"
+	"dex-aggregator/internal/types"
+)
+
+// TokenBuilder builds a types.Token
+type TokenBuilder struct {
+	token types.Token
+}
+
+// NewToken starts a token with the given address and 18 decimals
+func NewToken(address string) *TokenBuilder {
+	return &TokenBuilder{token: types.Token{Address: address, Decimals: 18}}
+}
+
+func (b *TokenBuilder) WithSymbol(symbol string) *TokenBuilder {
+	b.token.Symbol = symbol
+	return b
+}
+
+func (b *TokenBuilder) WithName(name string) *TokenBuilder {
+	b.token.Name = name
+	return b
+}
+
+func (b *TokenBuilder) WithDecimals(decimals int) *TokenBuilder {
+	b.token.Decimals = decimals
+	return b
+}
+
+func (b *TokenBuilder) Build() types.Token {
+	return b.token
+}
+
+// PoolBuilder builds a *types.Pool
+type PoolBuilder struct {
+	pool types.Pool
+}
+
+// NewPool starts an empty Uniswap V2 pool; set at least its tokens and reserves
+func NewPool() *PoolBuilder {
+	return &PoolBuilder{pool: types.Pool{Exchange: "Uniswap V2", Version: "v2"}}
+}
+
+func (b *PoolBuilder) WithAddress(address string) *PoolBuilder {
+	b.pool.Address = address
+	return b
+}
+
+func (b *PoolBuilder) WithChainID(chainID int64) *PoolBuilder {
+	b.pool.ChainID = chainID
+	return b
+}
+
+func (b *PoolBuilder) WithExchange(exchange string) *PoolBuilder {
+	b.pool.Exchange = exchange
+	return b
+}
+
+func (b *PoolBuilder) WithVersion(version string) *PoolBuilder {
+	b.pool.Version = version
+	return b
+}
+
+func (b *PoolBuilder) WithTokens(token0, token1 types.Token) *PoolBuilder {
+	b.pool.Token0, b.pool.Token1 = token0, token1
+	return b
+}
+
+// WithTokenAddresses sets tokens that have only an address
+func (b *PoolBuilder) WithTokenAddresses(token0, token1 string) *PoolBuilder {
+	b.pool.Token0, b.pool.Token1 = types.Token{Address: token0}, types.Token{Address: token1}
+	return b
+}
+
+// WithReserves sets the reserves; Build copies them, so the arguments may be reused
+func (b *PoolBuilder) WithReserves(reserve0, reserve1 *big.Int) *PoolBuilder {
+	b.pool.Reserve0, b.pool.Reserve1 = reserve0, reserve1
+	return b
+}
+
+func (b *PoolBuilder) WithFee(fee int) *PoolBuilder {
+	b.pool.Fee = fee
+	return b
+}
+
+func (b *PoolBuilder) WithLastUpdated(lastUpdated time.Time) *PoolBuilder {
+	b.pool.LastUpdated = lastUpdated
+	return b
+}
+
+func (b *PoolBuilder) WithReserveUpdatedAt(reserveUpdatedAt time.Time) *PoolBuilder {
+	b.pool.ReserveUpdatedAt = reserveUpdatedAt
+	return b
+}
+
+func (b *PoolBuilder) WithPaused(paused bool) *PoolBuilder {
+	b.pool.Paused = paused
+	return b
+}
+
+// Build returns a new pool on every call, so one builder can produce several pools
+func (b *PoolBuilder) Build() *types.Pool {
+	pool := b.pool
+	pool.Reserve0 = copyBigInt(b.pool.Reserve0)
+	pool.Reserve1 = copyBigInt(b.pool.Reserve1)
+	return &pool
+}
+
+// ExchangeBuilder builds a types.Exchange
+type ExchangeBuilder struct {
+	exchange types.Exchange
+}
+
+// NewExchange starts a V2 exchange with the given name
+func NewExchange(name string) *ExchangeBuilder {
+	return &ExchangeBuilder{exchange: types.Exchange{Name: name, Version: "v2"}}
+}
+
+func (b *ExchangeBuilder) WithFactory(factory string) *ExchangeBuilder {
+	b.exchange.Factory = factory
+	return b
+}
+
+func (b *ExchangeBuilder) WithRouter(router string) *ExchangeBuilder {
+	b.exchange.Router = router
+	return b
+}
+
+func (b *ExchangeBuilder) WithVersion(version string) *ExchangeBuilder {
+	b.exchange.Version = version
+	return b
+}
+
+func (b *ExchangeBuilder) Build() types.Exchange {
+	return b.exchange
+}
+
+// NewMemStoreWithPools returns a MemoryStore holding pools. It panics if a pool is
+// rejected, as that is a mistake in the test rather than the code under test.
+func NewMemStoreWithPools(pools ...*types.Pool) cache.Store {
+	store := cache.NewMemoryStore()
+	for _, pool := range pools {
+		if err := store.StorePool(context.Background(), pool); err != nil {
+			panic(fmt.Sprintf("testutil: failed to store pool %s: %v", pool.Address, err))
+		}
+	}
+	return store
+}
+
+func copyBigInt(n *big.Int) *big.Int {
+	if n == nil {
+		return nil
+	}
+	return new(big.Int).Set(n)
+}
@@ -0,0 +1,55 @@
+package testutil
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolBuilder(t *testing.T) {
+	weth := NewToken("0xweth").WithSymbol("WETH").Build()
+	usdt := NewToken("0xusdt").WithSymbol("USDT").WithDecimals(6).Build()
+	reserve := big.NewInt(1000)
+
+	builder := NewPool().WithTokens(weth, usdt).WithReserves(reserve, reserve).WithFee(300)
+	first := builder.WithAddress("pool1").Build()
+	second := builder.WithAddress("pool2").Build()
+
+	assert.Equal(t, "pool1", first.Address)
+	assert.Equal(t, "pool2", second.Address)
+	assert.Equal(t, "Uniswap V2", first.Exchange)
+	assert.Equal(t, 300, first.Fee)
+	assert.Equal(t, 18, first.Token0.Decimals)
+	assert.Equal(t, 6, first.Token1.Decimals)
+
+	// Built pools share no reserves with each other or the arguments
+	first.Reserve0.SetInt64(1)
+	assert.Equal(t, "1000", second.Reserve0.String())
+	assert.Equal(t, "1000", reserve.String())
+}
+
+func TestExchangeBuilder(t *testing.T) {
+	exchange := NewExchange("SushiSwap").WithFactory("0xfactory").WithRouter("0xrouter").Build()
+
+	assert.Equal(t, "SushiSwap", exchange.Name)
+	assert.Equal(t, "0xfactory", exchange.Factory)
+	assert.Equal(t, "0xrouter", exchange.Router)
+	assert.Equal(t, "v2", exchange.Version)
+}
+
+func TestNewMemStoreWithPools(t *testing.T) {
+	store := NewMemStoreWithPools(
+		NewPool().WithAddress("pool1").WithTokenAddresses("0xa", "0xb").WithReserves(big.NewInt(1), big.NewInt(2)).Build(),
+		NewPool().WithAddress("pool2").WithTokenAddresses("0xb", "0xc").WithReserves(big.NewInt(3), big.NewInt(4)).Build(),
+	)
+
+	pools, err := store.GetAllPools(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, pools, 2)
+
+	pool, err := store.GetPool(context.Background(), "pool2")
+	assert.NoError(t, err)
+	assert.Equal(t, "4", pool.Reserve1.String())
+}
@@ -0,0 +1,83 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Canonical fee tiers, a percentage with at least two decimals. The number in each
+// name is the fee in basis points.
+const (
+	FeeTierV2_30   = "0.30%"
+	FeeTierV3_5    = "0.05%"
+	FeeTierV3_30   = "0.30%"
+	FeeTierV3_100  = "1.00%"
+	FeeTierCurve_4 = "0.04%"
+)
+
+// feeUnitsPerBasisPoint is how many units of Pool.Fee make a basis point for each pool
+// type. V2 pools count thousandths of a percent (300 is 0.3%), V3 pools hundredths of a
+// basis point as their fee() does, and Curve pools the 1e10 precision of its fee().
+var feeUnitsPerBasisPoint = map[string]int{
+	"v2":    10,
+	"v3":    100,
+	"curve": 1000000,
+}
+
+// FeeTierToFee returns the fee in basis points of a tier such as "0.30%". Tiers that
+// are not a whole number of basis points between 0% and 100% are an error.
+func FeeTierToFee(tier string) (int, error) {
+	value, ok := strings.CutSuffix(strings.TrimSpace(tier), "%")
+	if !ok {
+		return 0, fmt.Errorf("invalid fee tier %q: missing %%", tier)
+	}
+	percent, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(percent) || percent < 0 || percent > 100 {
+		return 0, fmt.Errorf("invalid fee tier %q", tier)
+	}
+
+	basisPoints := percent * 100
+	rounded := math.Round(basisPoints)
+	if math.Abs(basisPoints-rounded) > 1e-9 {
+		return 0, fmt.Errorf("invalid fee tier %q: not a whole number of basis points", tier)
+	}
+	return int(rounded), nil
+}
+
+// FeeToFeeTier formats a Pool.Fee as a tier, reading it in the units of poolType ("v2",
+// "v3" or "curve", case-insensitive). Other pool types are read as V2, as FeeDetector
+// assumes for them. A non-positive fee has no tier and returns "".
+func FeeToFeeTier(fee int, poolType string) string {
+	if fee <= 0 {
+		return ""
+	}
+	unitsPerBasisPoint := feeUnits(poolType)
+
+	if fee%unitsPerBasisPoint == 0 {
+		basisPoints := fee / unitsPerBasisPoint
+		return fmt.Sprintf("%d.%02d%%", basisPoints/100, basisPoints%100)
+	}
+	// Fractions of a basis point need more than two decimals
+	percent := float64(fee) / float64(unitsPerBasisPoint*100)
+	return strconv.FormatFloat(percent, 'f', -1, 64) + "%"
+}
+
+// FeeToBasisPoints converts a Pool.Fee into basis points, reading it in the units of
+// poolType as FeeToFeeTier does. Fractions of a basis point are rounded.
+func FeeToBasisPoints(fee int, poolType string) int {
+	if fee <= 0 {
+		return 0
+	}
+	return int(math.Round(float64(fee) / float64(feeUnits(poolType))))
+}
+
+// feeUnits returns the units of Pool.Fee per basis point for poolType, defaulting to V2
+func feeUnits(poolType string) int {
+	unitsPerBasisPoint, ok := feeUnitsPerBasisPoint[strings.ToLower(poolType)]
+	if !ok {
+		return feeUnitsPerBasisPoint["v2"]
+	}
+	return unitsPerBasisPoint
+}
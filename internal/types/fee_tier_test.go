@@ -0,0 +1,99 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeeTierToFee(t *testing.T) {
+	testCases := []struct {
+		tier        string
+		expected    int
+		expectError bool
+	}{
+		{FeeTierV2_30, 30, false},
+		{FeeTierV3_5, 5, false},
+		{FeeTierV3_30, 30, false},
+		{FeeTierV3_100, 100, false},
+		{FeeTierCurve_4, 4, false},
+		{"0%", 0, false},
+		{" 0.3% ", 30, false},
+		{"100%", 10000, false},
+		{"0.30", 0, true},    // No percent sign
+		{"abc%", 0, true},    // Not a number
+		{"-0.30%", 0, true},  // Negative
+		{"100.01%", 0, true}, // Above 100%
+		{"0.015%", 0, true},  // Fraction of a basis point
+		{"", 0, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.tier, func(t *testing.T) {
+			fee, err := FeeTierToFee(tc.tier)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, fee)
+		})
+	}
+}
+
+func TestFeeToFeeTier(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fee      int
+		poolType string
+		expected string
+	}{
+		{"V2", 300, "v2", FeeTierV2_30},
+		{"V2 half fee", 150, "v2", "0.15%"},
+		{"V3 0.05%", 500, "v3", FeeTierV3_5},
+		{"V3 0.30%", 3000, "V3", FeeTierV3_30},
+		{"V3 1%", 10000, "v3", FeeTierV3_100},
+		{"V3 0.01%", 100, "v3", "0.01%"},
+		{"Curve", 4000000, "curve", FeeTierCurve_4},
+		{"Fraction of a basis point", 150000, "curve", "0.0015%"},
+		{"Unknown type read as V2", 300, "balancer", FeeTierV2_30},
+		{"No fee", 0, "v2", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, FeeToFeeTier(tc.fee, tc.poolType))
+		})
+	}
+}
+
+func TestFeeToBasisPoints(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fee      int
+		poolType string
+		expected int
+	}{
+		{"V2", 300, "v2", 30},
+		{"V3 0.05%", 500, "V3", 5},
+		{"Curve", 4000000, "curve", 4},
+		{"Fraction of a basis point rounds", 150000, "curve", 0},
+		{"Unknown type read as V2", 250, "balancer", 25},
+		{"No fee", 0, "v2", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, FeeToBasisPoints(tc.fee, tc.poolType))
+		})
+	}
+}
+
+func TestFeeTierRoundTrip(t *testing.T) {
+	// A V3 fee in hundredths of a basis point survives the trip through its tier
+	for _, fee := range []int{100, 500, 3000, 10000} {
+		basisPoints, err := FeeTierToFee(FeeToFeeTier(fee, "v3"))
+		assert.NoError(t, err)
+		assert.Equal(t, fee/100, basisPoints)
+	}
+}
@@ -3,28 +3,140 @@ package types
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"math/big"
+	"strings"
 	"time"
 )
 
+// DefaultChainID is used for pools that do not specify a chain (Ethereum mainnet)
+const DefaultChainID int64 = 1
+
 // Token information
 type Token struct {
 	Address  string `json:"address" bson:"address"`
 	Symbol   string `json:"symbol" bson:"symbol"`
+	Name     string `json:"name,omitempty" bson:"name,omitempty"`
 	Decimals int    `json:"decimals" bson:"decimals"`
+	LogoURI  string `json:"logoURI,omitempty" bson:"logo_uri,omitempty"`
 }
 
 // Liquidity pool
 type Pool struct {
-	Address     string    `json:"address" bson:"address"`
-	Exchange    string    `json:"exchange" bson:"exchange"`
-	Version     string    `json:"version" bson:"version"`
-	Token0      Token     `json:"token0" bson:"token0"`
-	Token1      Token     `json:"token1" bson:"token1"`
-	Reserve0    *big.Int  `json:"reserve0" bson:"reserve0"`
-	Reserve1    *big.Int  `json:"reserve1" bson:"reserve1"`
-	Fee         int       `json:"fee" bson:"fee"`
-	LastUpdated time.Time `json:"last_updated" bson:"last_updated"`
+	Address  string   `json:"address" bson:"address"`
+	ChainID  int64    `json:"chain_id" bson:"chain_id"`
+	Exchange string   `json:"exchange" bson:"exchange"`
+	Version  string   `json:"version" bson:"version"`
+	Token0   Token    `json:"token0" bson:"token0"`
+	Token1   Token    `json:"token1" bson:"token1"`
+	Reserve0 *big.Int `json:"reserve0" bson:"reserve0"`
+	Reserve1 *big.Int `json:"reserve1" bson:"reserve1"`
+	Fee      int      `json:"fee" bson:"fee"`
+	// FeeTier is Fee as a percentage such as FeeTierV3_5, comparable across pool types
+	FeeTier string `json:"fee_tier,omitempty" bson:"fee_tier,omitempty"`
+	// LPFeeBps and ProtocolFeeBps split the swap fee between liquidity providers and the
+	// protocol, such as 25 and 5 for a Uniswap V2 pool with the fee switch on. Both zero
+	// means unknown, read as DefaultSwapFeeBps to LPs.
+	LPFeeBps       int       `json:"lp_fee_bps,omitempty" bson:"lp_fee_bps,omitempty"`
+	ProtocolFeeBps int       `json:"protocol_fee_bps,omitempty" bson:"protocol_fee_bps,omitempty"`
+	LastUpdated    time.Time `json:"last_updated" bson:"last_updated"`
+	CreatedAt      time.Time `json:"created_at" bson:"created_at"` // When the pool was first stored
+	// ReserveUpdatedAt is when Reserve0 and Reserve1 were last written, unlike LastUpdated
+	// which also tracks metadata changes. Zero means unknown.
+	ReserveUpdatedAt time.Time `json:"reserve_updated_at" bson:"reserve_updated_at"`
+	// Paused pools are kept in the cache but excluded from routing. It is the only
+	// routing flag: IsActive is derived from it rather than stored alongside it.
+	Paused bool `json:"paused,omitempty" bson:"paused,omitempty"`
+
+	// Uniswap V3 state, zero for constant-product pools
+	TickSpacing  int      `json:"tick_spacing,omitempty" bson:"tick_spacing,omitempty"`
+	SqrtPriceX96 *big.Int `json:"sqrt_price_x96,omitempty" bson:"sqrt_price_x96,omitempty"`
+	TickCurrent  int32    `json:"tick_current,omitempty" bson:"tick_current,omitempty"`
+	Liquidity    *big.Int `json:"liquidity,omitempty" bson:"liquidity,omitempty"`
+
+	// Volume24h is the last 24 hours of swap volume in token0 units, set on graph refresh
+	Volume24h *big.Int `json:"volume_24h,omitempty" bson:"volume_24h,omitempty"`
+}
+
+// DefaultSwapFeeBps is the swap fee of pools without LPFeeBps or ProtocolFeeBps, 0.30%
+const DefaultSwapFeeBps = 30
+
+// TotalFeeBps returns the swap fee taken from the input, LPFeeBps plus ProtocolFeeBps.
+// The protocol share is paid out of the same fee, so it does not change the output.
+func (p *Pool) TotalFeeBps() int {
+	if p.LPFeeBps == 0 && p.ProtocolFeeBps == 0 {
+		return DefaultSwapFeeBps
+	}
+	return p.LPFeeBps + p.ProtocolFeeBps
+}
+
+// IsActive reports whether the pool takes part in routing. Pools are active unless an
+// operator paused them, so pools decoded or collected without the flag stay routable.
+func (p *Pool) IsActive() bool {
+	return !p.Paused
+}
+
+// SortedTokens returns the pool's tokens ordered by lowercase address, lower first, so
+// a pair is indexed the same way whichever token the collector made Token0
+func (p *Pool) SortedTokens() (lower, higher *Token) {
+	if strings.ToLower(p.Token1.Address) < strings.ToLower(p.Token0.Address) {
+		return &p.Token1, &p.Token0
+	}
+	return &p.Token0, &p.Token1
+}
+
+// PoolUpdate is a partial pool update; nil and zero fields are left unchanged
+type PoolUpdate struct {
+	Paused      *bool
+	Reserve0    *big.Int
+	Reserve1    *big.Int
+	LastUpdated time.Time
+}
+
+// Apply sets the fields of p given in u, stamping ReserveUpdatedAt when reserves change
+func (u *PoolUpdate) Apply(p *Pool) {
+	if u.Paused != nil {
+		p.Paused = *u.Paused
+	}
+	if u.Reserve0 != nil {
+		p.Reserve0 = new(big.Int).Set(u.Reserve0)
+	}
+	if u.Reserve1 != nil {
+		p.Reserve1 = new(big.Int).Set(u.Reserve1)
+	}
+	if u.Reserve0 != nil || u.Reserve1 != nil {
+		p.ReserveUpdatedAt = time.Now()
+	}
+	if !u.LastUpdated.IsZero() {
+		p.LastUpdated = u.LastUpdated
+	}
+}
+
+// LiquidityScoreMaxAge is the pool age at which LiquidityScore decays to zero
+var LiquidityScoreMaxAge = time.Hour
+
+// LiquidityScore rates the pool's depth and freshness at the current time
+func (p *Pool) LiquidityScore() float64 {
+	return p.LiquidityScoreAt(time.Now(), LiquidityScoreMaxAge)
+}
+
+// LiquidityScoreAt returns log10(sqrt(Reserve0*Reserve1)) * max(0, 1 - age/maxAge), where
+// age is measured from LastUpdated to now. Pools without reserves score 0; a non-positive
+// maxAge disables the freshness factor.
+func (p *Pool) LiquidityScoreAt(now time.Time, maxAge time.Duration) float64 {
+	if p.Reserve0 == nil || p.Reserve1 == nil || p.Reserve0.Sign() <= 0 || p.Reserve1.Sign() <= 0 {
+		return 0
+	}
+
+	freshness := 1.0
+	if maxAge > 0 {
+		freshness = math.Max(0, 1-now.Sub(p.LastUpdated).Seconds()/maxAge.Seconds())
+	}
+
+	// log10(sqrt(r0*r1)) = (log10(r0) + log10(r1)) / 2, which avoids the product overflowing float64
+	r0, _ := new(big.Float).SetInt(p.Reserve0).Float64()
+	r1, _ := new(big.Float).SetInt(p.Reserve1).Float64()
+	return (math.Log10(r0) + math.Log10(r1)) / 2 * freshness
 }
 
 // DEX exchange configuration
@@ -41,6 +153,18 @@ type QuoteRequest struct {
 	TokenOut string   `json:"tokenOut"`
 	AmountIn *big.Int `json:"amountIn"`
 	MaxHops  int      `json:"maxHops,omitempty"`
+	// GasPriceGwei ranks paths by output net of gas when non-zero
+	GasPriceGwei uint64 `json:"gasPriceGwei,omitempty"`
+	// DiversifyDEX keeps at most one path per exchange sequence
+	DiversifyDEX bool `json:"diversifyDEX,omitempty"`
+	// RiskAversion weights price impact against output when ranking paths:
+	// 0 ignores it, 1 counts each percent of impact as a percent of output
+	RiskAversion float64 `json:"riskAversion,omitempty"`
+	// GasToken is the address of the token gas is paid in; when set, the response
+	// carries the gas cost converted into it. WETH stands for ETH.
+	GasToken string `json:"gasToken,omitempty"`
+	// MaxSlippage drops paths whose SlippagePercent exceeds it, in percent; 0 keeps every path
+	MaxSlippage float64 `json:"maxSlippage,omitempty"`
 }
 
 // UnmarshalJSON custom unmarshaler for QuoteRequest to handle big.Int
@@ -81,26 +205,296 @@ func (q *QuoteRequest) MarshalJSON() ([]byte, error) {
 	})
 }
 
+// MultiQuoteFractionTotal is the sum of a MultiQuoteRequest's target fractions, which are
+// in basis points
+const MultiQuoteFractionTotal = 10000
+
+// MaxMultiQuoteTargets is the largest number of targets a MultiQuoteRequest may split into
+const MaxMultiQuoteTargets = 10
+
+// MultiQuoteTarget is one output of a MultiQuoteRequest, receiving Fraction basis points
+// of the input
+type MultiQuoteTarget struct {
+	TokenOut string `json:"tokenOut"`
+	Fraction uint   `json:"fraction"`
+}
+
+// MultiQuoteRequest splits one input amount across several output tokens
+type MultiQuoteRequest struct {
+	TokenIn  string             `json:"tokenIn"`
+	AmountIn *big.Int           `json:"amountIn"`
+	Targets  []MultiQuoteTarget `json:"targets"`
+	MaxHops  int                `json:"maxHops,omitempty"`
+}
+
+// UnmarshalJSON custom unmarshaler for MultiQuoteRequest to handle big.Int
+func (m *MultiQuoteRequest) UnmarshalJSON(data []byte) error {
+	type Alias MultiQuoteRequest
+	aux := &struct {
+		AmountIn string `json:"amountIn"`
+		*Alias
+	}{
+		Alias: (*Alias)(m),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	if aux.AmountIn != "" {
+		amount, ok := new(big.Int).SetString(aux.AmountIn, 10)
+		if !ok {
+			return fmt.Errorf("invalid amountIn format: %s", aux.AmountIn)
+		}
+		m.AmountIn = amount
+	}
+
+	return nil
+}
+
+// MultiQuoteResponse holds one quote per target of a MultiQuoteRequest, in target order
+type MultiQuoteResponse struct {
+	Legs             []*QuoteResponse `json:"legs"`
+	TotalGasEstimate *big.Int         `json:"totalGasEstimate"`
+}
+
+// MarshalJSON custom marshaler for MultiQuoteResponse to handle big.Int
+func (m *MultiQuoteResponse) MarshalJSON() ([]byte, error) {
+	type Alias MultiQuoteResponse
+	return json.Marshal(&struct {
+		TotalGasEstimate string `json:"totalGasEstimate"`
+		*Alias
+	}{
+		TotalGasEstimate: m.TotalGasEstimate.String(),
+		Alias:            (*Alias)(m),
+	})
+}
+
+// MaxSimulateAmounts is the largest number of amounts a SimulateRequest may price
+const MaxSimulateAmounts = 20
+
+// SimulateRequest asks for quotes at several input amounts of the same pair
+type SimulateRequest struct {
+	TokenIn  string     `json:"tokenIn"`
+	TokenOut string     `json:"tokenOut"`
+	Amounts  []*big.Int `json:"amounts"`
+}
+
+// UnmarshalJSON custom unmarshaler for SimulateRequest to handle big.Int amounts
+func (s *SimulateRequest) UnmarshalJSON(data []byte) error {
+	type Alias SimulateRequest
+	aux := &struct {
+		Amounts []string `json:"amounts"`
+		*Alias
+	}{
+		Alias: (*Alias)(s),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	s.Amounts = make([]*big.Int, len(aux.Amounts))
+	for i, value := range aux.Amounts {
+		amount, ok := new(big.Int).SetString(value, 10)
+		if !ok {
+			return fmt.Errorf("invalid amounts format: %s", value)
+		}
+		s.Amounts[i] = amount
+	}
+
+	return nil
+}
+
+// SimulatePoint is the best quote for one amount of a SimulateRequest
+type SimulatePoint struct {
+	AmountIn    *big.Int `json:"amountIn"`
+	AmountOut   *big.Int `json:"amountOut,omitempty"`
+	PriceImpact string   `json:"priceImpact,omitempty"` // Percentage of the best path
+	Error       string   `json:"error,omitempty"`       // Set instead of the output when quoting failed
+}
+
+// MarshalJSON custom marshaler for SimulatePoint to handle big.Int
+func (p *SimulatePoint) MarshalJSON() ([]byte, error) {
+	type Alias SimulatePoint
+	var amountOut string
+	if p.AmountOut != nil {
+		amountOut = p.AmountOut.String()
+	}
+	return json.Marshal(&struct {
+		AmountIn  string `json:"amountIn"`
+		AmountOut string `json:"amountOut,omitempty"`
+		*Alias
+	}{
+		AmountIn:  p.AmountIn.String(),
+		AmountOut: amountOut,
+		Alias:     (*Alias)(p),
+	})
+}
+
+// SimulateResponse is the price curve of a SimulateRequest, in request order
+type SimulateResponse struct {
+	Curve []*SimulatePoint `json:"curve"`
+}
+
+// DEXComparison is the best quote through the pools of a single exchange
+type DEXComparison struct {
+	Exchange    string   `json:"exchange"`
+	AmountOut   *big.Int `json:"amountOut,omitempty"`
+	PriceImpact string   `json:"priceImpact,omitempty"` // Percentage of the best path
+	Error       string   `json:"error,omitempty"`       // Set instead of the output when the exchange has no route
+}
+
+// MarshalJSON custom marshaler for DEXComparison to handle big.Int
+func (c *DEXComparison) MarshalJSON() ([]byte, error) {
+	type Alias DEXComparison
+	var amountOut string
+	if c.AmountOut != nil {
+		amountOut = c.AmountOut.String()
+	}
+	return json.Marshal(&struct {
+		AmountOut string `json:"amountOut,omitempty"`
+		*Alias
+	}{
+		AmountOut: amountOut,
+		Alias:     (*Alias)(c),
+	})
+}
+
+// DEXComparisonResponse compares the quotes of every configured exchange for one trade
+type DEXComparisonResponse struct {
+	Comparisons []*DEXComparison `json:"comparisons"`
+	// BestExchange has the most output, and is empty when no exchange has a route
+	BestExchange string `json:"bestExchange,omitempty"`
+}
+
+// QuoteExplanation describes in plain language why a quote's best path was chosen
+type QuoteExplanation struct {
+	Summary     string            `json:"summary"`
+	PathDetails []*HopExplanation `json:"pathDetails"`
+	WhyBestPath string            `json:"whyBestPath"`
+	PriceImpact string            `json:"priceImpact"`
+}
+
+// HopExplanation is one pool of an explained path. Amounts are decimal strings in the
+// tokens' smallest units.
+type HopExplanation struct {
+	Pool      string `json:"pool"`
+	Exchange  string `json:"exchange"`
+	TokenIn   string `json:"tokenIn"`
+	TokenOut  string `json:"tokenOut"`
+	Fee       int    `json:"fee"`
+	AmountIn  string `json:"amountIn"`
+	AmountOut string `json:"amountOut"`
+}
+
+// PoolActiveRequest switches a pool in or out of routing
+type PoolActiveRequest struct {
+	Active *bool `json:"active"`
+}
+
+// ReserveDeltaRequest adjusts a pool's reserves by signed amounts
+type ReserveDeltaRequest struct {
+	Delta0 *big.Int `json:"delta0"`
+	Delta1 *big.Int `json:"delta1"`
+}
+
+// UnmarshalJSON custom unmarshaler for ReserveDeltaRequest to handle signed big.Int deltas
+func (d *ReserveDeltaRequest) UnmarshalJSON(data []byte) error {
+	aux := &struct {
+		Delta0 string `json:"delta0"`
+		Delta1 string `json:"delta1"`
+	}{}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	for _, field := range []struct {
+		name  string
+		value string
+		dest  **big.Int
+	}{
+		{"delta0", aux.Delta0, &d.Delta0},
+		{"delta1", aux.Delta1, &d.Delta1},
+	} {
+		if field.value == "" {
+			continue
+		}
+		delta, ok := new(big.Int).SetString(field.value, 10)
+		if !ok {
+			return fmt.Errorf("invalid %s format: %s", field.name, field.value)
+		}
+		*field.dest = delta
+	}
+
+	return nil
+}
+
+// ReserveUpdate is one pool of a bulk reserve update. Reserves are decimal strings; an
+// empty reserve or zero LastUpdated leaves the pool's value unchanged.
+type ReserveUpdate struct {
+	Address     string    `json:"address"`
+	Reserve0    string    `json:"reserve0,omitempty"`
+	Reserve1    string    `json:"reserve1,omitempty"`
+	LastUpdated time.Time `json:"lastUpdated,omitempty"`
+}
+
+// PoolUpdate parses the update's reserves, which must be non-negative integers
+func (u *ReserveUpdate) PoolUpdate() (*PoolUpdate, error) {
+	update := &PoolUpdate{LastUpdated: u.LastUpdated}
+	for _, field := range []struct {
+		name  string
+		value string
+		dest  **big.Int
+	}{
+		{"reserve0", u.Reserve0, &update.Reserve0},
+		{"reserve1", u.Reserve1, &update.Reserve1},
+	} {
+		if field.value == "" {
+			continue
+		}
+		reserve, ok := new(big.Int).SetString(field.value, 10)
+		if !ok || reserve.Sign() < 0 {
+			return nil, fmt.Errorf("invalid %s for pool %s: %s", field.name, u.Address, field.value)
+		}
+		*field.dest = reserve
+	}
+	return update, nil
+}
+
 // QuoteResponse response for price quote
 type QuoteResponse struct {
-	AmountOut      *big.Int     `json:"amountOut"`
-	Paths          []*TradePath `json:"paths"`
-	BestPath       *TradePath   `json:"bestPath"`
-	GasEstimate    *big.Int     `json:"gasEstimate"`
-	ProcessingTime int64        `json:"processingTime,omitempty"` // Processing time in milliseconds
+	AmountOut       *big.Int     `json:"amountOut"`
+	Paths           []*TradePath `json:"paths"`
+	BestPath        *TradePath   `json:"bestPath"`
+	GasEstimate     *big.Int     `json:"gasEstimate"`
+	ExecutionPrice  string       `json:"executionPrice,omitempty"`  // tokenOut per tokenIn, adjusted for decimals
+	ProcessingTime  int64        `json:"processingTime,omitempty"`  // Processing time in milliseconds
+	MaxHopsAdjusted bool         `json:"maxHopsAdjusted,omitempty"` // Requested maxHops exceeded the server limit
+	PartialResult   bool         `json:"partialResult,omitempty"`   // The path search timed out, so better paths may exist
+	// GasCostInGasToken is the gas cost in base units of the requested GasToken, when
+	// the pool graph can price it
+	GasCostInGasToken *big.Int `json:"-"`
 }
 
 // MarshalJSON custom marshaler for QuoteResponse to handle big.Int
 func (q *QuoteResponse) MarshalJSON() ([]byte, error) {
 	type Alias QuoteResponse
+	var gasCostInGasToken string
+	if q.GasCostInGasToken != nil {
+		gasCostInGasToken = q.GasCostInGasToken.String()
+	}
 	return json.Marshal(&struct {
-		AmountOut   string `json:"amountOut"`
-		GasEstimate string `json:"gasEstimate"`
+		AmountOut         string `json:"amountOut"`
+		GasEstimate       string `json:"gasEstimate"`
+		GasCostInGasToken string `json:"gasCostInGasToken,omitempty"`
 		*Alias
 	}{
-		AmountOut:   q.AmountOut.String(),
-		GasEstimate: q.GasEstimate.String(),
-		Alias:       (*Alias)(q),
+		AmountOut:         q.AmountOut.String(),
+		GasEstimate:       q.GasEstimate.String(),
+		GasCostInGasToken: gasCostInGasToken,
+		Alias:             (*Alias)(q),
 	})
 }
 
@@ -108,8 +502,9 @@ func (q *QuoteResponse) MarshalJSON() ([]byte, error) {
 func (q *QuoteResponse) UnmarshalJSON(data []byte) error {
 	type Alias QuoteResponse
 	aux := &struct {
-		AmountOut   string `json:"amountOut"`
-		GasEstimate string `json:"gasEstimate"`
+		AmountOut         string `json:"amountOut"`
+		GasEstimate       string `json:"gasEstimate"`
+		GasCostInGasToken string `json:"gasCostInGasToken"`
 		*Alias
 	}{
 		Alias: (*Alias)(q),
@@ -128,6 +523,14 @@ func (q *QuoteResponse) UnmarshalJSON(data []byte) error {
 		q.AmountOut = amountOut
 	}
 
+	if aux.GasCostInGasToken != "" {
+		gasCost, ok := new(big.Int).SetString(aux.GasCostInGasToken, 10)
+		if !ok {
+			return fmt.Errorf("invalid gasCostInGasToken format: %s", aux.GasCostInGasToken)
+		}
+		q.GasCostInGasToken = gasCost
+	}
+
 	if aux.GasEstimate != "" {
 		gasEstimate, ok := new(big.Int).SetString(aux.GasEstimate, 10)
 		if !ok {
@@ -142,36 +545,179 @@ func (q *QuoteResponse) UnmarshalJSON(data []byte) error {
 // TradePath trading path
 type TradePath struct {
 	Pools     []*Pool  `json:"pools"`
+	AmountIn  *big.Int `json:"amountIn"` // Input routed through this path
 	AmountOut *big.Int `json:"amountOut"`
 	Dexes     []string `json:"dexes"`
 	GasCost   *big.Int `json:"gasCost"`
+	// PriceImpact is the percentage shortfall of AmountOut against the spot price
+	PriceImpact float64 `json:"priceImpact,omitempty"`
+	// SlippagePercent is the sum of the PriceImpact of each hop, the slippage a path
+	// accumulates across its pools
+	SlippagePercent float64 `json:"slippagePercent"`
+	// NetAmountOut is AmountOut minus gas cost in wei and the slippage penalty, set when
+	// a gas price or risk aversion is given
+	NetAmountOut *big.Int `json:"netAmountOut,omitempty"`
+	// HopDetails is the amount that flowed through each pool, in path order
+	HopDetails []HopDetail `json:"hopDetails,omitempty"`
+}
+
+// IsWithinSlippageTolerance reports whether the path's SlippagePercent is at most
+// maxSlippage percent
+func (t *TradePath) IsWithinSlippageTolerance(maxSlippage float64) bool {
+	return t.SlippagePercent <= maxSlippage
+}
+
+// HopDetail is one pool of a trade path and the amounts it swapped
+type HopDetail struct {
+	PoolAddress string   `json:"poolAddress"`
+	TokenIn     string   `json:"tokenIn"`
+	TokenOut    string   `json:"tokenOut"`
+	AmountIn    *big.Int `json:"amountIn"`
+	AmountOut   *big.Int `json:"amountOut"`
+	// PriceImpact is the percentage shortfall of AmountOut against the pool's spot price
+	PriceImpact float64 `json:"priceImpact"`
+}
+
+// MarshalJSON custom marshaler for HopDetail to handle big.Int
+func (h *HopDetail) MarshalJSON() ([]byte, error) {
+	type Alias HopDetail
+	return json.Marshal(&struct {
+		AmountIn  string `json:"amountIn"`
+		AmountOut string `json:"amountOut"`
+		*Alias
+	}{
+		AmountIn:  h.AmountIn.String(),
+		AmountOut: h.AmountOut.String(),
+		Alias:     (*Alias)(h),
+	})
+}
+
+// UnmarshalJSON custom unmarshaler for HopDetail to handle big.Int
+func (h *HopDetail) UnmarshalJSON(data []byte) error {
+	type Alias HopDetail
+	aux := &struct {
+		AmountIn  string `json:"amountIn"`
+		AmountOut string `json:"amountOut"`
+		*Alias
+	}{
+		Alias: (*Alias)(h),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	for _, field := range []struct {
+		name  string
+		value string
+		dest  **big.Int
+	}{
+		{"amountIn", aux.AmountIn, &h.AmountIn},
+		{"amountOut", aux.AmountOut, &h.AmountOut},
+	} {
+		if field.value == "" {
+			continue
+		}
+		value, ok := new(big.Int).SetString(field.value, 10)
+		if !ok {
+			return fmt.Errorf("invalid %s format: %s", field.name, field.value)
+		}
+		*field.dest = value
+	}
+
+	return nil
 }
 
 // MarshalJSON custom marshaler for TradePath to handle big.Int
 func (t *TradePath) MarshalJSON() ([]byte, error) {
 	type Alias TradePath
+	var netAmountOut string
+	if t.NetAmountOut != nil {
+		netAmountOut = t.NetAmountOut.String()
+	}
 	return json.Marshal(&struct {
-		AmountOut string `json:"amountOut"`
-		GasCost   string `json:"gasCost"`
+		AmountIn     string `json:"amountIn"`
+		AmountOut    string `json:"amountOut"`
+		GasCost      string `json:"gasCost"`
+		NetAmountOut string `json:"netAmountOut,omitempty"`
 		*Alias
 	}{
-		AmountOut: t.AmountOut.String(),
-		GasCost:   t.GasCost.String(),
-		Alias:     (*Alias)(t),
+		AmountIn:     t.AmountIn.String(),
+		AmountOut:    t.AmountOut.String(),
+		GasCost:      t.GasCost.String(),
+		NetAmountOut: netAmountOut,
+		Alias:        (*Alias)(t),
 	})
 }
 
+// UnmarshalJSON custom unmarshaler for TradePath to handle big.Int
+func (t *TradePath) UnmarshalJSON(data []byte) error {
+	type Alias TradePath
+	aux := &struct {
+		AmountIn     string `json:"amountIn"`
+		AmountOut    string `json:"amountOut"`
+		GasCost      string `json:"gasCost"`
+		NetAmountOut string `json:"netAmountOut"`
+		*Alias
+	}{
+		Alias: (*Alias)(t),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	// Convert strings to big.Int
+	for _, field := range []struct {
+		name  string
+		value string
+		dest  **big.Int
+	}{
+		{"amountIn", aux.AmountIn, &t.AmountIn},
+		{"amountOut", aux.AmountOut, &t.AmountOut},
+		{"gasCost", aux.GasCost, &t.GasCost},
+		{"netAmountOut", aux.NetAmountOut, &t.NetAmountOut},
+	} {
+		if field.value == "" {
+			continue
+		}
+		value, ok := new(big.Int).SetString(field.value, 10)
+		if !ok {
+			return fmt.Errorf("invalid %s format: %s", field.name, field.value)
+		}
+		*field.dest = value
+	}
+
+	return nil
+}
+
 // MarshalJSON custom marshaler for Pool to handle big.Int
 func (p *Pool) MarshalJSON() ([]byte, error) {
 	type Alias Pool
+	var sqrtPriceX96, liquidity, volume24h string
+	if p.SqrtPriceX96 != nil {
+		sqrtPriceX96 = p.SqrtPriceX96.String()
+	}
+	if p.Liquidity != nil {
+		liquidity = p.Liquidity.String()
+	}
+	if p.Volume24h != nil {
+		volume24h = p.Volume24h.String()
+	}
 	return json.Marshal(&struct {
-		Reserve0 string `json:"reserve0"`
-		Reserve1 string `json:"reserve1"`
+		Reserve0     string `json:"reserve0"`
+		Reserve1     string `json:"reserve1"`
+		SqrtPriceX96 string `json:"sqrt_price_x96,omitempty"`
+		Liquidity    string `json:"liquidity,omitempty"`
+		Volume24h    string `json:"volume_24h,omitempty"`
 		*Alias
 	}{
-		Reserve0: p.Reserve0.String(),
-		Reserve1: p.Reserve1.String(),
-		Alias:    (*Alias)(p),
+		Reserve0:     p.Reserve0.String(),
+		Reserve1:     p.Reserve1.String(),
+		SqrtPriceX96: sqrtPriceX96,
+		Liquidity:    liquidity,
+		Volume24h:    volume24h,
+		Alias:        (*Alias)(p),
 	})
 }
 
@@ -179,8 +725,11 @@ func (p *Pool) MarshalJSON() ([]byte, error) {
 func (p *Pool) UnmarshalJSON(data []byte) error {
 	type Alias Pool
 	aux := &struct {
-		Reserve0 string `json:"reserve0"`
-		Reserve1 string `json:"reserve1"`
+		Reserve0     string `json:"reserve0"`
+		Reserve1     string `json:"reserve1"`
+		SqrtPriceX96 string `json:"sqrt_price_x96"`
+		Liquidity    string `json:"liquidity"`
+		Volume24h    string `json:"volume_24h"`
 		*Alias
 	}{
 		Alias: (*Alias)(p),
@@ -207,5 +756,40 @@ func (p *Pool) UnmarshalJSON(data []byte) error {
 		p.Reserve1 = reserve1
 	}
 
+	if aux.SqrtPriceX96 != "" {
+		sqrtPriceX96, ok := new(big.Int).SetString(aux.SqrtPriceX96, 10)
+		if !ok {
+			return fmt.Errorf("invalid sqrt_price_x96 format: %s", aux.SqrtPriceX96)
+		}
+		p.SqrtPriceX96 = sqrtPriceX96
+	}
+
+	if aux.Liquidity != "" {
+		liquidity, ok := new(big.Int).SetString(aux.Liquidity, 10)
+		if !ok {
+			return fmt.Errorf("invalid liquidity format: %s", aux.Liquidity)
+		}
+		p.Liquidity = liquidity
+	}
+
+	if aux.Volume24h != "" {
+		volume24h, ok := new(big.Int).SetString(aux.Volume24h, 10)
+		if !ok {
+			return fmt.Errorf("invalid volume_24h format: %s", aux.Volume24h)
+		}
+		p.Volume24h = volume24h
+	}
+
 	return nil
 }
+
+// APIError structured error returned by the HTTP API
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
@@ -45,6 +45,56 @@ func TestPoolModel(t *testing.T) {
 	assert.Equal(t, "Uniswap V2", pool.Exchange)
 	assert.Equal(t, int64(1000000000000000000), pool.Reserve0.Int64())
 	assert.Equal(t, 300, pool.Fee)
+	assert.Nil(t, pool.SqrtPriceX96)
+	assert.Zero(t, pool.TickSpacing)
+
+	v3Pool := &Pool{
+		Address:      "0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640",
+		Exchange:     "Uniswap V3",
+		Version:      "v3",
+		Fee:          500,
+		TickSpacing:  10,
+		SqrtPriceX96: big.NewInt(0).Lsh(big.NewInt(1), 96), // price 1.0
+		TickCurrent:  -1,
+		Liquidity:    big.NewInt(1000000),
+	}
+
+	assert.Equal(t, 10, v3Pool.TickSpacing)
+	assert.Equal(t, int32(-1), v3Pool.TickCurrent)
+	assert.Equal(t, "79228162514264337593543950336", v3Pool.SqrtPriceX96.String())
+	assert.Equal(t, int64(1000000), v3Pool.Liquidity.Int64())
+}
+
+func TestPoolLiquidityScore(t *testing.T) {
+	now := time.Now()
+	pool := &Pool{
+		Reserve0: big.NewInt(1000000),
+		Reserve1: big.NewInt(100000000), // sqrt(1e6 * 1e8) = 1e7
+	}
+
+	testCases := []struct {
+		name     string
+		age      time.Duration
+		maxAge   time.Duration
+		expected float64
+	}{
+		{"Just updated", 0, time.Hour, 7},
+		{"Half of max age", 30 * time.Minute, time.Hour, 3.5},
+		{"At max age", time.Hour, time.Hour, 0},
+		{"Past max age", 2 * time.Hour, time.Hour, 0},
+		{"Freshness disabled", 2 * time.Hour, 0, 7},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool.LastUpdated = now.Add(-tc.age)
+			assert.InDelta(t, tc.expected, pool.LiquidityScoreAt(now, tc.maxAge), 1e-9)
+		})
+	}
+
+	empty := &Pool{Reserve0: big.NewInt(0), Reserve1: big.NewInt(1000), LastUpdated: now}
+	assert.Zero(t, empty.LiquidityScoreAt(now, time.Hour))
+	assert.Zero(t, (&Pool{LastUpdated: now}).LiquidityScoreAt(now, time.Hour))
 }
 
 func TestQuoteRequestJSON(t *testing.T) {
@@ -87,6 +137,137 @@ func TestPoolJSON(t *testing.T) {
 
 	assert.Equal(t, pool.Reserve0.String(), newPool.Reserve0.String())
 	assert.Equal(t, pool.Reserve1.String(), newPool.Reserve1.String())
+	assert.Nil(t, newPool.SqrtPriceX96)
+	assert.Nil(t, newPool.Liquidity)
+	assert.NotContains(t, string(data), "sqrt_price_x96")
+	assert.NotContains(t, string(data), "volume_24h")
+
+	v3Pool := &Pool{
+		Address:      "test-v3-pool",
+		Reserve0:     big.NewInt(1000000),
+		Reserve1:     big.NewInt(2000000),
+		TickSpacing:  60,
+		SqrtPriceX96: bigIntFromDecimal(t, "1771595571142957102961017161607260"),
+		TickCurrent:  200311,
+		Liquidity:    bigIntFromDecimal(t, "44721359549995793"),
+	}
+
+	data, err = json.Marshal(v3Pool)
+	assert.NoError(t, err)
+
+	var jsonData map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &jsonData))
+	assert.Equal(t, "1771595571142957102961017161607260", jsonData["sqrt_price_x96"])
+	assert.Equal(t, "44721359549995793", jsonData["liquidity"])
+
+	var newV3Pool Pool
+	assert.NoError(t, json.Unmarshal(data, &newV3Pool))
+	assert.Equal(t, 60, newV3Pool.TickSpacing)
+	assert.Equal(t, int32(200311), newV3Pool.TickCurrent)
+	assert.Equal(t, v3Pool.SqrtPriceX96.String(), newV3Pool.SqrtPriceX96.String())
+	assert.Equal(t, v3Pool.Liquidity.String(), newV3Pool.Liquidity.String())
+
+	err = json.Unmarshal([]byte(`{"address":"bad","sqrt_price_x96":"not-a-number"}`), &newV3Pool)
+	assert.Error(t, err)
+
+	pool.Volume24h = bigIntFromDecimal(t, "123456789012345678901234")
+	data, err = json.Marshal(pool)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"volume_24h":"123456789012345678901234"`)
+
+	var volumePool Pool
+	assert.NoError(t, json.Unmarshal(data, &volumePool))
+	assert.Equal(t, pool.Volume24h.String(), volumePool.Volume24h.String())
+}
+
+func bigIntFromDecimal(t *testing.T, s string) *big.Int {
+	t.Helper()
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		t.Fatalf("invalid decimal %q", s)
+	}
+	return n
+}
+
+func TestTradePathJSON(t *testing.T) {
+	path := &TradePath{
+		Pools:           []*Pool{{Address: "test-pool", Reserve0: big.NewInt(1), Reserve1: big.NewInt(2)}},
+		AmountIn:        big.NewInt(1000000),
+		AmountOut:       big.NewInt(1990000),
+		Dexes:           []string{"Uniswap V2"},
+		GasCost:         big.NewInt(121000),
+		NetAmountOut:    big.NewInt(1000),
+		SlippagePercent: 0.5,
+		HopDetails: []HopDetail{{
+			PoolAddress: "test-pool",
+			TokenIn:     "0xtokena",
+			TokenOut:    "0xtokenb",
+			AmountIn:    big.NewInt(1000000),
+			AmountOut:   big.NewInt(1990000),
+			PriceImpact: 0.5,
+		}},
+	}
+
+	data, err := json.Marshal(path)
+	assert.NoError(t, err)
+
+	var jsonData map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &jsonData))
+	assert.Equal(t, "1000000", jsonData["amountIn"])
+	assert.Equal(t, 0.5, jsonData["slippagePercent"])
+	hopJSON := jsonData["hopDetails"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "1990000", hopJSON["amountOut"])
+	assert.Equal(t, "test-pool", hopJSON["poolAddress"])
+
+	var newPath TradePath
+	assert.NoError(t, json.Unmarshal(data, &newPath))
+	assert.Equal(t, path.AmountIn.String(), newPath.AmountIn.String())
+	assert.Equal(t, path.AmountOut.String(), newPath.AmountOut.String())
+	assert.Equal(t, path.GasCost.String(), newPath.GasCost.String())
+	assert.Equal(t, path.NetAmountOut.String(), newPath.NetAmountOut.String())
+	assert.Equal(t, path.Dexes, newPath.Dexes)
+	assert.Equal(t, 0.5, newPath.SlippagePercent)
+	assert.Equal(t, "test-pool", newPath.Pools[0].Address)
+	if assert.Len(t, newPath.HopDetails, 1) {
+		hop := newPath.HopDetails[0]
+		assert.Equal(t, "1000000", hop.AmountIn.String())
+		assert.Equal(t, "1990000", hop.AmountOut.String())
+		assert.Equal(t, "0xtokenb", hop.TokenOut)
+		assert.Equal(t, 0.5, hop.PriceImpact)
+	}
+
+	err = json.Unmarshal([]byte(`{"amountIn":"1.5"}`), &newPath)
+	assert.Error(t, err)
+	err = json.Unmarshal([]byte(`{"hopDetails":[{"amountOut":"1.5"}]}`), &newPath)
+	assert.Error(t, err)
+}
+
+func TestTradePath_IsWithinSlippageTolerance(t *testing.T) {
+	path := &TradePath{SlippagePercent: 1.2}
+	assert.True(t, path.IsWithinSlippageTolerance(2))
+	assert.True(t, path.IsWithinSlippageTolerance(1.2))
+	assert.False(t, path.IsWithinSlippageTolerance(1))
+	assert.False(t, path.IsWithinSlippageTolerance(0))
+}
+
+func TestReserveUpdate_PoolUpdate(t *testing.T) {
+	lastUpdated := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	update, err := (&ReserveUpdate{Address: "0xpool", Reserve0: "1000", LastUpdated: lastUpdated}).PoolUpdate()
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1000), update.Reserve0)
+	assert.Nil(t, update.Reserve1)
+
+	pool := &Pool{Reserve0: big.NewInt(1), Reserve1: big.NewInt(2)}
+	update.Apply(pool)
+	assert.Equal(t, "1000", pool.Reserve0.String())
+	assert.Equal(t, "2", pool.Reserve1.String())
+	assert.Equal(t, lastUpdated, pool.LastUpdated)
+	assert.False(t, pool.ReserveUpdatedAt.IsZero())
+
+	_, err = (&ReserveUpdate{Address: "0xpool", Reserve1: "1e18"}).PoolUpdate()
+	assert.EqualError(t, err, "invalid reserve1 for pool 0xpool: 1e18")
+	_, err = (&ReserveUpdate{Address: "0xpool", Reserve0: "-5"}).PoolUpdate()
+	assert.Error(t, err)
 }
 
 func TestInvalidBigIntJSON(t *testing.T) {
@@ -121,4 +302,81 @@ func TestQuoteResponseJSON(t *testing.T) {
 
 	assert.Equal(t, "200000000", jsonData["amountOut"])
 	assert.Equal(t, "150000", jsonData["gasEstimate"])
+	assert.NotContains(t, jsonData, "gasCostInGasToken", "omitted without a gas token")
+
+	resp.GasCostInGasToken = big.NewInt(9000000)
+	data, err = json.Marshal(resp)
+	assert.NoError(t, err)
+	var decoded QuoteResponse
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "9000000", decoded.GasCostInGasToken.String())
+}
+
+func TestPool_SortedTokens(t *testing.T) {
+	// "0xBBB" sorts first byte-wise, but "0xaaa" is lower once both are lowercased
+	tokenA := Token{Address: "0xaaa", Symbol: "A"}
+	tokenB := Token{Address: "0xBBB", Symbol: "B"}
+
+	for _, pool := range []*Pool{{Token0: tokenA, Token1: tokenB}, {Token0: tokenB, Token1: tokenA}} {
+		lower, higher := pool.SortedTokens()
+		assert.Equal(t, "A", lower.Symbol)
+		assert.Equal(t, "B", higher.Symbol)
+	}
+
+	// The tokens are the pool's own, not copies
+	pool := &Pool{Token0: tokenB, Token1: tokenA}
+	lower, _ := pool.SortedTokens()
+	assert.Same(t, &pool.Token1, lower)
+}
+
+func TestPool_TotalFeeBps(t *testing.T) {
+	testCases := []struct {
+		name           string
+		lpFeeBps       int
+		protocolFeeBps int
+		expected       int
+	}{
+		{"unknown split", 0, 0, DefaultSwapFeeBps},
+		{"all to LPs", 5, 0, 5},
+		{"V2 fee switch on", 25, 5, 30},
+		{"all to the protocol", 0, 10, 10},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := &Pool{LPFeeBps: tc.lpFeeBps, ProtocolFeeBps: tc.protocolFeeBps}
+			assert.Equal(t, tc.expected, pool.TotalFeeBps())
+			if tc.protocolFeeBps > 0 {
+				assert.Equal(t, tc.lpFeeBps+tc.protocolFeeBps, pool.TotalFeeBps())
+			}
+		})
+	}
+
+	// Both shares survive a JSON round trip
+	data, err := json.Marshal(&Pool{Reserve0: big.NewInt(1), Reserve1: big.NewInt(1), LPFeeBps: 25, ProtocolFeeBps: 5})
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"lp_fee_bps":25`)
+	assert.Contains(t, string(data), `"protocol_fee_bps":5`)
+	var decoded Pool
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, 25, decoded.LPFeeBps)
+	assert.Equal(t, 5, decoded.ProtocolFeeBps)
+}
+
+func TestPool_IsActive(t *testing.T) {
+	// Pools stored before the flag existed decode as active
+	var stored Pool
+	assert.NoError(t, json.Unmarshal([]byte(`{"address":"0xpool","reserve0":"1","reserve1":"1"}`), &stored))
+	assert.True(t, stored.IsActive())
+
+	// Deactivating a pool pauses it, and only the paused flag is encoded
+	stored.Paused = true
+	assert.False(t, stored.IsActive())
+	data, err := json.Marshal(&stored)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"paused":true`)
+
+	var decoded Pool
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.False(t, decoded.IsActive())
 }
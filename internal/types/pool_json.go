@@ -0,0 +1,276 @@
+package types
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// poolJSONBufferSize is the size of the buffer PoolSliceJSON encodes into before
+// each write; a pool is flushed once the buffer holds more than poolJSONFlushAt bytes
+const (
+	poolJSONBufferSize = 8192
+	poolJSONFlushAt    = poolJSONBufferSize - 1024
+)
+
+// Field keys are written in sorted order, matching the key order of a pool
+// re-encoded through a map as scoredPool in the API does
+const (
+	keyAddress          = `{"address":`
+	keyChainID          = `,"chain_id":`
+	keyCreatedAt        = `,"created_at":`
+	keyExchange         = `,"exchange":`
+	keyFee              = `,"fee":`
+	keyFeeTier          = `,"fee_tier":`
+	keyLastUpdated      = `,"last_updated":`
+	keyLiquidity        = `,"liquidity":`
+	keyLiquidityScore   = `,"liquidityScore":`
+	keyLPFeeBps         = `,"lp_fee_bps":`
+	keyPaused           = `,"paused":true`
+	keyProtocolFeeBps   = `,"protocol_fee_bps":`
+	keyReserve0         = `,"reserve0":`
+	keyReserve1         = `,"reserve1":`
+	keyReserveUpdatedAt = `,"reserve_updated_at":`
+	keySqrtPriceX96     = `,"sqrt_price_x96":`
+	keyTickCurrent      = `,"tick_current":`
+	keyTickSpacing      = `,"tick_spacing":`
+	keyToken0           = `,"token0":`
+	keyToken1           = `,"token1":`
+	keyVersion          = `,"version":`
+	keyVolume24h        = `,"volume_24h":`
+)
+
+// PoolSliceJSON streams a JSON array of pools without marshalling each pool into its
+// own byte slice first. The output decodes to the same values as json.Marshal(Pools).
+type PoolSliceJSON struct {
+	Pools []*Pool
+	// IncludeLiquidityScore adds each pool's LiquidityScore as "liquidityScore"
+	IncludeLiquidityScore bool
+}
+
+// WriteTo writes the JSON array to w, returning the number of bytes written
+func (ps PoolSliceJSON) WriteTo(w io.Writer) (int64, error) {
+	var storage [poolJSONBufferSize]byte
+	buf := append(storage[:0], '[')
+	var written int64
+
+	flush := func() error {
+		n, err := w.Write(buf)
+		written += int64(n)
+		buf = buf[:0]
+		return err
+	}
+
+	for i, pool := range ps.Pools {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		var err error
+		if buf, err = ps.appendPool(buf, pool); err != nil {
+			return written, err
+		}
+		if len(buf) > poolJSONFlushAt {
+			if err := flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	buf = append(buf, ']')
+	return written, flush()
+}
+
+func (ps PoolSliceJSON) appendPool(buf []byte, p *Pool) ([]byte, error) {
+	if p == nil {
+		return append(buf, "null"...), nil
+	}
+
+	var err error
+	buf = append(buf, keyAddress...)
+	buf = appendJSONString(buf, p.Address)
+	buf = append(buf, keyChainID...)
+	buf = strconv.AppendInt(buf, p.ChainID, 10)
+	buf = append(buf, keyCreatedAt...)
+	if buf, err = appendJSONTime(buf, p.CreatedAt); err != nil {
+		return buf, err
+	}
+	buf = append(buf, keyExchange...)
+	buf = appendJSONString(buf, p.Exchange)
+	buf = append(buf, keyFee...)
+	buf = strconv.AppendInt(buf, int64(p.Fee), 10)
+	if p.FeeTier != "" {
+		buf = append(buf, keyFeeTier...)
+		buf = appendJSONString(buf, p.FeeTier)
+	}
+	buf = append(buf, keyLastUpdated...)
+	if buf, err = appendJSONTime(buf, p.LastUpdated); err != nil {
+		return buf, err
+	}
+	if p.Liquidity != nil {
+		buf = append(buf, keyLiquidity...)
+		buf = appendJSONBigInt(buf, p.Liquidity)
+	}
+	if ps.IncludeLiquidityScore {
+		buf = append(buf, keyLiquidityScore...)
+		if buf, err = appendJSONFloat(buf, p.LiquidityScore()); err != nil {
+			return buf, err
+		}
+	}
+	if p.LPFeeBps != 0 {
+		buf = append(buf, keyLPFeeBps...)
+		buf = strconv.AppendInt(buf, int64(p.LPFeeBps), 10)
+	}
+	if p.Paused {
+		buf = append(buf, keyPaused...)
+	}
+	if p.ProtocolFeeBps != 0 {
+		buf = append(buf, keyProtocolFeeBps...)
+		buf = strconv.AppendInt(buf, int64(p.ProtocolFeeBps), 10)
+	}
+	buf = append(buf, keyReserve0...)
+	buf = appendJSONBigInt(buf, p.Reserve0)
+	buf = append(buf, keyReserve1...)
+	buf = appendJSONBigInt(buf, p.Reserve1)
+	buf = append(buf, keyReserveUpdatedAt...)
+	if buf, err = appendJSONTime(buf, p.ReserveUpdatedAt); err != nil {
+		return buf, err
+	}
+	if p.SqrtPriceX96 != nil {
+		buf = append(buf, keySqrtPriceX96...)
+		buf = appendJSONBigInt(buf, p.SqrtPriceX96)
+	}
+	if p.TickCurrent != 0 {
+		buf = append(buf, keyTickCurrent...)
+		buf = strconv.AppendInt(buf, int64(p.TickCurrent), 10)
+	}
+	if p.TickSpacing != 0 {
+		buf = append(buf, keyTickSpacing...)
+		buf = strconv.AppendInt(buf, int64(p.TickSpacing), 10)
+	}
+	buf = append(buf, keyToken0...)
+	buf = appendJSONToken(buf, &p.Token0)
+	buf = append(buf, keyToken1...)
+	buf = appendJSONToken(buf, &p.Token1)
+	buf = append(buf, keyVersion...)
+	buf = appendJSONString(buf, p.Version)
+	if p.Volume24h != nil {
+		buf = append(buf, keyVolume24h...)
+		buf = appendJSONBigInt(buf, p.Volume24h)
+	}
+	return append(buf, '}'), nil
+}
+
+// appendJSONToken encodes t with the field order and omissions of its struct tags
+func appendJSONToken(buf []byte, t *Token) []byte {
+	buf = append(buf, `{"address":`...)
+	buf = appendJSONString(buf, t.Address)
+	buf = append(buf, `,"symbol":`...)
+	buf = appendJSONString(buf, t.Symbol)
+	if t.Name != "" {
+		buf = append(buf, `,"name":`...)
+		buf = appendJSONString(buf, t.Name)
+	}
+	buf = append(buf, `,"decimals":`...)
+	buf = strconv.AppendInt(buf, int64(t.Decimals), 10)
+	if t.LogoURI != "" {
+		buf = append(buf, `,"logoURI":`...)
+		buf = appendJSONString(buf, t.LogoURI)
+	}
+	return append(buf, '}')
+}
+
+// appendJSONBigInt encodes n as a decimal string, as Pool.MarshalJSON does
+func appendJSONBigInt(buf []byte, n *big.Int) []byte {
+	if n == nil {
+		return append(buf, `"<nil>"`...)
+	}
+	buf = append(buf, '"')
+	buf = n.Append(buf, 10)
+	return append(buf, '"')
+}
+
+// appendJSONTime encodes t as time.Time.MarshalJSON does
+func appendJSONTime(buf []byte, t time.Time) ([]byte, error) {
+	if y := t.Year(); y < 0 || y >= 10000 {
+		return buf, fmt.Errorf("time %v has a year outside of range [0,9999]", t)
+	}
+	buf = append(buf, '"')
+	buf = t.AppendFormat(buf, time.RFC3339Nano)
+	return append(buf, '"'), nil
+}
+
+// appendJSONFloat encodes f as encoding/json does for a float64
+func appendJSONFloat(buf []byte, f float64) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return buf, fmt.Errorf("unsupported float value %v", f)
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	buf = strconv.AppendFloat(buf, f, format, -1, 64)
+	if format == 'e' {
+		// Shorten e-09 to e-9, as encoding/json does
+		if n := len(buf); n >= 4 && buf[n-4] == 'e' && buf[n-3] == '-' && buf[n-2] == '0' {
+			buf[n-2] = buf[n-1]
+			buf = buf[:n-1]
+		}
+	}
+	return buf, nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString quotes s with the escaping of encoding/json, including its HTML escaping
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				buf = append(buf, '\\', b)
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
@@ -0,0 +1,147 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func benchmarkPools(n int) []*Pool {
+	now := time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC)
+	pools := make([]*Pool, n)
+	for i := range pools {
+		pools[i] = &Pool{
+			Address:          fmt.Sprintf("0x%040x", i),
+			ChainID:          1,
+			Exchange:         "Uniswap V2",
+			Version:          "v2",
+			Token0:           Token{Address: "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", Symbol: "WETH", Name: "Wrapped Ether", Decimals: 18},
+			Token1:           Token{Address: "0xdac17f958d2ee523a2206206994597c13d831ec7", Symbol: "USDT", Decimals: 6},
+			Reserve0:         new(big.Int).Mul(big.NewInt(int64(1000+i)), big.NewInt(1e18)),
+			Reserve1:         new(big.Int).Mul(big.NewInt(int64(2000000+i)), big.NewInt(1e6)),
+			Fee:              300,
+			FeeTier:          FeeTierV2_30,
+			LastUpdated:      now,
+			CreatedAt:        now.Add(-time.Hour),
+			ReserveUpdatedAt: now,
+		}
+	}
+	return pools
+}
+
+// decodeArray decodes a JSON array of objects so encodings can be compared regardless of key order
+func decodeArray(t *testing.T, data []byte) []map[string]interface{} {
+	var decoded []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	return decoded
+}
+
+func TestPoolSliceJSON_MatchesMarshal(t *testing.T) {
+	pools := benchmarkPools(3)
+
+	// Exercise the optional fields and string escaping
+	pools[1].Paused = true
+	pools[1].Exchange = "Quote \"<&>\" Swap\n\t é\x01"
+	pools[1].Token1.LogoURI = "https://example.com/usdt.png?a=1&b=2"
+	pools[1].LPFeeBps = 25
+	pools[1].ProtocolFeeBps = 5
+	pools[2].Version = "v3"
+	pools[2].TickSpacing = 60
+	pools[2].TickCurrent = -201234
+	pools[2].SqrtPriceX96 = big.NewInt(1234567890123)
+	pools[2].Liquidity = big.NewInt(987654321)
+	pools[2].Volume24h = big.NewInt(42)
+
+	var buf bytes.Buffer
+	n, err := PoolSliceJSON{Pools: pools}.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.True(t, json.Valid(buf.Bytes()))
+
+	expected, err := json.Marshal(pools)
+	assert.NoError(t, err)
+	assert.Equal(t, decodeArray(t, expected), decodeArray(t, buf.Bytes()))
+
+	// Escaped strings are byte-for-byte what encoding/json writes
+	exchange, _ := json.Marshal(pools[1].Exchange)
+	assert.Contains(t, buf.String(), `"exchange":`+string(exchange))
+
+	var decoded []*Pool
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, pools[2].SqrtPriceX96.String(), decoded[2].SqrtPriceX96.String())
+	assert.True(t, pools[0].LastUpdated.Equal(decoded[0].LastUpdated))
+
+	// Empty slices are empty arrays
+	buf.Reset()
+	_, err = PoolSliceJSON{}.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", buf.String())
+}
+
+func TestPoolSliceJSON_LiquidityScore(t *testing.T) {
+	pools := benchmarkPools(2)
+	pools[1].LastUpdated = time.Now()
+
+	var buf bytes.Buffer
+	_, err := PoolSliceJSON{Pools: pools, IncludeLiquidityScore: true}.WriteTo(&buf)
+	assert.NoError(t, err)
+
+	decoded := decodeArray(t, buf.Bytes())
+	assert.Equal(t, 0.0, decoded[0]["liquidityScore"], "stale pools score 0")
+	assert.InDelta(t, pools[1].LiquidityScore(), decoded[1]["liquidityScore"], 1e-3)
+}
+
+func TestAppendJSONFloat(t *testing.T) {
+	for _, f := range []float64{0, 1, -2.5, 14.123456789, 1e-7, 3.5e-12, 1e21, 123456789e20} {
+		expected, err := json.Marshal(f)
+		assert.NoError(t, err)
+		actual, err := appendJSONFloat(nil, f)
+		assert.NoError(t, err)
+		assert.Equal(t, string(expected), string(actual))
+	}
+}
+
+func TestPoolSliceJSON_Allocations(t *testing.T) {
+	pools := benchmarkPools(1000)
+
+	marshalAllocs := testing.AllocsPerRun(5, func() {
+		data, _ := json.Marshal(pools)
+		io.Discard.Write(data)
+	})
+	streamAllocs := testing.AllocsPerRun(5, func() {
+		PoolSliceJSON{Pools: pools}.WriteTo(io.Discard)
+	})
+
+	t.Logf("json.Marshal: %.0f allocs, PoolSliceJSON: %.0f allocs", marshalAllocs, streamAllocs)
+	assert.LessOrEqual(t, streamAllocs, 0.6*marshalAllocs, "streaming should allocate at least 40%% less")
+}
+
+func BenchmarkPoolSliceJSON_1000(b *testing.B) {
+	pools := benchmarkPools(1000)
+
+	b.Run("json.Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, err := json.Marshal(pools)
+			if err != nil {
+				b.Fatal(err)
+			}
+			io.Discard.Write(data)
+		}
+	})
+
+	b.Run("PoolSliceJSON", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := (PoolSliceJSON{Pools: pools}).WriteTo(io.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
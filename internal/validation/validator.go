@@ -0,0 +1,103 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"dex-aggregator/internal/types"
+)
+
+// DefaultMinLiquidityProduct is the smallest reserve0 * reserve1 accepted by default
+const DefaultMinLiquidityProduct = "1000000000000"
+
+var (
+	// ErrInsufficientLiquidity is returned for pools whose reserve product is below the minimum
+	ErrInsufficientLiquidity = errors.New("insufficient liquidity")
+	// ErrInvalidAddress is returned for pools with a missing or malformed address
+	ErrInvalidAddress = errors.New("invalid address")
+	// ErrInvalidFee is returned for pools whose fee split is negative or takes the whole input
+	ErrInvalidFee = errors.New("invalid fee")
+)
+
+var hexAddressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// PoolValidator checks a pool before it is stored or routed through
+type PoolValidator interface {
+	Validate(pool *types.Pool) error
+}
+
+// Validate runs validators in order and returns the first error
+func Validate(pool *types.Pool, validators []PoolValidator) error {
+	for _, validator := range validators {
+		if err := validator.Validate(pool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MinLiquidityValidator rejects pools whose reserve product is below MinProduct, as
+// near-empty pools give unreliable quotes
+type MinLiquidityValidator struct {
+	MinProduct *big.Int
+}
+
+// NewMinLiquidityValidator parses minProduct as a decimal integer; an empty string uses
+// DefaultMinLiquidityProduct
+func NewMinLiquidityValidator(minProduct string) (*MinLiquidityValidator, error) {
+	if minProduct == "" {
+		minProduct = DefaultMinLiquidityProduct
+	}
+	value, ok := new(big.Int).SetString(minProduct, 10)
+	if !ok || value.Sign() < 0 {
+		return nil, fmt.Errorf("invalid minimum liquidity product: %q", minProduct)
+	}
+	return &MinLiquidityValidator{MinProduct: value}, nil
+}
+
+func (v *MinLiquidityValidator) Validate(pool *types.Pool) error {
+	if pool.Reserve0 == nil || pool.Reserve1 == nil {
+		return fmt.Errorf("%w: pool %s has no reserves", ErrInsufficientLiquidity, pool.Address)
+	}
+	product := new(big.Int).Mul(pool.Reserve0, pool.Reserve1)
+	if product.Cmp(v.MinProduct) < 0 {
+		return fmt.Errorf("%w: pool %s reserve product %s is below %s", ErrInsufficientLiquidity, pool.Address, product, v.MinProduct)
+	}
+	return nil
+}
+
+// AddressValidator requires a pool address and two distinct hex token addresses. Pool
+// addresses are only checked for presence, since mock pools use synthetic identifiers.
+type AddressValidator struct{}
+
+func (AddressValidator) Validate(pool *types.Pool) error {
+	if strings.TrimSpace(pool.Address) == "" {
+		return fmt.Errorf("%w: pool address is required", ErrInvalidAddress)
+	}
+	for _, token := range []string{pool.Token0.Address, pool.Token1.Address} {
+		if !hexAddressPattern.MatchString(token) {
+			return fmt.Errorf("%w: pool %s token %q is not a hex address", ErrInvalidAddress, pool.Address, token)
+		}
+	}
+	if strings.EqualFold(pool.Token0.Address, pool.Token1.Address) {
+		return fmt.Errorf("%w: pool %s has the same token on both sides", ErrInvalidAddress, pool.Address)
+	}
+	return nil
+}
+
+// FeeValidator requires LPFeeBps and ProtocolFeeBps to be non-negative and to leave part
+// of the input to swap. Outside that range quotes and spot-rate weights are meaningless.
+type FeeValidator struct{}
+
+func (FeeValidator) Validate(pool *types.Pool) error {
+	if pool.LPFeeBps < 0 || pool.ProtocolFeeBps < 0 {
+		return fmt.Errorf("%w: pool %s has a negative fee (lp %d bps, protocol %d bps)", ErrInvalidFee, pool.Address, pool.LPFeeBps, pool.ProtocolFeeBps)
+	}
+	if total := pool.TotalFeeBps(); total >= 10000 {
+		return fmt.Errorf("%w: pool %s fee of %d bps takes the whole input", ErrInvalidFee, pool.Address, total)
+	}
+	return nil
+}
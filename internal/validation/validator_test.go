@@ -0,0 +1,124 @@
+package validation
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"dex-aggregator/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	wethAddress = "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"
+	usdtAddress = "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+)
+
+func newPool(reserve0, reserve1 int64) *types.Pool {
+	return &types.Pool{
+		Address:  "test-pool",
+		Token0:   types.Token{Address: wethAddress},
+		Token1:   types.Token{Address: usdtAddress},
+		Reserve0: big.NewInt(reserve0),
+		Reserve1: big.NewInt(reserve1),
+	}
+}
+
+func TestMinLiquidityValidator(t *testing.T) {
+	validator, err := NewMinLiquidityValidator("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultMinLiquidityProduct, validator.MinProduct.String())
+
+	testCases := []struct {
+		name     string
+		pool     *types.Pool
+		expected error
+	}{
+		{"At threshold", newPool(1000000, 1000000), nil},
+		{"Above threshold", newPool(10000000, 1000000), nil},
+		{"Below threshold", newPool(999999, 1000000), ErrInsufficientLiquidity},
+		{"Missing reserves", &types.Pool{Address: "test-pool"}, ErrInsufficientLiquidity},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validator.Validate(tc.pool)
+			if tc.expected == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, tc.expected), "got %v", err)
+			}
+		})
+	}
+
+	_, err = NewMinLiquidityValidator("lots")
+	assert.Error(t, err)
+}
+
+func TestAddressValidator(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(p *types.Pool)
+		valid  bool
+	}{
+		{"Valid", func(p *types.Pool) {}, true},
+		{"Missing pool address", func(p *types.Pool) { p.Address = " " }, false},
+		{"Malformed token", func(p *types.Pool) { p.Token1.Address = "0x1234" }, false},
+		{"Same token twice", func(p *types.Pool) { p.Token1.Address = wethAddress }, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := newPool(1, 1)
+			tc.modify(pool)
+			err := AddressValidator{}.Validate(pool)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, ErrInvalidAddress), "got %v", err)
+			}
+		})
+	}
+}
+
+func TestFeeValidator(t *testing.T) {
+	testCases := []struct {
+		name           string
+		lpFeeBps       int
+		protocolFeeBps int
+		valid          bool
+	}{
+		{"Default fee", 0, 0, true},
+		{"Split fee", 25, 5, true},
+		{"Largest fee", 9990, 9, true},
+		{"Negative LP fee", -1, 5, false},
+		{"Negative protocol fee", 30, -1, false},
+		{"Whole input", 9000, 1000, false},
+		{"Above whole input", 10000, 1, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := newPool(1, 1)
+			pool.LPFeeBps, pool.ProtocolFeeBps = tc.lpFeeBps, tc.protocolFeeBps
+			err := FeeValidator{}.Validate(pool)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, ErrInvalidFee), "got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidate_ReturnsFirstError(t *testing.T) {
+	minLiquidity, _ := NewMinLiquidityValidator("")
+	pool := newPool(1, 1)
+	pool.Token0.Address = "bad"
+
+	err := Validate(pool, []PoolValidator{AddressValidator{}, minLiquidity})
+	assert.True(t, errors.Is(err, ErrInvalidAddress))
+
+	assert.NoError(t, Validate(pool, nil))
+}
@@ -0,0 +1,114 @@
+package volume
+
+import (
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Window is the span of swaps summed by GetVolume24h
+const Window = 24 * time.Hour
+
+// bucketWidth is the resolution of the sliding window: swaps are grouped into
+// buckets of this width, so the window edge is accurate to one bucket
+const bucketWidth = 5 * time.Minute
+
+// bucketCount is the number of buckets covering Window
+const bucketCount = int(Window / bucketWidth)
+
+// bucket holds the swap volume of one bucketWidth interval
+type bucket struct {
+	index  int64 // Unix time / bucketWidth of the interval the amount belongs to
+	amount *big.Int
+}
+
+// ring is a per-pool circular buffer of buckets. A slot is reused once its
+// interval has left the window.
+type ring [bucketCount]bucket
+
+// Accumulator tracks per-pool swap volume over a sliding 24 hour window
+type Accumulator struct {
+	mutex sync.RWMutex
+	pools map[string]*ring // Lowercase pool address -> buckets
+	now   func() time.Time
+}
+
+func NewAccumulator() *Accumulator {
+	return &Accumulator{
+		pools: make(map[string]*ring),
+		now:   time.Now,
+	}
+}
+
+func bucketIndex(t time.Time) int64 {
+	return t.UnixNano() / int64(bucketWidth)
+}
+
+func slot(index int64) int {
+	return int(index % int64(bucketCount))
+}
+
+// RecordSwap adds amountIn to the pool's volume at timestamp. Swaps outside
+// the window and non-positive amounts are ignored.
+func (a *Accumulator) RecordSwap(poolAddress string, amountIn *big.Int, timestamp time.Time) {
+	if amountIn == nil || amountIn.Sign() <= 0 {
+		return
+	}
+
+	index := bucketIndex(timestamp)
+	current := bucketIndex(a.now())
+	if index <= current-int64(bucketCount) || index > current {
+		return
+	}
+
+	key := strings.ToLower(poolAddress)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	buckets, ok := a.pools[key]
+	if !ok {
+		buckets = &ring{}
+		a.pools[key] = buckets
+	}
+
+	b := &buckets[slot(index)]
+	if b.amount == nil || b.index != index {
+		b.index = index
+		b.amount = new(big.Int)
+	}
+	b.amount.Add(b.amount, amountIn)
+}
+
+// RecordReserveChange approximates a swap from a Sync of reserve0, recording
+// the size of the reserve0 change as volume in token0 units
+func (a *Accumulator) RecordReserveChange(poolAddress string, prevReserve0, reserve0 *big.Int, timestamp time.Time) {
+	if prevReserve0 == nil || reserve0 == nil {
+		return
+	}
+	delta := new(big.Int).Sub(reserve0, prevReserve0)
+	a.RecordSwap(poolAddress, delta.Abs(delta), timestamp)
+}
+
+// GetVolume24h sums the pool's swaps within the last 24 hours. Unknown pools
+// have zero volume.
+func (a *Accumulator) GetVolume24h(poolAddress string) *big.Int {
+	total := new(big.Int)
+	current := bucketIndex(a.now())
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	buckets, ok := a.pools[strings.ToLower(poolAddress)]
+	if !ok {
+		return total
+	}
+
+	for _, b := range buckets {
+		if b.amount != nil && b.index > current-int64(bucketCount) && b.index <= current {
+			total.Add(total, b.amount)
+		}
+	}
+	return total
+}
@@ -0,0 +1,72 @@
+package volume
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAccumulator(now *time.Time) *Accumulator {
+	a := NewAccumulator()
+	a.now = func() time.Time { return *now }
+	return a
+}
+
+func TestAccumulator_SlidingWindow(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	a := newTestAccumulator(&now)
+
+	a.RecordSwap("0xPool", big.NewInt(100), now.Add(-23*time.Hour))
+	a.RecordSwap("0xpool", big.NewInt(50), now.Add(-time.Hour))
+	a.RecordSwap("0xpool", big.NewInt(25), now)
+
+	assert.Equal(t, "175", a.GetVolume24h("0xPOOL").String())
+	assert.Equal(t, "0", a.GetVolume24h("0xother").String())
+
+	// The oldest swap leaves the window after two more hours
+	now = now.Add(2 * time.Hour)
+	assert.Equal(t, "75", a.GetVolume24h("0xpool").String())
+
+	// A day later everything has expired
+	now = now.Add(Window)
+	assert.Equal(t, "0", a.GetVolume24h("0xpool").String())
+}
+
+func TestAccumulator_ReusesExpiredSlots(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	a := newTestAccumulator(&now)
+
+	a.RecordSwap("0xpool", big.NewInt(10), now)
+
+	// One window later the swap lands in the same slot and must replace the stale amount
+	now = now.Add(Window)
+	a.RecordSwap("0xpool", big.NewInt(3), now)
+
+	assert.Equal(t, "3", a.GetVolume24h("0xpool").String())
+}
+
+func TestAccumulator_IgnoresInvalidSwaps(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	a := newTestAccumulator(&now)
+
+	a.RecordSwap("0xpool", nil, now)
+	a.RecordSwap("0xpool", big.NewInt(0), now)
+	a.RecordSwap("0xpool", big.NewInt(-5), now)
+	a.RecordSwap("0xpool", big.NewInt(7), now.Add(-Window-time.Minute))
+	a.RecordSwap("0xpool", big.NewInt(9), now.Add(time.Hour))
+
+	assert.Equal(t, "0", a.GetVolume24h("0xpool").String())
+}
+
+func TestAccumulator_RecordReserveChange(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	a := newTestAccumulator(&now)
+
+	a.RecordReserveChange("0xpool", big.NewInt(1000), big.NewInt(1200), now)
+	a.RecordReserveChange("0xpool", big.NewInt(1200), big.NewInt(1150), now)
+	a.RecordReserveChange("0xpool", nil, big.NewInt(1150), now)
+
+	assert.Equal(t, "250", a.GetVolume24h("0xpool").String())
+}
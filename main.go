@@ -1,64 +1,204 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
 	"time"
 
 	"dex-aggregator/config"
 	"dex-aggregator/internal/aggregator"
 	"dex-aggregator/internal/api"
+	"dex-aggregator/internal/api/middleware"
 	"dex-aggregator/internal/cache"
 	"dex-aggregator/internal/collector"
+	"dex-aggregator/internal/graphql"
+	"dex-aggregator/internal/health"
+	"dex-aggregator/internal/history"
+	"dex-aggregator/internal/metrics"
+	"dex-aggregator/internal/monitor"
+	"dex-aggregator/internal/resolver"
 	"dex-aggregator/internal/types"
+	"dex-aggregator/internal/validation"
+	"dex-aggregator/internal/volume"
 
+	gqlhandler "github.com/99designs/gqlgen/graphql/handler"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
 	if err := config.Init(); err != nil {
 		log.Fatalf("Failed to initialize config: %v", err)
 	}
+	// Startup wiring uses one snapshot; reloads are applied by watchConfig
+	cfg := config.Current()
+	if cfg.Server.DevMode && os.Getenv("SERVER_ENV") == "production" {
+		log.Fatal("Dev mode must not be enabled when SERVER_ENV=production")
+	}
 
 	log.Println("Starting DEX Aggregator with optimized configuration...")
 
+	// Pool liquidity scores decay to zero at the same age the health check treats as stale
+	types.LiquidityScoreMaxAge = cfg.Performance.MaxPoolAge
+
+	// appCtx bounds background work such as graph refreshes for the life of the process
+	appCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	poolValidators, err := newPoolValidators(cfg.DEX)
+	if err != nil {
+		log.Fatalf("Invalid DEX configuration: %v", err)
+	}
+
 	// Use two-level cache for better performance
 	store := cache.NewTwoLevelCache(
-		config.AppConfig.Redis.Addr,
-		config.AppConfig.Redis.Password,
-		config.AppConfig.Performance.CacheTTL,
+		cfg.Redis.Addr,
+		cfg.Redis.Password,
+		cfg.Ethereum.ChainID,
+		cfg.Performance.CacheTTL,
+		poolValidators...,
+	)
+	store.SetRetryPolicy(
+		cfg.Redis.MaxRetries,
+		time.Duration(cfg.Redis.RetryBaseDelayMs)*time.Millisecond,
 	)
 
+	// Record reserve changes from the first stored pool on
+	poolHistory := history.NewPoolHistory(history.DefaultPoolHistorySize)
+	store.SetPoolHistory(poolHistory)
+	volumes := volume.NewAccumulator()
+	store.SetVolumeAccumulator(volumes)
+
 	// Fix: Convert []types.Exchange to []*types.Exchange
-	exchangesPtrs := make([]*types.Exchange, len(config.AppConfig.DEX.Exchanges))
-	for i := range config.AppConfig.DEX.Exchanges {
-		exchangesPtrs[i] = &config.AppConfig.DEX.Exchanges[i]
+	exchangesPtrs := make([]*types.Exchange, len(cfg.DEX.Exchanges))
+	for i := range cfg.DEX.Exchanges {
+		exchangesPtrs[i] = &cfg.DEX.Exchanges[i]
 	}
 
-	poolCollector := collector.NewMockPoolCollector(store, exchangesPtrs)
+	poolCollector := collector.NewMockPoolCollector(store, exchangesPtrs...)
 
 	log.Println("Initializing mock pool data...")
 	if err := poolCollector.InitMockPools(); err != nil {
 		log.Fatalf("Failed to initialize mock data: %v", err)
 	}
 
-	router := aggregator.NewRouter(store, config.AppConfig.Performance)
-	handler := api.NewHandler(router, store)
+	defer store.Close()
+
+	router := newRouter(appCtx, store, cfg.Performance)
+	router.SetPoolValidators(poolValidators...)
+	watchConfig(router)
+	if cfg.Performance.WarmUpOnStart {
+		if err := router.WarmCommonQuotes(appCtx); err != nil {
+			log.Printf("Warning: Quote warm-up stopped: %v", err)
+		}
+	}
+	handler := api.NewHandler(router, store, resolver.NewSymbolCache(store, cfg.Performance.SymbolCacheTTL))
+
+	go metrics.SampleInFlightQuotes(appCtx, router, metrics.DefaultSampleInterval)
+
+	if minPools := cfg.Performance.MinHealthyPools; minPools > 0 {
+		monitor.NewPoolHealthMonitor(monitor.DefaultPoolHealthInterval).Start(appCtx, store, minPools, func(alert string) {
+			slog.Error("Pool health alert", "alert", alert)
+			metrics.PoolHealthAlerts.Inc()
+		})
+	}
+
+	router.SetVolumeAccumulator(volumes)
+	handler.SetVolumeAccumulator(volumes)
+
+	quoteHistory := history.NewRingBuffer(cfg.Performance.QuoteHistorySize)
+	router.SetQuoteHistory(quoteHistory)
+	handler.SetQuoteHistory(quoteHistory)
+	handler.SetPoolHistory(poolHistory)
+
+	var contractCaller ethereum.ContractCaller
+	if ethClient := dialEthClient(cfg.Ethereum.RPCURL); ethClient != nil {
+		contractCaller = ethClient
+	}
+	handler.SetTokenResolver(resolver.NewTokenResolver(store, contractCaller, cfg.Ethereum.TokenListURL))
+	if contractCaller != nil {
+		handler.SetNameResolver(resolver.NewENSResolver(store, contractCaller))
+	}
+	handler.AddHealthCheck("redis", health.NewRedisChecker(store, 0))
+	handler.AddHealthCheck("pools", health.NewPoolFreshnessChecker(store, cfg.Performance.MaxPoolAge))
 
 	r := mux.NewRouter()
+	r.Use(middleware.RequestID())
+	r.Use(middleware.ContentNegotiation)
+
+	if cfg.Server.DevMode && cfg.Dev.ArtificialLatencyMs > 0 {
+		log.Printf("Dev mode: adding %dms of artificial latency to every request", cfg.Dev.ArtificialLatencyMs)
+		r.Use(middleware.ArtificialLatency(time.Duration(cfg.Dev.ArtificialLatencyMs) * time.Millisecond))
+	}
+
+	if len(cfg.Server.APIKeys) > 0 {
+		log.Printf("API key authentication enabled for %d keys", len(cfg.Server.APIKeys))
+		r.Use(middleware.APIKeyAuth(cfg.Server.APIKeys))
+	}
+
+	// After APIKeyAuth, so each key owner gets its own quota
+	if cfg.Server.RateLimitRPS > 0 {
+		log.Printf("Rate limiting each client to %g requests/s with bursts of %d", cfg.Server.RateLimitRPS, cfg.Server.RateLimitBurst)
+		r.Use(middleware.RateLimit(cfg.Server.RateLimitRPS, cfg.Server.RateLimitBurst))
+	}
+
+	// Operator routes
+	adminAuth := middleware.AdminTokenAuth(cfg.Server.AdminToken)
+	r.Handle("/api/v1/pools", adminAuth(http.HandlerFunc(handler.CreatePool))).Methods("POST")
+	r.Handle("/api/v1/pools/export", adminAuth(http.HandlerFunc(handler.ExportPools))).Methods("GET")
+	r.Handle("/api/v1/pools/import", adminAuth(http.HandlerFunc(handler.ImportPools))).Methods("POST")
+	r.Handle("/api/v1/pools/bulk-update", adminAuth(http.HandlerFunc(handler.BulkUpdateReserves))).Methods("POST")
+	r.Handle("/api/v1/pools/{address}/pause", adminAuth(http.HandlerFunc(handler.PausePool))).Methods("PATCH")
+	r.Handle("/api/v1/pools/{address}/unpause", adminAuth(http.HandlerFunc(handler.UnpausePool))).Methods("PATCH")
+	r.Handle("/api/v1/pools/{address}/active", adminAuth(http.HandlerFunc(handler.SetPoolActive))).Methods("PATCH")
+	r.Handle("/api/v1/pools/{address}/reserves", adminAuth(http.HandlerFunc(handler.ApplyReserveDelta))).Methods("PATCH")
+	r.Handle("/api/v1/debug/graph.dot", adminAuth(http.HandlerFunc(handler.GetGraphDOT))).Methods("GET")
 
 	// API routes
 	r.HandleFunc("/api/v1/quote", handler.GetQuote).Methods("POST")
+	r.HandleFunc("/api/v1/quote/explain", handler.ExplainQuote).Methods("POST")
+	r.HandleFunc("/api/v1/compare", handler.GetDEXComparison).Methods("GET")
+	r.HandleFunc("/api/v1/quotes/history", handler.GetQuoteHistory).Methods("GET")
+	r.HandleFunc("/api/v1/simulate", handler.Simulate).Methods("POST")
+	r.HandleFunc("/api/v1/multiquote", handler.MultiQuote).Methods("POST")
+	// Not wrapped in CacheHeaders: large pool lists are streamed, and buffering them to
+	// hash an ETag would hold the whole response in memory. GetPools sets the cache
+	// headers itself.
 	r.HandleFunc("/api/v1/pools", handler.GetPools).Methods("GET")
 	r.HandleFunc("/api/v1/pools/search", handler.GetPoolsByTokens).Methods("GET")
+	r.HandleFunc("/api/v1/pools/stats", handler.GetPoolStats).Methods("GET")
+	r.HandleFunc("/api/v1/pools/export.csv", handler.ExportPoolsCSV).Methods("GET")
+	r.HandleFunc("/api/v1/exchanges", handler.GetExchanges).Methods("GET")
+	r.HandleFunc("/api/v1/graph/stats", handler.GetGraphStats).Methods("GET")
+	r.HandleFunc("/api/v1/pools/new", handler.GetNewPools).Methods("GET")
+	r.HandleFunc("/api/v1/pools/duplicates", handler.GetDuplicatePools).Methods("GET")
+	r.HandleFunc("/api/v1/pools/price-alerts", handler.GetPriceAlerts).Methods("GET")
 	r.HandleFunc("/api/v1/pools/{address}", handler.GetPoolByAddress).Methods("GET")
+	r.HandleFunc("/api/v1/pools/{address}/il", handler.GetImpermanentLoss).Methods("GET")
+	r.HandleFunc("/api/v1/pools/{address}/volume", handler.GetPoolVolume).Methods("GET")
+	r.HandleFunc("/api/v1/pools/{address}/history", handler.GetPoolHistory).Methods("GET")
+	r.HandleFunc("/api/v1/tokens", handler.GetTokens).Methods("GET")
+	r.HandleFunc("/api/v1/tokens/{address}/pools", handler.GetTokenPools).Methods("GET")
+	r.HandleFunc("/api/v1/arbitrage", handler.GetArbitrage).Methods("GET")
+	r.Handle("/graphql", gqlhandler.NewDefaultServer(graphql.NewExecutableSchema(graphql.Config{
+		Resolvers: graphql.NewResolver(router, store),
+	}))).Methods("GET", "POST")
 	r.HandleFunc("/health", handler.HealthCheck).Methods("GET")
-	r.HandleFunc("/config", handler.GetConfig).Methods("GET")
+	// Clients may see a hot-reloaded config up to five minutes late
+	r.Handle("/config", middleware.CacheHeaders(300, 60)(http.HandlerFunc(handler.GetConfig))).Methods("GET")
 	r.HandleFunc("/cache/stats", handler.GetCacheStats).Methods("GET")
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
 
 	// Root endpoint with system information
 	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		current := config.Current()
 		fmt.Fprintf(w, `
         <html>
             <head><title>DEX Aggregator - Optimized</title></head>
@@ -74,34 +214,179 @@ func main() {
                 </ul>
                 <p>Available endpoints:</p>
                 <ul>
-                    <li><a href="/api/v1/pools">GET /api/v1/pools</a> - Get all pools</li>
-                    <li><a href="/api/v1/pools/search?tokenA=0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2&tokenB=0xdAC17F958D2ee523a2206206994597C13D831ec7">GET /api/vI/pools/search</a> - Search pools</li>
+                    <li><a href="/api/v1/pools">GET /api/v1/pools</a> - Get all pools (filters: exchange, minReserve0; countOnly=true for the count alone)</li>
+                    <li><a href="/api/v1/pools/export.csv">GET /api/v1/pools/export.csv</a> - Download pools as CSV (filter: exchange)</li>
+                    <li><a href="/api/v1/pools/search?tokenA=0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2&tokenB=0xdAC17F958D2ee523a2206206994597C13D831ec7">GET /api/vI/pools/search</a> - Search pools by token addresses, or by symbol with symbolA and symbolB</li>
+                    <li><a href="/api/v1/tokens">GET /api/v1/tokens</a> - Get all tokens</li>
+                    <li><a href="/api/v1/tokens/0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2/pools">GET /api/v1/tokens/{address}/pools</a> - Pools containing a token (exchange)</li>
+                    <li><a href="/api/v1/exchanges">GET /api/v1/exchanges</a> - Exchanges and pool counts (sort: name, poolCount)</li>
+                    <li><a href="/api/v1/graph/stats">GET /api/v1/graph/stats</a> - Routing graph topology metrics</li>
                     <li><a href="/config">GET /config</a> - View current configuration</li>
                     <li><a href="/cache/stats">GET /cache/stats</a> - Cache performance</li>
+                    <li><a href="/metrics">GET /metrics</a> - Prometheus metrics</li>
+                    <li>GET /api/v1/pools/new - Pools created after ?since=RFC3339</li>
+                    <li><a href="/api/v1/pools/duplicates">GET /api/v1/pools/duplicates</a> - Pairs whose pools disagree on price (thresholdPercent, default 1.0)</li>
+                    <li><a href="/api/v1/pools/price-alerts">GET /api/v1/pools/price-alerts</a> - Pools priced away from their pair's median (threshold, default 5.0; pair=tokenA:tokenB)</li>
+                    <li>GET /api/v1/pools/{address}/il - Impermanent loss (entryReserve0, entryReserve1)</li>
+                    <li>GET /api/v1/pools/{address}/volume - 24 hour swap volume</li>
+                    <li>GET /api/v1/pools/{address}/history - Reserve snapshots, newest first (limit, default 50; since)</li>
                     <li>POST /api/v1/quote - Quote endpoint</li>
-                    <li><a href="/health">GET /health</a> - Health check</li>
+                    <li>POST /api/v1/quote/explain - Plain-language explanation of the chosen route</li>
+                    <li>GET /api/v1/compare - Quote of each exchange on its own (tokenIn, tokenOut, amountIn)</li>
+                    <li><a href="/api/v1/quotes/history">GET /api/v1/quotes/history</a> - Recent quotes, newest first (limit, default 20)</li>
+                    <li>POST /api/v1/simulate - Price curve for up to 20 amounts</li>
+                    <li>POST /api/v1/multiquote - Split one input across up to 10 output tokens</li>
+                    <li>GET|POST /graphql - GraphQL API with the quote and pools queries and the refreshPools mutation</li>
+                    <li>POST /api/v1/pools - Import a pool (requires X-Admin-Token)</li>
+                    <li>PATCH /api/v1/pools/{address}/pause, /unpause, /active - Exclude a pool from routing (requires X-Admin-Token)</li>
+                    <li>PATCH /api/v1/pools/{address}/reserves - Apply signed reserve deltas (requires X-Admin-Token)</li>
+                    <li>POST /api/v1/pools/bulk-update - Set the reserves of many pools from a JSON array (requires X-Admin-Token)</li>
+                    <li>GET /api/v1/pools/export, POST /api/v1/pools/import - Back up and restore pools as NDJSON (requires X-Admin-Token)</li>
+                    <li>GET /api/v1/debug/graph.dot - Routing graph in Graphviz DOT format (requires X-Admin-Token)</li>
+                    <li><a href="/health">GET /health</a> - Health check (Redis and pool freshness)</li>
                 </ul>
             </body>
         </html>
-        `, config.AppConfig.Server.Port, config.AppConfig.Redis.Addr,
+        `, current.Server.Port, current.Redis.Addr,
 			// Change: BaseTokens is now at the top level
-			len(config.AppConfig.BaseTokens),
-			config.AppConfig.Performance.MaxConcurrentPaths,
-			config.AppConfig.Performance.MaxSlippage)
+			len(current.BaseTokens),
+			current.Performance.MaxConcurrentPaths,
+			current.Performance.MaxSlippage)
 	})
 
-	port := ":" + config.AppConfig.Server.Port
+	port := ":" + cfg.Server.Port
 	log.Printf("HTTP server starting on http://localhost%s", port)
 	log.Printf("Performance settings: %d max concurrent paths, %.2f%% max slippage",
-		config.AppConfig.Performance.MaxConcurrentPaths,
-		config.AppConfig.Performance.MaxSlippage)
+		cfg.Performance.MaxConcurrentPaths,
+		cfg.Performance.MaxSlippage)
 
 	server := &http.Server{
 		Addr:         port,
 		Handler:      r,
-		ReadTimeout:  time.Duration(config.AppConfig.Server.ReadTimeout) * time.Second,
-		WriteTimeout: time.Duration(config.AppConfig.Server.WriteTimeout) * time.Second,
+		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(cfg.Server.WriteTimeout) * time.Second,
 	}
 
-	log.Fatal(server.ListenAndServe())
+	listener, err := net.Listen("tcp", port)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", port, err)
+	}
+
+	// The server is already accepting requests, answering local cache misses from Redis
+	// until warming completes
+	go warmLocalCache(appCtx, store)
+
+	log.Fatal(server.Serve(listener))
+}
+
+// warmLocalCache loads the pools already in Redis into the local cache, logging progress
+func warmLocalCache(ctx context.Context, store *cache.TwoLevelCache) {
+	progress := make(chan cache.WarmProgress, 1)
+	store.SetWarmProgress(progress)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for p := range progress {
+			log.Printf("Warming local cache: %d/%d pools loaded", p.Loaded, p.Total)
+		}
+	}()
+
+	start := time.Now()
+	err := store.WarmLocalCacheFromRedis(ctx, cache.DefaultWarmBatchSize)
+	store.SetWarmProgress(nil)
+	close(progress)
+	<-done
+
+	if err != nil {
+		log.Printf("Warning: Local cache warming stopped: %v", err)
+		return
+	}
+	log.Printf("Local cache warmed in %v", time.Since(start).Round(time.Millisecond))
+}
+
+// newRouter creates the router, warm-starting the routing graph from the
+// configured snapshot file when it exists. Without a usable snapshot the graph
+// is built from the cache and a new snapshot is written for the next start.
+func newRouter(ctx context.Context, store cache.Store, perfConfig config.PerformanceConfig) *aggregator.Router {
+	snapshotPath := perfConfig.GraphSnapshotPath
+	if snapshotPath == "" {
+		return aggregator.NewRouter(ctx, store, perfConfig)
+	}
+
+	if f, err := os.Open(snapshotPath); err == nil {
+		router, err := aggregator.NewRouterFromSnapshot(ctx, store, perfConfig, f)
+		f.Close()
+		if err == nil {
+			log.Printf("Warm-started routing graph from %s", snapshotPath)
+			return router
+		}
+		log.Printf("Warning: Failed to load graph snapshot %s: %v", snapshotPath, err)
+	}
+
+	router := aggregator.NewRouter(ctx, store, perfConfig)
+	if err := saveGraphSnapshot(ctx, router, snapshotPath); err != nil {
+		log.Printf("Warning: Failed to save graph snapshot %s: %v", snapshotPath, err)
+	}
+	return router
+}
+
+func saveGraphSnapshot(ctx context.Context, router *aggregator.Router, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return router.SaveGraph(ctx, f)
+}
+
+// watchConfig reloads the YAML config on change and applies performance settings to router
+func watchConfig(router *aggregator.Router) {
+	watcher, err := config.NewWatcher(config.DefaultConfigPath)
+	if err != nil {
+		log.Printf("Warning: Config hot-reload disabled: %v", err)
+		return
+	}
+
+	updates := watcher.Subscribe()
+	go func() {
+		for range updates {
+			cfg := config.Current()
+			router.UpdateConfig(cfg.Performance)
+			router.SetGasCosts(cfg.DEX)
+			router.SetMaxConsecutiveHopsPerDEX(cfg.DEX.MaxConsecutiveHopsPerDEX)
+			router.SetMaxPoolsPerPair(cfg.DEX.MaxPoolsPerPair)
+			router.SetReserveStaleness(cfg.DEX)
+			router.SetPairSlippageOverrides(cfg.DEX)
+			router.SetTokenFilter(cfg.DEX)
+			if validators, err := newPoolValidators(cfg.DEX); err != nil {
+				log.Printf("Warning: Keeping the current pool validators: %v", err)
+			} else {
+				router.SetPoolValidators(validators...)
+			}
+		}
+	}()
+}
+
+// newPoolValidators builds the checks a pool must pass to be stored and routed through
+func newPoolValidators(dexConfig config.DEXConfig) ([]validation.PoolValidator, error) {
+	minLiquidity, err := validation.NewMinLiquidityValidator(dexConfig.MinLiquidityProduct)
+	if err != nil {
+		return nil, err
+	}
+	return []validation.PoolValidator{validation.AddressValidator{}, validation.FeeValidator{}, minLiquidity}, nil
+}
+
+// dialEthClient connects to the Ethereum RPC endpoint, returning nil when it is unreachable
+func dialEthClient(rpcURL string) *ethclient.Client {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client, err := ethclient.DialContext(ctx, rpcURL)
+	if err != nil {
+		log.Printf("Warning: Failed to connect to Ethereum RPC, on-chain token metadata disabled: %v", err)
+		return nil
+	}
+	return client
 }
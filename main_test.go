@@ -51,18 +51,18 @@ func TestConfigInitialization_WithEnvVars(t *testing.T) {
 		t.Fatalf("Failed to initialize config: %v", err)
 	}
 
-	assert.NotNil(t, config.AppConfig)
+	assert.NotNil(t, config.Current())
 
 	// Check values loaded from environment
-	assert.Equal(t, "8080", config.AppConfig.Server.Port)
-	assert.Equal(t, "localhost:6379", config.AppConfig.Redis.Addr)
-	assert.Equal(t, 5, config.AppConfig.Performance.MaxConcurrentPaths)
-	assert.Equal(t, 2.5, config.AppConfig.Performance.MaxSlippage)
-	assert.Equal(t, 60*time.Second, config.AppConfig.Performance.CacheTTL)
-
-	// Check config.AppConfig.BaseTokens (top-level)
-	assert.Equal(t, 4, len(config.AppConfig.BaseTokens))
-	assert.Equal(t, "0xtokenA", config.AppConfig.BaseTokens[0])
+	assert.Equal(t, "8080", config.Current().Server.Port)
+	assert.Equal(t, "localhost:6379", config.Current().Redis.Addr)
+	assert.Equal(t, 5, config.Current().Performance.MaxConcurrentPaths)
+	assert.Equal(t, 2.5, config.Current().Performance.MaxSlippage)
+	assert.Equal(t, 60*time.Second, config.Current().Performance.CacheTTL)
+
+	// Check config.Current().BaseTokens (top-level)
+	assert.Equal(t, 4, len(config.Current().BaseTokens))
+	assert.Equal(t, "0xtokenA", config.Current().BaseTokens[0])
 }
 
 func TestConfigInitialization_Defaults(t *testing.T) {
@@ -77,17 +77,19 @@ func TestConfigInitialization_Defaults(t *testing.T) {
 		t.Fatalf("Failed to initialize config: %v", err)
 	}
 
-	assert.NotNil(t, config.AppConfig)
+	assert.NotNil(t, config.Current())
 
 	// Check default values (assuming config.yaml might not be found or might have defaults)
 	// These checks depend on what's in config.yaml or the hardcoded fallbacks
-	assert.Contains(t, []string{"8080", "8081"}, config.AppConfig.Server.Port) // 8080 is default in YAML and fallback
-	assert.Equal(t, "localhost:6379", config.AppConfig.Redis.Addr)
-	assert.Equal(t, 10, config.AppConfig.Performance.MaxConcurrentPaths) // Default fallback
-	assert.Equal(t, 5.0, config.AppConfig.Performance.MaxSlippage)       // Default fallback
-	assert.Equal(t, 300*time.Second, config.AppConfig.Performance.CacheTTL)
+	assert.Contains(t, []string{"8080", "8081"}, config.Current().Server.Port) // 8080 is default in YAML and fallback
+	assert.Equal(t, "localhost:6379", config.Current().Redis.Addr)
+	assert.Equal(t, 10, config.Current().Performance.MaxConcurrentPaths) // Default fallback
+	assert.Equal(t, 5.0, config.Current().Performance.MaxSlippage)       // Default fallback
+	assert.Equal(t, 300*time.Second, config.Current().Performance.CacheTTL)
+	assert.Equal(t, 300*time.Second, config.Current().Performance.SymbolCacheTTL)
+	assert.LessOrEqual(t, config.Current().DEX.MaxReserveAgeSecs, 0, "reserve age check is off by default")
 
 	// Check default base tokens (fallback)
-	assert.Equal(t, 4, len(config.AppConfig.BaseTokens))
-	assert.Equal(t, "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", config.AppConfig.BaseTokens[0])
+	assert.Equal(t, 4, len(config.Current().BaseTokens))
+	assert.Equal(t, "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", config.Current().BaseTokens[0])
 }